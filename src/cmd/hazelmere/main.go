@@ -7,7 +7,11 @@ import (
 	"github.com/ctfloyd/hazelmere-api/src/internal/cli/backfill"
 	"github.com/ctfloyd/hazelmere-api/src/internal/cli/dump"
 	"github.com/ctfloyd/hazelmere-api/src/internal/cli/fix"
+	"github.com/ctfloyd/hazelmere-api/src/internal/cli/migrate"
+	"github.com/ctfloyd/hazelmere-api/src/internal/cli/restore"
 	"github.com/ctfloyd/hazelmere-api/src/internal/cli/serve"
+	"github.com/ctfloyd/hazelmere-api/src/internal/cli/token"
+	"github.com/ctfloyd/hazelmere-api/src/internal/cli/user"
 )
 
 const usage = `hazelmere - Hazelmere API CLI
@@ -16,11 +20,22 @@ Usage:
   hazelmere <command> [arguments]
 
 Commands:
-  serve                Start the API server
-  dump                 Dump all database collections to JSON files
+  serve                Start the API server (--storage=mongo|memory); run migrate up first
+  dump [DIR]           Dump database collections to JSON files (--since, --user, --collection, --compress)
+  restore DIR          Restore collections from a dump (--database, --collections, --exclude, --drop, --upsert)
   backfill deltas      Backfill delta records from snapshots
-  backfill snapshots   Backfill snapshots from Wise Old Man
+  backfill snapshots   Backfill snapshots from Wise Old Man or an exported file (--users or --all,
+                       --file, --format, --start, --end, --source, --dry-run, --checkpoint)
   fix snapshot-xp      Fix snapshot experience change values
+  migrate status       List schema migrations and whether they are applied
+  migrate up           Apply pending migrations (--to VERSION)
+  migrate down         Revert applied migrations (--steps N, default 1)
+  token issue          Issue a new API token (--name, --scopes, --owner, --expires)
+  token list           List API tokens
+  token rotate ID      Replace the secret of an API token
+  token revoke ID      Revoke an API token
+  user delete ID       Soft delete a user (--hard to remove them with their snapshots and deltas)
+  user export ID       Export everything held about a user as JSON (--out FILE)
 
 Options:
   -h, --help           Show this help message
@@ -29,11 +44,18 @@ Options:
 Examples:
   hazelmere serve
   hazelmere serve -c config/prod.json
+  hazelmere serve --storage=memory
   hazelmere dump
   hazelmere dump ~/backups/hazelmere
+  hazelmere dump ~/backups/hazelmere-incr --since ~/backups/hazelmere --compress zstd
+  hazelmere restore ~/backups/hazelmere --database hazelmere_restore_test
   hazelmere backfill deltas
-  hazelmere backfill snapshots
+  hazelmere backfill snapshots --users msk --start 2025-01-01 --checkpoint backfill.json
+  hazelmere backfill snapshots --users msk --file temple-msk.json
   hazelmere fix snapshot-xp
+  hazelmere migrate up
+  hazelmere token issue --name discord-bot --scopes snapshot:read,worker:trigger --expires 2160h
+  hazelmere user export 4c7a8f8e-2a7e-4bd3-9d8b-3c1f1d5c7f01 --out export.json
 `
 
 func main() {
@@ -79,6 +101,9 @@ func main() {
 	case "dump":
 		err = dump.Run(configPath, filteredArgs)
 
+	case "restore":
+		err = restore.Run(configPath, filteredArgs)
+
 	case "backfill":
 		if len(filteredArgs) < 1 {
 			fmt.Fprintln(os.Stderr, "Error: backfill requires a subcommand (deltas, snapshots)")
@@ -115,6 +140,15 @@ func main() {
 			os.Exit(1)
 		}
 
+	case "migrate":
+		err = migrate.Run(configPath, filteredArgs)
+
+	case "token":
+		err = token.Run(configPath, filteredArgs)
+
+	case "user":
+		err = user.Run(configPath, filteredArgs)
+
 	default:
 		fmt.Fprintf(os.Stderr, "Error: unknown command: %s\n", cmd)
 		fmt.Fprintln(os.Stderr, "Run 'hazelmere --help' for usage")
@@ -2,6 +2,9 @@ package backfill
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log/slog"
 	"maps"
@@ -11,29 +14,94 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/delta"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/hiscore"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/history"
 	"github.com/ctfloyd/hazelmere-api/src/internal/core/snapshot"
 	"github.com/ctfloyd/hazelmere-api/src/internal/core/user"
-	wom2 "github.com/ctfloyd/hazelmere-api/src/internal/dependency/wom"
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
 	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
 	"github.com/ctfloyd/hazelmere-api/src/internal/initialize"
 	"github.com/ctfloyd/hazelmere-commons/pkg/hz_config"
 	"github.com/ctfloyd/hazelmere-commons/pkg/hz_logger"
-	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
-// Users to backfill - can be customized via args in the future
-var needBackfill = []string{"msk"}
+const snapshotsUsage = `Usage: hazelmere backfill snapshots (--users NAME,... | --all) [flags]
+
+Flags:
+  --users NAME,...     Runescape names of the users to backfill
+  --all                Backfill every user with tracking enabled
+  --file PATH          Import history from a TempleOSRS JSON export or hiscore CSV instead of
+                       Wise Old Man. Needs exactly one user in --users
+  --format FORMAT      Format of --file: temple or csv (default: from the file extension)
+  --start DATE         Earliest day to backfill, YYYY-MM-DD or RFC3339 (default: first seen by the source)
+  --end DATE           Last day to backfill, YYYY-MM-DD or RFC3339 (default: now)
+  --source TAG         Source recorded on inserted snapshots (default: WOM_BACKFILL_<MMYYYY> from
+                       Wise Old Man, or the format and file name, e.g. TEMPLE:export.json)
+  --dry-run            Report what would be inserted without writing anything
+  --checkpoint PATH    Record finished users in PATH so an interrupted run can resume`
 
 type snapshotBackfiller struct {
 	userRepo     user.UserRepository
 	snapshotRepo snapshot.SnapshotRepository
-	wiseOldMan   *wom2.Client
+	orchestrator hiscore.HiscoreOrchestrator
+	source       history.HistorySource
 	activityMap  map[string]string
+	options      snapshotOptions
+}
+
+// snapshotOptions are the flags that decide what a backfill inserts. They are stored in the
+// checkpoint so a resumed run can't silently pick up a different user set or date range.
+type snapshotOptions struct {
+	Users  []string           `json:"users,omitempty"`
+	All    bool               `json:"all,omitempty"`
+	File   string             `json:"file,omitempty"`
+	Format history.FileFormat `json:"format,omitempty"`
+	Start  time.Time          `json:"start,omitzero"`
+	End    time.Time          `json:"end,omitzero"`
+	// Source overrides the provenance the history source records. Empty keeps it.
+	Source string `json:"source,omitempty"`
+
+	dryRun         bool
+	checkpointPath string
+}
+
+func (o snapshotOptions) sameRun(other snapshotOptions) bool {
+	return slices.Equal(o.Users, other.Users) &&
+		o.All == other.All &&
+		o.File == other.File &&
+		o.Format == other.Format &&
+		o.Start.Equal(other.Start) &&
+		o.End.Equal(other.End) &&
+		o.Source == other.Source
+}
+
+// snapshotCheckpoint records the users a backfill has finished. Users that were interrupted
+// part way through are not recorded, but rerunning them only fetches the days still missing.
+type snapshotCheckpoint struct {
+	Options snapshotOptions `json:"options"`
+	// DefaultSource is the source used when --source isn't given, fixed when the checkpoint is
+	// started so a run resumed in a later month keeps the tag it began with.
+	DefaultSource string   `json:"defaultSource,omitempty"`
+	Completed     []string `json:"completed"`
 }
 
 func RunSnapshots(configPath string, args []string) error {
+	opts, err := parseSnapshotArgs(args)
+	if err != nil {
+		return err
+	}
+
+	checkpoint, err := loadCheckpoint(opts, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if opts.Source == "" {
+		opts.Source = checkpoint.DefaultSource
+	}
+
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
@@ -52,35 +120,90 @@ func RunSnapshots(configPath string, args []string) error {
 	}
 	defer initialize.MongoCleanup(ctx, client)
 
-	dbName := config.ValueOrPanic("mongo.database.name")
-	sCName := config.ValueOrPanic("mongo.database.collections.snapshot")
-	uCName := config.ValueOrPanic("mongo.database.collections.user")
-
-	sCollection := client.Database(dbName).Collection(sCName)
-	uCollection := client.Database(dbName).Collection(uCName)
-
+	factory := initialize.MongoFactory(config, client)
 	logger := hz_logger.NewZeroLogAdapater(hz_logger.LogLevelInfo)
 	mon := monitor.New(logger)
 
+	source, err := historySource(opts, logger, config)
+	if err != nil {
+		return err
+	}
+
+	userRepo := user.NewUserRepository(factory.NewUserCollection(), mon)
+	snapshotRepo := snapshot.NewSnapshotRepository(factory.NewSnapshotCollection(), mon)
+	snapshotService := snapshot.NewSnapshotService(mon, snapshotRepo, snapshot.NewSnapshotValidator(), userRepo)
+	deltaService := delta.NewDeltaService(mon, delta.NewDeltaRepository(factory.NewDeltaCollection(), mon), delta.NewDeltaCache(), userRepo)
+
 	backfiller := &snapshotBackfiller{
-		userRepo:     user.NewUserRepository(uCollection, mon),
-		snapshotRepo: snapshot.NewSnapshotRepository(sCollection, mon),
-		wiseOldMan:   wom2.NewClient(logger),
-		activityMap:  populateActivityMap(ctx, sCollection),
+		userRepo:     userRepo,
+		snapshotRepo: snapshotRepo,
+		orchestrator: hiscore.NewHiscoreOrchestrator(mon, snapshotService, deltaService, database.NewTransactionManager(client, false)),
+		source:       source,
+		activityMap:  populateActivityMap(ctx, factory.NewSnapshotCollection()),
+		options:      opts,
 	}
 
 	fmt.Println("=== Snapshot Backfill Script ===")
-	fmt.Printf("Database: %s\n", dbName)
-	fmt.Printf("Users to backfill: %v\n\n", needBackfill)
+	fmt.Printf("Database: %s\n", config.ValueOrPanic("mongo.database.name"))
+	if opts.All {
+		fmt.Println("Users: all with tracking enabled")
+	} else {
+		fmt.Printf("Users: %s\n", strings.Join(opts.Users, ", "))
+	}
+	fmt.Printf("History: %s\n", source.Name())
+	fmt.Printf("Range: %s to %s\n", formatBound(opts.Start, "first seen"), formatBound(opts.End, "now"))
+	if opts.Source != "" {
+		fmt.Printf("Source: %s\n", opts.Source)
+	}
+	if opts.dryRun {
+		fmt.Println("Dry run: nothing will be written")
+	}
+	if len(checkpoint.Completed) > 0 {
+		fmt.Printf("Resuming: %d users already complete\n", len(checkpoint.Completed))
+	}
+	fmt.Println()
 
-	users, err := backfiller.userRepo.GetAllUsers(ctx)
+	users, err := backfiller.resolveUsers(ctx)
 	if err != nil {
-		return fmt.Errorf("could not get all users: %w", err)
+		return err
 	}
 
+	var failed []string
 	for _, u := range users {
-		if slices.Contains(needBackfill, u.RunescapeName) && u.TrackingStatus == "ENABLED" {
-			backfiller.backfillMissingDataForUser(ctx, u.RunescapeName, u.Id)
+		if slices.Contains(checkpoint.Completed, u.Id) {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		inserted, err := backfiller.backfillUser(ctx, u)
+		if err != nil {
+			slog.Error("failed to backfill user", slog.String("username", u.RunescapeName), slog.Int("inserted", inserted), slog.Any("error", err))
+			failed = append(failed, u.RunescapeName)
+			continue
+		}
+		slog.Info("backfilled user", slog.String("username", u.RunescapeName), slog.Int("inserted", inserted))
+
+		if opts.dryRun {
+			continue
+		}
+		checkpoint.Completed = append(checkpoint.Completed, u.Id)
+		if err := saveCheckpoint(opts.checkpointPath, checkpoint); err != nil {
+			return err
+		}
+	}
+
+	if ctx.Err() != nil {
+		return fmt.Errorf("backfill interrupted; rerun with the same flags to resume: %w", ctx.Err())
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("backfill failed for %d users: %s", len(failed), strings.Join(failed, ", "))
+	}
+
+	if opts.checkpointPath != "" && !opts.dryRun {
+		if err := os.Remove(opts.checkpointPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove checkpoint: %w", err)
 		}
 	}
 
@@ -88,198 +211,334 @@ func RunSnapshots(configPath string, args []string) error {
 	return nil
 }
 
+func parseSnapshotArgs(args []string) (snapshotOptions, error) {
+	fs := flag.NewFlagSet("backfill snapshots", flag.ContinueOnError)
+	users := fs.String("users", "", "comma separated runescape names to backfill")
+	all := fs.Bool("all", false, "backfill every user with tracking enabled")
+	start := fs.String("start", "", "earliest day to backfill")
+	end := fs.String("end", "", "last day to backfill")
+	file := fs.String("file", "", "import history from a TempleOSRS JSON export or hiscore CSV")
+	format := fs.String("format", "", "format of --file: temple or csv")
+	source := fs.String("source", "", "source recorded on inserted snapshots")
+	dryRun := fs.Bool("dry-run", false, "report what would be inserted without writing")
+	checkpointPath := fs.String("checkpoint", "", "file recording finished users")
+	if err := fs.Parse(args); err != nil {
+		return snapshotOptions{}, err
+	}
+
+	opts := snapshotOptions{
+		All:            *all,
+		File:           *file,
+		Format:         history.FileFormat(*format),
+		Source:         *source,
+		dryRun:         *dryRun,
+		checkpointPath: *checkpointPath,
+	}
+	for _, name := range strings.Split(*users, ",") {
+		if name = strings.TrimSpace(name); name != "" && !slices.Contains(opts.Users, name) {
+			opts.Users = append(opts.Users, name)
+		}
+	}
+	if opts.All == (len(opts.Users) > 0) {
+		return snapshotOptions{}, fmt.Errorf("exactly one of --users or --all is required\n%s", snapshotsUsage)
+	}
+	if opts.File == "" {
+		if opts.Format != "" {
+			return snapshotOptions{}, fmt.Errorf("--format needs --file\n%s", snapshotsUsage)
+		}
+	} else {
+		if len(opts.Users) != 1 {
+			return snapshotOptions{}, fmt.Errorf("--file imports history for exactly one user in --users\n%s", snapshotsUsage)
+		}
+		if opts.Format == "" {
+			detected, ok := history.FileFormatFromPath(opts.File)
+			if !ok {
+				return snapshotOptions{}, fmt.Errorf("cannot tell the format of %s, pass --format\n%s", opts.File, snapshotsUsage)
+			}
+			opts.Format = detected
+		}
+		if !slices.Contains(history.AllFileFormats, opts.Format) {
+			return snapshotOptions{}, fmt.Errorf("unknown format %q\n%s", opts.Format, snapshotsUsage)
+		}
+	}
+
+	var err error
+	if opts.Start, err = parseDay(*start, false); err != nil {
+		return snapshotOptions{}, fmt.Errorf("--start: %w", err)
+	}
+	if opts.End, err = parseDay(*end, true); err != nil {
+		return snapshotOptions{}, fmt.Errorf("--end: %w", err)
+	}
+	if !opts.Start.IsZero() && !opts.End.IsZero() && !opts.Start.Before(opts.End) {
+		return snapshotOptions{}, errors.New("--start must be before --end")
+	}
+	return opts, nil
+}
+
+// parseDay accepts a date or an RFC3339 timestamp. A bare date used as an end bound covers the
+// whole day, so --start 2025-01-01 --end 2025-01-31 includes all of January.
+func parseDay(value string, endOfRange bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	day, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a YYYY-MM-DD date or RFC3339 timestamp", value)
+	}
+	if endOfRange {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day, nil
+}
+
+func formatBound(t time.Time, fallback string) string {
+	if t.IsZero() {
+		return fallback
+	}
+	return t.Format(time.RFC3339)
+}
+
+// defaultSource tags Wise Old Man backfills with the month they ran in. File imports keep the
+// provenance the file records.
+func defaultSource(opts snapshotOptions, now time.Time) string {
+	if opts.File != "" {
+		return ""
+	}
+	return "WOM_BACKFILL_" + now.Format("012006")
+}
+
+// loadCheckpoint reads the checkpoint for opts, or starts an empty one if there isn't a file yet.
+// A new checkpoint takes its default source from now.
+func loadCheckpoint(opts snapshotOptions, now time.Time) (snapshotCheckpoint, error) {
+	checkpoint := snapshotCheckpoint{Options: opts, DefaultSource: defaultSource(opts, now)}
+	if opts.checkpointPath == "" {
+		return checkpoint, nil
+	}
+
+	content, err := os.ReadFile(opts.checkpointPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return checkpoint, nil
+	}
+	if err != nil {
+		return snapshotCheckpoint{}, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var existing snapshotCheckpoint
+	if err := json.Unmarshal(content, &existing); err != nil {
+		return snapshotCheckpoint{}, fmt.Errorf("failed to parse checkpoint %s: %w", opts.checkpointPath, err)
+	}
+	if !existing.Options.sameRun(opts) {
+		return snapshotCheckpoint{}, fmt.Errorf("checkpoint %s was written with different options; rerun with the same flags or remove it", opts.checkpointPath)
+	}
+	existing.Options = opts
+	if existing.DefaultSource == "" {
+		existing.DefaultSource = checkpoint.DefaultSource
+	}
+	return existing, nil
+}
+
+// saveCheckpoint writes through a temporary file so an interrupt never leaves a truncated checkpoint.
+func saveCheckpoint(path string, checkpoint snapshotCheckpoint) error {
+	if path == "" {
+		return nil
+	}
+
+	content, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+func (b *snapshotBackfiller) resolveUsers(ctx context.Context) ([]user.UserData, error) {
+	if b.options.All {
+		users, err := b.userRepo.GetUsersWithTrackingEnabled(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not get users with tracking enabled: %w", err)
+		}
+		return users, nil
+	}
+
+	users := make([]user.UserData, 0, len(b.options.Users))
+	for _, name := range b.options.Users {
+		u, err := b.userRepo.GetUserByRunescapeName(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("could not get user %s: %w", name, err)
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
+
 type missingRange struct {
 	Start, End time.Time
 }
 
-func (b *snapshotBackfiller) backfillMissingDataForUser(ctx context.Context, username string, userId string) {
-	slog.Info("backfilling missing data for user", slog.String("username", username))
+// backfillUser fills the days in the requested range that have no snapshot, returning how many
+// snapshots were inserted (or would be, in a dry run).
+func (b *snapshotBackfiller) backfillUser(ctx context.Context, u user.UserData) (int, error) {
+	slog.Info("backfilling missing data for user", slog.String("username", u.RunescapeName))
+
+	firstSeen, err := b.source.FirstSeen(ctx, u.RunescapeName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get history start from %s: %w", b.source.Name(), err)
+	}
 
-	ranges := b.gatherRanges(ctx, username, userId)
+	times, err := b.snapshotRepo.GetAllTimestampsForUser(ctx, u.Id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get snapshot timestamps: %w", err)
+	}
+	existing := make([]time.Time, 0, len(times))
+	for _, t := range times {
+		existing = append(existing, t.Timestamp)
+	}
+
+	start, end := startOfDay(firstSeen.UTC()), time.Now().UTC()
+	if b.options.Start.After(start) {
+		start = b.options.Start
+	}
+	if !b.options.End.IsZero() && b.options.End.Before(end) {
+		end = b.options.End
+	}
+
+	ranges := missingRanges(existing, start, end)
 	if len(ranges) == 0 {
-		slog.Warn("no ranges for user", slog.String("user", username))
-		return
+		slog.Info("no missing days for user", slog.String("username", u.RunescapeName))
+		return 0, nil
 	}
 
-	womSnapshots := b.getWomSnapshots(ranges, username)
-	slog.Info("no dedupe wom snapshot cnt", slog.Int("cnt", len(womSnapshots)))
-	womSnapshots = dedupeWomSnapshots(womSnapshots)
-	slog.Info("dedupe wom snapshot cnt", slog.Int("cnt", len(womSnapshots)))
+	snapshots, err := b.getSnapshots(ctx, ranges, u.RunescapeName)
+	if err != nil {
+		return 0, err
+	}
+	snapshots = dedupeSnapshots(snapshots)
 
-	for i, ws := range womSnapshots {
-		slog.Info("insert snapshot", slog.String("username", username), slog.Int("idx", i), slog.Int("cnt", len(womSnapshots)))
-		ds := b.convertToSnapshotData(userId, ws)
-		_, err := b.snapshotRepo.InsertSnapshot(ctx, ds)
-		if err != nil {
-			slog.Error("failed to insert snapshot data for user", slog.String("user", username), slog.Any("error", err))
+	if b.options.dryRun {
+		fmt.Printf("%s: would insert %d snapshots across %d missing ranges\n", u.RunescapeName, len(snapshots), len(ranges))
+		return len(snapshots), nil
+	}
+
+	inserted := 0
+	for _, snap := range snapshots {
+		if ctx.Err() != nil {
+			return inserted, ctx.Err()
 		}
+		if _, err := b.orchestrator.CreateHistoricalSnapshotWithDelta(ctx, b.prepare(u.Id, snap)); err != nil {
+			return inserted, fmt.Errorf("failed to insert snapshot at %s: %w", snap.Timestamp.Format(time.RFC3339), err)
+		}
+		inserted++
 	}
+	return inserted, nil
 }
 
-func dedupeWomSnapshots(snapshots []wom2.Snapshot) []wom2.Snapshot {
-	snapshotByDay := make(map[time.Time]wom2.Snapshot)
+// dedupeSnapshots keeps the latest snapshot of each day, oldest day first.
+func dedupeSnapshots(snapshots []snapshot.HiscoreSnapshot) []snapshot.HiscoreSnapshot {
+	snapshotByDay := make(map[time.Time]snapshot.HiscoreSnapshot)
 	for _, s := range snapshots {
-		day := startOfDay(s.CreatedAt)
+		day := startOfDay(s.Timestamp.UTC())
 		if exist, ok := snapshotByDay[day]; ok {
-			if s.CreatedAt.After(exist.CreatedAt) {
+			if s.Timestamp.After(exist.Timestamp) {
 				snapshotByDay[day] = s
 			}
 		} else {
 			snapshotByDay[day] = s
 		}
 	}
-	return slices.Collect(maps.Values(snapshotByDay))
+	deduped := slices.Collect(maps.Values(snapshotByDay))
+	slices.SortFunc(deduped, func(a, b snapshot.HiscoreSnapshot) int { return a.Timestamp.Compare(b.Timestamp) })
+	return deduped
 }
 
-func (b *snapshotBackfiller) getWomSnapshots(ranges []missingRange, username string) []wom2.Snapshot {
-	womSnapshots := make([]wom2.Snapshot, 0, 1000)
+func (b *snapshotBackfiller) getSnapshots(ctx context.Context, ranges []missingRange, username string) ([]snapshot.HiscoreSnapshot, error) {
+	snapshots := make([]snapshot.HiscoreSnapshot, 0, 1000)
 
 	for _, rng := range ranges {
-		if rng.Start.Before(rng.End) {
-			slog.Info("Getting snapshot data from WOM for range", slog.Time("start", rng.Start), slog.Time("end", rng.End), slog.String("username", username))
-			snapshots, err := b.wiseOldMan.GetPlayerSnapshots(username, rng.Start, rng.End)
-			if err != nil {
-				slog.Error("Failed to get snapshot data from WOM for user.",
-					slog.String("user", username),
-					slog.Any("error", err),
-					slog.Time("start", rng.Start),
-					slog.Time("end", rng.End),
-				)
-			}
-
-			if len(snapshots) > 0 {
-				womSnapshots = append(womSnapshots, snapshots...)
-			}
+		slog.Info("Getting snapshot history for range", slog.String("source", b.source.Name()), slog.Time("start", rng.Start), slog.Time("end", rng.End), slog.String("username", username))
+		found, err := b.source.Snapshots(ctx, username, rng.Start, rng.End)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get snapshots from %s between %s and %s: %w", b.source.Name(), rng.Start.Format(time.RFC3339), rng.End.Format(time.RFC3339), err)
 		}
+		snapshots = append(snapshots, found...)
 	}
 
-	return womSnapshots
+	return snapshots, nil
 }
 
-func (b *snapshotBackfiller) gatherRanges(ctx context.Context, username string, userId string) []missingRange {
-	details, err := b.wiseOldMan.GetPlayerDetails(username)
-	if err != nil {
-		slog.Error("failed to get wom player details", slog.String("username", username), slog.Any("error", err))
-		return nil
-	}
-
-	times, err := b.snapshotRepo.GetAllTimestampsForUser(ctx, userId)
-	if err != nil {
-		slog.Error("failed to get all timestamps for user", slog.String("username", username), slog.Any("error", err))
-		return nil
-	}
-
+// missingRanges returns the runs of days between start and end that have no existing snapshot.
+// Ranges are clamped to [start, end) so a partial first or last day isn't widened.
+func missingRanges(existing []time.Time, start, end time.Time) []missingRange {
 	snapshotsByDay := make(map[time.Time]struct{})
-	for _, t := range times {
-		snapshotsByDay[startOfDay(t.Timestamp)] = struct{}{}
+	for _, t := range existing {
+		snapshotsByDay[startOfDay(t.UTC())] = struct{}{}
 	}
 
 	var ranges []missingRange
-
 	rangeStart := time.Time{}
-	for day := startOfDay(details.RegisteredAt); day.Before(endOfDay(time.Now())); day = day.AddDate(0, 0, 1) {
+	for day := startOfDay(start); day.Before(end); day = day.AddDate(0, 0, 1) {
 		if _, ok := snapshotsByDay[day]; !ok {
 			if rangeStart.IsZero() {
 				rangeStart = day
 			}
-		} else {
-			if !rangeStart.IsZero() {
-				ranges = append(ranges, missingRange{Start: rangeStart, End: day})
-				rangeStart = time.Time{}
-			}
+		} else if !rangeStart.IsZero() {
+			ranges = append(ranges, missingRange{Start: rangeStart, End: day})
+			rangeStart = time.Time{}
 		}
 	}
 
 	if !rangeStart.IsZero() {
-		ranges = append(ranges, missingRange{Start: rangeStart, End: startOfDay(time.Now().AddDate(0, 0, 1))})
+		ranges = append(ranges, missingRange{Start: rangeStart, End: end})
 	}
 
+	for i := range ranges {
+		if ranges[i].Start.Before(start) {
+			ranges[i].Start = start
+		}
+	}
 	return ranges
 }
 
-func (b *snapshotBackfiller) convertToSnapshotData(userId string, ss wom2.Snapshot) snapshot.HiscoreSnapshotData {
-	return snapshot.HiscoreSnapshotData{
-		Id:         uuid.NewString(),
-		UserId:     userId,
-		Timestamp:  ss.CreatedAt,
-		Skills:     b.convertSkills(ss.Data.Skills),
-		Bosses:     b.convertBosses(ss.Data.Bosses),
-		Activities: b.convertActivities(ss.Data.Activities),
-		Source:     "WOM_BACKFILL_122025",
+// prepare assigns a history snapshot to userId, names its activities the way existing snapshots
+// do and applies the --source override.
+func (b *snapshotBackfiller) prepare(userId string, snap snapshot.HiscoreSnapshot) snapshot.HiscoreSnapshot {
+	snap.UserId = userId
+	snap.Skills = slices.Clone(snap.Skills)
+	snap.Bosses = slices.Clone(snap.Bosses)
+	snap.Activities = slices.Clone(snap.Activities)
+	if b.options.Source != "" {
+		snap.Source = b.options.Source
 	}
-}
-
-func (b *snapshotBackfiller) convertActivities(a map[string]wom2.SnapshotActivity) []snapshot.ActivitySnapshotData {
-	convert := make([]snapshot.ActivitySnapshotData, 0, len(snapshot.AllActivityActivityTypes))
-	for _, activity := range snapshot.AllActivityActivityTypes {
-		if ac, ok := a[strings.ToLower(string(activity))]; ok {
-			convert = append(convert, snapshot.ActivitySnapshotData{
-				ActivityType: string(activity),
-				Name:         b.activityMap[string(activity)],
-				Rank:         ac.Rank,
-				Score:        ac.Score,
-			})
-		} else {
-			convert = append(convert, snapshot.ActivitySnapshotData{
-				ActivityType: string(activity),
-				Name:         b.activityMap[string(activity)],
-				Rank:         -1,
-				Score:        -1,
-			})
-		}
+	for i := range snap.Skills {
+		snap.Skills[i].Name = b.activityMap[string(snap.Skills[i].ActivityType)]
 	}
-	return convert
-}
-
-func (b *snapshotBackfiller) convertBosses(bosses map[string]wom2.SnapshotBoss) []snapshot.BossSnapshotData {
-	convert := make([]snapshot.BossSnapshotData, 0, len(snapshot.AllBossActivityTypes))
-	for _, boss := range snapshot.AllBossActivityTypes {
-		if bo, ok := bosses[strings.ToLower(string(boss))]; ok {
-			convert = append(convert, snapshot.BossSnapshotData{
-				ActivityType: string(boss),
-				Name:         b.activityMap[string(boss)],
-				Rank:         bo.Rank,
-				KillCount:    bo.Kills,
-			})
-		} else {
-			convert = append(convert, snapshot.BossSnapshotData{
-				ActivityType: string(boss),
-				Name:         b.activityMap[string(boss)],
-				Rank:         -1,
-				KillCount:    -1,
-			})
-		}
+	for i := range snap.Bosses {
+		snap.Bosses[i].Name = b.activityMap[string(snap.Bosses[i].ActivityType)]
+	}
+	for i := range snap.Activities {
+		snap.Activities[i].Name = b.activityMap[string(snap.Activities[i].ActivityType)]
 	}
-	return convert
+	return snap
 }
 
-func (b *snapshotBackfiller) convertSkills(s map[string]wom2.SnapshotSkill) []snapshot.SkillSnapshotData {
-	convert := make([]snapshot.SkillSnapshotData, 0, len(snapshot.AllSkillActivityTypes))
-	for _, skill := range snapshot.AllSkillActivityTypes {
-		key := strings.ToLower(string(skill))
-		if skill == snapshot.ActivityTypeRunecraft {
-			key = "runecrafting"
-		}
-
-		if sk, ok := s[key]; ok {
-			convert = append(convert, snapshot.SkillSnapshotData{
-				ActivityType: string(skill),
-				Name:         b.activityMap[string(skill)],
-				Level:        sk.Level,
-				Experience:   sk.Experience,
-				Rank:         sk.Rank,
-			})
-		} else {
-			convert = append(convert, snapshot.SkillSnapshotData{
-				ActivityType: string(skill),
-				Name:         b.activityMap[string(skill)],
-				Level:        -1,
-				Experience:   -1,
-				Rank:         -1,
-			})
-		}
+// historySource opens the file given by --file, or Wise Old Man when there isn't one.
+func historySource(opts snapshotOptions, logger hz_logger.Logger, config *hz_config.Config) (history.HistorySource, error) {
+	if opts.File == "" {
+		return history.NewWomHistorySource(initialize.InitWomClient(logger, config)), nil
 	}
-	return convert
+	source, err := history.NewFileHistorySource(opts.File, opts.Format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+	return source, nil
 }
 
 func startOfDay(t time.Time) time.Time {
@@ -287,11 +546,6 @@ func startOfDay(t time.Time) time.Time {
 	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
 }
 
-func endOfDay(t time.Time) time.Time {
-	y, m, d := t.Date()
-	return time.Date(y, m, d, 23, 59, 59, 0, t.Location())
-}
-
 func populateActivityMap(ctx context.Context, collection *mongo.Collection) map[string]string {
 	pipeline := mongo.Pipeline{
 		{
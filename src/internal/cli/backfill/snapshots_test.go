@@ -0,0 +1,292 @@
+package backfill
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/delta"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/hiscore"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/history"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/snapshot"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/user"
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	wom2 "github.com/ctfloyd/hazelmere-api/src/internal/dependency/wom"
+	"github.com/ctfloyd/hazelmere-api/src/internal/dependency/wom/womtest"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_logger"
+)
+
+func day(s string) time.Time {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseSnapshotArgs(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		opts, err := parseSnapshotArgs([]string{"--users", "msk, zezima,msk"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(opts.Users) != 2 || opts.Users[0] != "msk" || opts.Users[1] != "zezima" {
+			t.Errorf("users = %v, want [msk zezima]", opts.Users)
+		}
+		if opts.Source != "" {
+			t.Errorf("source = %q, want none until the checkpoint picks the default", opts.Source)
+		}
+		if !opts.Start.IsZero() || !opts.End.IsZero() {
+			t.Errorf("range = %v to %v, want unbounded", opts.Start, opts.End)
+		}
+	})
+
+	t.Run("FileKeepsItsProvenance", func(t *testing.T) {
+		opts, err := parseSnapshotArgs([]string{"--users", "msk", "--file", "history/msk.CSV"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if opts.Format != history.FileFormatCSV {
+			t.Errorf("format = %q, want csv from the extension", opts.Format)
+		}
+		if opts.Source != "" {
+			t.Errorf("source = %q, want none so the file's provenance is kept", opts.Source)
+		}
+	})
+
+	t.Run("DateEndCoversWholeDay", func(t *testing.T) {
+		opts, err := parseSnapshotArgs([]string{"--all", "--start", "2025-01-01", "--end", "2025-01-31"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !opts.Start.Equal(day("2025-01-01")) || !opts.End.Equal(day("2025-02-01")) {
+			t.Errorf("range = %v to %v, want 2025-01-01 to 2025-02-01", opts.Start, opts.End)
+		}
+	})
+
+	for name, args := range map[string][]string{
+		"NoUsers":           {},
+		"UsersAndAll":       {"--all", "--users", "msk"},
+		"BadStart":          {"--all", "--start", "yesterday"},
+		"StartAfterEnd":     {"--all", "--start", "2025-02-01", "--end", "2025-01-01"},
+		"FormatWithoutFile": {"--all", "--format", "csv"},
+		"FileForAll":        {"--all", "--file", "export.json"},
+		"FileForTwoUsers":   {"--users", "msk,zezima", "--file", "export.json"},
+		"UnknownExtension":  {"--users", "msk", "--file", "export.xml"},
+		"UnknownFormat":     {"--users", "msk", "--file", "export.json", "--format", "xml"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parseSnapshotArgs(args); err == nil {
+				t.Errorf("parseSnapshotArgs(%v) succeeded, want error", args)
+			}
+		})
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backfill.json")
+	opts := snapshotOptions{Users: []string{"msk"}, Start: day("2025-01-01"), checkpointPath: path}
+
+	checkpoint, err := loadCheckpoint(opts, day("2025-12-14"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checkpoint.Completed) != 0 {
+		t.Fatalf("completed = %v, want none without a file", checkpoint.Completed)
+	}
+
+	checkpoint.Completed = append(checkpoint.Completed, "user-1")
+	if err := saveCheckpoint(path, checkpoint); err != nil {
+		t.Fatal(err)
+	}
+
+	// Resuming in a later month keeps the default source the run started with.
+	resumed, err := loadCheckpoint(opts, day("2026-01-02"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resumed.Completed) != 1 || resumed.Completed[0] != "user-1" {
+		t.Errorf("completed = %v, want [user-1]", resumed.Completed)
+	}
+	if resumed.DefaultSource != "WOM_BACKFILL_122025" {
+		t.Errorf("default source = %q, want WOM_BACKFILL_122025 from when the checkpoint started", resumed.DefaultSource)
+	}
+
+	opts.dryRun = true
+	if _, err := loadCheckpoint(opts, day("2026-01-02")); err != nil {
+		t.Errorf("dry run should not change the run: %v", err)
+	}
+
+	opts.Source = "OTHER"
+	if _, err := loadCheckpoint(opts, day("2026-01-02")); err == nil {
+		t.Error("loadCheckpoint with different options succeeded, want error")
+	}
+
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary checkpoint left behind: %v", err)
+	}
+}
+
+func TestMissingRanges(t *testing.T) {
+	existing := []time.Time{
+		day("2025-01-03").Add(5 * time.Hour),
+		day("2025-01-04").Add(20 * time.Hour),
+		day("2025-01-07"),
+	}
+	end := day("2025-01-09").Add(12 * time.Hour)
+
+	ranges := missingRanges(existing, day("2025-01-01").Add(6*time.Hour), end)
+	want := []missingRange{
+		{Start: day("2025-01-01").Add(6 * time.Hour), End: day("2025-01-03")},
+		{Start: day("2025-01-05"), End: day("2025-01-07")},
+		{Start: day("2025-01-08"), End: end},
+	}
+	if len(ranges) != len(want) {
+		t.Fatalf("ranges = %v, want %v", ranges, want)
+	}
+	for i := range want {
+		if !ranges[i].Start.Equal(want[i].Start) || !ranges[i].End.Equal(want[i].End) {
+			t.Errorf("range %d = %v, want %v", i, ranges[i], want[i])
+		}
+	}
+
+	if ranges := missingRanges(existing, day("2025-01-03"), day("2025-01-05")); len(ranges) != 0 {
+		t.Errorf("ranges = %v, want none when every day has a snapshot", ranges)
+	}
+}
+
+func TestDedupeSnapshots(t *testing.T) {
+	snapshots := []snapshot.HiscoreSnapshot{
+		{Timestamp: day("2025-01-02").Add(3 * time.Hour)},
+		{Timestamp: day("2025-01-01").Add(9 * time.Hour)},
+		{Timestamp: day("2025-01-02").Add(8 * time.Hour)},
+		{Timestamp: day("2025-01-01").Add(1 * time.Hour)},
+	}
+
+	deduped := dedupeSnapshots(snapshots)
+	want := []time.Time{day("2025-01-01").Add(9 * time.Hour), day("2025-01-02").Add(8 * time.Hour)}
+	if len(deduped) != len(want) {
+		t.Fatalf("got %d snapshots, want %d", len(deduped), len(want))
+	}
+	for i := range want {
+		if !deduped[i].Timestamp.Equal(want[i]) {
+			t.Errorf("snapshot %d at %v, want %v", i, deduped[i].Timestamp, want[i])
+		}
+	}
+}
+
+type backfillFixture struct {
+	backfiller *snapshotBackfiller
+	server     *womtest.Server
+	user       user.UserData
+	deltaRepo  delta.DeltaRepository
+}
+
+// newBackfillFixture wires a backfiller to memory repositories and a fake WOM serving the
+// hazel_tester fixture, so a backfill runs end to end without Mongo or the network.
+func newBackfillFixture(t *testing.T, opts snapshotOptions) backfillFixture {
+	logger := hz_logger.NewZeroLogAdapater(hz_logger.LogLevelError)
+	mon := monitor.New(logger)
+	userRepo := user.NewMemoryUserRepository(mon)
+	snapshotRepo := snapshot.NewMemorySnapshotRepository(mon)
+	deltaRepo := delta.NewMemoryDeltaRepository(mon)
+
+	u, err := userRepo.CreateUser(context.Background(), user.UserData{Id: "1f0c5b2e-6a4d-4e8a-9c3b-7d2e1f0a9b8c", RunescapeName: "Hazel Tester", TrackingStatus: "ENABLED"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshotService := snapshot.NewSnapshotService(mon, snapshotRepo, snapshot.NewSnapshotValidator(), userRepo)
+	deltaService := delta.NewDeltaService(mon, deltaRepo, delta.NewDeltaCache(), userRepo)
+	server := womtest.NewServer(t, womtest.Fixture(t, "hazel_tester"))
+
+	return backfillFixture{
+		backfiller: &snapshotBackfiller{
+			userRepo:     userRepo,
+			snapshotRepo: snapshotRepo,
+			orchestrator: hiscore.NewHiscoreOrchestrator(mon, snapshotService, deltaService, database.NewTransactionManager(nil, false)),
+			source:       history.NewWomHistorySource(wom2.NewClient(logger, server.Config())),
+			activityMap:  map[string]string{},
+			options:      opts,
+		},
+		server:    server,
+		user:      u,
+		deltaRepo: deltaRepo,
+	}
+}
+
+func TestBackfillUser(t *testing.T) {
+	ctx := context.Background()
+	f := newBackfillFixture(t, snapshotOptions{Users: []string{"Hazel Tester"}, Source: "TEST"})
+
+	inserted, err := f.backfiller.backfillUser(ctx, f.user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inserted != 3 {
+		t.Errorf("inserted %d snapshots, want one for each of the 3 days in the fixture", inserted)
+	}
+
+	deltas, err := f.deltaRepo.GetAllDeltasForUser(ctx, f.user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deltas) != 2 {
+		t.Errorf("got %d deltas, want 2 linking the 3 snapshots", len(deltas))
+	}
+
+	inserted, err = f.backfiller.backfillUser(ctx, f.user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inserted != 0 {
+		t.Errorf("rerun inserted %d snapshots, want none once every fixture day is filled", inserted)
+	}
+}
+
+func TestBackfillUserRange(t *testing.T) {
+	f := newBackfillFixture(t, snapshotOptions{Users: []string{"Hazel Tester"}, Start: day("2025-01-02"), End: day("2025-01-03"), Source: "TEST"})
+
+	inserted, err := f.backfiller.backfillUser(context.Background(), f.user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inserted != 1 {
+		t.Errorf("inserted %d snapshots, want only the one from 2025-01-02", inserted)
+	}
+}
+
+func TestBackfillUserDryRun(t *testing.T) {
+	ctx := context.Background()
+	f := newBackfillFixture(t, snapshotOptions{Users: []string{"Hazel Tester"}, Source: "TEST", dryRun: true})
+
+	inserted, err := f.backfiller.backfillUser(ctx, f.user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inserted != 3 {
+		t.Errorf("dry run reported %d snapshots, want 3", inserted)
+	}
+
+	times, err := f.backfiller.snapshotRepo.GetAllTimestampsForUser(ctx, f.user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(times) != 0 {
+		t.Errorf("dry run wrote %d snapshots", len(times))
+	}
+}
+
+func TestBackfillUserNotOnWom(t *testing.T) {
+	f := newBackfillFixture(t, snapshotOptions{Users: []string{"Hazel Tester"}, Source: "TEST"})
+	f.user.RunescapeName = "Nobody"
+
+	_, err := f.backfiller.backfillUser(context.Background(), f.user)
+	if !errors.Is(err, history.ErrPlayerNotFound) {
+		t.Errorf("err = %v, want ErrPlayerNotFound", err)
+	}
+}
@@ -1,13 +1,20 @@
 package dump
 
 import (
+	"bufio"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/ctfloyd/hazelmere-api/src/internal/initialize"
@@ -17,46 +24,52 @@ import (
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
+const usage = `Usage:
+  hazelmere dump [DIR] [--since TIMESTAMP|PREVIOUS_DIR] [--user ID] [--collection A,B] [--compress none|gzip|zstd]`
+
+// incrementalNote is printed with every incremental dump, since it holds less than the name suggests.
+const incrementalNote = `Note: --since selects snapshots, deltas and audit records by when they were captured, not when
+they were written. Snapshots backfilled with older timestamps, snapshots and deltas moved by a
+user merge, and deletions are not included; take a full dump after backfills, merges or deletes.`
+
 const (
-	fetchBatchSize  = 1000 // Documents to fetch per batch from MongoDB
-	jsonWorkerCount = 4    // Parallel workers for JSON conversion per batch
-	globalWorkers   = 8    // Global worker pool size for processing batches
+	fetchBatchSize   = 1000 // Documents to fetch per range query
+	jsonWorkerCount  = 4    // Parallel workers for JSON conversion per batch
+	collectionWorker = 4    // Collections dumped at once
+	writeBufferSize  = 8 * 1024 * 1024
 )
 
-type collectionResult struct {
-	name     string
-	count    int64
-	duration time.Duration
-	err      error
+// collectionScope names the fields a collection can be filtered on. An empty field means the
+// collection cannot be scoped that way. timestampField is when a document was captured, not when
+// it was last written, so only collections whose documents never change once written have one.
+type collectionScope struct {
+	timestampField string
+	userField      string
 }
 
-// batchJob represents a single batch of work to fetch and convert
-type batchJob struct {
-	collName   string
-	batchNum   int
-	skip       int64
-	limit      int64
-	totalDocs  int64
-	collection *mongo.Collection
-	resultChan chan<- batchResult
+type dumpOptions struct {
+	outputDir   string
+	since       *time.Time
+	userId      string
+	collections map[string]bool
+	compression Compression
 }
 
-// batchResult contains the processed batch data ready for writing
-type batchResult struct {
-	batchNum  int
-	jsonDocs  [][]byte
-	fetchTime time.Duration
-	jsonTime  time.Duration
-	err       error
+type collectionResult struct {
+	entry    CollectionEntry
+	duration time.Duration
+	err      error
 }
 
 func Run(configPath string, args []string) error {
+	opts, err := parseArgs(args)
+	if err != nil {
+		return err
+	}
+
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
-	// Parse output directory from args or use default
-	outputDir := getOutputDir(args)
-
 	config := hz_config.NewConfigFromPath(configPath)
 	if err := config.Read(); err != nil {
 		return fmt.Errorf("failed to read config: %w", err)
@@ -75,12 +88,89 @@ func Run(configPath string, args []string) error {
 	dbName := config.ValueOrPanic("mongo.database.name")
 	db := client.Database(dbName)
 
+	scopes := map[string]collectionScope{
+		config.ValueOrPanic("mongo.database.collections.snapshot"): {timestampField: "timestamp", userField: "userId"},
+		config.ValueOrPanic("mongo.database.collections.delta"):    {timestampField: "timestamp", userField: "userId"},
+		config.ValueOrPanic("mongo.database.collections.audit"):    {timestampField: "timestamp"},
+		config.ValueOrPanic("mongo.database.collections.job"):      {userField: "userId"},
+		config.ValueOrPanic("mongo.database.collections.user"):     {userField: "_id"},
+		config.ValueOrPanic("mongo.database.collections.group"):    {userField: "memberships.userId"},
+	}
+
 	fmt.Println("=== MongoDB Collection Dump ===")
 	fmt.Printf("Database: %s\n", dbName)
-	fmt.Printf("Output Directory: %s\n", outputDir)
-	fmt.Printf("Global Workers: %d\n\n", globalWorkers)
+	fmt.Printf("Output Directory: %s\n", opts.outputDir)
+	fmt.Printf("Compression: %s\n", opts.compression)
+	if opts.since != nil {
+		fmt.Printf("Since: %s\n", opts.since.Format(time.RFC3339))
+		fmt.Println(incrementalNote)
+	}
+	if opts.userId != "" {
+		fmt.Printf("User: %s\n", opts.userId)
+	}
+	fmt.Println()
+
+	return dumpAllCollections(ctx, db, scopes, opts)
+}
+
+func parseArgs(args []string) (dumpOptions, error) {
+	fs := flag.NewFlagSet("dump", flag.ContinueOnError)
+	since := fs.String("since", "", "only export snapshots, deltas and audit records captured at or after an RFC3339 timestamp, or the start of the dump in a previous dump directory")
+	userId := fs.String("user", "", "only export the user and the snapshots and deltas belonging to this user id")
+	collections := fs.String("collection", "", "comma separated collections to export (default: all)")
+	compress := fs.String("compress", string(CompressionNone), "compress collection files: none, gzip or zstd")
+
+	// Allow the directory before or after the flags.
+	var outputDir string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		outputDir, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return dumpOptions{}, err
+	}
+	if outputDir == "" {
+		outputDir = getOutputDir(fs.Args())
+	}
+
+	compression, ok := CompressionFromValue(*compress)
+	if !ok {
+		return dumpOptions{}, fmt.Errorf("unknown compression %q\n%s", *compress, usage)
+	}
+
+	opts := dumpOptions{
+		outputDir:   outputDir,
+		userId:      *userId,
+		collections: make(map[string]bool),
+		compression: compression,
+	}
+	for _, name := range strings.Split(*collections, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			opts.collections[name] = true
+		}
+	}
+	if *since != "" {
+		t, err := parseSince(*since)
+		if err != nil {
+			return dumpOptions{}, err
+		}
+		opts.since = &t
+	}
+	return opts, nil
+}
 
-	return dumpAllCollections(ctx, db, outputDir)
+// parseSince accepts a timestamp, or a previous dump directory whose start time becomes the
+// lower bound. Using the start rather than the end means documents written while the previous
+// dump ran are exported again rather than missed.
+func parseSince(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+
+	manifest, err := ReadManifest(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("--since must be an RFC3339 timestamp or a dump directory with a %s: %w", ManifestFileName, err)
+	}
+	return manifest.StartedAt, nil
 }
 
 func getOutputDir(args []string) string {
@@ -98,385 +188,378 @@ func getOutputDir(args []string) string {
 	return filepath.Join(homeDir, "Documents", fmt.Sprintf("hazelmere_dmp_%s", dateStr))
 }
 
-func dumpAllCollections(ctx context.Context, db *mongo.Database, outputDir string) error {
-	// Create output directory
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
+// collectionFilter returns the query for a collection, and whether it should be exported at all.
+// Collections that cannot be scoped to a user are skipped by --user; collections without a
+// timestamp are exported in full by --since.
+func collectionFilter(scope collectionScope, opts dumpOptions) (bson.D, bool, bool) {
+	filter := bson.D{}
+	incremental := false
+	if opts.userId != "" {
+		if scope.userField == "" {
+			return nil, false, false
+		}
+		filter = append(filter, bson.E{Key: scope.userField, Value: opts.userId})
+	}
+	if opts.since != nil && scope.timestampField != "" {
+		filter = append(filter, bson.E{Key: scope.timestampField, Value: bson.M{"$gte": *opts.since}})
+		incremental = true
+	}
+	return filter, incremental, true
+}
+
+func dumpAllCollections(ctx context.Context, db *mongo.Database, scopes map[string]collectionScope, opts dumpOptions) error {
+	startTime := time.Now().UTC()
+
+	if err := os.MkdirAll(opts.outputDir, 0755); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	// List all collections
 	fmt.Println("Fetching collection list...")
 	collections, err := db.ListCollectionNames(ctx, bson.M{})
 	if err != nil {
 		return fmt.Errorf("failed to list collections: %w", err)
 	}
+	slices.Sort(collections)
 
-	if len(collections) == 0 {
-		fmt.Println("No collections found in database")
-		return nil
-	}
-
-	fmt.Printf("Found %d collections: %v\n\n", len(collections), collections)
-
-	// Get document counts for all collections first
-	fmt.Println("Counting documents in each collection...")
-	collectionCounts := make(map[string]int64)
-	var totalDocsExpected int64
-	var totalBatches int
-	for _, collName := range collections {
-		count, err := db.Collection(collName).CountDocuments(ctx, bson.M{})
-		if err != nil {
-			fmt.Printf("  WARNING: Could not count %s: %v\n", collName, err)
-			collectionCounts[collName] = 0
-		} else {
-			collectionCounts[collName] = count
-			totalDocsExpected += count
-			numBatches := (count + int64(fetchBatchSize) - 1) / int64(fetchBatchSize)
-			totalBatches += int(numBatches)
-			fmt.Printf("  %s: %d documents (%d batches)\n", collName, count, numBatches)
+	for name := range opts.collections {
+		if !slices.Contains(collections, name) {
+			return fmt.Errorf("collection %s does not exist in %s", name, db.Name())
 		}
 	}
-	fmt.Printf("\nTotal documents to dump: %d across %d batches\n", totalDocsExpected, totalBatches)
-	fmt.Printf("Global worker pool: %d workers, batch size: %d, JSON workers per batch: %d\n\n", globalWorkers, fetchBatchSize, jsonWorkerCount)
 
-	startTime := time.Now()
-
-	// Create global job queue for all batches across all collections
-	jobQueue := make(chan batchJob, totalBatches)
-
-	// Track active workers
-	var activeWorkers atomic.Int32
-	activeWorkers.Store(int32(globalWorkers))
-
-	// Start global worker pool
-	var workerWg sync.WaitGroup
-	for w := 0; w < globalWorkers; w++ {
-		workerWg.Add(1)
-		go func(workerID int) {
-			defer workerWg.Done()
-			defer activeWorkers.Add(-1)
-			for job := range jobQueue {
-				processBatchJob(ctx, workerID, job)
-			}
-		}(w)
-	}
-
-	// Start a writer goroutine for each collection
-	var writerWg sync.WaitGroup
-	resultChans := make(map[string]chan batchResult)
-	collResults := make(chan collectionResult, len(collections))
-
-	for _, collName := range collections {
-		count := collectionCounts[collName]
-		numBatches := int((count + int64(fetchBatchSize) - 1) / int64(fetchBatchSize))
-		if count == 0 {
-			numBatches = 0
-		}
-
-		resultChan := make(chan batchResult, numBatches+1)
-		resultChans[collName] = resultChan
-
-		writerWg.Add(1)
-		go func(collName string, expectedCount int64, numBatches int, resultChan <-chan batchResult) {
-			defer writerWg.Done()
-			result := writeCollectionBatches(ctx, collName, outputDir, expectedCount, numBatches, resultChan, &activeWorkers)
-			collResults <- result
-		}(collName, count, numBatches, resultChan)
-	}
-
-	// Enqueue all batch jobs
-	for _, collName := range collections {
-		count := collectionCounts[collName]
-		if count == 0 {
-			close(resultChans[collName])
+	var names []string
+	for _, name := range collections {
+		if len(opts.collections) > 0 && !opts.collections[name] {
 			continue
 		}
-
-		collection := db.Collection(collName)
-		resultChan := resultChans[collName]
-		numBatches := int((count + int64(fetchBatchSize) - 1) / int64(fetchBatchSize))
-
-		for b := 0; b < numBatches; b++ {
-			jobQueue <- batchJob{
-				collName:   collName,
-				batchNum:   b,
-				skip:       int64(b) * int64(fetchBatchSize),
-				limit:      int64(fetchBatchSize),
-				totalDocs:  count,
-				collection: collection,
-				resultChan: resultChan,
-			}
+		if _, _, ok := collectionFilter(scopes[name], opts); !ok {
+			fmt.Printf("  SKIP    [%s]: cannot be filtered by user\n", name)
+			continue
 		}
+		names = append(names, name)
 	}
-	close(jobQueue)
+	if len(names) == 0 {
+		fmt.Println("No collections to dump")
+		return nil
+	}
+	fmt.Printf("Dumping %d collections: %v\n\n", len(names), names)
 
-	// Wait for all workers to finish processing
-	workerWg.Wait()
+	results := make([]collectionResult, len(names))
+	semaphore := make(chan struct{}, collectionWorker)
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
 
-	// Close all result channels to signal writers to finish
-	for _, collName := range collections {
-		if collectionCounts[collName] > 0 {
-			close(resultChans[collName])
-		}
+			filter, incremental, _ := collectionFilter(scopes[name], opts)
+			results[i] = dumpCollection(ctx, db.Collection(name), filter, opts)
+			results[i].entry.Incremental = incremental
+		}()
 	}
+	wg.Wait()
 
-	// Wait for all writers to finish
-	writerWg.Wait()
-	close(collResults)
-
-	// Collect results
-	var totalDocs int64
-	var totalBytes int64
-	var results []collectionResult
-	for result := range collResults {
-		results = append(results, result)
+	manifest := Manifest{
+		Version:     manifestVersion,
+		Database:    db.Name(),
+		StartedAt:   startTime,
+		Since:       opts.since,
+		UserId:      opts.userId,
+		Compression: opts.compression,
+	}
+	var totalDocs, totalBytes int64
+	var failed []string
+	for _, result := range results {
 		if result.err != nil {
-			fmt.Printf("  ERROR   [%s]: %v\n", result.name, result.err)
-		} else {
-			fmt.Printf("  DONE    [%s]: %d documents in %v\n", result.name, result.count, result.duration.Round(time.Millisecond))
-			totalDocs += result.count
+			fmt.Printf("  ERROR   [%s]: %v\n", result.entry.Name, result.err)
+			failed = append(failed, result.entry.Name)
+			continue
 		}
+		fmt.Printf("  DONE    [%s]: %d documents in %v\n", result.entry.Name, result.entry.Documents, result.duration.Round(time.Millisecond))
+		manifest.Collections = append(manifest.Collections, result.entry)
+		totalDocs += result.entry.Documents
+		totalBytes += result.entry.Bytes
 	}
 
-	totalDuration := time.Since(startTime)
+	// An incomplete dump gets no manifest, so it can neither be restored with verification nor
+	// used as the base of an incremental dump.
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to dump: %s", strings.Join(failed, ", "))
+	}
 
-	// Calculate total bytes written
-	for _, collName := range collections {
-		outputPath := filepath.Join(outputDir, fmt.Sprintf("%s.json", collName))
-		if info, err := os.Stat(outputPath); err == nil {
-			totalBytes += info.Size()
-		}
+	manifest.FinishedAt = time.Now().UTC()
+	if err := writeManifest(opts.outputDir, manifest); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
 	}
 
+	totalDuration := time.Since(startTime)
 	fmt.Printf("\n=====================================\n")
 	fmt.Printf("           DUMP COMPLETE             \n")
 	fmt.Printf("=====================================\n")
-	fmt.Printf("Collections dumped: %d\n", len(collections))
+	fmt.Printf("Collections dumped: %d\n", len(manifest.Collections))
 	fmt.Printf("Total documents:    %d\n", totalDocs)
 	fmt.Printf("Total size:         %s\n", formatBytes(totalBytes))
 	fmt.Printf("Total time:         %v\n", totalDuration.Round(time.Millisecond))
 	fmt.Printf("Throughput:         %.0f docs/sec\n", float64(totalDocs)/totalDuration.Seconds())
-	fmt.Printf("Output directory:   %s\n", outputDir)
+	fmt.Printf("Output directory:   %s\n", opts.outputDir)
 	fmt.Printf("=====================================\n")
 
-	// Print file summary
 	fmt.Printf("\nOutput files:\n")
-	for _, collName := range collections {
-		outputPath := filepath.Join(outputDir, fmt.Sprintf("%s.json", collName))
-		if info, err := os.Stat(outputPath); err == nil {
-			fmt.Printf("  %s.json: %s\n", collName, formatBytes(info.Size()))
-		}
-	}
-
-	// Print error summary if any
-	var errors []collectionResult
-	for _, r := range results {
-		if r.err != nil {
-			errors = append(errors, r)
-		}
-	}
-	if len(errors) > 0 {
-		fmt.Printf("\nErrors (%d):\n", len(errors))
-		for _, e := range errors {
-			fmt.Printf("  - %s: %v\n", e.name, e.err)
-		}
+	for _, c := range manifest.Collections {
+		fmt.Printf("  %s: %s sha256:%s\n", c.File, formatBytes(c.Bytes), c.SHA256)
 	}
-
 	return nil
 }
 
-func processBatchJob(ctx context.Context, workerID int, job batchJob) {
-	fetchStart := time.Now()
+// dumpCollection pages through the collection in _id order, resuming each batch after the last
+// _id seen so every query is an index range scan rather than a growing skip.
+func dumpCollection(ctx context.Context, collection *mongo.Collection, filter bson.D, opts dumpOptions) collectionResult {
+	start := time.Now()
+	name := collection.Name()
+	fileName := name + opts.compression.Extension()
+	result := collectionResult{entry: CollectionEntry{Name: name, File: fileName}}
 
-	opts := options.Find().
-		SetBatchSize(int32(job.limit)).
-		SetLimit(job.limit).
-		SetSkip(job.skip)
+	expected, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		result.err = fmt.Errorf("count failed: %w", err)
+		return result
+	}
+	fmt.Printf("  START   [%s]: Expecting %d documents\n", name, expected)
 
-	cursor, err := job.collection.Find(ctx, bson.M{}, opts)
+	file, err := os.Create(filepath.Join(opts.outputDir, fileName))
 	if err != nil {
-		job.resultChan <- batchResult{batchNum: job.batchNum, err: fmt.Errorf("fetch failed: %w", err)}
-		return
+		result.err = fmt.Errorf("failed to create output file: %w", err)
+		return result
 	}
+	defer file.Close()
 
-	var batchDocs []bson.Raw
-	for cursor.Next(ctx) {
-		var raw bson.Raw
-		if err := cursor.Decode(&raw); err != nil {
-			cursor.Close(ctx)
-			job.resultChan <- batchResult{batchNum: job.batchNum, err: fmt.Errorf("decode failed: %w", err)}
-			return
+	writer, err := newCollectionWriter(file, opts.compression)
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	// Fetch the next batch while the current one is converted and written.
+	batches := make(chan []bson.Raw, 2)
+	fetchErr := make(chan error, 1)
+	fetchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		defer close(batches)
+		fetchErr <- fetchBatches(fetchCtx, collection, filter, batches)
+	}()
+
+	for batch := range batches {
+		jsonDocs, err := toExtJSON(batch)
+		if err == nil {
+			err = writer.writeDocuments(jsonDocs)
+		}
+		if err != nil {
+			cancel()
+			for range batches {
+			}
+			result.err = err
+			return result
 		}
-		rawCopy := make(bson.Raw, len(raw))
-		copy(rawCopy, raw)
-		batchDocs = append(batchDocs, rawCopy)
+
+		pct := 100.0
+		if expected > 0 {
+			pct = float64(writer.documents) / float64(expected) * 100
+		}
+		fmt.Printf("  WRITE   [%s]: %d docs - %d/%d (%.1f%%)\n", name, len(batch), writer.documents, expected, pct)
+	}
+	if err := <-fetchErr; err != nil {
+		result.err = err
+		return result
 	}
-	cursor.Close(ctx)
 
-	if err := cursor.Err(); err != nil {
-		job.resultChan <- batchResult{batchNum: job.batchNum, err: fmt.Errorf("cursor error: %w", err)}
-		return
+	if err := writer.close(); err != nil {
+		result.err = err
+		return result
 	}
 
-	fetchTime := time.Since(fetchStart)
+	result.entry.Documents = writer.documents
+	result.entry.Bytes = writer.bytes
+	result.entry.SHA256 = hex.EncodeToString(writer.hash.Sum(nil))
+	result.duration = time.Since(start)
+	return result
+}
+
+func fetchBatches(ctx context.Context, collection *mongo.Collection, filter bson.D, batches chan<- []bson.Raw) error {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetLimit(fetchBatchSize).
+		SetBatchSize(fetchBatchSize)
+
+	var lastId *bson.RawValue
+	for {
+		query := filter
+		if lastId != nil {
+			query = bson.D{{Key: "$and", Value: bson.A{filter, bson.D{{Key: "_id", Value: bson.M{"$gt": *lastId}}}}}}
+		}
 
-	// Convert to JSON in parallel
-	jsonStart := time.Now()
-	jsonDocs := make([][]byte, len(batchDocs))
-	jsonErrors := make([]error, len(batchDocs))
+		cursor, err := collection.Find(ctx, query, opts)
+		if err != nil {
+			return fmt.Errorf("fetch failed: %w", err)
+		}
 
-	var wg sync.WaitGroup
-	docChan := make(chan int, len(batchDocs))
+		var batch []bson.Raw
+		for cursor.Next(ctx) {
+			rawCopy := make(bson.Raw, len(cursor.Current))
+			copy(rawCopy, cursor.Current)
+			batch = append(batch, rawCopy)
+		}
+		cursor.Close(ctx)
+		if err := cursor.Err(); err != nil {
+			return fmt.Errorf("cursor error: %w", err)
+		}
+		if len(batch) == 0 {
+			return nil
+		}
 
-	numJsonWorkers := jsonWorkerCount
-	if len(batchDocs) < numJsonWorkers {
-		numJsonWorkers = len(batchDocs)
+		id, err := batch[len(batch)-1].LookupErr("_id")
+		if err != nil {
+			return fmt.Errorf("document without _id: %w", err)
+		}
+		lastId = &id
+
+		select {
+		case batches <- batch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if len(batch) < fetchBatchSize {
+			return nil
+		}
 	}
+}
+
+// toExtJSON converts documents to canonical Extended JSON in parallel, keeping their order.
+func toExtJSON(docs []bson.Raw) ([][]byte, error) {
+	jsonDocs := make([][]byte, len(docs))
+	jsonErrors := make([]error, len(docs))
 
-	for w := 0; w < numJsonWorkers; w++ {
+	var wg sync.WaitGroup
+	docChan := make(chan int, len(docs))
+	for w := 0; w < min(jsonWorkerCount, len(docs)); w++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			for idx := range docChan {
-				extJSON, err := bson.MarshalExtJSON(batchDocs[idx], true, false)
-				if err != nil {
-					jsonErrors[idx] = err
-				} else {
-					jsonDocs[idx] = extJSON
-				}
+				jsonDocs[idx], jsonErrors[idx] = bson.MarshalExtJSON(docs[idx], true, false)
 			}
 		}()
 	}
-
-	for i := range batchDocs {
+	for i := range docs {
 		docChan <- i
 	}
 	close(docChan)
 	wg.Wait()
 
-	jsonTime := time.Since(jsonStart)
-
 	for i, err := range jsonErrors {
 		if err != nil {
-			job.resultChan <- batchResult{batchNum: job.batchNum, err: fmt.Errorf("json marshal doc %d: %w", i, err)}
-			return
+			return nil, fmt.Errorf("json marshal doc %d: %w", i, err)
 		}
 	}
-
-	pct := float64(job.skip+int64(len(batchDocs))) / float64(job.totalDocs) * 100
-	fmt.Printf("  WORKER%d [%s]: Batch %d done - %d docs (fetch: %v, json: %v) - %.1f%%\n",
-		workerID, job.collName, job.batchNum, len(batchDocs),
-		fetchTime.Round(time.Millisecond), jsonTime.Round(time.Millisecond), pct)
-
-	job.resultChan <- batchResult{
-		batchNum:  job.batchNum,
-		jsonDocs:  jsonDocs,
-		fetchTime: fetchTime,
-		jsonTime:  jsonTime,
-	}
+	return jsonDocs, nil
 }
 
-func writeCollectionBatches(ctx context.Context, collName string, outputDir string, expectedCount int64, numBatches int, resultChan <-chan batchResult, activeWorkers *atomic.Int32) collectionResult {
-	start := time.Now()
-	result := collectionResult{name: collName}
+// collectionWriter writes a JSON array of documents through the compressor, and counts and
+// hashes the bytes that reach the file.
+type collectionWriter struct {
+	buffer     *bufio.Writer
+	compressor io.WriteCloser
+	hash       hashWriter
+	documents  int64
+	bytes      int64
+}
 
-	if expectedCount == 0 {
-		outputPath := filepath.Join(outputDir, fmt.Sprintf("%s.json", collName))
-		if err := os.WriteFile(outputPath, []byte("[]"), 0644); err != nil {
-			result.err = fmt.Errorf("failed to write empty file: %w", err)
-			return result
-		}
-		result.count = 0
-		result.duration = time.Since(start)
-		fmt.Printf("  START   [%s]: Empty collection, created empty file\n", collName)
-		return result
-	}
+type hashWriter interface {
+	io.Writer
+	Sum(b []byte) []byte
+}
 
-	fmt.Printf("  START   [%s]: Expecting %d documents in %d batches\n", collName, expectedCount, numBatches)
+func newCollectionWriter(file *os.File, compression Compression) (*collectionWriter, error) {
+	w := &collectionWriter{hash: sha256.New()}
+	w.buffer = bufio.NewWriterSize(io.MultiWriter(file, w.hash, countingWriter{&w.bytes}), writeBufferSize)
 
-	outputPath := filepath.Join(outputDir, fmt.Sprintf("%s.json", collName))
-	file, err := os.Create(outputPath)
+	compressor, err := compression.NewWriter(w.buffer)
 	if err != nil {
-		result.err = fmt.Errorf("failed to create output file: %w", err)
-		return result
+		return nil, fmt.Errorf("failed to create %s writer: %w", compression, err)
 	}
-	defer file.Close()
-
-	bufWriter := newBufferedFileWriter(file, 8*1024*1024)
-	defer bufWriter.Flush()
+	w.compressor = compressor
 
-	if _, err := bufWriter.WriteString("[\n"); err != nil {
-		result.err = fmt.Errorf("failed to write opening bracket: %w", err)
-		return result
+	if _, err := io.WriteString(w.compressor, "["); err != nil {
+		return nil, fmt.Errorf("failed to write opening bracket: %w", err)
 	}
+	return w, nil
+}
 
-	pendingBatches := make(map[int]batchResult)
-	nextBatchToWrite := 0
-	var totalDocCount int64
-	var bytesWritten int64
-	first := true
-
-	for batchResult := range resultChan {
-		if batchResult.err != nil {
-			result.err = batchResult.err
-			return result
+func (w *collectionWriter) writeDocuments(docs [][]byte) error {
+	for _, doc := range docs {
+		separator := ",\n"
+		if w.documents == 0 {
+			separator = "\n"
+		}
+		if _, err := io.WriteString(w.compressor, separator); err != nil {
+			return fmt.Errorf("failed to write separator: %w", err)
+		}
+		if _, err := w.compressor.Write(doc); err != nil {
+			return fmt.Errorf("failed to write document: %w", err)
 		}
+		w.documents++
+	}
+	return nil
+}
 
-		pendingBatches[batchResult.batchNum] = batchResult
+func (w *collectionWriter) close() error {
+	closing := "\n]"
+	if w.documents == 0 {
+		closing = "]"
+	}
+	if _, err := io.WriteString(w.compressor, closing); err != nil {
+		return fmt.Errorf("failed to write closing bracket: %w", err)
+	}
+	if err := w.compressor.Close(); err != nil {
+		return fmt.Errorf("failed to finish compression: %w", err)
+	}
+	if err := w.buffer.Flush(); err != nil {
+		return fmt.Errorf("failed to flush buffer: %w", err)
+	}
+	return nil
+}
 
-		for {
-			batch, ok := pendingBatches[nextBatchToWrite]
-			if !ok {
-				break
-			}
-			delete(pendingBatches, nextBatchToWrite)
-
-			writeStart := time.Now()
-			for _, extJSON := range batch.jsonDocs {
-				if !first {
-					n, err := bufWriter.WriteString(",\n")
-					if err != nil {
-						result.err = fmt.Errorf("failed to write separator: %w", err)
-						return result
-					}
-					bytesWritten += int64(n)
-				}
-				first = false
-
-				n, err := bufWriter.Write(extJSON)
-				if err != nil {
-					result.err = fmt.Errorf("failed to write document: %w", err)
-					return result
-				}
-				bytesWritten += int64(n)
-				totalDocCount++
-			}
-			writeDuration := time.Since(writeStart)
+type countingWriter struct {
+	n *int64
+}
 
-			pct := float64(totalDocCount) / float64(expectedCount) * 100
-			fmt.Printf("  WRITE   [%s]: Batch %d written - %d docs (write: %v) - %d/%d (%.1f%%) - %d workers active\n",
-				collName, nextBatchToWrite, len(batch.jsonDocs),
-				writeDuration.Round(time.Millisecond),
-				totalDocCount, expectedCount, pct,
-				activeWorkers.Load())
+func (c countingWriter) Write(p []byte) (int, error) {
+	*c.n += int64(len(p))
+	return len(p), nil
+}
 
-			nextBatchToWrite++
-		}
+// VerifyFile checks a collection file against its manifest entry.
+func VerifyFile(dir string, entry CollectionEntry) error {
+	file, err := os.Open(filepath.Join(dir, entry.File))
+	if err != nil {
+		return err
 	}
+	defer file.Close()
 
-	if _, err := bufWriter.WriteString("\n]"); err != nil {
-		result.err = fmt.Errorf("failed to write closing bracket: %w", err)
-		return result
+	hash := sha256.New()
+	n, err := io.Copy(hash, file)
+	if err != nil {
+		return err
 	}
-
-	if err := bufWriter.Flush(); err != nil {
-		result.err = fmt.Errorf("failed to flush buffer: %w", err)
-		return result
+	if n != entry.Bytes {
+		return fmt.Errorf("%s is %d bytes, manifest says %d", entry.File, n, entry.Bytes)
 	}
-
-	result.count = totalDocCount
-	result.duration = time.Since(start)
-	return result
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != entry.SHA256 {
+		return errors.New(entry.File + " does not match its sha256 checksum in the manifest")
+	}
+	return nil
 }
 
 func formatBytes(bytes int64) string {
@@ -496,48 +579,3 @@ func formatBytes(bytes int64) string {
 		return fmt.Sprintf("%d B", bytes)
 	}
 }
-
-type bufferedFileWriter struct {
-	file   *os.File
-	buffer []byte
-	pos    int
-}
-
-func newBufferedFileWriter(file *os.File, bufferSize int) *bufferedFileWriter {
-	return &bufferedFileWriter{
-		file:   file,
-		buffer: make([]byte, bufferSize),
-		pos:    0,
-	}
-}
-
-func (w *bufferedFileWriter) Write(p []byte) (int, error) {
-	written := 0
-	for len(p) > 0 {
-		space := len(w.buffer) - w.pos
-		if space == 0 {
-			if err := w.Flush(); err != nil {
-				return written, err
-			}
-			space = len(w.buffer)
-		}
-		n := copy(w.buffer[w.pos:], p)
-		w.pos += n
-		written += n
-		p = p[n:]
-	}
-	return written, nil
-}
-
-func (w *bufferedFileWriter) WriteString(s string) (int, error) {
-	return w.Write([]byte(s))
-}
-
-func (w *bufferedFileWriter) Flush() error {
-	if w.pos == 0 {
-		return nil
-	}
-	_, err := w.file.Write(w.buffer[:w.pos])
-	w.pos = 0
-	return err
-}
@@ -0,0 +1,116 @@
+package dump
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestCollectionWriterRoundTrip(t *testing.T) {
+	for _, compression := range compressions {
+		t.Run(string(compression), func(t *testing.T) {
+			for _, docs := range [][]string{nil, {`{"_id":"a"}`, `{"_id":"b","n":{"$numberInt":"1"}}`}} {
+				dir := t.TempDir()
+				entry := CollectionEntry{Name: "snapshot", File: "snapshot" + compression.Extension()}
+
+				file, err := os.Create(filepath.Join(dir, entry.File))
+				if err != nil {
+					t.Fatal(err)
+				}
+				writer, err := newCollectionWriter(file, compression)
+				if err != nil {
+					t.Fatal(err)
+				}
+				jsonDocs := make([][]byte, len(docs))
+				for i, doc := range docs {
+					jsonDocs[i] = []byte(doc)
+				}
+				if err := writer.writeDocuments(jsonDocs); err != nil {
+					t.Fatal(err)
+				}
+				if err := writer.close(); err != nil {
+					t.Fatal(err)
+				}
+				file.Close()
+
+				entry.Bytes = writer.bytes
+				entry.SHA256 = hex.EncodeToString(writer.hash.Sum(nil))
+				if err := VerifyFile(dir, entry); err != nil {
+					t.Fatalf("VerifyFile: %v", err)
+				}
+
+				file, err = os.Open(filepath.Join(dir, entry.File))
+				if err != nil {
+					t.Fatal(err)
+				}
+				reader, err := compression.NewReader(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, err := io.ReadAll(reader)
+				reader.Close()
+				file.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var parsed []json.RawMessage
+				if err := json.Unmarshal(data, &parsed); err != nil || len(parsed) != len(docs) {
+					t.Errorf("decompressed %q into %d documents, %v; want %d", data, len(parsed), err, len(docs))
+				}
+
+				entry.SHA256 = strings.Repeat("0", 64)
+				if VerifyFile(dir, entry) == nil {
+					t.Error("VerifyFile accepted a wrong checksum")
+				}
+			}
+		})
+	}
+}
+
+func TestSplitFileName(t *testing.T) {
+	cases := map[string]struct {
+		collection  string
+		compression Compression
+		ok          bool
+	}{
+		"snapshot.json":    {"snapshot", CompressionNone, true},
+		"snapshot.json.gz": {"snapshot", CompressionGzip, true},
+		"delta.json.zst":   {"delta", CompressionZstd, true},
+		ManifestFileName:   {"", "", false},
+		".json":            {"", "", false},
+		"notes.txt":        {"", "", false},
+	}
+	for name, want := range cases {
+		collection, compression, ok := SplitFileName(name)
+		if collection != want.collection || compression != want.compression || ok != want.ok {
+			t.Errorf("SplitFileName(%q) = %q, %q, %v; want %q, %q, %v", name, collection, compression, ok, want.collection, want.compression, want.ok)
+		}
+	}
+}
+
+func TestCollectionFilter(t *testing.T) {
+	since := time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC)
+	snapshot := collectionScope{timestampField: "timestamp", userField: "userId"}
+	token := collectionScope{}
+
+	filter, incremental, ok := collectionFilter(snapshot, dumpOptions{since: &since, userId: "u1"})
+	want := bson.D{{Key: "userId", Value: "u1"}, {Key: "timestamp", Value: bson.M{"$gte": since}}}
+	if !ok || !incremental || len(filter) != 2 || filter[0] != want[0] {
+		t.Errorf("snapshot filter = %v, %v, %v; want %v, true, true", filter, incremental, ok, want)
+	}
+
+	if filter, incremental, ok := collectionFilter(token, dumpOptions{since: &since}); !ok || incremental || len(filter) != 0 {
+		t.Errorf("token filter with --since = %v, %v, %v; want a full export", filter, incremental, ok)
+	}
+	if _, _, ok := collectionFilter(token, dumpOptions{userId: "u1"}); ok {
+		t.Error("token collection was exported with --user, want it skipped")
+	}
+}
@@ -0,0 +1,156 @@
+package dump
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ManifestFileName is written next to the collection files once a dump completes.
+const ManifestFileName = "manifest.json"
+
+const manifestVersion = 1
+
+// Manifest describes a completed dump: what was exported, how, and a checksum of every file.
+type Manifest struct {
+	Version     int               `json:"version"`
+	Database    string            `json:"database"`
+	StartedAt   time.Time         `json:"startedAt"`
+	FinishedAt  time.Time         `json:"finishedAt"`
+	Since       *time.Time        `json:"since,omitempty"`
+	UserId      string            `json:"userId,omitempty"`
+	Compression Compression       `json:"compression"`
+	Collections []CollectionEntry `json:"collections"`
+}
+
+// CollectionEntry is one collection file. Incremental is true when only documents at or after
+// the manifest's Since were exported.
+type CollectionEntry struct {
+	Name        string `json:"name"`
+	File        string `json:"file"`
+	Documents   int64  `json:"documents"`
+	Bytes       int64  `json:"bytes"`
+	SHA256      string `json:"sha256"`
+	Incremental bool   `json:"incremental"`
+}
+
+// Collection returns the entry for the named collection.
+func (m Manifest) Collection(name string) (CollectionEntry, bool) {
+	for _, c := range m.Collections {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return CollectionEntry{}, false
+}
+
+// ReadManifest reads the manifest in dir. It returns an error wrapping os.ErrNotExist for dumps
+// written before manifests existed.
+func ReadManifest(dir string) (Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFileName))
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return Manifest{}, fmt.Errorf("parsing %s: %w", ManifestFileName, err)
+	}
+	return manifest, nil
+}
+
+func writeManifest(dir string, manifest Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, ManifestFileName), append(data, '\n'), 0644)
+}
+
+// Compression is how collection files are encoded on disk.
+type Compression string
+
+const (
+	CompressionNone Compression = "none"
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+var compressions = []Compression{CompressionNone, CompressionGzip, CompressionZstd}
+
+func CompressionFromValue(value string) (Compression, bool) {
+	for _, c := range compressions {
+		if string(c) == value {
+			return c, true
+		}
+	}
+	return "", false
+}
+
+// Extension is the file suffix for a collection written with this compression.
+func (c Compression) Extension() string {
+	switch c {
+	case CompressionGzip:
+		return ".json.gz"
+	case CompressionZstd:
+		return ".json.zst"
+	default:
+		return ".json"
+	}
+}
+
+// SplitFileName returns the collection and compression of a collection file name, or false if
+// name is not a collection file.
+func SplitFileName(name string) (string, Compression, bool) {
+	if name == ManifestFileName {
+		return "", "", false
+	}
+	for _, c := range compressions {
+		if collection, ok := strings.CutSuffix(name, c.Extension()); ok && collection != "" {
+			return collection, c, true
+		}
+	}
+	return "", "", false
+}
+
+// NewWriter compresses everything written to the returned writer into w. Closing it flushes
+// the compressor but does not close w.
+func (c Compression) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	switch c {
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		return zstd.NewWriter(w)
+	default:
+		return nopWriteCloser{w}, nil
+	}
+}
+
+// NewReader decompresses r.
+func (c Compression) NewReader(r io.Reader) (io.ReadCloser, error) {
+	switch c {
+	case CompressionGzip:
+		return gzip.NewReader(r)
+	case CompressionZstd:
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return io.NopCloser(r), nil
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
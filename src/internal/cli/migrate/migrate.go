@@ -0,0 +1,123 @@
+package migrate
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/database/migration"
+	"github.com/ctfloyd/hazelmere-api/src/internal/initialize"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_config"
+)
+
+const usage = `Usage:
+  hazelmere migrate status
+  hazelmere migrate up [--to VERSION]
+  hazelmere migrate down [--steps N]`
+
+func Run(configPath string, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("migrate requires a subcommand (status, up, down)\n%s", usage)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	config := hz_config.NewConfigFromPath(configPath)
+	if err := config.Read(); err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	client, err := initialize.MongoClient(
+		config.ValueOrPanic("mongo.connection.host"),
+		config.ValueOrPanic("mongo.connection.username"),
+		config.ValueOrPanic("mongo.connection.password"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to connect to MongoDB: %w", err)
+	}
+	defer initialize.MongoCleanup(ctx, client)
+
+	migrator, err := migration.NewMigrator(initialize.MongoFactory(config, client), migration.All)
+	if err != nil {
+		return err
+	}
+
+	subcmd := args[0]
+	subargs := args[1:]
+	switch subcmd {
+	case "status":
+		return status(ctx, migrator)
+	case "up":
+		return up(ctx, migrator, subargs)
+	case "down":
+		return down(ctx, migrator, subargs)
+	default:
+		return fmt.Errorf("unknown migrate subcommand: %s\n%s", subcmd, usage)
+	}
+}
+
+func status(ctx context.Context, migrator *migration.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tDESCRIPTION\tAPPLIED")
+	for _, s := range statuses {
+		applied := "pending"
+		if s.AppliedAt != nil {
+			applied = s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Description, applied)
+	}
+	return w.Flush()
+}
+
+func up(ctx context.Context, migrator *migration.Migrator, args []string) error {
+	fs := flag.NewFlagSet("migrate up", flag.ContinueOnError)
+	to := fs.Int("to", 0, "apply migrations up to and including this version (default: all)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	applied, err := migrator.Up(ctx, *to)
+	for _, m := range applied {
+		fmt.Printf("Applied %d: %s\n", m.Version, m.Description)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		fmt.Println("Database is up to date")
+	}
+	return nil
+}
+
+func down(ctx context.Context, migrator *migration.Migrator, args []string) error {
+	fs := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+	steps := fs.Int("steps", 1, "number of applied migrations to revert, newest first")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *steps < 1 {
+		return fmt.Errorf("--steps must be at least 1")
+	}
+
+	reverted, err := migrator.Down(ctx, *steps)
+	for _, m := range reverted {
+		fmt.Printf("Reverted %d: %s\n", m.Version, m.Description)
+	}
+	if err != nil {
+		return err
+	}
+	if len(reverted) == 0 {
+		fmt.Println("No applied migrations to revert")
+	}
+	return nil
+}
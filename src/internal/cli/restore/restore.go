@@ -0,0 +1,441 @@
+package restore
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/cli/dump"
+	"github.com/ctfloyd/hazelmere-api/src/internal/initialize"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_config"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const usage = `Usage:
+  hazelmere restore [--database NAME] [--collections A,B] [--exclude A,B] [--workers N] [--batch-size N] [--drop] [--upsert] DIR`
+
+const (
+	defaultWorkers   = 8    // Parallel InsertMany calls across all collections
+	defaultBatchSize = 1000 // Documents per InsertMany call
+)
+
+type restoreOptions struct {
+	dir       string
+	database  string
+	include   map[string]bool
+	exclude   map[string]bool
+	workers   int
+	batchSize int
+	drop      bool
+	upsert    bool
+}
+
+// dumpFile is one collection written by hazelmere dump as a JSON array of Extended JSON documents.
+// entry is nil for dumps written before manifests existed.
+type dumpFile struct {
+	collection  string
+	path        string
+	compression dump.Compression
+	entry       *dump.CollectionEntry
+}
+
+type collectionResult struct {
+	name     string
+	before   int64
+	parsed   int64
+	inserted atomic.Int64
+	replaced atomic.Int64
+	after    int64
+	duration time.Duration
+
+	mu  sync.Mutex
+	err error
+}
+
+func (r *collectionResult) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+type insertJob struct {
+	collection *mongo.Collection
+	docs       []any
+	upsert     bool
+	result     *collectionResult
+}
+
+func Run(configPath string, args []string) error {
+	opts, err := parseArgs(args)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	config := hz_config.NewConfigFromPath(configPath)
+	if err := config.Read(); err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	files, err := findDumpFiles(opts)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no collection files to restore in %s", opts.dir)
+	}
+
+	client, err := initialize.MongoClient(
+		config.ValueOrPanic("mongo.connection.host"),
+		config.ValueOrPanic("mongo.connection.username"),
+		config.ValueOrPanic("mongo.connection.password"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to connect to MongoDB: %w", err)
+	}
+	defer initialize.MongoCleanup(ctx, client)
+
+	dbName := opts.database
+	if dbName == "" {
+		dbName = config.ValueOrPanic("mongo.database.name")
+	}
+
+	fmt.Println("=== MongoDB Collection Restore ===")
+	fmt.Printf("Database: %s\n", dbName)
+	fmt.Printf("Input Directory: %s\n", opts.dir)
+	fmt.Printf("Workers: %d\n", opts.workers)
+	fmt.Printf("Batch Size: %d\n", opts.batchSize)
+	fmt.Printf("Drop Existing: %t\n\n", opts.drop)
+
+	return restoreCollections(ctx, client.Database(dbName), files, opts)
+}
+
+func parseArgs(args []string) (restoreOptions, error) {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	database := fs.String("database", "", "database to restore into (default: mongo.database.name from config)")
+	collections := fs.String("collections", "", "comma separated collections to restore (default: every file in DIR)")
+	exclude := fs.String("exclude", "", "comma separated collections to skip")
+	workers := fs.Int("workers", defaultWorkers, "number of parallel insert workers")
+	batchSize := fs.Int("batch-size", defaultBatchSize, "documents per insert")
+	drop := fs.Bool("drop", false, "drop each collection before restoring it")
+	upsert := fs.Bool("upsert", false, "replace documents that already exist by _id, e.g. to apply an incremental dump on top of its base")
+
+	// Allow the directory before or after the flags.
+	var dir string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		dir, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return restoreOptions{}, err
+	}
+	if dir == "" && fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+	if dir == "" {
+		return restoreOptions{}, fmt.Errorf("restore requires a dump directory\n%s", usage)
+	}
+	if *workers < 1 || *batchSize < 1 {
+		return restoreOptions{}, fmt.Errorf("--workers and --batch-size must be at least 1")
+	}
+
+	return restoreOptions{
+		dir:       dir,
+		database:  *database,
+		include:   splitSet(*collections),
+		exclude:   splitSet(*exclude),
+		workers:   *workers,
+		batchSize: *batchSize,
+		drop:      *drop,
+		upsert:    *upsert,
+	}, nil
+}
+
+func splitSet(value string) map[string]bool {
+	set := make(map[string]bool)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			set[item] = true
+		}
+	}
+	return set
+}
+
+// findDumpFiles lists the collection files to restore and, when the dump has a manifest,
+// verifies each against its checksum before anything is written to the database.
+func findDumpFiles(opts restoreOptions) ([]dumpFile, error) {
+	entries, err := os.ReadDir(opts.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := dump.ReadManifest(opts.dir)
+	hasManifest := err == nil
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if !hasManifest {
+		fmt.Printf("WARNING: %s has no %s; files cannot be verified against checksums\n\n", opts.dir, dump.ManifestFileName)
+	} else if manifest.Since != nil && !opts.upsert {
+		return nil, fmt.Errorf("%s is an incremental dump since %s; restore it on top of its base with --upsert", opts.dir, manifest.Since.Format(time.RFC3339))
+	}
+
+	found := make(map[string]bool)
+	var files []dumpFile
+	for _, e := range entries {
+		name, compression, ok := dump.SplitFileName(e.Name())
+		if e.IsDir() || !ok {
+			continue
+		}
+		if found[name] {
+			return nil, fmt.Errorf("collection %s has more than one file in %s", name, opts.dir)
+		}
+		found[name] = true
+		if (len(opts.include) > 0 && !opts.include[name]) || opts.exclude[name] {
+			continue
+		}
+
+		file := dumpFile{collection: name, path: filepath.Join(opts.dir, e.Name()), compression: compression}
+		if hasManifest {
+			entry, ok := manifest.Collection(name)
+			if !ok || entry.File != e.Name() {
+				return nil, fmt.Errorf("%s is not listed in %s", e.Name(), dump.ManifestFileName)
+			}
+			if err := dump.VerifyFile(opts.dir, entry); err != nil {
+				return nil, err
+			}
+			file.entry = &entry
+		}
+		files = append(files, file)
+	}
+
+	for name := range opts.include {
+		if !found[name] {
+			return nil, fmt.Errorf("collection %s has no file in %s", name, opts.dir)
+		}
+	}
+	return files, nil
+}
+
+func restoreCollections(ctx context.Context, db *mongo.Database, files []dumpFile, opts restoreOptions) error {
+	startTime := time.Now()
+
+	results := make([]*collectionResult, len(files))
+	for i, file := range files {
+		result := &collectionResult{name: file.collection}
+		results[i] = result
+
+		collection := db.Collection(file.collection)
+		if opts.drop {
+			if err := collection.Drop(ctx); err != nil {
+				return fmt.Errorf("failed to drop %s: %w", file.collection, err)
+			}
+		}
+		before, err := collection.CountDocuments(ctx, bson.M{})
+		if err != nil {
+			return fmt.Errorf("failed to count %s: %w", file.collection, err)
+		}
+		result.before = before
+		fmt.Printf("  %s: %d existing documents\n", file.collection, before)
+	}
+	fmt.Println()
+
+	jobs := make(chan insertJob, opts.workers*2)
+	var workerWg sync.WaitGroup
+	for w := 0; w < opts.workers; w++ {
+		workerWg.Add(1)
+		go func() {
+			defer workerWg.Done()
+			for job := range jobs {
+				insertBatch(ctx, job)
+			}
+		}()
+	}
+
+	// Files are parsed one at a time; the insert workers are where the parallelism pays off.
+	for i, file := range files {
+		result := results[i]
+		collection := db.Collection(file.collection)
+		start := time.Now()
+		fmt.Printf("  START   [%s]: Reading %s\n", file.collection, file.path)
+
+		parsed, err := readDumpFile(file, opts.batchSize, func(docs []any) error {
+			select {
+			case jobs <- insertJob{collection: collection, docs: docs, upsert: opts.upsert, result: result}:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		result.parsed = parsed
+		result.duration = time.Since(start)
+		if err != nil {
+			result.fail(err)
+		} else if file.entry != nil && parsed != file.entry.Documents {
+			result.fail(fmt.Errorf("read %d documents, manifest says %d", parsed, file.entry.Documents))
+		}
+	}
+	close(jobs)
+	workerWg.Wait()
+
+	var failed []*collectionResult
+	var totalDocs int64
+	for _, result := range results {
+		after, err := db.Collection(result.name).CountDocuments(ctx, bson.M{})
+		if err != nil {
+			result.fail(fmt.Errorf("failed to count after restore: %w", err))
+		}
+		result.after = after
+
+		inserted, replaced := result.inserted.Load(), result.replaced.Load()
+		if result.err == nil && (inserted+replaced != result.parsed || result.after != result.before+inserted) {
+			result.fail(fmt.Errorf("count mismatch: %d existing + %d new of %d in dump, but collection has %d", result.before, inserted, result.parsed, result.after))
+		}
+		if result.err != nil {
+			failed = append(failed, result)
+			fmt.Printf("  ERROR   [%s]: %v\n", result.name, result.err)
+			continue
+		}
+		totalDocs += inserted + replaced
+		fmt.Printf("  VERIFIED [%s]: %d documents inserted, %d replaced, %d in collection\n", result.name, inserted, replaced, result.after)
+	}
+
+	totalDuration := time.Since(startTime)
+	fmt.Printf("\n=====================================\n")
+	fmt.Printf("          RESTORE COMPLETE           \n")
+	fmt.Printf("=====================================\n")
+	fmt.Printf("Collections restored: %d\n", len(results)-len(failed))
+	fmt.Printf("Total documents:      %d\n", totalDocs)
+	fmt.Printf("Total time:           %v\n", totalDuration.Round(time.Millisecond))
+	fmt.Printf("Throughput:           %.0f docs/sec\n", float64(totalDocs)/totalDuration.Seconds())
+	fmt.Printf("=====================================\n")
+
+	if len(failed) > 0 {
+		names := make([]string, len(failed))
+		for i, f := range failed {
+			names[i] = f.name
+		}
+		return fmt.Errorf("restore failed or could not be verified for: %s", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+func insertBatch(ctx context.Context, job insertJob) {
+	if job.upsert {
+		upsertBatch(ctx, job)
+		return
+	}
+
+	// Unordered so one bad document does not stop the rest of the batch.
+	res, err := job.collection.InsertMany(ctx, job.docs, options.InsertMany().SetOrdered(false))
+	if res != nil {
+		job.result.inserted.Add(int64(len(res.InsertedIDs)))
+	}
+	if err != nil {
+		job.result.fail(fmt.Errorf("insert failed: %w", err))
+	}
+}
+
+func upsertBatch(ctx context.Context, job insertJob) {
+	models := make([]mongo.WriteModel, 0, len(job.docs))
+	for _, doc := range job.docs {
+		var id any
+		for _, e := range doc.(bson.D) {
+			if e.Key == "_id" {
+				id = e.Value
+				break
+			}
+		}
+		if id == nil {
+			job.result.fail(errors.New("upsert failed: document without _id"))
+			return
+		}
+		models = append(models, mongo.NewReplaceOneModel().SetFilter(bson.D{{Key: "_id", Value: id}}).SetReplacement(doc).SetUpsert(true))
+	}
+
+	res, err := job.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if res != nil {
+		job.result.inserted.Add(res.UpsertedCount)
+		job.result.replaced.Add(res.MatchedCount)
+	}
+	if err != nil {
+		job.result.fail(fmt.Errorf("upsert failed: %w", err))
+	}
+}
+
+func readDumpFile(f dumpFile, batchSize int, emit func(docs []any) error) (int64, error) {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader, err := f.compression.NewReader(file)
+	if err != nil {
+		return 0, fmt.Errorf("opening %s: %w", f.path, err)
+	}
+	defer reader.Close()
+	return readDump(reader, batchSize, emit)
+}
+
+// readDump stream-parses a dump written by hazelmere dump, a JSON array of Extended JSON documents,
+// calling emit with batches of at most batchSize documents. It returns the number of documents read.
+func readDump(r io.Reader, batchSize int, emit func(docs []any) error) (int64, error) {
+	decoder := json.NewDecoder(bufio.NewReaderSize(r, 1024*1024))
+
+	if token, err := decoder.Token(); err != nil {
+		return 0, fmt.Errorf("reading dump: %w", err)
+	} else if token != json.Delim('[') {
+		return 0, errors.New("reading dump: expected a JSON array of documents")
+	}
+
+	var count int64
+	batch := make([]any, 0, batchSize)
+	for decoder.More() {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return count, fmt.Errorf("reading document %d: %w", count, err)
+		}
+
+		// bson.D keeps field order and the BSON types Extended JSON encodes, such as dates and ObjectIDs.
+		var doc bson.D
+		if err := bson.UnmarshalExtJSON(raw, false, &doc); err != nil {
+			return count, fmt.Errorf("parsing document %d: %w", count, err)
+		}
+		batch = append(batch, doc)
+		count++
+
+		if len(batch) == batchSize {
+			if err := emit(batch); err != nil {
+				return count, err
+			}
+			batch = make([]any, 0, batchSize)
+		}
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return count, fmt.Errorf("reading end of dump: %w", err)
+	}
+	if len(batch) > 0 {
+		if err := emit(batch); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,81 @@
+package restore
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// dumpOf renders documents the way hazelmere dump writes them.
+func dumpOf(t *testing.T, docs ...bson.D) string {
+	t.Helper()
+	parts := make([]string, len(docs))
+	for i, doc := range docs {
+		extJSON, err := bson.MarshalExtJSON(doc, true, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[i] = string(extJSON)
+	}
+	return "[\n" + strings.Join(parts, ",\n") + "\n]"
+}
+
+func TestReadDumpPreservesTypes(t *testing.T) {
+	timestamp := time.Date(2025, time.March, 10, 12, 30, 0, 0, time.UTC)
+	docs := []bson.D{
+		{{Key: "_id", Value: bson.NewObjectID()}, {Key: "timestamp", Value: timestamp}, {Key: "experience", Value: int64(13034431)}},
+		{{Key: "_id", Value: "c0ffee"}, {Key: "skills", Value: bson.A{bson.D{{Key: "level", Value: int32(99)}}}}},
+		{{Key: "_id", Value: bson.NewObjectID()}, {Key: "ratio", Value: 0.5}},
+	}
+
+	var batches [][]any
+	count, err := readDump(strings.NewReader(dumpOf(t, docs...)), 2, func(batch []any) error {
+		batches = append(batches, batch)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 || len(batches) != 2 || len(batches[0]) != 2 || len(batches[1]) != 1 {
+		t.Fatalf("read %d documents in batches %v, want 3 in batches of 2 and 1", count, batches)
+	}
+
+	i := 0
+	for _, batch := range batches {
+		for _, got := range batch {
+			gotBytes, err := bson.Marshal(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantBytes, err := bson.Marshal(docs[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(gotBytes, wantBytes) {
+				t.Errorf("document %d = %v, want %v", i, bson.Raw(gotBytes), bson.Raw(wantBytes))
+			}
+			i++
+		}
+	}
+}
+
+func TestReadDumpEmptyCollection(t *testing.T) {
+	count, err := readDump(strings.NewReader("[]"), 10, func([]any) error {
+		t.Error("emit called for an empty dump")
+		return nil
+	})
+	if err != nil || count != 0 {
+		t.Errorf("readDump = %d, %v; want 0, nil", count, err)
+	}
+}
+
+func TestReadDumpRejectsMalformedInput(t *testing.T) {
+	for _, input := range []string{`{"_id": 1}`, `[{"_id": 1},`, `[{"timestamp": {"$date": "not a date"}}]`} {
+		if _, err := readDump(strings.NewReader(input), 10, func([]any) error { return nil }); err == nil {
+			t.Errorf("readDump(%q) succeeded, want an error", input)
+		}
+	}
+}
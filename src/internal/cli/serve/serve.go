@@ -2,18 +2,26 @@ package serve
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"time"
 
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/audit"
 	"github.com/ctfloyd/hazelmere-api/src/internal/core/delta"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/group"
 	"github.com/ctfloyd/hazelmere-api/src/internal/core/health"
 	"github.com/ctfloyd/hazelmere-api/src/internal/core/hiscore"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/scheduler"
 	"github.com/ctfloyd/hazelmere-api/src/internal/core/snapshot"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/token"
 	"github.com/ctfloyd/hazelmere-api/src/internal/core/user"
 	"github.com/ctfloyd/hazelmere-api/src/internal/core/worker"
 	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/internal/database/migration"
 	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/middleware"
 	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
 	"github.com/ctfloyd/hazelmere-api/src/internal/initialize"
@@ -21,10 +29,30 @@ import (
 	"github.com/ctfloyd/hazelmere-commons/pkg/hz_config"
 	"github.com/ctfloyd/hazelmere-commons/pkg/hz_logger"
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
+// Storage selects where the API keeps its data.
+type Storage string
+
+const (
+	StorageMongo  Storage = "mongo"
+	StorageMemory Storage = "memory"
+)
+
 func Run(configPath string, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	storage := fs.String("storage", string(StorageMongo), "where to keep data: mongo, or memory for local development without a database")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if Storage(*storage) != StorageMongo && Storage(*storage) != StorageMemory {
+		return fmt.Errorf("unknown storage %q: expected %s or %s", *storage, StorageMongo, StorageMemory)
+	}
+
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
@@ -50,26 +78,61 @@ func Run(configPath string, args []string) error {
 		otelCfg.AuthHeader = config.ValueOrPanic("otel.authHeader")
 		logger.Info(ctx, "OpenTelemetry enabled, exporting to Grafana")
 	}
+	if config.BoolValueOrPanic("metrics.prometheus.enabled") {
+		otelCfg.PrometheusRegistry = prometheus.NewRegistry()
+		otelCfg.PrometheusRegistry.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+	}
 	otelShutdown, err := initialize.InitOtel(ctx, otelCfg)
 	if err != nil {
 		return fmt.Errorf("failed to initialize OpenTelemetry: %w", err)
 	}
 	defer otelShutdown(ctx)
 
+	if otelCfg.PrometheusRegistry != nil {
+		port := config.IntValueOrPanic("metrics.prometheus.port")
+		metricsServer := initialize.PrometheusServer(port, otelCfg.PrometheusRegistry)
+		go func() {
+			logger.InfoArgs(ctx, "Serving Prometheus metrics on :%d/metrics", port)
+			if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.ErrorArgs(ctx, "Prometheus metrics server error: %v", err)
+			}
+		}()
+		defer metricsServer.Shutdown(context.Background())
+	}
+
 	router := initialize.InitRouter(logger)
 
-	logger.Info(ctx, "Connecting to MongoDB")
-	client, err := initialize.MongoClient(
-		config.ValueOrPanic("mongo.connection.host"),
-		config.ValueOrPanic("mongo.connection.username"),
-		config.ValueOrPanic("mongo.connection.password"),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to connect to MongoDB: %w", err)
+	// Create the monitor (tracer + logger + metrics)
+	mon := monitor.New(logger)
+
+	var client *mongo.Client
+	var repos repositories
+	if Storage(*storage) == StorageMemory {
+		logger.Warn(ctx, "Using in-memory storage; all data is lost when the server stops")
+		repos = memoryRepositories(mon)
+	} else {
+		logger.Info(ctx, "Connecting to MongoDB")
+		client, err = initialize.MongoClient(
+			config.ValueOrPanic("mongo.connection.host"),
+			config.ValueOrPanic("mongo.connection.username"),
+			config.ValueOrPanic("mongo.connection.password"),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to connect to MongoDB: %w", err)
+		}
+		defer initialize.MongoCleanup(ctx, client)
+
+		f := initialize.MongoFactory(config, client)
+		if err := ensureSchema(ctx, logger, f); err != nil {
+			return err
+		}
+		repos = mongoRepositories(f, mon)
 	}
-	defer initialize.MongoCleanup(ctx, client)
 
-	if err := initializeApp(ctx, logger, config, router, client, environment); err != nil {
+	if err := initializeApp(ctx, logger, mon, config, router, client, repos, environment); err != nil {
 		return err
 	}
 
@@ -103,43 +166,92 @@ func resolveConfigPath(explicitPath string) (configPath string, environment stri
 	}
 }
 
-func initializeApp(ctx context.Context, logger hz_logger.Logger, config *hz_config.Config, router *chi.Mux, client *mongo.Client, environment string) error {
-	dbName := config.ValueOrPanic("mongo.database.name")
+// ensureSchema refuses to serve until every migration is applied, so the indexes queries rely
+// on exist. Serve never migrates itself: replicas starting together would race, and migrations
+// that rewrite data are for an operator to run with 'hazelmere migrate up'.
+func ensureSchema(ctx context.Context, logger hz_logger.Logger, f *database.MongoFactory) error {
+	migrator, err := migration.NewMigrator(f, migration.All)
+	if err != nil {
+		return err
+	}
 
-	// Create the monitor (tracer + logger + metrics)
-	mon := monitor.New(logger)
+	pending, err := migrator.Pending(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check database schema: %w", err)
+	}
+	for _, m := range pending {
+		logger.ErrorArgs(ctx, "Migration %d is not applied: %s", m.Version, m.Description)
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("database schema is %d migration(s) behind, run 'hazelmere migrate up' before serving", len(pending))
+	}
+	return nil
+}
 
-	f := database.NewMongoFactory(client, database.MongoFactoryConfig{
-		DatabaseName:           dbName,
-		SnapshotCollectionName: config.ValueOrPanic("mongo.database.collections.snapshot"),
-		UserCollectionName:     config.ValueOrPanic("mongo.database.collections.user"),
-		DeltaCollectionName:    config.ValueOrPanic("mongo.database.collections.delta"),
-	})
+// repositories holds the storage behind every service, so serve can run on Mongo or in memory.
+type repositories struct {
+	user     user.UserRepository
+	snapshot snapshot.SnapshotRepository
+	delta    delta.DeltaRepository
+	token    token.TokenRepository
+	audit    audit.AuditRepository
+	job      worker.JobRepository
+	lease    scheduler.LeaseRepository
+	group    group.GroupRepository
+}
+
+func mongoRepositories(f *database.MongoFactory, mon *monitor.Monitor) repositories {
+	return repositories{
+		user:     user.NewUserRepository(f.NewUserCollection(), mon),
+		snapshot: snapshot.NewSnapshotRepository(f.NewSnapshotCollection(), mon),
+		delta:    delta.NewDeltaRepository(f.NewDeltaCollection(), mon),
+		token:    token.NewTokenRepository(f.NewTokenCollection(), mon),
+		audit:    audit.NewAuditRepository(f.NewAuditCollection(), mon),
+		job:      worker.NewJobRepository(f.NewJobCollection(), mon),
+		lease:    scheduler.NewLeaseRepository(f.NewLeaseCollection(), mon),
+		group:    group.NewGroupRepository(f.NewGroupCollection(), mon),
+	}
+}
+
+func memoryRepositories(mon *monitor.Monitor) repositories {
+	return repositories{
+		user:     user.NewMemoryUserRepository(mon),
+		snapshot: snapshot.NewMemorySnapshotRepository(mon),
+		delta:    delta.NewMemoryDeltaRepository(mon),
+		token:    token.NewMemoryTokenRepository(mon),
+		audit:    audit.NewMemoryAuditRepository(mon),
+		job:      worker.NewMemoryJobRepository(mon),
+		lease:    scheduler.NewMemoryLeaseRepository(mon),
+		group:    group.NewMemoryGroupRepository(mon),
+	}
+}
 
-	userCollection := f.NewUserCollection()
-	userRepo := user.NewUserRepository(userCollection, mon)
+// initializeApp wires services and handlers onto the router. client is nil with in-memory storage.
+func initializeApp(ctx context.Context, logger hz_logger.Logger, mon *monitor.Monitor, config *hz_config.Config, router *chi.Mux, client *mongo.Client, repos repositories, environment string) error {
+	auditService := audit.NewAuditService(mon, repos.audit)
+
+	userRepo := repos.user
 	userValidator := user.NewUserValidator()
 	userService := user.NewUserService(mon, userRepo, userValidator)
-	userHandler := handler.NewUserHandler(mon, userService)
 
 	// Initialize delta components with cache
-	deltaCollection := f.NewDeltaCollection()
-	deltaRepo := delta.NewDeltaRepository(deltaCollection, mon)
 	deltaCache := delta.NewDeltaCache()
-	deltaService := delta.NewDeltaService(mon, deltaRepo, deltaCache, userRepo)
-	deltaHandler := handler.NewDeltaHandler(mon, deltaService)
+	if err := mon.Metrics().RegisterDeltaCacheSize(deltaCache.GetTotalCachedUsers, deltaCache.GetTotalCachedDeltas); err != nil {
+		logger.ErrorArgs(ctx, "Failed to register delta cache metrics: %v", err)
+	}
+	deltaService := delta.NewDeltaService(mon, repos.delta, deltaCache, userRepo)
 
 	// Initialize snapshot components
-	snapshotCollection := f.NewSnapshotCollection()
-	snapshotRepo := snapshot.NewSnapshotRepository(snapshotCollection, mon)
 	snapshotValidator := snapshot.NewSnapshotValidator()
-	snapshotService := snapshot.NewSnapshotService(mon, snapshotRepo, snapshotValidator, userRepo)
+	snapshotService := snapshot.NewSnapshotService(mon, repos.snapshot, snapshotValidator, userRepo)
 
 	// Initialize orchestrator (coordinates snapshot and delta creation in transactions)
 	txManager := database.NewTransactionManager(client, false)
 	orchestrator := hiscore.NewHiscoreOrchestrator(mon, snapshotService, deltaService, txManager)
+	groupService := group.NewGroupService(mon, repos.group, group.NewGroupValidator(), userRepo, deltaService)
+	userMerger := hiscore.NewUserMerger(mon, userService, snapshotService, deltaService, groupService, txManager)
 
-	snapshotHandler := handler.NewSnapshotHandler(mon, snapshotService, orchestrator)
+	accountTypeChecker := user.NewAccountTypeChecker(mon, userService, user.NewWomAccountTypeDetector(initialize.InitWomClient(logger, config)))
 
 	// Prime delta cache
 	logger.Info(ctx, "Priming delta cache...")
@@ -147,25 +259,66 @@ func initializeApp(ctx context.Context, logger hz_logger.Logger, config *hz_conf
 		logger.ErrorArgs(ctx, "Failed to prime delta cache: %v", err)
 	}
 
-	workerClient := initialize.InitWorkerClient(logger, config)
-	workerService := worker.NewWorkerService(mon, workerClient, snapshotService)
-	workerHandler := handler.NewWorkerHandler(mon, workerService)
+	workerClient, workerBreaker := initialize.InitWorkerClient(logger, config)
+	onDemandCooldown := time.Duration(config.IntValueOrPanic("onDemand.cooldownMs")) * time.Millisecond
+	workerService := worker.NewWorkerService(mon, workerClient, snapshotService, onDemandCooldown)
+
+	// Run queued on-demand snapshot jobs in the background until the server stops
+	jobRunner := worker.NewJobRunner(mon, repos.job, workerService, initialize.JobRunnerConfig(config))
+	go jobRunner.Run(ctx)
+	jobService := worker.NewJobService(mon, repos.job, worker.NewJobValidator(), jobRunner)
+
+	userLifecycle := hiscore.NewUserLifecycle(mon, userService, snapshotService, deltaService, auditService, jobService, groupService, txManager)
+
+	// Snapshot tracked users from this process when enabled; replicas elect one scheduler through a lease
+	if config.BoolValueOrPanic("scheduler.enabled") {
+		holder := schedulerHolder()
+		trackingScheduler := scheduler.NewScheduler(mon, initialize.SchedulerConfig(config), holder, repos.lease, userRepo, snapshotService, deltaService, workerService)
+		logger.InfoArgs(ctx, "Starting tracking scheduler as %s", holder)
+		go trackingScheduler.Run(ctx)
+	}
+
+	// Initialize health service with MongoDB client for deep health checks, reporting the worker through its circuit breaker
+	healthService := health.NewService(client, config.ValueOrPanic("mongo.database.name"), environment, health.BreakerCheck("worker", workerBreaker))
 
-	// Initialize health service with MongoDB client for deep health checks
-	healthService := health.NewService(client, dbName, environment)
-	healthHandler := handler.NewHealthHandler(mon, healthService)
+	tokenValidator := token.NewTokenValidator()
+	tokenService := token.NewTokenService(mon, repos.token, tokenValidator)
 
-	authorizer := middleware.NewAuthorizer(
-		config.BoolValueOrPanic("auth.enabled"),
-		config.StringSliceValueOrPanic("auth.tokens"),
-		mon,
-	)
+	authorizer := initialize.InitAuthorizer(config, tokenService, mon)
+	rateLimiter := initialize.InitRateLimiter(config, mon)
+	router.Use(authorizer.Identify)
+	router.Use(rateLimiter.Limit)
+	router.Use(middleware.NewIdempotency(mon).Replay)
 
 	logger.Info(ctx, "Registering routes")
-	handlers := []handler.HazelmereHandler{healthHandler, snapshotHandler, userHandler, workerHandler, deltaHandler}
+	handlers := handler.NewHandlers(mon, handler.Services{
+		Health:        healthService,
+		Snapshot:      snapshotService,
+		Orchestrator:  orchestrator,
+		User:          userService,
+		UserMerger:    userMerger,
+		UserLifecycle: userLifecycle,
+		AccountTypes:  accountTypeChecker,
+		Worker:        workerService,
+		Job:           jobService,
+		Delta:         deltaService,
+		Group:         groupService,
+		Token:         tokenService,
+		Audit:         auditService,
+	})
 	for i := 0; i < len(handlers); i++ {
 		handlers[i].RegisterRoutes(router, handler.ApiVersionV1, authorizer)
 	}
 
 	return nil
 }
+
+// schedulerHolder identifies this process in the scheduler lease. The hostname tells replicas
+// apart in the lease document; the suffix keeps restarts on the same host distinct.
+func schedulerHolder() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "hazelmere"
+	}
+	return host + "-" + uuid.New().String()[:8]
+}
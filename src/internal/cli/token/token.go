@@ -0,0 +1,179 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/token"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/auth"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-api/src/internal/initialize"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_config"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_logger"
+)
+
+const usage = `Usage:
+  hazelmere token issue --name NAME --scopes SCOPE[,SCOPE...] [--owner OWNER] [--expires DURATION]
+  hazelmere token list
+  hazelmere token rotate ID
+  hazelmere token revoke ID`
+
+func Run(configPath string, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("token requires a subcommand (issue, list, rotate, revoke)\n%s", usage)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	config := hz_config.NewConfigFromPath(configPath)
+	if err := config.Read(); err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	client, err := initialize.MongoClient(
+		config.ValueOrPanic("mongo.connection.host"),
+		config.ValueOrPanic("mongo.connection.username"),
+		config.ValueOrPanic("mongo.connection.password"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to connect to MongoDB: %w", err)
+	}
+	defer initialize.MongoCleanup(ctx, client)
+
+	dbName := config.ValueOrPanic("mongo.database.name")
+	collName := config.ValueOrPanic("mongo.database.collections.token")
+	collection := client.Database(dbName).Collection(collName)
+
+	logger := hz_logger.NewZeroLogAdapater(hz_logger.LogLevelWarn)
+	mon := monitor.New(logger)
+	service := token.NewTokenService(mon, token.NewTokenRepository(collection, mon), token.NewTokenValidator())
+
+	subcmd := args[0]
+	subargs := args[1:]
+	switch subcmd {
+	case "issue":
+		return issue(ctx, service, subargs)
+	case "list":
+		return list(ctx, service)
+	case "rotate":
+		return rotate(ctx, service, subargs)
+	case "revoke":
+		return revoke(ctx, service, subargs)
+	default:
+		return fmt.Errorf("unknown token subcommand: %s\n%s", subcmd, usage)
+	}
+}
+
+func issue(ctx context.Context, service token.TokenService, args []string) error {
+	fs := flag.NewFlagSet("token issue", flag.ContinueOnError)
+	name := fs.String("name", "", "name identifying the token")
+	owner := fs.String("owner", "", "person or service the token was issued to")
+	scopes := fs.String("scopes", "", "comma separated scopes to grant")
+	expires := fs.Duration("expires", 0, "lifetime of the token, e.g. 720h (default: never expires)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	t := token.Token{
+		Name:  *name,
+		Owner: *owner,
+	}
+	for _, scope := range strings.Split(*scopes, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			t.Scopes = append(t.Scopes, auth.Scope(scope))
+		}
+	}
+	if *expires > 0 {
+		expiresAt := time.Now().Add(*expires)
+		t.ExpiresAt = &expiresAt
+	}
+
+	issued, secret, err := service.IssueToken(ctx, t)
+	if err != nil {
+		return fmt.Errorf("failed to issue token: %w", err)
+	}
+
+	fmt.Printf("Issued token %s (%s)\n", issued.Name, issued.Id)
+	printSecret(secret)
+	return nil
+}
+
+func list(ctx context.Context, service token.TokenService) error {
+	tokens, err := service.GetAllTokens(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list tokens: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tOWNER\tSCOPES\tEXPIRES\tLAST USED\tSTATUS")
+	for _, t := range tokens {
+		scopes := make([]string, len(t.Scopes))
+		for i := range t.Scopes {
+			scopes[i] = string(t.Scopes[i])
+		}
+		status := "active"
+		if t.RevokedAt != nil {
+			status = "revoked"
+		} else if t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt) {
+			status = "expired"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			t.Id, t.Name, t.Owner, strings.Join(scopes, ","), formatTime(t.ExpiresAt), formatTime(t.LastUsedAt), status)
+	}
+	return w.Flush()
+}
+
+func rotate(ctx context.Context, service token.TokenService, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("rotate requires a token id\n%s", usage)
+	}
+
+	rotated, secret, err := service.RotateToken(ctx, args[0])
+	if err != nil {
+		if errors.Is(err, token.ErrTokenNotFound) {
+			return fmt.Errorf("token %s not found", args[0])
+		}
+		return fmt.Errorf("failed to rotate token: %w", err)
+	}
+
+	fmt.Printf("Rotated token %s (%s). The previous secret no longer works.\n", rotated.Name, rotated.Id)
+	printSecret(secret)
+	return nil
+}
+
+func revoke(ctx context.Context, service token.TokenService, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("revoke requires a token id\n%s", usage)
+	}
+
+	revoked, err := service.RevokeToken(ctx, args[0])
+	if err != nil {
+		if errors.Is(err, token.ErrTokenNotFound) {
+			return fmt.Errorf("token %s not found", args[0])
+		}
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	fmt.Printf("Revoked token %s (%s)\n", revoked.Name, revoked.Id)
+	return nil
+}
+
+func printSecret(secret string) {
+	fmt.Printf("Secret: %s\n", secret)
+	fmt.Println("Store this secret now, it cannot be shown again.")
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,160 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/audit"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/delta"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/group"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/hiscore"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/snapshot"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/user"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/worker"
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-api/src/internal/initialize"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_config"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_logger"
+)
+
+const usage = `Usage:
+  hazelmere user delete ID [--hard]
+  hazelmere user export ID [--out FILE]`
+
+func Run(configPath string, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("user requires a subcommand (delete, export)\n%s", usage)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	config := hz_config.NewConfigFromPath(configPath)
+	if err := config.Read(); err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	client, err := initialize.MongoClient(
+		config.ValueOrPanic("mongo.connection.host"),
+		config.ValueOrPanic("mongo.connection.username"),
+		config.ValueOrPanic("mongo.connection.password"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to connect to MongoDB: %w", err)
+	}
+	defer initialize.MongoCleanup(ctx, client)
+
+	factory := initialize.MongoFactory(config, client)
+	logger := hz_logger.NewZeroLogAdapater(hz_logger.LogLevelWarn)
+	mon := monitor.New(logger)
+
+	userRepo := user.NewUserRepository(factory.NewUserCollection(), mon)
+	userService := user.NewUserService(mon, userRepo, user.NewUserValidator())
+	snapshotService := snapshot.NewSnapshotService(mon, snapshot.NewSnapshotRepository(factory.NewSnapshotCollection(), mon), snapshot.NewSnapshotValidator(), userRepo)
+	deltaService := delta.NewDeltaService(mon, delta.NewDeltaRepository(factory.NewDeltaCollection(), mon), delta.NewDeltaCache(), userRepo)
+	auditService := audit.NewAuditService(mon, audit.NewAuditRepository(factory.NewAuditCollection(), mon))
+	groupService := group.NewGroupService(mon, group.NewGroupRepository(factory.NewGroupCollection(), mon), group.NewGroupValidator(), userRepo, deltaService)
+	jobService := worker.NewJobService(mon, worker.NewJobRepository(factory.NewJobCollection(), mon), worker.NewJobValidator(), nil)
+	lifecycle := hiscore.NewUserLifecycle(mon, userService, snapshotService, deltaService, auditService, jobService, groupService, database.NewTransactionManager(client, false))
+
+	subcmd := args[0]
+	subargs := args[1:]
+	switch subcmd {
+	case "delete":
+		return deleteUser(ctx, userService, lifecycle, subargs)
+	case "export":
+		return export(ctx, lifecycle, subargs)
+	default:
+		return fmt.Errorf("unknown user subcommand: %s\n%s", subcmd, usage)
+	}
+}
+
+// deleteUser works on the database directly. A running server keeps the deltas of a hard-deleted
+// user in its delta cache until it restarts, so prefer DELETE /v1/admin/user/{id} while it is up.
+func deleteUser(ctx context.Context, service user.UserService, lifecycle hiscore.UserLifecycle, args []string) error {
+	fs := flag.NewFlagSet("user delete", flag.ContinueOnError)
+	hard := fs.Bool("hard", false, "remove the user with their snapshots, deltas, jobs, group memberships and merged users instead of soft deleting")
+	id, err := parseWithId(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if *hard {
+		report, err := lifecycle.HardDeleteUser(ctx, id)
+		if err != nil {
+			return describe(id, "delete", err)
+		}
+		fmt.Printf("Deleted user %s with %d merged users, %d snapshots, %d deltas, %d jobs and %d memberships\n",
+			id, report.MergedUsersDeleted, report.SnapshotsDeleted, report.DeltasDeleted, report.JobsDeleted, report.MembershipsDeleted)
+		return nil
+	}
+
+	deleted, err := service.SoftDeleteUser(ctx, id)
+	if err != nil {
+		return describe(id, "delete", err)
+	}
+	fmt.Printf("Soft deleted user %s (%s). Tracking is disabled and they no longer appear in listings.\n", deleted.RunescapeName, deleted.Id)
+	return nil
+}
+
+func export(ctx context.Context, lifecycle hiscore.UserLifecycle, args []string) error {
+	fs := flag.NewFlagSet("user export", flag.ContinueOnError)
+	out := fs.String("out", "", "file to write the export to (default: stdout)")
+	id, err := parseWithId(fs, args)
+	if err != nil {
+		return err
+	}
+
+	exported, err := lifecycle.ExportUser(ctx, id)
+	if err != nil {
+		return describe(id, "export", err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", *out, err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(exported.ToAPI()); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	if *out != "" {
+		fmt.Fprintf(os.Stderr, "Exported user %s to %s\n", id, *out)
+	}
+	return nil
+}
+
+// parseWithId parses flags given before or after the user id.
+func parseWithId(fs *flag.FlagSet, args []string) (string, error) {
+	if len(args) > 0 && len(args[0]) > 0 && args[0][0] != '-' {
+		args = append(args[1:], args[0])
+	}
+	if err := fs.Parse(args); err != nil {
+		return "", err
+	}
+	if fs.NArg() != 1 {
+		return "", fmt.Errorf("%s requires a user id\n%s", fs.Name(), usage)
+	}
+	return fs.Arg(0), nil
+}
+
+func describe(id string, action string, err error) error {
+	if errors.Is(err, user.ErrUserNotFound) {
+		return fmt.Errorf("user %s not found", id)
+	}
+	return fmt.Errorf("failed to %s user: %w", action, err)
+}
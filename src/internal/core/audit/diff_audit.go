@@ -0,0 +1,57 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// Diff returns the top-level fields that differ between the JSON representations of before
+// and after. Either side may be nil, in which case every field of the other side is reported.
+func Diff(before any, after any) ([]FieldChange, error) {
+	beforeFields, err := toFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := toFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]struct{}, len(beforeFields)+len(afterFields))
+	for name := range beforeFields {
+		names[name] = struct{}{}
+	}
+	for name := range afterFields {
+		names[name] = struct{}{}
+	}
+
+	changes := make([]FieldChange, 0)
+	for name := range names {
+		b, a := beforeFields[name], afterFields[name]
+		if bytes.Equal(b, a) {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: name, Before: b, After: a})
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
+
+func toFields(value any) (map[string]json.RawMessage, error) {
+	if value == nil {
+		return map[string]json.RawMessage{}, nil
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
@@ -0,0 +1,106 @@
+package audit
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// AuditRepository is append-only: records can be inserted and queried, never updated or deleted.
+type AuditRepository interface {
+	InsertRecord(ctx context.Context, record AuditRecordData) (AuditRecordData, error)
+	QueryRecords(ctx context.Context, query AuditQueryData) ([]AuditRecordData, error)
+	// GetRecordsForEntities returns every record about the given entities, newest first.
+	GetRecordsForEntities(ctx context.Context, entityType string, entityIds []string) ([]AuditRecordData, error)
+}
+
+type mongoAuditRepository struct {
+	monitor    *monitor.Monitor
+	collection *mongo.Collection
+}
+
+func NewAuditRepository(auditCollection *mongo.Collection, mon *monitor.Monitor) AuditRepository {
+	return &mongoAuditRepository{
+		collection: auditCollection,
+		monitor:    mon,
+	}
+}
+
+func (ar *mongoAuditRepository) InsertRecord(ctx context.Context, record AuditRecordData) (AuditRecordData, error) {
+	ctx, span := ar.monitor.StartSpan(ctx, "mongoAuditRepository.InsertRecord")
+	defer span.End()
+
+	_, err := ar.collection.InsertOne(ctx, record)
+	if err != nil {
+		return AuditRecordData{}, errors.Join(database.ErrGeneric, err)
+	}
+	return record, nil
+}
+
+func (ar *mongoAuditRepository) QueryRecords(ctx context.Context, query AuditQueryData) ([]AuditRecordData, error) {
+	ctx, span := ar.monitor.StartSpan(ctx, "mongoAuditRepository.QueryRecords")
+	defer span.End()
+
+	filter := bson.M{}
+	if query.Actor != "" {
+		filter["actor"] = query.Actor
+	}
+	if query.EntityType != "" {
+		filter["entityType"] = query.EntityType
+	}
+	if query.EntityId != "" {
+		filter["entityId"] = query.EntityId
+	}
+
+	timestamp := bson.M{}
+	if !query.Start.IsZero() {
+		timestamp["$gte"] = query.Start
+	}
+	if !query.End.IsZero() {
+		timestamp["$lte"] = query.End
+	}
+	if len(timestamp) > 0 {
+		filter["timestamp"] = timestamp
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: -1}}).
+		SetLimit(int64(query.Limit))
+
+	cursor, err := ar.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return []AuditRecordData{}, errors.Join(database.ErrGeneric, err)
+	}
+
+	var results []AuditRecordData
+	if err = cursor.All(ctx, &results); err != nil {
+		return []AuditRecordData{}, errors.Join(database.ErrGeneric, err)
+	}
+
+	return results, nil
+}
+
+func (ar *mongoAuditRepository) GetRecordsForEntities(ctx context.Context, entityType string, entityIds []string) ([]AuditRecordData, error) {
+	ctx, span := ar.monitor.StartSpan(ctx, "mongoAuditRepository.GetRecordsForEntities")
+	defer span.End()
+
+	filter := bson.M{"entityType": entityType, "entityId": bson.M{"$in": entityIds}}
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}})
+
+	cursor, err := ar.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return []AuditRecordData{}, errors.Join(database.ErrGeneric, err)
+	}
+
+	var results []AuditRecordData
+	if err = cursor.All(ctx, &results); err != nil {
+		return []AuditRecordData{}, errors.Join(database.ErrGeneric, err)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,88 @@
+package audit
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+)
+
+type memoryAuditRepository struct {
+	monitor *monitor.Monitor
+	mu      sync.RWMutex
+	records []AuditRecordData
+}
+
+func NewMemoryAuditRepository(mon *monitor.Monitor) AuditRepository {
+	return &memoryAuditRepository{
+		monitor: mon,
+	}
+}
+
+func (ar *memoryAuditRepository) InsertRecord(ctx context.Context, record AuditRecordData) (AuditRecordData, error) {
+	ctx, span := ar.monitor.StartSpan(ctx, "memoryAuditRepository.InsertRecord")
+	defer span.End()
+
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	ar.records = append(ar.records, record)
+	return record, nil
+}
+
+func (ar *memoryAuditRepository) QueryRecords(ctx context.Context, query AuditQueryData) ([]AuditRecordData, error) {
+	ctx, span := ar.monitor.StartSpan(ctx, "memoryAuditRepository.QueryRecords")
+	defer span.End()
+
+	ar.mu.RLock()
+	defer ar.mu.RUnlock()
+
+	var results []AuditRecordData
+	for _, r := range ar.records {
+		if query.Actor != "" && r.Actor != query.Actor {
+			continue
+		}
+		if query.EntityType != "" && r.EntityType != query.EntityType {
+			continue
+		}
+		if query.EntityId != "" && r.EntityId != query.EntityId {
+			continue
+		}
+		if !query.Start.IsZero() && r.Timestamp.Before(query.Start) {
+			continue
+		}
+		if !query.End.IsZero() && r.Timestamp.After(query.End) {
+			continue
+		}
+		results = append(results, r)
+	}
+
+	slices.SortStableFunc(results, func(a, b AuditRecordData) int {
+		return b.Timestamp.Compare(a.Timestamp)
+	})
+	if query.Limit > 0 && len(results) > query.Limit {
+		results = results[:query.Limit]
+	}
+	return results, nil
+}
+
+func (ar *memoryAuditRepository) GetRecordsForEntities(ctx context.Context, entityType string, entityIds []string) ([]AuditRecordData, error) {
+	ctx, span := ar.monitor.StartSpan(ctx, "memoryAuditRepository.GetRecordsForEntities")
+	defer span.End()
+
+	ar.mu.RLock()
+	defer ar.mu.RUnlock()
+
+	var results []AuditRecordData
+	for _, r := range ar.records {
+		if r.EntityType == entityType && slices.Contains(entityIds, r.EntityId) {
+			results = append(results, r)
+		}
+	}
+
+	slices.SortStableFunc(results, func(a, b AuditRecordData) int {
+		return b.Timestamp.Compare(a.Timestamp)
+	})
+	return results, nil
+}
@@ -0,0 +1,99 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/google/uuid"
+)
+
+var ErrAuditGeneric = errors.New("an error occurred while performing the audit operation")
+var ErrInvalidAuditQuery = errors.New("audit query is invalid")
+
+const defaultQueryLimit = 100
+
+// MaxQueryLimit is the most records a single query returns.
+const MaxQueryLimit = 1000
+
+// entityBatchSize is the most entity ids looked up in one repository call, to keep filters small.
+const entityBatchSize = 1000
+
+type AuditService interface {
+	Record(ctx context.Context, record AuditRecord) error
+	QueryRecords(ctx context.Context, query AuditQuery) ([]AuditRecord, error)
+	// GetRecordsForEntities returns every record about the given entities, newest first. Unlike
+	// QueryRecords it is not limited, for exports that must be complete.
+	GetRecordsForEntities(ctx context.Context, entityType EntityType, entityIds []string) ([]AuditRecord, error)
+}
+
+type auditService struct {
+	monitor    *monitor.Monitor
+	repository AuditRepository
+}
+
+func NewAuditService(mon *monitor.Monitor, repository AuditRepository) AuditService {
+	return &auditService{
+		monitor:    mon,
+		repository: repository,
+	}
+}
+
+func (as *auditService) Record(ctx context.Context, record AuditRecord) error {
+	ctx, span := as.monitor.StartSpan(ctx, "auditService.Record")
+	defer span.End()
+
+	record.Id = uuid.New().String()
+	if record.Timestamp.IsZero() {
+		record.Timestamp = time.Now()
+	}
+
+	_, err := as.repository.InsertRecord(ctx, record.ToData())
+	if err != nil {
+		return errors.Join(ErrAuditGeneric, err)
+	}
+	return nil
+}
+
+func (as *auditService) QueryRecords(ctx context.Context, query AuditQuery) ([]AuditRecord, error) {
+	ctx, span := as.monitor.StartSpan(ctx, "auditService.QueryRecords")
+	defer span.End()
+
+	if !query.Start.IsZero() && !query.End.IsZero() && query.End.Before(query.Start) {
+		return []AuditRecord{}, errors.Join(ErrInvalidAuditQuery, errors.New("end must not be before start"))
+	}
+
+	if query.Limit <= 0 {
+		query.Limit = defaultQueryLimit
+	}
+	if query.Limit > MaxQueryLimit {
+		query.Limit = MaxQueryLimit
+	}
+
+	data, err := as.repository.QueryRecords(ctx, query.ToData())
+	if err != nil {
+		return []AuditRecord{}, errors.Join(ErrAuditGeneric, err)
+	}
+	return AuditRecord{}.ManyFromData(data), nil
+}
+
+func (as *auditService) GetRecordsForEntities(ctx context.Context, entityType EntityType, entityIds []string) ([]AuditRecord, error) {
+	ctx, span := as.monitor.StartSpan(ctx, "auditService.GetRecordsForEntities")
+	defer span.End()
+
+	var data []AuditRecordData
+	for batch := range slices.Chunk(entityIds, entityBatchSize) {
+		records, err := as.repository.GetRecordsForEntities(ctx, string(entityType), batch)
+		if err != nil {
+			return []AuditRecord{}, errors.Join(ErrAuditGeneric, err)
+		}
+		data = append(data, records...)
+	}
+
+	slices.SortStableFunc(data, func(a, b AuditRecordData) int {
+		return b.Timestamp.Compare(a.Timestamp)
+	})
+	return AuditRecord{}.ManyFromData(data), nil
+}
@@ -0,0 +1,31 @@
+package audit
+
+import "time"
+
+type AuditRecordData struct {
+	Id         string            `bson:"_id"`
+	Timestamp  time.Time         `bson:"timestamp"`
+	Actor      string            `bson:"actor"`
+	Caller     string            `bson:"caller,omitempty"`
+	RequestId  string            `bson:"requestId,omitempty"`
+	Action     string            `bson:"action"`
+	EntityType string            `bson:"entityType"`
+	EntityId   string            `bson:"entityId"`
+	Changes    []FieldChangeData `bson:"changes"`
+}
+
+// FieldChangeData holds the before and after values as JSON so arbitrary values round-trip unchanged.
+type FieldChangeData struct {
+	Field  string `bson:"field"`
+	Before string `bson:"before,omitempty"`
+	After  string `bson:"after,omitempty"`
+}
+
+type AuditQueryData struct {
+	Actor      string
+	EntityType string
+	EntityId   string
+	Start      time.Time
+	End        time.Time
+	Limit      int
+}
@@ -0,0 +1,166 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+)
+
+type Action string
+
+const (
+	ActionUserCreate             Action = "user.create"
+	ActionUserUpdate             Action = "user.update"
+	ActionUserRename             Action = "user.rename"
+	ActionUserMerge              Action = "user.merge"
+	ActionUserDelete             Action = "user.delete"
+	ActionUserPurge              Action = "user.purge"
+	ActionUserExport             Action = "user.export"
+	ActionUserAccountType        Action = "user.account_type"
+	ActionSnapshotCreate         Action = "snapshot.create"
+	ActionWorkerSnapshotOnDemand Action = "worker.snapshot_on_demand"
+	ActionWorkerJobCreate        Action = "worker.job_create"
+	ActionGroupCreate            Action = "group.create"
+	ActionGroupUpdate            Action = "group.update"
+	ActionGroupDelete            Action = "group.delete"
+	ActionGroupMemberAdd         Action = "group.member_add"
+	ActionGroupMemberUpdate      Action = "group.member_update"
+	ActionGroupMemberRemove      Action = "group.member_remove"
+	ActionTokenIssue             Action = "token.issue"
+	ActionTokenRotate            Action = "token.rotate"
+	ActionTokenRevoke            Action = "token.revoke"
+)
+
+type EntityType string
+
+const (
+	EntityTypeUser     EntityType = "user"
+	EntityTypeSnapshot EntityType = "snapshot"
+	EntityTypeJob      EntityType = "job"
+	EntityTypeGroup    EntityType = "group"
+	EntityTypeToken    EntityType = "token"
+)
+
+type AuditRecord struct {
+	Id         string
+	Timestamp  time.Time
+	Actor      string
+	Caller     string
+	RequestId  string
+	Action     Action
+	EntityType EntityType
+	EntityId   string
+	Changes    []FieldChange
+}
+
+// FieldChange is the JSON value of a single top-level field before and after a write.
+// Before is empty for creations.
+type FieldChange struct {
+	Field  string
+	Before json.RawMessage
+	After  json.RawMessage
+}
+
+type AuditQuery struct {
+	Actor      string
+	EntityType EntityType
+	EntityId   string
+	Start      time.Time
+	End        time.Time
+	Limit      int
+}
+
+// ToAPI converts the domain AuditRecord to an API AuditRecord
+func (ar AuditRecord) ToAPI() api.AuditRecord {
+	changes := make([]api.FieldChange, len(ar.Changes))
+	for i, c := range ar.Changes {
+		changes[i] = api.FieldChange{Field: c.Field, Before: c.Before, After: c.After}
+	}
+	return api.AuditRecord{
+		Id:         ar.Id,
+		Timestamp:  ar.Timestamp,
+		Actor:      ar.Actor,
+		Caller:     ar.Caller,
+		RequestId:  ar.RequestId,
+		Action:     string(ar.Action),
+		EntityType: string(ar.EntityType),
+		EntityId:   ar.EntityId,
+		Changes:    changes,
+	}
+}
+
+// ToData converts the domain AuditRecord to a data layer AuditRecordData
+func (ar AuditRecord) ToData() AuditRecordData {
+	changes := make([]FieldChangeData, len(ar.Changes))
+	for i, c := range ar.Changes {
+		changes[i] = FieldChangeData{Field: c.Field, Before: string(c.Before), After: string(c.After)}
+	}
+	return AuditRecordData{
+		Id:         ar.Id,
+		Timestamp:  ar.Timestamp,
+		Actor:      ar.Actor,
+		Caller:     ar.Caller,
+		RequestId:  ar.RequestId,
+		Action:     string(ar.Action),
+		EntityType: string(ar.EntityType),
+		EntityId:   ar.EntityId,
+		Changes:    changes,
+	}
+}
+
+// FromData creates a domain AuditRecord from data layer AuditRecordData (call as AuditRecord{}.FromData(...))
+func (AuditRecord) FromData(data AuditRecordData) AuditRecord {
+	changes := make([]FieldChange, len(data.Changes))
+	for i, c := range data.Changes {
+		changes[i] = FieldChange{Field: c.Field, Before: rawOrNil(c.Before), After: rawOrNil(c.After)}
+	}
+	return AuditRecord{
+		Id:         data.Id,
+		Timestamp:  data.Timestamp,
+		Actor:      data.Actor,
+		Caller:     data.Caller,
+		RequestId:  data.RequestId,
+		Action:     Action(data.Action),
+		EntityType: EntityType(data.EntityType),
+		EntityId:   data.EntityId,
+		Changes:    changes,
+	}
+}
+
+// ToData converts the domain AuditQuery to a data layer AuditQueryData
+func (aq AuditQuery) ToData() AuditQueryData {
+	return AuditQueryData{
+		Actor:      aq.Actor,
+		EntityType: string(aq.EntityType),
+		EntityId:   aq.EntityId,
+		Start:      aq.Start,
+		End:        aq.End,
+		Limit:      aq.Limit,
+	}
+}
+
+// ManyToAPI converts a slice of domain AuditRecords to API AuditRecords (call as AuditRecord{}.ManyToAPI(...))
+func (AuditRecord) ManyToAPI(records []AuditRecord) []api.AuditRecord {
+	apiRecords := make([]api.AuditRecord, len(records))
+	for i := range records {
+		apiRecords[i] = records[i].ToAPI()
+	}
+	return apiRecords
+}
+
+// ManyFromData converts a slice of AuditRecordData to domain AuditRecords (call as AuditRecord{}.ManyFromData(...))
+func (AuditRecord) ManyFromData(data []AuditRecordData) []AuditRecord {
+	records := make([]AuditRecord, len(data))
+	for i := range data {
+		records[i] = AuditRecord{}.FromData(data[i])
+	}
+	return records
+}
+
+func rawOrNil(value string) json.RawMessage {
+	if value == "" {
+		return nil
+	}
+	return json.RawMessage(value)
+}
@@ -120,6 +120,13 @@ func (dc *DeltaCache) GetDeltasInRange(userId string, startTime, endTime time.Ti
 	return result, true
 }
 
+// RemoveUser drops a user's cached deltas
+func (dc *DeltaCache) RemoveUser(userId string) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	delete(dc.cache, userId)
+}
+
 // IsCached checks if a user has cached deltas
 func (dc *DeltaCache) IsCached(userId string) bool {
 	dc.mu.RLock()
@@ -148,6 +155,18 @@ func (dc *DeltaCache) GetTotalCachedUsers() int {
 	return len(dc.cache)
 }
 
+// GetTotalCachedDeltas returns the number of daily aggregated deltas cached across all users
+func (dc *DeltaCache) GetTotalCachedDeltas() int {
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
+
+	total := 0
+	for _, cached := range dc.cache {
+		total += len(cached.DailyDeltas)
+	}
+	return total
+}
+
 // aggregateDeltasByDay groups raw deltas by day and merges them
 func aggregateDeltasByDay(rawDeltas []HiscoreDeltaData) map[string]HiscoreDelta {
 	dailyMap := make(map[string]HiscoreDelta)
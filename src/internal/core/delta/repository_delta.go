@@ -19,6 +19,8 @@ type DeltaRepository interface {
 	GetDeltasInRange(ctx context.Context, userId string, startTime, endTime time.Time) ([]HiscoreDeltaData, error)
 	GetAllDeltasForUser(ctx context.Context, userId string) ([]HiscoreDeltaData, error)
 	CountDeltasForUser(ctx context.Context, userId string) (int64, error)
+	DeleteDeltasForSnapshot(ctx context.Context, snapshotId string) (int64, error)
+	DeleteDeltasForUser(ctx context.Context, userId string) (int64, error)
 }
 
 type mongoDeltaRepository struct {
@@ -145,3 +147,29 @@ func (dr *mongoDeltaRepository) CountDeltasForUser(ctx context.Context, userId s
 	}
 	return count, nil
 }
+
+// DeleteDeltasForSnapshot removes the deltas ending at snapshotId and returns how many it removed.
+func (dr *mongoDeltaRepository) DeleteDeltasForSnapshot(ctx context.Context, snapshotId string) (int64, error) {
+	ctx, span := dr.monitor.StartSpan(ctx, "mongoDeltaRepository.DeleteDeltasForSnapshot")
+	defer span.End()
+
+	filter := bson.M{"snapshotId": snapshotId}
+	result, err := dr.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, errors.Join(database.ErrGeneric, err)
+	}
+	return result.DeletedCount, nil
+}
+
+// DeleteDeltasForUser removes every delta of userId and returns how many it removed.
+func (dr *mongoDeltaRepository) DeleteDeltasForUser(ctx context.Context, userId string) (int64, error) {
+	ctx, span := dr.monitor.StartSpan(ctx, "mongoDeltaRepository.DeleteDeltasForUser")
+	defer span.End()
+
+	filter := bson.M{"userId": userId}
+	result, err := dr.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, errors.Join(database.ErrGeneric, err)
+	}
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,138 @@
+package delta
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+)
+
+// memoryDeltaRepository mirrors the sorting and range semantics of mongoDeltaRepository.
+type memoryDeltaRepository struct {
+	monitor *monitor.Monitor
+	mu      sync.RWMutex
+	deltas  []HiscoreDeltaData
+}
+
+func NewMemoryDeltaRepository(mon *monitor.Monitor) DeltaRepository {
+	return &memoryDeltaRepository{
+		monitor: mon,
+	}
+}
+
+func (dr *memoryDeltaRepository) InsertDelta(ctx context.Context, delta HiscoreDeltaData) (HiscoreDeltaData, error) {
+	ctx, span := dr.monitor.StartSpan(ctx, "memoryDeltaRepository.InsertDelta")
+	defer span.End()
+
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+
+	for _, d := range dr.deltas {
+		if d.Id == delta.Id {
+			return HiscoreDeltaData{}, fmt.Errorf("%w: duplicate delta id %s", database.ErrGeneric, delta.Id)
+		}
+	}
+	dr.deltas = append(dr.deltas, delta)
+	return delta, nil
+}
+
+func (dr *memoryDeltaRepository) GetDeltaById(ctx context.Context, id string) (HiscoreDeltaData, error) {
+	ctx, span := dr.monitor.StartSpan(ctx, "memoryDeltaRepository.GetDeltaById")
+	defer span.End()
+
+	dr.mu.RLock()
+	defer dr.mu.RUnlock()
+
+	for _, d := range dr.deltas {
+		if d.Id == id {
+			return d, nil
+		}
+	}
+	return HiscoreDeltaData{}, database.ErrNotFound
+}
+
+func (dr *memoryDeltaRepository) GetLatestDeltaForUser(ctx context.Context, userId string) (HiscoreDeltaData, error) {
+	ctx, span := dr.monitor.StartSpan(ctx, "memoryDeltaRepository.GetLatestDeltaForUser")
+	defer span.End()
+
+	deltas := dr.sortedForUser(userId)
+	if len(deltas) == 0 {
+		return HiscoreDeltaData{}, database.ErrNotFound
+	}
+	return deltas[len(deltas)-1], nil
+}
+
+func (dr *memoryDeltaRepository) GetDeltasInRange(ctx context.Context, userId string, startTime, endTime time.Time) ([]HiscoreDeltaData, error) {
+	ctx, span := dr.monitor.StartSpan(ctx, "memoryDeltaRepository.GetDeltasInRange")
+	defer span.End()
+
+	var results []HiscoreDeltaData
+	for _, d := range dr.sortedForUser(userId) {
+		if !d.Timestamp.Before(startTime) && !d.Timestamp.After(endTime) {
+			results = append(results, d)
+		}
+	}
+	return results, nil
+}
+
+func (dr *memoryDeltaRepository) GetAllDeltasForUser(ctx context.Context, userId string) ([]HiscoreDeltaData, error) {
+	ctx, span := dr.monitor.StartSpan(ctx, "memoryDeltaRepository.GetAllDeltasForUser")
+	defer span.End()
+
+	return dr.sortedForUser(userId), nil
+}
+
+func (dr *memoryDeltaRepository) CountDeltasForUser(ctx context.Context, userId string) (int64, error) {
+	ctx, span := dr.monitor.StartSpan(ctx, "memoryDeltaRepository.CountDeltasForUser")
+	defer span.End()
+
+	return int64(len(dr.sortedForUser(userId))), nil
+}
+
+func (dr *memoryDeltaRepository) sortedForUser(userId string) []HiscoreDeltaData {
+	dr.mu.RLock()
+	defer dr.mu.RUnlock()
+
+	var results []HiscoreDeltaData
+	for _, d := range dr.deltas {
+		if d.UserId == userId {
+			results = append(results, d)
+		}
+	}
+	slices.SortStableFunc(results, func(a, b HiscoreDeltaData) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+	return results
+}
+
+func (dr *memoryDeltaRepository) DeleteDeltasForSnapshot(ctx context.Context, snapshotId string) (int64, error) {
+	ctx, span := dr.monitor.StartSpan(ctx, "memoryDeltaRepository.DeleteDeltasForSnapshot")
+	defer span.End()
+
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+
+	before := len(dr.deltas)
+	dr.deltas = slices.DeleteFunc(dr.deltas, func(d HiscoreDeltaData) bool {
+		return d.SnapshotId == snapshotId
+	})
+	return int64(before - len(dr.deltas)), nil
+}
+
+func (dr *memoryDeltaRepository) DeleteDeltasForUser(ctx context.Context, userId string) (int64, error) {
+	ctx, span := dr.monitor.StartSpan(ctx, "memoryDeltaRepository.DeleteDeltasForUser")
+	defer span.End()
+
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+
+	before := len(dr.deltas)
+	dr.deltas = slices.DeleteFunc(dr.deltas, func(d HiscoreDeltaData) bool {
+		return d.UserId == userId
+	})
+	return int64(before - len(dr.deltas)), nil
+}
@@ -0,0 +1,174 @@
+package delta_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/delta"
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/internal/database/databasetest"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_logger"
+	"github.com/google/uuid"
+)
+
+var testMonitor = monitor.New(hz_logger.NewZeroLogAdapater(hz_logger.LogLevelError))
+
+func TestMemoryDeltaRepository(t *testing.T) {
+	testDeltaRepository(t, func(t *testing.T) delta.DeltaRepository {
+		return delta.NewMemoryDeltaRepository(testMonitor)
+	})
+}
+
+func TestMongoDeltaRepository(t *testing.T) {
+	testDeltaRepository(t, func(t *testing.T) delta.DeltaRepository {
+		return delta.NewDeltaRepository(databasetest.Collection(t, "delta"), testMonitor)
+	})
+}
+
+// base is truncated to milliseconds because that is the precision Mongo stores.
+var base = time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+func newDeltaData(userId string, timestamp time.Time) delta.HiscoreDeltaData {
+	return delta.HiscoreDeltaData{
+		Id:                 uuid.New().String(),
+		UserId:             userId,
+		SnapshotId:         uuid.New().String(),
+		PreviousSnapshotId: uuid.New().String(),
+		Timestamp:          timestamp,
+		Skills:             []delta.SkillDeltaData{{ActivityType: "OVERALL", Name: "Overall", ExperienceGain: 100, LevelGain: 1}},
+		Bosses:             []delta.BossDeltaData{{ActivityType: "ZULRAH", Name: "Zulrah", KillCountGain: 2}},
+		Activities:         []delta.ActivityDeltaData{{ActivityType: "CLUE_SCROLLS_ALL", Name: "Clues", ScoreGain: 1}},
+	}
+}
+
+func insertDeltas(t *testing.T, repo delta.DeltaRepository, deltas ...delta.HiscoreDeltaData) {
+	t.Helper()
+	for _, d := range deltas {
+		if _, err := repo.InsertDelta(context.Background(), d); err != nil {
+			t.Fatalf("InsertDelta: %v", err)
+		}
+	}
+}
+
+func deltaIds(deltas []delta.HiscoreDeltaData) []string {
+	ids := make([]string, 0, len(deltas))
+	for _, d := range deltas {
+		ids = append(ids, d.Id)
+	}
+	return ids
+}
+
+// testDeltaRepository is the conformance suite every DeltaRepository must pass.
+func testDeltaRepository(t *testing.T, newRepo func(t *testing.T) delta.DeltaRepository) {
+	ctx := context.Background()
+
+	t.Run("GetDeltaById", func(t *testing.T) {
+		repo := newRepo(t)
+		want := newDeltaData(uuid.New().String(), base)
+		insertDeltas(t, repo, want)
+
+		got, err := repo.GetDeltaById(ctx, want.Id)
+		if err != nil {
+			t.Fatalf("GetDeltaById: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GetDeltaById = %+v, want %+v", got, want)
+		}
+
+		if _, err := repo.GetDeltaById(ctx, uuid.New().String()); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("GetDeltaById of an unknown id: got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("Latest", func(t *testing.T) {
+		repo := newRepo(t)
+		userId := uuid.New().String()
+		newest := newDeltaData(userId, base.Add(time.Hour))
+		insertDeltas(t, repo, newDeltaData(userId, base), newest, newDeltaData(userId, base.Add(-time.Hour)))
+
+		got, err := repo.GetLatestDeltaForUser(ctx, userId)
+		if err != nil || got.Id != newest.Id {
+			t.Errorf("GetLatestDeltaForUser = %s, %v; want %s", got.Id, err, newest.Id)
+		}
+		if _, err := repo.GetLatestDeltaForUser(ctx, uuid.New().String()); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("GetLatestDeltaForUser of an unknown user: got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("RangeAllAndCount", func(t *testing.T) {
+		repo := newRepo(t)
+		userId := uuid.New().String()
+		before := newDeltaData(userId, base.Add(-time.Minute))
+		atStart := newDeltaData(userId, base)
+		inside := newDeltaData(userId, base.Add(30*time.Minute))
+		atEnd := newDeltaData(userId, base.Add(time.Hour))
+		after := newDeltaData(userId, base.Add(time.Hour+time.Minute))
+		insertDeltas(t, repo, inside, after, atEnd, before, atStart, newDeltaData(uuid.New().String(), base))
+
+		got, err := repo.GetDeltasInRange(ctx, userId, base, base.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("GetDeltasInRange: %v", err)
+		}
+		want := []string{atStart.Id, inside.Id, atEnd.Id}
+		if !reflect.DeepEqual(deltaIds(got), want) {
+			t.Errorf("GetDeltasInRange = %v, want %v sorted by timestamp with inclusive bounds", deltaIds(got), want)
+		}
+
+		all, err := repo.GetAllDeltasForUser(ctx, userId)
+		if err != nil {
+			t.Fatalf("GetAllDeltasForUser: %v", err)
+		}
+		want = []string{before.Id, atStart.Id, inside.Id, atEnd.Id, after.Id}
+		if !reflect.DeepEqual(deltaIds(all), want) {
+			t.Errorf("GetAllDeltasForUser = %v, want %v sorted by timestamp", deltaIds(all), want)
+		}
+
+		count, err := repo.CountDeltasForUser(ctx, userId)
+		if err != nil || count != 5 {
+			t.Errorf("CountDeltasForUser = %d, %v; want 5", count, err)
+		}
+	})
+
+	t.Run("DeleteDeltasForSnapshot", func(t *testing.T) {
+		repo := newRepo(t)
+		userId := uuid.New().String()
+		kept := newDeltaData(userId, base)
+		removed := newDeltaData(userId, base.Add(time.Hour))
+		insertDeltas(t, repo, kept, removed)
+
+		deleted, err := repo.DeleteDeltasForSnapshot(ctx, removed.SnapshotId)
+		if err != nil || deleted != 1 {
+			t.Errorf("DeleteDeltasForSnapshot = %d, %v; want 1", deleted, err)
+		}
+		if deleted, err := repo.DeleteDeltasForSnapshot(ctx, uuid.New().String()); err != nil || deleted != 0 {
+			t.Errorf("DeleteDeltasForSnapshot of an unknown snapshot = %d, %v; want 0", deleted, err)
+		}
+
+		all, err := repo.GetAllDeltasForUser(ctx, userId)
+		if err != nil || !reflect.DeepEqual(deltaIds(all), []string{kept.Id}) {
+			t.Errorf("GetAllDeltasForUser after delete = %v, %v; want [%s]", deltaIds(all), err, kept.Id)
+		}
+	})
+
+	t.Run("DeleteDeltasForUser", func(t *testing.T) {
+		repo := newRepo(t)
+		userId, otherId := uuid.New().String(), uuid.New().String()
+		other := newDeltaData(otherId, base)
+		insertDeltas(t, repo, newDeltaData(userId, base), newDeltaData(userId, base.Add(time.Hour)), other)
+
+		if deleted, err := repo.DeleteDeltasForUser(ctx, userId); err != nil || deleted != 2 {
+			t.Errorf("DeleteDeltasForUser = %d, %v; want 2", deleted, err)
+		}
+		if count, err := repo.CountDeltasForUser(ctx, userId); err != nil || count != 0 {
+			t.Errorf("CountDeltasForUser after delete = %d, %v; want 0", count, err)
+		}
+		all, err := repo.GetAllDeltasForUser(ctx, otherId)
+		if err != nil || !reflect.DeepEqual(deltaIds(all), []string{other.Id}) {
+			t.Errorf("GetAllDeltasForUser of another user = %v, %v; want [%s]", deltaIds(all), err, other.Id)
+		}
+	})
+}
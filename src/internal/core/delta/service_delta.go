@@ -26,8 +26,16 @@ type DeltaIntervalResponse struct {
 
 type DeltaService interface {
 	CreateDelta(ctx context.Context, delta HiscoreDelta) (HiscoreDelta, error)
+	DeleteDeltasForSnapshot(ctx context.Context, userId string, snapshotId string) error
+	DeleteDeltasForUser(ctx context.Context, userId string) (int64, error)
+	CountDeltasForUser(ctx context.Context, userId string) (int64, error)
+	GetAllDeltasForUser(ctx context.Context, userId string) ([]HiscoreDelta, error)
+	RebuildCache(ctx context.Context, userId string) error
 	GetLatestDeltaForUser(ctx context.Context, userId string) (HiscoreDelta, error)
 	GetDeltasInRange(ctx context.Context, userId string, startTime, endTime time.Time) (DeltaIntervalResponse, error)
+	// GetStoredDeltasInRange reads the deltas in [startTime, endTime] from the repository rather
+	// than the daily aggregates of the cache, for callers that need the time of each delta.
+	GetStoredDeltasInRange(ctx context.Context, userId string, startTime, endTime time.Time) ([]HiscoreDelta, error)
 	GetDeltaSummary(ctx context.Context, userId string, startTime, endTime time.Time) (api.GetDeltaSummaryResponse, error)
 	PrimeCache(ctx context.Context) error
 }
@@ -57,7 +65,7 @@ func (ds *deltaService) CreateDelta(ctx context.Context, delta HiscoreDelta) (Hi
 	}
 
 	// Only insert if there are any changes
-	if len(delta.Skills) == 0 && len(delta.Bosses) == 0 && len(delta.Activities) == 0 {
+	if delta.IsEmpty() {
 		ds.monitor.Logger().DebugArgs(ctx, "No changes in delta, skipping creation for user %s", delta.UserId)
 		return HiscoreDelta{}, nil
 	}
@@ -76,12 +84,87 @@ func (ds *deltaService) CreateDelta(ctx context.Context, delta HiscoreDelta) (Hi
 	return HiscoreDelta{}.FromData(data), nil
 }
 
+// DeleteDeltasForSnapshot removes the deltas ending at snapshotId. The user's cached daily
+// aggregates cannot have a delta subtracted, so they are reloaded from the repository.
+func (ds *deltaService) DeleteDeltasForSnapshot(ctx context.Context, userId string, snapshotId string) error {
+	ctx, span := ds.monitor.StartSpan(ctx, "deltaService.DeleteDeltasForSnapshot")
+	defer span.End()
+
+	deleted, err := ds.repository.DeleteDeltasForSnapshot(ctx, snapshotId)
+	if err != nil {
+		return errors.Join(ErrDeltaGeneric, err)
+	}
+	if deleted > 0 && ds.cache.IsCached(userId) {
+		deltas, err := ds.repository.GetAllDeltasForUser(ctx, userId)
+		if err != nil {
+			return errors.Join(ErrDeltaGeneric, err)
+		}
+		ds.cache.SetUserDeltas(userId, deltas)
+	}
+	return nil
+}
+
+// DeleteDeltasForUser removes every delta of a user, and their cached daily aggregates with them.
+func (ds *deltaService) DeleteDeltasForUser(ctx context.Context, userId string) (int64, error) {
+	ctx, span := ds.monitor.StartSpan(ctx, "deltaService.DeleteDeltasForUser")
+	defer span.End()
+
+	deleted, err := ds.repository.DeleteDeltasForUser(ctx, userId)
+	if err != nil {
+		return 0, errors.Join(ErrDeltaGeneric, err)
+	}
+	ds.cache.RemoveUser(userId)
+	return deleted, nil
+}
+
+func (ds *deltaService) CountDeltasForUser(ctx context.Context, userId string) (int64, error) {
+	ctx, span := ds.monitor.StartSpan(ctx, "deltaService.CountDeltasForUser")
+	defer span.End()
+
+	count, err := ds.repository.CountDeltasForUser(ctx, userId)
+	if err != nil {
+		return 0, errors.Join(ErrDeltaGeneric, err)
+	}
+	return count, nil
+}
+
+func (ds *deltaService) GetAllDeltasForUser(ctx context.Context, userId string) ([]HiscoreDelta, error) {
+	ctx, span := ds.monitor.StartSpan(ctx, "deltaService.GetAllDeltasForUser")
+	defer span.End()
+
+	data, err := ds.repository.GetAllDeltasForUser(ctx, userId)
+	if err != nil {
+		return nil, errors.Join(ErrDeltaGeneric, err)
+	}
+	return HiscoreDelta{}.ManyFromData(data), nil
+}
+
+// RebuildCache reloads a user's cached daily aggregates from the repository, e.g. after their
+// deltas were rewritten. A user without deltas is dropped from the cache.
+func (ds *deltaService) RebuildCache(ctx context.Context, userId string) error {
+	ctx, span := ds.monitor.StartSpan(ctx, "deltaService.RebuildCache")
+	defer span.End()
+
+	deltas, err := ds.repository.GetAllDeltasForUser(ctx, userId)
+	if err != nil {
+		return errors.Join(ErrDeltaGeneric, err)
+	}
+	if len(deltas) == 0 {
+		ds.cache.RemoveUser(userId)
+		return nil
+	}
+	ds.cache.SetUserDeltas(userId, deltas)
+	return nil
+}
+
 func (ds *deltaService) GetLatestDeltaForUser(ctx context.Context, userId string) (HiscoreDelta, error) {
 	ctx, span := ds.monitor.StartSpan(ctx, "deltaService.GetLatestDeltaForUser")
 	defer span.End()
 
 	// Check cache first - returns domain type directly
-	if delta, found := ds.cache.GetLatestDelta(userId); found {
+	delta, found := ds.cache.GetLatestDelta(userId)
+	ds.monitor.Metrics().RecordDeltaCacheLookup(ctx, found)
+	if found {
 		ds.monitor.Logger().DebugArgs(ctx, "Cache hit for latest delta for user %s", userId)
 		return delta, nil
 	}
@@ -108,7 +191,9 @@ func (ds *deltaService) GetDeltasInRange(ctx context.Context, userId string, sta
 	}
 
 	// Check cache first - returns domain types directly (pre-aggregated by day)
-	if deltas, found := ds.cache.GetDeltasInRange(userId, startTime, endTime); found {
+	deltas, found := ds.cache.GetDeltasInRange(userId, startTime, endTime)
+	ds.monitor.Metrics().RecordDeltaCacheLookup(ctx, found)
+	if found {
 		ds.monitor.Logger().DebugArgs(ctx, "Cache hit for deltas in range for user %s", userId)
 		return DeltaIntervalResponse{
 			Deltas:      deltas,
@@ -129,6 +214,17 @@ func (ds *deltaService) GetDeltasInRange(ctx context.Context, userId string, sta
 	}, nil
 }
 
+func (ds *deltaService) GetStoredDeltasInRange(ctx context.Context, userId string, startTime, endTime time.Time) ([]HiscoreDelta, error) {
+	ctx, span := ds.monitor.StartSpan(ctx, "deltaService.GetStoredDeltasInRange")
+	defer span.End()
+
+	data, err := ds.repository.GetDeltasInRange(ctx, userId, startTime, endTime)
+	if err != nil {
+		return []HiscoreDelta{}, errors.Join(ErrDeltaGeneric, err)
+	}
+	return HiscoreDelta{}.ManyFromData(data), nil
+}
+
 func (ds *deltaService) GetDeltaSummary(ctx context.Context, userId string, startTime, endTime time.Time) (api.GetDeltaSummaryResponse, error) {
 	ctx, span := ds.monitor.StartSpan(ctx, "deltaService.GetDeltaSummary")
 	defer span.End()
@@ -140,7 +236,9 @@ func (ds *deltaService) GetDeltaSummary(ctx context.Context, userId string, star
 
 	// Check cache first, fall back to repository
 	var deltas []HiscoreDelta
-	if cachedDeltas, found := ds.cache.GetDeltasInRange(userId, startTime, endTime); found {
+	cachedDeltas, found := ds.cache.GetDeltasInRange(userId, startTime, endTime)
+	ds.monitor.Metrics().RecordDeltaCacheLookup(ctx, found)
+	if found {
 		ds.monitor.Logger().DebugArgs(ctx, "Cache hit for delta summary for user %s", userId)
 		deltas = cachedDeltas
 	} else {
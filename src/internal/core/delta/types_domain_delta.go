@@ -37,6 +37,11 @@ type ActivityDelta struct {
 	ScoreGain    int
 }
 
+// IsEmpty reports whether nothing changed between the two snapshots of the delta.
+func (hd HiscoreDelta) IsEmpty() bool {
+	return len(hd.Skills) == 0 && len(hd.Bosses) == 0 && len(hd.Activities) == 0
+}
+
 // ToAPI converts the domain HiscoreDelta to an API HiscoreDelta
 func (hd HiscoreDelta) ToAPI() api.HiscoreDelta {
 	skills := make([]api.SkillDelta, len(hd.Skills))
@@ -0,0 +1,146 @@
+package group
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// GroupRepository compares group names as normalized by NormalizeGroupName, and keeps
+// GroupData.NormalizedName in sync on writes.
+type GroupRepository interface {
+	GetGroupById(ctx context.Context, id string) (GroupData, error)
+	GetGroupByName(ctx context.Context, name string) (GroupData, error)
+	GetAllGroups(ctx context.Context) ([]GroupData, error)
+	// GetGroupsByMember returns the groups userId has a membership of, open or closed.
+	GetGroupsByMember(ctx context.Context, userId string) ([]GroupData, error)
+	CreateGroup(ctx context.Context, group GroupData) (GroupData, error)
+	UpdateGroup(ctx context.Context, group GroupData) (GroupData, error)
+	// DeleteGroup removes the group. Returns database.ErrNotFound for an unknown id.
+	DeleteGroup(ctx context.Context, id string) error
+}
+
+type mongoGroupRepository struct {
+	monitor    *monitor.Monitor
+	collection *mongo.Collection
+}
+
+func NewGroupRepository(groupCollection *mongo.Collection, mon *monitor.Monitor) GroupRepository {
+	return &mongoGroupRepository{
+		collection: groupCollection,
+		monitor:    mon,
+	}
+}
+
+func (gr *mongoGroupRepository) GetGroupById(ctx context.Context, id string) (GroupData, error) {
+	ctx, span := gr.monitor.StartSpan(ctx, "mongoGroupRepository.GetGroupById")
+	defer span.End()
+
+	return gr.findOne(ctx, bson.M{"_id": id})
+}
+
+func (gr *mongoGroupRepository) GetGroupByName(ctx context.Context, name string) (GroupData, error) {
+	ctx, span := gr.monitor.StartSpan(ctx, "mongoGroupRepository.GetGroupByName")
+	defer span.End()
+
+	return gr.findOne(ctx, bson.M{"normalizedName": NormalizeGroupName(name)})
+}
+
+func (gr *mongoGroupRepository) findOne(ctx context.Context, filter bson.M) (GroupData, error) {
+	result := gr.collection.FindOne(ctx, filter)
+	if result.Err() != nil {
+		if errors.Is(result.Err(), mongo.ErrNoDocuments) {
+			return GroupData{}, database.ErrNotFound
+		}
+
+		return GroupData{}, errors.Join(database.ErrGeneric, result.Err())
+	}
+
+	var group GroupData
+	err := result.Decode(&group)
+	if err != nil {
+		return GroupData{}, errors.Join(database.ErrGeneric, err)
+	}
+
+	return group, nil
+}
+
+func (gr *mongoGroupRepository) GetAllGroups(ctx context.Context) ([]GroupData, error) {
+	ctx, span := gr.monitor.StartSpan(ctx, "mongoGroupRepository.GetAllGroups")
+	defer span.End()
+
+	cursor, err := gr.collection.Find(ctx, bson.D{})
+	if err != nil {
+		return []GroupData{}, errors.Join(database.ErrGeneric, err)
+	}
+
+	var results []GroupData
+	if err = cursor.All(ctx, &results); err != nil {
+		return []GroupData{}, errors.Join(database.ErrGeneric, err)
+	}
+
+	return results, nil
+}
+
+func (gr *mongoGroupRepository) GetGroupsByMember(ctx context.Context, userId string) ([]GroupData, error) {
+	ctx, span := gr.monitor.StartSpan(ctx, "mongoGroupRepository.GetGroupsByMember")
+	defer span.End()
+
+	cursor, err := gr.collection.Find(ctx, bson.M{"memberships.userId": userId})
+	if err != nil {
+		return []GroupData{}, errors.Join(database.ErrGeneric, err)
+	}
+
+	var results []GroupData
+	if err = cursor.All(ctx, &results); err != nil {
+		return []GroupData{}, errors.Join(database.ErrGeneric, err)
+	}
+
+	return results, nil
+}
+
+func (gr *mongoGroupRepository) CreateGroup(ctx context.Context, group GroupData) (GroupData, error) {
+	ctx, span := gr.monitor.StartSpan(ctx, "mongoGroupRepository.CreateGroup")
+	defer span.End()
+
+	group.NormalizedName = NormalizeGroupName(group.Name)
+	_, err := gr.collection.InsertOne(ctx, group)
+	if err != nil {
+		return GroupData{}, errors.Join(database.ErrGeneric, err)
+	}
+	return group, nil
+}
+
+func (gr *mongoGroupRepository) UpdateGroup(ctx context.Context, group GroupData) (GroupData, error) {
+	ctx, span := gr.monitor.StartSpan(ctx, "mongoGroupRepository.UpdateGroup")
+	defer span.End()
+
+	group.NormalizedName = NormalizeGroupName(group.Name)
+	filter := bson.M{"_id": group.Id}
+	update := bson.M{"$set": group}
+
+	_, err := gr.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return GroupData{}, errors.Join(database.ErrGeneric, err)
+	}
+
+	return group, nil
+}
+
+func (gr *mongoGroupRepository) DeleteGroup(ctx context.Context, id string) error {
+	ctx, span := gr.monitor.StartSpan(ctx, "mongoGroupRepository.DeleteGroup")
+	defer span.End()
+
+	result, err := gr.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return errors.Join(database.ErrGeneric, err)
+	}
+	if result.DeletedCount == 0 {
+		return database.ErrNotFound
+	}
+	return nil
+}
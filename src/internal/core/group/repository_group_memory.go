@@ -0,0 +1,138 @@
+package group
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+)
+
+// memoryGroupRepository keeps groups in insertion order, like an unsorted Mongo find. Memberships
+// are copied in and out so callers cannot change a stored group in place.
+type memoryGroupRepository struct {
+	monitor *monitor.Monitor
+	mu      sync.RWMutex
+	groups  []GroupData
+}
+
+func NewMemoryGroupRepository(mon *monitor.Monitor) GroupRepository {
+	return &memoryGroupRepository{
+		monitor: mon,
+	}
+}
+
+func (gr *memoryGroupRepository) GetGroupById(ctx context.Context, id string) (GroupData, error) {
+	ctx, span := gr.monitor.StartSpan(ctx, "memoryGroupRepository.GetGroupById")
+	defer span.End()
+
+	return gr.find(func(g GroupData) bool { return g.Id == id })
+}
+
+func (gr *memoryGroupRepository) GetGroupByName(ctx context.Context, name string) (GroupData, error) {
+	ctx, span := gr.monitor.StartSpan(ctx, "memoryGroupRepository.GetGroupByName")
+	defer span.End()
+
+	normalized := NormalizeGroupName(name)
+	return gr.find(func(g GroupData) bool { return g.NormalizedName == normalized })
+}
+
+func (gr *memoryGroupRepository) find(match func(GroupData) bool) (GroupData, error) {
+	gr.mu.RLock()
+	defer gr.mu.RUnlock()
+
+	for _, g := range gr.groups {
+		if match(g) {
+			return cloneGroupData(g), nil
+		}
+	}
+	return GroupData{}, database.ErrNotFound
+}
+
+func (gr *memoryGroupRepository) GetAllGroups(ctx context.Context) ([]GroupData, error) {
+	ctx, span := gr.monitor.StartSpan(ctx, "memoryGroupRepository.GetAllGroups")
+	defer span.End()
+
+	gr.mu.RLock()
+	defer gr.mu.RUnlock()
+
+	groups := make([]GroupData, len(gr.groups))
+	for i := range gr.groups {
+		groups[i] = cloneGroupData(gr.groups[i])
+	}
+	return groups, nil
+}
+
+func (gr *memoryGroupRepository) GetGroupsByMember(ctx context.Context, userId string) ([]GroupData, error) {
+	ctx, span := gr.monitor.StartSpan(ctx, "memoryGroupRepository.GetGroupsByMember")
+	defer span.End()
+
+	gr.mu.RLock()
+	defer gr.mu.RUnlock()
+
+	var groups []GroupData
+	for _, g := range gr.groups {
+		if slices.ContainsFunc(g.Memberships, func(m MembershipData) bool { return m.UserId == userId }) {
+			groups = append(groups, cloneGroupData(g))
+		}
+	}
+	return groups, nil
+}
+
+func (gr *memoryGroupRepository) CreateGroup(ctx context.Context, group GroupData) (GroupData, error) {
+	ctx, span := gr.monitor.StartSpan(ctx, "memoryGroupRepository.CreateGroup")
+	defer span.End()
+
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	group.NormalizedName = NormalizeGroupName(group.Name)
+	for _, g := range gr.groups {
+		if g.Id == group.Id {
+			return GroupData{}, fmt.Errorf("%w: duplicate group id %s", database.ErrGeneric, group.Id)
+		}
+		if g.NormalizedName == group.NormalizedName {
+			return GroupData{}, fmt.Errorf("%w: duplicate group name %s", database.ErrGeneric, group.Name)
+		}
+	}
+	gr.groups = append(gr.groups, cloneGroupData(group))
+	return group, nil
+}
+
+func (gr *memoryGroupRepository) UpdateGroup(ctx context.Context, group GroupData) (GroupData, error) {
+	ctx, span := gr.monitor.StartSpan(ctx, "memoryGroupRepository.UpdateGroup")
+	defer span.End()
+
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	group.NormalizedName = NormalizeGroupName(group.Name)
+	for i, g := range gr.groups {
+		if g.Id == group.Id {
+			gr.groups[i] = cloneGroupData(group)
+		}
+	}
+	return group, nil
+}
+
+func (gr *memoryGroupRepository) DeleteGroup(ctx context.Context, id string) error {
+	ctx, span := gr.monitor.StartSpan(ctx, "memoryGroupRepository.DeleteGroup")
+	defer span.End()
+
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	before := len(gr.groups)
+	gr.groups = slices.DeleteFunc(gr.groups, func(g GroupData) bool { return g.Id == id })
+	if len(gr.groups) == before {
+		return database.ErrNotFound
+	}
+	return nil
+}
+
+func cloneGroupData(group GroupData) GroupData {
+	group.Memberships = slices.Clone(group.Memberships)
+	return group
+}
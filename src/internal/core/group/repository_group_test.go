@@ -0,0 +1,141 @@
+package group_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/group"
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/internal/database/databasetest"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_logger"
+	"github.com/google/uuid"
+)
+
+var testMonitor = monitor.New(hz_logger.NewZeroLogAdapater(hz_logger.LogLevelError))
+
+func TestMemoryGroupRepository(t *testing.T) {
+	testGroupRepository(t, func(t *testing.T) group.GroupRepository {
+		return group.NewMemoryGroupRepository(testMonitor)
+	})
+}
+
+func TestMongoGroupRepository(t *testing.T) {
+	testGroupRepository(t, func(t *testing.T) group.GroupRepository {
+		return group.NewGroupRepository(databasetest.Collection(t, "group"), testMonitor)
+	})
+}
+
+func newGroupData(name string) group.GroupData {
+	// Mongo stores times at millisecond precision, so truncate to compare round trips.
+	joinedAt := time.Now().UTC().Add(-48 * time.Hour).Truncate(time.Millisecond)
+	leftAt := joinedAt.Add(24 * time.Hour)
+	return group.GroupData{
+		Id:             uuid.New().String(),
+		Name:           name,
+		NormalizedName: group.NormalizeGroupName(name),
+		CreatedAt:      joinedAt,
+		Memberships: []group.MembershipData{
+			{UserId: uuid.New().String(), Role: string(group.GroupRoleOwner), JoinedAt: joinedAt},
+			{UserId: uuid.New().String(), Role: string(group.GroupRoleMember), JoinedAt: joinedAt, LeftAt: &leftAt},
+		},
+	}
+}
+
+// testGroupRepository is the conformance suite every GroupRepository must pass.
+func testGroupRepository(t *testing.T, newRepo func(t *testing.T) group.GroupRepository) {
+	ctx := context.Background()
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		repo := newRepo(t)
+		want := newGroupData("Iron Clad")
+		if _, err := repo.CreateGroup(ctx, want); err != nil {
+			t.Fatalf("CreateGroup: %v", err)
+		}
+
+		byId, err := repo.GetGroupById(ctx, want.Id)
+		if err != nil || !reflect.DeepEqual(byId, want) {
+			t.Errorf("GetGroupById = %+v, %v; want %+v", byId, err, want)
+		}
+		byName, err := repo.GetGroupByName(ctx, "  iron   CLAD ")
+		if err != nil || byName.Id != want.Id {
+			t.Errorf("GetGroupByName of a differently spaced and cased name = %+v, %v; want %s", byName, err, want.Id)
+		}
+
+		if _, err := repo.GetGroupById(ctx, uuid.New().String()); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("GetGroupById of an unknown id: got %v, want ErrNotFound", err)
+		}
+		if _, err := repo.GetGroupByName(ctx, "Gielinor Gains"); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("GetGroupByName of an unknown name: got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("ListAndUpdate", func(t *testing.T) {
+		repo := newRepo(t)
+		g := newGroupData("Iron Clad")
+		for _, data := range []group.GroupData{g, newGroupData("Gielinor Gains")} {
+			if _, err := repo.CreateGroup(ctx, data); err != nil {
+				t.Fatalf("CreateGroup: %v", err)
+			}
+		}
+		all, err := repo.GetAllGroups(ctx)
+		if err != nil || len(all) != 2 {
+			t.Errorf("GetAllGroups returned %d groups, %v; want 2", len(all), err)
+		}
+
+		leftAt := g.Memberships[0].JoinedAt.Add(time.Hour)
+		g.Name = "Iron Clad II"
+		g.Memberships[0].LeftAt = &leftAt
+		if _, err := repo.UpdateGroup(ctx, g); err != nil {
+			t.Fatalf("UpdateGroup: %v", err)
+		}
+		g.NormalizedName = group.NormalizeGroupName(g.Name)
+		got, err := repo.GetGroupById(ctx, g.Id)
+		if err != nil || !reflect.DeepEqual(got, g) {
+			t.Errorf("GetGroupById after UpdateGroup = %+v, %v; want %+v", got, err, g)
+		}
+	})
+
+	t.Run("GetGroupsByMember", func(t *testing.T) {
+		repo := newRepo(t)
+		g := newGroupData("Iron Clad")
+		other := newGroupData("Gielinor Gains")
+		for _, data := range []group.GroupData{g, other} {
+			if _, err := repo.CreateGroup(ctx, data); err != nil {
+				t.Fatalf("CreateGroup: %v", err)
+			}
+		}
+
+		// Closed memberships count too.
+		for _, m := range g.Memberships {
+			groups, err := repo.GetGroupsByMember(ctx, m.UserId)
+			if err != nil || len(groups) != 1 || groups[0].Id != g.Id {
+				t.Errorf("GetGroupsByMember(%s) = %+v, %v; want [%s]", m.UserId, groups, err, g.Id)
+			}
+		}
+		if groups, err := repo.GetGroupsByMember(ctx, uuid.New().String()); err != nil || len(groups) != 0 {
+			t.Errorf("GetGroupsByMember of a user in no group = %+v, %v; want none", groups, err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		repo := newRepo(t)
+		g := newGroupData("Iron Clad")
+		if _, err := repo.CreateGroup(ctx, g); err != nil {
+			t.Fatalf("CreateGroup: %v", err)
+		}
+
+		if err := repo.DeleteGroup(ctx, g.Id); err != nil {
+			t.Fatalf("DeleteGroup: %v", err)
+		}
+		if _, err := repo.GetGroupById(ctx, g.Id); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("GetGroupById after DeleteGroup: got %v, want ErrNotFound", err)
+		}
+		if err := repo.DeleteGroup(ctx, g.Id); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("DeleteGroup of a deleted group: got %v, want ErrNotFound", err)
+		}
+	})
+}
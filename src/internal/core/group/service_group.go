@@ -0,0 +1,495 @@
+package group
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/delta"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/snapshot"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/user"
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+	"github.com/google/uuid"
+)
+
+var ErrGroupGeneric = errors.New("an error occurred while performing the group operation")
+var ErrGroupNotFound = errors.New("group not found")
+var ErrGroupValidation = errors.New("group is invalid")
+var ErrGroupNameTaken = errors.New("group name taken")
+
+// DefaultContributorLimit and MaxContributorLimit bound the top contributors of group gains.
+const DefaultContributorLimit = 10
+const MaxContributorLimit = 100
+
+type GroupService interface {
+	GetAllGroups(ctx context.Context) ([]Group, error)
+	GetGroupById(ctx context.Context, id string) (Group, error)
+	// GetGroupsByMember returns the groups userId has been in, with all of their memberships.
+	GetGroupsByMember(ctx context.Context, userId string) ([]Group, error)
+	CreateGroup(ctx context.Context, group Group) (Group, error)
+	UpdateGroup(ctx context.Context, id string, name string) (Group, error)
+	DeleteGroup(ctx context.Context, id string) (Group, error)
+	AddMember(ctx context.Context, id string, userId string, role api.GroupRole, joinedAt time.Time) (Group, error)
+	UpdateMemberRole(ctx context.Context, id string, userId string, role api.GroupRole) (Group, error)
+	RemoveMember(ctx context.Context, id string, userId string, leftAt time.Time) (Group, error)
+	// RemoveUserFromGroups deletes every membership of userId, open or closed, as if they had
+	// never been in a group, and returns how many it deleted.
+	RemoveUserFromGroups(ctx context.Context, userId string) (int64, error)
+	// MoveMemberships gives the memberships of sourceId to targetId when merging users, and
+	// returns how many it moved. Moving them again finds none to move.
+	MoveMemberships(ctx context.Context, sourceId string, targetId string) (int, error)
+	GetGroupGains(ctx context.Context, id string, startTime time.Time, endTime time.Time, limit int) (GroupGains, error)
+}
+
+type groupService struct {
+	monitor        *monitor.Monitor
+	validator      GroupValidator
+	repository     GroupRepository
+	userRepository user.UserRepository
+	deltaService   delta.DeltaService
+}
+
+func NewGroupService(mon *monitor.Monitor, repository GroupRepository, validator GroupValidator, userRepository user.UserRepository, deltaService delta.DeltaService) GroupService {
+	return &groupService{
+		monitor:        mon,
+		validator:      validator,
+		repository:     repository,
+		userRepository: userRepository,
+		deltaService:   deltaService,
+	}
+}
+
+func (gs *groupService) GetAllGroups(ctx context.Context) ([]Group, error) {
+	ctx, span := gs.monitor.StartSpan(ctx, "groupService.GetAllGroups")
+	defer span.End()
+
+	data, err := gs.repository.GetAllGroups(ctx)
+	if err != nil {
+		return []Group{}, errors.Join(ErrGroupGeneric, err)
+	}
+	return Group{}.ManyFromData(data), nil
+}
+
+func (gs *groupService) GetGroupById(ctx context.Context, id string) (Group, error) {
+	ctx, span := gs.monitor.StartSpan(ctx, "groupService.GetGroupById")
+	defer span.End()
+
+	data, err := gs.repository.GetGroupById(ctx, id)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return Group{}, ErrGroupNotFound
+		}
+		return Group{}, errors.Join(ErrGroupGeneric, err)
+	}
+	return Group{}.FromData(data), nil
+}
+
+func (gs *groupService) GetGroupsByMember(ctx context.Context, userId string) ([]Group, error) {
+	ctx, span := gs.monitor.StartSpan(ctx, "groupService.GetGroupsByMember")
+	defer span.End()
+
+	data, err := gs.repository.GetGroupsByMember(ctx, userId)
+	if err != nil {
+		return []Group{}, errors.Join(ErrGroupGeneric, err)
+	}
+	return Group{}.ManyFromData(data), nil
+}
+
+// CreateGroup stores a new, empty group. Members are added through AddMember.
+func (gs *groupService) CreateGroup(ctx context.Context, group Group) (Group, error) {
+	ctx, span := gs.monitor.StartSpan(ctx, "groupService.CreateGroup")
+	defer span.End()
+
+	if err := gs.validator.ValidateGroup(group); err != nil {
+		return Group{}, errors.Join(ErrGroupValidation, err)
+	}
+	if err := gs.ensureNameAvailable(ctx, "", group.Name); err != nil {
+		return Group{}, err
+	}
+
+	group.Id = uuid.New().String()
+	group.CreatedAt = time.Now()
+	group.Memberships = []Membership{}
+
+	data, err := gs.repository.CreateGroup(ctx, group.ToData())
+	if err != nil {
+		return Group{}, errors.Join(ErrGroupGeneric, err)
+	}
+	return Group{}.FromData(data), nil
+}
+
+func (gs *groupService) UpdateGroup(ctx context.Context, id string, name string) (Group, error) {
+	ctx, span := gs.monitor.StartSpan(ctx, "groupService.UpdateGroup")
+	defer span.End()
+
+	group, err := gs.GetGroupById(ctx, id)
+	if err != nil {
+		return Group{}, err
+	}
+
+	group.Name = name
+	if err := gs.validator.ValidateGroup(group); err != nil {
+		return Group{}, errors.Join(ErrGroupValidation, err)
+	}
+	if err := gs.ensureNameAvailable(ctx, id, name); err != nil {
+		return Group{}, err
+	}
+
+	return gs.update(ctx, group)
+}
+
+// DeleteGroup removes the group and returns it as it was. The members' users are untouched.
+func (gs *groupService) DeleteGroup(ctx context.Context, id string) (Group, error) {
+	ctx, span := gs.monitor.StartSpan(ctx, "groupService.DeleteGroup")
+	defer span.End()
+
+	group, err := gs.GetGroupById(ctx, id)
+	if err != nil {
+		return Group{}, err
+	}
+
+	if err := gs.repository.DeleteGroup(ctx, id); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return Group{}, ErrGroupNotFound
+		}
+		return Group{}, errors.Join(ErrGroupGeneric, err)
+	}
+	return group, nil
+}
+
+// AddMember opens a new membership of userId. A user who left can rejoin, which starts a new
+// stay rather than reopening the old one, so gains made while away never count for the group.
+func (gs *groupService) AddMember(ctx context.Context, id string, userId string, role api.GroupRole, joinedAt time.Time) (Group, error) {
+	ctx, span := gs.monitor.StartSpan(ctx, "groupService.AddMember")
+	defer span.End()
+
+	group, err := gs.GetGroupById(ctx, id)
+	if err != nil {
+		return Group{}, err
+	}
+
+	u, err := gs.userRepository.GetUserById(ctx, userId)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return Group{}, errors.Join(ErrGroupValidation, fmt.Errorf("user %s not found", userId))
+		}
+		return Group{}, errors.Join(ErrGroupGeneric, err)
+	}
+	if u.DeletedAt != nil {
+		return Group{}, errors.Join(ErrGroupValidation, fmt.Errorf("user %s is deleted", userId))
+	}
+
+	membership := Membership{
+		UserId:   userId,
+		Role:     groupRoleFromRequest(role),
+		JoinedAt: joinedAt,
+	}
+	if err := gs.validator.ValidateJoin(group, membership); err != nil {
+		return Group{}, errors.Join(ErrGroupValidation, err)
+	}
+
+	group.Memberships = append(group.Memberships, membership)
+	return gs.update(ctx, group)
+}
+
+func (gs *groupService) UpdateMemberRole(ctx context.Context, id string, userId string, role api.GroupRole) (Group, error) {
+	ctx, span := gs.monitor.StartSpan(ctx, "groupService.UpdateMemberRole")
+	defer span.End()
+
+	group, err := gs.GetGroupById(ctx, id)
+	if err != nil {
+		return Group{}, err
+	}
+
+	i := group.activeMembership(userId)
+	if i < 0 {
+		return Group{}, errors.Join(ErrGroupValidation, fmt.Errorf("user %s is not a member", userId))
+	}
+	newRole := groupRoleFromRequest(role)
+	if err := gs.validator.ValidateRole(newRole); err != nil {
+		return Group{}, errors.Join(ErrGroupValidation, err)
+	}
+
+	group.Memberships[i].Role = newRole
+	return gs.update(ctx, group)
+}
+
+// RemoveMember closes the open membership of userId at leftAt. The membership is kept, so the
+// member's gains up to leftAt still count for the group.
+func (gs *groupService) RemoveMember(ctx context.Context, id string, userId string, leftAt time.Time) (Group, error) {
+	ctx, span := gs.monitor.StartSpan(ctx, "groupService.RemoveMember")
+	defer span.End()
+
+	group, err := gs.GetGroupById(ctx, id)
+	if err != nil {
+		return Group{}, err
+	}
+
+	i := group.activeMembership(userId)
+	if i < 0 {
+		return Group{}, errors.Join(ErrGroupValidation, fmt.Errorf("user %s is not a member", userId))
+	}
+	if err := gs.validator.ValidateLeave(group.Memberships[i], leftAt); err != nil {
+		return Group{}, errors.Join(ErrGroupValidation, err)
+	}
+
+	group.Memberships[i].LeftAt = &leftAt
+	return gs.update(ctx, group)
+}
+
+func (gs *groupService) RemoveUserFromGroups(ctx context.Context, userId string) (int64, error) {
+	ctx, span := gs.monitor.StartSpan(ctx, "groupService.RemoveUserFromGroups")
+	defer span.End()
+
+	groups, err := gs.GetGroupsByMember(ctx, userId)
+	if err != nil {
+		return 0, err
+	}
+
+	var removed int64
+	for _, group := range groups {
+		removed += int64(group.removeMember(userId))
+		if _, err := gs.update(ctx, group); err != nil {
+			return 0, err
+		}
+	}
+	return removed, nil
+}
+
+func (gs *groupService) MoveMemberships(ctx context.Context, sourceId string, targetId string) (int, error) {
+	ctx, span := gs.monitor.StartSpan(ctx, "groupService.MoveMemberships")
+	defer span.End()
+
+	groups, err := gs.GetGroupsByMember(ctx, sourceId)
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, group := range groups {
+		moved += group.moveMember(sourceId, targetId)
+		if _, err := gs.update(ctx, group); err != nil {
+			return 0, err
+		}
+	}
+	return moved, nil
+}
+
+// GetGroupGains sums the deltas of every member over the part of [startTime, endTime] they were
+// in the group. A member's experience is their overall experience gain.
+func (gs *groupService) GetGroupGains(ctx context.Context, id string, startTime time.Time, endTime time.Time, limit int) (GroupGains, error) {
+	ctx, span := gs.monitor.StartSpan(ctx, "groupService.GetGroupGains")
+	defer span.End()
+
+	if endTime.After(time.Now()) {
+		endTime = time.Now()
+	}
+	if !startTime.Before(endTime) {
+		return GroupGains{}, errors.Join(ErrGroupValidation, errors.New("startTime must be before endTime and not in the future"))
+	}
+	if endTime.Sub(startTime) > delta.MaxIntervalDuration {
+		return GroupGains{}, errors.Join(ErrGroupValidation, errors.New("maximum time interval exceeded"))
+	}
+	if limit == 0 {
+		limit = DefaultContributorLimit
+	}
+	if limit < 0 || limit > MaxContributorLimit {
+		return GroupGains{}, errors.Join(ErrGroupValidation, fmt.Errorf("limit must be between 1 and %d", MaxContributorLimit))
+	}
+
+	group, err := gs.GetGroupById(ctx, id)
+	if err != nil {
+		return GroupGains{}, err
+	}
+
+	members := group.periodsByUser(startTime, endTime)
+	gains := newGainsAccumulator()
+	var contributors []Contributor
+	for userId, periods := range members {
+		deltas, err := gs.memberDeltas(ctx, userId, periods)
+		if err != nil {
+			return GroupGains{}, errors.Join(ErrGroupGeneric, err)
+		}
+
+		experience := 0
+		for _, d := range deltas {
+			if !slices.ContainsFunc(periods, func(p period) bool { return p.contains(d.Timestamp) }) {
+				continue
+			}
+			experience += gains.add(d)
+		}
+		if experience > 0 {
+			contributors = append(contributors, Contributor{UserId: userId, ExperienceGain: experience})
+		}
+	}
+
+	slices.SortFunc(contributors, func(a, b Contributor) int {
+		return cmp.Or(cmp.Compare(b.ExperienceGain, a.ExperienceGain), cmp.Compare(a.UserId, b.UserId))
+	})
+	total := 0
+	for _, c := range contributors {
+		total += c.ExperienceGain
+	}
+	if len(contributors) > limit {
+		contributors = contributors[:limit]
+	}
+	for i := range contributors {
+		u, err := gs.userRepository.GetUserById(ctx, contributors[i].UserId)
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			return GroupGains{}, errors.Join(ErrGroupGeneric, err)
+		}
+		contributors[i].RunescapeName = u.RunescapeName
+	}
+
+	return GroupGains{
+		GroupId:             group.Id,
+		StartTime:           startTime,
+		EndTime:             endTime,
+		TotalExperienceGain: total,
+		Skills:              gains.skillTotals(),
+		Bosses:              gains.bossTotals(),
+		Activities:          gains.activityTotals(),
+		TopContributors:     contributors,
+		MemberCount:         len(members),
+	}, nil
+}
+
+// memberDeltas returns the deltas of userId over periods. Cached deltas are daily aggregates
+// stamped with the time of the day's last delta, so on a day a period starts or ends they would
+// count the whole day or none of it. Those days are read from the stored deltas instead.
+func (gs *groupService) memberDeltas(ctx context.Context, userId string, periods []period) ([]delta.HiscoreDelta, error) {
+	partialDays := make(map[time.Time]bool)
+	for _, p := range periods {
+		partialDays[startOfDay(p.start)] = true
+		partialDays[startOfDay(p.end)] = true
+	}
+
+	response, err := gs.deltaService.GetDeltasInRange(ctx, userId, periods[0].start, periods[len(periods)-1].end)
+	if err != nil {
+		return nil, err
+	}
+	var deltas []delta.HiscoreDelta
+	for _, d := range response.Deltas {
+		if !partialDays[startOfDay(d.Timestamp)] {
+			deltas = append(deltas, d)
+		}
+	}
+
+	for day := range partialDays {
+		stored, err := gs.deltaService.GetStoredDeltasInRange(ctx, userId, day, day.Add(24*time.Hour-time.Nanosecond))
+		if err != nil {
+			return nil, err
+		}
+		deltas = append(deltas, stored...)
+	}
+	return deltas, nil
+}
+
+// startOfDay returns the start of the UTC day of t, the days the delta cache aggregates by.
+func startOfDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+func (gs *groupService) update(ctx context.Context, group Group) (Group, error) {
+	data, err := gs.repository.UpdateGroup(ctx, group.ToData())
+	if err != nil {
+		return Group{}, errors.Join(ErrGroupGeneric, err)
+	}
+	return Group{}.FromData(data), nil
+}
+
+// ensureNameAvailable fails when a group other than id already has name.
+func (gs *groupService) ensureNameAvailable(ctx context.Context, id string, name string) error {
+	existing, err := gs.repository.GetGroupByName(ctx, name)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil
+		}
+		return errors.Join(ErrGroupGeneric, err)
+	}
+	if existing.Id != id {
+		return ErrGroupNameTaken
+	}
+	return nil
+}
+
+// gainsAccumulator sums deltas per activity type.
+type gainsAccumulator struct {
+	skills     map[snapshot.ActivityType]*delta.SkillDelta
+	bosses     map[snapshot.ActivityType]*delta.BossDelta
+	activities map[snapshot.ActivityType]*delta.ActivityDelta
+}
+
+func newGainsAccumulator() *gainsAccumulator {
+	return &gainsAccumulator{
+		skills:     make(map[snapshot.ActivityType]*delta.SkillDelta),
+		bosses:     make(map[snapshot.ActivityType]*delta.BossDelta),
+		activities: make(map[snapshot.ActivityType]*delta.ActivityDelta),
+	}
+}
+
+// add sums d into the totals and returns its overall experience gain.
+func (ga *gainsAccumulator) add(d delta.HiscoreDelta) int {
+	overall := 0
+	for _, s := range d.Skills {
+		if _, ok := ga.skills[s.ActivityType]; !ok {
+			ga.skills[s.ActivityType] = &delta.SkillDelta{ActivityType: s.ActivityType, Name: s.Name}
+		}
+		ga.skills[s.ActivityType].ExperienceGain += s.ExperienceGain
+		ga.skills[s.ActivityType].LevelGain += s.LevelGain
+		if s.ActivityType == snapshot.ActivityTypeOverall {
+			overall += s.ExperienceGain
+		}
+	}
+	for _, b := range d.Bosses {
+		if _, ok := ga.bosses[b.ActivityType]; !ok {
+			ga.bosses[b.ActivityType] = &delta.BossDelta{ActivityType: b.ActivityType, Name: b.Name}
+		}
+		ga.bosses[b.ActivityType].KillCountGain += b.KillCountGain
+	}
+	for _, a := range d.Activities {
+		if _, ok := ga.activities[a.ActivityType]; !ok {
+			ga.activities[a.ActivityType] = &delta.ActivityDelta{ActivityType: a.ActivityType, Name: a.Name}
+		}
+		ga.activities[a.ActivityType].ScoreGain += a.ScoreGain
+	}
+	return overall
+}
+
+func (ga *gainsAccumulator) skillTotals() []delta.SkillDelta {
+	skills := make([]delta.SkillDelta, 0, len(ga.skills))
+	for _, s := range ga.skills {
+		skills = append(skills, *s)
+	}
+	slices.SortFunc(skills, func(a, b delta.SkillDelta) int {
+		return cmp.Or(cmp.Compare(b.ExperienceGain, a.ExperienceGain), cmp.Compare(a.ActivityType, b.ActivityType))
+	})
+	return skills
+}
+
+func (ga *gainsAccumulator) bossTotals() []delta.BossDelta {
+	bosses := make([]delta.BossDelta, 0, len(ga.bosses))
+	for _, b := range ga.bosses {
+		bosses = append(bosses, *b)
+	}
+	slices.SortFunc(bosses, func(a, b delta.BossDelta) int {
+		return cmp.Or(cmp.Compare(b.KillCountGain, a.KillCountGain), cmp.Compare(a.ActivityType, b.ActivityType))
+	})
+	return bosses
+}
+
+func (ga *gainsAccumulator) activityTotals() []delta.ActivityDelta {
+	activities := make([]delta.ActivityDelta, 0, len(ga.activities))
+	for _, a := range ga.activities {
+		activities = append(activities, *a)
+	}
+	slices.SortFunc(activities, func(a, b delta.ActivityDelta) int {
+		return cmp.Or(cmp.Compare(b.ScoreGain, a.ScoreGain), cmp.Compare(a.ActivityType, b.ActivityType))
+	})
+	return activities
+}
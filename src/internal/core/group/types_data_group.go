@@ -0,0 +1,18 @@
+package group
+
+import "time"
+
+type GroupData struct {
+	Id             string           `bson:"_id"`
+	Name           string           `bson:"name"`
+	NormalizedName string           `bson:"normalizedName"`
+	CreatedAt      time.Time        `bson:"createdAt"`
+	Memberships    []MembershipData `bson:"memberships"`
+}
+
+type MembershipData struct {
+	UserId   string     `bson:"userId"`
+	Role     string     `bson:"role"`
+	JoinedAt time.Time  `bson:"joinedAt"`
+	LeftAt   *time.Time `bson:"leftAt,omitempty"`
+}
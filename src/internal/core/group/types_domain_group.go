@@ -0,0 +1,330 @@
+package group
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/delta"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+)
+
+type GroupRole string
+
+const (
+	GroupRoleOwner  GroupRole = "OWNER"
+	GroupRoleAdmin  GroupRole = "ADMIN"
+	GroupRoleMember GroupRole = "MEMBER"
+)
+
+func GroupRoleFromValue(value string) GroupRole {
+	if value == string(GroupRoleOwner) {
+		return GroupRoleOwner
+	}
+
+	if value == string(GroupRoleAdmin) {
+		return GroupRoleAdmin
+	}
+
+	return GroupRoleMember
+}
+
+var AllGroupRoles = []GroupRole{
+	GroupRoleOwner,
+	GroupRoleAdmin,
+	GroupRoleMember,
+}
+
+// groupRoleFromRequest keeps an unknown role as given, so validation rejects it instead of the
+// user silently joining as a MEMBER. Leaving it out still means MEMBER.
+func groupRoleFromRequest(value api.GroupRole) GroupRole {
+	if value == "" {
+		return GroupRoleMember
+	}
+	return GroupRole(value)
+}
+
+// NormalizeGroupName returns the form group names are compared in: case and runs of whitespace
+// do not make two names different.
+func NormalizeGroupName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+type Group struct {
+	Id          string
+	Name        string
+	CreatedAt   time.Time
+	Memberships []Membership
+}
+
+// Membership is one stay of a user in a group. Only a user's latest membership can be open.
+type Membership struct {
+	UserId   string
+	Role     GroupRole
+	JoinedAt time.Time
+	LeftAt   *time.Time
+}
+
+func (m Membership) isActive() bool {
+	return m.LeftAt == nil
+}
+
+// overlap returns the part of [start, end) the membership covers, and false when it covers none.
+func (m Membership) overlap(start time.Time, end time.Time) (time.Time, time.Time, bool) {
+	if m.JoinedAt.After(start) {
+		start = m.JoinedAt
+	}
+	if m.LeftAt != nil && m.LeftAt.Before(end) {
+		end = *m.LeftAt
+	}
+	return start, end, start.Before(end)
+}
+
+// activeMembership returns the index of the open membership of userId, or -1 when they are not
+// currently a member.
+func (g Group) activeMembership(userId string) int {
+	for i := range g.Memberships {
+		if g.Memberships[i].UserId == userId && g.Memberships[i].isActive() {
+			return i
+		}
+	}
+	return -1
+}
+
+// lastLeftAt returns when userId last left the group, and false when they never did.
+func (g Group) lastLeftAt(userId string) (time.Time, bool) {
+	var last time.Time
+	found := false
+	for _, m := range g.Memberships {
+		if m.UserId == userId && m.LeftAt != nil && (!found || m.LeftAt.After(last)) {
+			last = *m.LeftAt
+			found = true
+		}
+	}
+	return last, found
+}
+
+// period is the part of a query interval a membership covers. A delta belongs to the period
+// when it ends in (start, end]: one ending at the join time holds gains made before joining.
+type period struct {
+	start time.Time
+	end   time.Time
+}
+
+func (p period) contains(t time.Time) bool {
+	return t.After(p.start) && !t.After(p.end)
+}
+
+// periodsByUser returns, per member, the periods of [start, end] they were in the group, oldest
+// first. Members with no such period are left out.
+func (g Group) periodsByUser(start time.Time, end time.Time) map[string][]period {
+	periods := make(map[string][]period)
+	for _, m := range g.Memberships {
+		if s, e, ok := m.overlap(start, end); ok {
+			periods[m.UserId] = append(periods[m.UserId], period{s, e})
+		}
+	}
+	for _, p := range periods {
+		slices.SortFunc(p, func(a, b period) int { return a.start.Compare(b.start) })
+	}
+	return periods
+}
+
+// removeMember drops every membership of userId and returns how many it dropped.
+func (g *Group) removeMember(userId string) int {
+	before := len(g.Memberships)
+	g.Memberships = slices.DeleteFunc(g.Memberships, func(m Membership) bool { return m.UserId == userId })
+	return before - len(g.Memberships)
+}
+
+// moveMember gives the memberships of sourceId to targetId and returns how many it moved. Stays
+// of the two that overlap become one stay with the higher role, so the stays of targetId still
+// do not overlap.
+func (g *Group) moveMember(sourceId string, targetId string) int {
+	var stays, others []Membership
+	moved := 0
+	for _, m := range g.Memberships {
+		switch m.UserId {
+		case sourceId:
+			m.UserId = targetId
+			stays = append(stays, m)
+			moved++
+		case targetId:
+			stays = append(stays, m)
+		default:
+			others = append(others, m)
+		}
+	}
+	if moved == 0 {
+		return 0
+	}
+
+	slices.SortStableFunc(stays, func(a, b Membership) int { return a.JoinedAt.Compare(b.JoinedAt) })
+	var joined []Membership
+	for _, m := range stays {
+		last := len(joined) - 1
+		if last < 0 || (joined[last].LeftAt != nil && !m.JoinedAt.Before(*joined[last].LeftAt)) {
+			joined = append(joined, m)
+			continue
+		}
+		if joined[last].LeftAt != nil && (m.LeftAt == nil || m.LeftAt.After(*joined[last].LeftAt)) {
+			joined[last].LeftAt = m.LeftAt
+		}
+		if roleRank(m.Role) < roleRank(joined[last].Role) {
+			joined[last].Role = m.Role
+		}
+	}
+	g.Memberships = append(others, joined...)
+	return moved
+}
+
+// roleRank orders roles by AllGroupRoles, most privileged first.
+func roleRank(role GroupRole) int {
+	if i := slices.Index(AllGroupRoles, role); i >= 0 {
+		return i
+	}
+	return len(AllGroupRoles)
+}
+
+// ToAPI converts the domain Group to an API Group
+func (g Group) ToAPI() api.Group {
+	memberships := make([]api.GroupMembership, len(g.Memberships))
+	for i, m := range g.Memberships {
+		memberships[i] = api.GroupMembership{
+			UserId:   m.UserId,
+			Role:     api.GroupRole(m.Role),
+			JoinedAt: m.JoinedAt,
+			LeftAt:   m.LeftAt,
+		}
+	}
+	return api.Group{
+		Id:          g.Id,
+		Name:        g.Name,
+		CreatedAt:   g.CreatedAt,
+		Memberships: memberships,
+	}
+}
+
+// ToData converts the domain Group to a data layer GroupData
+func (g Group) ToData() GroupData {
+	memberships := make([]MembershipData, len(g.Memberships))
+	for i, m := range g.Memberships {
+		memberships[i] = MembershipData{
+			UserId:   m.UserId,
+			Role:     string(m.Role),
+			JoinedAt: m.JoinedAt,
+			LeftAt:   m.LeftAt,
+		}
+	}
+	return GroupData{
+		Id:          g.Id,
+		Name:        g.Name,
+		CreatedAt:   g.CreatedAt,
+		Memberships: memberships,
+	}
+}
+
+// FromData creates a domain Group from data layer GroupData (call as Group{}.FromData(...))
+func (Group) FromData(data GroupData) Group {
+	memberships := make([]Membership, len(data.Memberships))
+	for i, m := range data.Memberships {
+		memberships[i] = Membership{
+			UserId:   m.UserId,
+			Role:     GroupRoleFromValue(m.Role),
+			JoinedAt: m.JoinedAt,
+			LeftAt:   m.LeftAt,
+		}
+	}
+	return Group{
+		Id:          data.Id,
+		Name:        data.Name,
+		CreatedAt:   data.CreatedAt,
+		Memberships: memberships,
+	}
+}
+
+// ManyToAPI converts a slice of domain Groups to API Groups (call as Group{}.ManyToAPI(...))
+func (Group) ManyToAPI(groups []Group) []api.Group {
+	apiGroups := make([]api.Group, len(groups))
+	for i := range groups {
+		apiGroups[i] = groups[i].ToAPI()
+	}
+	return apiGroups
+}
+
+// ManyFromData converts a slice of GroupData to domain Groups (call as Group{}.ManyFromData(...))
+func (Group) ManyFromData(data []GroupData) []Group {
+	groups := make([]Group, len(data))
+	for i := range data {
+		groups[i] = Group{}.FromData(data[i])
+	}
+	return groups
+}
+
+// GroupGains sums the deltas members made while they were in a group. Skills, Bosses and
+// Activities hold totals, largest first.
+type GroupGains struct {
+	GroupId             string
+	StartTime           time.Time
+	EndTime             time.Time
+	TotalExperienceGain int
+	Skills              []delta.SkillDelta
+	Bosses              []delta.BossDelta
+	Activities          []delta.ActivityDelta
+	TopContributors     []Contributor
+	MemberCount         int
+}
+
+type Contributor struct {
+	UserId         string
+	RunescapeName  string
+	ExperienceGain int
+}
+
+// ToAPI converts the domain GroupGains to an API GetGroupGainsResponse
+func (gg GroupGains) ToAPI() api.GetGroupGainsResponse {
+	skills := make([]api.SkillDeltaSummary, len(gg.Skills))
+	for i, s := range gg.Skills {
+		skills[i] = api.SkillDeltaSummary{
+			ActivityType:        s.ActivityType.ToAPI(),
+			Name:                s.Name,
+			TotalExperienceGain: s.ExperienceGain,
+			TotalLevelGain:      s.LevelGain,
+		}
+	}
+	bosses := make([]api.BossDeltaSummary, len(gg.Bosses))
+	for i, b := range gg.Bosses {
+		bosses[i] = api.BossDeltaSummary{
+			ActivityType:       b.ActivityType.ToAPI(),
+			Name:               b.Name,
+			TotalKillCountGain: b.KillCountGain,
+		}
+	}
+	activities := make([]api.ActivityDeltaSummary, len(gg.Activities))
+	for i, a := range gg.Activities {
+		activities[i] = api.ActivityDeltaSummary{
+			ActivityType:   a.ActivityType.ToAPI(),
+			Name:           a.Name,
+			TotalScoreGain: a.ScoreGain,
+		}
+	}
+	contributors := make([]api.GroupContributor, len(gg.TopContributors))
+	for i, c := range gg.TopContributors {
+		contributors[i] = api.GroupContributor{
+			UserId:         c.UserId,
+			RunescapeName:  c.RunescapeName,
+			ExperienceGain: c.ExperienceGain,
+		}
+	}
+	return api.GetGroupGainsResponse{
+		GroupId:             gg.GroupId,
+		StartTime:           gg.StartTime,
+		EndTime:             gg.EndTime,
+		TotalExperienceGain: gg.TotalExperienceGain,
+		Skills:              skills,
+		Bosses:              bosses,
+		Activities:          activities,
+		TopContributors:     contributors,
+		MemberCount:         gg.MemberCount,
+	}
+}
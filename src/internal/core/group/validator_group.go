@@ -0,0 +1,75 @@
+package group
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// MaxGroupNameLength bounds group names so they fit on a dashboard.
+const MaxGroupNameLength = 64
+
+type GroupValidator interface {
+	ValidateGroup(group Group) error
+	ValidateName(name string) error
+	ValidateRole(role GroupRole) error
+	ValidateJoin(group Group, membership Membership) error
+	ValidateLeave(membership Membership, leftAt time.Time) error
+}
+
+type groupValidator struct {
+}
+
+func NewGroupValidator() GroupValidator {
+	return &groupValidator{}
+}
+
+func (gv *groupValidator) ValidateGroup(group Group) error {
+	return gv.ValidateName(group.Name)
+}
+
+func (gv *groupValidator) ValidateName(name string) error {
+	if NormalizeGroupName(name) == "" {
+		return errors.New("name is required")
+	}
+	if len([]rune(name)) > MaxGroupNameLength {
+		return fmt.Errorf("name must be at most %d characters", MaxGroupNameLength)
+	}
+	return nil
+}
+
+func (gv *groupValidator) ValidateRole(role GroupRole) error {
+	if !slices.Contains(AllGroupRoles, role) {
+		return fmt.Errorf("role must be one of %v", AllGroupRoles)
+	}
+	return nil
+}
+
+// ValidateJoin checks a new membership against the group. Stays of a user must not overlap, so a
+// user can only rejoin once they have left, and not before the time they left.
+func (gv *groupValidator) ValidateJoin(group Group, membership Membership) error {
+	if err := gv.ValidateRole(membership.Role); err != nil {
+		return err
+	}
+	if membership.JoinedAt.After(time.Now()) {
+		return errors.New("joinedAt must not be in the future")
+	}
+	if group.activeMembership(membership.UserId) >= 0 {
+		return fmt.Errorf("user %s is already a member", membership.UserId)
+	}
+	if leftAt, ok := group.lastLeftAt(membership.UserId); ok && membership.JoinedAt.Before(leftAt) {
+		return fmt.Errorf("joinedAt must not be before the user last left, at %s", leftAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func (gv *groupValidator) ValidateLeave(membership Membership, leftAt time.Time) error {
+	if leftAt.After(time.Now()) {
+		return errors.New("leftAt must not be in the future")
+	}
+	if leftAt.Before(membership.JoinedAt) {
+		return errors.New("leftAt must not be before the member joined")
+	}
+	return nil
+}
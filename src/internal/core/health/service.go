@@ -2,84 +2,107 @@ package health
 
 import (
 	"context"
+	"fmt"
 	"time"
 
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/breaker"
 	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/version"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.opentelemetry.io/otel"
 )
 
 
-// DependencyStatus represents the health status of a single dependency
-type DependencyStatus struct {
-	Name    string `json:"name"`
-	Status  string `json:"status"` // "healthy", "unhealthy", "degraded"
-	Latency string `json:"latency,omitempty"`
-	Error   string `json:"error,omitempty"`
-}
-
-// HealthResponse is the complete health check response
-type HealthResponse struct {
-	Status       string             `json:"status"` // "healthy", "unhealthy", "degraded"
-	Environment  string             `json:"environment"`
-	Commit       string             `json:"commit"`
-	BuildTime    string             `json:"buildTime"`
-	Timestamp    string             `json:"timestamp"`
-	Dependencies []DependencyStatus `json:"dependencies"`
-}
+// DependencyCheck reports the status of a non-critical dependency. An unhealthy one degrades the
+// service without failing the health check, since the API still serves everything else.
+type DependencyCheck func(ctx context.Context) api.DependencyStatus
 
 // Service performs health checks on application dependencies
 type Service struct {
 	mongoClient *mongo.Client
 	dbName      string
 	environment string
+	checks      []DependencyCheck
 }
 
-// NewService creates a new health service
-func NewService(mongoClient *mongo.Client, dbName string, environment string) *Service {
+// NewService creates a new health service. A nil mongoClient means the API runs without a
+// database, so no database dependency is checked.
+func NewService(mongoClient *mongo.Client, dbName string, environment string, checks ...DependencyCheck) *Service {
 	return &Service{
 		mongoClient: mongoClient,
 		dbName:      dbName,
 		environment: environment,
+		checks:      checks,
+	}
+}
+
+// BreakerCheck reports a dependency guarded by a circuit breaker: unhealthy while the breaker is
+// open, degraded while a probe call decides whether it closes.
+func BreakerCheck(name string, b *breaker.Breaker) DependencyCheck {
+	return func(ctx context.Context) api.DependencyStatus {
+		status := api.DependencyStatus{
+			Name:   name,
+			Status: api.HealthStatusHealthy,
+		}
+		state, openedAt := b.State()
+		switch state {
+		case breaker.StateOpen:
+			status.Status = api.HealthStatusUnhealthy
+			status.Error = fmt.Sprintf("circuit breaker open since %s", openedAt.UTC().Format(time.RFC3339))
+		case breaker.StateHalfOpen:
+			status.Status = api.HealthStatusDegraded
+			status.Error = "circuit breaker half-open"
+		}
+		return status
 	}
 }
 
 // Check performs a deep health check of all dependencies
 // Returns the health response and whether the service is healthy (for HTTP status code)
-func (s *Service) Check(ctx context.Context) (HealthResponse, bool) {
+func (s *Service) Check(ctx context.Context) (api.HealthResponse, bool) {
 	ctx, span := otel.Tracer("hazelmere").Start(ctx, "Service.Check")
 	defer span.End()
 
 	info := version.Get()
 
-	response := HealthResponse{
-		Status:       "healthy",
+	response := api.HealthResponse{
+		Status:       api.HealthStatusHealthy,
 		Environment:  s.environment,
 		Commit:       info.Commit,
 		BuildTime:    info.BuildTime,
 		Timestamp:    time.Now().UTC().Format(time.RFC3339),
-		Dependencies: make([]DependencyStatus, 0),
+		Dependencies: make([]api.DependencyStatus, 0),
 	}
 
-	// Check MongoDB - this is critical
-	mongoStatus := s.checkMongo(ctx)
-	response.Dependencies = append(response.Dependencies, mongoStatus)
-
-	// If MongoDB is unhealthy, the whole service is unhealthy
 	isHealthy := true
-	if mongoStatus.Status == "unhealthy" {
-		response.Status = "unhealthy"
-		isHealthy = false
+	if s.mongoClient != nil {
+		// Check MongoDB - this is critical
+		mongoStatus := s.checkMongo(ctx)
+		response.Dependencies = append(response.Dependencies, mongoStatus)
+
+		// If MongoDB is unhealthy, the whole service is unhealthy
+		if mongoStatus.Status == api.HealthStatusUnhealthy {
+			response.Status = api.HealthStatusUnhealthy
+			isHealthy = false
+		}
+	}
+
+	for _, check := range s.checks {
+		dependencyStatus := check(ctx)
+		response.Dependencies = append(response.Dependencies, dependencyStatus)
+		if dependencyStatus.Status != api.HealthStatusHealthy && response.Status == api.HealthStatusHealthy {
+			response.Status = api.HealthStatusDegraded
+		}
 	}
 
 	return response, isHealthy
 }
 
-func (s *Service) checkMongo(ctx context.Context) DependencyStatus {
-	status := DependencyStatus{
+func (s *Service) checkMongo(ctx context.Context) api.DependencyStatus {
+	status := api.DependencyStatus{
 		Name:   "mongodb",
-		Status: "healthy",
+		Status: api.HealthStatusHealthy,
 	}
 
 	// Create a context with timeout for the ping
@@ -93,14 +116,14 @@ func (s *Service) checkMongo(ctx context.Context) DependencyStatus {
 	status.Latency = latency.Round(time.Millisecond).String()
 
 	if err != nil {
-		status.Status = "unhealthy"
+		status.Status = api.HealthStatusUnhealthy
 		status.Error = err.Error()
 		return status
 	}
 
 	// Check if latency is too high (degraded)
 	if latency > 1*time.Second {
-		status.Status = "degraded"
+		status.Status = api.HealthStatusDegraded
 	}
 
 	return status
@@ -0,0 +1,254 @@
+package hiscore
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/audit"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/delta"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/group"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/snapshot"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/user"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/worker"
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+)
+
+// UserLifecycle covers the operations on everything held about a user, for privacy requests.
+// Soft deletes only touch the user record, so they are left to user.UserService.
+type UserLifecycle interface {
+	// HardDeleteUser removes a user with their snapshots, deltas, worker jobs and group
+	// memberships, and the tombstones of the users merged into them with theirs. Audit records are kept, as the log of
+	// who changed what. The user record goes last, so a delete that stopped part way is finished
+	// by running it again. Users in the middle of a merge cannot be deleted until it is finished.
+	HardDeleteUser(ctx context.Context, id string) (DeletionReport, error)
+	// ExportUser gathers the user record, the tombstones of the users merged into them, and the
+	// snapshots, deltas, worker jobs, group memberships and audit records of all of them.
+	ExportUser(ctx context.Context, id string) (UserExport, error)
+}
+
+type userLifecycle struct {
+	*hiscoreOrchestrator
+	userService  user.UserService
+	auditService audit.AuditService
+	jobService   worker.JobService
+	groupService group.GroupService
+}
+
+func NewUserLifecycle(
+	mon *monitor.Monitor,
+	userService user.UserService,
+	snapshotService snapshot.SnapshotService,
+	deltaService delta.DeltaService,
+	auditService audit.AuditService,
+	jobService worker.JobService,
+	groupService group.GroupService,
+	txManager *database.TransactionManager,
+) UserLifecycle {
+	return &userLifecycle{
+		hiscoreOrchestrator: &hiscoreOrchestrator{
+			monitor:         mon,
+			snapshotService: snapshotService,
+			deltaService:    deltaService,
+			txManager:       txManager,
+		},
+		userService:  userService,
+		auditService: auditService,
+		jobService:   jobService,
+		groupService: groupService,
+	}
+}
+
+func (l *userLifecycle) HardDeleteUser(ctx context.Context, id string) (DeletionReport, error) {
+	ctx, span := l.monitor.StartSpan(ctx, "userLifecycle.HardDeleteUser")
+	defer span.End()
+
+	u, err := l.userService.GetUserById(ctx, id)
+	if err != nil {
+		return DeletionReport{}, err
+	}
+	// A merge resumed after the delete would not find the user, leaving the other user locked.
+	if err := user.EnsureNotMerging(u); err != nil {
+		return DeletionReport{}, err
+	}
+	tombstones, err := l.getMergedUsers(ctx, id)
+	if err != nil {
+		return DeletionReport{}, err
+	}
+
+	report := DeletionReport{UserId: id}
+	err = l.txManager.WithTransaction(ctx, func(txCtx context.Context) error {
+		for _, tombstone := range tombstones {
+			if err := l.deleteUserAndData(txCtx, tombstone.Id, &report); err != nil {
+				return err
+			}
+			report.MergedUsersDeleted++
+		}
+		return l.deleteUserAndData(txCtx, id, &report)
+	})
+	if err != nil {
+		return DeletionReport{}, err
+	}
+
+	l.monitor.Logger().InfoArgs(ctx, "Deleted user %s with %d merged users, %d snapshots, %d deltas, %d jobs and %d memberships",
+		id, report.MergedUsersDeleted, report.SnapshotsDeleted, report.DeltasDeleted, report.JobsDeleted, report.MembershipsDeleted)
+	return report, nil
+}
+
+// deleteUserAndData deletes the data of a user before the user record, adding what it removed
+// to report.
+func (l *userLifecycle) deleteUserAndData(ctx context.Context, id string, report *DeletionReport) error {
+	snapshots, err := l.snapshotService.DeleteSnapshotsForUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	report.SnapshotsDeleted += snapshots
+
+	// Also drops the user from the delta cache.
+	deltas, err := l.deltaService.DeleteDeltasForUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	report.DeltasDeleted += deltas
+
+	jobs, err := l.jobService.DeleteJobsForUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	report.JobsDeleted += jobs
+
+	memberships, err := l.groupService.RemoveUserFromGroups(ctx, id)
+	if err != nil {
+		return err
+	}
+	report.MembershipsDeleted += memberships
+
+	return l.userService.DeleteUser(ctx, id)
+}
+
+// getMergedUsers returns the tombstones of the users merged into id, and of those merged into
+// them in turn. Each comes before the tombstone it was merged into, so deleting them in order
+// never leaves one that cannot be reached from id.
+func (l *userLifecycle) getMergedUsers(ctx context.Context, id string) ([]user.User, error) {
+	var tombstones []user.User
+	pending := []string{id}
+	seen := map[string]bool{id: true}
+	for len(pending) > 0 {
+		merged, err := l.userService.GetUsersMergedInto(ctx, pending[0])
+		if err != nil {
+			return nil, err
+		}
+		pending = pending[1:]
+		for _, m := range merged {
+			if seen[m.Id] {
+				continue
+			}
+			seen[m.Id] = true
+			tombstones = append(tombstones, m)
+			pending = append(pending, m.Id)
+		}
+	}
+	slices.Reverse(tombstones)
+	return tombstones, nil
+}
+
+// getGroups returns the groups any of userIds were in, with only the memberships of userIds.
+func (l *userLifecycle) getGroups(ctx context.Context, userIds []string) ([]group.Group, error) {
+	var groups []group.Group
+	seen := make(map[string]bool)
+	for _, userId := range userIds {
+		found, err := l.groupService.GetGroupsByMember(ctx, userId)
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range found {
+			if seen[g.Id] {
+				continue
+			}
+			seen[g.Id] = true
+			g.Memberships = slices.DeleteFunc(g.Memberships, func(m group.Membership) bool {
+				return !slices.Contains(userIds, m.UserId)
+			})
+			groups = append(groups, g)
+		}
+	}
+	return groups, nil
+}
+
+func (l *userLifecycle) ExportUser(ctx context.Context, id string) (UserExport, error) {
+	ctx, span := l.monitor.StartSpan(ctx, "userLifecycle.ExportUser")
+	defer span.End()
+
+	u, err := l.userService.GetUserById(ctx, id)
+	if err != nil {
+		return UserExport{}, err
+	}
+	tombstones, err := l.getMergedUsers(ctx, id)
+	if err != nil {
+		return UserExport{}, err
+	}
+
+	export := UserExport{
+		ExportedAt:  time.Now(),
+		User:        u,
+		MergedUsers: tombstones,
+	}
+	userIds := []string{id}
+	for _, tombstone := range tombstones {
+		userIds = append(userIds, tombstone.Id)
+	}
+
+	var snapshotIds, jobIds []string
+	for _, userId := range userIds {
+		snapshots, err := l.snapshotService.GetAllSnapshotsForUser(ctx, userId)
+		if err != nil {
+			return UserExport{}, err
+		}
+		deltas, err := l.deltaService.GetAllDeltasForUser(ctx, userId)
+		if err != nil {
+			return UserExport{}, err
+		}
+		jobs, err := l.jobService.GetJobsForUser(ctx, userId)
+		if err != nil {
+			return UserExport{}, err
+		}
+
+		export.Snapshots = append(export.Snapshots, snapshots...)
+		export.Deltas = append(export.Deltas, deltas...)
+		export.Jobs = append(export.Jobs, jobs...)
+		for _, s := range snapshots {
+			snapshotIds = append(snapshotIds, s.Id)
+		}
+		for _, j := range jobs {
+			jobIds = append(jobIds, j.Id)
+		}
+	}
+
+	groups, err := l.getGroups(ctx, userIds)
+	if err != nil {
+		return UserExport{}, err
+	}
+	export.Groups = groups
+
+	entities := []struct {
+		entityType audit.EntityType
+		ids        []string
+	}{
+		{audit.EntityTypeUser, userIds},
+		{audit.EntityTypeSnapshot, snapshotIds},
+		{audit.EntityTypeJob, jobIds},
+	}
+	for _, entity := range entities {
+		records, err := l.auditService.GetRecordsForEntities(ctx, entity.entityType, entity.ids)
+		if err != nil {
+			return UserExport{}, err
+		}
+		export.AuditRecords = append(export.AuditRecords, records...)
+	}
+	slices.SortStableFunc(export.AuditRecords, func(a, b audit.AuditRecord) int {
+		return b.Timestamp.Compare(a.Timestamp)
+	})
+
+	return export, nil
+}
@@ -0,0 +1,148 @@
+package hiscore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/audit"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/group"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/snapshot"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/user"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/worker"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+)
+
+func TestUserLifecycle(t *testing.T) {
+	ctx := context.Background()
+	f := newOrchestratorFixture()
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	u, err := f.userService.CreateUser(ctx, user.User{RunescapeName: "Hyger"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := f.userService.CreateUser(ctx, user.User{RunescapeName: "Zezima"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, userId := range []string{u.Id, u.Id, u.Id, other.Id} {
+		snap := testSnapshot(base.AddDate(0, 0, i), 1000*(i+1))
+		snap.UserId = userId
+		if _, err := f.orchestrator.CreateSnapshotWithDelta(ctx, snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A user merged into u leaves a tombstone whose job and audit records belong to u's data.
+	merged, err := f.userService.CreateUser(ctx, user.User{RunescapeName: "Lynx Titan"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.merger.MergeUsers(ctx, merged.Id, u.Id, false); err != nil {
+		t.Fatal(err)
+	}
+	var jobIds []string
+	for _, userId := range []string{u.Id, merged.Id, other.Id} {
+		job, err := f.jobService.CreateSnapshotJob(ctx, worker.Job{UserId: userId})
+		if err != nil {
+			t.Fatal(err)
+		}
+		jobIds = append(jobIds, job.Id)
+	}
+	g, err := f.groupService.CreateGroup(ctx, group.Group{Name: "Iron Clad"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, userId := range []string{u.Id, other.Id} {
+		if _, err := f.groupService.AddMember(ctx, g.Id, userId, api.GroupRoleMember, base); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snapshots, err := f.snapshotService.GetAllSnapshotsForUser(ctx, u.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// More records than an audit query returns, to check the export is not cut short.
+	records := []audit.AuditRecord{
+		{Action: audit.ActionUserCreate, EntityType: audit.EntityTypeUser, EntityId: merged.Id},
+		{Action: audit.ActionSnapshotCreate, EntityType: audit.EntityTypeSnapshot, EntityId: snapshots[0].Id},
+		{Action: audit.ActionWorkerJobCreate, EntityType: audit.EntityTypeJob, EntityId: jobIds[1]},
+		{Action: audit.ActionUserUpdate, EntityType: audit.EntityTypeUser, EntityId: other.Id},
+	}
+	for range audit.MaxQueryLimit {
+		records = append(records, audit.AuditRecord{Action: audit.ActionUserUpdate, EntityType: audit.EntityTypeUser, EntityId: u.Id})
+	}
+	for _, record := range records {
+		record.Actor = "admin"
+		if err := f.auditService.Record(ctx, record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	export, err := f.lifecycle.ExportUser(ctx, u.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if export.User.Id != u.Id || len(export.Snapshots) != 3 || len(export.Deltas) != 2 || len(export.Jobs) != 2 || len(export.AuditRecords) != audit.MaxQueryLimit+3 {
+		t.Errorf("export has %d snapshots, %d deltas, %d jobs and %d audit records, want 3, 2, 2 and %d",
+			len(export.Snapshots), len(export.Deltas), len(export.Jobs), len(export.AuditRecords), audit.MaxQueryLimit+3)
+	}
+	if len(export.MergedUsers) != 1 || export.MergedUsers[0].Id != merged.Id {
+		t.Errorf("export has merged users %+v, want %s", export.MergedUsers, merged.Id)
+	}
+	// Only the user's own memberships are exported, not those of the other members.
+	if len(export.Groups) != 1 || len(export.Groups[0].Memberships) != 1 || export.Groups[0].Memberships[0].UserId != u.Id {
+		t.Errorf("export has groups %+v, want %s with only the user's membership", export.Groups, g.Id)
+	}
+
+	if !f.deltaCache.IsCached(u.Id) {
+		t.Fatal("user is not in the delta cache before the hard delete")
+	}
+	report, err := f.lifecycle.HardDeleteUser(ctx, u.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.SnapshotsDeleted != 3 || report.DeltasDeleted != 2 || report.JobsDeleted != 2 || report.MergedUsersDeleted != 1 || report.MembershipsDeleted != 1 {
+		t.Errorf("report = %+v, want 3 snapshots, 2 deltas, 2 jobs, 1 merged user and 1 membership deleted", report)
+	}
+	if f.deltaCache.IsCached(u.Id) {
+		t.Error("user is still in the delta cache after the hard delete")
+	}
+	if snapshots, _ := f.snapshotRepo.GetAllSnapshotsForUser(ctx, u.Id); len(snapshots) != 0 {
+		t.Errorf("%d snapshots left after the hard delete, want 0", len(snapshots))
+	}
+	if _, err := f.userService.GetUserById(ctx, u.Id); !errors.Is(err, user.ErrUserNotFound) {
+		t.Errorf("GetUserById after the hard delete: got %v, want ErrUserNotFound", err)
+	}
+	if _, err := f.userService.GetUserById(ctx, merged.Id); !errors.Is(err, user.ErrUserNotFound) {
+		t.Errorf("GetUserById of the merged user after the hard delete: got %v, want ErrUserNotFound", err)
+	}
+	for _, userId := range []string{u.Id, merged.Id} {
+		if jobs, _ := f.jobService.GetJobsForUser(ctx, userId); len(jobs) != 0 {
+			t.Errorf("%d jobs of %s left after the hard delete, want 0", len(jobs), userId)
+		}
+	}
+	if _, err := f.lifecycle.ExportUser(ctx, u.Id); !errors.Is(err, user.ErrUserNotFound) {
+		t.Errorf("ExportUser after the hard delete: got %v, want ErrUserNotFound", err)
+	}
+	// A snapshot arriving late, as from the scheduler, must not bring data back under the id.
+	late := testSnapshot(base.AddDate(0, 0, 10), 9000)
+	late.UserId = u.Id
+	if _, err := f.orchestrator.CreateSnapshotWithDelta(ctx, late); !errors.Is(err, snapshot.ErrSnapshotValidation) {
+		t.Errorf("snapshot after the hard delete: got %v, want ErrSnapshotValidation", err)
+	}
+
+	// Other users are untouched.
+	if snapshots, _ := f.snapshotRepo.GetAllSnapshotsForUser(ctx, other.Id); len(snapshots) != 1 {
+		t.Errorf("other user has %d snapshots after the hard delete, want 1", len(snapshots))
+	}
+	if left, err := f.groupService.GetGroupById(ctx, g.Id); err != nil || len(left.Memberships) != 1 || left.Memberships[0].UserId != other.Id {
+		t.Errorf("group after the hard delete = %+v, %v; want only the other user's membership", left.Memberships, err)
+	}
+	if jobs, _ := f.jobService.GetJobsForUser(ctx, other.Id); len(jobs) != 1 {
+		t.Errorf("other user has %d jobs after the hard delete, want 1", len(jobs))
+	}
+}
@@ -0,0 +1,225 @@
+package hiscore
+
+import (
+	"context"
+	"slices"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/delta"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/group"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/snapshot"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/user"
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+)
+
+type UserMerger interface {
+	// MergeUsers moves the snapshots and group memberships of source to target, rebuilds the
+	// delta chain of target over the combined snapshots and leaves source as a tombstone pointing
+	// at target. With dryRun it only reports what a merge would do.
+	//
+	// Both users are locked against new snapshots before their snapshots are read, and every write
+	// can be repeated, so a merge that fails part way is finished by running it again.
+	MergeUsers(ctx context.Context, sourceId string, targetId string, dryRun bool) (MergeReport, error)
+}
+
+type userMerger struct {
+	*hiscoreOrchestrator
+	userService  user.UserService
+	groupService group.GroupService
+}
+
+func NewUserMerger(
+	mon *monitor.Monitor,
+	userService user.UserService,
+	snapshotService snapshot.SnapshotService,
+	deltaService delta.DeltaService,
+	groupService group.GroupService,
+	txManager *database.TransactionManager,
+) UserMerger {
+	return &userMerger{
+		hiscoreOrchestrator: &hiscoreOrchestrator{
+			monitor:         mon,
+			snapshotService: snapshotService,
+			deltaService:    deltaService,
+			txManager:       txManager,
+		},
+		userService:  userService,
+		groupService: groupService,
+	}
+}
+
+// chainedSnapshot is a snapshot in the merged chain and the user it came from.
+type chainedSnapshot struct {
+	snapshot   snapshot.HiscoreSnapshot
+	fromSource bool
+}
+
+func (m *userMerger) MergeUsers(ctx context.Context, sourceId string, targetId string, dryRun bool) (MergeReport, error) {
+	ctx, span := m.monitor.StartSpan(ctx, "userMerger.MergeUsers")
+	defer span.End()
+
+	source, target, err := m.userService.PrepareMerge(ctx, sourceId, targetId)
+	if err != nil {
+		return MergeReport{}, err
+	}
+	if !dryRun {
+		source, target, err = m.userService.BeginMerge(ctx, source, target)
+		if err != nil {
+			return MergeReport{}, err
+		}
+	}
+
+	sourceSnapshots, err := m.snapshotService.GetAllSnapshotsForUser(ctx, source.Id)
+	if err != nil {
+		return MergeReport{}, err
+	}
+	targetSnapshots, err := m.snapshotService.GetAllSnapshotsForUser(ctx, target.Id)
+	if err != nil {
+		return MergeReport{}, err
+	}
+
+	chain, duplicates := mergeSnapshotChains(sourceSnapshots, targetSnapshots)
+
+	report := MergeReport{
+		SourceUserId:         source.Id,
+		TargetUserId:         target.Id,
+		DryRun:               dryRun,
+		SourceSnapshots:      len(sourceSnapshots),
+		TargetSnapshots:      len(targetSnapshots),
+		DuplicateSnapshotIds: duplicates,
+		Target:               target,
+	}
+
+	var moved []string
+	for _, c := range chain {
+		if c.fromSource {
+			moved = append(moved, c.snapshot.Id)
+		}
+	}
+	report.SnapshotsMoved = len(moved)
+
+	for _, id := range []string{source.Id, target.Id} {
+		count, err := m.deltaService.CountDeltasForUser(ctx, id)
+		if err != nil {
+			return MergeReport{}, err
+		}
+		report.DeltasRemoved += count
+	}
+
+	// The chain is rebuilt as the target's, so the new deltas belong to the target even where
+	// both snapshots came from the source.
+	var deltas []delta.HiscoreDelta
+	for i := 1; i < len(chain); i++ {
+		current := chain[i].snapshot
+		current.UserId = target.Id
+		d := m.computeDelta(ctx, chain[i-1].snapshot, current)
+		if !d.IsEmpty() {
+			deltas = append(deltas, d)
+		}
+	}
+	report.DeltasCreated = len(deltas)
+
+	groups, err := m.groupService.GetGroupsByMember(ctx, source.Id)
+	if err != nil {
+		return MergeReport{}, err
+	}
+	for _, g := range groups {
+		for _, membership := range g.Memberships {
+			if membership.UserId == source.Id {
+				report.MembershipsMoved++
+			}
+		}
+	}
+
+	if dryRun {
+		return report, nil
+	}
+
+	err = m.txManager.WithTransaction(ctx, func(txCtx context.Context) error {
+		if len(moved) > 0 {
+			if err := m.snapshotService.ReassignSnapshots(txCtx, moved, target.Id); err != nil {
+				return err
+			}
+		}
+		if len(duplicates) > 0 {
+			if err := m.snapshotService.DeleteSnapshots(txCtx, duplicates); err != nil {
+				return err
+			}
+		}
+
+		for _, id := range []string{source.Id, target.Id} {
+			if _, err := m.deltaService.DeleteDeltasForUser(txCtx, id); err != nil {
+				return err
+			}
+		}
+		for _, d := range deltas {
+			if _, err := m.deltaService.CreateDelta(txCtx, d); err != nil {
+				return err
+			}
+		}
+
+		// A resumed merge can't tell which snapshots were moved before it stopped, so every
+		// experience change in the chain is recomputed.
+		for i, c := range chain {
+			change := 0
+			if i > 0 {
+				change = c.snapshot.GetSkill(snapshot.ActivityTypeOverall).Experience - chain[i-1].snapshot.GetSkill(snapshot.ActivityTypeOverall).Experience
+			}
+			if err := m.snapshotService.SetOverallExperienceChange(txCtx, c.snapshot.Id, change); err != nil {
+				return err
+			}
+		}
+
+		if _, err := m.groupService.MoveMemberships(txCtx, source.Id, target.Id); err != nil {
+			return err
+		}
+
+		merged, err := m.userService.CompleteMerge(txCtx, source, target)
+		if err != nil {
+			return err
+		}
+		report.Target = merged
+		return nil
+	})
+	if err != nil {
+		return MergeReport{}, err
+	}
+
+	if err := m.deltaService.RebuildCache(ctx, target.Id); err != nil {
+		return MergeReport{}, err
+	}
+
+	m.monitor.Logger().InfoArgs(ctx, "Merged user %s into %s: moved %d snapshots and %d memberships, dropped %d duplicates, replaced %d deltas with %d",
+		source.Id, target.Id, report.SnapshotsMoved, report.MembershipsMoved, len(duplicates), report.DeltasRemoved, report.DeltasCreated)
+	return report, nil
+}
+
+// mergeSnapshotChains orders the snapshots of both users by timestamp, the target's first on ties.
+// A source snapshot next to a target snapshot with the same stats is the same hiscore captured
+// under both users, so it is returned as a duplicate instead of joining the chain.
+func mergeSnapshotChains(source []snapshot.HiscoreSnapshot, target []snapshot.HiscoreSnapshot) ([]chainedSnapshot, []string) {
+	all := make([]chainedSnapshot, 0, len(source)+len(target))
+	for i, snapshots := range [][]snapshot.HiscoreSnapshot{target, source} {
+		for _, snap := range snapshots {
+			all = append(all, chainedSnapshot{snapshot: snap, fromSource: i == 1})
+		}
+	}
+	slices.SortStableFunc(all, func(a, b chainedSnapshot) int {
+		return a.snapshot.Timestamp.Compare(b.snapshot.Timestamp)
+	})
+
+	sameStats := func(a, b chainedSnapshot) bool {
+		return !a.fromSource && a.snapshot.SameStats(b.snapshot) && b.snapshot.SameStats(a.snapshot)
+	}
+
+	var chain []chainedSnapshot
+	var duplicates []string
+	for i, c := range all {
+		if c.fromSource && ((i > 0 && sameStats(all[i-1], c)) || (i+1 < len(all) && sameStats(all[i+1], c))) {
+			duplicates = append(duplicates, c.snapshot.Id)
+			continue
+		}
+		chain = append(chain, c)
+	}
+	return chain, duplicates
+}
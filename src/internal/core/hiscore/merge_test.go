@@ -0,0 +1,270 @@
+package hiscore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/group"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/snapshot"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/user"
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+)
+
+func TestMergeUsers(t *testing.T) {
+	ctx := context.Background()
+	f := newOrchestratorFixture()
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	target, err := f.userService.CreateUser(ctx, user.User{RunescapeName: "Hyger"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := f.userService.CreateUser(ctx, user.User{RunescapeName: "Hyger Alt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshotFor := func(userId string, timestamp time.Time, overallExperience int) string {
+		t.Helper()
+		snap := testSnapshot(timestamp, overallExperience)
+		snap.UserId = userId
+		created, err := f.orchestrator.CreateSnapshotWithDelta(ctx, snap)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return created.Snapshot.Id
+	}
+
+	t0 := snapshotFor(target.Id, base, 1000)
+	t2 := snapshotFor(target.Id, base.AddDate(0, 0, 2), 1400)
+	t4 := snapshotFor(target.Id, base.AddDate(0, 0, 4), 2000)
+	s1 := snapshotFor(source.Id, base.AddDate(0, 0, 1), 1200)
+	// The same hiscore as t2, captured under the source an hour later.
+	duplicate := snapshotFor(source.Id, base.AddDate(0, 0, 2).Add(time.Hour), 1400)
+	s3 := snapshotFor(source.Id, base.AddDate(0, 0, 3), 1700)
+
+	// Both users are in one group, where the stay of the source overlaps the target's, and only
+	// the source is in another.
+	shared, err := f.groupService.CreateGroup(ctx, group.Group{Name: "Iron Clad"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sourceOnly, err := f.groupService.CreateGroup(ctx, group.Group{Name: "Gielinor Gains"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.groupService.AddMember(ctx, shared.Id, target.Id, api.GroupRoleMember, base); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.groupService.AddMember(ctx, shared.Id, source.Id, api.GroupRoleAdmin, base.AddDate(0, 0, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.groupService.RemoveMember(ctx, shared.Id, source.Id, base.AddDate(0, 0, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.groupService.AddMember(ctx, sourceOnly.Id, source.Id, api.GroupRoleOwner, base); err != nil {
+		t.Fatal(err)
+	}
+
+	dryRun, err := f.merger.MergeUsers(ctx, source.Id, target.Id, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dryRun.SnapshotsMoved != 2 || len(dryRun.DuplicateSnapshotIds) != 1 || dryRun.DuplicateSnapshotIds[0] != duplicate ||
+		dryRun.DeltasRemoved != 4 || dryRun.DeltasCreated != 4 || dryRun.MembershipsMoved != 2 {
+		t.Fatalf("dry run report = %+v, want 2 moved, the duplicate dropped, 4 deltas replaced by 4 and 2 memberships moved", dryRun)
+	}
+	if snapshots, _ := f.snapshotRepo.GetAllSnapshotsForUser(ctx, source.Id); len(snapshots) != 3 {
+		t.Fatalf("source has %d snapshots after a dry run, want 3", len(snapshots))
+	}
+
+	report, err := f.merger.MergeUsers(ctx, source.Id, target.Id, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.SnapshotsMoved != dryRun.SnapshotsMoved || report.DeltasCreated != dryRun.DeltasCreated {
+		t.Errorf("report = %+v, want the same counts as the dry run %+v", report, dryRun)
+	}
+
+	if snapshots, _ := f.snapshotRepo.GetAllSnapshotsForUser(ctx, source.Id); len(snapshots) != 0 {
+		t.Errorf("source has %d snapshots after the merge, want none", len(snapshots))
+	}
+	if _, err := f.snapshotRepo.GetSnapshotById(ctx, duplicate); err == nil {
+		t.Errorf("duplicate snapshot %s still exists", duplicate)
+	}
+
+	deltas, err := f.deltaRepo.GetAllDeltasForUser(ctx, target.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gains := make(map[string]int)
+	for _, d := range deltas {
+		gains[d.PreviousSnapshotId+">"+d.SnapshotId] = overallGain(d)
+	}
+	want := map[string]int{t0 + ">" + s1: 200, s1 + ">" + t2: 200, t2 + ">" + s3: 300, s3 + ">" + t4: 300}
+	if len(gains) != len(want) {
+		t.Fatalf("deltas = %v, want %v", gains, want)
+	}
+	for link, gain := range want {
+		if gains[link] != gain {
+			t.Errorf("delta %s gained %d, want %d", link, gains[link], gain)
+		}
+	}
+	if sourceDeltas, _ := f.deltaRepo.GetAllDeltasForUser(ctx, source.Id); len(sourceDeltas) != 0 {
+		t.Errorf("source has %d deltas after the merge, want none", len(sourceDeltas))
+	}
+
+	for id, change := range map[string]int{t0: 0, s1: 200, t2: 200, s3: 300, t4: 300} {
+		data, err := f.snapshotRepo.GetSnapshotById(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data.UserId != target.Id || data.OverallExperienceChange != change {
+			t.Errorf("snapshot %s = user %s, experience change %d; want %s, %d", id, data.UserId, data.OverallExperienceChange, target.Id, change)
+		}
+	}
+
+	latest, err := f.deltaService.GetLatestDeltaForUser(ctx, target.Id)
+	if err != nil || latest.SnapshotId != t4 {
+		t.Errorf("latest delta = %+v, %v; want the rebuilt one into %s", latest, err, t4)
+	}
+
+	tombstone, err := f.userService.GetUserById(ctx, source.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tombstone.MergedInto != target.Id || tombstone.MergedAt == nil || tombstone.TrackingStatus != user.TrackingStatusDisabled ||
+		tombstone.RunescapeName != "" || tombstone.MergingWith != "" {
+		t.Errorf("source = %+v, want a disabled, nameless tombstone pointing at %s", tombstone, target.Id)
+	}
+	// The overlapping stays become one, with the higher role.
+	if g, err := f.groupService.GetGroupById(ctx, shared.Id); err != nil || len(g.Memberships) != 1 ||
+		g.Memberships[0].UserId != target.Id || !g.Memberships[0].JoinedAt.Equal(base) || g.Memberships[0].LeftAt != nil || g.Memberships[0].Role != group.GroupRoleAdmin {
+		t.Errorf("shared group after the merge = %+v, %v; want one open ADMIN stay of the target since %s", g.Memberships, err, base)
+	}
+	if g, err := f.groupService.GetGroupById(ctx, sourceOnly.Id); err != nil || len(g.Memberships) != 1 || g.Memberships[0].UserId != target.Id {
+		t.Errorf("source's group after the merge = %+v, %v; want the membership moved to the target", g.Memberships, err)
+	}
+	if groups, err := f.groupService.GetGroupsByMember(ctx, source.Id); err != nil || len(groups) != 0 {
+		t.Errorf("source is in %d groups after the merge, %v; want none", len(groups), err)
+	}
+	if all, err := f.userService.GetAllUsers(ctx); err != nil || len(all) != 1 || all[0].Id != target.Id {
+		t.Errorf("GetAllUsers after the merge = %+v, %v; want only the target", all, err)
+	}
+	// Snapshots still sent under the old id would land on the hidden tombstone.
+	late := testSnapshot(base.AddDate(0, 0, 10), 9000)
+	late.UserId = source.Id
+	if _, err := f.orchestrator.CreateSnapshotWithDelta(ctx, late); !errors.Is(err, snapshot.ErrSnapshotValidation) {
+		t.Errorf("snapshot of the merged user: got %v, want ErrSnapshotValidation", err)
+	}
+	found, matchedAlias, err := f.userService.GetUserByRunescapeName(ctx, "hyger alt")
+	if err != nil || found.Id != target.Id || !matchedAlias {
+		t.Errorf("lookup by the source name = %+v, %t, %v; want the target matched by alias", found, matchedAlias, err)
+	}
+
+	if _, err := f.merger.MergeUsers(ctx, source.Id, target.Id, true); !errors.Is(err, user.ErrUserValidation) {
+		t.Errorf("merging a merged user returned %v, want ErrUserValidation", err)
+	}
+	if _, err := f.merger.MergeUsers(ctx, target.Id, target.Id, true); !errors.Is(err, user.ErrUserValidation) {
+		t.Errorf("merging a user into itself returned %v, want ErrUserValidation", err)
+	}
+}
+
+// failingExperienceChanges fails the first experience change, as if the process stopped after
+// moving the snapshots and rebuilding the deltas.
+type failingExperienceChanges struct {
+	snapshot.SnapshotService
+	failed bool
+}
+
+func (s *failingExperienceChanges) SetOverallExperienceChange(ctx context.Context, id string, change int) error {
+	if !s.failed {
+		s.failed = true
+		return errors.New("interrupted")
+	}
+	return s.SnapshotService.SetOverallExperienceChange(ctx, id, change)
+}
+
+func TestMergeUsersResumes(t *testing.T) {
+	ctx := context.Background()
+	f := newOrchestratorFixture()
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	merger := NewUserMerger(f.monitor, f.userService, &failingExperienceChanges{SnapshotService: f.snapshotService}, f.deltaService, f.groupService, database.NewTransactionManager(nil, false))
+
+	target, err := f.userService.CreateUser(ctx, user.User{RunescapeName: "Hyger"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := f.userService.CreateUser(ctx, user.User{RunescapeName: "Hyger Alt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, owner := range []string{target.Id, source.Id, target.Id, source.Id} {
+		snap := testSnapshot(base.AddDate(0, 0, i), 1000+100*i)
+		snap.UserId = owner
+		if _, err := f.orchestrator.CreateSnapshotWithDelta(ctx, snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := merger.MergeUsers(ctx, source.Id, target.Id, false); err == nil {
+		t.Fatal("MergeUsers succeeded, want the injected failure")
+	}
+
+	// Until the merge is finished neither user takes snapshots, nor joins another merge.
+	late := testSnapshot(base.AddDate(0, 0, 5), 2000)
+	late.UserId = target.Id
+	if _, err := f.orchestrator.CreateSnapshotWithDelta(ctx, late); !errors.Is(err, snapshot.ErrSnapshotValidation) {
+		t.Errorf("snapshot of a user being merged: got %v, want ErrSnapshotValidation", err)
+	}
+	other, err := f.userService.CreateUser(ctx, user.User{RunescapeName: "Other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := merger.MergeUsers(ctx, other.Id, target.Id, true); !errors.Is(err, user.ErrUserValidation) {
+		t.Errorf("merging into a user being merged: got %v, want ErrUserValidation", err)
+	}
+
+	// Nor is either deleted, which would leave the other locked for good.
+	if _, err := f.userService.SoftDeleteUser(ctx, source.Id); !errors.Is(err, user.ErrUserValidation) {
+		t.Errorf("soft deleting a user being merged: got %v, want ErrUserValidation", err)
+	}
+	if _, err := f.lifecycle.HardDeleteUser(ctx, target.Id); !errors.Is(err, user.ErrUserValidation) {
+		t.Errorf("hard deleting a user being merged: got %v, want ErrUserValidation", err)
+	}
+
+	if _, err := merger.MergeUsers(ctx, source.Id, target.Id, false); err != nil {
+		t.Fatalf("resuming the merge: %v", err)
+	}
+
+	snapshots, err := f.snapshotRepo.GetAllSnapshotsForUser(ctx, target.Id)
+	if err != nil || len(snapshots) != 4 {
+		t.Fatalf("target has %d snapshots, %v; want all 4", len(snapshots), err)
+	}
+	for _, data := range snapshots {
+		want := 100
+		if data.Timestamp.Equal(base) {
+			want = 0
+		}
+		if data.OverallExperienceChange != want {
+			t.Errorf("snapshot at %s has experience change %d, want %d", data.Timestamp, data.OverallExperienceChange, want)
+		}
+	}
+	if deltas, err := f.deltaRepo.GetAllDeltasForUser(ctx, target.Id); err != nil || len(deltas) != 3 {
+		t.Errorf("target has %d deltas, %v; want 3", len(deltas), err)
+	}
+
+	merged, err := f.userService.GetUserById(ctx, target.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.MergingWith != "" || len(merged.NameHistory) != 1 {
+		t.Errorf("target = %+v, want unlocked with the one rename from the source", merged)
+	}
+	if _, err := f.orchestrator.CreateSnapshotWithDelta(ctx, late); err != nil {
+		t.Errorf("snapshot after the merge: %v", err)
+	}
+}
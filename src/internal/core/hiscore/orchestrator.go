@@ -3,6 +3,7 @@ package hiscore
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/ctfloyd/hazelmere-api/src/internal/core/delta"
@@ -17,6 +18,7 @@ var ErrSnapshotValidation = errors.New("snapshot validation failed")
 
 type HiscoreOrchestrator interface {
 	CreateSnapshotWithDelta(ctx context.Context, snap snapshot.HiscoreSnapshot) (CreateSnapshotResponse, error)
+	CreateHistoricalSnapshotWithDelta(ctx context.Context, snap snapshot.HiscoreSnapshot) (CreateSnapshotResponse, error)
 	GetDeltaSummary(ctx context.Context, userId string, startTime, endTime time.Time) (DeltaSummaryResponse, error)
 }
 
@@ -85,12 +87,116 @@ func (o *hiscoreOrchestrator) CreateSnapshotWithDelta(ctx context.Context, snap
 		return CreateSnapshotResponse{}, err
 	}
 
+	o.monitor.Metrics().RecordSnapshotCreated(ctx, createdSnapshot.Source)
+	if createdDelta != nil {
+		o.monitor.Metrics().RecordDeltaCreated(ctx)
+	}
+
 	return CreateSnapshotResponse{
 		Snapshot: createdSnapshot,
 		Delta:    createdDelta,
 	}, nil
 }
 
+// CreateHistoricalSnapshotWithDelta inserts a snapshot between existing ones, as backfills do.
+// The new snapshot gets a delta from the one before it, and the snapshot after it has its delta
+// and experience change recomputed against the new snapshot instead.
+//
+// Without transactions the writes can stop part way, so the snapshot itself is inserted last: a
+// day only counts as backfilled once its deltas are in place, and until then rerunning the
+// backfill repeats every step. Each step replaces what an earlier attempt wrote, and the snapshot
+// id is derived from the user and timestamp so a retry replaces its earlier delta too.
+func (o *hiscoreOrchestrator) CreateHistoricalSnapshotWithDelta(ctx context.Context, snap snapshot.HiscoreSnapshot) (CreateSnapshotResponse, error) {
+	ctx, span := o.monitor.StartSpan(ctx, "hiscoreOrchestrator.CreateHistoricalSnapshotWithDelta")
+	defer span.End()
+
+	if err := o.snapshotService.ValidateSnapshot(ctx, snap); err != nil {
+		return CreateSnapshotResponse{}, err
+	}
+	snap.Id = historicalSnapshotId(snap.UserId, snap.Timestamp)
+
+	previousSnapshot, hasPreviousSnapshot, err := o.optionalSnapshot(o.snapshotService.GetSnapshotBeforeForUser(ctx, snap.UserId, snap.Timestamp))
+	if err != nil {
+		return CreateSnapshotResponse{}, err
+	}
+	nextSnapshot, hasNextSnapshot, err := o.optionalSnapshot(o.snapshotService.GetSnapshotAfterForUser(ctx, snap.UserId, snap.Timestamp))
+	if err != nil {
+		return CreateSnapshotResponse{}, err
+	}
+
+	var createdSnapshot snapshot.HiscoreSnapshot
+	var createdDelta *delta.HiscoreDelta
+	err = o.txManager.WithTransaction(ctx, func(txCtx context.Context) error {
+		if hasNextSnapshot {
+			if err := o.deltaService.DeleteDeltasForSnapshot(txCtx, nextSnapshot.UserId, nextSnapshot.Id); err != nil {
+				return err
+			}
+			if _, err := o.deltaService.CreateDelta(txCtx, o.computeDelta(ctx, snap, nextSnapshot)); err != nil {
+				return err
+			}
+			change := nextSnapshot.GetSkill(snapshot.ActivityTypeOverall).Experience - snap.GetSkill(snapshot.ActivityTypeOverall).Experience
+			if err := o.snapshotService.SetOverallExperienceChange(txCtx, nextSnapshot.Id, change); err != nil {
+				return err
+			}
+		}
+
+		if hasPreviousSnapshot {
+			if err := o.deltaService.DeleteDeltasForSnapshot(txCtx, snap.UserId, snap.Id); err != nil {
+				return err
+			}
+			insertedDelta, err := o.deltaService.CreateDelta(txCtx, o.computeDelta(ctx, previousSnapshot, snap))
+			if err != nil {
+				return err
+			}
+			if insertedDelta.Id != "" {
+				createdDelta = &insertedDelta
+			}
+		}
+
+		created, err := o.snapshotService.CreateHistoricalSnapshot(txCtx, snap)
+		if err != nil {
+			return err
+		}
+		createdSnapshot = created
+		return nil
+	})
+
+	if err != nil {
+		return CreateSnapshotResponse{}, err
+	}
+
+	o.monitor.Metrics().RecordSnapshotCreated(ctx, createdSnapshot.Source)
+	if createdDelta != nil {
+		o.monitor.Metrics().RecordDeltaCreated(ctx)
+	}
+
+	return CreateSnapshotResponse{
+		Snapshot: createdSnapshot,
+		Delta:    createdDelta,
+	}, nil
+}
+
+// historicalSnapshotNamespace scopes the ids derived by historicalSnapshotId.
+var historicalSnapshotNamespace = uuid.MustParse("6f1d3b52-8a4e-4c2b-9e57-0d2f8c6a41b3")
+
+// historicalSnapshotId returns the same id every time a user's snapshot at timestamp is
+// backfilled. Timestamps are compared at millisecond precision, as Mongo stores them.
+func historicalSnapshotId(userId string, timestamp time.Time) string {
+	name := fmt.Sprintf("%s/%d", userId, timestamp.UnixMilli())
+	return uuid.NewSHA1(historicalSnapshotNamespace, []byte(name)).String()
+}
+
+// optionalSnapshot turns a not found lookup into a missing snapshot rather than an error.
+func (o *hiscoreOrchestrator) optionalSnapshot(snap snapshot.HiscoreSnapshot, err error) (snapshot.HiscoreSnapshot, bool, error) {
+	if errors.Is(err, snapshot.ErrSnapshotNotFound) {
+		return snapshot.HiscoreSnapshot{}, false, nil
+	}
+	if err != nil {
+		return snapshot.HiscoreSnapshot{}, false, err
+	}
+	return snap, true, nil
+}
+
 func (o *hiscoreOrchestrator) GetDeltaSummary(ctx context.Context, userId string, startTime, endTime time.Time) (DeltaSummaryResponse, error) {
 	ctx, span := o.monitor.StartSpan(ctx, "hiscoreOrchestrator.GetDeltaSummary")
 	defer span.End()
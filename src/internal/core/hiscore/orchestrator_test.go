@@ -0,0 +1,249 @@
+package hiscore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/audit"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/delta"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/group"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/snapshot"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/user"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/worker"
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_logger"
+)
+
+const testUserId = "4c7a8f8e-2a7e-4bd3-9d8b-3c1f1d5c7f01"
+
+type orchestratorFixture struct {
+	monitor         *monitor.Monitor
+	orchestrator    HiscoreOrchestrator
+	snapshotService snapshot.SnapshotService
+	merger          UserMerger
+	lifecycle       UserLifecycle
+	userService     user.UserService
+	userRepo        user.UserRepository
+	auditService    audit.AuditService
+	jobService      worker.JobService
+	groupService    group.GroupService
+	deltaCache      *delta.DeltaCache
+	deltaService    delta.DeltaService
+	snapshotRepo    snapshot.SnapshotRepository
+	deltaRepo       delta.DeltaRepository
+}
+
+func newOrchestratorFixture() orchestratorFixture {
+	mon := monitor.New(hz_logger.NewZeroLogAdapater(hz_logger.LogLevelError))
+	userRepo := user.NewMemoryUserRepository(mon)
+	snapshotRepo := snapshot.NewMemorySnapshotRepository(mon)
+	deltaRepo := delta.NewMemoryDeltaRepository(mon)
+
+	snapshotService := snapshot.NewSnapshotService(mon, snapshotRepo, snapshot.NewSnapshotValidator(), userRepo)
+	deltaCache := delta.NewDeltaCache()
+	deltaService := delta.NewDeltaService(mon, deltaRepo, deltaCache, userRepo)
+	userService := user.NewUserService(mon, userRepo, user.NewUserValidator())
+	auditService := audit.NewAuditService(mon, audit.NewMemoryAuditRepository(mon))
+	jobService := worker.NewJobService(mon, worker.NewMemoryJobRepository(mon), worker.NewJobValidator(), nil)
+	groupService := group.NewGroupService(mon, group.NewMemoryGroupRepository(mon), group.NewGroupValidator(), userRepo, deltaService)
+	txManager := database.NewTransactionManager(nil, false)
+	return orchestratorFixture{
+		monitor:         mon,
+		orchestrator:    NewHiscoreOrchestrator(mon, snapshotService, deltaService, txManager),
+		snapshotService: snapshotService,
+		merger:          NewUserMerger(mon, userService, snapshotService, deltaService, groupService, txManager),
+		lifecycle:       NewUserLifecycle(mon, userService, snapshotService, deltaService, auditService, jobService, groupService, txManager),
+		userService:     userService,
+		userRepo:        userRepo,
+		auditService:    auditService,
+		jobService:      jobService,
+		groupService:    groupService,
+		deltaCache:      deltaCache,
+		deltaService:    deltaService,
+		snapshotRepo:    snapshotRepo,
+		deltaRepo:       deltaRepo,
+	}
+}
+
+// createTestUser creates the user testSnapshot belongs to, since snapshots are only taken of
+// users that exist.
+func (f orchestratorFixture) createTestUser(t *testing.T) {
+	t.Helper()
+	data := user.User{Id: testUserId, RunescapeName: "Test User", TrackingStatus: user.TrackingStatusEnabled}.ToData()
+	if _, err := f.userRepo.CreateUser(context.Background(), data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testSnapshot builds a snapshot with every activity type, where only overall experience varies.
+func testSnapshot(timestamp time.Time, overallExperience int) snapshot.HiscoreSnapshot {
+	snap := snapshot.HiscoreSnapshot{UserId: testUserId, Timestamp: timestamp, Source: "TEST"}
+	for _, skill := range snapshot.AllSkillActivityTypes {
+		experience := 0
+		if skill == snapshot.ActivityTypeOverall {
+			experience = overallExperience
+		}
+		snap.Skills = append(snap.Skills, snapshot.SkillSnapshot{ActivityType: skill, Level: 1, Experience: experience, Rank: 1})
+	}
+	for _, boss := range snapshot.AllBossActivityTypes {
+		snap.Bosses = append(snap.Bosses, snapshot.BossSnapshot{ActivityType: boss, KillCount: -1, Rank: -1})
+	}
+	for _, activity := range snapshot.AllActivityActivityTypes {
+		snap.Activities = append(snap.Activities, snapshot.ActivitySnapshot{ActivityType: activity, Score: -1, Rank: -1})
+	}
+	return snap
+}
+
+func overallGain(d delta.HiscoreDeltaData) int {
+	for _, skill := range d.Skills {
+		if skill.ActivityType == string(snapshot.ActivityTypeOverall) {
+			return skill.ExperienceGain
+		}
+	}
+	return 0
+}
+
+func TestCreateHistoricalSnapshotWithDelta(t *testing.T) {
+	ctx := context.Background()
+	f := newOrchestratorFixture()
+	f.createTestUser(t)
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	first, err := f.orchestrator.CreateSnapshotWithDelta(ctx, testSnapshot(base, 1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, err := f.orchestrator.CreateSnapshotWithDelta(ctx, testSnapshot(base.AddDate(0, 0, 2), 1600))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	middle, err := f.orchestrator.CreateHistoricalSnapshotWithDelta(ctx, testSnapshot(base.AddDate(0, 0, 1), 1200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if middle.Delta == nil || middle.Delta.PreviousSnapshotId != first.Snapshot.Id {
+		t.Fatalf("delta = %+v, want one from the first snapshot", middle.Delta)
+	}
+
+	deltas, err := f.deltaRepo.GetAllDeltasForUser(ctx, testUserId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gains := make(map[string]int)
+	for _, d := range deltas {
+		gains[d.PreviousSnapshotId+">"+d.SnapshotId] = overallGain(d)
+	}
+	want := map[string]int{
+		first.Snapshot.Id + ">" + middle.Snapshot.Id: 200,
+		middle.Snapshot.Id + ">" + last.Snapshot.Id:  400,
+	}
+	if len(gains) != len(want) {
+		t.Fatalf("deltas = %v, want %v", gains, want)
+	}
+	for link, gain := range want {
+		if gains[link] != gain {
+			t.Errorf("delta %s gained %d, want %d", link, gains[link], gain)
+		}
+	}
+
+	for id, change := range map[string]int{middle.Snapshot.Id: 200, last.Snapshot.Id: 400} {
+		data, err := f.snapshotRepo.GetSnapshotById(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data.OverallExperienceChange != change {
+			t.Errorf("snapshot %s experience change = %d, want %d", id, data.OverallExperienceChange, change)
+		}
+	}
+}
+
+func TestCreateHistoricalSnapshotWithDeltaBeforeFirst(t *testing.T) {
+	ctx := context.Background()
+	f := newOrchestratorFixture()
+	f.createTestUser(t)
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	existing, err := f.orchestrator.CreateSnapshotWithDelta(ctx, testSnapshot(base, 1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	earlier, err := f.orchestrator.CreateHistoricalSnapshotWithDelta(ctx, testSnapshot(base.AddDate(0, 0, -1), 900))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if earlier.Delta != nil {
+		t.Errorf("delta = %+v, want none for the earliest snapshot", earlier.Delta)
+	}
+
+	deltas, err := f.deltaRepo.GetAllDeltasForUser(ctx, testUserId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deltas) != 1 || deltas[0].SnapshotId != existing.Snapshot.Id || overallGain(deltas[0]) != 100 {
+		t.Errorf("deltas = %+v, want one of 100 into the existing snapshot", deltas)
+	}
+}
+
+// failingHistoricalSnapshots fails the first historical insert, as if the process stopped after
+// writing the deltas.
+type failingHistoricalSnapshots struct {
+	snapshot.SnapshotService
+	failed bool
+}
+
+func (s *failingHistoricalSnapshots) CreateHistoricalSnapshot(ctx context.Context, snap snapshot.HiscoreSnapshot) (snapshot.HiscoreSnapshot, error) {
+	if !s.failed {
+		s.failed = true
+		return snapshot.HiscoreSnapshot{}, errors.New("interrupted")
+	}
+	return s.SnapshotService.CreateHistoricalSnapshot(ctx, snap)
+}
+
+func TestCreateHistoricalSnapshotWithDeltaRetry(t *testing.T) {
+	ctx := context.Background()
+	f := newOrchestratorFixture()
+	f.createTestUser(t)
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	orchestrator := NewHiscoreOrchestrator(f.monitor, &failingHistoricalSnapshots{SnapshotService: f.snapshotService}, f.deltaService, database.NewTransactionManager(nil, false))
+
+	first, err := orchestrator.CreateSnapshotWithDelta(ctx, testSnapshot(base, 1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, err := orchestrator.CreateSnapshotWithDelta(ctx, testSnapshot(base.AddDate(0, 0, 2), 1600))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := orchestrator.CreateHistoricalSnapshotWithDelta(ctx, testSnapshot(base.AddDate(0, 0, 1), 1200)); err == nil {
+		t.Fatal("CreateHistoricalSnapshotWithDelta succeeded, want the injected failure")
+	}
+	// The day must still look missing so the backfill retries it.
+	before, err := f.snapshotRepo.GetSnapshotBeforeForUser(ctx, testUserId, last.Snapshot.Timestamp)
+	if err != nil || before.Id != first.Snapshot.Id {
+		t.Fatalf("snapshot before the last = %s, %v; want the first until the retry succeeds", before.Id, err)
+	}
+
+	middle, err := orchestrator.CreateHistoricalSnapshotWithDelta(ctx, testSnapshot(base.AddDate(0, 0, 1), 1200))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deltas, err := f.deltaRepo.GetAllDeltasForUser(ctx, testUserId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	links := make(map[string]int)
+	for _, d := range deltas {
+		links[d.PreviousSnapshotId+">"+d.SnapshotId]++
+	}
+	want := []string{first.Snapshot.Id + ">" + middle.Snapshot.Id, middle.Snapshot.Id + ">" + last.Snapshot.Id}
+	if len(deltas) != len(want) || links[want[0]] != 1 || links[want[1]] != 1 {
+		t.Errorf("deltas after the retry = %v, want exactly %v", links, want)
+	}
+}
@@ -1,8 +1,15 @@
 package hiscore
 
 import (
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/audit"
 	"github.com/ctfloyd/hazelmere-api/src/internal/core/delta"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/group"
 	"github.com/ctfloyd/hazelmere-api/src/internal/core/snapshot"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/user"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/worker"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
 )
 
 // CreateSnapshotResponse contains all objects created when creating a snapshot with delta
@@ -16,3 +23,80 @@ type DeltaSummaryResponse struct {
 	Snapshot snapshot.HiscoreSnapshot
 	Deltas   []delta.HiscoreDelta
 }
+
+// MergeReport describes a merge of one user into another, or with DryRun what it would do.
+type MergeReport struct {
+	SourceUserId         string
+	TargetUserId         string
+	DryRun               bool
+	SourceSnapshots      int
+	TargetSnapshots      int
+	SnapshotsMoved       int
+	DuplicateSnapshotIds []string
+	DeltasRemoved        int64
+	DeltasCreated        int
+	MembershipsMoved     int
+	Target               user.User
+}
+
+func (r MergeReport) ToAPI() api.MergeReport {
+	return api.MergeReport{
+		SourceUserId:         r.SourceUserId,
+		TargetUserId:         r.TargetUserId,
+		DryRun:               r.DryRun,
+		SourceSnapshots:      r.SourceSnapshots,
+		TargetSnapshots:      r.TargetSnapshots,
+		SnapshotsMoved:       r.SnapshotsMoved,
+		DuplicateSnapshotIds: r.DuplicateSnapshotIds,
+		DeltasRemoved:        r.DeltasRemoved,
+		DeltasCreated:        r.DeltasCreated,
+		MembershipsMoved:     r.MembershipsMoved,
+		Target:               r.Target.ToAPI(),
+	}
+}
+
+// DeletionReport counts what a hard delete of a user removed.
+type DeletionReport struct {
+	UserId             string
+	MergedUsersDeleted int64
+	SnapshotsDeleted   int64
+	DeltasDeleted      int64
+	JobsDeleted        int64
+	MembershipsDeleted int64
+}
+
+func (r DeletionReport) ToAPI() api.DeleteUserPermanentlyResponse {
+	return api.DeleteUserPermanentlyResponse{
+		UserId:             r.UserId,
+		MergedUsersDeleted: r.MergedUsersDeleted,
+		SnapshotsDeleted:   r.SnapshotsDeleted,
+		DeltasDeleted:      r.DeltasDeleted,
+		JobsDeleted:        r.JobsDeleted,
+		MembershipsDeleted: r.MembershipsDeleted,
+	}
+}
+
+// UserExport is everything held about a user.
+type UserExport struct {
+	ExportedAt   time.Time
+	User         user.User
+	MergedUsers  []user.User
+	Snapshots    []snapshot.HiscoreSnapshot
+	Deltas       []delta.HiscoreDelta
+	Jobs         []worker.Job
+	Groups       []group.Group
+	AuditRecords []audit.AuditRecord
+}
+
+func (e UserExport) ToAPI() api.UserExport {
+	return api.UserExport{
+		ExportedAt:   e.ExportedAt,
+		User:         e.User.ToAPI(),
+		MergedUsers:  user.User{}.ManyToAPI(e.MergedUsers),
+		Snapshots:    snapshot.HiscoreSnapshot{}.ManyToAPI(e.Snapshots),
+		Deltas:       delta.HiscoreDelta{}.ManyToAPI(e.Deltas),
+		Jobs:         worker.Job{}.ManyToAPI(e.Jobs),
+		Groups:       group.Group{}.ManyToAPI(e.Groups),
+		AuditRecords: audit.AuditRecord{}.ManyToAPI(e.AuditRecords),
+	}
+}
@@ -0,0 +1,175 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/snapshot"
+)
+
+var ErrPlayerNotFound = errors.New("player has no history in this source")
+var ErrHistoryGeneric = errors.New("could not read history")
+
+// HistorySource supplies a player's hiscore history from outside Hazelmere, such as Wise Old Man
+// or an exported file.
+type HistorySource interface {
+	// Name identifies the source, e.g. WOM. Snapshots carry it in their Source field.
+	Name() string
+	// FirstSeen returns the earliest time the source may have history for player.
+	FirstSeen(ctx context.Context, player string) (time.Time, error)
+	// Snapshots returns the player's snapshots taken in [start, end), oldest first. Every skill,
+	// boss and activity is present, with -1 for values the source didn't have. UserId is left
+	// empty for the caller to fill in.
+	Snapshots(ctx context.Context, player string, start, end time.Time) ([]snapshot.HiscoreSnapshot, error)
+}
+
+// builder collects the values a source found for one snapshot and fills in the rest.
+type builder struct {
+	skills     map[snapshot.ActivityType]snapshot.SkillSnapshot
+	bosses     map[snapshot.ActivityType]snapshot.BossSnapshot
+	activities map[snapshot.ActivityType]snapshot.ActivitySnapshot
+}
+
+func newBuilder() *builder {
+	return &builder{
+		skills:     make(map[snapshot.ActivityType]snapshot.SkillSnapshot),
+		bosses:     make(map[snapshot.ActivityType]snapshot.BossSnapshot),
+		activities: make(map[snapshot.ActivityType]snapshot.ActivitySnapshot),
+	}
+}
+
+func (b *builder) skill(activityType snapshot.ActivityType, level, experience, rank int) {
+	b.skills[activityType] = snapshot.SkillSnapshot{ActivityType: activityType, Level: level, Experience: experience, Rank: rank}
+}
+
+func (b *builder) boss(activityType snapshot.ActivityType, killCount, rank int) {
+	b.bosses[activityType] = snapshot.BossSnapshot{ActivityType: activityType, KillCount: killCount, Rank: rank}
+}
+
+func (b *builder) activity(activityType snapshot.ActivityType, score, rank int) {
+	b.activities[activityType] = snapshot.ActivitySnapshot{ActivityType: activityType, Score: score, Rank: rank}
+}
+
+// set records value under whichever kind activityType is. Skills use it as experience.
+func (b *builder) set(activityType snapshot.ActivityType, value, level, rank int) {
+	switch {
+	case slices.Contains(snapshot.AllSkillActivityTypes, activityType):
+		b.skill(activityType, level, value, rank)
+	case slices.Contains(snapshot.AllBossActivityTypes, activityType):
+		b.boss(activityType, value, rank)
+	case slices.Contains(snapshot.AllActivityActivityTypes, activityType):
+		b.activity(activityType, value, rank)
+	}
+}
+
+func (b *builder) build(timestamp time.Time, source string) snapshot.HiscoreSnapshot {
+	snap := snapshot.HiscoreSnapshot{
+		Timestamp:  timestamp.UTC(),
+		Skills:     make([]snapshot.SkillSnapshot, 0, len(snapshot.AllSkillActivityTypes)),
+		Bosses:     make([]snapshot.BossSnapshot, 0, len(snapshot.AllBossActivityTypes)),
+		Activities: make([]snapshot.ActivitySnapshot, 0, len(snapshot.AllActivityActivityTypes)),
+		Source:     source,
+	}
+	for _, activityType := range snapshot.AllSkillActivityTypes {
+		skill, ok := b.skills[activityType]
+		if !ok {
+			skill = snapshot.SkillSnapshot{ActivityType: activityType, Level: -1, Experience: -1, Rank: -1}
+		}
+		snap.Skills = append(snap.Skills, skill)
+	}
+	for _, activityType := range snapshot.AllBossActivityTypes {
+		boss, ok := b.bosses[activityType]
+		if !ok {
+			boss = snapshot.BossSnapshot{ActivityType: activityType, KillCount: -1, Rank: -1}
+		}
+		snap.Bosses = append(snap.Bosses, boss)
+	}
+	for _, activityType := range snapshot.AllActivityActivityTypes {
+		activity, ok := b.activities[activityType]
+		if !ok {
+			activity = snapshot.ActivitySnapshot{ActivityType: activityType, Score: -1, Rank: -1}
+		}
+		snap.Activities = append(snap.Activities, activity)
+	}
+	return snap
+}
+
+// inRange keeps the snapshots in [start, end) and sorts them oldest first.
+func inRange(snapshots []snapshot.HiscoreSnapshot, start, end time.Time) []snapshot.HiscoreSnapshot {
+	kept := make([]snapshot.HiscoreSnapshot, 0, len(snapshots))
+	for _, snap := range snapshots {
+		if !snap.Timestamp.Before(start) && snap.Timestamp.Before(end) {
+			kept = append(kept, snap)
+		}
+	}
+	slices.SortFunc(kept, func(a, b snapshot.HiscoreSnapshot) int { return a.Timestamp.Compare(b.Timestamp) })
+	return kept
+}
+
+// metricKey reduces a metric name to lower case letters and digits, so "Clue_Scrolls (all)",
+// "clue_scrolls_all" and CLUE_SCROLLS_ALL all compare equal.
+func metricKey(name string) string {
+	var key strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			key.WriteRune(r)
+		}
+	}
+	return key.String()
+}
+
+// metricAliases maps the names other trackers use to Hazelmere's activity types where the two
+// don't reduce to the same key.
+var metricAliases = map[string]snapshot.ActivityType{
+	"runecrafting":       snapshot.ActivityTypeRunecraft,
+	"clueall":            snapshot.ActivityTypeClueScrollsall,
+	"cluebeginner":       snapshot.ActivityTypeClueScrollsbeginner,
+	"clueeasy":           snapshot.ActivityTypeClueScrollseasy,
+	"cluemedium":         snapshot.ActivityTypeClueScrollsmedium,
+	"cluehard":           snapshot.ActivityTypeClueScrollshard,
+	"clueelite":          snapshot.ActivityTypeClueScrollselite,
+	"cluemaster":         snapshot.ActivityTypeClueScrollsmaster,
+	"lms":                snapshot.ActivityTypeLMSRank,
+	"lastmanstanding":    snapshot.ActivityTypeLMSRank,
+	"pvparena":           snapshot.ActivityTypePvPArenaRank,
+	"soulwars":           snapshot.ActivityTypeSoulWarsZeal,
+	"guardiansoftherift": snapshot.ActivityTypeRiftsclosed,
+	"bountyhunter":       snapshot.ActivityTypeBountyHunterHunter,
+	"thenightmare":       snapshot.ActivityTypeNightmare,
+	"gauntlet":           snapshot.ActivityTypeTheGauntlet,
+	"corruptedgauntlet":  snapshot.ActivityTypeTheCorruptedGauntlet,
+	"hueycoatl":          snapshot.ActivityTypeTheHueycoatl,
+	"leviathan":          snapshot.ActivityTypeTheLeviathan,
+	"whisperer":          snapshot.ActivityTypeTheWhisperer,
+	"royaltitans":        snapshot.ActivityTypeTheRoyalTitans,
+	"tob":                snapshot.ActivityTypeTheatreOfBlood,
+	"tobhm":              snapshot.ActivityTypeTheatreOfBloodHardMode,
+	"cox":                snapshot.ActivityTypeChambersofXeric,
+	"coxcm":              snapshot.ActivityTypeChambersofXericChallengeMode,
+	"toa":                snapshot.ActivityTypeTombsOfAmascut,
+	"toaexpert":          snapshot.ActivityTypeTombsOfAmascutExpertMode,
+	"collectionlog":      snapshot.ActivityTypeCollectionsLogged,
+}
+
+var activityTypesByKey = func() map[string]snapshot.ActivityType {
+	byKey := make(map[string]snapshot.ActivityType, len(snapshot.AllActivityTypes)+len(metricAliases))
+	for _, activityType := range snapshot.AllActivityTypes {
+		byKey[metricKey(string(activityType))] = activityType
+	}
+	for key, activityType := range metricAliases {
+		byKey[key] = activityType
+	}
+	return byKey
+}()
+
+// lookupMetric resolves a metric name from another tracker to an activity type.
+func lookupMetric(name string) (snapshot.ActivityType, bool) {
+	activityType, ok := activityTypesByKey[metricKey(name)]
+	if !ok || activityType == snapshot.ActivityTypeUnknown {
+		return "", false
+	}
+	return activityType, true
+}
@@ -0,0 +1,308 @@
+package history
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/snapshot"
+)
+
+type FileFormat string
+
+const (
+	// FileFormatTemple is a TempleOSRS datapoint export: a JSON object with an optional player
+	// name and a data array of datapoints. Each datapoint has a date and one key per metric,
+	// with <metric>_rank and <metric>_level alongside:
+	//
+	//	{"player": "Hazel Tester", "data": [{"date": "2025-01-01 08:00:00", "Overall": 1000000, "Overall_level": 100, "Overall_rank": 152311, "Zulrah": 5}]}
+	//
+	// Dates are UTC, either "2006-01-02 15:04:05", RFC3339 or unix seconds. Metrics Hazelmere
+	// doesn't track, such as Ehp, are ignored.
+	FileFormatTemple FileFormat = "temple"
+	// FileFormatCSV is raw hiscore lite output. Each snapshot is an RFC3339 timestamp line followed
+	// by the hiscore lines exactly as returned: rank,level,experience for every skill, then
+	// rank,score for every activity and boss, in the order Hazelmere lists them. Blank lines and
+	// lines starting with # are skipped.
+	FileFormatCSV FileFormat = "csv"
+)
+
+var AllFileFormats = []FileFormat{FileFormatTemple, FileFormatCSV}
+
+// FileFormatFromPath picks the format from the file extension: .json is Temple, .csv and .txt are CSV.
+func FileFormatFromPath(path string) (FileFormat, bool) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FileFormatTemple, true
+	case ".csv", ".txt":
+		return FileFormatCSV, true
+	}
+	return "", false
+}
+
+type fileHistorySource struct {
+	name      string
+	player    string
+	snapshots []snapshot.HiscoreSnapshot
+}
+
+// NewFileHistorySource reads the whole export at path up front, so a malformed file fails before
+// anything is imported. Snapshots record the format and file name as their source, e.g.
+// TEMPLE:export.json.
+func NewFileHistorySource(path string, format FileFormat) (HistorySource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Join(ErrHistoryGeneric, err)
+	}
+	defer file.Close()
+
+	source := &fileHistorySource{name: strings.ToUpper(string(format)) + ":" + filepath.Base(path)}
+	switch format {
+	case FileFormatTemple:
+		source.player, source.snapshots, err = parseTemple(file, source.name)
+	case FileFormatCSV:
+		source.snapshots, err = parseHiscoreCSV(file, source.name)
+	default:
+		err = fmt.Errorf("unknown file format %q", format)
+	}
+	if err != nil {
+		return nil, errors.Join(ErrHistoryGeneric, fmt.Errorf("%s: %w", path, err))
+	}
+	return source, nil
+}
+
+func (s *fileHistorySource) Name() string {
+	return s.name
+}
+
+func (s *fileHistorySource) FirstSeen(_ context.Context, player string) (time.Time, error) {
+	if !s.hasPlayer(player) {
+		return time.Time{}, ErrPlayerNotFound
+	}
+	first := s.snapshots[0].Timestamp
+	for _, snap := range s.snapshots[1:] {
+		if snap.Timestamp.Before(first) {
+			first = snap.Timestamp
+		}
+	}
+	return first, nil
+}
+
+func (s *fileHistorySource) Snapshots(_ context.Context, player string, start, end time.Time) ([]snapshot.HiscoreSnapshot, error) {
+	if !s.hasPlayer(player) {
+		return nil, ErrPlayerNotFound
+	}
+	return inRange(s.snapshots, start, end), nil
+}
+
+// hasPlayer reports whether the file holds history for player. Files that don't name a player
+// are taken to belong to whoever they are imported for.
+func (s *fileHistorySource) hasPlayer(player string) bool {
+	if len(s.snapshots) == 0 {
+		return false
+	}
+	return s.player == "" || normalizePlayerName(s.player) == normalizePlayerName(player)
+}
+
+// normalizePlayerName folds the characters the hiscores treat as equivalent in names.
+func normalizePlayerName(name string) string {
+	return strings.NewReplacer("_", " ", "-", " ").Replace(strings.ToLower(strings.TrimSpace(name)))
+}
+
+type templeExport struct {
+	Player string                       `json:"player"`
+	Data   []map[string]json.RawMessage `json:"data"`
+}
+
+const templeDateLayout = "2006-01-02 15:04:05"
+
+func parseTemple(r io.Reader, source string) (string, []snapshot.HiscoreSnapshot, error) {
+	var export templeExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return "", nil, err
+	}
+
+	snapshots := make([]snapshot.HiscoreSnapshot, 0, len(export.Data))
+	for i, datapoint := range export.Data {
+		snap, err := parseTempleDatapoint(datapoint, source)
+		if err != nil {
+			return "", nil, fmt.Errorf("datapoint %d: %w", i, err)
+		}
+		snapshots = append(snapshots, snap)
+	}
+	return export.Player, snapshots, nil
+}
+
+func parseTempleDatapoint(datapoint map[string]json.RawMessage, source string) (snapshot.HiscoreSnapshot, error) {
+	timestamp, err := parseTempleDate(datapoint["date"])
+	if err != nil {
+		return snapshot.HiscoreSnapshot{}, err
+	}
+
+	type metric struct{ value, level, rank int }
+	metrics := make(map[snapshot.ActivityType]*metric)
+	for key, raw := range datapoint {
+		if key == "date" {
+			continue
+		}
+		name, field := key, "value"
+		if base, ok := strings.CutSuffix(key, "_rank"); ok {
+			name, field = base, "rank"
+		} else if base, ok := strings.CutSuffix(key, "_level"); ok {
+			name, field = base, "level"
+		}
+
+		activityType, ok := lookupMetric(name)
+		if !ok {
+			continue
+		}
+		var value float64
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return snapshot.HiscoreSnapshot{}, fmt.Errorf("%s: %w", key, err)
+		}
+
+		m, ok := metrics[activityType]
+		if !ok {
+			m = &metric{value: -1, level: -1, rank: -1}
+			metrics[activityType] = m
+		}
+		switch field {
+		case "rank":
+			m.rank = int(value)
+		case "level":
+			m.level = int(value)
+		default:
+			m.value = int(value)
+		}
+	}
+
+	b := newBuilder()
+	for activityType, m := range metrics {
+		b.set(activityType, m.value, m.level, m.rank)
+	}
+	return b.build(timestamp, source), nil
+}
+
+func parseTempleDate(raw json.RawMessage) (time.Time, error) {
+	if raw == nil {
+		return time.Time{}, errors.New("missing date")
+	}
+
+	var seconds int64
+	if err := json.Unmarshal(raw, &seconds); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return time.Time{}, fmt.Errorf("date: %w", err)
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse(templeDateLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("date %q is not %q, RFC3339 or unix seconds", value, templeDateLayout)
+	}
+	return t, nil
+}
+
+// hiscoreOrder is the order of lines in hiscore lite output.
+var hiscoreOrder = func() []snapshot.ActivityType {
+	order := make([]snapshot.ActivityType, 0, len(snapshot.AllSkillActivityTypes)+len(snapshot.AllActivityActivityTypes)+len(snapshot.AllBossActivityTypes))
+	order = append(order, snapshot.AllSkillActivityTypes...)
+	order = append(order, snapshot.AllActivityActivityTypes...)
+	return append(order, snapshot.AllBossActivityTypes...)
+}()
+
+func parseHiscoreCSV(r io.Reader, source string) ([]snapshot.HiscoreSnapshot, error) {
+	var snapshots []snapshot.HiscoreSnapshot
+	var timestamp time.Time
+	var rows [][]int
+	startLine := 0
+
+	finish := func() error {
+		if timestamp.IsZero() {
+			return nil
+		}
+		if len(rows) != len(hiscoreOrder) {
+			return fmt.Errorf("line %d: snapshot has %d hiscore lines, want %d", startLine, len(rows), len(hiscoreOrder))
+		}
+		b := newBuilder()
+		for i, activityType := range hiscoreOrder {
+			row := rows[i]
+			if i < len(snapshot.AllSkillActivityTypes) {
+				b.skill(activityType, row[1], row[2], row[0])
+			} else {
+				b.set(activityType, row[1], -1, row[0])
+			}
+		}
+		snapshots = append(snapshots, b.build(timestamp, source))
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if !strings.Contains(line, ",") {
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			t, err := time.Parse(time.RFC3339, line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %q is not an RFC3339 timestamp", lineNumber, line)
+			}
+			timestamp, rows, startLine = t, rows[:0], lineNumber
+			continue
+		}
+
+		if timestamp.IsZero() {
+			return nil, fmt.Errorf("line %d: hiscore line before the first timestamp", lineNumber)
+		}
+		row, err := parseHiscoreLine(line, len(rows) < len(snapshot.AllSkillActivityTypes))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// parseHiscoreLine parses rank,level,experience for a skill or rank,score for anything else.
+func parseHiscoreLine(line string, skill bool) ([]int, error) {
+	fields := strings.Split(line, ",")
+	want := 2
+	if skill {
+		want = 3
+	}
+	if len(fields) != want {
+		return nil, fmt.Errorf("%q has %d fields, want %d", line, len(fields), want)
+	}
+
+	values := make([]int, len(fields))
+	for i, field := range fields {
+		value, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", line, err)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
@@ -0,0 +1,232 @@
+package history_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/history"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/snapshot"
+	"github.com/ctfloyd/hazelmere-api/src/internal/dependency/wom"
+	"github.com/ctfloyd/hazelmere-api/src/internal/dependency/wom/womtest"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_logger"
+)
+
+var (
+	allTime = time.Unix(0, 0)
+	farAway = time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// assertComplete checks a snapshot has every activity type, as sources promise.
+func assertComplete(t *testing.T, snap snapshot.HiscoreSnapshot) {
+	t.Helper()
+	if err := snapshot.NewSnapshotValidator().ValidateSnapshot(snapshot.HiscoreSnapshot{
+		UserId: "user", Timestamp: snap.Timestamp, Skills: snap.Skills, Bosses: snap.Bosses, Activities: snap.Activities,
+	}); err != nil {
+		t.Errorf("snapshot at %v is incomplete: %v", snap.Timestamp, err)
+	}
+}
+
+func TestWomHistorySource(t *testing.T) {
+	ctx := context.Background()
+	fixture := womtest.Fixture(t, "hazel_tester")
+	server := womtest.NewServer(t, fixture)
+	source := history.NewWomHistorySource(wom.NewClient(hz_logger.NewZeroLogAdapater(hz_logger.LogLevelError), server.Config()))
+
+	firstSeen, err := source.FirstSeen(ctx, fixture.Username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !firstSeen.Equal(fixture.RegisteredAt) {
+		t.Errorf("first seen = %v, want %v", firstSeen, fixture.RegisteredAt)
+	}
+
+	snapshots, err := source.Snapshots(ctx, fixture.Username, allTime, farAway)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != len(fixture.Snapshots) {
+		t.Fatalf("got %d snapshots, want %d", len(snapshots), len(fixture.Snapshots))
+	}
+	for i, snap := range snapshots {
+		assertComplete(t, snap)
+		if snap.Source != "WOM" {
+			t.Errorf("source = %q, want WOM", snap.Source)
+		}
+		if i > 0 && snap.Timestamp.Before(snapshots[i-1].Timestamp) {
+			t.Errorf("snapshots are not oldest first")
+		}
+	}
+
+	last := snapshots[len(snapshots)-1]
+	if got := last.GetSkill(snapshot.ActivityTypeRunecraft).Experience; got != fixture.Snapshots[3].Data.Skills["runecrafting"].Experience {
+		t.Errorf("runecraft experience = %d, want the fixture's runecrafting", got)
+	}
+	if got := last.GetBoss(snapshot.ActivityTypeZulrah).KillCount; got != 7 {
+		t.Errorf("zulrah kills = %d, want 7", got)
+	}
+	if got := last.GetSkill(snapshot.ActivityTypeSailing).Experience; got != -1 {
+		t.Errorf("sailing experience = %d, want -1 when WOM doesn't have it", got)
+	}
+
+	_, err = source.FirstSeen(ctx, "nobody")
+	if !errors.Is(err, history.ErrPlayerNotFound) {
+		t.Errorf("err = %v, want ErrPlayerNotFound", err)
+	}
+}
+
+func TestTempleFileHistorySource(t *testing.T) {
+	ctx := context.Background()
+	path := writeFile(t, "export.json", `{
+		"player": "Hazel_Tester",
+		"data": [
+			{"date": "2025-01-02 08:00:00", "Overall": 1200, "Overall_level": 40, "Overall_rank": 900, "Runecrafting": 50, "Zulrah": 3, "Zulrah_rank": 100, "Clue_all": 4, "Ehp": 1.5},
+			{"date": "2025-01-01T08:00:00Z", "Overall": 1000, "Overall_level": 38, "Overall_rank": 1000},
+			{"date": 1736064000, "Overall": 1500.0}
+		]
+	}`)
+
+	source, err := history.NewFileHistorySource(path, history.FileFormatTemple)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source.Name() != "TEMPLE:export.json" {
+		t.Errorf("name = %q, want TEMPLE:export.json", source.Name())
+	}
+
+	firstSeen, err := source.FirstSeen(ctx, "hazel tester")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2025, 1, 1, 8, 0, 0, 0, time.UTC); !firstSeen.Equal(want) {
+		t.Errorf("first seen = %v, want %v", firstSeen, want)
+	}
+
+	snapshots, err := source.Snapshots(ctx, "Hazel Tester", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 {
+		t.Fatalf("got %d snapshots, want 2 inside the range", len(snapshots))
+	}
+	for _, snap := range snapshots {
+		assertComplete(t, snap)
+		if snap.Source != "TEMPLE:export.json" {
+			t.Errorf("source = %q, want TEMPLE:export.json", snap.Source)
+		}
+	}
+
+	second := snapshots[1]
+	overall := second.GetSkill(snapshot.ActivityTypeOverall)
+	if overall.Experience != 1200 || overall.Level != 40 || overall.Rank != 900 {
+		t.Errorf("overall = %+v, want 1200 experience at level 40 rank 900", overall)
+	}
+	if runecraft := second.GetSkill(snapshot.ActivityTypeRunecraft); runecraft.Experience != 50 || runecraft.Level != -1 {
+		t.Errorf("runecraft = %+v, want 50 experience and no level", runecraft)
+	}
+	if zulrah := second.GetBoss(snapshot.ActivityTypeZulrah); zulrah.KillCount != 3 || zulrah.Rank != 100 {
+		t.Errorf("zulrah = %+v, want 3 kills at rank 100", zulrah)
+	}
+	if clues := second.GetActivity(snapshot.ActivityTypeClueScrollsall); clues.Score != 4 {
+		t.Errorf("clues = %+v, want a score of 4", clues)
+	}
+
+	if _, err := source.Snapshots(ctx, "someone else", allTime, farAway); !errors.Is(err, history.ErrPlayerNotFound) {
+		t.Errorf("err = %v, want ErrPlayerNotFound for another player", err)
+	}
+}
+
+// hiscoreLines renders a snapshot in hiscore lite order where every skill has experience and
+// every other line has score.
+func hiscoreLines(experience, score int) string {
+	var lines []string
+	for range snapshot.AllSkillActivityTypes {
+		lines = append(lines, fmt.Sprintf("10,50,%d", experience))
+	}
+	for range len(snapshot.AllActivityActivityTypes) + len(snapshot.AllBossActivityTypes) {
+		lines = append(lines, fmt.Sprintf("20,%d", score))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestCSVFileHistorySource(t *testing.T) {
+	ctx := context.Background()
+	path := writeFile(t, "hiscores.csv", "# exported by hand\n"+
+		"2025-01-01T08:00:00Z\n"+hiscoreLines(1000, 1)+"\n\n"+
+		"2025-01-02T08:00:00Z\n"+hiscoreLines(2000, 2)+"\n")
+
+	source, err := history.NewFileHistorySource(path, history.FileFormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots, err := source.Snapshots(ctx, "anyone", allTime, farAway)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 {
+		t.Fatalf("got %d snapshots, want 2", len(snapshots))
+	}
+
+	snap := snapshots[1]
+	assertComplete(t, snap)
+	if snap.Source != "CSV:hiscores.csv" {
+		t.Errorf("source = %q, want CSV:hiscores.csv", snap.Source)
+	}
+	if skill := snap.GetSkill(snapshot.ActivityTypeSailing); skill.Experience != 2000 || skill.Level != 50 || skill.Rank != 10 {
+		t.Errorf("sailing = %+v, want 2000 experience at level 50 rank 10", skill)
+	}
+	if boss := snap.GetBoss(snapshot.ActivityTypeZulrah); boss.KillCount != 2 || boss.Rank != 20 {
+		t.Errorf("zulrah = %+v, want 2 kills at rank 20", boss)
+	}
+	if activity := snap.GetActivity(snapshot.ActivityTypeLeaguePoints); activity.Score != 2 {
+		t.Errorf("league points = %+v, want a score of 2", activity)
+	}
+}
+
+func TestCSVFileHistorySourceRejectsMalformedFiles(t *testing.T) {
+	lines := hiscoreLines(1000, 1)
+	for name, content := range map[string]string{
+		"LinesBeforeTimestamp": lines,
+		"MissingLines":         "2025-01-01T08:00:00Z\n" + lines[:strings.LastIndex(lines, "\n")],
+		"BadTimestamp":         "yesterday\n" + lines,
+		"SkillWithTwoFields":   "2025-01-01T08:00:00Z\n10,50\n",
+		"NotANumber":           "2025-01-01T08:00:00Z\n10,fifty,1000\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := history.NewFileHistorySource(writeFile(t, "bad.csv", content), history.FileFormatCSV)
+			if !errors.Is(err, history.ErrHistoryGeneric) {
+				t.Errorf("err = %v, want ErrHistoryGeneric", err)
+			}
+		})
+	}
+}
+
+func TestFileFormatFromPath(t *testing.T) {
+	for path, want := range map[string]history.FileFormat{
+		"export.json":     history.FileFormatTemple,
+		"dir/HISCORE.CSV": history.FileFormatCSV,
+		"lines.txt":       history.FileFormatCSV,
+	} {
+		if got, ok := history.FileFormatFromPath(path); !ok || got != want {
+			t.Errorf("FileFormatFromPath(%q) = %q, %v, want %q", path, got, ok, want)
+		}
+	}
+	if _, ok := history.FileFormatFromPath("export.xml"); ok {
+		t.Error("FileFormatFromPath(export.xml) recognised the format")
+	}
+}
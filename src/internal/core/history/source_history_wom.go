@@ -0,0 +1,73 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/snapshot"
+	"github.com/ctfloyd/hazelmere-api/src/internal/dependency/wom"
+)
+
+const womSourceName = "WOM"
+
+type womHistorySource struct {
+	client *wom.Client
+}
+
+// NewWomHistorySource reads history from Wise Old Man.
+func NewWomHistorySource(client *wom.Client) HistorySource {
+	return &womHistorySource{client: client}
+}
+
+func (s *womHistorySource) Name() string {
+	return womSourceName
+}
+
+func (s *womHistorySource) FirstSeen(ctx context.Context, player string) (time.Time, error) {
+	details, err := s.client.GetPlayerDetails(ctx, player)
+	if err != nil {
+		return time.Time{}, mapWomError(err)
+	}
+	return details.RegisteredAt.UTC(), nil
+}
+
+func (s *womHistorySource) Snapshots(ctx context.Context, player string, start, end time.Time) ([]snapshot.HiscoreSnapshot, error) {
+	womSnapshots, err := s.client.GetPlayerSnapshots(ctx, player, start, end)
+	if err != nil {
+		return nil, mapWomError(err)
+	}
+
+	snapshots := make([]snapshot.HiscoreSnapshot, 0, len(womSnapshots))
+	for _, ws := range womSnapshots {
+		snapshots = append(snapshots, convertWomSnapshot(ws))
+	}
+	return inRange(snapshots, start, end), nil
+}
+
+func mapWomError(err error) error {
+	if errors.Is(err, wom.ErrPlayerNotFound) {
+		return errors.Join(ErrPlayerNotFound, err)
+	}
+	return errors.Join(ErrHistoryGeneric, err)
+}
+
+func convertWomSnapshot(ws wom.Snapshot) snapshot.HiscoreSnapshot {
+	b := newBuilder()
+	for metric, skill := range ws.Data.Skills {
+		if activityType, ok := lookupMetric(metric); ok {
+			b.skill(activityType, skill.Level, skill.Experience, skill.Rank)
+		}
+	}
+	for metric, boss := range ws.Data.Bosses {
+		if activityType, ok := lookupMetric(metric); ok {
+			b.boss(activityType, boss.Kills, boss.Rank)
+		}
+	}
+	for metric, activity := range ws.Data.Activities {
+		if activityType, ok := lookupMetric(metric); ok {
+			b.activity(activityType, activity.Score, activity.Rank)
+		}
+	}
+	return b.build(ws.CreatedAt, womSourceName)
+}
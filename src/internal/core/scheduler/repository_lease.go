@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type LeaseRepository interface {
+	// TryAcquire takes or renews the named lease for holder until now+ttl. It reports false,
+	// without an error, while another holder has an unexpired lease.
+	TryAcquire(ctx context.Context, name string, holder string, now time.Time, ttl time.Duration) (bool, error)
+	// Release gives up the lease if holder still has it.
+	Release(ctx context.Context, name string, holder string) error
+}
+
+type mongoLeaseRepository struct {
+	monitor    *monitor.Monitor
+	collection *mongo.Collection
+}
+
+func NewLeaseRepository(leaseCollection *mongo.Collection, mon *monitor.Monitor) LeaseRepository {
+	return &mongoLeaseRepository{
+		collection: leaseCollection,
+		monitor:    mon,
+	}
+}
+
+// TryAcquire upserts the lease when it is free, expired or already held by holder. When another
+// holder has it the filter matches nothing, and the upsert fails on the duplicate _id.
+func (lr *mongoLeaseRepository) TryAcquire(ctx context.Context, name string, holder string, now time.Time, ttl time.Duration) (bool, error) {
+	ctx, span := lr.monitor.StartSpan(ctx, "mongoLeaseRepository.TryAcquire")
+	defer span.End()
+
+	filter := bson.M{"_id": name, "$or": bson.A{
+		bson.M{"holder": holder},
+		bson.M{"expiresAt": bson.M{"$lte": now}},
+	}}
+	update := bson.M{"$set": bson.M{"holder": holder, "expiresAt": now.Add(ttl)}}
+
+	_, err := lr.collection.UpdateOne(ctx, filter, update, options.UpdateOne().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Join(database.ErrGeneric, err)
+	}
+	return true, nil
+}
+
+func (lr *mongoLeaseRepository) Release(ctx context.Context, name string, holder string) error {
+	ctx, span := lr.monitor.StartSpan(ctx, "mongoLeaseRepository.Release")
+	defer span.End()
+
+	_, err := lr.collection.DeleteOne(ctx, bson.M{"_id": name, "holder": holder})
+	if err != nil {
+		return errors.Join(database.ErrGeneric, err)
+	}
+	return nil
+}
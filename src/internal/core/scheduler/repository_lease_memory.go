@@ -0,0 +1,50 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+)
+
+type memoryLeaseRepository struct {
+	monitor *monitor.Monitor
+	mu      sync.Mutex
+	leases  map[string]LeaseData
+}
+
+func NewMemoryLeaseRepository(mon *monitor.Monitor) LeaseRepository {
+	return &memoryLeaseRepository{
+		monitor: mon,
+		leases:  make(map[string]LeaseData),
+	}
+}
+
+func (lr *memoryLeaseRepository) TryAcquire(ctx context.Context, name string, holder string, now time.Time, ttl time.Duration) (bool, error) {
+	ctx, span := lr.monitor.StartSpan(ctx, "memoryLeaseRepository.TryAcquire")
+	defer span.End()
+
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	lease, ok := lr.leases[name]
+	if ok && lease.Holder != holder && lease.ExpiresAt.After(now) {
+		return false, nil
+	}
+	lr.leases[name] = LeaseData{Name: name, Holder: holder, ExpiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+func (lr *memoryLeaseRepository) Release(ctx context.Context, name string, holder string) error {
+	ctx, span := lr.monitor.StartSpan(ctx, "memoryLeaseRepository.Release")
+	defer span.End()
+
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	if lr.leases[name].Holder == holder {
+		delete(lr.leases, name)
+	}
+	return nil
+}
@@ -0,0 +1,296 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/delta"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/snapshot"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/user"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/worker"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+)
+
+// LeaseName is the lease document held by the replica that schedules snapshots.
+const LeaseName = "tracking-scheduler"
+
+type Config struct {
+	// Tick is how often due users are looked for and the lease is renewed.
+	Tick time.Duration
+	// LeaseTtl must be a few ticks long, so a leader keeps its lease between renewals.
+	LeaseTtl time.Duration
+	// Concurrency caps the snapshots taken at once. Users due while it is reached wait for a
+	// later tick.
+	Concurrency int
+	// Jitter spreads every interval by up to this fraction either way, e.g. 0.1 for ±10%.
+	Jitter    float64
+	Intervals Intervals
+}
+
+// Intervals picks how often a user is snapshotted from their latest delta, which is only
+// recorded when something on their hiscores changed.
+type Intervals struct {
+	// Active applies to users with a delta within ActiveWithin.
+	Active       time.Duration
+	ActiveWithin time.Duration
+	// Inactive applies to users without a delta within InactiveAfter, or without any.
+	Inactive      time.Duration
+	InactiveAfter time.Duration
+	// Default applies to everyone else.
+	Default time.Duration
+}
+
+// For returns the interval of a user last active at lastActive, zero if they never were.
+func (i Intervals) For(lastActive time.Time, now time.Time) time.Duration {
+	if lastActive.IsZero() {
+		return i.Inactive
+	}
+	since := now.Sub(lastActive)
+	switch {
+	case since <= i.ActiveWithin:
+		return i.Active
+	case since > i.InactiveAfter:
+		return i.Inactive
+	default:
+		return i.Default
+	}
+}
+
+var DefaultConfig = Config{
+	Tick:        30 * time.Second,
+	LeaseTtl:    90 * time.Second,
+	Concurrency: 4,
+	Jitter:      0.1,
+	Intervals: Intervals{
+		Active:        30 * time.Minute,
+		ActiveWithin:  24 * time.Hour,
+		Default:       3 * time.Hour,
+		Inactive:      24 * time.Hour,
+		InactiveAfter: 7 * 24 * time.Hour,
+	},
+}
+
+// Scheduler snapshots users with tracking enabled on their own intervals. Every replica can run
+// one; only the replica holding the lease schedules, and another takes over once it expires.
+type Scheduler struct {
+	monitor         *monitor.Monitor
+	config          Config
+	holder          string
+	leases          LeaseRepository
+	userRepository  user.UserRepository
+	snapshotService snapshot.SnapshotService
+	deltaService    delta.DeltaService
+	workerService   worker.WorkerService
+	slots           chan struct{}
+	now             func() time.Time
+
+	mu      sync.Mutex
+	leader  bool
+	next    map[string]time.Time
+	running map[string]bool
+	wg      sync.WaitGroup
+}
+
+// NewScheduler creates a scheduler that holds the lease as holder, which must be unique to
+// this replica.
+func NewScheduler(mon *monitor.Monitor, config Config, holder string, leases LeaseRepository, userRepository user.UserRepository, snapshotService snapshot.SnapshotService, deltaService delta.DeltaService, workerService worker.WorkerService) *Scheduler {
+	return &Scheduler{
+		monitor:         mon,
+		config:          config,
+		holder:          holder,
+		leases:          leases,
+		userRepository:  userRepository,
+		snapshotService: snapshotService,
+		deltaService:    deltaService,
+		workerService:   workerService,
+		slots:           make(chan struct{}, max(config.Concurrency, 1)),
+		now:             time.Now,
+		next:            make(map[string]time.Time),
+		running:         make(map[string]bool),
+	}
+}
+
+// Run schedules until ctx is done, then waits for snapshots in progress and releases the lease.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Tick)
+	defer ticker.Stop()
+
+	for {
+		s.Tick(ctx)
+
+		select {
+		case <-ctx.Done():
+			s.wg.Wait()
+			s.stepDown(context.WithoutCancel(ctx))
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick renews the lease and, while this replica leads, starts snapshots of the users that are
+// due. It does not wait for them.
+func (s *Scheduler) Tick(ctx context.Context) {
+	ctx, span := s.monitor.StartSpan(ctx, "Scheduler.Tick")
+	defer span.End()
+
+	if !s.lead(ctx) {
+		return
+	}
+
+	users, err := s.userRepository.GetUsersWithTrackingEnabled(ctx)
+	if err != nil {
+		s.monitor.Logger().ErrorArgs(ctx, "Failed to list users with tracking enabled: %v", err)
+		return
+	}
+
+	now := s.now()
+	for _, userId := range s.due(ctx, users, now) {
+		select {
+		case s.slots <- struct{}{}:
+		default:
+			s.monitor.Logger().DebugArgs(ctx, "Scheduler is at its concurrency cap; deferring due users to the next tick")
+			return
+		}
+
+		s.mu.Lock()
+		s.running[userId] = true
+		s.mu.Unlock()
+
+		s.wg.Go(func() {
+			defer func() { <-s.slots }()
+			s.snapshot(context.WithoutCancel(ctx), userId)
+		})
+	}
+}
+
+// lead takes or renews the lease, and reports whether this replica may schedule.
+func (s *Scheduler) lead(ctx context.Context) bool {
+	acquired, err := s.leases.TryAcquire(ctx, LeaseName, s.holder, s.now(), s.config.LeaseTtl)
+	if err != nil {
+		s.monitor.Logger().ErrorArgs(ctx, "Failed to renew the scheduler lease: %v", err)
+		acquired = false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if acquired && !s.leader {
+		s.monitor.Logger().InfoArgs(ctx, "Scheduler %s is now scheduling tracked users", s.holder)
+	} else if !acquired && s.leader {
+		s.monitor.Logger().WarnArgs(ctx, "Scheduler %s lost its lease and stopped scheduling", s.holder)
+		// Another replica schedules meanwhile, so the known due times go stale.
+		clear(s.next)
+	}
+	s.leader = acquired
+	return acquired
+}
+
+func (s *Scheduler) stepDown(ctx context.Context) {
+	s.mu.Lock()
+	leader := s.leader
+	s.leader = false
+	s.mu.Unlock()
+
+	if !leader {
+		return
+	}
+	if err := s.leases.Release(ctx, LeaseName, s.holder); err != nil {
+		s.monitor.Logger().WarnArgs(ctx, "Failed to release the scheduler lease: %v", err)
+	}
+}
+
+// due returns the users to snapshot now, oldest due first. Users seen for the first time are
+// due an interval after their latest snapshot, so taking over from another replica or an
+// external scheduler does not snapshot everyone at once.
+func (s *Scheduler) due(ctx context.Context, users []user.UserData, now time.Time) []string {
+	tracked := make(map[string]bool, len(users))
+	var due []string
+	for _, u := range users {
+		tracked[u.Id] = true
+
+		s.mu.Lock()
+		next, known := s.next[u.Id]
+		running := s.running[u.Id]
+		s.mu.Unlock()
+
+		if running {
+			continue
+		}
+		if !known {
+			next = s.firstRun(ctx, u.Id, now)
+			s.mu.Lock()
+			s.next[u.Id] = next
+			s.mu.Unlock()
+		}
+		if !next.After(now) {
+			due = append(due, u.Id)
+		}
+	}
+
+	s.mu.Lock()
+	for userId := range s.next {
+		if !tracked[userId] {
+			delete(s.next, userId)
+		}
+	}
+	slices.SortFunc(due, func(a, b string) int { return s.next[a].Compare(s.next[b]) })
+	s.mu.Unlock()
+	return due
+}
+
+func (s *Scheduler) firstRun(ctx context.Context, userId string, now time.Time) time.Time {
+	latest, err := s.snapshotService.GetLatestSnapshotForUser(ctx, userId)
+	if err != nil {
+		if !errors.Is(err, snapshot.ErrSnapshotNotFound) {
+			s.monitor.Logger().WarnArgs(ctx, "Failed to get the latest snapshot of user %s: %v", userId, err)
+		}
+		return now
+	}
+	return latest.Timestamp.Add(s.jitter(s.interval(ctx, userId, now)))
+}
+
+func (s *Scheduler) snapshot(ctx context.Context, userId string) {
+	ctx, span := s.monitor.StartSpan(ctx, "Scheduler.snapshot")
+	defer span.End()
+
+	result, err := s.workerService.GenerateSnapshotOnDemand(ctx, userId)
+	if err != nil {
+		s.monitor.Logger().WarnArgs(ctx, "Scheduled snapshot of user %s failed: %v", userId, err)
+	} else if result.Reused {
+		s.monitor.Logger().DebugArgs(ctx, "Scheduled snapshot of user %s reused snapshot %s", userId, result.Snapshot.Id)
+	}
+
+	now := s.now()
+	next := now.Add(s.jitter(s.interval(ctx, userId, now)))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, userId)
+	if s.leader {
+		s.next[userId] = next
+	}
+}
+
+func (s *Scheduler) interval(ctx context.Context, userId string, now time.Time) time.Duration {
+	var lastActive time.Time
+	latest, err := s.deltaService.GetLatestDeltaForUser(ctx, userId)
+	if err == nil {
+		lastActive = latest.Timestamp
+	} else if !errors.Is(err, delta.ErrDeltaNotFound) {
+		s.monitor.Logger().WarnArgs(ctx, "Failed to get the latest delta of user %s: %v", userId, err)
+		return s.config.Intervals.Default
+	}
+	return s.config.Intervals.For(lastActive, now)
+}
+
+func (s *Scheduler) jitter(d time.Duration) time.Duration {
+	if s.config.Jitter <= 0 {
+		return d
+	}
+	return time.Duration(float64(d) * (1 + s.config.Jitter*(2*rand.Float64()-1)))
+}
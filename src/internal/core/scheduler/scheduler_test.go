@@ -0,0 +1,176 @@
+package scheduler
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/delta"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/snapshot"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/user"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/worker"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_logger"
+)
+
+// fakeWorkerService records the users it is asked to snapshot. Calls block until release is
+// closed.
+type fakeWorkerService struct {
+	mu      sync.Mutex
+	calls   []string
+	release chan struct{}
+}
+
+func (f *fakeWorkerService) GenerateSnapshotOnDemand(ctx context.Context, userId string) (worker.OnDemandResult, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, userId)
+	f.mu.Unlock()
+	<-f.release
+	return worker.OnDemandResult{}, nil
+}
+
+func (f *fakeWorkerService) called() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.calls)
+}
+
+type schedulerFixture struct {
+	mon       *monitor.Monitor
+	users     user.UserRepository
+	snapshots snapshot.SnapshotService
+	deltas    delta.DeltaService
+	leases    LeaseRepository
+	worker    *fakeWorkerService
+}
+
+func newSchedulerFixture(t *testing.T, users ...user.UserData) schedulerFixture {
+	t.Helper()
+	mon := monitor.New(hz_logger.NewZeroLogAdapater(hz_logger.LogLevelError))
+	userRepo := user.NewMemoryUserRepository(mon)
+	for _, u := range users {
+		if _, err := userRepo.CreateUser(context.Background(), u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return schedulerFixture{
+		mon:       mon,
+		users:     userRepo,
+		snapshots: snapshot.NewSnapshotService(mon, snapshot.NewMemorySnapshotRepository(mon), snapshot.NewSnapshotValidator(), userRepo),
+		deltas:    delta.NewDeltaService(mon, delta.NewMemoryDeltaRepository(mon), delta.NewDeltaCache(), userRepo),
+		leases:    NewMemoryLeaseRepository(mon),
+		worker:    &fakeWorkerService{release: make(chan struct{})},
+	}
+}
+
+func (f schedulerFixture) scheduler(holder string, concurrency int) *Scheduler {
+	config := DefaultConfig
+	config.Concurrency = concurrency
+	config.Jitter = 0
+	return NewScheduler(f.mon, config, holder, f.leases, f.users, f.snapshots, f.deltas, f.worker)
+}
+
+func trackedUser(id string, status user.TrackingStatus) user.UserData {
+	return user.UserData{Id: id, RunescapeName: id, TrackingStatus: string(status)}
+}
+
+func TestIntervalsFor(t *testing.T) {
+	intervals := DefaultConfig.Intervals
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		lastActive time.Time
+		want       time.Duration
+	}{
+		{"never active", time.Time{}, intervals.Inactive},
+		{"active an hour ago", now.Add(-time.Hour), intervals.Active},
+		{"active three days ago", now.Add(-72 * time.Hour), intervals.Default},
+		{"active a month ago", now.AddDate(0, -1, 0), intervals.Inactive},
+	}
+	for _, tt := range tests {
+		if got := intervals.For(tt.lastActive, now); got != tt.want {
+			t.Errorf("%s: interval %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSchedulerCapsConcurrencyAndSkipsUntrackedUsers(t *testing.T) {
+	ctx := context.Background()
+	f := newSchedulerFixture(t,
+		trackedUser("a", user.TrackingStatusEnabled),
+		trackedUser("b", user.TrackingStatusEnabled),
+		trackedUser("c", user.TrackingStatusEnabled),
+		trackedUser("untracked", user.TrackingStatusDisabled),
+	)
+	s := f.scheduler("replica-1", 2)
+
+	// Users without snapshots are due at once, but only two run at a time.
+	s.Tick(ctx)
+	s.mu.Lock()
+	running := len(s.running)
+	s.mu.Unlock()
+	if running != 2 {
+		t.Fatalf("%d snapshots running, want 2", running)
+	}
+	close(f.worker.release)
+	s.wg.Wait()
+
+	s.Tick(ctx)
+	s.wg.Wait()
+	calls := f.worker.called()
+	slices.Sort(calls)
+	if !slices.Equal(calls, []string{"a", "b", "c"}) {
+		t.Fatalf("snapshotted %v, want a, b and c", calls)
+	}
+
+	// Everyone now waits out their interval.
+	s.Tick(ctx)
+	s.wg.Wait()
+	if calls := f.worker.called(); len(calls) != 3 {
+		t.Errorf("snapshotted %v again before their interval", calls[3:])
+	}
+}
+
+func TestSchedulerOnlySchedulesWhileHoldingTheLease(t *testing.T) {
+	ctx := context.Background()
+	f := newSchedulerFixture(t, trackedUser("a", user.TrackingStatusEnabled))
+	close(f.worker.release)
+
+	now := time.Now()
+	leader := f.scheduler("replica-1", 1)
+	follower := f.scheduler("replica-2", 1)
+	leader.now = func() time.Time { return now }
+	follower.now = func() time.Time { return now }
+
+	leader.Tick(ctx)
+	leader.wg.Wait()
+	follower.Tick(ctx)
+	follower.wg.Wait()
+	if calls := f.worker.called(); len(calls) != 1 {
+		t.Fatalf("user was snapshotted %d times, want once by the leader", len(calls))
+	}
+	if follower.leader {
+		t.Fatal("follower took the lease while the leader held it")
+	}
+
+	// The leader stops renewing, e.g. because it crashed, and the follower takes over.
+	now = now.Add(DefaultConfig.LeaseTtl + time.Second)
+	follower.Tick(ctx)
+	if !follower.leader {
+		t.Fatal("follower did not take over an expired lease")
+	}
+	leader.Tick(ctx)
+	if leader.leader {
+		t.Error("old leader kept scheduling after losing its lease")
+	}
+
+	// Stepping down hands the lease over at once.
+	follower.stepDown(ctx)
+	leader.Tick(ctx)
+	if !leader.leader {
+		t.Error("lease was not released when the follower stepped down")
+	}
+}
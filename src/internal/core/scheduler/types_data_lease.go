@@ -0,0 +1,9 @@
+package scheduler
+
+import "time"
+
+type LeaseData struct {
+	Name      string    `bson:"_id"`
+	Holder    string    `bson:"holder"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
@@ -30,6 +30,13 @@ type SnapshotRepository interface {
 	InsertSnapshot(ctx context.Context, snapshot HiscoreSnapshotData) (HiscoreSnapshotData, error)
 	GetSnapshotForUserNearestTimestamp(ctx context.Context, userId string, timestamp time.Time) (HiscoreSnapshotData, error)
 	GetOldestSnapshotForUser(ctx context.Context, userId string) (HiscoreSnapshotData, error)
+	GetSnapshotBeforeForUser(ctx context.Context, userId string, timestamp time.Time) (HiscoreSnapshotData, error)
+	GetSnapshotAfterForUser(ctx context.Context, userId string, timestamp time.Time) (HiscoreSnapshotData, error)
+	UpdateOverallExperienceChange(ctx context.Context, id string, change int) error
+	// ReassignSnapshots moves the snapshots with the given ids to userId and returns how many moved.
+	ReassignSnapshots(ctx context.Context, ids []string, userId string) (int64, error)
+	DeleteSnapshots(ctx context.Context, ids []string) (int64, error)
+	DeleteSnapshotsForUser(ctx context.Context, userId string) (int64, error)
 }
 
 type mongoSnapshotRepository struct {
@@ -433,3 +440,100 @@ func (sr *mongoSnapshotRepository) GetOldestSnapshotForUser(ctx context.Context,
 
 	return snapshot, nil
 }
+
+// GetSnapshotBeforeForUser returns the latest snapshot strictly before timestamp.
+func (sr *mongoSnapshotRepository) GetSnapshotBeforeForUser(ctx context.Context, userId string, timestamp time.Time) (HiscoreSnapshotData, error) {
+	ctx, span := sr.monitor.StartSpan(ctx, "mongoSnapshotRepository.GetSnapshotBeforeForUser")
+	defer span.End()
+
+	sort := options.FindOne().SetSort(bson.D{{Key: "timestamp", Value: -1}})
+	filter := bson.M{"userId": userId, "timestamp": bson.M{"$lt": timestamp}}
+	return sr.findOne(ctx, filter, sort)
+}
+
+// GetSnapshotAfterForUser returns the earliest snapshot strictly after timestamp.
+func (sr *mongoSnapshotRepository) GetSnapshotAfterForUser(ctx context.Context, userId string, timestamp time.Time) (HiscoreSnapshotData, error) {
+	ctx, span := sr.monitor.StartSpan(ctx, "mongoSnapshotRepository.GetSnapshotAfterForUser")
+	defer span.End()
+
+	sort := options.FindOne().SetSort(bson.D{{Key: "timestamp", Value: 1}})
+	filter := bson.M{"userId": userId, "timestamp": bson.M{"$gt": timestamp}}
+	return sr.findOne(ctx, filter, sort)
+}
+
+func (sr *mongoSnapshotRepository) findOne(ctx context.Context, filter bson.M, opts *options.FindOneOptionsBuilder) (HiscoreSnapshotData, error) {
+	result := sr.collection.FindOne(ctx, filter, opts)
+	if result.Err() != nil {
+		if errors.Is(result.Err(), mongo.ErrNoDocuments) {
+			return HiscoreSnapshotData{}, errors.Join(database.ErrNotFound, result.Err())
+		}
+		return HiscoreSnapshotData{}, errors.Join(database.ErrGeneric, result.Err())
+	}
+
+	var snapshot HiscoreSnapshotData
+	if err := result.Decode(&snapshot); err != nil {
+		return HiscoreSnapshotData{}, errors.Join(database.ErrGeneric, err)
+	}
+	return snapshot, nil
+}
+
+func (sr *mongoSnapshotRepository) UpdateOverallExperienceChange(ctx context.Context, id string, change int) error {
+	ctx, span := sr.monitor.StartSpan(ctx, "mongoSnapshotRepository.UpdateOverallExperienceChange")
+	defer span.End()
+
+	filter := bson.M{"_id": id}
+	update := bson.M{"$set": bson.M{"overallExperienceChange": change}}
+	result, err := sr.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return errors.Join(database.ErrGeneric, err)
+	}
+	if result.MatchedCount == 0 {
+		return database.ErrNotFound
+	}
+	return nil
+}
+
+func (sr *mongoSnapshotRepository) ReassignSnapshots(ctx context.Context, ids []string, userId string) (int64, error) {
+	ctx, span := sr.monitor.StartSpan(ctx, "mongoSnapshotRepository.ReassignSnapshots")
+	defer span.End()
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	filter := bson.M{"_id": bson.M{"$in": ids}}
+	update := bson.M{"$set": bson.M{"userId": userId}}
+	result, err := sr.collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, errors.Join(database.ErrGeneric, err)
+	}
+	return result.ModifiedCount, nil
+}
+
+func (sr *mongoSnapshotRepository) DeleteSnapshots(ctx context.Context, ids []string) (int64, error) {
+	ctx, span := sr.monitor.StartSpan(ctx, "mongoSnapshotRepository.DeleteSnapshots")
+	defer span.End()
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	filter := bson.M{"_id": bson.M{"$in": ids}}
+	result, err := sr.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, errors.Join(database.ErrGeneric, err)
+	}
+	return result.DeletedCount, nil
+}
+
+func (sr *mongoSnapshotRepository) DeleteSnapshotsForUser(ctx context.Context, userId string) (int64, error) {
+	ctx, span := sr.monitor.StartSpan(ctx, "mongoSnapshotRepository.DeleteSnapshotsForUser")
+	defer span.End()
+
+	filter := bson.M{"userId": userId}
+	result, err := sr.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, errors.Join(database.ErrGeneric, err)
+	}
+	return result.DeletedCount, nil
+}
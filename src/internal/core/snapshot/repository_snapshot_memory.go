@@ -0,0 +1,311 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+)
+
+// memorySnapshotRepository keeps snapshots in insertion order and mirrors the sorting, range and
+// aggregation semantics of mongoSnapshotRepository.
+type memorySnapshotRepository struct {
+	monitor   *monitor.Monitor
+	mu        sync.RWMutex
+	snapshots []HiscoreSnapshotData
+}
+
+func NewMemorySnapshotRepository(mon *monitor.Monitor) SnapshotRepository {
+	return &memorySnapshotRepository{
+		monitor: mon,
+	}
+}
+
+func (sr *memorySnapshotRepository) GetSnapshotById(ctx context.Context, id string) (HiscoreSnapshotData, error) {
+	ctx, span := sr.monitor.StartSpan(ctx, "memorySnapshotRepository.GetSnapshotById")
+	defer span.End()
+
+	sr.mu.RLock()
+	defer sr.mu.RUnlock()
+
+	for _, s := range sr.snapshots {
+		if s.Id == id {
+			return s, nil
+		}
+	}
+	return HiscoreSnapshotData{}, database.ErrNotFound
+}
+
+func (sr *memorySnapshotRepository) GetLatestSnapshotForUser(ctx context.Context, userId string) (HiscoreSnapshotData, error) {
+	ctx, span := sr.monitor.StartSpan(ctx, "memorySnapshotRepository.GetLatestSnapshotForUser")
+	defer span.End()
+
+	snapshots := sr.sortedForUser(userId)
+	if len(snapshots) == 0 {
+		return HiscoreSnapshotData{}, database.ErrNotFound
+	}
+	return snapshots[len(snapshots)-1], nil
+}
+
+func (sr *memorySnapshotRepository) GetOldestSnapshotForUser(ctx context.Context, userId string) (HiscoreSnapshotData, error) {
+	ctx, span := sr.monitor.StartSpan(ctx, "memorySnapshotRepository.GetOldestSnapshotForUser")
+	defer span.End()
+
+	snapshots := sr.sortedForUser(userId)
+	if len(snapshots) == 0 {
+		return HiscoreSnapshotData{}, database.ErrNotFound
+	}
+	return snapshots[0], nil
+}
+
+func (sr *memorySnapshotRepository) GetSnapshotInterval(ctx context.Context, userId string, startTime time.Time, endTime time.Time, aggregationWindow api.AggregationWindow) (SnapshotIntervalResult, error) {
+	ctx, span := sr.monitor.StartSpan(ctx, "memorySnapshotRepository.GetSnapshotInterval")
+	defer span.End()
+
+	inRange := inTimeRange(sr.sortedForUser(userId), startTime, endTime)
+
+	result := SnapshotIntervalResult{TotalSnapshots: len(inRange)}
+	best := make(map[string]HiscoreSnapshotData)
+	for _, s := range inRange {
+		if s.OverallExperienceChange > 0 {
+			result.SnapshotsWithGains++
+		}
+		if s.OverallExperienceChange == 0 {
+			continue
+		}
+
+		// Keep the snapshot with the most overall experience in each window, latest first on ties.
+		key := aggregationKey(s.Timestamp, aggregationWindow)
+		current, ok := best[key]
+		if !ok || overallExperience(s) > overallExperience(current) ||
+			(overallExperience(s) == overallExperience(current) && s.Timestamp.After(current.Timestamp)) {
+			best[key] = s
+		}
+	}
+
+	for _, s := range best {
+		result.Snapshots = append(result.Snapshots, s)
+	}
+	slices.SortFunc(result.Snapshots, func(a, b HiscoreSnapshotData) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+
+	return result, nil
+}
+
+func (sr *memorySnapshotRepository) GetSnapshotsInRange(ctx context.Context, userId string, startTime time.Time, endTime time.Time) ([]HiscoreSnapshotData, error) {
+	ctx, span := sr.monitor.StartSpan(ctx, "memorySnapshotRepository.GetSnapshotsInRange")
+	defer span.End()
+
+	return inTimeRange(sr.sortedForUser(userId), startTime, endTime), nil
+}
+
+func (sr *memorySnapshotRepository) GetAllSnapshotsForUser(ctx context.Context, userId string) ([]HiscoreSnapshotData, error) {
+	ctx, span := sr.monitor.StartSpan(ctx, "memorySnapshotRepository.GetAllSnapshotsForUser")
+	defer span.End()
+
+	return sr.forUser(userId), nil
+}
+
+func (sr *memorySnapshotRepository) GetAllTimestampsForUser(ctx context.Context, userId string) ([]HiscoreTimestampData, error) {
+	ctx, span := sr.monitor.StartSpan(ctx, "memorySnapshotRepository.GetAllTimestampsForUser")
+	defer span.End()
+
+	var results []HiscoreTimestampData
+	for _, s := range sr.forUser(userId) {
+		results = append(results, HiscoreTimestampData{Timestamp: s.Timestamp})
+	}
+	return results, nil
+}
+
+func (sr *memorySnapshotRepository) InsertSnapshot(ctx context.Context, snapshot HiscoreSnapshotData) (HiscoreSnapshotData, error) {
+	ctx, span := sr.monitor.StartSpan(ctx, "memorySnapshotRepository.InsertSnapshot")
+	defer span.End()
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	for _, s := range sr.snapshots {
+		if s.Id == snapshot.Id {
+			return HiscoreSnapshotData{}, fmt.Errorf("%w: duplicate snapshot id %s", database.ErrGeneric, snapshot.Id)
+		}
+	}
+	sr.snapshots = append(sr.snapshots, snapshot)
+	return snapshot, nil
+}
+
+func (sr *memorySnapshotRepository) GetSnapshotForUserNearestTimestamp(ctx context.Context, userId string, timestamp time.Time) (HiscoreSnapshotData, error) {
+	ctx, span := sr.monitor.StartSpan(ctx, "memorySnapshotRepository.GetSnapshotForUserNearestTimestamp")
+	defer span.End()
+
+	var lessThan, greaterThan HiscoreSnapshotData
+	for _, s := range sr.sortedForUser(userId) {
+		if !s.Timestamp.After(timestamp) {
+			lessThan = s
+		}
+		if !s.Timestamp.Before(timestamp) && greaterThan.Timestamp.IsZero() {
+			greaterThan = s
+		}
+	}
+
+	if lessThan.Timestamp.IsZero() && greaterThan.Timestamp.IsZero() {
+		return HiscoreSnapshotData{}, database.ErrNotFound
+	}
+	if lessThan.Timestamp.IsZero() {
+		return greaterThan, nil
+	}
+	if greaterThan.Timestamp.IsZero() {
+		return lessThan, nil
+	}
+	if timestamp.Sub(lessThan.Timestamp) < greaterThan.Timestamp.Sub(timestamp) {
+		return lessThan, nil
+	}
+	return greaterThan, nil
+}
+
+func (sr *memorySnapshotRepository) GetSnapshotBeforeForUser(ctx context.Context, userId string, timestamp time.Time) (HiscoreSnapshotData, error) {
+	ctx, span := sr.monitor.StartSpan(ctx, "memorySnapshotRepository.GetSnapshotBeforeForUser")
+	defer span.End()
+
+	snapshots := sr.sortedForUser(userId)
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if snapshots[i].Timestamp.Before(timestamp) {
+			return snapshots[i], nil
+		}
+	}
+	return HiscoreSnapshotData{}, database.ErrNotFound
+}
+
+func (sr *memorySnapshotRepository) GetSnapshotAfterForUser(ctx context.Context, userId string, timestamp time.Time) (HiscoreSnapshotData, error) {
+	ctx, span := sr.monitor.StartSpan(ctx, "memorySnapshotRepository.GetSnapshotAfterForUser")
+	defer span.End()
+
+	for _, s := range sr.sortedForUser(userId) {
+		if s.Timestamp.After(timestamp) {
+			return s, nil
+		}
+	}
+	return HiscoreSnapshotData{}, database.ErrNotFound
+}
+
+func (sr *memorySnapshotRepository) UpdateOverallExperienceChange(ctx context.Context, id string, change int) error {
+	ctx, span := sr.monitor.StartSpan(ctx, "memorySnapshotRepository.UpdateOverallExperienceChange")
+	defer span.End()
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	for i := range sr.snapshots {
+		if sr.snapshots[i].Id == id {
+			sr.snapshots[i].OverallExperienceChange = change
+			return nil
+		}
+	}
+	return database.ErrNotFound
+}
+
+func (sr *memorySnapshotRepository) ReassignSnapshots(ctx context.Context, ids []string, userId string) (int64, error) {
+	ctx, span := sr.monitor.StartSpan(ctx, "memorySnapshotRepository.ReassignSnapshots")
+	defer span.End()
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	var moved int64
+	for i := range sr.snapshots {
+		if slices.Contains(ids, sr.snapshots[i].Id) && sr.snapshots[i].UserId != userId {
+			sr.snapshots[i].UserId = userId
+			moved++
+		}
+	}
+	return moved, nil
+}
+
+func (sr *memorySnapshotRepository) DeleteSnapshots(ctx context.Context, ids []string) (int64, error) {
+	ctx, span := sr.monitor.StartSpan(ctx, "memorySnapshotRepository.DeleteSnapshots")
+	defer span.End()
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	before := len(sr.snapshots)
+	sr.snapshots = slices.DeleteFunc(sr.snapshots, func(s HiscoreSnapshotData) bool {
+		return slices.Contains(ids, s.Id)
+	})
+	return int64(before - len(sr.snapshots)), nil
+}
+
+func (sr *memorySnapshotRepository) DeleteSnapshotsForUser(ctx context.Context, userId string) (int64, error) {
+	ctx, span := sr.monitor.StartSpan(ctx, "memorySnapshotRepository.DeleteSnapshotsForUser")
+	defer span.End()
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	before := len(sr.snapshots)
+	sr.snapshots = slices.DeleteFunc(sr.snapshots, func(s HiscoreSnapshotData) bool {
+		return s.UserId == userId
+	})
+	return int64(before - len(sr.snapshots)), nil
+}
+
+// forUser returns the user's snapshots in insertion order, like an unsorted Mongo find.
+func (sr *memorySnapshotRepository) forUser(userId string) []HiscoreSnapshotData {
+	sr.mu.RLock()
+	defer sr.mu.RUnlock()
+
+	var results []HiscoreSnapshotData
+	for _, s := range sr.snapshots {
+		if s.UserId == userId {
+			results = append(results, s)
+		}
+	}
+	return results
+}
+
+func (sr *memorySnapshotRepository) sortedForUser(userId string) []HiscoreSnapshotData {
+	results := sr.forUser(userId)
+	slices.SortStableFunc(results, func(a, b HiscoreSnapshotData) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+	return results
+}
+
+// inTimeRange filters snapshots sorted by timestamp to those within [startTime, endTime].
+func inTimeRange(snapshots []HiscoreSnapshotData, startTime time.Time, endTime time.Time) []HiscoreSnapshotData {
+	var results []HiscoreSnapshotData
+	for _, s := range snapshots {
+		if !s.Timestamp.Before(startTime) && !s.Timestamp.After(endTime) {
+			results = append(results, s)
+		}
+	}
+	return results
+}
+
+// aggregationKey matches the $dateToString formats used by getDateFormatForAggregationWindow.
+func aggregationKey(timestamp time.Time, window api.AggregationWindow) string {
+	timestamp = timestamp.UTC()
+	switch window {
+	case api.AggregationWindowWeekly:
+		year, week := timestamp.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case api.AggregationWindowMonthly:
+		return timestamp.Format("2006-01")
+	default:
+		return timestamp.Format("2006-01-02")
+	}
+}
+
+func overallExperience(snapshot HiscoreSnapshotData) int {
+	for _, skill := range snapshot.Skills {
+		if skill.ActivityType == string(ActivityTypeOverall) {
+			return skill.Experience
+		}
+	}
+	return 0
+}
@@ -0,0 +1,302 @@
+package snapshot_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/snapshot"
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/internal/database/databasetest"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_logger"
+	"github.com/google/uuid"
+)
+
+var testMonitor = monitor.New(hz_logger.NewZeroLogAdapater(hz_logger.LogLevelError))
+
+func TestMemorySnapshotRepository(t *testing.T) {
+	testSnapshotRepository(t, func(t *testing.T) snapshot.SnapshotRepository {
+		return snapshot.NewMemorySnapshotRepository(testMonitor)
+	})
+}
+
+func TestMongoSnapshotRepository(t *testing.T) {
+	testSnapshotRepository(t, func(t *testing.T) snapshot.SnapshotRepository {
+		return snapshot.NewSnapshotRepository(databasetest.Collection(t, "snapshot"), testMonitor)
+	})
+}
+
+// base is truncated to milliseconds because that is the precision Mongo stores.
+var base = time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+func newSnapshotData(userId string, timestamp time.Time, overall int, change int) snapshot.HiscoreSnapshotData {
+	return snapshot.HiscoreSnapshotData{
+		Id:        uuid.New().String(),
+		UserId:    userId,
+		Timestamp: timestamp,
+		Skills: []snapshot.SkillSnapshotData{
+			{ActivityType: string(api.ActivityTypeOverall), Name: "Overall", Level: 100, Experience: overall, Rank: 1},
+		},
+		Bosses:                  []snapshot.BossSnapshotData{{ActivityType: string(api.ActivityTypeZulrah), Name: "Zulrah", KillCount: 5, Rank: 2}},
+		Activities:              []snapshot.ActivitySnapshotData{{ActivityType: string(api.ActivityTypeClueScrollsall), Name: "Clues", Score: 3, Rank: 4}},
+		OverallExperienceChange: change,
+		Source:                  "test",
+	}
+}
+
+func insertSnapshots(t *testing.T, repo snapshot.SnapshotRepository, snapshots ...snapshot.HiscoreSnapshotData) {
+	t.Helper()
+	for _, s := range snapshots {
+		if _, err := repo.InsertSnapshot(context.Background(), s); err != nil {
+			t.Fatalf("InsertSnapshot: %v", err)
+		}
+	}
+}
+
+func snapshotIds(snapshots []snapshot.HiscoreSnapshotData) []string {
+	ids := make([]string, 0, len(snapshots))
+	for _, s := range snapshots {
+		ids = append(ids, s.Id)
+	}
+	return ids
+}
+
+// testSnapshotRepository is the conformance suite every SnapshotRepository must pass.
+func testSnapshotRepository(t *testing.T, newRepo func(t *testing.T) snapshot.SnapshotRepository) {
+	ctx := context.Background()
+
+	t.Run("GetSnapshotById", func(t *testing.T) {
+		repo := newRepo(t)
+		want := newSnapshotData(uuid.New().String(), base, 100, 0)
+		insertSnapshots(t, repo, want)
+
+		got, err := repo.GetSnapshotById(ctx, want.Id)
+		if err != nil {
+			t.Fatalf("GetSnapshotById: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GetSnapshotById = %+v, want %+v", got, want)
+		}
+
+		if _, err := repo.GetSnapshotById(ctx, uuid.New().String()); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("GetSnapshotById of an unknown id: got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("LatestAndOldest", func(t *testing.T) {
+		repo := newRepo(t)
+		userId := uuid.New().String()
+		middle := newSnapshotData(userId, base, 200, 100)
+		newest := newSnapshotData(userId, base.Add(time.Hour), 300, 100)
+		oldest := newSnapshotData(userId, base.Add(-time.Hour), 100, 0)
+		insertSnapshots(t, repo, middle, newest, oldest, newSnapshotData(uuid.New().String(), base.Add(2*time.Hour), 1, 0))
+
+		latest, err := repo.GetLatestSnapshotForUser(ctx, userId)
+		if err != nil || latest.Id != newest.Id {
+			t.Errorf("GetLatestSnapshotForUser = %s, %v; want %s", latest.Id, err, newest.Id)
+		}
+		first, err := repo.GetOldestSnapshotForUser(ctx, userId)
+		if err != nil || first.Id != oldest.Id {
+			t.Errorf("GetOldestSnapshotForUser = %s, %v; want %s", first.Id, err, oldest.Id)
+		}
+
+		unknown := uuid.New().String()
+		if _, err := repo.GetLatestSnapshotForUser(ctx, unknown); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("GetLatestSnapshotForUser of an unknown user: got %v, want ErrNotFound", err)
+		}
+		if _, err := repo.GetOldestSnapshotForUser(ctx, unknown); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("GetOldestSnapshotForUser of an unknown user: got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("AllForUser", func(t *testing.T) {
+		repo := newRepo(t)
+		userId := uuid.New().String()
+		insertSnapshots(t, repo,
+			newSnapshotData(userId, base, 1, 0),
+			newSnapshotData(userId, base.Add(time.Hour), 2, 1),
+			newSnapshotData(uuid.New().String(), base, 1, 0),
+		)
+
+		all, err := repo.GetAllSnapshotsForUser(ctx, userId)
+		if err != nil || len(all) != 2 {
+			t.Errorf("GetAllSnapshotsForUser returned %d snapshots, %v; want 2", len(all), err)
+		}
+		timestamps, err := repo.GetAllTimestampsForUser(ctx, userId)
+		if err != nil || len(timestamps) != 2 {
+			t.Errorf("GetAllTimestampsForUser returned %d timestamps, %v; want 2", len(timestamps), err)
+		}
+		none, err := repo.GetAllSnapshotsForUser(ctx, uuid.New().String())
+		if err != nil || len(none) != 0 {
+			t.Errorf("GetAllSnapshotsForUser of an unknown user returned %d snapshots, %v; want 0", len(none), err)
+		}
+	})
+
+	t.Run("GetSnapshotsInRange", func(t *testing.T) {
+		repo := newRepo(t)
+		userId := uuid.New().String()
+		before := newSnapshotData(userId, base.Add(-time.Minute), 1, 0)
+		atStart := newSnapshotData(userId, base, 2, 1)
+		inside := newSnapshotData(userId, base.Add(30*time.Minute), 3, 1)
+		atEnd := newSnapshotData(userId, base.Add(time.Hour), 4, 1)
+		after := newSnapshotData(userId, base.Add(time.Hour+time.Minute), 5, 1)
+		insertSnapshots(t, repo, inside, after, atEnd, before, atStart)
+
+		got, err := repo.GetSnapshotsInRange(ctx, userId, base, base.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("GetSnapshotsInRange: %v", err)
+		}
+		want := []string{atStart.Id, inside.Id, atEnd.Id}
+		if !reflect.DeepEqual(snapshotIds(got), want) {
+			t.Errorf("GetSnapshotsInRange = %v, want %v sorted by timestamp with inclusive bounds", snapshotIds(got), want)
+		}
+	})
+
+	t.Run("GetSnapshotForUserNearestTimestamp", func(t *testing.T) {
+		repo := newRepo(t)
+		userId := uuid.New().String()
+		early := newSnapshotData(userId, base, 1, 0)
+		late := newSnapshotData(userId, base.Add(time.Hour), 2, 1)
+		insertSnapshots(t, repo, late, early)
+
+		cases := []struct {
+			name      string
+			timestamp time.Time
+			want      string
+		}{
+			{"before all", base.Add(-time.Hour), early.Id},
+			{"after all", base.Add(2 * time.Hour), late.Id},
+			{"exact", base.Add(time.Hour), late.Id},
+			{"closer to earlier", base.Add(20 * time.Minute), early.Id},
+			{"closer to later", base.Add(40 * time.Minute), late.Id},
+			{"tie prefers later", base.Add(30 * time.Minute), late.Id},
+		}
+		for _, c := range cases {
+			got, err := repo.GetSnapshotForUserNearestTimestamp(ctx, userId, c.timestamp)
+			if err != nil || got.Id != c.want {
+				t.Errorf("%s: GetSnapshotForUserNearestTimestamp = %s, %v; want %s", c.name, got.Id, err, c.want)
+			}
+		}
+
+		if _, err := repo.GetSnapshotForUserNearestTimestamp(ctx, uuid.New().String(), base); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("GetSnapshotForUserNearestTimestamp of an unknown user: got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("BeforeAndAfter", func(t *testing.T) {
+		repo := newRepo(t)
+		userId := uuid.New().String()
+		early := newSnapshotData(userId, base, 1, 0)
+		late := newSnapshotData(userId, base.Add(time.Hour), 2, 1)
+		insertSnapshots(t, repo, late, early)
+
+		if got, err := repo.GetSnapshotBeforeForUser(ctx, userId, base.Add(time.Hour)); err != nil || got.Id != early.Id {
+			t.Errorf("GetSnapshotBeforeForUser = %s, %v; want %s, excluding the exact timestamp", got.Id, err, early.Id)
+		}
+		if got, err := repo.GetSnapshotAfterForUser(ctx, userId, base); err != nil || got.Id != late.Id {
+			t.Errorf("GetSnapshotAfterForUser = %s, %v; want %s, excluding the exact timestamp", got.Id, err, late.Id)
+		}
+		if _, err := repo.GetSnapshotBeforeForUser(ctx, userId, base); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("GetSnapshotBeforeForUser of the first snapshot: got %v, want ErrNotFound", err)
+		}
+		if _, err := repo.GetSnapshotAfterForUser(ctx, userId, base.Add(time.Hour)); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("GetSnapshotAfterForUser of the last snapshot: got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("UpdateOverallExperienceChange", func(t *testing.T) {
+		repo := newRepo(t)
+		s := newSnapshotData(uuid.New().String(), base, 100, 0)
+		insertSnapshots(t, repo, s)
+
+		if err := repo.UpdateOverallExperienceChange(ctx, s.Id, 42); err != nil {
+			t.Fatalf("UpdateOverallExperienceChange: %v", err)
+		}
+		if got, err := repo.GetSnapshotById(ctx, s.Id); err != nil || got.OverallExperienceChange != 42 {
+			t.Errorf("OverallExperienceChange after update = %d, %v; want 42", got.OverallExperienceChange, err)
+		}
+		if err := repo.UpdateOverallExperienceChange(ctx, uuid.New().String(), 1); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("UpdateOverallExperienceChange of an unknown id: got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("ReassignAndDeleteSnapshots", func(t *testing.T) {
+		repo := newRepo(t)
+		from, to := uuid.New().String(), uuid.New().String()
+		first := newSnapshotData(from, base, 100, 0)
+		second := newSnapshotData(from, base.Add(time.Hour), 200, 100)
+		kept := newSnapshotData(to, base.Add(2*time.Hour), 300, 100)
+		insertSnapshots(t, repo, first, second, kept)
+
+		if moved, err := repo.ReassignSnapshots(ctx, []string{first.Id, second.Id}, to); err != nil || moved != 2 {
+			t.Fatalf("ReassignSnapshots moved %d, %v; want 2", moved, err)
+		}
+		if deleted, err := repo.DeleteSnapshots(ctx, []string{second.Id, uuid.New().String()}); err != nil || deleted != 1 {
+			t.Fatalf("DeleteSnapshots deleted %d, %v; want 1", deleted, err)
+		}
+
+		all, err := repo.GetAllSnapshotsForUser(ctx, to)
+		if err != nil || !reflect.DeepEqual(snapshotIds(all), []string{first.Id, kept.Id}) {
+			t.Errorf("GetAllSnapshotsForUser after moving = %v, %v; want [%s %s]", snapshotIds(all), err, first.Id, kept.Id)
+		}
+		if left, err := repo.GetAllSnapshotsForUser(ctx, from); err != nil || len(left) != 0 {
+			t.Errorf("GetAllSnapshotsForUser of the old user = %v, %v; want none", snapshotIds(left), err)
+		}
+	})
+
+	t.Run("DeleteSnapshotsForUser", func(t *testing.T) {
+		repo := newRepo(t)
+		deleted, kept := uuid.New().String(), uuid.New().String()
+		insertSnapshots(t, repo,
+			newSnapshotData(deleted, base, 100, 0),
+			newSnapshotData(deleted, base.Add(time.Hour), 200, 100),
+			newSnapshotData(kept, base, 100, 0),
+		)
+
+		if count, err := repo.DeleteSnapshotsForUser(ctx, deleted); err != nil || count != 2 {
+			t.Fatalf("DeleteSnapshotsForUser deleted %d, %v; want 2", count, err)
+		}
+		if left, err := repo.GetAllSnapshotsForUser(ctx, deleted); err != nil || len(left) != 0 {
+			t.Errorf("GetAllSnapshotsForUser of the deleted user = %v, %v; want none", snapshotIds(left), err)
+		}
+		if left, err := repo.GetAllSnapshotsForUser(ctx, kept); err != nil || len(left) != 1 {
+			t.Errorf("GetAllSnapshotsForUser of another user = %v, %v; want one", snapshotIds(left), err)
+		}
+	})
+
+	t.Run("GetSnapshotInterval", func(t *testing.T) {
+		repo := newRepo(t)
+		userId := uuid.New().String()
+		day := base.Truncate(24 * time.Hour)
+		dayOneLow := newSnapshotData(userId, day.Add(time.Hour), 100, 10)
+		dayOneHigh := newSnapshotData(userId, day.Add(2*time.Hour), 200, 100)
+		dayOneFlat := newSnapshotData(userId, day.Add(3*time.Hour), 200, 0)
+		dayTwoLoss := newSnapshotData(userId, day.Add(25*time.Hour), 150, -50)
+		outside := newSnapshotData(userId, day.Add(72*time.Hour), 500, 350)
+		insertSnapshots(t, repo, dayTwoLoss, dayOneFlat, outside, dayOneHigh, dayOneLow)
+
+		got, err := repo.GetSnapshotInterval(ctx, userId, day, day.Add(48*time.Hour), api.AggregationWindowDaily)
+		if err != nil {
+			t.Fatalf("GetSnapshotInterval: %v", err)
+		}
+		if got.TotalSnapshots != 4 || got.SnapshotsWithGains != 2 {
+			t.Errorf("GetSnapshotInterval counted %d total and %d with gains, want 4 and 2", got.TotalSnapshots, got.SnapshotsWithGains)
+		}
+		want := []string{dayOneHigh.Id, dayTwoLoss.Id}
+		if !reflect.DeepEqual(snapshotIds(got.Snapshots), want) {
+			t.Errorf("GetSnapshotInterval snapshots = %v, want the best changed snapshot per day %v", snapshotIds(got.Snapshots), want)
+		}
+
+		monthly, err := repo.GetSnapshotInterval(ctx, userId, day, day.Add(48*time.Hour), api.AggregationWindowMonthly)
+		if err != nil {
+			t.Fatalf("GetSnapshotInterval: %v", err)
+		}
+		if !reflect.DeepEqual(snapshotIds(monthly.Snapshots), []string{dayOneHigh.Id}) {
+			t.Errorf("monthly GetSnapshotInterval snapshots = %v, want [%s]", snapshotIds(monthly.Snapshots), dayOneHigh.Id)
+		}
+	})
+}
@@ -3,6 +3,7 @@ package snapshot
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/ctfloyd/hazelmere-api/src/internal/core/user"
@@ -27,11 +28,24 @@ type SnapshotIntervalResponse struct {
 
 type SnapshotService interface {
 	CreateSnapshot(ctx context.Context, snapshot HiscoreSnapshot) (HiscoreSnapshot, error)
+	CreateHistoricalSnapshot(ctx context.Context, snapshot HiscoreSnapshot) (HiscoreSnapshot, error)
+	ValidateSnapshot(ctx context.Context, snapshot HiscoreSnapshot) error
+	ValidateUser(ctx context.Context, userId string) error
+	SetOverallExperienceChange(ctx context.Context, id string, change int) error
 	GetSnapshotById(ctx context.Context, id string) (HiscoreSnapshot, error)
 	GetSnapshotInterval(ctx context.Context, userId string, startTime time.Time, endTime time.Time, aggregationWindow api.AggregationWindow) (SnapshotIntervalResponse, error)
 	GetAllSnapshotsForUser(ctx context.Context, userId string) ([]HiscoreSnapshot, error)
 	GetSnapshotForUserNearestTimestamp(ctx context.Context, userId string, timestamp int64) (HiscoreSnapshot, error)
 	GetLatestSnapshotForUser(ctx context.Context, userId string) (HiscoreSnapshot, error)
+	GetSnapshotBeforeForUser(ctx context.Context, userId string, timestamp time.Time) (HiscoreSnapshot, error)
+	GetSnapshotAfterForUser(ctx context.Context, userId string, timestamp time.Time) (HiscoreSnapshot, error)
+	ReassignSnapshots(ctx context.Context, ids []string, userId string) error
+	DeleteSnapshots(ctx context.Context, ids []string) error
+	DeleteSnapshotsForUser(ctx context.Context, userId string) (int64, error)
+	// GetAccountTypeTimeline returns the account type of a user over time, so snapshots can be
+	// read with the type the user had when they were taken. Users that are not tracked have an
+	// empty timeline.
+	GetAccountTypeTimeline(ctx context.Context, userId string) (user.AccountTypeTimeline, error)
 }
 
 type snapshotService struct {
@@ -160,19 +174,71 @@ func (ss *snapshotService) CreateSnapshot(ctx context.Context, snapshot HiscoreS
 	defer span.End()
 
 	snapshot.Id = uuid.New().String()
+	previous, err := ss.repository.GetLatestSnapshotForUser(ctx, snapshot.UserId)
+	return ss.insertSnapshot(ctx, snapshot, previous, err)
+}
+
+// CreateHistoricalSnapshot inserts a snapshot older than the user's latest, taking its experience
+// change from the snapshot just before it rather than the latest one. An id already set on the
+// snapshot is kept, so a retried backfill can write deltas for it before it is inserted.
+func (ss *snapshotService) CreateHistoricalSnapshot(ctx context.Context, snapshot HiscoreSnapshot) (HiscoreSnapshot, error) {
+	ctx, span := ss.monitor.StartSpan(ctx, "snapshotService.CreateHistoricalSnapshot")
+	defer span.End()
+
+	if snapshot.Id == "" {
+		snapshot.Id = uuid.New().String()
+	}
+
+	previous, err := ss.repository.GetSnapshotBeforeForUser(ctx, snapshot.UserId, snapshot.Timestamp)
+	return ss.insertSnapshot(ctx, snapshot, previous, err)
+}
+
+// ValidateSnapshot reports whether snapshot could be created, for callers that must know before
+// writing anything that depends on it. Its user must be able to take snapshots, as ValidateUser
+// checks.
+func (ss *snapshotService) ValidateSnapshot(ctx context.Context, snapshot HiscoreSnapshot) error {
+	if err := ss.validator.ValidateSnapshot(snapshot); err != nil {
+		return errors.Join(ErrSnapshotValidation, err)
+	}
+	return ss.ValidateUser(ctx, snapshot.UserId)
+}
 
-	err := ss.validator.ValidateSnapshot(snapshot)
+// ValidateUser reports whether snapshots of userId would be accepted. Users that do not exist or
+// were deleted take none, so nothing is written under the id of a user deleted for good. Nor do
+// merged users, whose snapshots belong to the user they were merged into, or users being merged,
+// since the merge is rewriting their snapshots and deltas.
+func (ss *snapshotService) ValidateUser(ctx context.Context, userId string) error {
+	data, err := ss.userRepository.GetUserById(ctx, userId)
 	if err != nil {
-		return HiscoreSnapshot{}, errors.Join(ErrSnapshotValidation, err)
+		if errors.Is(err, database.ErrNotFound) {
+			return errors.Join(ErrSnapshotValidation, fmt.Errorf("user %s does not exist", userId))
+		}
+		return errors.Join(ErrSnapshotGeneric, err)
+	}
+	if data.DeletedAt != nil {
+		return errors.Join(ErrSnapshotValidation, errors.New("user was deleted"))
+	}
+	if data.MergedInto != "" {
+		return errors.Join(ErrSnapshotValidation, fmt.Errorf("user was merged into %s", data.MergedInto))
+	}
+	if data.MergingWith != "" {
+		return errors.Join(ErrSnapshotValidation, fmt.Errorf("user is being merged with %s", data.MergingWith))
 	}
+	return nil
+}
 
-	xpChange := 0
+// insertSnapshot validates and inserts snapshot. previous and previousErr are the result of looking
+// up the snapshot its experience change is measured against.
+func (ss *snapshotService) insertSnapshot(ctx context.Context, snapshot HiscoreSnapshot, previous HiscoreSnapshotData, previousErr error) (HiscoreSnapshot, error) {
+	if err := ss.ValidateSnapshot(ctx, snapshot); err != nil {
+		return HiscoreSnapshot{}, err
+	}
 
-	previousSnapshotData, err := ss.repository.GetLatestSnapshotForUser(ctx, snapshot.UserId)
-	if err != nil && !errors.Is(err, database.ErrNotFound) {
-		return HiscoreSnapshot{}, errors.Join(ErrSnapshotGeneric, err)
-	} else if err == nil {
-		previousSnapshot := HiscoreSnapshot{}.FromData(previousSnapshotData)
+	xpChange := 0
+	if previousErr != nil && !errors.Is(previousErr, database.ErrNotFound) {
+		return HiscoreSnapshot{}, errors.Join(ErrSnapshotGeneric, previousErr)
+	} else if previousErr == nil {
+		previousSnapshot := HiscoreSnapshot{}.FromData(previous)
 		xpChange = snapshot.GetSkill(ActivityTypeOverall).Experience - previousSnapshot.GetSkill(ActivityTypeOverall).Experience
 	}
 
@@ -190,6 +256,19 @@ func (ss *snapshotService) CreateSnapshot(ctx context.Context, snapshot HiscoreS
 	return createdSnapshot, nil
 }
 
+func (ss *snapshotService) SetOverallExperienceChange(ctx context.Context, id string, change int) error {
+	ctx, span := ss.monitor.StartSpan(ctx, "snapshotService.SetOverallExperienceChange")
+	defer span.End()
+
+	if err := ss.repository.UpdateOverallExperienceChange(ctx, id, change); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrSnapshotNotFound
+		}
+		return errors.Join(ErrSnapshotGeneric, err)
+	}
+	return nil
+}
+
 func (ss *snapshotService) GetLatestSnapshotForUser(ctx context.Context, userId string) (HiscoreSnapshot, error) {
 	ctx, span := ss.monitor.StartSpan(ctx, "snapshotService.GetLatestSnapshotForUser")
 	defer span.End()
@@ -204,6 +283,67 @@ func (ss *snapshotService) GetLatestSnapshotForUser(ctx context.Context, userId
 	return HiscoreSnapshot{}.FromData(data), nil
 }
 
+func (ss *snapshotService) GetSnapshotBeforeForUser(ctx context.Context, userId string, timestamp time.Time) (HiscoreSnapshot, error) {
+	ctx, span := ss.monitor.StartSpan(ctx, "snapshotService.GetSnapshotBeforeForUser")
+	defer span.End()
+
+	data, err := ss.repository.GetSnapshotBeforeForUser(ctx, userId, timestamp)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return HiscoreSnapshot{}, ErrSnapshotNotFound
+		}
+		return HiscoreSnapshot{}, errors.Join(ErrSnapshotGeneric, err)
+	}
+	return HiscoreSnapshot{}.FromData(data), nil
+}
+
+func (ss *snapshotService) GetSnapshotAfterForUser(ctx context.Context, userId string, timestamp time.Time) (HiscoreSnapshot, error) {
+	ctx, span := ss.monitor.StartSpan(ctx, "snapshotService.GetSnapshotAfterForUser")
+	defer span.End()
+
+	data, err := ss.repository.GetSnapshotAfterForUser(ctx, userId, timestamp)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return HiscoreSnapshot{}, ErrSnapshotNotFound
+		}
+		return HiscoreSnapshot{}, errors.Join(ErrSnapshotGeneric, err)
+	}
+	return HiscoreSnapshot{}.FromData(data), nil
+}
+
+// ReassignSnapshots moves snapshots to another user. Their experience changes and deltas are left
+// to the caller, who knows the order they end up in.
+func (ss *snapshotService) ReassignSnapshots(ctx context.Context, ids []string, userId string) error {
+	ctx, span := ss.monitor.StartSpan(ctx, "snapshotService.ReassignSnapshots")
+	defer span.End()
+
+	if _, err := ss.repository.ReassignSnapshots(ctx, ids, userId); err != nil {
+		return errors.Join(ErrSnapshotGeneric, err)
+	}
+	return nil
+}
+
+func (ss *snapshotService) DeleteSnapshots(ctx context.Context, ids []string) error {
+	ctx, span := ss.monitor.StartSpan(ctx, "snapshotService.DeleteSnapshots")
+	defer span.End()
+
+	if _, err := ss.repository.DeleteSnapshots(ctx, ids); err != nil {
+		return errors.Join(ErrSnapshotGeneric, err)
+	}
+	return nil
+}
+
+func (ss *snapshotService) DeleteSnapshotsForUser(ctx context.Context, userId string) (int64, error) {
+	ctx, span := ss.monitor.StartSpan(ctx, "snapshotService.DeleteSnapshotsForUser")
+	defer span.End()
+
+	deleted, err := ss.repository.DeleteSnapshotsForUser(ctx, userId)
+	if err != nil {
+		return 0, errors.Join(ErrSnapshotGeneric, err)
+	}
+	return deleted, nil
+}
+
 func validateSnapshotInterval(startTime, endTime time.Time) (time.Time, time.Time, error) {
 	if startTime.Equal(endTime) {
 		return time.Time{}, time.Time{}, errors.Join(ErrInvalidIntervalRequest, errors.New("start time must not equal end time"))
@@ -225,3 +365,17 @@ func validateSnapshotInterval(startTime, endTime time.Time) (time.Time, time.Tim
 
 	return startTime, endTime, nil
 }
+
+func (ss *snapshotService) GetAccountTypeTimeline(ctx context.Context, userId string) (user.AccountTypeTimeline, error) {
+	ctx, span := ss.monitor.StartSpan(ctx, "snapshotService.GetAccountTypeTimeline")
+	defer span.End()
+
+	data, err := ss.userRepository.GetUserById(ctx, userId)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return user.AccountTypeTimeline{}, nil
+		}
+		return user.AccountTypeTimeline{}, errors.Join(ErrSnapshotGeneric, err)
+	}
+	return user.User{}.FromData(data).AccountTypeTimeline(), nil
+}
@@ -43,10 +43,12 @@ func (hs HiscoreSnapshot) GetActivity(activityType ActivityType) ActivitySnapsho
 }
 
 func (hs HiscoreSnapshot) Equals(other HiscoreSnapshot) bool {
-	if hs.UserId != other.UserId {
-		return false
-	}
+	return hs.UserId == other.UserId && hs.SameStats(other)
+}
 
+// SameStats reports whether two snapshots hold the same levels, experience, kill counts and
+// scores, whoever they belong to. Ranks are ignored since they move without the player playing.
+func (hs HiscoreSnapshot) SameStats(other HiscoreSnapshot) bool {
 	for _, skill := range hs.Skills {
 		if skill.ActivityType != ActivityTypeUnknown {
 			otherSkill := other.GetSkill(skill.ActivityType)
@@ -0,0 +1,124 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type TokenRepository interface {
+	GetTokenById(ctx context.Context, id string) (TokenData, error)
+	GetTokenBySecretHash(ctx context.Context, secretHash string) (TokenData, error)
+	GetAllTokens(ctx context.Context) ([]TokenData, error)
+	CreateToken(ctx context.Context, token TokenData) (TokenData, error)
+	UpdateToken(ctx context.Context, token TokenData) (TokenData, error)
+	UpdateLastUsed(ctx context.Context, id string, lastUsedAt time.Time) error
+}
+
+type mongoTokenRepository struct {
+	monitor    *monitor.Monitor
+	collection *mongo.Collection
+}
+
+func NewTokenRepository(tokenCollection *mongo.Collection, mon *monitor.Monitor) TokenRepository {
+	return &mongoTokenRepository{
+		collection: tokenCollection,
+		monitor:    mon,
+	}
+}
+
+func (tr *mongoTokenRepository) GetTokenById(ctx context.Context, id string) (TokenData, error) {
+	ctx, span := tr.monitor.StartSpan(ctx, "mongoTokenRepository.GetTokenById")
+	defer span.End()
+
+	return tr.findOne(ctx, bson.M{"_id": id})
+}
+
+func (tr *mongoTokenRepository) GetTokenBySecretHash(ctx context.Context, secretHash string) (TokenData, error) {
+	ctx, span := tr.monitor.StartSpan(ctx, "mongoTokenRepository.GetTokenBySecretHash")
+	defer span.End()
+
+	return tr.findOne(ctx, bson.M{"secretHash": secretHash})
+}
+
+func (tr *mongoTokenRepository) findOne(ctx context.Context, filter bson.M) (TokenData, error) {
+	result := tr.collection.FindOne(ctx, filter)
+	if result.Err() != nil {
+		if errors.Is(result.Err(), mongo.ErrNoDocuments) {
+			return TokenData{}, database.ErrNotFound
+		}
+
+		return TokenData{}, errors.Join(database.ErrGeneric, result.Err())
+	}
+
+	var token TokenData
+	err := result.Decode(&token)
+	if err != nil {
+		return TokenData{}, errors.Join(database.ErrGeneric, err)
+	}
+
+	return token, nil
+}
+
+func (tr *mongoTokenRepository) GetAllTokens(ctx context.Context) ([]TokenData, error) {
+	ctx, span := tr.monitor.StartSpan(ctx, "mongoTokenRepository.GetAllTokens")
+	defer span.End()
+
+	cursor, err := tr.collection.Find(ctx, bson.D{})
+	if err != nil {
+		return []TokenData{}, errors.Join(database.ErrGeneric, err)
+	}
+
+	var results []TokenData
+	if err = cursor.All(ctx, &results); err != nil {
+		return []TokenData{}, errors.Join(database.ErrGeneric, err)
+	}
+
+	return results, nil
+}
+
+func (tr *mongoTokenRepository) CreateToken(ctx context.Context, token TokenData) (TokenData, error) {
+	ctx, span := tr.monitor.StartSpan(ctx, "mongoTokenRepository.CreateToken")
+	defer span.End()
+
+	_, err := tr.collection.InsertOne(ctx, token)
+	if err != nil {
+		return TokenData{}, errors.Join(database.ErrGeneric, err)
+	}
+	return token, nil
+}
+
+func (tr *mongoTokenRepository) UpdateToken(ctx context.Context, token TokenData) (TokenData, error) {
+	ctx, span := tr.monitor.StartSpan(ctx, "mongoTokenRepository.UpdateToken")
+	defer span.End()
+
+	filter := bson.M{"_id": token.Id}
+	update := bson.M{"$set": token}
+
+	_, err := tr.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return TokenData{}, errors.Join(database.ErrGeneric, err)
+	}
+
+	return token, nil
+}
+
+func (tr *mongoTokenRepository) UpdateLastUsed(ctx context.Context, id string, lastUsedAt time.Time) error {
+	ctx, span := tr.monitor.StartSpan(ctx, "mongoTokenRepository.UpdateLastUsed")
+	defer span.End()
+
+	filter := bson.M{"_id": id}
+	update := bson.M{"$set": bson.M{"lastUsedAt": lastUsedAt}}
+
+	_, err := tr.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return errors.Join(database.ErrGeneric, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package token
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+)
+
+type memoryTokenRepository struct {
+	monitor *monitor.Monitor
+	mu      sync.RWMutex
+	tokens  []TokenData
+}
+
+func NewMemoryTokenRepository(mon *monitor.Monitor) TokenRepository {
+	return &memoryTokenRepository{
+		monitor: mon,
+	}
+}
+
+func (tr *memoryTokenRepository) GetTokenById(ctx context.Context, id string) (TokenData, error) {
+	ctx, span := tr.monitor.StartSpan(ctx, "memoryTokenRepository.GetTokenById")
+	defer span.End()
+
+	return tr.find(func(t TokenData) bool { return t.Id == id })
+}
+
+func (tr *memoryTokenRepository) GetTokenBySecretHash(ctx context.Context, secretHash string) (TokenData, error) {
+	ctx, span := tr.monitor.StartSpan(ctx, "memoryTokenRepository.GetTokenBySecretHash")
+	defer span.End()
+
+	return tr.find(func(t TokenData) bool { return t.SecretHash == secretHash })
+}
+
+func (tr *memoryTokenRepository) find(match func(TokenData) bool) (TokenData, error) {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+
+	for _, t := range tr.tokens {
+		if match(t) {
+			return t, nil
+		}
+	}
+	return TokenData{}, database.ErrNotFound
+}
+
+func (tr *memoryTokenRepository) GetAllTokens(ctx context.Context) ([]TokenData, error) {
+	ctx, span := tr.monitor.StartSpan(ctx, "memoryTokenRepository.GetAllTokens")
+	defer span.End()
+
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+
+	return append([]TokenData(nil), tr.tokens...), nil
+}
+
+func (tr *memoryTokenRepository) CreateToken(ctx context.Context, token TokenData) (TokenData, error) {
+	ctx, span := tr.monitor.StartSpan(ctx, "memoryTokenRepository.CreateToken")
+	defer span.End()
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	for _, t := range tr.tokens {
+		if t.Id == token.Id {
+			return TokenData{}, fmt.Errorf("%w: duplicate token id %s", database.ErrGeneric, token.Id)
+		}
+	}
+	tr.tokens = append(tr.tokens, token)
+	return token, nil
+}
+
+func (tr *memoryTokenRepository) UpdateToken(ctx context.Context, token TokenData) (TokenData, error) {
+	ctx, span := tr.monitor.StartSpan(ctx, "memoryTokenRepository.UpdateToken")
+	defer span.End()
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	for i, t := range tr.tokens {
+		if t.Id == token.Id {
+			tr.tokens[i] = token
+		}
+	}
+	return token, nil
+}
+
+func (tr *memoryTokenRepository) UpdateLastUsed(ctx context.Context, id string, lastUsedAt time.Time) error {
+	ctx, span := tr.monitor.StartSpan(ctx, "memoryTokenRepository.UpdateLastUsed")
+	defer span.End()
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	for i, t := range tr.tokens {
+		if t.Id == id {
+			tr.tokens[i].LastUsedAt = &lastUsedAt
+		}
+	}
+	return nil
+}
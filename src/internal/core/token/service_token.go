@@ -0,0 +1,203 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/auth"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/google/uuid"
+)
+
+var ErrTokenGeneric = errors.New("an error occurred while performing the token operation")
+var ErrTokenNotFound = errors.New("token not found")
+var ErrTokenValidation = errors.New("token is invalid")
+
+// secretPrefix makes issued secrets recognizable in logs and secret scanners.
+const secretPrefix = "hzm_"
+
+// lastUsedGranularity bounds how often authenticating with a token writes its last-used time.
+const lastUsedGranularity = time.Minute
+
+type TokenService interface {
+	GetAllTokens(ctx context.Context) ([]Token, error)
+	GetTokenById(ctx context.Context, id string) (Token, error)
+	IssueToken(ctx context.Context, token Token) (Token, string, error)
+	RotateToken(ctx context.Context, id string) (Token, string, error)
+	RevokeToken(ctx context.Context, id string) (Token, error)
+	Authenticate(ctx context.Context, secret string) (auth.Principal, bool, error)
+}
+
+type tokenService struct {
+	monitor    *monitor.Monitor
+	validator  TokenValidator
+	repository TokenRepository
+}
+
+func NewTokenService(mon *monitor.Monitor, repository TokenRepository, validator TokenValidator) TokenService {
+	return &tokenService{
+		monitor:    mon,
+		validator:  validator,
+		repository: repository,
+	}
+}
+
+func (ts *tokenService) GetAllTokens(ctx context.Context) ([]Token, error) {
+	ctx, span := ts.monitor.StartSpan(ctx, "tokenService.GetAllTokens")
+	defer span.End()
+
+	data, err := ts.repository.GetAllTokens(ctx)
+	if err != nil {
+		return []Token{}, errors.Join(ErrTokenGeneric, err)
+	}
+	return Token{}.ManyFromData(data), nil
+}
+
+func (ts *tokenService) GetTokenById(ctx context.Context, id string) (Token, error) {
+	ctx, span := ts.monitor.StartSpan(ctx, "tokenService.GetTokenById")
+	defer span.End()
+
+	return ts.getTokenById(ctx, id)
+}
+
+// IssueToken stores a new token and returns it with its plaintext secret.
+// The secret is only ever returned here and from RotateToken; only its hash is persisted.
+func (ts *tokenService) IssueToken(ctx context.Context, token Token) (Token, string, error) {
+	ctx, span := ts.monitor.StartSpan(ctx, "tokenService.IssueToken")
+	defer span.End()
+
+	err := ts.validator.ValidateToken(token)
+	if err != nil {
+		return Token{}, "", errors.Join(ErrTokenValidation, err)
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return Token{}, "", errors.Join(ErrTokenGeneric, err)
+	}
+
+	token.Id = uuid.New().String()
+	token.SecretHash = HashSecret(secret)
+	token.CreatedAt = time.Now()
+	token.LastUsedAt = nil
+	token.RevokedAt = nil
+
+	data, err := ts.repository.CreateToken(ctx, token.ToData())
+	if err != nil {
+		return Token{}, "", errors.Join(ErrTokenGeneric, err)
+	}
+
+	return Token{}.FromData(data), secret, nil
+}
+
+// RotateToken replaces the secret of an existing token, immediately invalidating the old one.
+func (ts *tokenService) RotateToken(ctx context.Context, id string) (Token, string, error) {
+	ctx, span := ts.monitor.StartSpan(ctx, "tokenService.RotateToken")
+	defer span.End()
+
+	token, err := ts.getTokenById(ctx, id)
+	if err != nil {
+		return Token{}, "", err
+	}
+
+	if token.isRevoked() {
+		return Token{}, "", errors.Join(ErrTokenValidation, errors.New("revoked tokens cannot be rotated"))
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return Token{}, "", errors.Join(ErrTokenGeneric, err)
+	}
+	token.SecretHash = HashSecret(secret)
+
+	data, err := ts.repository.UpdateToken(ctx, token.ToData())
+	if err != nil {
+		return Token{}, "", errors.Join(ErrTokenGeneric, err)
+	}
+
+	return Token{}.FromData(data), secret, nil
+}
+
+func (ts *tokenService) RevokeToken(ctx context.Context, id string) (Token, error) {
+	ctx, span := ts.monitor.StartSpan(ctx, "tokenService.RevokeToken")
+	defer span.End()
+
+	token, err := ts.getTokenById(ctx, id)
+	if err != nil {
+		return Token{}, err
+	}
+
+	if token.isRevoked() {
+		return token, nil
+	}
+
+	now := time.Now()
+	token.RevokedAt = &now
+
+	data, err := ts.repository.UpdateToken(ctx, token.ToData())
+	if err != nil {
+		return Token{}, errors.Join(ErrTokenGeneric, err)
+	}
+
+	return Token{}.FromData(data), nil
+}
+
+// Authenticate resolves a presented secret to the principal of an active token.
+// It reports false for unknown, revoked and expired tokens.
+func (ts *tokenService) Authenticate(ctx context.Context, secret string) (auth.Principal, bool, error) {
+	ctx, span := ts.monitor.StartSpan(ctx, "tokenService.Authenticate")
+	defer span.End()
+
+	data, err := ts.repository.GetTokenBySecretHash(ctx, HashSecret(secret))
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return auth.Principal{}, false, nil
+		}
+		return auth.Principal{}, false, errors.Join(ErrTokenGeneric, err)
+	}
+
+	token := Token{}.FromData(data)
+	now := time.Now()
+	if token.isRevoked() || token.isExpired(now) {
+		return auth.Principal{}, false, nil
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= lastUsedGranularity {
+		if err := ts.repository.UpdateLastUsed(ctx, token.Id, now); err != nil {
+			ts.monitor.Logger().WarnArgs(ctx, "Failed to record last use of token %s: %v", token.Name, err)
+		}
+	}
+
+	return token.ToPrincipal(), true, nil
+}
+
+func (ts *tokenService) getTokenById(ctx context.Context, id string) (Token, error) {
+	data, err := ts.repository.GetTokenById(ctx, id)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return Token{}, ErrTokenNotFound
+		}
+		return Token{}, errors.Join(ErrTokenGeneric, err)
+	}
+	return Token{}.FromData(data), nil
+}
+
+// HashSecret returns the hex encoded sha256 of a token secret, as stored in the token collection.
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return secretPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
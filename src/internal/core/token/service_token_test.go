@@ -0,0 +1,152 @@
+package token_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/token"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/auth"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_logger"
+	"github.com/google/uuid"
+)
+
+func newTestTokenService() (token.TokenService, token.TokenRepository) {
+	mon := monitor.New(hz_logger.NewZeroLogAdapater(hz_logger.LogLevelError))
+	repository := token.NewMemoryTokenRepository(mon)
+	return token.NewTokenService(mon, repository, token.NewTokenValidator()), repository
+}
+
+func TestIssueTokenStoresOnlyTheSecretHash(t *testing.T) {
+	ctx := context.Background()
+	service, repository := newTestTokenService()
+
+	issued, secret, err := service.IssueToken(ctx, token.Token{Name: "discord-bot", Scopes: []auth.Scope{auth.ScopeSnapshotRead}})
+	if err != nil {
+		t.Fatalf("IssueToken: %v", err)
+	}
+	if !strings.HasPrefix(secret, "hzm_") {
+		t.Errorf("IssueToken secret = %q, want the hzm_ prefix", secret)
+	}
+
+	stored, err := repository.GetTokenById(ctx, issued.Id)
+	if err != nil {
+		t.Fatalf("GetTokenById: %v", err)
+	}
+	if stored.SecretHash != token.HashSecret(secret) || strings.Contains(stored.SecretHash, secret) {
+		t.Errorf("stored secret hash = %q, want the sha256 of the secret", stored.SecretHash)
+	}
+
+	principal, ok, err := service.Authenticate(ctx, secret)
+	if err != nil || !ok {
+		t.Fatalf("Authenticate with the issued secret = %v, %v; want authenticated", ok, err)
+	}
+	if principal.Name != "discord-bot" || !principal.HasScope(auth.ScopeSnapshotRead) || principal.HasScope(auth.ScopeUserWrite) {
+		t.Errorf("Authenticate = %+v, want discord-bot with only snapshot:read", principal)
+	}
+
+	after, err := repository.GetTokenById(ctx, issued.Id)
+	if err != nil || after.LastUsedAt == nil {
+		t.Errorf("LastUsedAt after Authenticate = %v, %v; want it recorded", after.LastUsedAt, err)
+	}
+}
+
+func TestIssueTokenRejectsInvalidTokens(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newTestTokenService()
+	past := time.Now().Add(-time.Hour)
+
+	invalid := map[string]token.Token{
+		"no name":       {Scopes: []auth.Scope{auth.ScopeAdmin}},
+		"no scopes":     {Name: "grafana"},
+		"unknown scope": {Name: "grafana", Scopes: []auth.Scope{"snapshot:delete"}},
+		"expired":       {Name: "grafana", Scopes: []auth.Scope{auth.ScopeAdmin}, ExpiresAt: &past},
+	}
+	for name, tok := range invalid {
+		if _, _, err := service.IssueToken(ctx, tok); !errors.Is(err, token.ErrTokenValidation) {
+			t.Errorf("IssueToken with %s: got %v, want ErrTokenValidation", name, err)
+		}
+	}
+}
+
+func TestRotateTokenInvalidatesTheOldSecret(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newTestTokenService()
+
+	issued, oldSecret, err := service.IssueToken(ctx, token.Token{Name: "grafana", Scopes: []auth.Scope{auth.ScopeAdmin}})
+	if err != nil {
+		t.Fatalf("IssueToken: %v", err)
+	}
+	rotated, newSecret, err := service.RotateToken(ctx, issued.Id)
+	if err != nil {
+		t.Fatalf("RotateToken: %v", err)
+	}
+	if rotated.Id != issued.Id || newSecret == oldSecret {
+		t.Errorf("RotateToken = %s with secret %q, want the same token with a new secret", rotated.Id, newSecret)
+	}
+
+	if _, ok, _ := service.Authenticate(ctx, oldSecret); ok {
+		t.Error("Authenticate with the secret from before the rotation succeeded, want it rejected")
+	}
+	if _, ok, _ := service.Authenticate(ctx, newSecret); !ok {
+		t.Error("Authenticate with the rotated secret failed, want it accepted")
+	}
+
+	if _, _, err := service.RotateToken(ctx, uuid.New().String()); !errors.Is(err, token.ErrTokenNotFound) {
+		t.Errorf("RotateToken of an unknown token: got %v, want ErrTokenNotFound", err)
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newTestTokenService()
+
+	issued, secret, err := service.IssueToken(ctx, token.Token{Name: "grafana", Scopes: []auth.Scope{auth.ScopeAdmin}})
+	if err != nil {
+		t.Fatalf("IssueToken: %v", err)
+	}
+	revoked, err := service.RevokeToken(ctx, issued.Id)
+	if err != nil || revoked.RevokedAt == nil {
+		t.Fatalf("RevokeToken = %+v, %v; want it revoked", revoked, err)
+	}
+
+	if _, ok, _ := service.Authenticate(ctx, secret); ok {
+		t.Error("Authenticate with a revoked token succeeded, want it rejected")
+	}
+	again, err := service.RevokeToken(ctx, issued.Id)
+	if err != nil || !again.RevokedAt.Equal(*revoked.RevokedAt) {
+		t.Errorf("RevokeToken of a revoked token = %v, %v; want the original revocation kept", again.RevokedAt, err)
+	}
+	if _, _, err := service.RotateToken(ctx, issued.Id); !errors.Is(err, token.ErrTokenValidation) {
+		t.Errorf("RotateToken of a revoked token: got %v, want ErrTokenValidation", err)
+	}
+}
+
+func TestAuthenticateRejectsExpiredAndUnknownSecrets(t *testing.T) {
+	ctx := context.Background()
+	service, repository := newTestTokenService()
+
+	// Tokens cannot be issued already expired, so store one that has since expired directly.
+	expiredAt := time.Now().Add(-time.Minute)
+	_, err := repository.CreateToken(ctx, token.Token{
+		Id:         uuid.New().String(),
+		Name:       "expired",
+		SecretHash: token.HashSecret("hzm_expired"),
+		Scopes:     []auth.Scope{auth.ScopeAdmin},
+		CreatedAt:  expiredAt.Add(-time.Hour),
+		ExpiresAt:  &expiredAt,
+	}.ToData())
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+
+	if _, ok, err := service.Authenticate(ctx, "hzm_expired"); ok || err != nil {
+		t.Errorf("Authenticate with an expired token = %v, %v; want rejected without error", ok, err)
+	}
+	if _, ok, err := service.Authenticate(ctx, "hzm_unknown"); ok || err != nil {
+		t.Errorf("Authenticate with an unknown secret = %v, %v; want rejected without error", ok, err)
+	}
+}
@@ -0,0 +1,15 @@
+package token
+
+import "time"
+
+type TokenData struct {
+	Id         string     `bson:"_id"`
+	Name       string     `bson:"name"`
+	Owner      string     `bson:"owner"`
+	SecretHash string     `bson:"secretHash"`
+	Scopes     []string   `bson:"scopes"`
+	CreatedAt  time.Time  `bson:"createdAt"`
+	ExpiresAt  *time.Time `bson:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `bson:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `bson:"revokedAt,omitempty"`
+}
@@ -0,0 +1,125 @@
+package token
+
+import (
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/auth"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+)
+
+type Token struct {
+	Id         string
+	Name       string
+	Owner      string
+	SecretHash string
+	Scopes     []auth.Scope
+	CreatedAt  time.Time
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+func (t Token) isRevoked() bool {
+	return t.RevokedAt != nil
+}
+
+func (t Token) isExpired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// ToPrincipal converts the domain Token to the principal attached to authenticated requests
+func (t Token) ToPrincipal() auth.Principal {
+	return auth.Principal{
+		Name:   t.Name,
+		Scopes: t.Scopes,
+	}
+}
+
+// ToAPI converts the domain Token to an API Token. The secret hash is never exposed.
+func (t Token) ToAPI() api.Token {
+	scopes := make([]string, len(t.Scopes))
+	for i := range t.Scopes {
+		scopes[i] = string(t.Scopes[i])
+	}
+	return api.Token{
+		Id:         t.Id,
+		Name:       t.Name,
+		Owner:      t.Owner,
+		Scopes:     scopes,
+		CreatedAt:  t.CreatedAt,
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		RevokedAt:  t.RevokedAt,
+	}
+}
+
+// FromIssueRequest creates a domain Token from an IssueTokenRequest (call as Token{}.FromIssueRequest(...))
+// Unknown scopes are kept as-is so the validator can reject them.
+func (Token) FromIssueRequest(request api.IssueTokenRequest) Token {
+	scopes := make([]auth.Scope, len(request.Scopes))
+	for i := range request.Scopes {
+		scopes[i] = auth.Scope(request.Scopes[i])
+	}
+	return Token{
+		Name:      request.Name,
+		Owner:     request.Owner,
+		Scopes:    scopes,
+		ExpiresAt: request.ExpiresAt,
+	}
+}
+
+// ToData converts the domain Token to a data layer TokenData
+func (t Token) ToData() TokenData {
+	scopes := make([]string, len(t.Scopes))
+	for i := range t.Scopes {
+		scopes[i] = string(t.Scopes[i])
+	}
+	return TokenData{
+		Id:         t.Id,
+		Name:       t.Name,
+		Owner:      t.Owner,
+		SecretHash: t.SecretHash,
+		Scopes:     scopes,
+		CreatedAt:  t.CreatedAt,
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		RevokedAt:  t.RevokedAt,
+	}
+}
+
+// FromData creates a domain Token from data layer TokenData (call as Token{}.FromData(...))
+func (Token) FromData(data TokenData) Token {
+	scopes := make([]auth.Scope, len(data.Scopes))
+	for i := range data.Scopes {
+		scopes[i] = auth.Scope(data.Scopes[i])
+	}
+	return Token{
+		Id:         data.Id,
+		Name:       data.Name,
+		Owner:      data.Owner,
+		SecretHash: data.SecretHash,
+		Scopes:     scopes,
+		CreatedAt:  data.CreatedAt,
+		ExpiresAt:  data.ExpiresAt,
+		LastUsedAt: data.LastUsedAt,
+		RevokedAt:  data.RevokedAt,
+	}
+}
+
+// ManyToAPI converts a slice of domain Tokens to API Tokens (call as Token{}.ManyToAPI(...))
+func (Token) ManyToAPI(tokens []Token) []api.Token {
+	apiTokens := make([]api.Token, len(tokens))
+	for i := range tokens {
+		apiTokens[i] = tokens[i].ToAPI()
+	}
+	return apiTokens
+}
+
+// ManyFromData converts a slice of TokenData to domain Tokens (call as Token{}.ManyFromData(...))
+func (Token) ManyFromData(data []TokenData) []Token {
+	tokens := make([]Token, len(data))
+	for i := range data {
+		tokens[i] = Token{}.FromData(data[i])
+	}
+	return tokens
+}
@@ -0,0 +1,42 @@
+package token
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/auth"
+)
+
+type TokenValidator interface {
+	ValidateToken(token Token) error
+}
+
+type tokenValidator struct {
+}
+
+func NewTokenValidator() TokenValidator {
+	return &tokenValidator{}
+}
+
+func (tv *tokenValidator) ValidateToken(token Token) error {
+	if token.Name == "" {
+		return errors.New("name is required")
+	}
+
+	if len(token.Scopes) == 0 {
+		return errors.New("at least one scope is required")
+	}
+
+	for _, scope := range token.Scopes {
+		if _, ok := auth.ScopeFromValue(string(scope)); !ok {
+			return fmt.Errorf("unknown scope: %s", scope)
+		}
+	}
+
+	if token.ExpiresAt != nil && !token.ExpiresAt.After(time.Now()) {
+		return errors.New("expiry must be in the future")
+	}
+
+	return nil
+}
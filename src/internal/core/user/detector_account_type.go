@@ -0,0 +1,62 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+)
+
+var ErrAccountTypeUndetected = errors.New("account type could not be detected")
+
+// AccountTypeDetector looks up the account type of a user from outside Hazelmere.
+type AccountTypeDetector interface {
+	// DetectAccountType returns the account type of u and when the source saw it, or
+	// ErrAccountTypeUndetected when the source does not know it.
+	DetectAccountType(ctx context.Context, u User) (accountType AccountType, detectedAt time.Time, err error)
+}
+
+// AccountTypeChecker checks the account type of a user on demand.
+type AccountTypeChecker interface {
+	// CheckAccountType detects the account type of a user and records it through
+	// UserService.ReportAccountType, reporting whether it changed.
+	CheckAccountType(ctx context.Context, id string) (user User, changed bool, err error)
+}
+
+type accountTypeChecker struct {
+	monitor  *monitor.Monitor
+	service  UserService
+	detector AccountTypeDetector
+}
+
+func NewAccountTypeChecker(mon *monitor.Monitor, service UserService, detector AccountTypeDetector) AccountTypeChecker {
+	return &accountTypeChecker{
+		monitor:  mon,
+		service:  service,
+		detector: detector,
+	}
+}
+
+func (c *accountTypeChecker) CheckAccountType(ctx context.Context, id string) (User, bool, error) {
+	ctx, span := c.monitor.StartSpan(ctx, "accountTypeChecker.CheckAccountType")
+	defer span.End()
+
+	user, err := c.service.GetUserById(ctx, id)
+	if err != nil {
+		return User{}, false, err
+	}
+	if err := ensureWritable(user); err != nil {
+		return User{}, false, err
+	}
+
+	accountType, detectedAt, err := c.detector.DetectAccountType(ctx, user)
+	if err != nil {
+		if errors.Is(err, ErrAccountTypeUndetected) {
+			return User{}, false, err
+		}
+		return User{}, false, errors.Join(ErrUserGeneric, err)
+	}
+
+	return c.service.ReportAccountType(ctx, id, accountType, detectedAt)
+}
@@ -0,0 +1,106 @@
+package user_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/user"
+	"github.com/ctfloyd/hazelmere-api/src/internal/dependency/wom"
+	"github.com/ctfloyd/hazelmere-api/src/internal/dependency/wom/womtest"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_logger"
+)
+
+func TestWomAccountTypeDetector(t *testing.T) {
+	ctx := context.Background()
+	updatedAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	server := womtest.NewServer(t,
+		womtest.Player{Username: "Hyger", Type: wom.PlayerTypeHardcore, UpdatedAt: &updatedAt},
+		womtest.Player{Username: "Group Iron", Type: wom.PlayerTypeIronman},
+		womtest.Player{Username: "New Player"},
+	)
+	detector := user.NewWomAccountTypeDetector(wom.NewClient(hz_logger.NewZeroLogAdapater(hz_logger.LogLevelError), server.Config()))
+
+	tests := []struct {
+		name    string
+		current user.AccountType
+		want    user.AccountType
+		wantErr error
+	}{
+		{"Hyger", user.AccountTypeIronman, user.AccountTypeHardcoreIronman, nil},
+		// WOM reports group ironmen as ironmen, so that is not a change for them.
+		{"Group Iron", user.AccountTypeGroupIronman, user.AccountTypeGroupIronman, nil},
+		{"Group Iron", user.AccountTypeNormal, user.AccountTypeIronman, nil},
+		{"New Player", user.AccountTypeNormal, "", user.ErrAccountTypeUndetected},
+		{"Nobody", user.AccountTypeNormal, "", user.ErrAccountTypeUndetected},
+	}
+	for _, tt := range tests {
+		got, _, err := detector.DetectAccountType(ctx, user.User{RunescapeName: tt.name, AccountType: tt.current})
+		if got != tt.want || !errors.Is(err, tt.wantErr) {
+			t.Errorf("DetectAccountType(%s, %s) = %s, %v; want %s, %v", tt.name, tt.current, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	// The type is as of the last time WOM updated the player, not as of the check.
+	if _, detectedAt, err := detector.DetectAccountType(ctx, user.User{RunescapeName: "Hyger"}); err != nil || !detectedAt.Equal(updatedAt) {
+		t.Errorf("DetectAccountType detected at %v, %v; want %v", detectedAt, err, updatedAt)
+	}
+}
+
+func TestAccountTypeChanges(t *testing.T) {
+	ctx := context.Background()
+	womUpdatedAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	server := womtest.NewServer(t, womtest.Player{Username: "Hyger", Type: wom.PlayerTypeIronman, UpdatedAt: &womUpdatedAt})
+	service := user.NewUserService(testMonitor, user.NewMemoryUserRepository(testMonitor), user.NewUserValidator())
+	checker := user.NewAccountTypeChecker(testMonitor, service,
+		user.NewWomAccountTypeDetector(wom.NewClient(hz_logger.NewZeroLogAdapater(hz_logger.LogLevelError), server.Config())))
+
+	created, err := service.CreateUser(ctx, user.User{RunescapeName: "Hyger", AccountType: user.AccountTypeHardcoreIronman})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The hardcore status was lost, as the check finds.
+	checked, changed, err := checker.CheckAccountType(ctx, created.Id)
+	if err != nil || !changed || checked.AccountType != user.AccountTypeIronman {
+		t.Fatalf("CheckAccountType = %s, %t, %v; want a change to IRONMAN", checked.AccountType, changed, err)
+	}
+	lostAt := checked.AccountTypeHistory[0].ChangedAt
+	if !lostAt.Equal(womUpdatedAt) {
+		t.Errorf("detected change at %v, want %v when WOM last updated the player", lostAt, womUpdatedAt)
+	}
+
+	// A report from before the last change is stale, and the same type is no change.
+	if u, changed, err := service.ReportAccountType(ctx, created.Id, user.AccountTypeNormal, lostAt.Add(-time.Hour)); err != nil || changed || u.AccountType != user.AccountTypeIronman {
+		t.Errorf("stale ReportAccountType = %s, %t, %v; want IRONMAN unchanged", u.AccountType, changed, err)
+	}
+	if _, changed, err := service.ReportAccountType(ctx, created.Id, user.AccountTypeIronman, lostAt.Add(time.Hour)); err != nil || changed {
+		t.Errorf("ReportAccountType of the current type = %t, %v; want no change", changed, err)
+	}
+	if _, _, err := service.ReportAccountType(ctx, created.Id, "WIZARD", lostAt.Add(time.Hour)); !errors.Is(err, user.ErrUserValidation) {
+		t.Errorf("ReportAccountType of an unknown type: got %v, want ErrUserValidation", err)
+	}
+
+	// De-ironing through an update is a manual change.
+	checked.AccountType = user.AccountTypeNormal
+	updated, err := service.UpdateUser(ctx, checked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := updated.AccountTypeHistory
+	if len(history) != 2 ||
+		history[0].From != user.AccountTypeHardcoreIronman || history[0].To != user.AccountTypeIronman || history[0].Source != user.AccountTypeSourceDetected ||
+		history[1].From != user.AccountTypeIronman || history[1].To != user.AccountTypeNormal || history[1].Source != user.AccountTypeSourceManual {
+		t.Errorf("account type history = %+v, want HARDCORE_IRONMAN to IRONMAN detected, then IRONMAN to NORMAL manually", history)
+	}
+
+	// WOM has not updated the player since, so checking again does not undo the manual change.
+	if checked, changed, err := checker.CheckAccountType(ctx, created.Id); err != nil || changed || checked.AccountType != user.AccountTypeNormal {
+		t.Errorf("CheckAccountType after the manual change = %s, %t, %v; want NORMAL unchanged", checked.AccountType, changed, err)
+	}
+
+	if _, err := service.CreateUser(ctx, user.User{RunescapeName: "Zezima", AccountType: "WIZARD"}); !errors.Is(err, user.ErrUserValidation) {
+		t.Errorf("CreateUser with an unknown account type: got %v, want ErrUserValidation", err)
+	}
+}
@@ -0,0 +1,60 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/dependency/wom"
+)
+
+type womAccountTypeDetector struct {
+	client *wom.Client
+}
+
+// NewWomAccountTypeDetector reads account types from Wise Old Man, as of the last time it
+// updated the player.
+func NewWomAccountTypeDetector(client *wom.Client) AccountTypeDetector {
+	return &womAccountTypeDetector{client: client}
+}
+
+func (d *womAccountTypeDetector) DetectAccountType(ctx context.Context, u User) (AccountType, time.Time, error) {
+	details, err := d.client.GetPlayerDetails(ctx, u.RunescapeName)
+	if err != nil {
+		if errors.Is(err, wom.ErrPlayerNotFound) {
+			return "", time.Time{}, errors.Join(ErrAccountTypeUndetected, err)
+		}
+		return "", time.Time{}, err
+	}
+
+	accountType, err := accountTypeFromWom(details.Type, u.AccountType)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	// WOM only knows the type as of its last update, which may be long before this check.
+	detectedAt := time.Now()
+	if details.UpdatedAt != nil {
+		detectedAt = *details.UpdatedAt
+	}
+	return accountType, detectedAt, nil
+}
+
+// accountTypeFromWom maps a WOM player type to an account type. WOM reports group ironmen as
+// ironmen, so a group ironman stays one while WOM still sees an ironman.
+func accountTypeFromWom(playerType string, current AccountType) (AccountType, error) {
+	switch playerType {
+	case wom.PlayerTypeRegular:
+		return AccountTypeNormal, nil
+	case wom.PlayerTypeIronman:
+		if current == AccountTypeGroupIronman {
+			return AccountTypeGroupIronman, nil
+		}
+		return AccountTypeIronman, nil
+	case wom.PlayerTypeHardcore:
+		return AccountTypeHardcoreIronman, nil
+	case wom.PlayerTypeUltimate:
+		return AccountTypeUltimateIronman, nil
+	default:
+		return "", ErrAccountTypeUndetected
+	}
+}
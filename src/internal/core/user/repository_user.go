@@ -3,22 +3,49 @@ package user
 import (
 	"context"
 	"errors"
+	"regexp"
 
 	"github.com/ctfloyd/hazelmere-api/src/internal/database"
 	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
+// UserRepository compares runescape names as normalized by api.NormalizeRunescapeName, and keeps
+// UserData.NormalizedName in sync on writes. Listings leave out soft-deleted and merged users.
 type UserRepository interface {
 	GetUserById(ctx context.Context, id string) (UserData, error)
+	// GetUserByRunescapeName matches the current names of live users only, so deleted and merged
+	// users do not hold on to their names.
 	GetUserByRunescapeName(ctx context.Context, runescapeName string) (UserData, error)
+	// GetUsersByAlias returns the users who held runescapeName in the past.
+	GetUsersByAlias(ctx context.Context, runescapeName string) ([]UserData, error)
+	// GetUsersMergedInto returns the tombstones of the users merged into id.
+	GetUsersMergedInto(ctx context.Context, id string) ([]UserData, error)
 	GetAllUsers(ctx context.Context) ([]UserData, error)
 	GetUsersWithTrackingEnabled(ctx context.Context) ([]UserData, error)
+	// QueryUsers returns at most query.Limit users matching query, in its sort order.
+	QueryUsers(ctx context.Context, query UserQueryData) ([]UserData, error)
 	CreateUser(ctx context.Context, user UserData) (UserData, error)
 	UpdateUser(ctx context.Context, user UserData) (UserData, error)
+	// DeleteUser removes the user for good. Returns database.ErrNotFound for an unknown id.
+	DeleteUser(ctx context.Context, id string) error
 }
 
+// syncNames derives the name fields the repository keeps in sync with RunescapeName.
+func syncNames(user *UserData) {
+	user.NormalizedName = api.NormalizeRunescapeName(user.RunescapeName)
+	user.LiveName = ""
+	if user.DeletedAt == nil && user.MergedInto == "" {
+		user.LiveName = user.NormalizedName
+	}
+}
+
+// live matches the users listings return: neither deleted nor merged into another user.
+var live = bson.M{"deletedAt": bson.M{"$exists": false}, "mergedInto": bson.M{"$exists": false}}
+
 type mongoUserRepository struct {
 	monitor    *monitor.Monitor
 	collection *mongo.Collection
@@ -59,7 +86,7 @@ func (ur *mongoUserRepository) GetUserByRunescapeName(ctx context.Context, runes
 	ctx, span := ur.monitor.StartSpan(ctx, "mongoUserRepository.GetUserByRunescapeName")
 	defer span.End()
 
-	filter := bson.M{"runescapeName": runescapeName}
+	filter := bson.M{"liveName": api.NormalizeRunescapeName(runescapeName)}
 
 	result := ur.collection.FindOne(ctx, filter)
 	if result.Err() != nil {
@@ -79,11 +106,47 @@ func (ur *mongoUserRepository) GetUserByRunescapeName(ctx context.Context, runes
 	return user, nil
 }
 
+func (ur *mongoUserRepository) GetUsersByAlias(ctx context.Context, runescapeName string) ([]UserData, error) {
+	ctx, span := ur.monitor.StartSpan(ctx, "mongoUserRepository.GetUsersByAlias")
+	defer span.End()
+
+	filter := bson.M{"aliases": api.NormalizeRunescapeName(runescapeName)}
+
+	cursor, err := ur.collection.Find(ctx, filter)
+	if err != nil {
+		return []UserData{}, errors.Join(database.ErrGeneric, err)
+	}
+
+	var results []UserData
+	if err = cursor.All(ctx, &results); err != nil {
+		return []UserData{}, errors.Join(database.ErrGeneric, err)
+	}
+
+	return results, nil
+}
+
+func (ur *mongoUserRepository) GetUsersMergedInto(ctx context.Context, id string) ([]UserData, error) {
+	ctx, span := ur.monitor.StartSpan(ctx, "mongoUserRepository.GetUsersMergedInto")
+	defer span.End()
+
+	cursor, err := ur.collection.Find(ctx, bson.M{"mergedInto": id})
+	if err != nil {
+		return []UserData{}, errors.Join(database.ErrGeneric, err)
+	}
+
+	var results []UserData
+	if err = cursor.All(ctx, &results); err != nil {
+		return []UserData{}, errors.Join(database.ErrGeneric, err)
+	}
+
+	return results, nil
+}
+
 func (ur *mongoUserRepository) GetAllUsers(ctx context.Context) ([]UserData, error) {
 	ctx, span := ur.monitor.StartSpan(ctx, "mongoUserRepository.GetAllUsers")
 	defer span.End()
 
-	cursor, err := ur.collection.Find(ctx, bson.D{})
+	cursor, err := ur.collection.Find(ctx, live)
 	if err != nil {
 		return []UserData{}, errors.Join(database.ErrGeneric, err)
 	}
@@ -100,7 +163,7 @@ func (ur *mongoUserRepository) GetUsersWithTrackingEnabled(ctx context.Context)
 	ctx, span := ur.monitor.StartSpan(ctx, "mongoUserRepository.GetUsersWithTrackingEnabled")
 	defer span.End()
 
-	filter := bson.M{"trackingStatus": string(TrackingStatusEnabled)}
+	filter := bson.M{"trackingStatus": string(TrackingStatusEnabled), "deletedAt": bson.M{"$exists": false}}
 
 	cursor, err := ur.collection.Find(ctx, filter)
 	if err != nil {
@@ -115,10 +178,69 @@ func (ur *mongoUserRepository) GetUsersWithTrackingEnabled(ctx context.Context)
 	return results, nil
 }
 
+func (ur *mongoUserRepository) QueryUsers(ctx context.Context, query UserQueryData) ([]UserData, error) {
+	ctx, span := ur.monitor.StartSpan(ctx, "mongoUserRepository.QueryUsers")
+	defer span.End()
+
+	conditions := bson.A{live}
+	if query.Name != "" {
+		pattern := regexp.QuoteMeta(query.Name)
+		if !query.NameContains {
+			// An anchored pattern can use the normalizedName index.
+			pattern = "^" + pattern
+		}
+		conditions = append(conditions, bson.M{"normalizedName": bson.M{"$regex": pattern}})
+	}
+	if query.TrackingStatus != "" {
+		conditions = append(conditions, bson.M{"trackingStatus": query.TrackingStatus})
+	}
+	if query.AccountType != "" {
+		conditions = append(conditions, bson.M{"accountType": query.AccountType})
+	}
+	if !query.CreatedAfter.IsZero() {
+		conditions = append(conditions, bson.M{"createdAt": bson.M{"$gt": query.CreatedAfter}})
+	}
+
+	direction := 1
+	after := "$gt"
+	if query.Descending {
+		direction = -1
+		after = "$lt"
+	}
+	if query.After != nil {
+		var position any = query.After.NormalizedName
+		if query.SortField == UserSortFieldCreatedAt {
+			position = query.After.CreatedAt
+		}
+		conditions = append(conditions, bson.M{"$or": bson.A{
+			bson.M{query.SortField: bson.M{after: position}},
+			bson.M{query.SortField: position, "_id": bson.M{after: query.After.Id}},
+		}})
+	}
+
+	filter := bson.M{"$and": conditions}
+	opts := options.Find().
+		SetSort(bson.D{{Key: query.SortField, Value: direction}, {Key: "_id", Value: direction}}).
+		SetLimit(int64(query.Limit))
+
+	cursor, err := ur.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return []UserData{}, errors.Join(database.ErrGeneric, err)
+	}
+
+	var results []UserData
+	if err = cursor.All(ctx, &results); err != nil {
+		return []UserData{}, errors.Join(database.ErrGeneric, err)
+	}
+
+	return results, nil
+}
+
 func (ur *mongoUserRepository) CreateUser(ctx context.Context, user UserData) (UserData, error) {
 	ctx, span := ur.monitor.StartSpan(ctx, "mongoUserRepository.CreateUser")
 	defer span.End()
 
+	syncNames(&user)
 	_, err := ur.collection.InsertOne(ctx, user)
 	if err != nil {
 		return UserData{}, errors.Join(database.ErrGeneric, err)
@@ -130,8 +252,20 @@ func (ur *mongoUserRepository) UpdateUser(ctx context.Context, user UserData) (U
 	ctx, span := ur.monitor.StartSpan(ctx, "mongoUserRepository.UpdateUser")
 	defer span.End()
 
+	syncNames(&user)
 	filter := bson.M{"_id": user.Id}
 	update := bson.M{"$set": user}
+	// $set skips empty fields, so the ones that are cleared during a user's life are removed.
+	unset := bson.M{}
+	if user.LiveName == "" {
+		unset["liveName"] = ""
+	}
+	if user.MergingWith == "" {
+		unset["mergingWith"] = ""
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
 
 	_, err := ur.collection.UpdateOne(ctx, filter, update)
 	if err != nil {
@@ -140,3 +274,17 @@ func (ur *mongoUserRepository) UpdateUser(ctx context.Context, user UserData) (U
 
 	return user, nil
 }
+
+func (ur *mongoUserRepository) DeleteUser(ctx context.Context, id string) error {
+	ctx, span := ur.monitor.StartSpan(ctx, "mongoUserRepository.DeleteUser")
+	defer span.End()
+
+	result, err := ur.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return errors.Join(database.ErrGeneric, err)
+	}
+	if result.DeletedCount == 0 {
+		return database.ErrNotFound
+	}
+	return nil
+}
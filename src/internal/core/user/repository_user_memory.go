@@ -0,0 +1,229 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+)
+
+// memoryUserRepository keeps users in insertion order, like an unsorted Mongo find.
+type memoryUserRepository struct {
+	monitor *monitor.Monitor
+	mu      sync.RWMutex
+	users   []UserData
+}
+
+func NewMemoryUserRepository(mon *monitor.Monitor) UserRepository {
+	return &memoryUserRepository{
+		monitor: mon,
+	}
+}
+
+func (ur *memoryUserRepository) GetUserById(ctx context.Context, id string) (UserData, error) {
+	ctx, span := ur.monitor.StartSpan(ctx, "memoryUserRepository.GetUserById")
+	defer span.End()
+
+	return ur.find(func(u UserData) bool { return u.Id == id })
+}
+
+func (ur *memoryUserRepository) GetUserByRunescapeName(ctx context.Context, runescapeName string) (UserData, error) {
+	ctx, span := ur.monitor.StartSpan(ctx, "memoryUserRepository.GetUserByRunescapeName")
+	defer span.End()
+
+	normalized := api.NormalizeRunescapeName(runescapeName)
+	return ur.find(func(u UserData) bool { return u.LiveName == normalized })
+}
+
+func (ur *memoryUserRepository) GetUsersByAlias(ctx context.Context, runescapeName string) ([]UserData, error) {
+	ctx, span := ur.monitor.StartSpan(ctx, "memoryUserRepository.GetUsersByAlias")
+	defer span.End()
+
+	normalized := api.NormalizeRunescapeName(runescapeName)
+	return ur.filter(func(u UserData) bool { return slices.Contains(u.Aliases, normalized) }), nil
+}
+
+func (ur *memoryUserRepository) GetUsersMergedInto(ctx context.Context, id string) ([]UserData, error) {
+	ctx, span := ur.monitor.StartSpan(ctx, "memoryUserRepository.GetUsersMergedInto")
+	defer span.End()
+
+	return ur.filter(func(u UserData) bool { return u.MergedInto == id }), nil
+}
+
+// isLive matches the users listings return, as the live filter does for Mongo.
+func isLive(u UserData) bool {
+	return u.DeletedAt == nil && u.MergedInto == ""
+}
+
+func (ur *memoryUserRepository) GetAllUsers(ctx context.Context) ([]UserData, error) {
+	ctx, span := ur.monitor.StartSpan(ctx, "memoryUserRepository.GetAllUsers")
+	defer span.End()
+
+	return ur.filter(isLive), nil
+}
+
+func (ur *memoryUserRepository) GetUsersWithTrackingEnabled(ctx context.Context) ([]UserData, error) {
+	ctx, span := ur.monitor.StartSpan(ctx, "memoryUserRepository.GetUsersWithTrackingEnabled")
+	defer span.End()
+
+	return ur.filter(func(u UserData) bool {
+		return u.TrackingStatus == string(TrackingStatusEnabled) && u.DeletedAt == nil
+	}), nil
+}
+
+func (ur *memoryUserRepository) QueryUsers(ctx context.Context, query UserQueryData) ([]UserData, error) {
+	ctx, span := ur.monitor.StartSpan(ctx, "memoryUserRepository.QueryUsers")
+	defer span.End()
+
+	compare := func(a, b UserData) int {
+		var c int
+		if query.SortField == UserSortFieldCreatedAt {
+			c = createdAtOrZero(a).Compare(createdAtOrZero(b))
+		} else {
+			c = strings.Compare(a.NormalizedName, b.NormalizedName)
+		}
+		if c == 0 {
+			c = strings.Compare(a.Id, b.Id)
+		}
+		if query.Descending {
+			return -c
+		}
+		return c
+	}
+
+	var position UserData
+	if query.After != nil {
+		position = UserData{Id: query.After.Id, NormalizedName: query.After.NormalizedName, CreatedAt: &query.After.CreatedAt}
+	}
+
+	results := ur.filter(func(u UserData) bool {
+		if !isLive(u) {
+			return false
+		}
+		if query.Name != "" {
+			if query.NameContains && !strings.Contains(u.NormalizedName, query.Name) {
+				return false
+			}
+			if !query.NameContains && !strings.HasPrefix(u.NormalizedName, query.Name) {
+				return false
+			}
+		}
+		if query.TrackingStatus != "" && u.TrackingStatus != query.TrackingStatus {
+			return false
+		}
+		if query.AccountType != "" && u.AccountType != query.AccountType {
+			return false
+		}
+		if !query.CreatedAfter.IsZero() && (u.CreatedAt == nil || !u.CreatedAt.After(query.CreatedAfter)) {
+			return false
+		}
+		return query.After == nil || compare(u, position) > 0
+	})
+
+	slices.SortFunc(results, compare)
+	if query.Limit > 0 && len(results) > query.Limit {
+		results = results[:query.Limit]
+	}
+	return results, nil
+}
+
+func createdAtOrZero(u UserData) time.Time {
+	if u.CreatedAt == nil {
+		return time.Time{}
+	}
+	return *u.CreatedAt
+}
+
+func (ur *memoryUserRepository) CreateUser(ctx context.Context, user UserData) (UserData, error) {
+	ctx, span := ur.monitor.StartSpan(ctx, "memoryUserRepository.CreateUser")
+	defer span.End()
+
+	syncNames(&user)
+
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+
+	for _, u := range ur.users {
+		if u.Id == user.Id {
+			return UserData{}, fmt.Errorf("%w: duplicate user id %s", database.ErrGeneric, user.Id)
+		}
+	}
+	if err := ur.checkLiveName(user); err != nil {
+		return UserData{}, err
+	}
+	ur.users = append(ur.users, user)
+	return user, nil
+}
+
+// checkLiveName enforces the unique liveName index. Callers hold the lock.
+func (ur *memoryUserRepository) checkLiveName(user UserData) error {
+	for _, u := range ur.users {
+		if user.LiveName != "" && u.Id != user.Id && u.LiveName == user.LiveName {
+			return fmt.Errorf("%w: duplicate live user name %s", database.ErrGeneric, user.LiveName)
+		}
+	}
+	return nil
+}
+
+// UpdateUser replaces the stored user. Like the Mongo update it is a no-op for an unknown id.
+func (ur *memoryUserRepository) UpdateUser(ctx context.Context, user UserData) (UserData, error) {
+	ctx, span := ur.monitor.StartSpan(ctx, "memoryUserRepository.UpdateUser")
+	defer span.End()
+
+	syncNames(&user)
+
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+
+	if err := ur.checkLiveName(user); err != nil {
+		return UserData{}, err
+	}
+	for i, u := range ur.users {
+		if u.Id == user.Id {
+			ur.users[i] = user
+		}
+	}
+	return user, nil
+}
+
+func (ur *memoryUserRepository) DeleteUser(ctx context.Context, id string) error {
+	ctx, span := ur.monitor.StartSpan(ctx, "memoryUserRepository.DeleteUser")
+	defer span.End()
+
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+
+	before := len(ur.users)
+	ur.users = slices.DeleteFunc(ur.users, func(u UserData) bool { return u.Id == id })
+	if len(ur.users) == before {
+		return database.ErrNotFound
+	}
+	return nil
+}
+
+func (ur *memoryUserRepository) find(match func(UserData) bool) (UserData, error) {
+	users := ur.filter(match)
+	if len(users) == 0 {
+		return UserData{}, database.ErrNotFound
+	}
+	return users[0], nil
+}
+
+func (ur *memoryUserRepository) filter(match func(UserData) bool) []UserData {
+	ur.mu.RLock()
+	defer ur.mu.RUnlock()
+
+	var results []UserData
+	for _, u := range ur.users {
+		if match(u) {
+			results = append(results, u)
+		}
+	}
+	return results
+}
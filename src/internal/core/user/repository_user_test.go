@@ -0,0 +1,281 @@
+package user_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/user"
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/internal/database/databasetest"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_logger"
+	"github.com/google/uuid"
+)
+
+var testMonitor = monitor.New(hz_logger.NewZeroLogAdapater(hz_logger.LogLevelError))
+
+func TestMemoryUserRepository(t *testing.T) {
+	testUserRepository(t, func(t *testing.T) user.UserRepository {
+		return user.NewMemoryUserRepository(testMonitor)
+	})
+}
+
+func TestMongoUserRepository(t *testing.T) {
+	testUserRepository(t, func(t *testing.T) user.UserRepository {
+		return user.NewUserRepository(databasetest.Collection(t, "user"), testMonitor)
+	})
+}
+
+func newUserData(name string, status user.TrackingStatus) user.UserData {
+	return user.UserData{
+		Id:             uuid.New().String(),
+		RunescapeName:  name,
+		TrackingStatus: string(status),
+		AccountType:    "NORMAL",
+		NormalizedName: api.NormalizeRunescapeName(name),
+		LiveName:       api.NormalizeRunescapeName(name),
+	}
+}
+
+// testUserRepository is the conformance suite every UserRepository must pass.
+func testUserRepository(t *testing.T, newRepo func(t *testing.T) user.UserRepository) {
+	ctx := context.Background()
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		repo := newRepo(t)
+		want := newUserData("zezima", user.TrackingStatusEnabled)
+		if _, err := repo.CreateUser(ctx, want); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+
+		byId, err := repo.GetUserById(ctx, want.Id)
+		if err != nil || !reflect.DeepEqual(byId, want) {
+			t.Errorf("GetUserById = %+v, %v; want %+v", byId, err, want)
+		}
+		byName, err := repo.GetUserByRunescapeName(ctx, "zezima")
+		if err != nil || !reflect.DeepEqual(byName, want) {
+			t.Errorf("GetUserByRunescapeName = %+v, %v; want %+v", byName, err, want)
+		}
+
+		if _, err := repo.GetUserById(ctx, uuid.New().String()); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("GetUserById of an unknown id: got %v, want ErrNotFound", err)
+		}
+		if _, err := repo.GetUserByRunescapeName(ctx, "lynx titan"); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("GetUserByRunescapeName of an unknown name: got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("ListAndTracking", func(t *testing.T) {
+		repo := newRepo(t)
+		enabled := newUserData("woox", user.TrackingStatusEnabled)
+		disabled := newUserData("b0aty", user.TrackingStatusDisabled)
+		for _, u := range []user.UserData{enabled, disabled} {
+			if _, err := repo.CreateUser(ctx, u); err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+		}
+
+		all, err := repo.GetAllUsers(ctx)
+		if err != nil || len(all) != 2 {
+			t.Errorf("GetAllUsers returned %d users, %v; want 2", len(all), err)
+		}
+		tracked, err := repo.GetUsersWithTrackingEnabled(ctx)
+		if err != nil || len(tracked) != 1 || !reflect.DeepEqual(tracked[0], enabled) {
+			t.Errorf("GetUsersWithTrackingEnabled = %+v, %v; want [%+v]", tracked, err, enabled)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		repo := newRepo(t)
+		u := newUserData("zezima", user.TrackingStatusEnabled)
+		if _, err := repo.CreateUser(ctx, u); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+
+		u.AccountType = "IRONMAN"
+		u.TrackingStatus = string(user.TrackingStatusDisabled)
+		if _, err := repo.UpdateUser(ctx, u); err != nil {
+			t.Fatalf("UpdateUser: %v", err)
+		}
+		got, err := repo.GetUserById(ctx, u.Id)
+		if err != nil || !reflect.DeepEqual(got, u) {
+			t.Errorf("GetUserById after UpdateUser = %+v, %v; want %+v", got, err, u)
+		}
+
+		// Updating an unknown user is a no-op rather than an insert.
+		if _, err := repo.UpdateUser(ctx, newUserData("ghost", user.TrackingStatusEnabled)); err != nil {
+			t.Fatalf("UpdateUser of an unknown user: %v", err)
+		}
+		all, err := repo.GetAllUsers(ctx)
+		if err != nil || len(all) != 1 {
+			t.Errorf("GetAllUsers after updating an unknown user returned %d users, %v; want 1", len(all), err)
+		}
+	})
+
+	t.Run("LiveName", func(t *testing.T) {
+		repo := newRepo(t)
+		u := newUserData("Zezima", user.TrackingStatusEnabled)
+		u.LiveName = ""
+		if _, err := repo.CreateUser(ctx, u); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		if got, err := repo.GetUserById(ctx, u.Id); err != nil || got.LiveName != "zezima" {
+			t.Errorf("LiveName of a live user = %q, %v; want zezima", got.LiveName, err)
+		}
+
+		deletedAt := time.Now().UTC().Truncate(time.Millisecond)
+		u.DeletedAt = &deletedAt
+		if _, err := repo.UpdateUser(ctx, u); err != nil {
+			t.Fatalf("UpdateUser: %v", err)
+		}
+		if got, err := repo.GetUserById(ctx, u.Id); err != nil || got.LiveName != "" {
+			t.Errorf("LiveName of a deleted user = %q, %v; want it unset", got.LiveName, err)
+		}
+
+		// A deleted user no longer holds their name, so it can be tracked again.
+		if _, err := repo.GetUserByRunescapeName(ctx, "zezima"); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("GetUserByRunescapeName of a deleted user: got %v, want ErrNotFound", err)
+		}
+		reused := newUserData("Zezima", user.TrackingStatusEnabled)
+		if _, err := repo.CreateUser(ctx, reused); err != nil {
+			t.Fatalf("CreateUser with the name of a deleted user: %v", err)
+		}
+		if got, err := repo.GetUserByRunescapeName(ctx, "zezima"); err != nil || got.Id != reused.Id {
+			t.Errorf("GetUserByRunescapeName = %s, %v; want %s", got.Id, err, reused.Id)
+		}
+
+		merged := newUserData("Lynx Titan", user.TrackingStatusDisabled)
+		merged.MergedInto = reused.Id
+		if _, err := repo.CreateUser(ctx, merged); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		tombstones, err := repo.GetUsersMergedInto(ctx, reused.Id)
+		if err != nil || len(tombstones) != 1 || tombstones[0].Id != merged.Id {
+			t.Errorf("GetUsersMergedInto = %+v, %v; want [%s]", tombstones, err, merged.Id)
+		}
+	})
+
+	t.Run("NormalizedNamesAndAliases", func(t *testing.T) {
+		repo := newRepo(t)
+		u := newUserData("Iron_Hyger", user.TrackingStatusEnabled)
+		u.NameHistory = []user.NameChangeData{{OldName: "Old-Name", NewName: "Iron_Hyger", ChangedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}}
+		u.Aliases = []string{"old name"}
+		if _, err := repo.CreateUser(ctx, u); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+
+		for _, name := range []string{"iron hyger", " IRON-HYGER ", "iron__hyger"} {
+			if got, err := repo.GetUserByRunescapeName(ctx, name); err != nil || got.Id != u.Id {
+				t.Errorf("GetUserByRunescapeName(%q) = %s, %v; want %s", name, got.Id, err, u.Id)
+			}
+		}
+		if _, err := repo.GetUserByRunescapeName(ctx, "old name"); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("GetUserByRunescapeName of a past name: got %v, want ErrNotFound", err)
+		}
+
+		aliased, err := repo.GetUsersByAlias(ctx, "OLD_NAME")
+		if err != nil || len(aliased) != 1 || aliased[0].Id != u.Id {
+			t.Errorf("GetUsersByAlias = %+v, %v; want [%s]", aliased, err, u.Id)
+		}
+		if aliased, err := repo.GetUsersByAlias(ctx, "iron hyger"); err != nil || len(aliased) != 0 {
+			t.Errorf("GetUsersByAlias of a current name = %+v, %v; want none", aliased, err)
+		}
+	})
+
+	t.Run("QueryUsers", func(t *testing.T) {
+		repo := newRepo(t)
+		base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		ids := make(map[string]string)
+		for i, name := range []string{"iron hyger", "hyger", "iron man", "b0aty", "woox"} {
+			u := newUserData(name, user.TrackingStatusEnabled)
+			if name == "woox" {
+				u.TrackingStatus = string(user.TrackingStatusDisabled)
+			}
+			if strings.HasPrefix(name, "iron") {
+				u.AccountType = "IRONMAN"
+			}
+			createdAt := base.AddDate(0, 0, i)
+			u.CreatedAt = &createdAt
+			if _, err := repo.CreateUser(ctx, u); err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+			ids[name] = u.Id
+		}
+
+		names := func(query user.UserQueryData) []string {
+			t.Helper()
+			users, err := repo.QueryUsers(ctx, query)
+			if err != nil {
+				t.Fatalf("QueryUsers(%+v): %v", query, err)
+			}
+			var got []string
+			for _, u := range users {
+				got = append(got, u.NormalizedName)
+			}
+			return got
+		}
+
+		tests := map[string]struct {
+			query user.UserQueryData
+			want  []string
+		}{
+			"prefix":         {user.UserQueryData{Name: "iron", SortField: user.UserSortFieldName}, []string{"iron hyger", "iron man"}},
+			"contains":       {user.UserQueryData{Name: "hyger", NameContains: true, SortField: user.UserSortFieldName}, []string{"hyger", "iron hyger"}},
+			"tracking":       {user.UserQueryData{TrackingStatus: "DISABLED", SortField: user.UserSortFieldName}, []string{"woox"}},
+			"account type":   {user.UserQueryData{AccountType: "IRONMAN", SortField: user.UserSortFieldName, Descending: true}, []string{"iron man", "iron hyger"}},
+			"created after":  {user.UserQueryData{CreatedAfter: base.AddDate(0, 0, 2), SortField: user.UserSortFieldCreatedAt}, []string{"b0aty", "woox"}},
+			"newest first":   {user.UserQueryData{SortField: user.UserSortFieldCreatedAt, Descending: true, Limit: 2}, []string{"woox", "b0aty"}},
+			"after position": {user.UserQueryData{SortField: user.UserSortFieldName, After: &user.UserCursorData{NormalizedName: "hyger", Id: ids["hyger"]}, Limit: 2}, []string{"iron hyger", "iron man"}},
+			"after time": {user.UserQueryData{SortField: user.UserSortFieldCreatedAt, Descending: true,
+				After: &user.UserCursorData{CreatedAt: base.AddDate(0, 0, 2), Id: ids["iron man"]}}, []string{"hyger", "iron hyger"}},
+			"regex is quoted": {user.UserQueryData{Name: ".*", NameContains: true, SortField: user.UserSortFieldName}, nil},
+		}
+		for name, tt := range tests {
+			if got := names(tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: QueryUsers = %v, want %v", name, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("DeleteAndSoftDelete", func(t *testing.T) {
+		repo := newRepo(t)
+		kept := newUserData("woox", user.TrackingStatusEnabled)
+		softDeleted := newUserData("b0aty", user.TrackingStatusDisabled)
+		deletedAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+		softDeleted.DeletedAt = &deletedAt
+		purged := newUserData("zezima", user.TrackingStatusEnabled)
+		for _, u := range []user.UserData{kept, softDeleted, purged} {
+			if _, err := repo.CreateUser(ctx, u); err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+		}
+
+		if err := repo.DeleteUser(ctx, purged.Id); err != nil {
+			t.Fatalf("DeleteUser: %v", err)
+		}
+		if _, err := repo.GetUserById(ctx, purged.Id); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("GetUserById of a deleted user: got %v, want ErrNotFound", err)
+		}
+		if err := repo.DeleteUser(ctx, purged.Id); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("DeleteUser of a deleted user: got %v, want ErrNotFound", err)
+		}
+
+		// Soft-deleted users are still found by id, but not listed.
+		if got, err := repo.GetUserById(ctx, softDeleted.Id); err != nil || got.DeletedAt == nil || !got.DeletedAt.Equal(deletedAt) {
+			t.Errorf("GetUserById of a soft-deleted user = %+v, %v; want deletedAt %v", got, err, deletedAt)
+		}
+		all, err := repo.GetAllUsers(ctx)
+		if err != nil || len(all) != 1 || all[0].Id != kept.Id {
+			t.Errorf("GetAllUsers = %+v, %v; want only %s", all, err, kept.Id)
+		}
+		queried, err := repo.QueryUsers(ctx, user.UserQueryData{SortField: user.UserSortFieldName, Limit: 10})
+		if err != nil || len(queried) != 1 || queried[0].Id != kept.Id {
+			t.Errorf("QueryUsers = %+v, %v; want only %s", queried, err, kept.Id)
+		}
+	})
+}
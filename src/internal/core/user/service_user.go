@@ -2,10 +2,16 @@ package user
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"slices"
+	"time"
 
 	"github.com/ctfloyd/hazelmere-api/src/internal/database"
 	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
 	"github.com/google/uuid"
 )
 
@@ -13,12 +19,50 @@ var ErrUserGeneric = errors.New("an service_error occurred while performing the
 var ErrUserNotFound = errors.New("user not found")
 var ErrUserValidation = errors.New("user is invalid")
 var ErrRunescapeNameTracked = errors.New("runescape name tracked")
+var ErrInvalidUserQuery = errors.New("user query is invalid")
+
+const (
+	defaultUserQueryLimit = 100
+	maxUserQueryLimit     = 1000
+)
 
 type UserService interface {
 	GetUserById(ctx context.Context, id string) (User, error)
+	// GetUserByRunescapeName finds a user by their current name or, failing that, by a past
+	// name, which is reported by matchedAlias.
+	GetUserByRunescapeName(ctx context.Context, runescapeName string) (user User, matchedAlias bool, err error)
 	GetAllUsers(ctx context.Context) ([]User, error)
+	// GetUsersMergedInto returns the tombstones of the users merged into id.
+	GetUsersMergedInto(ctx context.Context, id string) ([]User, error)
+	// QueryUsers returns a page of the users matching query. Pages hold 100 users unless the
+	// query asks for fewer, and at most 1000.
+	QueryUsers(ctx context.Context, query UserQuery) (UserPage, error)
 	CreateUser(ctx context.Context, user User) (User, error)
+	// UpdateUser replaces the name, tracking status and account type of a user. An empty account
+	// type keeps the current one.
 	UpdateUser(ctx context.Context, user User) (User, error)
+	// RenameUser changes the name of a user, keeping the old one as an alias.
+	RenameUser(ctx context.Context, id string, runescapeName string) (User, error)
+	// PrepareMerge loads two users and checks that source can be merged into target, or that an
+	// unfinished merge of the two can be resumed.
+	PrepareMerge(ctx context.Context, sourceId string, targetId string) (source User, target User, err error)
+	// BeginMerge marks both users as being merged, which stops snapshots being taken of them and
+	// other changes to them until CompleteMerge.
+	BeginMerge(ctx context.Context, source User, target User) (User, User, error)
+	// CompleteMerge moves the names of source to target and leaves source as a nameless tombstone
+	// pointing at target. Moving their snapshots is up to the caller. Completing a merge that
+	// stopped part way does not record the names twice.
+	CompleteMerge(ctx context.Context, source User, target User) (User, error)
+	// SoftDeleteUser stops tracking a user, hides them from listings and frees their name,
+	// keeping their data. Deleting a deleted user returns them unchanged, and users in the middle
+	// of a merge cannot be deleted until it is finished.
+	SoftDeleteUser(ctx context.Context, id string) (User, error)
+	// DeleteUser removes the user record for good. Their snapshots and deltas are up to the caller.
+	DeleteUser(ctx context.Context, id string) error
+	// ReportAccountType records the account type a check found a user to have at detectedAt,
+	// and reports whether it changed. Reports older than the last change are ignored, since
+	// checks may finish out of order.
+	ReportAccountType(ctx context.Context, id string, accountType AccountType, detectedAt time.Time) (user User, changed bool, err error)
 }
 
 type userService struct {
@@ -50,6 +94,58 @@ func (us *userService) GetUserById(ctx context.Context, id string) (User, error)
 	return User{}.FromData(data), nil
 }
 
+func (us *userService) GetUserByRunescapeName(ctx context.Context, runescapeName string) (User, bool, error) {
+	ctx, span := us.monitor.StartSpan(ctx, "userService.GetUserByRunescapeName")
+	defer span.End()
+
+	// Deleted and merged users are not found by name. The names of merged users moved to the
+	// user they were merged into, and are found below as aliases.
+	data, err := us.repository.GetUserByRunescapeName(ctx, runescapeName)
+	if err == nil {
+		return User{}.FromData(data), false, nil
+	}
+	if !errors.Is(err, database.ErrNotFound) {
+		return User{}, false, errors.Join(ErrUserGeneric, err)
+	}
+
+	aliased, err := us.repository.GetUsersByAlias(ctx, runescapeName)
+	if err != nil {
+		return User{}, false, errors.Join(ErrUserGeneric, err)
+	}
+	if len(aliased) == 0 {
+		return User{}, false, ErrUserNotFound
+	}
+
+	// A name freed by a rename can be taken and given up again, so several users may have held
+	// it. The one who gave it up last is the best guess.
+	// Merged users are skipped, since their names moved to the user they were merged into.
+	var found User
+	var renamedAt time.Time
+	for _, candidate := range (User{}).ManyFromData(aliased) {
+		if candidate.isMerged() || candidate.isDeleted() {
+			continue
+		}
+		if at, ok := candidate.renamedFrom(runescapeName); ok && !at.Before(renamedAt) {
+			found, renamedAt = candidate, at
+		}
+	}
+	if found.Id == "" {
+		return User{}, false, ErrUserNotFound
+	}
+	return found, true, nil
+}
+
+func (us *userService) GetUsersMergedInto(ctx context.Context, id string) ([]User, error) {
+	ctx, span := us.monitor.StartSpan(ctx, "userService.GetUsersMergedInto")
+	defer span.End()
+
+	data, err := us.repository.GetUsersMergedInto(ctx, id)
+	if err != nil {
+		return []User{}, errors.Join(ErrUserGeneric, err)
+	}
+	return User{}.ManyFromData(data), nil
+}
+
 func (us *userService) GetAllUsers(ctx context.Context) ([]User, error) {
 	ctx, span := us.monitor.StartSpan(ctx, "userService.GetAllUsers")
 	defer span.End()
@@ -61,11 +157,91 @@ func (us *userService) GetAllUsers(ctx context.Context) ([]User, error) {
 	return User{}.ManyFromData(data), nil
 }
 
+func (us *userService) QueryUsers(ctx context.Context, query UserQuery) (UserPage, error) {
+	ctx, span := us.monitor.StartSpan(ctx, "userService.QueryUsers")
+	defer span.End()
+
+	if err := us.validator.ValidateQuery(query); err != nil {
+		return UserPage{}, errors.Join(ErrInvalidUserQuery, err)
+	}
+	if query.Sort == "" {
+		query.Sort = UserSortName
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultUserQueryLimit
+	}
+	query.Limit = min(query.Limit, maxUserQueryLimit)
+
+	var after *UserCursorData
+	if query.Cursor != "" {
+		cursor, err := decodeUserCursor(query.Cursor, query.Sort)
+		if err != nil {
+			return UserPage{}, errors.Join(ErrInvalidUserQuery, err)
+		}
+		after = &cursor
+	}
+
+	// One more user than the page holds tells whether there is a next page.
+	data := query.ToData(after)
+	data.Limit = query.Limit + 1
+	results, err := us.repository.QueryUsers(ctx, data)
+	if err != nil {
+		return UserPage{}, errors.Join(ErrUserGeneric, err)
+	}
+
+	page := UserPage{Users: User{}.ManyFromData(results)}
+	if len(page.Users) > query.Limit {
+		page.Users = page.Users[:query.Limit]
+		page.NextCursor = encodeUserCursor(page.Users[query.Limit-1], query.Sort)
+	}
+	return page, nil
+}
+
+// userCursor is the opaque cursor handed to clients. It records the sort it was made for, since
+// a position in one sort means nothing in another.
+type userCursor struct {
+	Sort UserSort `json:"s"`
+	UserCursorData
+}
+
+func encodeUserCursor(u User, sort UserSort) string {
+	cursor := userCursor{Sort: sort, UserCursorData: UserCursorData{Id: u.Id}}
+	if sort == UserSortCreatedAt || sort == UserSortCreatedAtDesc {
+		if u.CreatedAt != nil {
+			cursor.CreatedAt = *u.CreatedAt
+		}
+	} else {
+		cursor.NormalizedName = api.NormalizeRunescapeName(u.RunescapeName)
+	}
+	encoded, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+func decodeUserCursor(value string, sort UserSort) (UserCursorData, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return UserCursorData{}, errors.New("cursor is malformed")
+	}
+	var cursor userCursor
+	if err := json.Unmarshal(decoded, &cursor); err != nil || cursor.Id == "" {
+		return UserCursorData{}, errors.New("cursor is malformed")
+	}
+	if cursor.Sort != sort {
+		return UserCursorData{}, fmt.Errorf("cursor is for sort %s, not %s", cursor.Sort, sort)
+	}
+	return cursor.UserCursorData, nil
+}
+
 func (us *userService) CreateUser(ctx context.Context, user User) (User, error) {
 	ctx, span := us.monitor.StartSpan(ctx, "userService.CreateUser")
 	defer span.End()
 
 	user.Id = uuid.New().String()
+	createdAt := time.Now()
+	user.CreatedAt = &createdAt
+	if user.AccountType == "" {
+		user.AccountType = AccountTypeNormal
+	}
 
 	err := us.validator.ValidateUser(user)
 	if err != nil {
@@ -93,28 +269,72 @@ func (us *userService) UpdateUser(ctx context.Context, user User) (User, error)
 	ctx, span := us.monitor.StartSpan(ctx, "userService.UpdateUser")
 	defer span.End()
 
-	_, err := us.GetUserById(ctx, user.Id)
+	existing, err := us.GetUserById(ctx, user.Id)
 	if err != nil {
 		if errors.Is(err, database.ErrNotFound) {
 			return User{}, ErrUserNotFound
 		}
 		return User{}, errors.Join(ErrUserGeneric, err)
 	}
+	if err := ensureWritable(existing); err != nil {
+		return User{}, err
+	}
+	if user.AccountType == "" {
+		user.AccountType = existing.AccountType
+	}
 
 	err = us.validator.ValidateUser(user)
 	if err != nil {
 		return User{}, errors.Join(ErrUserValidation, err)
 	}
 
-	existingUser, err := us.repository.GetUserByRunescapeName(ctx, user.RunescapeName)
+	if err := us.ensureNameAvailable(ctx, user.Id, user.RunescapeName); err != nil {
+		return User{}, err
+	}
+
+	// Renaming through an update keeps the old name as an alias, as RenameUser does, and changing
+	// the account type is recorded as a manual change.
+	now := time.Now()
+	accountType := user.AccountType
+	user.NameHistory = existing.NameHistory
+	user.AccountType = existing.AccountType
+	user.AccountTypeHistory = existing.AccountTypeHistory
+	user.CreatedAt = existing.CreatedAt
+	user.recordRename(existing.RunescapeName, now)
+	user.recordAccountTypeChange(accountType, now, AccountTypeSourceManual)
+
+	data, err := us.repository.UpdateUser(ctx, user.ToData())
 	if err != nil {
-		if !errors.Is(err, database.ErrNotFound) {
-			return User{}, errors.Join(ErrUserGeneric, err)
-		}
-	} else if existingUser.Id != user.Id {
-		return User{}, ErrRunescapeNameTracked
+		return User{}, errors.Join(ErrUserGeneric, err)
 	}
 
+	return User{}.FromData(data), nil
+}
+
+func (us *userService) RenameUser(ctx context.Context, id string, runescapeName string) (User, error) {
+	ctx, span := us.monitor.StartSpan(ctx, "userService.RenameUser")
+	defer span.End()
+
+	user, err := us.GetUserById(ctx, id)
+	if err != nil {
+		return User{}, err
+	}
+	if err := ensureWritable(user); err != nil {
+		return User{}, err
+	}
+
+	if err := us.validator.ValidateRunescapeName(runescapeName); err != nil {
+		return User{}, errors.Join(ErrUserValidation, err)
+	}
+
+	if err := us.ensureNameAvailable(ctx, id, runescapeName); err != nil {
+		return User{}, err
+	}
+
+	oldName := user.RunescapeName
+	user.RunescapeName = runescapeName
+	user.recordRename(oldName, time.Now())
+
 	data, err := us.repository.UpdateUser(ctx, user.ToData())
 	if err != nil {
 		return User{}, errors.Join(ErrUserGeneric, err)
@@ -122,3 +342,194 @@ func (us *userService) UpdateUser(ctx context.Context, user User) (User, error)
 
 	return User{}.FromData(data), nil
 }
+
+func (us *userService) PrepareMerge(ctx context.Context, sourceId string, targetId string) (User, User, error) {
+	ctx, span := us.monitor.StartSpan(ctx, "userService.PrepareMerge")
+	defer span.End()
+
+	source, err := us.GetUserById(ctx, sourceId)
+	if err != nil {
+		return User{}, User{}, err
+	}
+	target, err := us.GetUserById(ctx, targetId)
+	if err != nil {
+		return User{}, User{}, err
+	}
+
+	if err := us.validator.ValidateMerge(source, target); err != nil {
+		return User{}, User{}, errors.Join(ErrUserValidation, err)
+	}
+	return source, target, nil
+}
+
+func (us *userService) BeginMerge(ctx context.Context, source User, target User) (User, User, error) {
+	ctx, span := us.monitor.StartSpan(ctx, "userService.BeginMerge")
+	defer span.End()
+
+	if err := us.validator.ValidateMerge(source, target); err != nil {
+		return User{}, User{}, errors.Join(ErrUserValidation, err)
+	}
+
+	source.MergingWith = target.Id
+	target.MergingWith = source.Id
+	for _, u := range []User{source, target} {
+		if _, err := us.repository.UpdateUser(ctx, u.ToData()); err != nil {
+			return User{}, User{}, errors.Join(ErrUserGeneric, err)
+		}
+	}
+	return source, target, nil
+}
+
+func (us *userService) CompleteMerge(ctx context.Context, source User, target User) (User, error) {
+	ctx, span := us.monitor.StartSpan(ctx, "userService.CompleteMerge")
+	defer span.End()
+
+	if err := us.validator.ValidateMerge(source, target); err != nil {
+		return User{}, errors.Join(ErrUserValidation, err)
+	}
+
+	// The target takes over the past names of the source, and the current one as a rename, so
+	// lookups by any of them find it. A merge resumed after the target was saved finds them there.
+	now := time.Now()
+	history := slices.Clone(target.NameHistory)
+	for _, change := range source.NameHistory {
+		if !slices.ContainsFunc(history, change.Equal) {
+			history = append(history, change)
+		}
+	}
+	slices.SortStableFunc(history, func(a, b NameChange) int { return a.ChangedAt.Compare(b.ChangedAt) })
+	target.NameHistory = history
+	if _, ok := target.renamedFrom(source.RunescapeName); !ok {
+		target.recordRename(source.RunescapeName, now)
+	}
+	target.MergingWith = ""
+
+	// The tombstone gives up its name, which now belongs to the target.
+	source.RunescapeName = ""
+	source.MergedInto = target.Id
+	source.MergedAt = &now
+	source.MergingWith = ""
+	source.TrackingStatus = TrackingStatusDisabled
+
+	data, err := us.repository.UpdateUser(ctx, target.ToData())
+	if err != nil {
+		return User{}, errors.Join(ErrUserGeneric, err)
+	}
+	if _, err := us.repository.UpdateUser(ctx, source.ToData()); err != nil {
+		return User{}, errors.Join(ErrUserGeneric, err)
+	}
+
+	return User{}.FromData(data), nil
+}
+
+func (us *userService) SoftDeleteUser(ctx context.Context, id string) (User, error) {
+	ctx, span := us.monitor.StartSpan(ctx, "userService.SoftDeleteUser")
+	defer span.End()
+
+	user, err := us.GetUserById(ctx, id)
+	if err != nil {
+		return User{}, err
+	}
+	if user.isDeleted() {
+		return user, nil
+	}
+	// A merge resumed after the delete would be refused, leaving the other user locked.
+	if err := EnsureNotMerging(user); err != nil {
+		return User{}, err
+	}
+
+	now := time.Now()
+	user.DeletedAt = &now
+	user.TrackingStatus = TrackingStatusDisabled
+
+	data, err := us.repository.UpdateUser(ctx, user.ToData())
+	if err != nil {
+		return User{}, errors.Join(ErrUserGeneric, err)
+	}
+	return User{}.FromData(data), nil
+}
+
+func (us *userService) DeleteUser(ctx context.Context, id string) error {
+	ctx, span := us.monitor.StartSpan(ctx, "userService.DeleteUser")
+	defer span.End()
+
+	if err := us.repository.DeleteUser(ctx, id); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrUserNotFound
+		}
+		return errors.Join(ErrUserGeneric, err)
+	}
+	return nil
+}
+
+func (us *userService) ReportAccountType(ctx context.Context, id string, accountType AccountType, detectedAt time.Time) (User, bool, error) {
+	ctx, span := us.monitor.StartSpan(ctx, "userService.ReportAccountType")
+	defer span.End()
+
+	if err := us.validator.ValidateAccountType(accountType); err != nil {
+		return User{}, false, errors.Join(ErrUserValidation, err)
+	}
+
+	user, err := us.GetUserById(ctx, id)
+	if err != nil {
+		return User{}, false, err
+	}
+	if err := ensureWritable(user); err != nil {
+		return User{}, false, err
+	}
+
+	if last, ok := user.lastAccountTypeChange(); ok && detectedAt.Before(last) {
+		return user, false, nil
+	}
+	if !user.recordAccountTypeChange(accountType, detectedAt, AccountTypeSourceDetected) {
+		return user, false, nil
+	}
+
+	data, err := us.repository.UpdateUser(ctx, user.ToData())
+	if err != nil {
+		return User{}, false, errors.Join(ErrUserGeneric, err)
+	}
+
+	us.monitor.Logger().InfoArgs(ctx, "Account type of user %s changed to %s", id, accountType)
+	return User{}.FromData(data), true, nil
+}
+
+// ensureWritable fails for users that only remain as a record, merged or deleted ones, and for
+// users in the middle of a merge.
+func ensureWritable(user User) error {
+	if user.isMerged() {
+		return errors.Join(ErrUserValidation, fmt.Errorf("user was merged into %s", user.MergedInto))
+	}
+	if err := EnsureNotMerging(user); err != nil {
+		return err
+	}
+	if user.isDeleted() {
+		return errors.Join(ErrUserValidation, errors.New("user was deleted"))
+	}
+	return nil
+}
+
+// EnsureNotMerging fails for users in the middle of a merge, which must not be deleted until the
+// merge is finished.
+func EnsureNotMerging(user User) error {
+	if user.isMerging() {
+		return errors.Join(ErrUserValidation, fmt.Errorf("user is being merged with %s", user.MergingWith))
+	}
+	return nil
+}
+
+// ensureNameAvailable fails when another user currently holds runescapeName. Past names are
+// free to take, since OSRS releases them on a rename.
+func (us *userService) ensureNameAvailable(ctx context.Context, id string, runescapeName string) error {
+	existing, err := us.repository.GetUserByRunescapeName(ctx, runescapeName)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil
+		}
+		return errors.Join(ErrUserGeneric, err)
+	}
+	if existing.Id != id {
+		return ErrRunescapeNameTracked
+	}
+	return nil
+}
@@ -1,8 +1,73 @@
 package user
 
+import "time"
+
 type UserData struct {
 	Id             string `bson:"_id"`
 	RunescapeName  string `bson:"runescapeName"`
 	TrackingStatus string `bson:"trackingStatus"`
 	AccountType    string `bson:"accountType"`
+	// NormalizedName is RunescapeName as compared by lookups. The repository keeps it in sync.
+	NormalizedName string `bson:"normalizedName"`
+	// LiveName is NormalizedName on users that are neither deleted nor merged, and unset on the
+	// rest, so that its unique index allows one live user per name. The repository keeps it in sync.
+	LiveName    string           `bson:"liveName,omitempty"`
+	NameHistory []NameChangeData `bson:"nameHistory,omitempty"`
+	// Aliases holds the normalized past names in NameHistory, so lookups by them can use an index.
+	Aliases []string `bson:"aliases,omitempty"`
+	// AccountTypeHistory lists the account type changes of the user, oldest first.
+	AccountTypeHistory []AccountTypeChangeData `bson:"accountTypeHistory,omitempty"`
+	// MergedInto is set on a user whose history was merged into another user, leaving a tombstone.
+	MergedInto string     `bson:"mergedInto,omitempty"`
+	MergedAt   *time.Time `bson:"mergedAt,omitempty"`
+	// MergingWith is set on both users of a merge that has started but not completed, to the id
+	// of the other user. No snapshots are taken of them meanwhile.
+	MergingWith string `bson:"mergingWith,omitempty"`
+	// CreatedAt is when the user started being tracked. Users created before it was recorded
+	// were backfilled with the time of their first snapshot.
+	CreatedAt *time.Time `bson:"createdAt,omitempty"`
+	// DeletedAt is set on a soft-deleted user, which listings leave out.
+	DeletedAt *time.Time `bson:"deletedAt,omitempty"`
+}
+
+type NameChangeData struct {
+	OldName   string    `bson:"oldName"`
+	NewName   string    `bson:"newName"`
+	ChangedAt time.Time `bson:"changedAt"`
+}
+
+type AccountTypeChangeData struct {
+	From      string    `bson:"from"`
+	To        string    `bson:"to"`
+	ChangedAt time.Time `bson:"changedAt"`
+	Source    string    `bson:"source"`
+}
+
+// Fields users can be sorted by. Ties are broken by id, so every sort is a total order.
+const (
+	UserSortFieldName      = "normalizedName"
+	UserSortFieldCreatedAt = "createdAt"
+)
+
+// UserQueryData selects a page of users. Zero filters are ignored. After, when set, is the last
+// user of the previous page, and only users sorted after it are returned.
+type UserQueryData struct {
+	// Name is a normalized name matched as a prefix of normalizedName, or anywhere in it with
+	// NameContains.
+	Name           string
+	NameContains   bool
+	TrackingStatus string
+	AccountType    string
+	CreatedAfter   time.Time
+	SortField      string
+	Descending     bool
+	After          *UserCursorData
+	Limit          int
+}
+
+// UserCursorData is the position of a user in a sorted query.
+type UserCursorData struct {
+	NormalizedName string    `json:"n,omitempty"`
+	CreatedAt      time.Time `json:"c,omitzero"`
+	Id             string    `json:"i"`
 }
@@ -1,6 +1,12 @@
 package user
 
-import "github.com/ctfloyd/hazelmere-api/src/pkg/api"
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+)
 
 type TrackingStatus string
 
@@ -55,11 +61,186 @@ func AccountTypeFromValue(value string) AccountType {
 	return AccountTypeNormal
 }
 
+var AllAccountTypes = []AccountType{
+	AccountTypeNormal,
+	AccountTypeIronman,
+	AccountTypeHardcoreIronman,
+	AccountTypeUltimateIronman,
+	AccountTypeGroupIronman,
+}
+
+// accountTypeFromRequest keeps an unknown account type as given, so validation rejects it
+// instead of the user silently being tracked as NORMAL. Leaving it out still means NORMAL.
+func accountTypeFromRequest(value api.AccountType) AccountType {
+	if value == "" {
+		return AccountTypeNormal
+	}
+	return AccountType(value)
+}
+
+type AccountTypeSource string
+
+const (
+	AccountTypeSourceManual   AccountTypeSource = "MANUAL"
+	AccountTypeSourceDetected AccountTypeSource = "DETECTED"
+)
+
+func AccountTypeSourceFromValue(value string) AccountTypeSource {
+	if value == string(AccountTypeSourceDetected) {
+		return AccountTypeSourceDetected
+	}
+
+	return AccountTypeSourceManual
+}
+
 type User struct {
 	Id             string         `json:"id"`
 	RunescapeName  string         `json:"runescapeName"`
 	TrackingStatus TrackingStatus `json:"trackingStatus"`
 	AccountType    AccountType    `json:"accountType"`
+	NameHistory    []NameChange   `json:"nameHistory"`
+	// AccountTypeHistory lists the account type changes of the user, oldest first.
+	AccountTypeHistory []AccountTypeChange `json:"accountTypeHistory"`
+	MergedInto         string              `json:"mergedInto"`
+	MergedAt           *time.Time          `json:"mergedAt"`
+	MergingWith        string              `json:"mergingWith,omitempty"`
+	CreatedAt          *time.Time          `json:"createdAt"`
+	DeletedAt          *time.Time          `json:"deletedAt"`
+}
+
+type NameChange struct {
+	OldName   string    `json:"oldName"`
+	NewName   string    `json:"newName"`
+	ChangedAt time.Time `json:"changedAt"`
+}
+
+// Equal reports whether both record the same rename.
+func (c NameChange) Equal(other NameChange) bool {
+	return c.OldName == other.OldName && c.NewName == other.NewName && c.ChangedAt.Equal(other.ChangedAt)
+}
+
+type AccountTypeChange struct {
+	From      AccountType       `json:"from"`
+	To        AccountType       `json:"to"`
+	ChangedAt time.Time         `json:"changedAt"`
+	Source    AccountTypeSource `json:"source"`
+}
+
+// AccountTypeTimeline is the account type of a user over time: their current type and the
+// changes that led to it, oldest first.
+type AccountTypeTimeline struct {
+	Current AccountType
+	Changes []AccountTypeChange
+}
+
+// NameMatch is how UserQuery.Name is matched against names.
+type NameMatch string
+
+const (
+	NameMatchPrefix   NameMatch = "prefix"
+	NameMatchContains NameMatch = "contains"
+)
+
+// UserSort orders the users of a query. A leading '-' sorts descending.
+type UserSort string
+
+const (
+	UserSortName          UserSort = "name"
+	UserSortNameDesc      UserSort = "-name"
+	UserSortCreatedAt     UserSort = "createdAt"
+	UserSortCreatedAtDesc UserSort = "-createdAt"
+)
+
+var AllUserSorts = []UserSort{UserSortName, UserSortNameDesc, UserSortCreatedAt, UserSortCreatedAtDesc}
+
+// UserQuery searches users a page at a time. Zero values are ignored. Names are compared
+// normalized, as lookups by name are.
+type UserQuery struct {
+	Name           string
+	NameMatch      NameMatch
+	TrackingStatus TrackingStatus
+	AccountType    AccountType
+	CreatedAfter   time.Time
+	Sort           UserSort
+	// Cursor is the NextCursor of the previous page.
+	Cursor string
+	Limit  int
+}
+
+// UserPage is a page of users. NextCursor is empty on the last page.
+type UserPage struct {
+	Users      []User
+	NextCursor string
+}
+
+// Aliases returns the normalized past names of the user, without their current name.
+func (u User) Aliases() []string {
+	current := api.NormalizeRunescapeName(u.RunescapeName)
+	var aliases []string
+	for _, change := range u.NameHistory {
+		alias := api.NormalizeRunescapeName(change.OldName)
+		if alias != current && !slices.Contains(aliases, alias) {
+			aliases = append(aliases, alias)
+		}
+	}
+	return aliases
+}
+
+// renamedFrom returns when the user last changed away from a name, and whether they ever did.
+func (u User) renamedFrom(name string) (time.Time, bool) {
+	normalized := api.NormalizeRunescapeName(name)
+	for _, change := range slices.Backward(u.NameHistory) {
+		if api.NormalizeRunescapeName(change.OldName) == normalized {
+			return change.ChangedAt, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// recordRename adds a rename from oldName to the current name to the history. Changes in case or
+// separators only are not renames, so they are not recorded.
+func (u *User) recordRename(oldName string, at time.Time) {
+	if api.NormalizeRunescapeName(oldName) == api.NormalizeRunescapeName(u.RunescapeName) {
+		return
+	}
+	u.NameHistory = append(slices.Clone(u.NameHistory), NameChange{OldName: oldName, NewName: u.RunescapeName, ChangedAt: at})
+}
+
+// recordAccountTypeChange switches the user to accountType, adding the change to the history. It
+// reports whether the type changed.
+func (u *User) recordAccountTypeChange(accountType AccountType, at time.Time, source AccountTypeSource) bool {
+	if accountType == u.AccountType {
+		return false
+	}
+	change := AccountTypeChange{From: u.AccountType, To: accountType, ChangedAt: at, Source: source}
+	u.AccountTypeHistory = append(slices.Clone(u.AccountTypeHistory), change)
+	u.AccountType = accountType
+	return true
+}
+
+// lastAccountTypeChange returns when the account type last changed, and whether it ever did.
+func (u User) lastAccountTypeChange() (time.Time, bool) {
+	if len(u.AccountTypeHistory) == 0 {
+		return time.Time{}, false
+	}
+	return u.AccountTypeHistory[len(u.AccountTypeHistory)-1].ChangedAt, true
+}
+
+// AccountTypeTimeline returns the account type of the user over time.
+func (u User) AccountTypeTimeline() AccountTypeTimeline {
+	return AccountTypeTimeline{Current: u.AccountType, Changes: u.AccountTypeHistory}
+}
+
+func (u User) isMerged() bool {
+	return u.MergedInto != ""
+}
+
+func (u User) isMerging() bool {
+	return u.MergingWith != ""
+}
+
+func (u User) isDeleted() bool {
+	return u.DeletedAt != nil
 }
 
 func (u User) isTrackingEnabled() bool {
@@ -73,10 +254,16 @@ func (u User) isTrackingDisabled() bool {
 // ToAPI converts the domain User to an API User
 func (u User) ToAPI() api.User {
 	return api.User{
-		Id:             u.Id,
-		RunescapeName:  u.RunescapeName,
-		TrackingStatus: api.TrackingStatusFromValue(string(u.TrackingStatus)),
-		AccountType:    api.AccountTypeFromValue(string(u.AccountType)),
+		Id:                 u.Id,
+		RunescapeName:      u.RunescapeName,
+		TrackingStatus:     api.TrackingStatusFromValue(string(u.TrackingStatus)),
+		AccountType:        api.AccountTypeFromValue(string(u.AccountType)),
+		NameHistory:        NameChange{}.ManyToAPI(u.NameHistory),
+		AccountTypeHistory: AccountTypeChange{}.ManyToAPI(u.AccountTypeHistory),
+		MergedInto:         u.MergedInto,
+		MergedAt:           u.MergedAt,
+		CreatedAt:          u.CreatedAt,
+		DeletedAt:          u.DeletedAt,
 	}
 }
 
@@ -95,7 +282,7 @@ func (User) FromCreateRequest(request api.CreateUserRequest) User {
 	return User{
 		RunescapeName:  request.RunescapeName,
 		TrackingStatus: TrackingStatusFromValue(string(request.TrackingStatus)),
-		AccountType:    AccountTypeFromValue(string(request.AccountType)),
+		AccountType:    accountTypeFromRequest(request.AccountType),
 	}
 }
 
@@ -105,27 +292,64 @@ func (User) FromUpdateRequest(request api.UpdateUserRequest) User {
 		Id:             request.Id,
 		RunescapeName:  request.RunescapeName,
 		TrackingStatus: TrackingStatusFromValue(string(request.TrackingStatus)),
-		AccountType:    AccountTypeFromValue(string(request.AccountType)),
+		// Left empty when omitted, so UpdateUser keeps the current account type.
+		AccountType: AccountType(request.AccountType),
 	}
 }
 
 // ToData converts the domain User to a data layer UserData
 func (u User) ToData() UserData {
 	return UserData{
-		Id:             u.Id,
-		RunescapeName:  u.RunescapeName,
-		TrackingStatus: string(u.TrackingStatus),
-		AccountType:    string(u.AccountType),
+		Id:                 u.Id,
+		RunescapeName:      u.RunescapeName,
+		TrackingStatus:     string(u.TrackingStatus),
+		AccountType:        string(u.AccountType),
+		NormalizedName:     api.NormalizeRunescapeName(u.RunescapeName),
+		NameHistory:        NameChange{}.ManyToData(u.NameHistory),
+		Aliases:            u.Aliases(),
+		AccountTypeHistory: AccountTypeChange{}.ManyToData(u.AccountTypeHistory),
+		MergedInto:         u.MergedInto,
+		MergedAt:           u.MergedAt,
+		MergingWith:        u.MergingWith,
+		CreatedAt:          u.CreatedAt,
+		DeletedAt:          u.DeletedAt,
 	}
 }
 
 // FromData creates a domain User from data layer UserData (call as User{}.FromData(...))
 func (User) FromData(userData UserData) User {
 	return User{
-		Id:             userData.Id,
-		RunescapeName:  userData.RunescapeName,
-		TrackingStatus: TrackingStatusFromValue(userData.TrackingStatus),
-		AccountType:    AccountTypeFromValue(userData.AccountType),
+		Id:                 userData.Id,
+		RunescapeName:      userData.RunescapeName,
+		TrackingStatus:     TrackingStatusFromValue(userData.TrackingStatus),
+		AccountType:        AccountTypeFromValue(userData.AccountType),
+		NameHistory:        NameChange{}.ManyFromData(userData.NameHistory),
+		AccountTypeHistory: AccountTypeChange{}.ManyFromData(userData.AccountTypeHistory),
+		MergedInto:         userData.MergedInto,
+		MergedAt:           userData.MergedAt,
+		MergingWith:        userData.MergingWith,
+		CreatedAt:          userData.CreatedAt,
+		DeletedAt:          userData.DeletedAt,
+	}
+}
+
+// ToData converts the domain UserQuery to a data layer UserQueryData, resuming after the given
+// cursor position.
+func (q UserQuery) ToData(after *UserCursorData) UserQueryData {
+	sortField := UserSortFieldName
+	if q.Sort == UserSortCreatedAt || q.Sort == UserSortCreatedAtDesc {
+		sortField = UserSortFieldCreatedAt
+	}
+	return UserQueryData{
+		Name:           api.NormalizeRunescapeName(q.Name),
+		NameContains:   q.NameMatch == NameMatchContains,
+		TrackingStatus: string(q.TrackingStatus),
+		AccountType:    string(q.AccountType),
+		CreatedAfter:   q.CreatedAfter,
+		SortField:      sortField,
+		Descending:     strings.HasPrefix(string(q.Sort), "-"),
+		After:          after,
+		Limit:          q.Limit,
 	}
 }
 
@@ -146,3 +370,93 @@ func (User) ManyFromData(userData []UserData) []User {
 	}
 	return users
 }
+
+// ManyToAPI converts name changes to API name changes (call as NameChange{}.ManyToAPI(...))
+func (NameChange) ManyToAPI(changes []NameChange) []api.NameChange {
+	if len(changes) == 0 {
+		return nil
+	}
+	apiChanges := make([]api.NameChange, len(changes))
+	for i, change := range changes {
+		apiChanges[i] = api.NameChange{OldName: change.OldName, NewName: change.NewName, ChangedAt: change.ChangedAt}
+	}
+	return apiChanges
+}
+
+// ManyToData converts name changes to data layer name changes (call as NameChange{}.ManyToData(...))
+func (NameChange) ManyToData(changes []NameChange) []NameChangeData {
+	if len(changes) == 0 {
+		return nil
+	}
+	data := make([]NameChangeData, len(changes))
+	for i, change := range changes {
+		data[i] = NameChangeData{OldName: change.OldName, NewName: change.NewName, ChangedAt: change.ChangedAt}
+	}
+	return data
+}
+
+// ManyFromData converts data layer name changes to name changes (call as NameChange{}.ManyFromData(...))
+func (NameChange) ManyFromData(data []NameChangeData) []NameChange {
+	if len(data) == 0 {
+		return nil
+	}
+	changes := make([]NameChange, len(data))
+	for i, change := range data {
+		changes[i] = NameChange{OldName: change.OldName, NewName: change.NewName, ChangedAt: change.ChangedAt}
+	}
+	return changes
+}
+
+// ToAPI converts the account type of a timeline to its API form.
+func (t AccountTypeTimeline) ToAPI() (api.AccountType, []api.AccountTypeChange) {
+	if t.Current == "" {
+		return "", nil
+	}
+	return api.AccountTypeFromValue(string(t.Current)), AccountTypeChange{}.ManyToAPI(t.Changes)
+}
+
+// ManyToAPI converts account type changes to API changes (call as AccountTypeChange{}.ManyToAPI(...))
+func (AccountTypeChange) ManyToAPI(changes []AccountTypeChange) []api.AccountTypeChange {
+	if len(changes) == 0 {
+		return nil
+	}
+	apiChanges := make([]api.AccountTypeChange, len(changes))
+	for i, change := range changes {
+		apiChanges[i] = api.AccountTypeChange{
+			From:      api.AccountTypeFromValue(string(change.From)),
+			To:        api.AccountTypeFromValue(string(change.To)),
+			ChangedAt: change.ChangedAt,
+			Source:    api.AccountTypeSource(change.Source),
+		}
+	}
+	return apiChanges
+}
+
+// ManyToData converts account type changes to data layer changes (call as AccountTypeChange{}.ManyToData(...))
+func (AccountTypeChange) ManyToData(changes []AccountTypeChange) []AccountTypeChangeData {
+	if len(changes) == 0 {
+		return nil
+	}
+	data := make([]AccountTypeChangeData, len(changes))
+	for i, change := range changes {
+		data[i] = AccountTypeChangeData{From: string(change.From), To: string(change.To), ChangedAt: change.ChangedAt, Source: string(change.Source)}
+	}
+	return data
+}
+
+// ManyFromData converts data layer changes to account type changes (call as AccountTypeChange{}.ManyFromData(...))
+func (AccountTypeChange) ManyFromData(data []AccountTypeChangeData) []AccountTypeChange {
+	if len(data) == 0 {
+		return nil
+	}
+	changes := make([]AccountTypeChange, len(data))
+	for i, change := range data {
+		changes[i] = AccountTypeChange{
+			From:      AccountTypeFromValue(change.From),
+			To:        AccountTypeFromValue(change.To),
+			ChangedAt: change.ChangedAt,
+			Source:    AccountTypeSourceFromValue(change.Source),
+		}
+	}
+	return changes
+}
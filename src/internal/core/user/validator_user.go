@@ -1,7 +1,23 @@
 package user
 
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"unicode"
+
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+)
+
+// MaxRunescapeNameLength is the longest name OSRS allows.
+const MaxRunescapeNameLength = 12
+
 type UserValidator interface {
 	ValidateUser(user User) error
+	ValidateRunescapeName(name string) error
+	ValidateAccountType(accountType AccountType) error
+	ValidateMerge(source User, target User) error
+	ValidateQuery(query UserQuery) error
 }
 
 type userValidator struct {
@@ -12,5 +28,76 @@ func NewUserValidator() UserValidator {
 }
 
 func (uv *userValidator) ValidateUser(user User) error {
+	if err := uv.ValidateRunescapeName(user.RunescapeName); err != nil {
+		return err
+	}
+	return uv.ValidateAccountType(user.AccountType)
+}
+
+func (uv *userValidator) ValidateAccountType(accountType AccountType) error {
+	if !slices.Contains(AllAccountTypes, accountType) {
+		return fmt.Errorf("accountType must be one of %v", AllAccountTypes)
+	}
+	return nil
+}
+
+// ValidateRunescapeName accepts the names OSRS does: letters, digits, spaces, '_' and '-', at
+// most twelve of them.
+func (uv *userValidator) ValidateRunescapeName(name string) error {
+	if api.NormalizeRunescapeName(name) == "" {
+		return errors.New("runescapeName is required")
+	}
+	if len([]rune(name)) > MaxRunescapeNameLength {
+		return errors.New("runescapeName must be at most 12 characters")
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != ' ' && r != '_' && r != '-' {
+			return errors.New("runescapeName may only contain letters, digits, spaces, '_' and '-'")
+		}
+	}
+	return nil
+}
+
+// ValidateMerge accepts merging two distinct users, neither of which was merged before nor is
+// being merged with a third user.
+func (uv *userValidator) ValidateMerge(source User, target User) error {
+	if source.Id == target.Id {
+		return errors.New("a user cannot be merged into itself")
+	}
+	for _, pair := range [][2]User{{source, target}, {target, source}} {
+		if pair[0].isMerging() && pair[0].MergingWith != pair[1].Id {
+			return fmt.Errorf("user %s is being merged with %s", pair[0].Id, pair[0].MergingWith)
+		}
+	}
+	if source.isMerged() {
+		return fmt.Errorf("user %s was already merged into %s", source.Id, source.MergedInto)
+	}
+	if target.isMerged() {
+		return fmt.Errorf("user %s was merged into %s", target.Id, target.MergedInto)
+	}
+	for _, u := range []User{source, target} {
+		if u.isDeleted() {
+			return fmt.Errorf("user %s was deleted", u.Id)
+		}
+	}
+	return nil
+}
+
+func (uv *userValidator) ValidateQuery(query UserQuery) error {
+	if query.NameMatch != "" && query.NameMatch != NameMatchPrefix && query.NameMatch != NameMatchContains {
+		return fmt.Errorf("match must be %s or %s", NameMatchPrefix, NameMatchContains)
+	}
+	if query.Sort != "" && !slices.Contains(AllUserSorts, query.Sort) {
+		return fmt.Errorf("sort must be one of %v", AllUserSorts)
+	}
+	if query.TrackingStatus != "" && TrackingStatusFromValue(string(query.TrackingStatus)) != query.TrackingStatus {
+		return fmt.Errorf("unknown trackingStatus %s", query.TrackingStatus)
+	}
+	if query.AccountType != "" && AccountTypeFromValue(string(query.AccountType)) != query.AccountType {
+		return fmt.Errorf("unknown accountType %s", query.AccountType)
+	}
+	if query.Limit < 0 {
+		return errors.New("limit must not be negative")
+	}
 	return nil
 }
@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+)
+
+var ErrWebhookTargetForbidden = errors.New("webhook target is not a public address")
+
+// newWebhookClient returns the client webhooks are delivered with. Webhook urls come from API
+// callers, so unless private targets are allowed the client refuses to connect to loopback,
+// private, link-local and other non-public addresses. The check runs on the resolved address at
+// dial time, so a public name that resolves to a private address is refused too. Redirects are
+// not followed and proxies are not used, since either would connect somewhere other than the
+// address that was checked.
+func newWebhookClient(config WebhookConfig) *http.Client {
+	dialer := &net.Dialer{Timeout: config.Timeout}
+	if !config.AllowPrivateTargets {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			return checkWebhookAddress(address)
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   config.Timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// checkWebhookAddress rejects a resolved host:port that isn't a public unicast address.
+func checkWebhookAddress(address string) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return errors.Join(ErrWebhookTargetForbidden, err)
+	}
+	ip := addrPort.Addr().Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || sharedAddressSpace.Contains(ip) {
+		return fmt.Errorf("%w: %s", ErrWebhookTargetForbidden, ip)
+	}
+	return nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, which IsPrivate does not cover.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
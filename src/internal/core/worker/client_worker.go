@@ -0,0 +1,69 @@
+package worker
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/breaker"
+	"github.com/ctfloyd/hazelmere-worker/src/pkg/worker_client"
+)
+
+// WorkerClient is the part of hazelmere-worker the API depends on.
+type WorkerClient interface {
+	// GenerateSnapshotOnDemand snapshots a user and returns the id of the stored snapshot. It
+	// returns ErrHiscoreTimeout when the worker could not reach the Runescape hiscores in time.
+	GenerateSnapshotOnDemand(ctx context.Context, userId string) (string, error)
+}
+
+type hazelmereWorkerClient struct {
+	client *worker_client.HazelmereWorker
+}
+
+// NewWorkerClient adapts the hazelmere-worker client to WorkerClient.
+func NewWorkerClient(client *worker_client.HazelmereWorker) WorkerClient {
+	return &hazelmereWorkerClient{client: client}
+}
+
+func (c *hazelmereWorkerClient) GenerateSnapshotOnDemand(ctx context.Context, userId string) (string, error) {
+	response, err := c.client.Snapshot.GenerateSnapshotOnDemand(userId)
+	if errors.Is(err, worker_client.ErrRunescapeHiscoreTimeout) {
+		return "", ErrHiscoreTimeout
+	}
+	if err != nil {
+		return "", err
+	}
+	return response.SnapshotId, nil
+}
+
+type breakerWorkerClient struct {
+	client  WorkerClient
+	breaker *breaker.Breaker
+}
+
+// NewBreakerWorkerClient guards client with a circuit breaker, so calls fail fast with
+// ErrWorkerUnavailable while the worker is down instead of each waiting for its timeout.
+// Hiscore timeouts mean the worker answered, so they do not count as failures.
+func NewBreakerWorkerClient(client WorkerClient, b *breaker.Breaker) WorkerClient {
+	return &breakerWorkerClient{client: client, breaker: b}
+}
+
+func (c *breakerWorkerClient) GenerateSnapshotOnDemand(ctx context.Context, userId string) (string, error) {
+	var snapshotId string
+	var hiscoreErr error
+	err := c.breaker.Execute(func() error {
+		var err error
+		snapshotId, err = c.client.GenerateSnapshotOnDemand(ctx, userId)
+		if errors.Is(err, ErrHiscoreTimeout) {
+			hiscoreErr = err
+			return nil
+		}
+		return err
+	})
+	if errors.Is(err, breaker.ErrOpen) {
+		return "", ErrWorkerUnavailable
+	}
+	if err != nil {
+		return "", err
+	}
+	return snapshotId, hiscoreErr
+}
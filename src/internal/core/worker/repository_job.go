@@ -0,0 +1,139 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type JobRepository interface {
+	CreateJob(ctx context.Context, job JobData) (JobData, error)
+	GetJobById(ctx context.Context, id string) (JobData, error)
+	// ClaimNextJob marks the oldest queued job as running and returns it. Jobs left running
+	// since before staleBefore, by an instance that stopped mid-job, are claimed again.
+	// Returns database.ErrNotFound when there is nothing to claim.
+	ClaimNextJob(ctx context.Context, now time.Time, staleBefore time.Time) (JobData, error)
+	// UpdateJob replaces the job as long as it has not been claimed again since it was read,
+	// and returns database.ErrNotFound otherwise.
+	UpdateJob(ctx context.Context, job JobData) (JobData, error)
+	// GetJobsForUser returns the user's jobs, oldest first.
+	GetJobsForUser(ctx context.Context, userId string) ([]JobData, error)
+	DeleteJobsForUser(ctx context.Context, userId string) (int64, error)
+}
+
+type mongoJobRepository struct {
+	monitor    *monitor.Monitor
+	collection *mongo.Collection
+}
+
+func NewJobRepository(jobCollection *mongo.Collection, mon *monitor.Monitor) JobRepository {
+	return &mongoJobRepository{
+		collection: jobCollection,
+		monitor:    mon,
+	}
+}
+
+func (jr *mongoJobRepository) CreateJob(ctx context.Context, job JobData) (JobData, error) {
+	ctx, span := jr.monitor.StartSpan(ctx, "mongoJobRepository.CreateJob")
+	defer span.End()
+
+	_, err := jr.collection.InsertOne(ctx, job)
+	if err != nil {
+		return JobData{}, errors.Join(database.ErrGeneric, err)
+	}
+	return job, nil
+}
+
+func (jr *mongoJobRepository) GetJobById(ctx context.Context, id string) (JobData, error) {
+	ctx, span := jr.monitor.StartSpan(ctx, "mongoJobRepository.GetJobById")
+	defer span.End()
+
+	return decodeJob(jr.collection.FindOne(ctx, bson.M{"_id": id}))
+}
+
+func (jr *mongoJobRepository) ClaimNextJob(ctx context.Context, now time.Time, staleBefore time.Time) (JobData, error) {
+	ctx, span := jr.monitor.StartSpan(ctx, "mongoJobRepository.ClaimNextJob")
+	defer span.End()
+
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": string(api.WorkerJobStatusQueued)},
+		bson.M{"status": string(api.WorkerJobStatusRunning), "startedAt": bson.M{"$lt": staleBefore}},
+	}}
+	update := bson.M{
+		"$set": bson.M{"status": string(api.WorkerJobStatusRunning), "startedAt": now},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "createdAt", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	return decodeJob(jr.collection.FindOneAndUpdate(ctx, filter, update, opts))
+}
+
+func (jr *mongoJobRepository) UpdateJob(ctx context.Context, job JobData) (JobData, error) {
+	ctx, span := jr.monitor.StartSpan(ctx, "mongoJobRepository.UpdateJob")
+	defer span.End()
+
+	result, err := jr.collection.ReplaceOne(ctx, bson.M{"_id": job.Id, "attempts": job.Attempts}, job)
+	if err != nil {
+		return JobData{}, errors.Join(database.ErrGeneric, err)
+	}
+	if result.MatchedCount == 0 {
+		return JobData{}, database.ErrNotFound
+	}
+	return job, nil
+}
+
+func (jr *mongoJobRepository) GetJobsForUser(ctx context.Context, userId string) ([]JobData, error) {
+	ctx, span := jr.monitor.StartSpan(ctx, "mongoJobRepository.GetJobsForUser")
+	defer span.End()
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+	cursor, err := jr.collection.Find(ctx, bson.M{"userId": userId}, opts)
+	if err != nil {
+		return []JobData{}, errors.Join(database.ErrGeneric, err)
+	}
+
+	var results []JobData
+	if err = cursor.All(ctx, &results); err != nil {
+		return []JobData{}, errors.Join(database.ErrGeneric, err)
+	}
+
+	return results, nil
+}
+
+func (jr *mongoJobRepository) DeleteJobsForUser(ctx context.Context, userId string) (int64, error) {
+	ctx, span := jr.monitor.StartSpan(ctx, "mongoJobRepository.DeleteJobsForUser")
+	defer span.End()
+
+	result, err := jr.collection.DeleteMany(ctx, bson.M{"userId": userId})
+	if err != nil {
+		return 0, errors.Join(database.ErrGeneric, err)
+	}
+	return result.DeletedCount, nil
+}
+
+func decodeJob(result *mongo.SingleResult) (JobData, error) {
+	if result.Err() != nil {
+		if errors.Is(result.Err(), mongo.ErrNoDocuments) {
+			return JobData{}, database.ErrNotFound
+		}
+
+		return JobData{}, errors.Join(database.ErrGeneric, result.Err())
+	}
+
+	var job JobData
+	err := result.Decode(&job)
+	if err != nil {
+		return JobData{}, errors.Join(database.ErrGeneric, err)
+	}
+
+	return job, nil
+}
@@ -0,0 +1,126 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+)
+
+type memoryJobRepository struct {
+	monitor *monitor.Monitor
+	mu      sync.RWMutex
+	jobs    []JobData
+}
+
+func NewMemoryJobRepository(mon *monitor.Monitor) JobRepository {
+	return &memoryJobRepository{
+		monitor: mon,
+	}
+}
+
+func (jr *memoryJobRepository) CreateJob(ctx context.Context, job JobData) (JobData, error) {
+	ctx, span := jr.monitor.StartSpan(ctx, "memoryJobRepository.CreateJob")
+	defer span.End()
+
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+
+	for _, j := range jr.jobs {
+		if j.Id == job.Id {
+			return JobData{}, fmt.Errorf("%w: duplicate job id %s", database.ErrGeneric, job.Id)
+		}
+	}
+	jr.jobs = append(jr.jobs, job)
+	return job, nil
+}
+
+func (jr *memoryJobRepository) GetJobById(ctx context.Context, id string) (JobData, error) {
+	ctx, span := jr.monitor.StartSpan(ctx, "memoryJobRepository.GetJobById")
+	defer span.End()
+
+	jr.mu.RLock()
+	defer jr.mu.RUnlock()
+
+	for _, j := range jr.jobs {
+		if j.Id == id {
+			return j, nil
+		}
+	}
+	return JobData{}, database.ErrNotFound
+}
+
+// ClaimNextJob relies on jobs being appended in creation order.
+func (jr *memoryJobRepository) ClaimNextJob(ctx context.Context, now time.Time, staleBefore time.Time) (JobData, error) {
+	ctx, span := jr.monitor.StartSpan(ctx, "memoryJobRepository.ClaimNextJob")
+	defer span.End()
+
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+
+	for i, j := range jr.jobs {
+		queued := j.Status == string(api.WorkerJobStatusQueued)
+		stale := j.Status == string(api.WorkerJobStatusRunning) && j.StartedAt != nil && j.StartedAt.Before(staleBefore)
+		if !queued && !stale {
+			continue
+		}
+		j.Status = string(api.WorkerJobStatusRunning)
+		j.StartedAt = &now
+		j.Attempts++
+		jr.jobs[i] = j
+		return j, nil
+	}
+	return JobData{}, database.ErrNotFound
+}
+
+func (jr *memoryJobRepository) UpdateJob(ctx context.Context, job JobData) (JobData, error) {
+	ctx, span := jr.monitor.StartSpan(ctx, "memoryJobRepository.UpdateJob")
+	defer span.End()
+
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+
+	for i, j := range jr.jobs {
+		if j.Id == job.Id && j.Attempts == job.Attempts {
+			jr.jobs[i] = job
+			return job, nil
+		}
+	}
+	return JobData{}, database.ErrNotFound
+}
+
+// GetJobsForUser relies on jobs being appended in creation order.
+func (jr *memoryJobRepository) GetJobsForUser(ctx context.Context, userId string) ([]JobData, error) {
+	ctx, span := jr.monitor.StartSpan(ctx, "memoryJobRepository.GetJobsForUser")
+	defer span.End()
+
+	jr.mu.RLock()
+	defer jr.mu.RUnlock()
+
+	var results []JobData
+	for _, j := range jr.jobs {
+		if j.UserId == userId {
+			results = append(results, j)
+		}
+	}
+	return results, nil
+}
+
+func (jr *memoryJobRepository) DeleteJobsForUser(ctx context.Context, userId string) (int64, error) {
+	ctx, span := jr.monitor.StartSpan(ctx, "memoryJobRepository.DeleteJobsForUser")
+	defer span.End()
+
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+
+	before := len(jr.jobs)
+	jr.jobs = slices.DeleteFunc(jr.jobs, func(j JobData) bool {
+		return j.UserId == userId
+	})
+	return int64(before - len(jr.jobs)), nil
+}
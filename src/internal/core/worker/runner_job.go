@@ -0,0 +1,273 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/snapshot"
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+)
+
+const (
+	HeaderJobId            = "X-Hazelmere-Job-Id"
+	HeaderWebhookSignature = "X-Hazelmere-Signature"
+)
+
+type JobRunnerConfig struct {
+	Workers      int
+	PollInterval time.Duration
+	// StaleAfter is how long a job may stay running before it is assumed to be abandoned by an
+	// instance that stopped, and is claimed again.
+	StaleAfter time.Duration
+	// MaxAttempts bounds how many times an abandoned job is claimed before it fails.
+	MaxAttempts int
+	Webhook     WebhookConfig
+}
+
+type WebhookConfig struct {
+	Timeout   time.Duration
+	Retries   int
+	RetryWait time.Duration
+	// Secret signs webhook bodies with HMAC-SHA256 in the X-Hazelmere-Signature header, as
+	// "sha256=<hex>". Bodies are not signed when it is empty.
+	Secret string
+	// AllowPrivateTargets lets webhooks reach loopback and private addresses, for local
+	// development. Leave it off wherever untrusted callers can create jobs.
+	AllowPrivateTargets bool
+}
+
+var DefaultJobRunnerConfig = JobRunnerConfig{
+	Workers:      2,
+	PollInterval: 5 * time.Second,
+	StaleAfter:   5 * time.Minute,
+	MaxAttempts:  3,
+	Webhook: WebhookConfig{
+		Timeout:   5 * time.Second,
+		Retries:   3,
+		RetryWait: time.Second,
+	},
+}
+
+// JobRunner works through queued jobs. Several runners, in this or other instances, can share
+// a repository since claiming a job is atomic.
+type JobRunner struct {
+	monitor       *monitor.Monitor
+	repository    JobRepository
+	workerService WorkerService
+	config        JobRunnerConfig
+	client        *http.Client
+	wake          chan struct{}
+}
+
+func NewJobRunner(mon *monitor.Monitor, repository JobRepository, workerService WorkerService, config JobRunnerConfig) *JobRunner {
+	return &JobRunner{
+		monitor:       mon,
+		repository:    repository,
+		workerService: workerService,
+		config:        config,
+		client:        newWebhookClient(config.Webhook),
+		wake:          make(chan struct{}, 1),
+	}
+}
+
+// Wake makes an idle runner look for jobs now rather than at its next poll.
+func (jr *JobRunner) Wake() {
+	select {
+	case jr.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run works through jobs until ctx is done. Jobs that are already running are finished, and
+// their webhooks delivered, before it returns.
+func (jr *JobRunner) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range max(jr.config.Workers, 1) {
+		wg.Go(func() { jr.work(ctx) })
+	}
+	wg.Wait()
+}
+
+func (jr *JobRunner) work(ctx context.Context) {
+	ticker := time.NewTicker(jr.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil && jr.RunNext(ctx) {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-jr.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunNext claims and runs a single job. It reports whether there was a job to run.
+func (jr *JobRunner) RunNext(ctx context.Context) bool {
+	ctx, span := jr.monitor.StartSpan(ctx, "JobRunner.RunNext")
+	defer span.End()
+
+	now := time.Now()
+	data, err := jr.repository.ClaimNextJob(ctx, now, now.Add(-jr.config.StaleAfter))
+	if err != nil {
+		if !errors.Is(err, database.ErrNotFound) {
+			jr.monitor.Logger().ErrorArgs(ctx, "Failed to claim the next job: %v", err)
+		}
+		return false
+	}
+
+	// A claimed job is always finished, even if the runner is asked to stop meanwhile.
+	ctx = context.WithoutCancel(ctx)
+	job := Job{}.FromData(data)
+	if job.Attempts > jr.config.MaxAttempts {
+		jr.monitor.Logger().WarnArgs(ctx, "Job %s was abandoned %d times, failing it", job.Id, job.Attempts-1)
+		job.fail(api.ErrorCodeInternal, fmt.Sprintf("the job was abandoned %d times", job.Attempts-1))
+	} else {
+		jr.execute(ctx, &job)
+	}
+
+	if err := jr.save(ctx, &job); err != nil {
+		return true
+	}
+	if job.WebhookUrl != "" {
+		jr.notify(ctx, &job)
+	}
+	return true
+}
+
+func (jr *JobRunner) execute(ctx context.Context, job *Job) {
+	jr.monitor.Logger().InfoArgs(ctx, "Running job %s: %s for user %s", job.Id, job.Type, job.UserId)
+
+	result, err := jr.workerService.GenerateSnapshotOnDemand(ctx, job.UserId)
+	if err != nil {
+		if errors.Is(err, snapshot.ErrSnapshotValidation) {
+			jr.monitor.Logger().WarnArgs(ctx, "Cannot run job %s: %+v", job.Id, err)
+			job.fail(api.ErrorCodeInvalidUser, err.Error())
+			return
+		}
+		if errors.Is(err, ErrHiscoreTimeout) {
+			jr.monitor.Logger().WarnArgs(ctx, "Hiscore timeout while running job %s", job.Id)
+			job.fail(api.ErrorCodeHiscoreTimeout, "Osrs hiscores timed out.")
+			return
+		}
+		if errors.Is(err, ErrWorkerUnavailable) {
+			jr.monitor.Logger().WarnArgs(ctx, "Worker unavailable while running job %s", job.Id)
+			job.fail(api.ErrorCodeWorkerUnavailable, "The worker is unavailable, try again later.")
+			return
+		}
+		jr.monitor.Logger().ErrorArgs(ctx, "An unexpected error occurred while running job %s: %+v", job.Id, err)
+		job.fail(api.ErrorCodeInternal, "An unexpected error occurred while performing the worker operation.")
+		return
+	}
+
+	now := time.Now()
+	job.Status = api.WorkerJobStatusSucceeded
+	job.SnapshotId = result.Snapshot.Id
+	job.SnapshotReused = result.Reused
+	job.FinishedAt = &now
+}
+
+func (j *Job) fail(code string, message string) {
+	now := time.Now()
+	j.Status = api.WorkerJobStatusFailed
+	j.ErrorCode = code
+	j.ErrorMessage = message
+	j.FinishedAt = &now
+}
+
+// save persists the job unless another runner has claimed it since, in which case that runner
+// owns the outcome and the webhook.
+func (jr *JobRunner) save(ctx context.Context, job *Job) error {
+	_, err := jr.repository.UpdateJob(ctx, job.ToData())
+	if errors.Is(err, database.ErrNotFound) {
+		jr.monitor.Logger().WarnArgs(ctx, "Job %s was claimed again before it finished; discarding this run", job.Id)
+	} else if err != nil {
+		jr.monitor.Logger().ErrorArgs(ctx, "Failed to save job %s: %v", job.Id, err)
+	}
+	return err
+}
+
+// notify delivers the finished job to its webhook and records the outcome on the job.
+func (jr *JobRunner) notify(ctx context.Context, job *Job) {
+	ctx, span := jr.monitor.StartSpan(ctx, "JobRunner.notify")
+	defer span.End()
+
+	body, err := json.Marshal(api.GetWorkerJobResponse{Job: job.ToAPI()})
+	if err != nil {
+		jr.monitor.Logger().ErrorArgs(ctx, "Failed to encode webhook for job %s: %v", job.Id, err)
+		return
+	}
+
+	wait := jr.config.Webhook.RetryWait
+	for attempt := 0; ; attempt++ {
+		err = jr.deliver(ctx, job, body)
+		if err == nil || attempt >= jr.config.Webhook.Retries || errors.Is(err, ErrWebhookTargetForbidden) {
+			break
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			err = errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wait *= 2
+	}
+
+	if err != nil {
+		jr.monitor.Logger().WarnArgs(ctx, "Failed to deliver webhook for job %s: %v", job.Id, err)
+		job.WebhookError = err.Error()
+	} else {
+		now := time.Now()
+		job.WebhookDeliveredAt = &now
+	}
+	_ = jr.save(ctx, job)
+}
+
+func (jr *JobRunner) deliver(ctx context.Context, job *Job, body []byte) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, job.WebhookUrl, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(HeaderJobId, job.Id)
+	if jr.config.Webhook.Secret != "" {
+		request.Header.Set(HeaderWebhookSignature, SignWebhook(jr.config.Webhook.Secret, body))
+	}
+
+	response, err := jr.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", response.StatusCode)
+	}
+	return nil
+}
+
+// SignWebhook returns the X-Hazelmere-Signature value of a webhook body, so receivers can
+// verify it with hmac.Equal.
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,208 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/snapshot"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_logger"
+	"github.com/google/uuid"
+)
+
+type fakeWorkerService struct{}
+
+func (f *fakeWorkerService) GenerateSnapshotOnDemand(ctx context.Context, userId string) (OnDemandResult, error) {
+	return OnDemandResult{Snapshot: snapshot.HiscoreSnapshot{Id: "snapshot-" + userId, UserId: userId}}, nil
+}
+
+func newTestRunner(t *testing.T, workerService WorkerService) (JobService, JobRepository, *JobRunner) {
+	t.Helper()
+	mon := monitor.New(hz_logger.NewZeroLogAdapater(hz_logger.LogLevelError))
+	repository := NewMemoryJobRepository(mon)
+	config := DefaultJobRunnerConfig
+	config.Webhook.RetryWait = time.Millisecond
+	config.Webhook.Secret = "webhook-secret"
+	// Test webhooks listen on loopback.
+	config.Webhook.AllowPrivateTargets = true
+	runner := NewJobRunner(mon, repository, workerService, config)
+	return NewJobService(mon, repository, NewJobValidator(), runner), repository, runner
+}
+
+func TestJobRunnerDeliversSignedWebhook(t *testing.T) {
+	ctx := context.Background()
+
+	type delivery struct {
+		header http.Header
+		body   []byte
+	}
+	deliveries := make(chan delivery, 4)
+	failures := 1
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		deliveries <- delivery{header: r.Header, body: body}
+	}))
+	defer webhook.Close()
+
+	service, _, runner := newTestRunner(t, &fakeWorkerService{})
+	job, err := service.CreateSnapshotJob(ctx, Job{UserId: uuid.New().String(), WebhookUrl: webhook.URL})
+	if err != nil {
+		t.Fatalf("CreateSnapshotJob: %v", err)
+	}
+	if !runner.RunNext(ctx) {
+		t.Fatal("RunNext found no job to run")
+	}
+
+	d := <-deliveries
+	if got, want := d.header.Get(HeaderWebhookSignature), SignWebhook("webhook-secret", d.body); got != want {
+		t.Errorf("signature is %q, want %q", got, want)
+	}
+	var payload api.GetWorkerJobResponse
+	if err := json.Unmarshal(d.body, &payload); err != nil {
+		t.Fatalf("decoding webhook: %v", err)
+	}
+	if payload.Job.Id != job.Id || payload.Job.Status != api.WorkerJobStatusSucceeded {
+		t.Errorf("webhook carried job %s in %s, want %s in %s", payload.Job.Id, payload.Job.Status, job.Id, api.WorkerJobStatusSucceeded)
+	}
+
+	stored, err := service.GetJobById(ctx, job.Id)
+	if err != nil {
+		t.Fatalf("GetJobById: %v", err)
+	}
+	if stored.WebhookDeliveredAt == nil || stored.WebhookError != "" {
+		t.Errorf("webhook delivery recorded as %v with error %q, want delivered", stored.WebhookDeliveredAt, stored.WebhookError)
+	}
+	if stored.SnapshotId != "snapshot-"+job.UserId {
+		t.Errorf("job snapshot is %q, want the generated snapshot", stored.SnapshotId)
+	}
+}
+
+func TestJobRunnerRefusesPrivateWebhookTargets(t *testing.T) {
+	ctx := context.Background()
+
+	var calls atomic.Int32
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer webhook.Close()
+
+	service, repository, _ := newTestRunner(t, &fakeWorkerService{})
+	config := DefaultJobRunnerConfig
+	config.Webhook.RetryWait = time.Millisecond
+	runner := NewJobRunner(monitor.New(hz_logger.NewZeroLogAdapater(hz_logger.LogLevelError)), repository, &fakeWorkerService{}, config)
+
+	job, err := service.CreateSnapshotJob(ctx, Job{UserId: uuid.New().String(), WebhookUrl: webhook.URL})
+	if err != nil {
+		t.Fatalf("CreateSnapshotJob: %v", err)
+	}
+	if !runner.RunNext(ctx) {
+		t.Fatal("RunNext found no job to run")
+	}
+
+	stored, err := service.GetJobById(ctx, job.Id)
+	if err != nil {
+		t.Fatalf("GetJobById: %v", err)
+	}
+	if calls.Load() != 0 || stored.WebhookDeliveredAt != nil || !strings.Contains(stored.WebhookError, ErrWebhookTargetForbidden.Error()) {
+		t.Errorf("webhook to loopback made %d calls and recorded error %q, want it refused without a call", calls.Load(), stored.WebhookError)
+	}
+}
+
+func TestJobRunnerDoesNotFollowWebhookRedirects(t *testing.T) {
+	ctx := context.Background()
+
+	var redirected atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected.Add(1)
+	}))
+	defer target.Close()
+	webhook := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer webhook.Close()
+
+	service, _, runner := newTestRunner(t, &fakeWorkerService{})
+	job, err := service.CreateSnapshotJob(ctx, Job{UserId: uuid.New().String(), WebhookUrl: webhook.URL})
+	if err != nil {
+		t.Fatalf("CreateSnapshotJob: %v", err)
+	}
+	if !runner.RunNext(ctx) {
+		t.Fatal("RunNext found no job to run")
+	}
+
+	stored, err := service.GetJobById(ctx, job.Id)
+	if err != nil {
+		t.Fatalf("GetJobById: %v", err)
+	}
+	if redirected.Load() != 0 || stored.WebhookDeliveredAt != nil {
+		t.Errorf("redirect was followed %d times and delivery recorded as %v, want the redirect treated as a failure", redirected.Load(), stored.WebhookDeliveredAt)
+	}
+}
+
+func TestCheckWebhookAddress(t *testing.T) {
+	for address, allowed := range map[string]bool{
+		"93.184.215.14:443":         true,
+		"[2606:4700::1111]:443":     true,
+		"127.0.0.1:80":              false,
+		"10.1.2.3:80":               false,
+		"172.16.0.1:80":             false,
+		"192.168.1.1:80":            false,
+		"169.254.169.254:80":        false,
+		"100.64.0.1:80":             false,
+		"0.0.0.0:80":                false,
+		"[::1]:80":                  false,
+		"[fe80::1]:80":              false,
+		"[fd00::1]:80":              false,
+		"[::ffff:127.0.0.1]:80":     false,
+		"[::ffff:93.184.215.14]:80": true,
+	} {
+		if err := checkWebhookAddress(address); (err == nil) != allowed {
+			t.Errorf("checkWebhookAddress(%s) = %v, want allowed %v", address, err, allowed)
+		}
+	}
+}
+
+func TestJobRunnerReclaimsAbandonedJobs(t *testing.T) {
+	ctx := context.Background()
+	service, repository, runner := newTestRunner(t, &fakeWorkerService{})
+
+	job, err := service.CreateSnapshotJob(ctx, Job{UserId: uuid.New().String()})
+	if err != nil {
+		t.Fatalf("CreateSnapshotJob: %v", err)
+	}
+
+	// Claim the job as runs that stopped midway, until it has used up its attempts.
+	longAgo := time.Now().Add(-time.Hour)
+	for range DefaultJobRunnerConfig.MaxAttempts {
+		if _, err := repository.ClaimNextJob(ctx, longAgo, time.Now()); err != nil {
+			t.Fatalf("ClaimNextJob: %v", err)
+		}
+	}
+
+	// A run that is still going is not reclaimed.
+	if _, err := repository.ClaimNextJob(ctx, time.Now(), longAgo.Add(-time.Minute)); err == nil {
+		t.Fatal("ClaimNextJob reclaimed a job that is not stale")
+	}
+
+	if !runner.RunNext(ctx) {
+		t.Fatal("RunNext did not reclaim the abandoned job")
+	}
+	stored, err := service.GetJobById(ctx, job.Id)
+	if err != nil {
+		t.Fatalf("GetJobById: %v", err)
+	}
+	if stored.Status != api.WorkerJobStatusFailed || stored.ErrorCode != api.ErrorCodeInternal {
+		t.Errorf("abandoned job is %s with code %q, want %s with %s", stored.Status, stored.ErrorCode, api.WorkerJobStatusFailed, api.ErrorCodeInternal)
+	}
+}
@@ -0,0 +1,109 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+	"github.com/google/uuid"
+)
+
+var ErrJobGeneric = errors.New("an error occurred while performing the job operation")
+var ErrJobNotFound = errors.New("job not found")
+var ErrJobValidation = errors.New("job is invalid")
+
+type JobService interface {
+	CreateSnapshotJob(ctx context.Context, job Job) (Job, error)
+	GetJobById(ctx context.Context, id string) (Job, error)
+	GetJobsForUser(ctx context.Context, userId string) ([]Job, error)
+	DeleteJobsForUser(ctx context.Context, userId string) (int64, error)
+}
+
+type jobService struct {
+	monitor    *monitor.Monitor
+	validator  JobValidator
+	repository JobRepository
+	runner     *JobRunner
+}
+
+// NewJobService creates the job service. Created jobs wake runner, if one runs in this process,
+// instead of waiting for its next poll.
+func NewJobService(mon *monitor.Monitor, repository JobRepository, validator JobValidator, runner *JobRunner) JobService {
+	return &jobService{
+		monitor:    mon,
+		validator:  validator,
+		repository: repository,
+		runner:     runner,
+	}
+}
+
+// CreateSnapshotJob queues an on-demand snapshot of job.UserId.
+func (js *jobService) CreateSnapshotJob(ctx context.Context, job Job) (Job, error) {
+	ctx, span := js.monitor.StartSpan(ctx, "jobService.CreateSnapshotJob")
+	defer span.End()
+
+	err := js.validator.ValidateJob(job)
+	if err != nil {
+		return Job{}, errors.Join(ErrJobValidation, err)
+	}
+
+	job.Id = uuid.New().String()
+	job.Type = api.WorkerJobTypeSnapshotOnDemand
+	job.Status = api.WorkerJobStatusQueued
+	job.CreatedAt = time.Now()
+
+	data, err := js.repository.CreateJob(ctx, job.ToData())
+	if err != nil {
+		return Job{}, errors.Join(ErrJobGeneric, err)
+	}
+
+	if js.runner != nil {
+		js.runner.Wake()
+	}
+
+	return Job{}.FromData(data), nil
+}
+
+func (js *jobService) GetJobById(ctx context.Context, id string) (Job, error) {
+	ctx, span := js.monitor.StartSpan(ctx, "jobService.GetJobById")
+	defer span.End()
+
+	data, err := js.repository.GetJobById(ctx, id)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return Job{}, ErrJobNotFound
+		}
+		return Job{}, errors.Join(ErrJobGeneric, err)
+	}
+	return Job{}.FromData(data), nil
+}
+
+func (js *jobService) GetJobsForUser(ctx context.Context, userId string) ([]Job, error) {
+	ctx, span := js.monitor.StartSpan(ctx, "jobService.GetJobsForUser")
+	defer span.End()
+
+	data, err := js.repository.GetJobsForUser(ctx, userId)
+	if err != nil {
+		return nil, errors.Join(ErrJobGeneric, err)
+	}
+
+	jobs := make([]Job, len(data))
+	for i, d := range data {
+		jobs[i] = Job{}.FromData(d)
+	}
+	return jobs, nil
+}
+
+func (js *jobService) DeleteJobsForUser(ctx context.Context, userId string) (int64, error) {
+	ctx, span := js.monitor.StartSpan(ctx, "jobService.DeleteJobsForUser")
+	defer span.End()
+
+	deleted, err := js.repository.DeleteJobsForUser(ctx, userId)
+	if err != nil {
+		return 0, errors.Join(ErrJobGeneric, err)
+	}
+	return deleted, nil
+}
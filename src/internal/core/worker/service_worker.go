@@ -3,46 +3,113 @@ package worker
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/ctfloyd/hazelmere-api/src/internal/core/snapshot"
 	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
-	"github.com/ctfloyd/hazelmere-worker/src/pkg/worker_client"
+	"golang.org/x/sync/singleflight"
 )
 
 var ErrWorkerGeneric = errors.New("an error occurred while performing the worker operation")
 var ErrHiscoreTimeout = errors.New("hiscore timeout")
+var ErrWorkerUnavailable = errors.New("worker is unavailable")
 
 type WorkerService interface {
-	GenerateSnapshotOnDemand(ctx context.Context, userId string) (snapshot.HiscoreSnapshot, error)
+	GenerateSnapshotOnDemand(ctx context.Context, userId string) (OnDemandResult, error)
+}
+
+// OnDemandResult is the snapshot returned for an on-demand request. Reused is set when the
+// snapshot was taken within the cooldown and returned instead of fetching the hiscores again,
+// and Shared when the request joined a fetch already in flight for the same user.
+type OnDemandResult struct {
+	Snapshot      snapshot.HiscoreSnapshot
+	Reused        bool
+	Shared        bool
+	NextRefreshAt time.Time
 }
 
 type workerService struct {
 	monitor         *monitor.Monitor
-	workerClient    *worker_client.HazelmereWorker
+	workerClient    WorkerClient
 	snapshotService snapshot.SnapshotService
+	cooldown        time.Duration
+	inFlight        singleflight.Group
 }
 
-func NewWorkerService(mon *monitor.Monitor, workerClient *worker_client.HazelmereWorker, snapshotService snapshot.SnapshotService) WorkerService {
+// NewWorkerService creates the worker service. A snapshot taken less than cooldown ago is
+// returned instead of fetching a new one; a zero cooldown always fetches.
+func NewWorkerService(mon *monitor.Monitor, workerClient WorkerClient, snapshotService snapshot.SnapshotService, cooldown time.Duration) WorkerService {
 	return &workerService{
 		monitor:         mon,
 		workerClient:    workerClient,
 		snapshotService: snapshotService,
+		cooldown:        cooldown,
 	}
 }
 
-func (ws *workerService) GenerateSnapshotOnDemand(ctx context.Context, userId string) (snapshot.HiscoreSnapshot, error) {
+// GenerateSnapshotOnDemand fetches the hiscores of a user through the worker and returns the
+// stored snapshot. Concurrent requests for the same user share a single fetch. Users that cannot
+// take snapshots, such as deleted ones, are refused before the worker is called.
+func (ws *workerService) GenerateSnapshotOnDemand(ctx context.Context, userId string) (OnDemandResult, error) {
 	ctx, span := ws.monitor.StartSpan(ctx, "workerService.GenerateSnapshotOnDemand")
 	defer span.End()
 
-	response, err := ws.workerClient.Snapshot.GenerateSnapshotOnDemand(userId)
+	if err := ws.snapshotService.ValidateUser(ctx, userId); err != nil {
+		return OnDemandResult{}, err
+	}
+
+	if ws.cooldown > 0 {
+		latest, err := ws.snapshotService.GetLatestSnapshotForUser(ctx, userId)
+		if err != nil && !errors.Is(err, snapshot.ErrSnapshotNotFound) {
+			return OnDemandResult{}, errors.Join(ErrWorkerGeneric, err)
+		}
+		if err == nil && time.Since(latest.Timestamp) < ws.cooldown {
+			ws.monitor.Metrics().RecordWorkerOnDemandRequest(ctx, monitor.OnDemandResultReused)
+			return OnDemandResult{Snapshot: latest, Reused: true, NextRefreshAt: latest.Timestamp.Add(ws.cooldown)}, nil
+		}
+	}
+
+	// The fetch outlives any single caller, so it must not be cancelled with the first one.
+	// singleflight reports every caller of a shared fetch as shared, including the one that ran
+	// it, so the caller that ran it is tracked separately.
+	flightCtx := context.WithoutCancel(ctx)
+	ran := false
+	value, err, _ := ws.inFlight.Do(userId, func() (any, error) {
+		ran = true
+		return ws.generateSnapshot(flightCtx, userId)
+	})
 	if err != nil {
-		if errors.Is(err, worker_client.ErrRunescapeHiscoreTimeout) {
+		return OnDemandResult{}, err
+	}
+
+	result := monitor.OnDemandResultFresh
+	if !ran {
+		result = monitor.OnDemandResultShared
+	}
+	ws.monitor.Metrics().RecordWorkerOnDemandRequest(ctx, result)
+
+	ss := value.(snapshot.HiscoreSnapshot)
+	return OnDemandResult{Snapshot: ss, Shared: !ran, NextRefreshAt: ss.Timestamp.Add(ws.cooldown)}, nil
+}
+
+func (ws *workerService) generateSnapshot(ctx context.Context, userId string) (snapshot.HiscoreSnapshot, error) {
+	start := time.Now()
+	snapshotId, err := ws.workerClient.GenerateSnapshotOnDemand(ctx, userId)
+	if err != nil {
+		if errors.Is(err, ErrHiscoreTimeout) {
+			ws.monitor.Metrics().RecordWorkerOnDemand(ctx, time.Since(start), monitor.WorkerOutcomeTimeout)
 			return snapshot.HiscoreSnapshot{}, ErrHiscoreTimeout
 		}
+		if errors.Is(err, ErrWorkerUnavailable) {
+			ws.monitor.Metrics().RecordWorkerOnDemand(ctx, time.Since(start), monitor.WorkerOutcomeUnavailable)
+			return snapshot.HiscoreSnapshot{}, ErrWorkerUnavailable
+		}
+		ws.monitor.Metrics().RecordWorkerOnDemand(ctx, time.Since(start), monitor.WorkerOutcomeError)
 		return snapshot.HiscoreSnapshot{}, errors.Join(ErrWorkerGeneric, err)
 	}
+	ws.monitor.Metrics().RecordWorkerOnDemand(ctx, time.Since(start), monitor.WorkerOutcomeSuccess)
 
-	ss, err := ws.snapshotService.GetSnapshotById(ctx, response.SnapshotId)
+	ss, err := ws.snapshotService.GetSnapshotById(ctx, snapshotId)
 	if err != nil {
 		return snapshot.HiscoreSnapshot{}, errors.Join(ErrWorkerGeneric, err)
 	}
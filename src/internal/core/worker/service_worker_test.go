@@ -0,0 +1,192 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/snapshot"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/user"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/breaker"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_logger"
+	"github.com/google/uuid"
+)
+
+// fakeWorker stands in for hazelmere-worker by storing a snapshot taken now, or failing with err
+// when set. Calls block until release is closed.
+type fakeWorker struct {
+	repository snapshot.SnapshotRepository
+	calls      atomic.Int32
+	release    chan struct{}
+	err        error
+}
+
+func (f *fakeWorker) GenerateSnapshotOnDemand(ctx context.Context, userId string) (string, error) {
+	f.calls.Add(1)
+	<-f.release
+	if f.err != nil {
+		return "", f.err
+	}
+	data, err := f.repository.InsertSnapshot(context.Background(), snapshot.HiscoreSnapshotData{
+		Id:        uuid.New().String(),
+		UserId:    userId,
+		Timestamp: time.Now(),
+	})
+	return data.Id, err
+}
+
+func newTestWorkerService(cooldown time.Duration) (*workerService, *fakeWorker, user.UserRepository) {
+	mon := monitor.New(hz_logger.NewZeroLogAdapater(hz_logger.LogLevelError))
+	repository := snapshot.NewMemorySnapshotRepository(mon)
+	userRepository := user.NewMemoryUserRepository(mon)
+	fake := &fakeWorker{repository: repository, release: make(chan struct{})}
+	service := NewWorkerService(mon, fake, snapshot.NewSnapshotService(mon, repository, snapshot.NewSnapshotValidator(), userRepository), cooldown)
+	return service.(*workerService), fake, userRepository
+}
+
+// newTestUser stores a tracked user and returns their id.
+func newTestUser(t *testing.T, repository user.UserRepository) string {
+	t.Helper()
+	id := uuid.New().String()
+	data := user.User{Id: id, RunescapeName: id[:12], TrackingStatus: user.TrackingStatusEnabled}.ToData()
+	if _, err := repository.CreateUser(context.Background(), data); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestGenerateSnapshotOnDemandCoalescesConcurrentRequests(t *testing.T) {
+	ctx := context.Background()
+	service, fake, users := newTestWorkerService(time.Minute)
+	userId := newTestUser(t, users)
+
+	const callers = 5
+	results := make([]OnDemandResult, callers)
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Go(func() {
+			result, err := service.GenerateSnapshotOnDemand(ctx, userId)
+			if err != nil {
+				t.Errorf("GenerateSnapshotOnDemand: %v", err)
+			}
+			results[i] = result
+		})
+	}
+	// Give every caller time to join the fetch; a late caller is served by the cooldown instead.
+	time.Sleep(50 * time.Millisecond)
+	close(fake.release)
+	wg.Wait()
+
+	if calls := fake.calls.Load(); calls != 1 {
+		t.Fatalf("worker was called %d times, want 1", calls)
+	}
+	fresh := 0
+	for _, result := range results {
+		if result.Snapshot.Id != results[0].Snapshot.Id {
+			t.Errorf("callers got snapshots %s and %s, want the same one", result.Snapshot.Id, results[0].Snapshot.Id)
+		}
+		if !result.Reused && !result.Shared {
+			fresh++
+		}
+	}
+	if fresh != 1 {
+		t.Errorf("%d callers got a fresh snapshot, want exactly 1", fresh)
+	}
+}
+
+func TestGenerateSnapshotOnDemandReusesSnapshotsWithinCooldown(t *testing.T) {
+	ctx := context.Background()
+	service, fake, users := newTestWorkerService(time.Hour)
+	close(fake.release)
+	userId := newTestUser(t, users)
+
+	first, err := service.GenerateSnapshotOnDemand(ctx, userId)
+	if err != nil {
+		t.Fatalf("GenerateSnapshotOnDemand: %v", err)
+	}
+	if first.Reused {
+		t.Error("first snapshot of a user was reused")
+	}
+
+	second, err := service.GenerateSnapshotOnDemand(ctx, userId)
+	if err != nil {
+		t.Fatalf("GenerateSnapshotOnDemand: %v", err)
+	}
+	if !second.Reused || second.Snapshot.Id != first.Snapshot.Id {
+		t.Errorf("second request got snapshot %s reused %t, want %s reused", second.Snapshot.Id, second.Reused, first.Snapshot.Id)
+	}
+	if want := first.Snapshot.Timestamp.Add(time.Hour); !second.NextRefreshAt.Equal(want) {
+		t.Errorf("next refresh at %v, want %v", second.NextRefreshAt, want)
+	}
+
+	// Once the cooldown has passed, the hiscores are fetched again.
+	service.cooldown = time.Nanosecond
+	third, err := service.GenerateSnapshotOnDemand(ctx, userId)
+	if err != nil {
+		t.Fatalf("GenerateSnapshotOnDemand: %v", err)
+	}
+	if third.Reused || fake.calls.Load() != 2 {
+		t.Errorf("request after the cooldown was reused %t with %d worker calls, want a new fetch", third.Reused, fake.calls.Load())
+	}
+}
+
+func TestGenerateSnapshotOnDemandFailsFastWhileWorkerIsDown(t *testing.T) {
+	ctx := context.Background()
+	service, fake, users := newTestWorkerService(0)
+	close(fake.release)
+	service.workerClient = NewBreakerWorkerClient(fake, breaker.New(breaker.Config{FailureThreshold: 2, OpenFor: time.Hour}))
+
+	// Hiscore timeouts mean the worker answered, so they do not open the breaker.
+	fake.err = ErrHiscoreTimeout
+	for range 3 {
+		if _, err := service.GenerateSnapshotOnDemand(ctx, newTestUser(t, users)); !errors.Is(err, ErrHiscoreTimeout) {
+			t.Fatalf("GenerateSnapshotOnDemand: got %v, want ErrHiscoreTimeout", err)
+		}
+	}
+
+	fake.err = errors.New("connection refused")
+	for range 2 {
+		if _, err := service.GenerateSnapshotOnDemand(ctx, newTestUser(t, users)); !errors.Is(err, ErrWorkerGeneric) {
+			t.Fatalf("GenerateSnapshotOnDemand: got %v, want ErrWorkerGeneric", err)
+		}
+	}
+
+	calls := fake.calls.Load()
+	if _, err := service.GenerateSnapshotOnDemand(ctx, newTestUser(t, users)); !errors.Is(err, ErrWorkerUnavailable) {
+		t.Fatalf("GenerateSnapshotOnDemand with the breaker open: got %v, want ErrWorkerUnavailable", err)
+	}
+	if fake.calls.Load() != calls {
+		t.Error("worker was called with the breaker open")
+	}
+}
+
+func TestGenerateSnapshotOnDemandRefusesUsersThatCannotTakeSnapshots(t *testing.T) {
+	ctx := context.Background()
+	service, fake, users := newTestWorkerService(0)
+	close(fake.release)
+
+	deleted := newTestUser(t, users)
+	data, err := users.GetUserById(ctx, deleted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	data.DeletedAt = &now
+	if _, err := users.UpdateUser(ctx, data); err != nil {
+		t.Fatal(err)
+	}
+
+	// A user deleted for good is missing altogether.
+	for _, userId := range []string{deleted, uuid.New().String()} {
+		if _, err := service.GenerateSnapshotOnDemand(ctx, userId); !errors.Is(err, snapshot.ErrSnapshotValidation) {
+			t.Errorf("GenerateSnapshotOnDemand for %s: got %v, want ErrSnapshotValidation", userId, err)
+		}
+	}
+	if calls := fake.calls.Load(); calls != 0 {
+		t.Errorf("worker was called %d times, want none", calls)
+	}
+}
@@ -0,0 +1,22 @@
+package worker
+
+import "time"
+
+type JobData struct {
+	Id                 string     `bson:"_id"`
+	Type               string     `bson:"type"`
+	UserId             string     `bson:"userId"`
+	Status             string     `bson:"status"`
+	SnapshotId         string     `bson:"snapshotId,omitempty"`
+	SnapshotReused     bool       `bson:"snapshotReused,omitempty"`
+	ErrorCode          string     `bson:"errorCode,omitempty"`
+	ErrorMessage       string     `bson:"errorMessage,omitempty"`
+	WebhookUrl         string     `bson:"webhookUrl,omitempty"`
+	RequestedBy        string     `bson:"requestedBy,omitempty"`
+	Attempts           int        `bson:"attempts"`
+	CreatedAt          time.Time  `bson:"createdAt"`
+	StartedAt          *time.Time `bson:"startedAt,omitempty"`
+	FinishedAt         *time.Time `bson:"finishedAt,omitempty"`
+	WebhookDeliveredAt *time.Time `bson:"webhookDeliveredAt,omitempty"`
+	WebhookError       string     `bson:"webhookError,omitempty"`
+}
@@ -0,0 +1,102 @@
+package worker
+
+import (
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+)
+
+type Job struct {
+	Id                 string
+	Type               api.WorkerJobType
+	UserId             string
+	Status             api.WorkerJobStatus
+	SnapshotId         string
+	SnapshotReused     bool
+	ErrorCode          string
+	ErrorMessage       string
+	WebhookUrl         string
+	RequestedBy        string
+	Attempts           int
+	CreatedAt          time.Time
+	StartedAt          *time.Time
+	FinishedAt         *time.Time
+	WebhookDeliveredAt *time.Time
+	WebhookError       string
+}
+
+// ToAPI converts the domain Job to an API WorkerJob. RequestedBy and webhook delivery errors
+// are kept internal.
+func (j Job) ToAPI() api.WorkerJob {
+	job := api.WorkerJob{
+		Id:                 j.Id,
+		Type:               j.Type,
+		UserId:             j.UserId,
+		Status:             j.Status,
+		SnapshotId:         j.SnapshotId,
+		SnapshotReused:     j.SnapshotReused,
+		WebhookUrl:         j.WebhookUrl,
+		Attempts:           j.Attempts,
+		CreatedAt:          j.CreatedAt,
+		StartedAt:          j.StartedAt,
+		FinishedAt:         j.FinishedAt,
+		WebhookDeliveredAt: j.WebhookDeliveredAt,
+	}
+	if j.ErrorCode != "" {
+		job.Error = &api.WorkerJobError{Code: j.ErrorCode, Message: j.ErrorMessage}
+	}
+	return job
+}
+
+// ManyToAPI converts a slice of domain Jobs to API WorkerJobs (call as Job{}.ManyToAPI(...))
+func (Job) ManyToAPI(jobs []Job) []api.WorkerJob {
+	apiJobs := make([]api.WorkerJob, len(jobs))
+	for i := range jobs {
+		apiJobs[i] = jobs[i].ToAPI()
+	}
+	return apiJobs
+}
+
+// ToData converts the domain Job to a data layer JobData
+func (j Job) ToData() JobData {
+	return JobData{
+		Id:                 j.Id,
+		Type:               string(j.Type),
+		UserId:             j.UserId,
+		Status:             string(j.Status),
+		SnapshotId:         j.SnapshotId,
+		SnapshotReused:     j.SnapshotReused,
+		ErrorCode:          j.ErrorCode,
+		ErrorMessage:       j.ErrorMessage,
+		WebhookUrl:         j.WebhookUrl,
+		RequestedBy:        j.RequestedBy,
+		Attempts:           j.Attempts,
+		CreatedAt:          j.CreatedAt,
+		StartedAt:          j.StartedAt,
+		FinishedAt:         j.FinishedAt,
+		WebhookDeliveredAt: j.WebhookDeliveredAt,
+		WebhookError:       j.WebhookError,
+	}
+}
+
+// FromData creates a domain Job from data layer JobData (call as Job{}.FromData(...))
+func (Job) FromData(data JobData) Job {
+	return Job{
+		Id:                 data.Id,
+		Type:               api.WorkerJobType(data.Type),
+		UserId:             data.UserId,
+		Status:             api.WorkerJobStatus(data.Status),
+		SnapshotId:         data.SnapshotId,
+		SnapshotReused:     data.SnapshotReused,
+		ErrorCode:          data.ErrorCode,
+		ErrorMessage:       data.ErrorMessage,
+		WebhookUrl:         data.WebhookUrl,
+		RequestedBy:        data.RequestedBy,
+		Attempts:           data.Attempts,
+		CreatedAt:          data.CreatedAt,
+		StartedAt:          data.StartedAt,
+		FinishedAt:         data.FinishedAt,
+		WebhookDeliveredAt: data.WebhookDeliveredAt,
+		WebhookError:       data.WebhookError,
+	}
+}
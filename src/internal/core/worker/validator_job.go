@@ -0,0 +1,34 @@
+package worker
+
+import (
+	"errors"
+	"net/url"
+
+	"github.com/google/uuid"
+)
+
+type JobValidator interface {
+	ValidateJob(job Job) error
+}
+
+type jobValidator struct {
+}
+
+func NewJobValidator() JobValidator {
+	return &jobValidator{}
+}
+
+func (jv *jobValidator) ValidateJob(job Job) error {
+	if _, err := uuid.Parse(job.UserId); err != nil {
+		return errors.New("userId must be a uuid")
+	}
+
+	if job.WebhookUrl != "" {
+		u, err := url.Parse(job.WebhookUrl)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("webhookUrl must be an absolute http or https url")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+// Package databasetest provides throwaway Mongo databases for repository conformance tests.
+package databasetest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// MongoURIEnv names the environment variable holding the connection string of a Mongo instance
+// the tests may create and drop databases on. Mongo tests are skipped when it is unset.
+const MongoURIEnv = "HAZELMERE_TEST_MONGO_URI"
+
+// Database returns a fresh database that is dropped when the test ends.
+func Database(t testing.TB) *mongo.Database {
+	t.Helper()
+
+	uri := os.Getenv(MongoURIEnv)
+	if uri == "" {
+		t.Skipf("%s is not set", MongoURIEnv)
+	}
+
+	client, err := mongo.Connect(options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connecting to mongo: %v", err)
+	}
+
+	database := client.Database(fmt.Sprintf("hazelmere_test_%s", uuid.New().String()[:8]))
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := database.Drop(ctx); err != nil {
+			t.Errorf("dropping test database: %v", err)
+		}
+		_ = client.Disconnect(ctx)
+	})
+
+	return database
+}
+
+// Collection returns an empty collection in a fresh database that is dropped when the test ends.
+func Collection(t testing.TB, name string) *mongo.Collection {
+	t.Helper()
+	return Database(t).Collection(name)
+}
@@ -3,10 +3,16 @@ package database
 import "go.mongodb.org/mongo-driver/v2/mongo"
 
 type MongoFactoryConfig struct {
-	DatabaseName           string
-	SnapshotCollectionName string
-	UserCollectionName     string
-	DeltaCollectionName    string
+	DatabaseName            string
+	SnapshotCollectionName  string
+	UserCollectionName      string
+	DeltaCollectionName     string
+	TokenCollectionName     string
+	AuditCollectionName     string
+	JobCollectionName       string
+	LeaseCollectionName     string
+	GroupCollectionName     string
+	MigrationCollectionName string
 }
 
 type MongoFactory struct {
@@ -32,3 +38,27 @@ func (mf *MongoFactory) NewUserCollection() *mongo.Collection {
 func (mf *MongoFactory) NewDeltaCollection() *mongo.Collection {
 	return mf.client.Database(mf.config.DatabaseName).Collection(mf.config.DeltaCollectionName)
 }
+
+func (mf *MongoFactory) NewTokenCollection() *mongo.Collection {
+	return mf.client.Database(mf.config.DatabaseName).Collection(mf.config.TokenCollectionName)
+}
+
+func (mf *MongoFactory) NewAuditCollection() *mongo.Collection {
+	return mf.client.Database(mf.config.DatabaseName).Collection(mf.config.AuditCollectionName)
+}
+
+func (mf *MongoFactory) NewJobCollection() *mongo.Collection {
+	return mf.client.Database(mf.config.DatabaseName).Collection(mf.config.JobCollectionName)
+}
+
+func (mf *MongoFactory) NewLeaseCollection() *mongo.Collection {
+	return mf.client.Database(mf.config.DatabaseName).Collection(mf.config.LeaseCollectionName)
+}
+
+func (mf *MongoFactory) NewGroupCollection() *mongo.Collection {
+	return mf.client.Database(mf.config.DatabaseName).Collection(mf.config.GroupCollectionName)
+}
+
+func (mf *MongoFactory) NewMigrationCollection() *mongo.Collection {
+	return mf.client.Database(mf.config.DatabaseName).Collection(mf.config.MigrationCollectionName)
+}
@@ -0,0 +1,293 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Mongo error codes for dropping an index, or the collection holding it, that does not exist.
+const (
+	codeNamespaceNotFound = 26
+	codeIndexNotFound     = 27
+)
+
+// All is the ordered schema history. Append new migrations; never renumber or edit applied ones.
+var All = []Migration{
+	{
+		Version:     1,
+		Description: "index snapshots by user and timestamp",
+		Up: createIndexes((*database.MongoFactory).NewSnapshotCollection,
+			index("userId_timestamp", bson.D{{Key: "userId", Value: 1}, {Key: "timestamp", Value: 1}}, false),
+		),
+		Down: dropIndexes((*database.MongoFactory).NewSnapshotCollection, "userId_timestamp"),
+	},
+	{
+		Version:     2,
+		Description: "index deltas by user and timestamp",
+		Up: createIndexes((*database.MongoFactory).NewDeltaCollection,
+			index("userId_timestamp", bson.D{{Key: "userId", Value: 1}, {Key: "timestamp", Value: 1}}, false),
+		),
+		Down: dropIndexes((*database.MongoFactory).NewDeltaCollection, "userId_timestamp"),
+	},
+	{
+		Version:     3,
+		Description: "index users by unique runescape name and tracking status",
+		Up: createIndexes((*database.MongoFactory).NewUserCollection,
+			index("runescapeName_unique", bson.D{{Key: "runescapeName", Value: 1}}, true),
+			index("trackingStatus", bson.D{{Key: "trackingStatus", Value: 1}}, false),
+		),
+		Down: dropIndexes((*database.MongoFactory).NewUserCollection, "runescapeName_unique", "trackingStatus"),
+	},
+	{
+		Version:     4,
+		Description: "index tokens by unique secret hash",
+		Up: createIndexes((*database.MongoFactory).NewTokenCollection,
+			index("secretHash_unique", bson.D{{Key: "secretHash", Value: 1}}, true),
+		),
+		Down: dropIndexes((*database.MongoFactory).NewTokenCollection, "secretHash_unique"),
+	},
+	{
+		Version:     5,
+		Description: "index audit records by timestamp, actor and entity",
+		Up: createIndexes((*database.MongoFactory).NewAuditCollection,
+			index("timestamp", bson.D{{Key: "timestamp", Value: -1}}, false),
+			index("actor_timestamp", bson.D{{Key: "actor", Value: 1}, {Key: "timestamp", Value: -1}}, false),
+			index("entity_timestamp", bson.D{{Key: "entityType", Value: 1}, {Key: "entityId", Value: 1}, {Key: "timestamp", Value: -1}}, false),
+		),
+		Down: dropIndexes((*database.MongoFactory).NewAuditCollection, "timestamp", "actor_timestamp", "entity_timestamp"),
+	},
+	{
+		Version:     6,
+		Description: "index worker jobs by status and creation time",
+		Up: createIndexes((*database.MongoFactory).NewJobCollection,
+			index("status_createdAt", bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: 1}}, false),
+		),
+		Down: dropIndexes((*database.MongoFactory).NewJobCollection, "status_createdAt"),
+	},
+	{
+		Version:     7,
+		Description: "backfill normalized user names and index users by normalized name and alias",
+		Up: func(ctx context.Context, f *database.MongoFactory) error {
+			if err := backfillNormalizedNames(ctx, f.NewUserCollection()); err != nil {
+				return err
+			}
+			return createIndexes((*database.MongoFactory).NewUserCollection,
+				index("normalizedName", bson.D{{Key: "normalizedName", Value: 1}}, false),
+				index("aliases", bson.D{{Key: "aliases", Value: 1}}, false),
+			)(ctx, f)
+		},
+		Down: dropIndexes((*database.MongoFactory).NewUserCollection, "normalizedName", "aliases"),
+	},
+	{
+		Version:     8,
+		Description: "backfill user creation times and index users for search",
+		Up: func(ctx context.Context, f *database.MongoFactory) error {
+			if err := backfillUserCreatedAt(ctx, f.NewUserCollection(), f.NewSnapshotCollection()); err != nil {
+				return err
+			}
+			return createIndexes((*database.MongoFactory).NewUserCollection,
+				index("normalizedName_id", bson.D{{Key: "normalizedName", Value: 1}, {Key: "_id", Value: 1}}, false),
+				index("createdAt_id", bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}, false),
+				index("trackingStatus_normalizedName", bson.D{{Key: "trackingStatus", Value: 1}, {Key: "normalizedName", Value: 1}}, false),
+				index("accountType_normalizedName", bson.D{{Key: "accountType", Value: 1}, {Key: "normalizedName", Value: 1}}, false),
+			)(ctx, f)
+		},
+		Down: dropIndexes((*database.MongoFactory).NewUserCollection, "normalizedName_id", "createdAt_id", "trackingStatus_normalizedName", "accountType_normalizedName"),
+	},
+	{
+		Version:     9,
+		Description: "index groups by unique normalized name and member",
+		Up: createIndexes((*database.MongoFactory).NewGroupCollection,
+			index("normalizedName_unique", bson.D{{Key: "normalizedName", Value: 1}}, true),
+			index("memberships_userId", bson.D{{Key: "memberships.userId", Value: 1}}, false),
+		),
+		Down: dropIndexes((*database.MongoFactory).NewGroupCollection, "normalizedName_unique", "memberships_userId"),
+	},
+	{
+		Version:     10,
+		Description: "dedupe live user names and make them unique instead of raw runescape names",
+		Up: func(ctx context.Context, f *database.MongoFactory) error {
+			if err := dedupeLiveNames(ctx, f.NewUserCollection()); err != nil {
+				return err
+			}
+			if err := createIndexes((*database.MongoFactory).NewUserCollection,
+				partialUniqueIndex("liveName_unique", bson.D{{Key: "liveName", Value: 1}}, bson.M{"liveName": bson.M{"$exists": true}}),
+			)(ctx, f); err != nil {
+				return err
+			}
+			return dropIndexes((*database.MongoFactory).NewUserCollection, "runescapeName_unique")(ctx, f)
+		},
+		// Down cannot restore a raw unique index over every user: merge tombstones share an empty
+		// name and deduped users keep theirs, so names are only unique among live users.
+		Down: func(ctx context.Context, f *database.MongoFactory) error {
+			if err := createIndexes((*database.MongoFactory).NewUserCollection,
+				partialUniqueIndex("runescapeName_unique", bson.D{{Key: "runescapeName", Value: 1}}, bson.M{"liveName": bson.M{"$exists": true}}),
+			)(ctx, f); err != nil {
+				return err
+			}
+			return dropIndexes((*database.MongoFactory).NewUserCollection, "liveName_unique")(ctx, f)
+		},
+	},
+	{
+		Version:     11,
+		Description: "index worker jobs by user and users by the user they were merged into",
+		Up: func(ctx context.Context, f *database.MongoFactory) error {
+			if err := createIndexes((*database.MongoFactory).NewJobCollection,
+				index("userId_createdAt", bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: 1}}, false),
+			)(ctx, f); err != nil {
+				return err
+			}
+			return createIndexes((*database.MongoFactory).NewUserCollection,
+				index("mergedInto", bson.D{{Key: "mergedInto", Value: 1}}, false),
+			)(ctx, f)
+		},
+		Down: func(ctx context.Context, f *database.MongoFactory) error {
+			if err := dropIndexes((*database.MongoFactory).NewUserCollection, "mergedInto")(ctx, f); err != nil {
+				return err
+			}
+			return dropIndexes((*database.MongoFactory).NewJobCollection, "userId_createdAt")(ctx, f)
+		},
+	},
+}
+
+// dedupeLiveNames sets liveName on users that are neither deleted nor merged. Live users whose
+// names differ only in case or spacing are the same player tracked twice; the earliest created
+// keeps the name and the others are soft-deleted, which keeps their history for a later merge.
+func dedupeLiveNames(ctx context.Context, collection *mongo.Collection) error {
+	live := bson.M{"deletedAt": bson.M{"$exists": false}, "mergedInto": bson.M{"$exists": false}}
+	opts := options.Find().
+		SetProjection(bson.M{"normalizedName": 1}).
+		SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := collection.Find(ctx, live, opts)
+	if err != nil {
+		return err
+	}
+
+	var users []struct {
+		Id             string `bson:"_id"`
+		NormalizedName string `bson:"normalizedName"`
+	}
+	if err := cursor.All(ctx, &users); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	kept := make(map[string]bool, len(users))
+	for _, u := range users {
+		update := bson.M{"$set": bson.M{"liveName": u.NormalizedName}}
+		if kept[u.NormalizedName] {
+			update = bson.M{"$set": bson.M{"deletedAt": now}, "$unset": bson.M{"liveName": ""}}
+		}
+		kept[u.NormalizedName] = true
+		if _, err := collection.UpdateOne(ctx, bson.M{"_id": u.Id}, update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillNormalizedNames sets normalizedName on users written before it existed. The index on it
+// is not unique, since existing names may differ only in case.
+func backfillNormalizedNames(ctx context.Context, collection *mongo.Collection) error {
+	cursor, err := collection.Find(ctx, bson.M{"normalizedName": bson.M{"$exists": false}}, options.Find().SetProjection(bson.M{"runescapeName": 1}))
+	if err != nil {
+		return err
+	}
+
+	var users []struct {
+		Id            string `bson:"_id"`
+		RunescapeName string `bson:"runescapeName"`
+	}
+	if err := cursor.All(ctx, &users); err != nil {
+		return err
+	}
+
+	for _, u := range users {
+		update := bson.M{"$set": bson.M{"normalizedName": api.NormalizeRunescapeName(u.RunescapeName)}}
+		if _, err := collection.UpdateOne(ctx, bson.M{"_id": u.Id}, update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillUserCreatedAt sets createdAt on users written before it existed to the time of their
+// first snapshot, or to now for users without any, so that every user sorts by it.
+func backfillUserCreatedAt(ctx context.Context, users *mongo.Collection, snapshots *mongo.Collection) error {
+	cursor, err := users.Find(ctx, bson.M{"createdAt": bson.M{"$exists": false}}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return err
+	}
+
+	var missing []struct {
+		Id string `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &missing); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, u := range missing {
+		createdAt := now
+		var first struct {
+			Timestamp time.Time `bson:"timestamp"`
+		}
+		err := snapshots.FindOne(ctx, bson.M{"userId": u.Id}, options.FindOne().SetSort(bson.D{{Key: "timestamp", Value: 1}})).Decode(&first)
+		if err == nil {
+			createdAt = first.Timestamp
+		} else if !errors.Is(err, mongo.ErrNoDocuments) {
+			return err
+		}
+
+		if _, err := users.UpdateOne(ctx, bson.M{"_id": u.Id}, bson.M{"$set": bson.M{"createdAt": createdAt}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func index(name string, keys bson.D, unique bool) mongo.IndexModel {
+	opts := options.Index().SetName(name)
+	if unique {
+		opts.SetUnique(true)
+	}
+	return mongo.IndexModel{Keys: keys, Options: opts}
+}
+
+// partialUniqueIndex is unique among the documents matching filter. Partial filters cannot use
+// $exists: false, so absent fields have to be excluded by a field that is only set when wanted.
+func partialUniqueIndex(name string, keys bson.D, filter bson.M) mongo.IndexModel {
+	return mongo.IndexModel{Keys: keys, Options: options.Index().SetName(name).SetUnique(true).SetPartialFilterExpression(filter)}
+}
+
+// createIndexes is idempotent because Mongo accepts an index that already exists with the same
+// name and keys.
+func createIndexes(collection func(*database.MongoFactory) *mongo.Collection, models ...mongo.IndexModel) func(context.Context, *database.MongoFactory) error {
+	return func(ctx context.Context, f *database.MongoFactory) error {
+		_, err := collection(f).Indexes().CreateMany(ctx, models)
+		return err
+	}
+}
+
+func dropIndexes(collection func(*database.MongoFactory) *mongo.Collection, names ...string) func(context.Context, *database.MongoFactory) error {
+	return func(ctx context.Context, f *database.MongoFactory) error {
+		for _, name := range names {
+			err := collection(f).Indexes().DropOne(ctx, name)
+			var commandErr mongo.CommandError
+			if errors.As(err, &commandErr) && (commandErr.HasErrorCode(codeIndexNotFound) || commandErr.HasErrorCode(codeNamespaceNotFound)) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,187 @@
+// Package migration applies versioned changes to the Mongo schema, such as indexes, and records
+// the ones that have run in the migration collection.
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var ErrInvalidMigrations = errors.New("invalid migrations")
+var ErrUnknownMigration = errors.New("applied migration is not known to this build")
+
+// Migration is one step of the schema. Up and Down must both be idempotent: Up may run again
+// after a run that failed part way, and Down may run after a partially applied Up.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, f *database.MongoFactory) error
+	Down        func(ctx context.Context, f *database.MongoFactory) error
+}
+
+// Status reports whether a migration has been applied. AppliedAt is nil for pending migrations.
+type Status struct {
+	Version     int
+	Description string
+	AppliedAt   *time.Time
+}
+
+type record struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"appliedAt"`
+}
+
+type Migrator struct {
+	factory    *database.MongoFactory
+	migrations []Migration
+}
+
+// NewMigrator returns a migrator for migrations, which must be in strictly increasing version order.
+func NewMigrator(f *database.MongoFactory, migrations []Migration) (*Migrator, error) {
+	if err := validate(migrations); err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		factory:    f,
+		migrations: migrations,
+	}, nil
+}
+
+func validate(migrations []Migration) error {
+	previous := 0
+	for _, m := range migrations {
+		if m.Version <= previous {
+			return fmt.Errorf("%w: version %d must be greater than %d", ErrInvalidMigrations, m.Version, previous)
+		}
+		if m.Up == nil || m.Down == nil {
+			return fmt.Errorf("%w: version %d needs both an up and a down step", ErrInvalidMigrations, m.Version)
+		}
+		previous = m.Version
+	}
+	return nil
+}
+
+// Status lists every known migration in version order.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Description: migration.Description}
+		if r, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &r.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Pending lists the migrations that have not been applied, in version order.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies pending migrations in order, stopping after target. A target of 0 applies all of
+// them. It returns the migrations it applied.
+func (m *Migrator) Up(ctx context.Context, target int) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var ran []Migration
+	for _, migration := range m.migrations {
+		if target > 0 && migration.Version > target {
+			break
+		}
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		if err := migration.Up(ctx, m.factory); err != nil {
+			return ran, fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Description, err)
+		}
+
+		r := record{Version: migration.Version, Description: migration.Description, AppliedAt: time.Now().UTC()}
+		if _, err := m.factory.NewMigrationCollection().InsertOne(ctx, r); err != nil && !mongo.IsDuplicateKeyError(err) {
+			return ran, fmt.Errorf("recording migration %d: %w", migration.Version, err)
+		}
+		ran = append(ran, migration)
+	}
+	return ran, nil
+}
+
+// Down reverts the most recently applied migrations, newest first, and returns the ones it reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[int]Migration, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+	for version := range applied {
+		if _, ok := known[version]; !ok {
+			return nil, fmt.Errorf("%w: version %d", ErrUnknownMigration, version)
+		}
+	}
+
+	var reverted []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+
+		if err := migration.Down(ctx, m.factory); err != nil {
+			return reverted, fmt.Errorf("reverting migration %d (%s) failed: %w", migration.Version, migration.Description, err)
+		}
+		if _, err := m.factory.NewMigrationCollection().DeleteOne(ctx, bson.M{"_id": migration.Version}); err != nil {
+			return reverted, fmt.Errorf("removing record of migration %d: %w", migration.Version, err)
+		}
+		reverted = append(reverted, migration)
+	}
+	return reverted, nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]record, error) {
+	cursor, err := m.factory.NewMigrationCollection().Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("reading applied migrations: %w", err)
+	}
+
+	var records []record
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, fmt.Errorf("reading applied migrations: %w", err)
+	}
+
+	applied := make(map[int]record, len(records))
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
@@ -0,0 +1,106 @@
+package migration_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/internal/database/databasetest"
+	"github.com/ctfloyd/hazelmere-api/src/internal/database/migration"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func noop(context.Context, *database.MongoFactory) error { return nil }
+
+func TestNewMigratorValidatesOrder(t *testing.T) {
+	f := database.NewMongoFactory(nil, database.MongoFactoryConfig{})
+	cases := map[string][]migration.Migration{
+		"duplicate version":  {{Version: 1, Up: noop, Down: noop}, {Version: 1, Up: noop, Down: noop}},
+		"decreasing version": {{Version: 2, Up: noop, Down: noop}, {Version: 1, Up: noop, Down: noop}},
+		"zero version":       {{Version: 0, Up: noop, Down: noop}},
+		"missing down":       {{Version: 1, Up: noop}},
+	}
+	for name, migrations := range cases {
+		if _, err := migration.NewMigrator(f, migrations); !errors.Is(err, migration.ErrInvalidMigrations) {
+			t.Errorf("%s: got %v, want ErrInvalidMigrations", name, err)
+		}
+	}
+}
+
+func TestAllIsValid(t *testing.T) {
+	f := database.NewMongoFactory(nil, database.MongoFactoryConfig{})
+	if _, err := migration.NewMigrator(f, migration.All); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigratorUpAndDown(t *testing.T) {
+	ctx := context.Background()
+	db := databasetest.Database(t)
+	f := database.NewMongoFactory(db.Client(), database.MongoFactoryConfig{
+		DatabaseName:            db.Name(),
+		SnapshotCollectionName:  "snapshot",
+		UserCollectionName:      "user",
+		DeltaCollectionName:     "delta",
+		TokenCollectionName:     "token",
+		AuditCollectionName:     "audit",
+		JobCollectionName:       "job",
+		LeaseCollectionName:     "lease",
+		GroupCollectionName:     "group",
+		MigrationCollectionName: "migration",
+	})
+	migrator, err := migration.NewMigrator(f, migration.All)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := migrator.Up(ctx, 0)
+	if err != nil || len(applied) != len(migration.All) {
+		t.Fatalf("Up applied %d migrations, %v; want %d", len(applied), err, len(migration.All))
+	}
+	if again, err := migrator.Up(ctx, 0); err != nil || len(again) != 0 {
+		t.Errorf("second Up applied %d migrations, %v; want none", len(again), err)
+	}
+	if pending, err := migrator.Pending(ctx); err != nil || len(pending) != 0 {
+		t.Errorf("Pending after Up = %v, %v; want none", pending, err)
+	}
+
+	users := f.NewUserCollection()
+	if _, err := users.InsertOne(ctx, bson.M{"_id": "1", "runescapeName": "Zezima", "normalizedName": "zezima", "liveName": "zezima"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.InsertOne(ctx, bson.M{"_id": "2", "runescapeName": "zezima", "normalizedName": "zezima", "liveName": "zezima"}); err == nil {
+		t.Error("inserting a second live user with the same normalized name succeeded, want a unique index violation")
+	}
+	// Deleted and merged users leave liveName unset and may share the name with a live user, and
+	// merge tombstones share an empty name.
+	for id, name := range map[string]string{"3": "ZEZIMA", "4": "zeZima", "5": "Zezima", "6": "", "7": ""} {
+		if _, err := users.InsertOne(ctx, bson.M{"_id": id, "runescapeName": name, "normalizedName": "zezima"}); err != nil {
+			t.Errorf("inserting a user without a live name: %v", err)
+		}
+	}
+
+	reverted, err := migrator.Down(ctx, 2)
+	if err != nil || len(reverted) != 2 || reverted[0].Version != len(migration.All) {
+		t.Fatalf("Down reverted %v, %v; want the two newest migrations", reverted, err)
+	}
+	if pending, err := migrator.Pending(ctx); err != nil || len(pending) != 2 || pending[0].Version != len(migration.All)-1 {
+		t.Errorf("Pending after Down = %v, %v; want the two reverted migrations", pending, err)
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		pending := s.Version > len(migration.All)-2
+		if (s.AppliedAt == nil) != pending {
+			t.Errorf("migration %d applied = %v, want %v", s.Version, s.AppliedAt != nil, !pending)
+		}
+	}
+
+	if applied, err := migrator.Up(ctx, len(migration.All)-1); err != nil || len(applied) != 1 {
+		t.Errorf("Up to %d applied %d migrations, %v; want 1", len(migration.All)-1, len(applied), err)
+	}
+}
@@ -2,103 +2,213 @@ package wom
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log/slog"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
-	"github.com/ctfloyd/hazelmere-commons/pkg/hz_client"
 	"github.com/ctfloyd/hazelmere-commons/pkg/hz_logger"
 )
 
-const requestsPerMinute = 7
+// DefaultHost is the public Wise Old Man API.
+const DefaultHost = "https://api.wiseoldman.net"
+
+// maxLimit is the largest page WOM returns for snapshot queries.
 const maxLimit = 50
 
-type Client struct {
-	h             *hz_client.HttpClient
-	nextRequestAt time.Time
+var (
+	ErrWom            = errors.New("wise old man request failed")
+	ErrPlayerNotFound = errors.New("wise old man player not found")
+	ErrRateLimited    = errors.New("wise old man rate limit exceeded")
+)
+
+// RetryPolicy controls how failed requests are retried. Transport errors, 429 and 5xx responses
+// are retried; waits use full jitter up to min(MaxWait, BaseWait * 2^attempt), and never less
+// than a 429's Retry-After.
+type RetryPolicy struct {
+	MaxRetries int
+	BaseWait   time.Duration
+	MaxWait    time.Duration
+}
+
+type Config struct {
+	Host    string
+	Timeout time.Duration
+	// RequestsPerMinute spaces requests evenly. Zero disables limiting.
+	RequestsPerMinute int
+	Retry             RetryPolicy
+	// Limiter is shared by every client given it, so separate clients stay under one WOM limit.
+	// A nil Limiter creates one from RequestsPerMinute.
+	Limiter *Limiter
+}
+
+// DefaultConfig stays under WOM's anonymous limit of 20 requests per minute with room to spare.
+var DefaultConfig = Config{
+	Host:              DefaultHost,
+	Timeout:           5 * time.Second,
+	RequestsPerMinute: 7,
+	Retry: RetryPolicy{
+		MaxRetries: 4,
+		BaseWait:   10 * time.Second,
+		MaxWait:    time.Minute,
+	},
 }
 
-func NewClient(logger hz_logger.Logger) *Client {
-	wom := hz_client.NewHttpClient(hz_client.HttpClientConfig{
-		Host:           "https://api.wiseoldman.net",
-		TimeoutMs:      5000,
-		Retries:        0,
-		RetryWaitMs:    0,
-		RetryMaxWaitMs: 0,
-	}, func(str string) { logger.Error(context.TODO(), str) })
+// Client calls the Wise Old Man API. It is safe for concurrent use; every request waits on the
+// rate limiter first.
+type Client struct {
+	host       string
+	httpClient *http.Client
+	limiter    *Limiter
+	retry      RetryPolicy
+	logger     hz_logger.Logger
+}
 
+func NewClient(logger hz_logger.Logger, config Config) *Client {
+	limiter := config.Limiter
+	if limiter == nil {
+		limiter = NewLimiter(config.RequestsPerMinute)
+	}
 	return &Client{
-		h: wom,
+		host:       config.Host,
+		httpClient: &http.Client{Timeout: config.Timeout},
+		limiter:    limiter,
+		retry:      config.Retry,
+		logger:     logger,
 	}
 }
 
-func (c *Client) GetPlayerDetails(username string) (PlayerDetails, error) {
-	url := fmt.Sprintf("%s/v2/players/%s", c.h.GetHost(), username)
-	attempts := 0
-	for attempts < 2 {
-		c.modulate()
-		var response PlayerDetails
-		if err := c.h.Get(url, &response); err != nil {
-			time.Sleep(10 * time.Second)
-			attempts++
-			continue
-		}
-		return response, nil
+func (c *Client) GetPlayerDetails(ctx context.Context, username string) (PlayerDetails, error) {
+	var response PlayerDetails
+	if err := c.get(ctx, "/v2/players/"+url.PathEscape(username), nil, &response); err != nil {
+		return PlayerDetails{}, err
 	}
-	return PlayerDetails{}, errors.New("ran out of attempts")
+	return response, nil
 }
 
-func (c *Client) GetPlayerSnapshots(username string, startTime time.Time, endTime time.Time) ([]Snapshot, error) {
+// GetPlayerSnapshots returns every snapshot of username taken between startTime and endTime,
+// following WOM's pagination.
+func (c *Client) GetPlayerSnapshots(ctx context.Context, username string, startTime time.Time, endTime time.Time) ([]Snapshot, error) {
 	snapshots := make([]Snapshot, 0)
+	path := "/v2/players/" + url.PathEscape(username) + "/snapshots"
 
-	done := false
-	page := 0
-	errorCount := 0
-	for !done {
-		slog.Info("page", slog.Int("page", page))
-		c.modulate()
-		url := fmt.Sprintf("%s/v2/players/%s/snapshots?startDate=%s&endDate=%s&limit=%d&offset=%d",
-			c.h.GetHost(),
-			username,
-			startTime.Format("2006-01-02"),
-			endTime.Format("2006-01-02"),
-			maxLimit,
-			page*maxLimit,
-		)
+	for offset := 0; ; offset += maxLimit {
+		query := url.Values{}
+		query.Set("startDate", startTime.UTC().Format(time.RFC3339))
+		query.Set("endDate", endTime.UTC().Format(time.RFC3339))
+		query.Set("limit", strconv.Itoa(maxLimit))
+		query.Set("offset", strconv.Itoa(offset))
 
 		var response []Snapshot
+		if err := c.get(ctx, path, query, &response); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, response...)
 
-		if err := c.h.Get(url, &response); err != nil {
-			errorCount++
-			if errorCount >= 5 {
-				return nil, err
-			}
-			time.Sleep(10 * time.Second)
-			continue
+		if len(response) < maxLimit {
+			return snapshots, nil
 		}
+	}
+}
 
-		snapshots = append(snapshots, response...)
+func (c *Client) get(ctx context.Context, path string, query url.Values, response any) error {
+	target := c.host + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return errors.Join(ErrWom, err)
+		}
+
+		retryAfter, err := c.attempt(ctx, target, response)
+		if err == nil {
+			return nil
+		}
+
+		retryable := retryAfter >= 0
+		if !retryable || attempt >= c.retry.MaxRetries {
+			return err
+		}
 
-		page++
-		errorCount = 0
-		if len(response) != 50 {
-			done = true
+		wait := max(c.backoff(attempt), retryAfter)
+		if errors.Is(err, ErrRateLimited) {
+			// Hold back every caller sharing the limiter, not just this one.
+			c.limiter.Delay(wait)
 		}
+		c.logger.WarnArgs(ctx, "Wise Old Man request to %s failed, retrying in %s: %v", path, wait, err)
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(wait):
+		}
+	}
+}
+
+// attempt sends the request once. A non-negative retryAfter means the failure may be retried,
+// no sooner than retryAfter.
+func (c *Client) attempt(ctx context.Context, target string, response any) (time.Duration, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return -1, errors.Join(ErrWom, err)
 	}
+	request.Header.Set("Accept", "application/json")
 
-	return snapshots, nil
+	res, err := c.httpClient.Do(request)
+	if err != nil {
+		// A cancelled or expired context is final, anything else is a transport failure.
+		if ctx.Err() != nil {
+			return -1, errors.Join(ErrWom, err)
+		}
+		return 0, errors.Join(ErrWom, err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return 0, errors.Join(ErrWom, err)
+	}
+
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode <= 299:
+		if err := json.Unmarshal(body, response); err != nil {
+			return -1, errors.Join(ErrWom, err)
+		}
+		return -1, nil
+	case res.StatusCode == http.StatusNotFound:
+		return -1, ErrPlayerNotFound
+	case res.StatusCode == http.StatusTooManyRequests:
+		return parseRetryAfter(res.Header.Get("Retry-After")), ErrRateLimited
+	case res.StatusCode >= 500:
+		return 0, errors.Join(ErrWom, fmt.Errorf("unexpected status %d", res.StatusCode))
+	default:
+		return -1, errors.Join(ErrWom, fmt.Errorf("unexpected status %d", res.StatusCode))
+	}
 }
 
-func (c *Client) getRequestOffset() time.Duration {
-	return (60 / requestsPerMinute) * time.Second
+func (c *Client) backoff(attempt int) time.Duration {
+	ceiling := c.retry.BaseWait << attempt
+	if ceiling <= 0 || ceiling > c.retry.MaxWait {
+		ceiling = c.retry.MaxWait
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling)
 }
 
-func (c *Client) modulate() {
-	now := time.Now()
-	if time.Now().Before(c.nextRequestAt) {
-		dur := c.nextRequestAt.Sub(now)
-		time.Sleep(dur)
+// parseRetryAfter reads a Retry-After header given in seconds. Anything else means no minimum wait.
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0
 	}
-	c.nextRequestAt = time.Now().Add(c.getRequestOffset())
+	return time.Duration(seconds) * time.Second
 }
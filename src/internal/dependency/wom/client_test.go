@@ -0,0 +1,146 @@
+package wom_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/dependency/wom"
+	"github.com/ctfloyd/hazelmere-api/src/internal/dependency/wom/womtest"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_logger"
+)
+
+var testLogger = hz_logger.NewZeroLogAdapater(hz_logger.LogLevelError)
+
+func TestGetPlayerDetails(t *testing.T) {
+	fixture := womtest.Fixture(t, "hazel_tester")
+	server := womtest.NewServer(t, fixture)
+	client := wom.NewClient(testLogger, server.Config())
+
+	details, err := client.GetPlayerDetails(context.Background(), "hazel tester")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !details.RegisteredAt.Equal(fixture.RegisteredAt) {
+		t.Errorf("registeredAt = %v, want %v", details.RegisteredAt, fixture.RegisteredAt)
+	}
+
+	_, err = client.GetPlayerDetails(context.Background(), "nobody")
+	if !errors.Is(err, wom.ErrPlayerNotFound) {
+		t.Errorf("err = %v, want ErrPlayerNotFound", err)
+	}
+	if got := server.Requests(); got != 2 {
+		t.Errorf("requests = %d, want 2 since not found is not retried", got)
+	}
+}
+
+func TestGetPlayerSnapshotsPages(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	player := womtest.Player{Username: "pager", RegisteredAt: start}
+	for i := range 120 {
+		player.Snapshots = append(player.Snapshots, wom.Snapshot{CreatedAt: start.Add(time.Duration(i) * time.Hour)})
+	}
+	server := womtest.NewServer(t, player)
+	client := wom.NewClient(testLogger, server.Config())
+
+	snapshots, err := client.GetPlayerSnapshots(context.Background(), "pager", start, start.Add(100*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 101 {
+		t.Errorf("got %d snapshots, want 101", len(snapshots))
+	}
+	if got := server.Requests(); got != 3 {
+		t.Errorf("requests = %d, want 3 pages", got)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	fixture := womtest.Fixture(t, "hazel_tester")
+
+	t.Run("Recovers", func(t *testing.T) {
+		server := womtest.NewServer(t, fixture)
+		client := wom.NewClient(testLogger, server.Config())
+		server.FailNext(http.StatusTooManyRequests, http.StatusBadGateway)
+
+		if _, err := client.GetPlayerDetails(context.Background(), fixture.Username); err != nil {
+			t.Fatal(err)
+		}
+		if got := server.Requests(); got != 3 {
+			t.Errorf("requests = %d, want 3", got)
+		}
+	})
+
+	t.Run("RateLimited", func(t *testing.T) {
+		server := womtest.NewServer(t, fixture)
+		client := wom.NewClient(testLogger, server.Config())
+		server.FailNext(http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests)
+
+		_, err := client.GetPlayerDetails(context.Background(), fixture.Username)
+		if !errors.Is(err, wom.ErrRateLimited) {
+			t.Errorf("err = %v, want ErrRateLimited", err)
+		}
+	})
+
+	t.Run("BadRequestIsFinal", func(t *testing.T) {
+		server := womtest.NewServer(t, fixture)
+		client := wom.NewClient(testLogger, server.Config())
+		server.FailNext(http.StatusBadRequest)
+
+		_, err := client.GetPlayerDetails(context.Background(), fixture.Username)
+		if !errors.Is(err, wom.ErrWom) {
+			t.Errorf("err = %v, want ErrWom", err)
+		}
+		if got := server.Requests(); got != 1 {
+			t.Errorf("requests = %d, want 1", got)
+		}
+	})
+}
+
+func TestCancelWhileRateLimited(t *testing.T) {
+	fixture := womtest.Fixture(t, "hazel_tester")
+	server := womtest.NewServer(t, fixture)
+	config := server.Config()
+	config.RequestsPerMinute = 1
+	client := wom.NewClient(testLogger, config)
+
+	if _, err := client.GetPlayerDetails(context.Background(), fixture.Username); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	_, err := client.GetPlayerDetails(ctx, fixture.Username)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("cancelled call took %s, want it to return with its context", elapsed)
+	}
+	if got := server.Requests(); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+}
+
+func TestLimiterSpacesConcurrentCallers(t *testing.T) {
+	limiter := wom.NewLimiter(1200) // one slot every 50ms
+
+	started := time.Now()
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			if err := limiter.Wait(context.Background()); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+
+	if elapsed := time.Since(started); elapsed < 150*time.Millisecond {
+		t.Errorf("4 waits took %s, want at least 150ms", elapsed)
+	}
+}
@@ -0,0 +1,65 @@
+package wom
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter spaces requests evenly at a fixed rate. Each Wait reserves the next free slot, so
+// concurrent callers queue up behind each other instead of bursting.
+type Limiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// NewLimiter allows requestsPerMinute requests a minute. Zero or less allows requests through
+// without waiting.
+func NewLimiter(requestsPerMinute int) *Limiter {
+	if requestsPerMinute <= 0 {
+		return &Limiter{}
+	}
+	return &Limiter{interval: time.Minute / time.Duration(requestsPerMinute)}
+}
+
+// Wait blocks until the caller's slot comes up or ctx is done. A cancelled caller still uses its
+// slot, which only ever makes the limiter more conservative.
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l.interval <= 0 {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	wait := slot.Sub(now)
+	if wait <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Delay pushes every slot back so nothing is sent for at least d, e.g. after WOM answers 429.
+func (l *Limiter) Delay(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); l.next.Before(until) {
+		l.next = until
+	}
+}
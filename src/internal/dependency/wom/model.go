@@ -4,8 +4,23 @@ import "time"
 
 type PlayerDetails struct {
 	RegisteredAt time.Time `json:"registeredAt"`
+	// UpdatedAt is when WOM last updated the player, which Type is as of. Nil if it never has.
+	UpdatedAt *time.Time `json:"updatedAt"`
+	// LastChangedAt is when WOM last saw the stats of the player change.
+	LastChangedAt *time.Time `json:"lastChangedAt"`
+	// Type is one of the PlayerType values.
+	Type string `json:"type"`
 }
 
+// Player types WOM reports. It does not tell group ironmen apart from ironmen.
+const (
+	PlayerTypeUnknown  = "unknown"
+	PlayerTypeRegular  = "regular"
+	PlayerTypeIronman  = "ironman"
+	PlayerTypeHardcore = "hardcore"
+	PlayerTypeUltimate = "ultimate"
+)
+
 type Snapshot struct {
 	CreatedAt time.Time    `json:"createdAt"`
 	Data      SnapshotData `json:"data"`
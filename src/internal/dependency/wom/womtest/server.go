@@ -0,0 +1,188 @@
+// Package womtest provides a fake Wise Old Man API for testing code that backfills from WOM
+// without network access.
+package womtest
+
+import (
+	"embed"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/dependency/wom"
+)
+
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+// Player is a player known to the fake server.
+type Player struct {
+	Username     string         `json:"username"`
+	RegisteredAt time.Time      `json:"registeredAt"`
+	Snapshots    []wom.Snapshot `json:"snapshots"`
+	// Type is the WOM player type, unknown when left out.
+	Type string `json:"type"`
+	// UpdatedAt is when WOM last updated the player, never when left out.
+	UpdatedAt *time.Time `json:"updatedAt"`
+}
+
+// Fixture loads the player stored in fixtures/<name>.json.
+func Fixture(t testing.TB, name string) Player {
+	t.Helper()
+
+	content, err := fixtures.ReadFile("fixtures/" + name + ".json")
+	if err != nil {
+		t.Fatalf("reading wom fixture %s: %v", name, err)
+	}
+	var player Player
+	if err := json.Unmarshal(content, &player); err != nil {
+		t.Fatalf("parsing wom fixture %s: %v", name, err)
+	}
+	return player
+}
+
+// Server is a fake of the WOM player and snapshot endpoints. Responses can be made to fail with
+// FailNext to exercise retries.
+type Server struct {
+	URL string
+
+	mu       sync.Mutex
+	players  map[string]Player
+	failures []int
+	requests int
+}
+
+// NewServer starts a fake WOM server that is closed when the test ends.
+func NewServer(t testing.TB, players ...Player) *Server {
+	t.Helper()
+
+	s := &Server{players: make(map[string]Player)}
+	for _, player := range players {
+		s.AddPlayer(player)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v2/players/{username}", s.getPlayer)
+	mux.HandleFunc("GET /v2/players/{username}/snapshots", s.getSnapshots)
+
+	server := httptest.NewServer(s.track(mux))
+	t.Cleanup(server.Close)
+	s.URL = server.URL
+	return s
+}
+
+// Config returns a client config pointed at the server, without rate limiting or retry waits.
+func (s *Server) Config() wom.Config {
+	return wom.Config{
+		Host:    s.URL,
+		Timeout: 5 * time.Second,
+		Retry:   wom.RetryPolicy{MaxRetries: 2},
+	}
+}
+
+func (s *Server) AddPlayer(player Player) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.players[strings.ToLower(player.Username)] = player
+}
+
+// FailNext answers the next len(statuses) requests with the given statuses, in order. A 429 is
+// sent with Retry-After: 0.
+func (s *Server) FailNext(statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, statuses...)
+}
+
+// Requests returns how many requests the server has received, including failed ones.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests++
+		var status int
+		if len(s.failures) > 0 {
+			status, s.failures = s.failures[0], s.failures[1:]
+		}
+		s.mu.Unlock()
+
+		if status == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "0")
+		}
+		writeJSON(w, status, map[string]string{"message": http.StatusText(status)})
+	})
+}
+
+func (s *Server) player(r *http.Request) (Player, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	player, ok := s.players[strings.ToLower(r.PathValue("username"))]
+	return player, ok
+}
+
+func (s *Server) getPlayer(w http.ResponseWriter, r *http.Request) {
+	player, ok := s.player(r)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "Player not found."})
+		return
+	}
+	playerType := player.Type
+	if playerType == "" {
+		playerType = wom.PlayerTypeUnknown
+	}
+	writeJSON(w, http.StatusOK, wom.PlayerDetails{RegisteredAt: player.RegisteredAt, UpdatedAt: player.UpdatedAt, Type: playerType})
+}
+
+// getSnapshots mirrors WOM: snapshots between startDate and endDate inclusive, newest first,
+// paged by limit and offset.
+func (s *Server) getSnapshots(w http.ResponseWriter, r *http.Request) {
+	player, ok := s.player(r)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "Player not found."})
+		return
+	}
+
+	query := r.URL.Query()
+	start, startErr := time.Parse(time.RFC3339, query.Get("startDate"))
+	end, endErr := time.Parse(time.RFC3339, query.Get("endDate"))
+	limit, limitErr := strconv.Atoi(query.Get("limit"))
+	offset, offsetErr := strconv.Atoi(query.Get("offset"))
+	if startErr != nil || endErr != nil || limitErr != nil || offsetErr != nil || limit <= 0 || offset < 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": "Invalid query."})
+		return
+	}
+
+	var matching []wom.Snapshot
+	for _, snapshot := range player.Snapshots {
+		if !snapshot.CreatedAt.Before(start) && !snapshot.CreatedAt.After(end) {
+			matching = append(matching, snapshot)
+		}
+	}
+	slices.SortFunc(matching, func(a, b wom.Snapshot) int { return b.CreatedAt.Compare(a.CreatedAt) })
+
+	page := make([]wom.Snapshot, 0, limit)
+	if offset < len(matching) {
+		page = append(page, matching[offset:min(offset+limit, len(matching))]...)
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
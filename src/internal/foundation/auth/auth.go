@@ -0,0 +1,49 @@
+// Package auth holds the scopes API tokens grant and the principal they authenticate as. It is
+// shared by the token domain and the HTTP middleware that enforces it.
+package auth
+
+import "slices"
+
+type Scope string
+
+const (
+	ScopeSnapshotRead  Scope = "snapshot:read"
+	ScopeSnapshotWrite Scope = "snapshot:write"
+	ScopeUserRead      Scope = "user:read"
+	ScopeUserWrite     Scope = "user:write"
+	ScopeWorkerTrigger Scope = "worker:trigger"
+	ScopeAdmin         Scope = "admin"
+)
+
+var AllScopes = []Scope{
+	ScopeSnapshotRead,
+	ScopeSnapshotWrite,
+	ScopeUserRead,
+	ScopeUserWrite,
+	ScopeWorkerTrigger,
+	ScopeAdmin,
+}
+
+// ScopeFromValue returns the scope matching value and whether it is a known scope.
+func ScopeFromValue(value string) (Scope, bool) {
+	for _, scope := range AllScopes {
+		if value == string(scope) {
+			return scope, true
+		}
+	}
+	return "", false
+}
+
+// Principal is an authenticated caller, attached to the request context by the authorizer.
+type Principal struct {
+	Name   string
+	Scopes []Scope
+	// Anonymous marks the principal every caller is given while authorization is disabled. It
+	// is not backed by a token.
+	Anonymous bool
+}
+
+// HasScope reports whether the principal was granted scope. Admin grants every scope.
+func (p Principal) HasScope(scope Scope) bool {
+	return slices.Contains(p.Scopes, ScopeAdmin) || slices.Contains(p.Scopes, scope)
+}
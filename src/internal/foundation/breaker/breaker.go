@@ -0,0 +1,140 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned without calling through while the breaker is open.
+var ErrOpen = errors.New("circuit breaker is open")
+
+type State int
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+type Config struct {
+	// FailureThreshold is the number of consecutive failures that opens the breaker.
+	FailureThreshold int
+	// OpenFor is how long calls fail fast before a single probe call is let through.
+	OpenFor time.Duration
+	// OnStateChange, when set, is called on every transition while the breaker is locked, so it
+	// must not call back into the breaker.
+	OnStateChange func(from State, to State)
+}
+
+var DefaultConfig = Config{
+	FailureThreshold: 5,
+	OpenFor:          30 * time.Second,
+}
+
+// Breaker stops calling a dependency after repeated failures. Once OpenFor has passed, one
+// probe call decides whether it closes again or stays open for another OpenFor.
+type Breaker struct {
+	config Config
+	now    func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func New(config Config) *Breaker {
+	return &Breaker{
+		config: config,
+		now:    time.Now,
+	}
+}
+
+// Execute calls fn unless the breaker is open, in which case it returns ErrOpen. Any error
+// returned by fn counts as a failure.
+func (b *Breaker) Execute(fn func() error) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+	err := fn()
+	b.record(err == nil)
+	return err
+}
+
+// State returns the current state, and when an open breaker opened.
+func (b *Breaker) State() (State, time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state, b.openedAt
+}
+
+func (b *Breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.config.OpenFor {
+			return ErrOpen
+		}
+		b.transition(StateHalfOpen)
+		b.probing = true
+	case StateHalfOpen:
+		if b.probing {
+			return ErrOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+func (b *Breaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateHalfOpen {
+		b.probing = false
+		if success {
+			b.failures = 0
+			b.transition(StateClosed)
+		} else {
+			b.open()
+		}
+		return
+	}
+
+	if success {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == StateClosed && b.failures >= max(b.config.FailureThreshold, 1) {
+		b.open()
+	}
+}
+
+func (b *Breaker) open() {
+	b.openedAt = b.now()
+	b.transition(StateOpen)
+}
+
+func (b *Breaker) transition(to State) {
+	from := b.state
+	b.state = to
+	if from != to && b.config.OnStateChange != nil {
+		b.config.OnStateChange(from, to)
+	}
+}
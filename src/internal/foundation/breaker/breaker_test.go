@@ -0,0 +1,89 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var errDown = errors.New("dependency is down")
+
+func TestBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	var transitions []State
+	b := New(Config{
+		FailureThreshold: 3,
+		OpenFor:          time.Minute,
+		OnStateChange:    func(_ State, to State) { transitions = append(transitions, to) },
+	})
+	b.now = func() time.Time { return now }
+
+	fail := func() error { return errDown }
+	succeed := func() error { return nil }
+
+	// A success resets the count, so only three failures in a row open the breaker.
+	b.Execute(fail)
+	b.Execute(fail)
+	b.Execute(succeed)
+	b.Execute(fail)
+	b.Execute(fail)
+	if state, _ := b.State(); state != StateClosed {
+		t.Fatalf("breaker is %s after two consecutive failures, want closed", state)
+	}
+	b.Execute(fail)
+	if state, _ := b.State(); state != StateOpen {
+		t.Fatalf("breaker is %s after three consecutive failures, want open", state)
+	}
+
+	called := false
+	if err := b.Execute(func() error { called = true; return nil }); !errors.Is(err, ErrOpen) || called {
+		t.Fatalf("open breaker returned %v and called through %t, want ErrOpen without calling", err, called)
+	}
+
+	// After OpenFor a failing probe opens it again for another OpenFor.
+	now = now.Add(time.Minute)
+	if err := b.Execute(fail); !errors.Is(err, errDown) {
+		t.Fatalf("probe returned %v, want the dependency error", err)
+	}
+	if err := b.Execute(succeed); !errors.Is(err, ErrOpen) {
+		t.Fatalf("breaker after a failed probe returned %v, want ErrOpen", err)
+	}
+
+	// A successful probe closes it.
+	now = now.Add(time.Minute)
+	if err := b.Execute(succeed); err != nil {
+		t.Fatalf("probe returned %v", err)
+	}
+	if state, _ := b.State(); state != StateClosed {
+		t.Fatalf("breaker is %s after a successful probe, want closed", state)
+	}
+
+	want := []State{StateOpen, StateHalfOpen, StateOpen, StateHalfOpen, StateClosed}
+	if len(transitions) != len(want) {
+		t.Fatalf("transitions %v, want %v", transitions, want)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Fatalf("transitions %v, want %v", transitions, want)
+		}
+	}
+}
+
+func TestBreakerLetsOneProbeThroughAtATime(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	b := New(Config{FailureThreshold: 1, OpenFor: time.Minute})
+	b.now = func() time.Time { return now }
+
+	b.Execute(func() error { return errDown })
+	now = now.Add(time.Minute)
+
+	err := b.Execute(func() error {
+		if err := b.Execute(func() error { return nil }); !errors.Is(err, ErrOpen) {
+			t.Errorf("second call during a probe returned %v, want ErrOpen", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("probe returned %v", err)
+	}
+}
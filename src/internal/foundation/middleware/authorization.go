@@ -1,61 +1,153 @@
 package middleware
 
 import (
+	"context"
+	"crypto/sha256"
 	"net/http"
-	"slices"
 	"strings"
 
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/auth"
 	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
 	"github.com/ctfloyd/hazelmere-api/src/internal/rest/service_error"
 	"github.com/ctfloyd/hazelmere-commons/pkg/hz_handler"
 )
 
+// TokenDefinition is a named API token and the scopes it grants.
+type TokenDefinition struct {
+	Name   string
+	Token  string
+	Scopes []auth.Scope
+}
+
+type principalContextKey struct{}
+
+// PrincipalFromContext returns the principal authenticated for the current request, if any.
+func PrincipalFromContext(ctx context.Context) (auth.Principal, bool) {
+	p, ok := ctx.Value(principalContextKey{}).(auth.Principal)
+	return p, ok
+}
+
+func withPrincipal(ctx context.Context, p auth.Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, p)
+}
+
+// TokenStore resolves bearer secrets issued at runtime. It reports false for unknown,
+// revoked or expired secrets.
+type TokenStore interface {
+	Authenticate(ctx context.Context, secret string) (auth.Principal, bool, error)
+}
+
 type Authorizer struct {
-	enabled       bool
-	allowedTokens []string
-	monitor       *monitor.Monitor
+	enabled bool
+	tokens  map[[sha256.Size]byte]TokenDefinition
+	store   TokenStore
+	monitor *monitor.Monitor
 }
 
-func NewAuthorizer(enabled bool, allowedTokens []string, mon *monitor.Monitor) *Authorizer {
-	return &Authorizer{enabled, allowedTokens, mon}
+// NewAuthorizer creates an authorizer. Config tokens are checked first so they keep working as a
+// bootstrap fallback; the store, which may be nil, is consulted for everything else.
+func NewAuthorizer(enabled bool, tokens []TokenDefinition, store TokenStore, mon *monitor.Monitor) *Authorizer {
+	byHash := make(map[[sha256.Size]byte]TokenDefinition, len(tokens))
+	for _, t := range tokens {
+		byHash[sha256.Sum256([]byte(t.Token))] = t
+	}
+	return &Authorizer{enabled, byHash, store, mon}
 }
 
-func (a *Authorizer) Authorize(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ctx, span := a.monitor.StartSpan(r.Context(), "Authorizer.Authorize")
-		defer span.End()
+// Require returns middleware that authenticates the bearer token and checks it grants scope.
+// A missing or unknown token is rejected with 401, a valid token without the scope with 403.
+func (a *Authorizer) Require(scope auth.Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := a.monitor.StartSpan(r.Context(), "Authorizer.Require")
+			defer span.End()
 
-		headerToken := r.Header.Get("Authorization")
-		if !a.enabled {
-			a.monitor.Logger().Info(ctx, "Authorization header present but auth is disabled.")
-			next.ServeHTTP(w, r.WithContext(ctx))
-			return
-		}
+			headerToken := r.Header.Get("Authorization")
+			if !a.enabled {
+				a.monitor.Logger().Info(ctx, "Authorization header present but auth is disabled.")
+				ctx = withPrincipal(ctx, auth.Principal{Name: "anonymous", Scopes: []auth.Scope{auth.ScopeAdmin}, Anonymous: true})
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
 
-		if headerToken == "" {
-			a.monitor.Logger().Warn(ctx, "No authorization header.")
-			unauthorized(w)
-			return
-		}
+			if headerToken == "" {
+				a.monitor.Logger().Warn(ctx, "No authorization header.")
+				a.monitor.Metrics().RecordAuthorizationFailure(ctx, monitor.TokenLabelNone, "missing_header")
+				unauthorized(w)
+				return
+			}
+
+			parts := strings.Split(headerToken, " ")
+			if len(parts) != 2 {
+				a.monitor.Logger().Warn(ctx, "Malformed authorization header.")
+				a.monitor.Metrics().RecordAuthorizationFailure(ctx, monitor.TokenLabelNone, "malformed_header")
+				unauthorized(w)
+				return
+			}
+
+			token := parts[1]
+			principal, ok, err := a.resolve(ctx, token)
+			if err != nil {
+				a.monitor.Logger().ErrorArgs(ctx, "Failed to resolve token: %v", err)
+				hz_handler.Error(w, service_error.Internal, "An unexpected error occurred while authorizing the request.")
+				return
+			}
+			if !ok {
+				a.monitor.Logger().WarnArgs(ctx, "Token %s not allowed.", monitor.TokenFingerprint(token))
+				a.monitor.Metrics().RecordAuthorizationFailure(ctx, monitor.TokenLabelUnknown, "token_not_allowed")
+				unauthorized(w)
+				return
+			}
+
+			if !principal.HasScope(scope) {
+				a.monitor.Logger().WarnArgs(ctx, "Token %s is missing scope %s.", principal.Name, scope)
+				a.monitor.Metrics().RecordAuthorizationFailure(ctx, principal.Name, "missing_scope")
+				forbidden(w)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(withPrincipal(ctx, principal)))
+		})
+	}
+}
 
-		parts := strings.Split(headerToken, " ")
-		if len(parts) != 2 {
-			a.monitor.Logger().Warn(ctx, "Malformed authorization header.")
-			unauthorized(w)
+// Identify attaches the principal of a valid bearer token to the request context without
+// rejecting anything, so middleware ahead of Require (such as rate limiting) can key on it.
+func (a *Authorizer) Identify(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.Header.Get("Authorization"), " ")
+		if !a.enabled || len(parts) != 2 {
+			next.ServeHTTP(w, r)
 			return
 		}
 
-		token := parts[1]
-		if !slices.Contains(a.allowedTokens, token) {
-			a.monitor.Logger().Warn(ctx, "Token not allowed.")
-			unauthorized(w)
+		principal, ok, err := a.resolve(r.Context(), parts[1])
+		if err != nil {
+			a.monitor.Logger().WarnArgs(r.Context(), "Failed to identify token: %v", err)
+		}
+		if !ok {
+			next.ServeHTTP(w, r)
 			return
 		}
 
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), principal)))
 	})
 }
 
+func (a *Authorizer) resolve(ctx context.Context, token string) (auth.Principal, bool, error) {
+	if definition, ok := a.tokens[sha256.Sum256([]byte(token))]; ok {
+		return auth.Principal{Name: definition.Name, Scopes: definition.Scopes}, true, nil
+	}
+	if a.store == nil {
+		return auth.Principal{}, false, nil
+	}
+	return a.store.Authenticate(ctx, token)
+}
+
 func unauthorized(w http.ResponseWriter) {
 	hz_handler.Error(w, service_error.Unauthorized, "You are not permitted to access this resource.")
 }
+
+func forbidden(w http.ResponseWriter) {
+	hz_handler.Error(w, service_error.Forbidden, "Your token does not grant access to this resource.")
+}
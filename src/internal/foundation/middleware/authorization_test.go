@@ -0,0 +1,102 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/token"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/auth"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/middleware"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_logger"
+)
+
+var testMonitor = monitor.New(hz_logger.NewZeroLogAdapater(hz_logger.LogLevelError))
+
+// principalEcho responds with the name of the principal the middleware attached, if any.
+var principalEcho = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if principal, ok := middleware.PrincipalFromContext(r.Context()); ok {
+		_, _ = w.Write([]byte(principal.Name))
+	}
+})
+
+func serve(handler http.Handler, authorization string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if authorization != "" {
+		r.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func newTestAuthorizer(t *testing.T, enabled bool) (*middleware.Authorizer, string) {
+	t.Helper()
+
+	service := token.NewTokenService(testMonitor, token.NewMemoryTokenRepository(testMonitor), token.NewTokenValidator())
+	_, secret, err := service.IssueToken(context.Background(), token.Token{Name: "issued", Scopes: []auth.Scope{auth.ScopeSnapshotRead}})
+	if err != nil {
+		t.Fatalf("IssueToken: %v", err)
+	}
+
+	authorizer := middleware.NewAuthorizer(enabled, []middleware.TokenDefinition{
+		{Name: "config", Token: "config-secret", Scopes: []auth.Scope{auth.ScopeUserWrite}},
+	}, service, testMonitor)
+	return authorizer, secret
+}
+
+func TestAuthorizerRequire(t *testing.T) {
+	authorizer, issued := newTestAuthorizer(t, true)
+	snapshotRead := authorizer.Require(auth.ScopeSnapshotRead)(principalEcho)
+	userWrite := authorizer.Require(auth.ScopeUserWrite)(principalEcho)
+
+	tests := []struct {
+		name          string
+		handler       http.Handler
+		authorization string
+		status        int
+		principal     string
+	}{
+		{"config token", userWrite, "Bearer config-secret", http.StatusOK, "config"},
+		{"issued token", snapshotRead, "Bearer " + issued, http.StatusOK, "issued"},
+		{"missing header", snapshotRead, "", http.StatusUnauthorized, ""},
+		{"malformed header", snapshotRead, "Bearer", http.StatusUnauthorized, ""},
+		{"unknown token", snapshotRead, "Bearer hzm_unknown", http.StatusUnauthorized, ""},
+		{"config token missing scope", snapshotRead, "Bearer config-secret", http.StatusForbidden, ""},
+		{"issued token missing scope", userWrite, "Bearer " + issued, http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(tt.handler, tt.authorization)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.status == http.StatusOK && w.Body.String() != tt.principal {
+				t.Errorf("principal = %q, want %q", w.Body.String(), tt.principal)
+			}
+		})
+	}
+}
+
+func TestAuthorizerRequireWhenDisabled(t *testing.T) {
+	authorizer, _ := newTestAuthorizer(t, false)
+
+	w := serve(authorizer.Require(auth.ScopeAdmin)(principalEcho), "")
+	if w.Code != http.StatusOK || w.Body.String() != "anonymous" {
+		t.Errorf("Require with auth disabled = %d %q, want 200 as anonymous", w.Code, w.Body.String())
+	}
+}
+
+func TestAuthorizerIdentify(t *testing.T) {
+	authorizer, issued := newTestAuthorizer(t, true)
+	handler := authorizer.Identify(principalEcho)
+
+	if w := serve(handler, "Bearer "+issued); w.Code != http.StatusOK || w.Body.String() != "issued" {
+		t.Errorf("Identify with an issued token = %d %q, want 200 as issued", w.Code, w.Body.String())
+	}
+	if w := serve(handler, "Bearer hzm_unknown"); w.Code != http.StatusOK || w.Body.String() != "" {
+		t.Errorf("Identify with an unknown token = %d %q, want 200 with no principal", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-api/src/internal/rest/service_error"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_handler"
+)
+
+const (
+	// HeaderIdempotencyKey is set by callers on a write they may send more than once.
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderIdempotentReplayed is set on a response replayed for a repeated key.
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+)
+
+// idempotencyKeyTtl is how long the response to a keyed write is kept for replay.
+const idempotencyKeyTtl = 24 * time.Hour
+
+// keyedResponse is the response to the first request sent with a key. done is closed once the
+// response is known; kept is false when it was a server error, which is not replayed.
+type keyedResponse struct {
+	requestHash [sha256.Size]byte
+	done        chan struct{}
+	kept        bool
+	status      int
+	header      http.Header
+	body        []byte
+	storedAt    time.Time
+}
+
+// Idempotency replays the response to a write sent again with the same Idempotency-Key, so that
+// callers can retry writes that are not idempotent in themselves. Keys belong to the caller and
+// route, and a key sent with a different body is refused. A repeat that arrives while the first
+// request is running waits for its response. Server errors are not kept, so a retry runs the
+// write again. Responses are kept in memory, so each instance of the API dedupes on its own.
+type Idempotency struct {
+	monitor *monitor.Monitor
+
+	mu        sync.Mutex
+	responses map[string]*keyedResponse
+	lastSweep time.Time
+}
+
+func NewIdempotency(mon *monitor.Monitor) *Idempotency {
+	return &Idempotency{
+		monitor:   mon,
+		responses: make(map[string]*keyedResponse),
+		lastSweep: time.Now(),
+	}
+}
+
+// Replay is middleware that dedupes keyed writes. It must run after Authorizer.Identify so keys
+// of authenticated callers are scoped to their token.
+func (i *Idempotency) Replay(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(HeaderIdempotencyKey)
+		if key == "" || r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, span := i.monitor.StartSpan(r.Context(), "Idempotency.Replay")
+		defer span.End()
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			hz_handler.Error(w, service_error.BadRequest, "Failed to read the request body.")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		hash := sha256.Sum256(body)
+		scoped := callerKey(r) + " " + r.Method + " " + r.URL.Path + " " + key
+
+		for {
+			response, first := i.claim(scoped, hash, time.Now())
+			if first {
+				i.serve(next, w, r, scoped, response)
+				return
+			}
+			if response.requestHash != hash {
+				i.monitor.Logger().WarnArgs(ctx, "Idempotency key %s reused for a different request.", key)
+				hz_handler.Error(w, service_error.BadRequest, "The Idempotency-Key was already used for a different request.")
+				return
+			}
+
+			select {
+			case <-response.done:
+			case <-r.Context().Done():
+				return
+			}
+			// A server error is forgotten, so the repeat claims the key and runs the write again.
+			if response.kept {
+				replay(w, response)
+				return
+			}
+		}
+	})
+}
+
+// claim returns the response kept for key, or claims key for the caller and reports that it is
+// the first to send it.
+func (i *Idempotency) claim(key string, hash [sha256.Size]byte, now time.Time) (*keyedResponse, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.sweep(now)
+
+	if response, ok := i.responses[key]; ok {
+		return response, false
+	}
+	response := &keyedResponse{requestHash: hash, done: make(chan struct{}), storedAt: now}
+	i.responses[key] = response
+	return response, true
+}
+
+// serve runs the write and keeps its response for replay, unless it was a server error or the
+// handler panicked.
+func (i *Idempotency) serve(next http.Handler, w http.ResponseWriter, r *http.Request, key string, response *keyedResponse) {
+	recorder := &recordingWriter{ResponseWriter: w}
+	finished := false
+	defer func() {
+		i.mu.Lock()
+		defer i.mu.Unlock()
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if finished && status < http.StatusInternalServerError {
+			response.kept = true
+			response.status = status
+			response.header = w.Header().Clone()
+			response.body = recorder.body.Bytes()
+		} else {
+			delete(i.responses, key)
+		}
+		close(response.done)
+	}()
+
+	next.ServeHTTP(recorder, r)
+	finished = true
+}
+
+func (i *Idempotency) sweep(now time.Time) {
+	if now.Sub(i.lastSweep) < time.Minute {
+		return
+	}
+	for key, response := range i.responses {
+		if now.Sub(response.storedAt) > idempotencyKeyTtl {
+			delete(i.responses, key)
+		}
+	}
+	i.lastSweep = now
+}
+
+func replay(w http.ResponseWriter, response *keyedResponse) {
+	for name, values := range response.header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(HeaderIdempotentReplayed, "true")
+	w.WriteHeader(response.status)
+	_, _ = w.Write(response.body)
+}
+
+// recordingWriter passes a response through while keeping a copy of it.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
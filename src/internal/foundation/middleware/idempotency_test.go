@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_logger"
+)
+
+// countingWrite stands in for a write: it counts its calls and answers with the count, or with
+// status when it is set.
+type countingWrite struct {
+	calls   atomic.Int32
+	status  atomic.Int32
+	release chan struct{}
+}
+
+func (c *countingWrite) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := c.calls.Add(1)
+	if c.release != nil {
+		<-c.release
+	}
+	if status := c.status.Load(); status != 0 {
+		w.WriteHeader(int(status))
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	_, _ = w.Write([]byte(strconv.Itoa(int(n))))
+}
+
+func newTestIdempotency() *Idempotency {
+	return NewIdempotency(monitor.New(hz_logger.NewZeroLogAdapater(hz_logger.LogLevelError)))
+}
+
+// keyed sends a POST with body and key from remoteAddr through the middleware.
+func keyed(handler http.Handler, key string, body string, remoteAddr string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/v1/snapshot", strings.NewReader(body))
+	r.RemoteAddr = remoteAddr
+	if key != "" {
+		r.Header.Set(HeaderIdempotencyKey, key)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestIdempotencyReplaysRepeatedWrites(t *testing.T) {
+	write := &countingWrite{}
+	handler := newTestIdempotency().Replay(write)
+
+	first := keyed(handler, "a", `{"n":1}`, "192.0.2.1:1234")
+	again := keyed(handler, "a", `{"n":1}`, "192.0.2.1:5678")
+	if write.calls.Load() != 1 {
+		t.Fatalf("write ran %d times for one key, want 1", write.calls.Load())
+	}
+	if again.Code != first.Code || again.Body.String() != first.Body.String() || again.Header().Get(HeaderIdempotentReplayed) != "true" {
+		t.Errorf("repeat = %d %q replayed %q, want the first response %d %q replayed", again.Code, again.Body.String(), again.Header().Get(HeaderIdempotentReplayed), first.Code, first.Body.String())
+	}
+
+	// Keys belong to a caller, and requests without one always run.
+	keyed(handler, "a", `{"n":1}`, "192.0.2.2:1234")
+	keyed(handler, "", `{"n":1}`, "192.0.2.1:1234")
+	keyed(handler, "", `{"n":1}`, "192.0.2.1:1234")
+	if write.calls.Load() != 4 {
+		t.Errorf("write ran %d times, want once more for the other caller and for each unkeyed request", write.calls.Load())
+	}
+
+	if w := keyed(handler, "a", `{"n":2}`, "192.0.2.1:1234"); w.Code != http.StatusBadRequest {
+		t.Errorf("status of a key reused with another body = %d, want 400", w.Code)
+	}
+}
+
+func TestIdempotencyRunsWritesAgainAfterServerErrors(t *testing.T) {
+	write := &countingWrite{}
+	write.status.Store(http.StatusServiceUnavailable)
+	handler := newTestIdempotency().Replay(write)
+
+	keyed(handler, "a", `{}`, "192.0.2.1:1234")
+	write.status.Store(0)
+	if w := keyed(handler, "a", `{}`, "192.0.2.1:1234"); w.Code != http.StatusCreated || write.calls.Load() != 2 {
+		t.Errorf("retry after a 503 = %d after %d calls, want 201 from a second call", w.Code, write.calls.Load())
+	}
+}
+
+func TestIdempotencyRepeatsWaitForTheFirstWrite(t *testing.T) {
+	write := &countingWrite{release: make(chan struct{})}
+	handler := newTestIdempotency().Replay(write)
+
+	var wg sync.WaitGroup
+	responses := make([]*httptest.ResponseRecorder, 3)
+	for i := range responses {
+		wg.Go(func() {
+			responses[i] = keyed(handler, "a", `{}`, "192.0.2.1:1234")
+		})
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(write.release)
+	wg.Wait()
+
+	if write.calls.Load() != 1 {
+		t.Fatalf("write ran %d times for concurrent repeats, want 1", write.calls.Load())
+	}
+	for _, w := range responses {
+		if w.Code != http.StatusCreated || w.Body.String() != "1" {
+			t.Errorf("concurrent repeat = %d %q, want the response to the one write", w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/auth"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-api/src/internal/rest/service_error"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_handler"
+)
+
+// idleBucketTtl is how long an untouched bucket is kept before it is swept.
+const idleBucketTtl = 10 * time.Minute
+
+// callersPerIp is how many anonymous limits the callers behind one IP may use between them, so
+// that rotating the caller header cannot lift an IP's limit.
+const callersPerIp = 4
+
+// RateLimit is a token bucket configuration: requests refill at RequestsPerMinute and
+// up to Burst requests may be made at once. A zero RequestsPerMinute disables limiting.
+type RateLimit struct {
+	RequestsPerMinute int
+	Burst             int
+}
+
+func (l RateLimit) perSecond() float64 {
+	return float64(l.RequestsPerMinute) / 60
+}
+
+type bucket struct {
+	tokens   float64
+	limit    RateLimit
+	lastSeen time.Time
+}
+
+// RateLimiter limits requests per caller. Callers are identified by their authenticated
+// principal, falling back to the client IP and x-hz-caller header. Authenticated callers get the
+// most generous limit among their scopes, everyone else the anonymous limit, with all callers
+// behind one IP held to callersPerIp anonymous limits together.
+type RateLimiter struct {
+	enabled   bool
+	anonymous RateLimit
+	scopes    map[auth.Scope]RateLimit
+	monitor   *monitor.Monitor
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewRateLimiter(enabled bool, anonymous RateLimit, scopes map[auth.Scope]RateLimit, mon *monitor.Monitor) *RateLimiter {
+	return &RateLimiter{
+		enabled:   enabled,
+		anonymous: anonymous,
+		scopes:    scopes,
+		monitor:   mon,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Limit is middleware that enforces the caller's rate limit and reports it through
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers. It must run after
+// Authorizer.Identify so authenticated callers are keyed by their token.
+func (rl *RateLimiter) Limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !rl.enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, span := rl.monitor.StartSpan(r.Context(), "RateLimiter.Limit")
+		now := time.Now()
+		key, limit, ipKey := rl.identify(r)
+		allowed, remaining, reset := rl.take(key, limit, now)
+		if allowed && ipKey != "" {
+			if ipAllowed, _, ipReset := rl.take(ipKey, rl.ipLimit(), now); !ipAllowed {
+				key, allowed, remaining, reset = ipKey, false, 0, ipReset
+			}
+		}
+		span.End()
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(reset))
+
+		if !allowed {
+			rl.monitor.Logger().WarnArgs(ctx, "Rate limit exceeded for %s.", key)
+			w.Header().Set("Retry-After", strconv.Itoa(reset))
+			hz_handler.Error(w, service_error.RateLimited, "Too many requests, slow down and retry later.")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// identify returns the caller's bucket key and limit and, for unauthenticated callers, the key of
+// the bucket shared by every caller behind their IP.
+func (rl *RateLimiter) identify(r *http.Request) (string, RateLimit, string) {
+	// The anonymous principal holds the admin scope while auth is disabled, so it must not
+	// earn the admin limit.
+	if principal, ok := PrincipalFromContext(r.Context()); ok && !principal.Anonymous {
+		return callerKey(r), rl.limitFor(principal), ""
+	}
+	return callerKey(r), rl.anonymous, "ip:" + clientIp(r)
+}
+
+// ipLimit bounds all unauthenticated callers behind one IP together.
+func (rl *RateLimiter) ipLimit() RateLimit {
+	return RateLimit{
+		RequestsPerMinute: rl.anonymous.RequestsPerMinute * callersPerIp,
+		Burst:             rl.anonymous.Burst * callersPerIp,
+	}
+}
+
+// callerKey identifies the caller of r: authenticated callers by their token, everyone else by
+// IP and x-hz-caller header. The header is chosen by the caller, so it only splits an IP between
+// the applications behind it and never moves a caller to another IP's key.
+func callerKey(r *http.Request) string {
+	if principal, ok := PrincipalFromContext(r.Context()); ok && !principal.Anonymous {
+		return "token:" + principal.Name
+	}
+	return "ip:" + clientIp(r) + " caller:" + r.Header.Get("x-hz-caller")
+}
+
+func clientIp(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+func (rl *RateLimiter) limitFor(principal auth.Principal) RateLimit {
+	limit := rl.anonymous
+	for _, scope := range principal.Scopes {
+		if scoped, ok := rl.scopes[scope]; ok && scoped.RequestsPerMinute > limit.RequestsPerMinute {
+			limit = scoped
+		}
+	}
+	return limit
+}
+
+// take consumes a token from the key's bucket. It returns whether the request is allowed,
+// the whole tokens left and the seconds until the bucket is full again (or, when rejected,
+// until the next token is available).
+func (rl *RateLimiter) take(key string, limit RateLimit, now time.Time) (bool, int, int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.sweep(now)
+
+	b, ok := rl.buckets[key]
+	if !ok || b.limit != limit {
+		b = &bucket{tokens: float64(limit.Burst), limit: limit, lastSeen: now}
+		rl.buckets[key] = b
+	}
+
+	rate := limit.perSecond()
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.lastSeen).Seconds()*rate)
+	b.lastSeen = now
+
+	if rate <= 0 {
+		return true, limit.Burst, 0
+	}
+
+	if b.tokens < 1 {
+		return false, 0, int(math.Ceil((1 - b.tokens) / rate))
+	}
+
+	b.tokens--
+	return true, int(b.tokens), int(math.Ceil((float64(limit.Burst) - b.tokens) / rate))
+}
+
+func (rl *RateLimiter) sweep(now time.Time) {
+	if now.Sub(rl.lastSweep) < idleBucketTtl {
+		return
+	}
+	for key, b := range rl.buckets {
+		if now.Sub(b.lastSeen) > idleBucketTtl {
+			delete(rl.buckets, key)
+		}
+	}
+	rl.lastSweep = now
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/auth"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_logger"
+)
+
+var (
+	anonymousLimit = RateLimit{RequestsPerMinute: 60, Burst: 2}
+	adminLimit     = RateLimit{RequestsPerMinute: 6000, Burst: 100}
+)
+
+func newTestRateLimiter() *RateLimiter {
+	mon := monitor.New(hz_logger.NewZeroLogAdapater(hz_logger.LogLevelError))
+	return NewRateLimiter(true, anonymousLimit, map[auth.Scope]RateLimit{auth.ScopeAdmin: adminLimit}, mon)
+}
+
+// limited sends a request from remoteAddr through the rate limiter, with header values set.
+func limited(rl *RateLimiter, r *http.Request, remoteAddr string, header map[string]string) *httptest.ResponseRecorder {
+	r.RemoteAddr = remoteAddr
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	rl.Limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, r)
+	return w
+}
+
+func TestRateLimiterRefillsOverTime(t *testing.T) {
+	rl := newTestRateLimiter()
+	start := time.Now()
+
+	for i := range anonymousLimit.Burst {
+		if allowed, _, _ := rl.take("ip:192.0.2.1", anonymousLimit, start); !allowed {
+			t.Fatalf("request %d within the burst was rejected", i+1)
+		}
+	}
+	allowed, remaining, reset := rl.take("ip:192.0.2.1", anonymousLimit, start)
+	if allowed || remaining != 0 || reset != 1 {
+		t.Errorf("take past the burst = %v, %d remaining, reset %ds; want rejected, 0 remaining, reset 1s", allowed, remaining, reset)
+	}
+
+	if allowed, _, _ := rl.take("ip:192.0.2.1", anonymousLimit, start.Add(time.Second)); !allowed {
+		t.Error("take a second later was rejected, want one token refilled at 60 requests a minute")
+	}
+
+	// An idle bucket refills to its burst and no further.
+	_, remaining, _ = rl.take("ip:192.0.2.1", anonymousLimit, start.Add(time.Minute))
+	if remaining != anonymousLimit.Burst-1 {
+		t.Errorf("remaining after idling = %d, want %d", remaining, anonymousLimit.Burst-1)
+	}
+}
+
+func TestRateLimiterRejectsWithRetryAfter(t *testing.T) {
+	rl := newTestRateLimiter()
+
+	for range anonymousLimit.Burst {
+		limited(rl, httptest.NewRequest(http.MethodGet, "/", nil), "192.0.2.1:1234", nil)
+	}
+	w := limited(rl, httptest.NewRequest(http.MethodGet, "/", nil), "192.0.2.1:1234", nil)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status past the burst = %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("RateLimit-Remaining = %q, want 0", got)
+	}
+}
+
+func TestRateLimiterKeysUnauthenticatedCallersByIPAndCaller(t *testing.T) {
+	rl := newTestRateLimiter()
+
+	for range anonymousLimit.Burst {
+		limited(rl, httptest.NewRequest(http.MethodGet, "/", nil), "192.0.2.1:1234", map[string]string{"x-hz-caller": "grafana"})
+	}
+	if w := limited(rl, httptest.NewRequest(http.MethodGet, "/", nil), "192.0.2.1:5678", map[string]string{"x-hz-caller": "grafana"}); w.Code != http.StatusTooManyRequests {
+		t.Errorf("status past the caller's burst = %d, want 429", w.Code)
+	}
+	if w := limited(rl, httptest.NewRequest(http.MethodGet, "/", nil), "192.0.2.1:1234", map[string]string{"x-hz-caller": "discord-bot"}); w.Code != http.StatusOK {
+		t.Errorf("status for another caller behind the same IP = %d, want 200", w.Code)
+	}
+
+	// Rotating the caller header must not lift the IP's limit.
+	for i := range anonymousLimit.Burst * callersPerIp {
+		limited(rl, httptest.NewRequest(http.MethodGet, "/", nil), "192.0.2.1:1234", map[string]string{"x-hz-caller": string(rune('a' + i))})
+	}
+	w := limited(rl, httptest.NewRequest(http.MethodGet, "/", nil), "192.0.2.1:1234", map[string]string{"x-hz-caller": "rotated"})
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("status with a rotated caller header past the IP's limit = %d, want 429", w.Code)
+	}
+
+	if w := limited(rl, httptest.NewRequest(http.MethodGet, "/", nil), "192.0.2.2:1234", map[string]string{"x-hz-caller": "grafana"}); w.Code != http.StatusOK {
+		t.Errorf("status for the same caller from another IP = %d, want 200", w.Code)
+	}
+}
+
+func TestRateLimiterLimits(t *testing.T) {
+	rl := newTestRateLimiter()
+
+	admin := httptest.NewRequest(http.MethodGet, "/", nil)
+	admin = admin.WithContext(withPrincipal(admin.Context(), auth.Principal{Name: "grafana", Scopes: []auth.Scope{auth.ScopeAdmin}}))
+	if got := limited(rl, admin, "192.0.2.1:1234", nil).Header().Get("RateLimit-Limit"); got != "100" {
+		t.Errorf("RateLimit-Limit for an admin token = %s, want 100", got)
+	}
+
+	// While auth is disabled every caller is an anonymous admin, which must not lift the limit.
+	anonymous := httptest.NewRequest(http.MethodGet, "/", nil)
+	anonymous = anonymous.WithContext(withPrincipal(anonymous.Context(), auth.Principal{Name: "anonymous", Scopes: []auth.Scope{auth.ScopeAdmin}, Anonymous: true}))
+	if got := limited(rl, anonymous, "192.0.2.1:1234", nil).Header().Get("RateLimit-Limit"); got != "2" {
+		t.Errorf("RateLimit-Limit for the anonymous principal = %s, want 2", got)
+	}
+}
@@ -1,40 +1,197 @@
 package monitor
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync/atomic"
+	"time"
+
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 )
 
+// Outcomes recorded against on-demand worker calls. Unavailable calls were failed fast by the
+// worker's circuit breaker.
+const (
+	WorkerOutcomeSuccess     = "success"
+	WorkerOutcomeTimeout     = "timeout"
+	WorkerOutcomeError       = "error"
+	WorkerOutcomeUnavailable = "unavailable"
+)
+
+// Results of on-demand snapshot requests: a new fetch, a fetch shared with a concurrent
+// request, or a recent snapshot reused within the cooldown.
+const (
+	OnDemandResultFresh  = "fresh"
+	OnDemandResultShared = "shared"
+	OnDemandResultReused = "reused"
+)
+
 // Metrics holds application-level metrics instruments.
-// Add counters, histograms, and gauges here as needed.
+// Instruments are created against the global meter provider, so they export through
+// whichever readers (OTLP push, Prometheus pull) were configured at startup.
 type Metrics struct {
 	meter metric.Meter
 
-	// Example metrics (stubbed for now):
-	// RequestCount   metric.Int64Counter
-	// RequestLatency metric.Float64Histogram
+	snapshotsCreated       metric.Int64Counter
+	deltasCreated          metric.Int64Counter
+	deltaCacheLookups      metric.Int64Counter
+	workerOnDemandLatency  metric.Float64Histogram
+	workerOnDemandTimeouts metric.Int64Counter
+	workerOnDemandRequests metric.Int64Counter
+	authorizationFailures  metric.Int64Counter
+
+	// Running totals backing the cache hit ratio gauge.
+	cacheHits   atomic.Int64
+	cacheMisses atomic.Int64
 }
 
 // NewMetrics creates and registers application metrics.
 func NewMetrics() *Metrics {
 	meter := otel.Meter("hazelmere")
 
-	// Initialize metrics here as needed:
-	// requestCount, _ := meter.Int64Counter("http.request.count",
-	// 	metric.WithDescription("Total number of HTTP requests"),
-	// )
-	// requestLatency, _ := meter.Float64Histogram("http.request.latency",
-	// 	metric.WithDescription("HTTP request latency in milliseconds"),
-	// )
-
-	return &Metrics{
-		meter: meter,
-		// RequestCount:   requestCount,
-		// RequestLatency: requestLatency,
+	// Instrument creation only fails on invalid names or options, which are static here.
+	snapshotsCreated, _ := meter.Int64Counter("hazelmere.snapshots.created",
+		metric.WithDescription("Number of snapshots created, by source"),
+	)
+	deltasCreated, _ := meter.Int64Counter("hazelmere.deltas.created",
+		metric.WithDescription("Number of deltas created"),
+	)
+	deltaCacheLookups, _ := meter.Int64Counter("hazelmere.delta_cache.lookups",
+		metric.WithDescription("Delta cache lookups, by result (hit or miss)"),
+	)
+	workerOnDemandLatency, _ := meter.Float64Histogram("hazelmere.worker.on_demand.latency",
+		metric.WithDescription("Latency of on-demand snapshot generation through the worker"),
+		metric.WithUnit("ms"),
+	)
+	workerOnDemandTimeouts, _ := meter.Int64Counter("hazelmere.worker.on_demand.timeouts",
+		metric.WithDescription("Number of on-demand snapshot requests that timed out on the OSRS hiscores"),
+	)
+	workerOnDemandRequests, _ := meter.Int64Counter("hazelmere.worker.on_demand.requests",
+		metric.WithDescription("On-demand snapshot requests, by result (fresh, shared or reused)"),
+	)
+	authorizationFailures, _ := meter.Int64Counter("hazelmere.authorization.failures",
+		metric.WithDescription("Number of rejected requests, by token and reason"),
+	)
+
+	m := &Metrics{
+		meter:                  meter,
+		snapshotsCreated:       snapshotsCreated,
+		deltasCreated:          deltasCreated,
+		deltaCacheLookups:      deltaCacheLookups,
+		workerOnDemandLatency:  workerOnDemandLatency,
+		workerOnDemandTimeouts: workerOnDemandTimeouts,
+		workerOnDemandRequests: workerOnDemandRequests,
+		authorizationFailures:  authorizationFailures,
 	}
+
+	_, _ = meter.Float64ObservableGauge("hazelmere.delta_cache.hit_ratio",
+		metric.WithDescription("Ratio of delta cache hits to total lookups since startup"),
+		metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+			hits := m.cacheHits.Load()
+			total := hits + m.cacheMisses.Load()
+			if total > 0 {
+				o.Observe(float64(hits) / float64(total))
+			}
+			return nil
+		}),
+	)
+
+	return m
 }
 
 // Meter returns the underlying OpenTelemetry meter for creating custom metrics.
 func (m *Metrics) Meter() metric.Meter {
 	return m.meter
 }
+
+// RecordSnapshotCreated counts a created snapshot against its source.
+func (m *Metrics) RecordSnapshotCreated(ctx context.Context, source string) {
+	if source == "" {
+		source = "unknown"
+	}
+	m.snapshotsCreated.Add(ctx, 1, metric.WithAttributes(attribute.String("source", source)))
+}
+
+// RecordDeltaCreated counts a created delta.
+func (m *Metrics) RecordDeltaCreated(ctx context.Context) {
+	m.deltasCreated.Add(ctx, 1)
+}
+
+// RecordDeltaCacheLookup counts a delta cache lookup as a hit or a miss.
+func (m *Metrics) RecordDeltaCacheLookup(ctx context.Context, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+		m.cacheHits.Add(1)
+	} else {
+		m.cacheMisses.Add(1)
+	}
+	m.deltaCacheLookups.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result)))
+}
+
+// RecordWorkerOnDemand records the latency and outcome of an on-demand worker call.
+func (m *Metrics) RecordWorkerOnDemand(ctx context.Context, duration time.Duration, outcome string) {
+	attrs := metric.WithAttributes(attribute.String("outcome", outcome))
+	m.workerOnDemandLatency.Record(ctx, float64(duration.Microseconds())/1000, attrs)
+	if outcome == WorkerOutcomeTimeout {
+		m.workerOnDemandTimeouts.Add(ctx, 1)
+	}
+}
+
+// RecordWorkerOnDemandRequest counts an on-demand snapshot request by how it was served.
+func (m *Metrics) RecordWorkerOnDemandRequest(ctx context.Context, result string) {
+	m.workerOnDemandRequests.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result)))
+}
+
+// Token labels for authorization failures that have no known token name. Unresolved tokens
+// share one label so callers cannot create a metric series per token they make up.
+const (
+	TokenLabelNone    = "none"
+	TokenLabelUnknown = "unknown"
+)
+
+// RecordAuthorizationFailure counts a rejected request. The token label must be the token's name
+// when known, otherwise TokenLabelNone or TokenLabelUnknown; never the secret or anything
+// derived from it.
+func (m *Metrics) RecordAuthorizationFailure(ctx context.Context, tokenLabel string, reason string) {
+	m.authorizationFailures.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("token", tokenLabel),
+		attribute.String("reason", reason),
+	))
+}
+
+// RegisterDeltaCacheSize exposes the delta cache size as observable gauges.
+func (m *Metrics) RegisterDeltaCacheSize(users func() int, entries func() int) error {
+	_, err := m.meter.Int64ObservableGauge("hazelmere.delta_cache.users",
+		metric.WithDescription("Number of users held in the delta cache"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(int64(users()))
+			return nil
+		}),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.meter.Int64ObservableGauge("hazelmere.delta_cache.entries",
+		metric.WithDescription("Number of daily aggregated deltas held in the delta cache"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(int64(entries()))
+			return nil
+		}),
+	)
+	return err
+}
+
+// TokenFingerprint returns a short, non-reversible identifier for a token, to tell unresolved
+// tokens apart in logs.
+func TokenFingerprint(token string) string {
+	if token == "" {
+		return "none"
+	}
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:4])
+}
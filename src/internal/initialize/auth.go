@@ -0,0 +1,37 @@
+package initialize
+
+import (
+	"fmt"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/auth"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/middleware"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_config"
+)
+
+// InitAuthorizer builds the authorizer from the named token definitions under auth.tokens.
+// Each name listed in auth.tokenNames must have a matching auth.tokens.<name> entry with a
+// token and the scopes it grants. Tokens issued at runtime are resolved through store.
+func InitAuthorizer(config *hz_config.Config, store middleware.TokenStore, mon *monitor.Monitor) *middleware.Authorizer {
+	names := config.StringSliceValueOrPanic("auth.tokenNames")
+	tokens := make([]middleware.TokenDefinition, 0, len(names))
+	for _, name := range names {
+		prefix := fmt.Sprintf("auth.tokens.%s", name)
+
+		scopes := make([]auth.Scope, 0)
+		for _, value := range config.StringSliceValueOrPanic(prefix + ".scopes") {
+			scope, ok := auth.ScopeFromValue(value)
+			if !ok {
+				panic(fmt.Sprintf("unknown scope %q for token %s", value, name))
+			}
+			scopes = append(scopes, scope)
+		}
+
+		tokens = append(tokens, middleware.TokenDefinition{
+			Name:   name,
+			Token:  config.ValueOrPanic(prefix + ".token"),
+			Scopes: scopes,
+		})
+	}
+	return middleware.NewAuthorizer(config.BoolValueOrPanic("auth.enabled"), tokens, store, mon)
+}
@@ -0,0 +1,28 @@
+package initialize
+
+import (
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/worker"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_config"
+)
+
+// JobRunnerConfig reads the job runner settings under jobs. jobs.webhook.secret is optional;
+// webhooks are sent unsigned without it. jobs.webhook.allowPrivateTargets should only be set in
+// development.
+func JobRunnerConfig(config *hz_config.Config) worker.JobRunnerConfig {
+	secret, _ := config.Value("jobs.webhook.secret")
+	return worker.JobRunnerConfig{
+		Workers:      config.IntValueOrPanic("jobs.workers"),
+		PollInterval: time.Duration(config.IntValueOrPanic("jobs.pollIntervalMs")) * time.Millisecond,
+		StaleAfter:   time.Duration(config.IntValueOrPanic("jobs.staleAfterMs")) * time.Millisecond,
+		MaxAttempts:  config.IntValueOrPanic("jobs.maxAttempts"),
+		Webhook: worker.WebhookConfig{
+			Timeout:             time.Duration(config.IntValueOrPanic("jobs.webhook.timeoutMs")) * time.Millisecond,
+			Retries:             config.IntValueOrPanic("jobs.webhook.retries"),
+			RetryWait:           time.Duration(config.IntValueOrPanic("jobs.webhook.retryWaitMs")) * time.Millisecond,
+			Secret:              secret,
+			AllowPrivateTargets: config.BoolValueOrPanic("jobs.webhook.allowPrivateTargets"),
+		},
+	}
+}
@@ -2,6 +2,9 @@ package initialize
 
 import (
 	"context"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_config"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
@@ -20,3 +23,19 @@ func MongoCleanup(ctx context.Context, client *mongo.Client) {
 		panic(err)
 	}
 }
+
+// MongoFactory builds the collection factory from the names under mongo.database.
+func MongoFactory(config *hz_config.Config, client *mongo.Client) *database.MongoFactory {
+	return database.NewMongoFactory(client, database.MongoFactoryConfig{
+		DatabaseName:            config.ValueOrPanic("mongo.database.name"),
+		SnapshotCollectionName:  config.ValueOrPanic("mongo.database.collections.snapshot"),
+		UserCollectionName:      config.ValueOrPanic("mongo.database.collections.user"),
+		DeltaCollectionName:     config.ValueOrPanic("mongo.database.collections.delta"),
+		TokenCollectionName:     config.ValueOrPanic("mongo.database.collections.token"),
+		AuditCollectionName:     config.ValueOrPanic("mongo.database.collections.audit"),
+		JobCollectionName:       config.ValueOrPanic("mongo.database.collections.job"),
+		LeaseCollectionName:     config.ValueOrPanic("mongo.database.collections.lease"),
+		GroupCollectionName:     config.ValueOrPanic("mongo.database.collections.group"),
+		MigrationCollectionName: config.ValueOrPanic("mongo.database.collections.migration"),
+	})
+}
@@ -5,9 +5,11 @@ import (
 	"strings"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
@@ -22,17 +24,32 @@ type OtelConfig struct {
 	ServiceNamespace string
 	Endpoint         string
 	AuthHeader       string
+
+	// PrometheusRegistry, when set, registers a pull-based Prometheus exporter for metrics.
+	// It works independently of Enabled so metrics are available without a collector.
+	PrometheusRegistry *prometheus.Registry
 }
 
 // InitOtel initializes OpenTelemetry tracing and metrics.
 // When disabled, it uses noop providers. When enabled, it exports to the configured endpoint.
+// A Prometheus registry in the config adds a pull exporter for metrics either way.
 // Returns a shutdown function that should be deferred.
 func InitOtel(ctx context.Context, cfg OtelConfig) (func(context.Context) error, error) {
-	if !cfg.Enabled {
+	if !cfg.Enabled && cfg.PrometheusRegistry == nil {
 		// Disabled: no-op, just return empty shutdown
 		return func(context.Context) error { return nil }, nil
 	}
 
+	if !cfg.Enabled {
+		// Prometheus only: metrics without traces
+		meterProvider, err := newPrometheusMeterProvider(cfg.PrometheusRegistry)
+		if err != nil {
+			return nil, err
+		}
+		otel.SetMeterProvider(meterProvider)
+		return meterProvider.Shutdown, nil
+	}
+
 	// Set up resource with service info
 	res, err := resource.New(ctx,
 		resource.WithAttributes(
@@ -77,12 +94,21 @@ func InitOtel(ctx context.Context, cfg OtelConfig) (func(context.Context) error,
 		return nil, err
 	}
 
-	meterProvider := metric.NewMeterProvider(
+	meterOpts := []metric.Option{
 		metric.WithReader(metric.NewPeriodicReader(metricExporter,
 			metric.WithInterval(15*time.Second),
 		)),
 		metric.WithResource(res),
-	)
+	}
+	if cfg.PrometheusRegistry != nil {
+		promExporter, err := otelprometheus.New(otelprometheus.WithRegisterer(cfg.PrometheusRegistry))
+		if err != nil {
+			return nil, err
+		}
+		meterOpts = append(meterOpts, metric.WithReader(promExporter))
+	}
+
+	meterProvider := metric.NewMeterProvider(meterOpts...)
 	otel.SetMeterProvider(meterProvider)
 
 	// Set up propagation
@@ -108,3 +134,11 @@ func InitOtel(ctx context.Context, cfg OtelConfig) (func(context.Context) error,
 
 	return shutdown, nil
 }
+
+func newPrometheusMeterProvider(registry *prometheus.Registry) (*metric.MeterProvider, error) {
+	exporter, err := otelprometheus.New(otelprometheus.WithRegisterer(registry))
+	if err != nil {
+		return nil, err
+	}
+	return metric.NewMeterProvider(metric.WithReader(exporter)), nil
+}
@@ -0,0 +1,31 @@
+package initialize
+
+import (
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/auth"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/middleware"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_config"
+)
+
+// InitRateLimiter builds the rate limiter from rateLimit.anonymous and the optional per-scope
+// limits under rateLimit.scopes.<scope>.
+func InitRateLimiter(config *hz_config.Config, mon *monitor.Monitor) *middleware.RateLimiter {
+	anonymous := middleware.RateLimit{
+		RequestsPerMinute: config.IntValueOrPanic("rateLimit.anonymous.requestsPerMinute"),
+		Burst:             config.IntValueOrPanic("rateLimit.anonymous.burst"),
+	}
+
+	scopes := make(map[auth.Scope]middleware.RateLimit)
+	for _, scope := range auth.AllScopes {
+		prefix := "rateLimit.scopes." + string(scope)
+		if _, err := config.Value(prefix + ".requestsPerMinute"); err != nil {
+			continue
+		}
+		scopes[scope] = middleware.RateLimit{
+			RequestsPerMinute: config.IntValueOrPanic(prefix + ".requestsPerMinute"),
+			Burst:             config.IntValueOrPanic(prefix + ".burst"),
+		}
+	}
+
+	return middleware.NewRateLimiter(config.BoolValueOrPanic("rateLimit.enabled"), anonymous, scopes, mon)
+}
@@ -0,0 +1,28 @@
+package initialize
+
+import (
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/scheduler"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_config"
+)
+
+// SchedulerConfig reads the tracking scheduler settings under scheduler.
+func SchedulerConfig(config *hz_config.Config) scheduler.Config {
+	ms := func(key string) time.Duration {
+		return time.Duration(config.IntValueOrPanic(key)) * time.Millisecond
+	}
+	return scheduler.Config{
+		Tick:        ms("scheduler.tickMs"),
+		LeaseTtl:    ms("scheduler.leaseTtlMs"),
+		Concurrency: config.IntValueOrPanic("scheduler.concurrency"),
+		Jitter:      float64(config.IntValueOrPanic("scheduler.jitterPercent")) / 100,
+		Intervals: scheduler.Intervals{
+			Active:        ms("scheduler.intervals.activeMs"),
+			ActiveWithin:  ms("scheduler.intervals.activeWithinMs"),
+			Default:       ms("scheduler.intervals.defaultMs"),
+			Inactive:      ms("scheduler.intervals.inactiveMs"),
+			InactiveAfter: ms("scheduler.intervals.inactiveAfterMs"),
+		},
+	}
+}
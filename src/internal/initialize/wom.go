@@ -0,0 +1,22 @@
+package initialize
+
+import (
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/dependency/wom"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_config"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_logger"
+)
+
+func InitWomClient(logger hz_logger.Logger, config *hz_config.Config) *wom.Client {
+	return wom.NewClient(logger, wom.Config{
+		Host:              config.ValueOrPanic("clients.wom.host"),
+		Timeout:           time.Duration(config.IntValueOrPanic("clients.wom.timeout")) * time.Millisecond,
+		RequestsPerMinute: config.IntValueOrPanic("clients.wom.requestsPerMinute"),
+		Retry: wom.RetryPolicy{
+			MaxRetries: config.IntValueOrPanic("clients.wom.retries"),
+			BaseWait:   time.Duration(config.IntValueOrPanic("clients.wom.retryWaitMs")) * time.Millisecond,
+			MaxWait:    time.Duration(config.IntValueOrPanic("clients.wom.retryMaxWaitMs")) * time.Millisecond,
+		},
+	})
+}
@@ -2,13 +2,19 @@ package initialize
 
 import (
 	"context"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/worker"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/breaker"
 	"github.com/ctfloyd/hazelmere-commons/pkg/hz_client"
 	"github.com/ctfloyd/hazelmere-commons/pkg/hz_config"
 	"github.com/ctfloyd/hazelmere-commons/pkg/hz_logger"
 	"github.com/ctfloyd/hazelmere-worker/src/pkg/worker_client"
 )
 
-func InitWorkerClient(logger hz_logger.Logger, config *hz_config.Config) *worker_client.HazelmereWorker {
+// InitWorkerClient creates the hazelmere-worker client guarded by a circuit breaker configured
+// under clients.worker.breaker. The breaker is returned so its state can be health checked.
+func InitWorkerClient(logger hz_logger.Logger, config *hz_config.Config) (worker.WorkerClient, *breaker.Breaker) {
 	clientConfig := hz_client.HttpClientConfig{
 		Host:           config.ValueOrPanic("clients.worker.host"),
 		TimeoutMs:      config.IntValueOrPanic("clients.worker.timeout"),
@@ -17,5 +23,15 @@ func InitWorkerClient(logger hz_logger.Logger, config *hz_config.Config) *worker
 		RetryMaxWaitMs: config.IntValueOrPanic("clients.worker.retryMaxWaitMs"),
 	}
 	httpClient := hz_client.NewHttpClient(clientConfig, func(msg string) { logger.Error(context.TODO(), msg) })
-	return worker_client.NewHazelmereWorker(httpClient)
+
+	workerBreaker := breaker.New(breaker.Config{
+		FailureThreshold: config.IntValueOrPanic("clients.worker.breaker.failureThreshold"),
+		OpenFor:          time.Duration(config.IntValueOrPanic("clients.worker.breaker.openMs")) * time.Millisecond,
+		OnStateChange: func(from breaker.State, to breaker.State) {
+			logger.WarnArgs(context.TODO(), "Worker circuit breaker changed from %s to %s", from, to)
+		},
+	})
+
+	client := worker.NewWorkerClient(worker_client.NewHazelmereWorker(httpClient))
+	return worker.NewBreakerWorkerClient(client, workerBreaker), workerBreaker
 }
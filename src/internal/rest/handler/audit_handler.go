@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/audit"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/auth"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/middleware"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-api/src/internal/rest/service_error"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_handler"
+	"github.com/go-chi/chi/v5"
+	chiWare "github.com/go-chi/chi/v5/middleware"
+)
+
+type AuditHandler struct {
+	monitor *monitor.Monitor
+	service audit.AuditService
+}
+
+func NewAuditHandler(mon *monitor.Monitor, service audit.AuditService) *AuditHandler {
+	return &AuditHandler{mon, service}
+}
+
+func (ah *AuditHandler) RegisterRoutes(mux *chi.Mux, version ApiVersion, authorizer *middleware.Authorizer) {
+	if version == ApiVersionV1 {
+		mux.Group(func(r chi.Router) {
+			r.Use(chiWare.Timeout(5000 * time.Millisecond))
+			r.Use(authorizer.Require(auth.ScopeAdmin))
+			r.Get("/v1/admin/audit", ah.GetAuditRecords)
+		})
+	}
+}
+
+// GetAuditRecords returns the most recent audit records, newest first. Supported query parameters
+// are actor, entityType, entityId, start and end (unix millis) and limit.
+func (ah *AuditHandler) GetAuditRecords(w http.ResponseWriter, r *http.Request) {
+	ctx, span := ah.monitor.StartSpan(r.Context(), "AuditHandler.GetAuditRecords")
+	defer span.End()
+
+	params := r.URL.Query()
+	query := audit.AuditQuery{
+		Actor:      params.Get("actor"),
+		EntityType: audit.EntityType(params.Get("entityType")),
+		EntityId:   params.Get("entityId"),
+	}
+
+	var err error
+	if query.Start, err = parseMillisParam(params.Get("start")); err != nil {
+		hz_handler.Error(w, service_error.BadRequest, "Could not convert start to a number.")
+		return
+	}
+	if query.End, err = parseMillisParam(params.Get("end")); err != nil {
+		hz_handler.Error(w, service_error.BadRequest, "Could not convert end to a number.")
+		return
+	}
+	if limit := params.Get("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil {
+			hz_handler.Error(w, service_error.BadRequest, "Could not convert limit to a number.")
+			return
+		}
+	}
+
+	ah.monitor.Logger().InfoArgs(ctx, "Querying audit records: %+v", query)
+
+	records, err := ah.service.QueryRecords(ctx, query)
+	if err != nil {
+		if errors.Is(err, audit.ErrInvalidAuditQuery) {
+			ah.monitor.Logger().WarnArgs(ctx, "Invalid audit query: %+v", err)
+			hz_handler.Error(w, service_error.BadRequest, err.Error())
+			return
+		}
+		ah.monitor.Logger().ErrorArgs(ctx, "An unexpected error occurred while querying audit records: %+v", err)
+		hz_handler.Error(w, service_error.Internal, "An unexpected error occurred while performing the audit operation.")
+		return
+	}
+
+	response := api.GetAuditRecordsResponse{
+		Records: audit.AuditRecord{}.ManyToAPI(records),
+	}
+
+	hz_handler.Ok(w, response)
+}
+
+func parseMillisParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	millis, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(millis), nil
+}
+
+// snapshotAuditView is the part of a snapshot recorded in audit diffs. The full set of
+// skills, bosses and activities is already kept in the snapshot collection.
+type snapshotAuditView struct {
+	Id        string    `json:"id"`
+	UserId    string    `json:"userId"`
+	Timestamp time.Time `json:"timestamp"`
+	Source    string    `json:"source,omitempty"`
+}
+
+func newSnapshotAuditView(s api.HiscoreSnapshot) snapshotAuditView {
+	return snapshotAuditView{Id: s.Id, UserId: s.UserId, Timestamp: s.Timestamp, Source: s.Source}
+}
+
+// recordAudit writes an audit record for a completed write. The write has already happened,
+// so failures are logged rather than surfaced to the caller.
+func recordAudit(ctx context.Context, mon *monitor.Monitor, service audit.AuditService, r *http.Request, action audit.Action, entityType audit.EntityType, entityId string, before any, after any) {
+	changes, err := audit.Diff(before, after)
+	if err != nil {
+		mon.Logger().ErrorArgs(ctx, "Failed to diff %s %s for audit: %v", entityType, entityId, err)
+	}
+
+	actor := "anonymous"
+	if principal, ok := middleware.PrincipalFromContext(ctx); ok {
+		actor = principal.Name
+	}
+
+	record := audit.AuditRecord{
+		Actor:      actor,
+		Caller:     r.Header.Get("x-hz-caller"),
+		RequestId:  chiWare.GetReqID(ctx),
+		Action:     action,
+		EntityType: entityType,
+		EntityId:   entityId,
+		Changes:    changes,
+	}
+
+	if err := service.Record(ctx, record); err != nil {
+		mon.Logger().ErrorArgs(ctx, "Failed to record audit for %s %s: %v", action, entityId, err)
+	}
+}
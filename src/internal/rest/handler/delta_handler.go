@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/ctfloyd/hazelmere-api/src/internal/core/delta"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/auth"
 	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/middleware"
 	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
 	"github.com/ctfloyd/hazelmere-api/src/internal/rest/service_error"
@@ -29,6 +30,7 @@ func (dh *DeltaHandler) RegisterRoutes(mux *chi.Mux, version ApiVersion, authori
 	if version == ApiVersionV1 {
 		mux.Group(func(r chi.Router) {
 			r.Use(chiWare.Timeout(5000 * time.Millisecond))
+			r.Use(authorizer.Require(auth.ScopeSnapshotRead))
 			r.Get(fmt.Sprintf("/v1/delta/{userId:%s}/latest", hz_handler.RegexUuid), dh.GetLatestDelta)
 			r.Post("/v1/delta/interval", dh.GetDeltaInterval)
 			r.Post("/v1/delta/summary", dh.GetDeltaSummary)
@@ -0,0 +1,314 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/audit"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/group"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/auth"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/middleware"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-api/src/internal/rest/service_error"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_handler"
+	"github.com/go-chi/chi/v5"
+	chiWare "github.com/go-chi/chi/v5/middleware"
+)
+
+type GroupHandler struct {
+	monitor *monitor.Monitor
+	service group.GroupService
+	audit   audit.AuditService
+}
+
+func NewGroupHandler(mon *monitor.Monitor, service group.GroupService, auditService audit.AuditService) *GroupHandler {
+	return &GroupHandler{mon, service, auditService}
+}
+
+func (gh *GroupHandler) RegisterRoutes(mux *chi.Mux, version ApiVersion, authorizer *middleware.Authorizer) {
+	if version == ApiVersionV1 {
+		mux.Group(func(r chi.Router) {
+			r.Use(chiWare.Timeout(5000 * time.Millisecond))
+			r.Group(func(secure chi.Router) {
+				secure.Use(authorizer.Require(auth.ScopeUserRead))
+				secure.Get("/v1/group", gh.GetAllGroups)
+				secure.Get(fmt.Sprintf("/v1/group/{id:%s}", hz_handler.RegexUuid), gh.GetGroupById)
+				secure.Post("/v1/group/gains", gh.GetGroupGains)
+			})
+			r.Group(func(secure chi.Router) {
+				secure.Use(authorizer.Require(auth.ScopeUserWrite))
+				secure.Post("/v1/group", gh.CreateGroup)
+				secure.Put(fmt.Sprintf("/v1/group/{id:%s}", hz_handler.RegexUuid), gh.UpdateGroup)
+				secure.Delete(fmt.Sprintf("/v1/group/{id:%s}", hz_handler.RegexUuid), gh.DeleteGroup)
+				secure.Post(fmt.Sprintf("/v1/group/{id:%s}/member", hz_handler.RegexUuid), gh.AddMember)
+				secure.Put(fmt.Sprintf("/v1/group/{id:%s}/member/{userId:%s}", hz_handler.RegexUuid, hz_handler.RegexUuid), gh.UpdateMember)
+				secure.Delete(fmt.Sprintf("/v1/group/{id:%s}/member/{userId:%s}", hz_handler.RegexUuid, hz_handler.RegexUuid), gh.RemoveMember)
+			})
+		})
+	}
+}
+
+func (gh *GroupHandler) GetAllGroups(w http.ResponseWriter, r *http.Request) {
+	ctx, span := gh.monitor.StartSpan(r.Context(), "GroupHandler.GetAllGroups")
+	defer span.End()
+
+	gh.monitor.Logger().Info(ctx, "Getting all groups")
+
+	groups, err := gh.service.GetAllGroups(ctx)
+	if err != nil {
+		gh.writeError(ctx, w, err)
+		return
+	}
+
+	response := api.GetAllGroupsResponse{
+		Groups: group.Group{}.ManyToAPI(groups),
+	}
+
+	hz_handler.Ok(w, response)
+}
+
+func (gh *GroupHandler) GetGroupById(w http.ResponseWriter, r *http.Request) {
+	ctx, span := gh.monitor.StartSpan(r.Context(), "GroupHandler.GetGroupById")
+	defer span.End()
+
+	id := chi.URLParam(r, "id")
+	gh.monitor.Logger().InfoArgs(ctx, "Getting group by id: %s", id)
+
+	g, err := gh.service.GetGroupById(ctx, id)
+	if err != nil {
+		gh.writeError(ctx, w, err)
+		return
+	}
+
+	response := api.GetGroupResponse{
+		Group: g.ToAPI(),
+	}
+
+	hz_handler.Ok(w, response)
+}
+
+func (gh *GroupHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	ctx, span := gh.monitor.StartSpan(r.Context(), "GroupHandler.CreateGroup")
+	defer span.End()
+
+	var createGroupRequest api.CreateGroupRequest
+	if ok := hz_handler.ReadBody(w, r, &createGroupRequest); !ok {
+		return
+	}
+
+	gh.monitor.Logger().InfoArgs(ctx, "Creating group: %s", createGroupRequest.Name)
+
+	g, err := gh.service.CreateGroup(ctx, group.Group{Name: createGroupRequest.Name})
+	if err != nil {
+		gh.writeError(ctx, w, err)
+		return
+	}
+
+	recordAudit(ctx, gh.monitor, gh.audit, r, audit.ActionGroupCreate, audit.EntityTypeGroup, g.Id, nil, g.ToAPI())
+
+	response := api.CreateGroupResponse{
+		Group: g.ToAPI(),
+	}
+
+	hz_handler.Ok(w, response)
+}
+
+func (gh *GroupHandler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
+	ctx, span := gh.monitor.StartSpan(r.Context(), "GroupHandler.UpdateGroup")
+	defer span.End()
+
+	id := chi.URLParam(r, "id")
+
+	var updateGroupRequest api.UpdateGroupRequest
+	if ok := hz_handler.ReadBody(w, r, &updateGroupRequest); !ok {
+		return
+	}
+
+	gh.monitor.Logger().InfoArgs(ctx, "Updating group %s: %s", id, updateGroupRequest.Name)
+
+	before := gh.snapshot(ctx, id)
+	g, err := gh.service.UpdateGroup(ctx, id, updateGroupRequest.Name)
+	if err != nil {
+		gh.writeError(ctx, w, err)
+		return
+	}
+
+	recordAudit(ctx, gh.monitor, gh.audit, r, audit.ActionGroupUpdate, audit.EntityTypeGroup, g.Id, before, g.ToAPI())
+
+	response := api.UpdateGroupResponse{
+		Group: g.ToAPI(),
+	}
+
+	hz_handler.Ok(w, response)
+}
+
+func (gh *GroupHandler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	ctx, span := gh.monitor.StartSpan(r.Context(), "GroupHandler.DeleteGroup")
+	defer span.End()
+
+	id := chi.URLParam(r, "id")
+	gh.monitor.Logger().InfoArgs(ctx, "Deleting group: %s", id)
+
+	g, err := gh.service.DeleteGroup(ctx, id)
+	if err != nil {
+		gh.writeError(ctx, w, err)
+		return
+	}
+
+	recordAudit(ctx, gh.monitor, gh.audit, r, audit.ActionGroupDelete, audit.EntityTypeGroup, g.Id, g.ToAPI(), nil)
+
+	response := api.DeleteGroupResponse{
+		Group: g.ToAPI(),
+	}
+
+	hz_handler.Ok(w, response)
+}
+
+func (gh *GroupHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	ctx, span := gh.monitor.StartSpan(r.Context(), "GroupHandler.AddMember")
+	defer span.End()
+
+	id := chi.URLParam(r, "id")
+
+	var addMemberRequest api.AddGroupMemberRequest
+	if ok := hz_handler.ReadBody(w, r, &addMemberRequest); !ok {
+		return
+	}
+
+	joinedAt := time.Now()
+	if addMemberRequest.JoinedAt != nil {
+		joinedAt = *addMemberRequest.JoinedAt
+	}
+
+	gh.monitor.Logger().InfoArgs(ctx, "Adding user %s to group %s", addMemberRequest.UserId, id)
+
+	before := gh.snapshot(ctx, id)
+	g, err := gh.service.AddMember(ctx, id, addMemberRequest.UserId, addMemberRequest.Role, joinedAt)
+	if err != nil {
+		gh.writeError(ctx, w, err)
+		return
+	}
+
+	recordAudit(ctx, gh.monitor, gh.audit, r, audit.ActionGroupMemberAdd, audit.EntityTypeGroup, g.Id, before, g.ToAPI())
+
+	response := api.AddGroupMemberResponse{
+		Group: g.ToAPI(),
+	}
+
+	hz_handler.Ok(w, response)
+}
+
+func (gh *GroupHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	ctx, span := gh.monitor.StartSpan(r.Context(), "GroupHandler.UpdateMember")
+	defer span.End()
+
+	id := chi.URLParam(r, "id")
+	userId := chi.URLParam(r, "userId")
+
+	var updateMemberRequest api.UpdateGroupMemberRequest
+	if ok := hz_handler.ReadBody(w, r, &updateMemberRequest); !ok {
+		return
+	}
+
+	gh.monitor.Logger().InfoArgs(ctx, "Updating user %s in group %s to role %s", userId, id, updateMemberRequest.Role)
+
+	before := gh.snapshot(ctx, id)
+	g, err := gh.service.UpdateMemberRole(ctx, id, userId, updateMemberRequest.Role)
+	if err != nil {
+		gh.writeError(ctx, w, err)
+		return
+	}
+
+	recordAudit(ctx, gh.monitor, gh.audit, r, audit.ActionGroupMemberUpdate, audit.EntityTypeGroup, g.Id, before, g.ToAPI())
+
+	response := api.UpdateGroupMemberResponse{
+		Group: g.ToAPI(),
+	}
+
+	hz_handler.Ok(w, response)
+}
+
+// RemoveMember ends the membership of a user, now or at the leftAt query parameter (unix millis)
+// to record a departure after the fact.
+func (gh *GroupHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	ctx, span := gh.monitor.StartSpan(r.Context(), "GroupHandler.RemoveMember")
+	defer span.End()
+
+	id := chi.URLParam(r, "id")
+	userId := chi.URLParam(r, "userId")
+
+	leftAt, err := parseMillisParam(r.URL.Query().Get("leftAt"))
+	if err != nil {
+		hz_handler.Error(w, service_error.BadRequest, "leftAt must be a unix timestamp in milliseconds.")
+		return
+	}
+	if leftAt.IsZero() {
+		leftAt = time.Now()
+	}
+
+	gh.monitor.Logger().InfoArgs(ctx, "Removing user %s from group %s", userId, id)
+
+	before := gh.snapshot(ctx, id)
+	g, err := gh.service.RemoveMember(ctx, id, userId, leftAt)
+	if err != nil {
+		gh.writeError(ctx, w, err)
+		return
+	}
+
+	recordAudit(ctx, gh.monitor, gh.audit, r, audit.ActionGroupMemberRemove, audit.EntityTypeGroup, g.Id, before, g.ToAPI())
+
+	response := api.RemoveGroupMemberResponse{
+		Group: g.ToAPI(),
+	}
+
+	hz_handler.Ok(w, response)
+}
+
+func (gh *GroupHandler) GetGroupGains(w http.ResponseWriter, r *http.Request) {
+	ctx, span := gh.monitor.StartSpan(r.Context(), "GroupHandler.GetGroupGains")
+	defer span.End()
+
+	var gainsRequest api.GetGroupGainsRequest
+	if ok := hz_handler.ReadBody(w, r, &gainsRequest); !ok {
+		return
+	}
+
+	gh.monitor.Logger().InfoArgs(ctx, "Getting group gains: %v", gainsRequest)
+
+	gains, err := gh.service.GetGroupGains(ctx, gainsRequest.GroupId, gainsRequest.StartTime, gainsRequest.EndTime, gainsRequest.Limit)
+	if err != nil {
+		gh.writeError(ctx, w, err)
+		return
+	}
+
+	hz_handler.Ok(w, gains.ToAPI())
+}
+
+// snapshot captures a group for the audit diff of a write. A missing group is reported by the
+// write itself.
+func (gh *GroupHandler) snapshot(ctx context.Context, id string) any {
+	if existing, err := gh.service.GetGroupById(ctx, id); err == nil {
+		return existing.ToAPI()
+	}
+	return nil
+}
+
+func (gh *GroupHandler) writeError(ctx context.Context, w http.ResponseWriter, err error) {
+	if errors.Is(err, group.ErrGroupNotFound) {
+		gh.monitor.Logger().Warn(ctx, "Group not found.")
+		hz_handler.Error(w, service_error.GroupNotFound, "Group not found.")
+	} else if errors.Is(err, group.ErrGroupValidation) {
+		gh.monitor.Logger().WarnArgs(ctx, "Invalid group request: %+v", err)
+		hz_handler.Error(w, service_error.InvalidGroup, err.Error())
+	} else if errors.Is(err, group.ErrGroupNameTaken) {
+		gh.monitor.Logger().Warn(ctx, "Group name taken.")
+		hz_handler.Error(w, service_error.GroupNameTaken, "The name is already used by another group.")
+	} else {
+		gh.monitor.Logger().ErrorArgs(ctx, "An unexpected error occurred while performing the group operation: %+v", err)
+		hz_handler.Error(w, service_error.Internal, "An unexpected error occurred while performing the group operation.")
+	}
+}
@@ -1,7 +1,17 @@
 package handler
 
 import (
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/audit"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/delta"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/group"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/health"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/hiscore"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/snapshot"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/token"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/user"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/worker"
 	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/middleware"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -15,3 +25,37 @@ const (
 type HazelmereHandler interface {
 	RegisterRoutes(mux *chi.Mux, version ApiVersion, authorizer *middleware.Authorizer)
 }
+
+// Services are the services the handlers call. Callers that only register routes, such as the
+// OpenAPI generator, may leave them nil.
+type Services struct {
+	Health        *health.Service
+	Snapshot      snapshot.SnapshotService
+	Orchestrator  hiscore.HiscoreOrchestrator
+	User          user.UserService
+	UserMerger    hiscore.UserMerger
+	UserLifecycle hiscore.UserLifecycle
+	AccountTypes  user.AccountTypeChecker
+	Worker        worker.WorkerService
+	Job           worker.JobService
+	Delta         delta.DeltaService
+	Group         group.GroupService
+	Token         token.TokenService
+	Audit         audit.AuditService
+}
+
+// NewHandlers returns every handler the API serves. The server, the OpenAPI spec and the client
+// contract tests all register this list, so a new handler cannot be left out of any of them.
+func NewHandlers(mon *monitor.Monitor, services Services) []HazelmereHandler {
+	return []HazelmereHandler{
+		NewHealthHandler(mon, services.Health),
+		NewSnapshotHandler(mon, services.Snapshot, services.Orchestrator, services.Audit),
+		NewUserHandler(mon, services.User, services.UserMerger, services.UserLifecycle, services.AccountTypes, services.Audit),
+		NewWorkerHandler(mon, services.Worker, services.Job, services.Audit),
+		NewDeltaHandler(mon, services.Delta),
+		NewGroupHandler(mon, services.Group, services.Audit),
+		NewTokenHandler(mon, services.Token, services.Audit),
+		NewAuditHandler(mon, services.Audit),
+		NewOpenAPIHandler(mon),
+	}
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/middleware"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-api/src/internal/rest/openapi"
+	"github.com/go-chi/chi/v5"
+	chiWare "github.com/go-chi/chi/v5/middleware"
+)
+
+type OpenAPIHandler struct {
+	monitor *monitor.Monitor
+}
+
+func NewOpenAPIHandler(mon *monitor.Monitor) *OpenAPIHandler {
+	return &OpenAPIHandler{mon}
+}
+
+func (oh *OpenAPIHandler) RegisterRoutes(mux *chi.Mux, version ApiVersion, authorizer *middleware.Authorizer) {
+	if version == ApiVersionV1 {
+		mux.Group(func(r chi.Router) {
+			r.Use(chiWare.Timeout(1000 * time.Millisecond))
+			r.Get("/openapi.json", oh.GetOpenAPIDocument)
+		})
+	}
+}
+
+func (oh *OpenAPIHandler) GetOpenAPIDocument(w http.ResponseWriter, r *http.Request) {
+	ctx, span := oh.monitor.StartSpan(r.Context(), "OpenAPIHandler.GetOpenAPIDocument")
+	defer span.End()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(openapi.Spec); err != nil {
+		oh.monitor.Logger().ErrorArgs(ctx, "Failed to write OpenAPI document: %v", err)
+	}
+}
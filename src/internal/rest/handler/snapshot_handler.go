@@ -7,9 +7,11 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/audit"
 	"github.com/ctfloyd/hazelmere-api/src/internal/core/delta"
 	"github.com/ctfloyd/hazelmere-api/src/internal/core/hiscore"
 	"github.com/ctfloyd/hazelmere-api/src/internal/core/snapshot"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/auth"
 	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/middleware"
 	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
 	"github.com/ctfloyd/hazelmere-api/src/internal/rest/service_error"
@@ -23,22 +25,26 @@ type SnapshotHandler struct {
 	monitor      *monitor.Monitor
 	service      snapshot.SnapshotService
 	orchestrator hiscore.HiscoreOrchestrator
+	audit        audit.AuditService
 }
 
-func NewSnapshotHandler(mon *monitor.Monitor, service snapshot.SnapshotService, orchestrator hiscore.HiscoreOrchestrator) *SnapshotHandler {
-	return &SnapshotHandler{mon, service, orchestrator}
+func NewSnapshotHandler(mon *monitor.Monitor, service snapshot.SnapshotService, orchestrator hiscore.HiscoreOrchestrator, auditService audit.AuditService) *SnapshotHandler {
+	return &SnapshotHandler{mon, service, orchestrator, auditService}
 }
 
 func (sh *SnapshotHandler) RegisterRoutes(mux *chi.Mux, version ApiVersion, authorizer *middleware.Authorizer) {
 	if version == ApiVersionV1 {
 		mux.Group(func(r chi.Router) {
 			r.Use(chiWare.Timeout(5000 * time.Millisecond))
-			r.Get(fmt.Sprintf("/v1/snapshot/{userId:%s}/nearest/{timestamp}", hz_handler.RegexUuid), sh.GetSnapshotForUserNearestTimestamp)
-			r.Post(fmt.Sprintf("/v1/snapshot/interval"), sh.GetSnapshotInterval)
-			r.Post("/v1/summary/delta", sh.GetSnapshotWithDeltas)
 			r.Group(func(secure chi.Router) {
-				secure.Use(authorizer.Authorize)
+				secure.Use(authorizer.Require(auth.ScopeSnapshotRead))
 				secure.Get(fmt.Sprintf("/v1/snapshot/{userId:%s}", hz_handler.RegexUuid), sh.GetAllSnapshotsForUser)
+				secure.Get(fmt.Sprintf("/v1/snapshot/{userId:%s}/nearest/{timestamp}", hz_handler.RegexUuid), sh.GetSnapshotForUserNearestTimestamp)
+				secure.Post("/v1/snapshot/interval", sh.GetSnapshotInterval)
+				secure.Post("/v1/summary/delta", sh.GetSnapshotWithDeltas)
+			})
+			r.Group(func(secure chi.Router) {
+				secure.Use(authorizer.Require(auth.ScopeSnapshotWrite))
 				secure.Post("/v1/snapshot", sh.CreateSnapshot)
 			})
 		})
@@ -67,11 +73,19 @@ func (sh *SnapshotHandler) GetSnapshotInterval(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	timeline, err := sh.service.GetAccountTypeTimeline(ctx, intervalRequest.UserId)
+	if err != nil {
+		sh.monitor.Logger().ErrorArgs(ctx, "An unexpected error occurred while getting the account types of user %s: %+v", intervalRequest.UserId, err)
+		hz_handler.Error(w, service_error.Internal, "An unexpected error occurred while getting snapshot interval.")
+		return
+	}
+
 	response := api.GetSnapshotIntervalResponse{
 		Snapshots:          snapshot.HiscoreSnapshot{}.ManyToAPI(result.Snapshots),
 		TotalSnapshots:     result.TotalSnapshots,
 		SnapshotsWithGains: result.SnapshotsWithGains,
 	}
+	response.AccountType, response.AccountTypeHistory = timeline.ToAPI()
 
 	hz_handler.Ok(w, response)
 }
@@ -90,9 +104,17 @@ func (sh *SnapshotHandler) GetAllSnapshotsForUser(w http.ResponseWriter, r *http
 		return
 	}
 
+	timeline, err := sh.service.GetAccountTypeTimeline(ctx, userId)
+	if err != nil {
+		sh.monitor.Logger().ErrorArgs(ctx, "An unexpected error occurred while getting the account types of user %s: %+v", userId, err)
+		hz_handler.Error(w, service_error.Internal, "An unexpected error occurred while getting all snapshots for user.")
+		return
+	}
+
 	response := api.GetAllSnapshotsForUser{
 		Snapshots: snapshot.HiscoreSnapshot{}.ManyToAPI(snapshots),
 	}
+	response.AccountType, response.AccountTypeHistory = timeline.ToAPI()
 
 	hz_handler.Ok(w, response)
 }
@@ -125,8 +147,11 @@ func (sh *SnapshotHandler) CreateSnapshot(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	created := result.Snapshot.ToAPI()
+	recordAudit(ctx, sh.monitor, sh.audit, r, audit.ActionSnapshotCreate, audit.EntityTypeSnapshot, created.Id, nil, newSnapshotAuditView(created))
+
 	response := api.CreateSnapshotResponse{
-		Snapshot: result.Snapshot.ToAPI(),
+		Snapshot: created,
 	}
 
 	hz_handler.Ok(w, response)
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/audit"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/token"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/auth"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/middleware"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-api/src/internal/rest/service_error"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_handler"
+	"github.com/go-chi/chi/v5"
+	chiWare "github.com/go-chi/chi/v5/middleware"
+)
+
+type TokenHandler struct {
+	monitor *monitor.Monitor
+	service token.TokenService
+	audit   audit.AuditService
+}
+
+func NewTokenHandler(mon *monitor.Monitor, service token.TokenService, auditService audit.AuditService) *TokenHandler {
+	return &TokenHandler{mon, service, auditService}
+}
+
+func (th *TokenHandler) RegisterRoutes(mux *chi.Mux, version ApiVersion, authorizer *middleware.Authorizer) {
+	if version == ApiVersionV1 {
+		mux.Group(func(r chi.Router) {
+			r.Use(chiWare.Timeout(5000 * time.Millisecond))
+			r.Use(authorizer.Require(auth.ScopeAdmin))
+			r.Get("/v1/admin/token", th.GetAllTokens)
+			r.Post("/v1/admin/token", th.IssueToken)
+			r.Post(fmt.Sprintf("/v1/admin/token/{id:%s}/rotate", hz_handler.RegexUuid), th.RotateToken)
+			r.Post(fmt.Sprintf("/v1/admin/token/{id:%s}/revoke", hz_handler.RegexUuid), th.RevokeToken)
+		})
+	}
+}
+
+func (th *TokenHandler) GetAllTokens(w http.ResponseWriter, r *http.Request) {
+	ctx, span := th.monitor.StartSpan(r.Context(), "TokenHandler.GetAllTokens")
+	defer span.End()
+
+	th.monitor.Logger().Info(ctx, "Getting all tokens")
+
+	tokens, err := th.service.GetAllTokens(ctx)
+	if err != nil {
+		th.monitor.Logger().ErrorArgs(ctx, "An unexpected error occurred while getting all tokens: %+v", err)
+		hz_handler.Error(w, service_error.Internal, "An unexpected error occurred while performing the token operation.")
+		return
+	}
+
+	response := api.GetAllTokensResponse{
+		Tokens: token.Token{}.ManyToAPI(tokens),
+	}
+
+	hz_handler.Ok(w, response)
+}
+
+func (th *TokenHandler) IssueToken(w http.ResponseWriter, r *http.Request) {
+	ctx, span := th.monitor.StartSpan(r.Context(), "TokenHandler.IssueToken")
+	defer span.End()
+
+	var issueTokenRequest api.IssueTokenRequest
+	if ok := hz_handler.ReadBody(w, r, &issueTokenRequest); !ok {
+		return
+	}
+
+	th.monitor.Logger().InfoArgs(ctx, "Issuing token: %s", issueTokenRequest.Name)
+
+	t, secret, err := th.service.IssueToken(ctx, token.Token{}.FromIssueRequest(issueTokenRequest))
+	if err != nil {
+		th.writeError(ctx, w, err)
+		return
+	}
+
+	recordAudit(ctx, th.monitor, th.audit, r, audit.ActionTokenIssue, audit.EntityTypeToken, t.Id, nil, t.ToAPI())
+
+	response := api.IssueTokenResponse{
+		Token:  t.ToAPI(),
+		Secret: secret,
+	}
+
+	hz_handler.Ok(w, response)
+}
+
+func (th *TokenHandler) RotateToken(w http.ResponseWriter, r *http.Request) {
+	ctx, span := th.monitor.StartSpan(r.Context(), "TokenHandler.RotateToken")
+	defer span.End()
+
+	id := chi.URLParam(r, "id")
+	th.monitor.Logger().InfoArgs(ctx, "Rotating token: %s", id)
+
+	before := th.snapshot(ctx, id)
+	t, secret, err := th.service.RotateToken(ctx, id)
+	if err != nil {
+		th.writeError(ctx, w, err)
+		return
+	}
+
+	recordAudit(ctx, th.monitor, th.audit, r, audit.ActionTokenRotate, audit.EntityTypeToken, t.Id, before, t.ToAPI())
+
+	response := api.RotateTokenResponse{
+		Token:  t.ToAPI(),
+		Secret: secret,
+	}
+
+	hz_handler.Ok(w, response)
+}
+
+func (th *TokenHandler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	ctx, span := th.monitor.StartSpan(r.Context(), "TokenHandler.RevokeToken")
+	defer span.End()
+
+	id := chi.URLParam(r, "id")
+	th.monitor.Logger().InfoArgs(ctx, "Revoking token: %s", id)
+
+	before := th.snapshot(ctx, id)
+	t, err := th.service.RevokeToken(ctx, id)
+	if err != nil {
+		th.writeError(ctx, w, err)
+		return
+	}
+
+	recordAudit(ctx, th.monitor, th.audit, r, audit.ActionTokenRevoke, audit.EntityTypeToken, t.Id, before, t.ToAPI())
+
+	response := api.RevokeTokenResponse{
+		Token: t.ToAPI(),
+	}
+
+	hz_handler.Ok(w, response)
+}
+
+// snapshot captures a token for the audit diff of a write. The API view leaves out the secret
+// hash, so a rotation records who rotated the token but not what it was rotated to.
+func (th *TokenHandler) snapshot(ctx context.Context, id string) any {
+	if existing, err := th.service.GetTokenById(ctx, id); err == nil {
+		return existing.ToAPI()
+	}
+	return nil
+}
+
+func (th *TokenHandler) writeError(ctx context.Context, w http.ResponseWriter, err error) {
+	if errors.Is(err, token.ErrTokenNotFound) {
+		th.monitor.Logger().Warn(ctx, "Token not found.")
+		hz_handler.Error(w, service_error.TokenNotFound, "Token not found.")
+	} else if errors.Is(err, token.ErrTokenValidation) {
+		th.monitor.Logger().WarnArgs(ctx, "Invalid token request: %+v", err)
+		hz_handler.Error(w, service_error.InvalidToken, err.Error())
+	} else {
+		th.monitor.Logger().ErrorArgs(ctx, "An unexpected error occurred while performing the token operation: %+v", err)
+		hz_handler.Error(w, service_error.Internal, "An unexpected error occurred while performing the token operation.")
+	}
+}
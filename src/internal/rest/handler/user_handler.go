@@ -1,12 +1,17 @@
 package handler
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/audit"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/hiscore"
 	"github.com/ctfloyd/hazelmere-api/src/internal/core/user"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/auth"
 	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/middleware"
 	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
 	"github.com/ctfloyd/hazelmere-api/src/internal/rest/service_error"
@@ -17,24 +22,42 @@ import (
 )
 
 type UserHandler struct {
-	monitor *monitor.Monitor
-	service user.UserService
+	monitor      *monitor.Monitor
+	service      user.UserService
+	merger       hiscore.UserMerger
+	lifecycle    hiscore.UserLifecycle
+	accountTypes user.AccountTypeChecker
+	audit        audit.AuditService
 }
 
-func NewUserHandler(mon *monitor.Monitor, service user.UserService) *UserHandler {
-	return &UserHandler{mon, service}
+func NewUserHandler(mon *monitor.Monitor, service user.UserService, merger hiscore.UserMerger, lifecycle hiscore.UserLifecycle, accountTypes user.AccountTypeChecker, auditService audit.AuditService) *UserHandler {
+	return &UserHandler{mon, service, merger, lifecycle, accountTypes, auditService}
 }
 
 func (uh *UserHandler) RegisterRoutes(mux *chi.Mux, version ApiVersion, authorizer *middleware.Authorizer) {
 	if version == ApiVersionV1 {
 		mux.Group(func(r chi.Router) {
 			r.Use(chiWare.Timeout(5000 * time.Millisecond))
-			r.Get(fmt.Sprintf("/v1/user/{id:%s}", hz_handler.RegexUuid), uh.GetUserById)
-			r.Get("/v1/user", uh.GetAllUsers)
 			r.Group(func(secure chi.Router) {
-				secure.Use(authorizer.Authorize)
+				secure.Use(authorizer.Require(auth.ScopeUserRead))
+				secure.Get(fmt.Sprintf("/v1/user/{id:%s}", hz_handler.RegexUuid), uh.GetUserById)
+				secure.Get("/v1/user/name/{name}", uh.GetUserByName)
+				secure.Get("/v1/user", uh.GetAllUsers)
+			})
+			r.Group(func(secure chi.Router) {
+				secure.Use(authorizer.Require(auth.ScopeUserWrite))
 				secure.Post("/v1/user", uh.CreateUser)
 				secure.Put("/v1/user", uh.UpdateUser)
+				secure.Post(fmt.Sprintf("/v1/user/{id:%s}/rename", hz_handler.RegexUuid), uh.RenameUser)
+				secure.Delete(fmt.Sprintf("/v1/user/{id:%s}", hz_handler.RegexUuid), uh.DeleteUser)
+				secure.Post(fmt.Sprintf("/v1/user/{id:%s}/account-type", hz_handler.RegexUuid), uh.ReportAccountType)
+				secure.Post(fmt.Sprintf("/v1/user/{id:%s}/account-type/check", hz_handler.RegexUuid), uh.CheckAccountType)
+			})
+			r.Group(func(admin chi.Router) {
+				admin.Use(authorizer.Require(auth.ScopeAdmin))
+				admin.Post("/v1/admin/user/merge", uh.MergeUsers)
+				admin.Delete(fmt.Sprintf("/v1/admin/user/{id:%s}", hz_handler.RegexUuid), uh.DeleteUserPermanently)
+				admin.Get(fmt.Sprintf("/v1/admin/user/{id:%s}/export", hz_handler.RegexUuid), uh.ExportUser)
 			})
 		})
 	}
@@ -67,21 +90,81 @@ func (uh *UserHandler) GetUserById(w http.ResponseWriter, r *http.Request) {
 	hz_handler.Ok(w, response)
 }
 
+// GetUserByName finds a user by their current or a past runescape name, ignoring case and
+// treating '_', '-' and spaces alike.
+func (uh *UserHandler) GetUserByName(w http.ResponseWriter, r *http.Request) {
+	ctx, span := uh.monitor.StartSpan(r.Context(), "UserHandler.GetUserByName")
+	defer span.End()
+
+	name := chi.URLParam(r, "name")
+	uh.monitor.Logger().InfoArgs(ctx, "Getting user by name: %s", name)
+
+	u, matchedAlias, err := uh.service.GetUserByRunescapeName(ctx, name)
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			uh.monitor.Logger().WarnArgs(ctx, "User not found: %s", name)
+			hz_handler.Error(w, service_error.UserNotFound, "User not found.")
+			return
+		}
+		uh.monitor.Logger().ErrorArgs(ctx, "An unexpected error occurred while getting user by name: %+v", err)
+		hz_handler.Error(w, service_error.Internal, "An unexpected service_error occurred while performing the user operation.")
+		return
+	}
+
+	response := api.GetUserByNameResponse{
+		User:         u.ToAPI(),
+		MatchedAlias: matchedAlias,
+	}
+
+	hz_handler.Ok(w, response)
+}
+
+// GetAllUsers lists users a page at a time, 100 unless limit asks for fewer, optionally filtered
+// by the query parameters: name and match (prefix or contains), trackingStatus, accountType,
+// createdAfter (unix millis), sort (name, -name, createdAt or -createdAt), limit and cursor.
 func (uh *UserHandler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
 	ctx, span := uh.monitor.StartSpan(r.Context(), "UserHandler.GetAllUsers")
 	defer span.End()
 
-	uh.monitor.Logger().Info(ctx, "Getting all users")
+	params := r.URL.Query()
+	query := user.UserQuery{
+		Name:           params.Get("name"),
+		NameMatch:      user.NameMatch(params.Get("match")),
+		TrackingStatus: user.TrackingStatus(params.Get("trackingStatus")),
+		AccountType:    user.AccountType(params.Get("accountType")),
+		Sort:           user.UserSort(params.Get("sort")),
+		Cursor:         params.Get("cursor"),
+	}
+
+	var err error
+	if query.CreatedAfter, err = parseMillisParam(params.Get("createdAfter")); err != nil {
+		hz_handler.Error(w, service_error.BadRequest, "Could not convert createdAfter to a number.")
+		return
+	}
+	if limit := params.Get("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil {
+			hz_handler.Error(w, service_error.BadRequest, "Could not convert limit to a number.")
+			return
+		}
+	}
+
+	uh.monitor.Logger().InfoArgs(ctx, "Listing users: %+v", query)
 
-	users, err := uh.service.GetAllUsers(ctx)
+	page, err := uh.service.QueryUsers(ctx, query)
 	if err != nil {
-		uh.monitor.Logger().ErrorArgs(ctx, "An unexpected error occurred while getting all users: %+v", err)
+		if errors.Is(err, user.ErrInvalidUserQuery) {
+			uh.monitor.Logger().WarnArgs(ctx, "Invalid user query: %+v", err)
+			hz_handler.Error(w, service_error.BadRequest, err.Error())
+			return
+		}
+		uh.monitor.Logger().ErrorArgs(ctx, "An unexpected error occurred while listing users: %+v", err)
 		hz_handler.Error(w, service_error.Internal, "An unexpected service_error occurred while performing the user operation.")
 		return
 	}
 
 	response := api.GetAllUsersResponse{
-		Users: user.User{}.ManyToAPI(users),
+		Users:      user.User{}.ManyToAPI(page.Users),
+		NextCursor: page.NextCursor,
 	}
 
 	hz_handler.Ok(w, response)
@@ -104,9 +187,15 @@ func (uh *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 
 	u, err := uh.service.CreateUser(ctx, domainUser)
 	if err != nil {
+		if errors.Is(err, user.ErrUserValidation) {
+			uh.monitor.Logger().WarnArgs(ctx, "Invalid user: %+v", err)
+			hz_handler.Error(w, service_error.InvalidUser, err.Error())
+			return
+		}
 		if errors.Is(err, user.ErrRunescapeNameTracked) {
 			uh.monitor.Logger().WarnArgs(ctx, "Runescape name already tracked: %s", createUserRequest.RunescapeName)
 			hz_handler.Error(w, service_error.RunescapeNameAlreadyTracked, "The runescape name is already associated with a user.")
+			return
 		}
 
 		uh.monitor.Logger().ErrorArgs(ctx, "An unexpected error occurred while creating user: %+v", err)
@@ -114,6 +203,8 @@ func (uh *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	recordAudit(ctx, uh.monitor, uh.audit, r, audit.ActionUserCreate, audit.EntityTypeUser, u.Id, nil, u.ToAPI())
+
 	response := api.CreateUserResponse{
 		User: u.ToAPI(),
 	}
@@ -136,11 +227,28 @@ func (uh *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 
 	domainUser := user.User{}.FromUpdateRequest(updateUserRequest)
 
+	// Captured for the audit diff; a missing user is reported by UpdateUser below.
+	var before any
+	if existing, err := uh.service.GetUserById(ctx, updateUserRequest.Id); err == nil {
+		before = existing.ToAPI()
+	}
+
 	u, err := uh.service.UpdateUser(ctx, domainUser)
 	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			uh.monitor.Logger().WarnArgs(ctx, "User not found: %s", updateUserRequest.Id)
+			hz_handler.Error(w, service_error.UserNotFound, "User not found.")
+			return
+		}
+		if errors.Is(err, user.ErrUserValidation) {
+			uh.monitor.Logger().WarnArgs(ctx, "Invalid user: %+v", err)
+			hz_handler.Error(w, service_error.InvalidUser, err.Error())
+			return
+		}
 		if errors.Is(err, user.ErrRunescapeNameTracked) {
 			uh.monitor.Logger().WarnArgs(ctx, "Runescape name already tracked for user: %s", updateUserRequest.Id)
 			hz_handler.Error(w, service_error.RunescapeNameAlreadyTracked, "The runescape name is already associated with a user.")
+			return
 		}
 
 		uh.monitor.Logger().ErrorArgs(ctx, "An unexpected error occurred while updating user: %+v", err)
@@ -148,9 +256,291 @@ func (uh *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response := api.CreateUserResponse{
+	recordAudit(ctx, uh.monitor, uh.audit, r, audit.ActionUserUpdate, audit.EntityTypeUser, u.Id, before, u.ToAPI())
+
+	response := api.UpdateUserResponse{
 		User: u.ToAPI(),
 	}
 
 	hz_handler.Ok(w, response)
 }
+
+// RenameUser changes the runescape name of a user after a name change in game. The old name is
+// kept as an alias, so the user is still found by it.
+func (uh *UserHandler) RenameUser(w http.ResponseWriter, r *http.Request) {
+	ctx, span := uh.monitor.StartSpan(r.Context(), "UserHandler.RenameUser")
+	defer span.End()
+
+	id := chi.URLParam(r, "id")
+
+	var renameUserRequest api.RenameUserRequest
+	if ok := hz_handler.ReadBody(w, r, &renameUserRequest); !ok {
+		uh.monitor.Logger().Warn(ctx, "Failed to read request body for rename user")
+		hz_handler.Error(w, service_error.BadRequest, "Request body could not be read.")
+		return
+	}
+
+	uh.monitor.Logger().InfoArgs(ctx, "Renaming user %s to %s", id, renameUserRequest.RunescapeName)
+
+	// Captured for the audit diff; a missing user is reported by RenameUser below.
+	var before any
+	if existing, err := uh.service.GetUserById(ctx, id); err == nil {
+		before = existing.ToAPI()
+	}
+
+	u, err := uh.service.RenameUser(ctx, id, renameUserRequest.RunescapeName)
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			uh.monitor.Logger().WarnArgs(ctx, "User not found: %s", id)
+			hz_handler.Error(w, service_error.UserNotFound, "User not found.")
+			return
+		}
+		if errors.Is(err, user.ErrUserValidation) {
+			uh.monitor.Logger().WarnArgs(ctx, "Invalid user: %+v", err)
+			hz_handler.Error(w, service_error.InvalidUser, err.Error())
+			return
+		}
+		if errors.Is(err, user.ErrRunescapeNameTracked) {
+			uh.monitor.Logger().WarnArgs(ctx, "Runescape name already tracked: %s", renameUserRequest.RunescapeName)
+			hz_handler.Error(w, service_error.RunescapeNameAlreadyTracked, "The runescape name is already associated with a user.")
+			return
+		}
+
+		uh.monitor.Logger().ErrorArgs(ctx, "An unexpected error occurred while renaming user: %+v", err)
+		hz_handler.Error(w, service_error.Internal, "An unexpected service_error occurred while performing the user operation.")
+		return
+	}
+
+	recordAudit(ctx, uh.monitor, uh.audit, r, audit.ActionUserRename, audit.EntityTypeUser, u.Id, before, u.ToAPI())
+
+	response := api.RenameUserResponse{
+		User: u.ToAPI(),
+	}
+
+	hz_handler.Ok(w, response)
+}
+
+// MergeUsers merges the history of one user into another, e.g. when the same account was tracked
+// twice. The source user is kept as a tombstone pointing at the target. A dry run only reports
+// what the merge would do.
+func (uh *UserHandler) MergeUsers(w http.ResponseWriter, r *http.Request) {
+	ctx, span := uh.monitor.StartSpan(r.Context(), "UserHandler.MergeUsers")
+	defer span.End()
+
+	var mergeUsersRequest api.MergeUsersRequest
+	if ok := hz_handler.ReadBody(w, r, &mergeUsersRequest); !ok {
+		uh.monitor.Logger().Warn(ctx, "Failed to read request body for merge users")
+		hz_handler.Error(w, service_error.BadRequest, "Request body could not be read.")
+		return
+	}
+
+	uh.monitor.Logger().InfoArgs(ctx, "Merging user %s into %s (dry run: %t)", mergeUsersRequest.SourceUserId, mergeUsersRequest.TargetUserId, mergeUsersRequest.DryRun)
+
+	report, err := uh.merger.MergeUsers(ctx, mergeUsersRequest.SourceUserId, mergeUsersRequest.TargetUserId, mergeUsersRequest.DryRun)
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			uh.monitor.Logger().WarnArgs(ctx, "User not found while merging %s into %s", mergeUsersRequest.SourceUserId, mergeUsersRequest.TargetUserId)
+			hz_handler.Error(w, service_error.UserNotFound, "User not found.")
+			return
+		}
+		if errors.Is(err, user.ErrUserValidation) {
+			uh.monitor.Logger().WarnArgs(ctx, "Invalid user merge: %+v", err)
+			hz_handler.Error(w, service_error.InvalidUser, err.Error())
+			return
+		}
+
+		uh.monitor.Logger().ErrorArgs(ctx, "An unexpected error occurred while merging users: %+v", err)
+		hz_handler.Error(w, service_error.Internal, "An unexpected service_error occurred while performing the user operation.")
+		return
+	}
+
+	if !report.DryRun {
+		recordAudit(ctx, uh.monitor, uh.audit, r, audit.ActionUserMerge, audit.EntityTypeUser, report.SourceUserId, nil, report.ToAPI())
+	}
+
+	response := api.MergeUsersResponse{
+		Report: report.ToAPI(),
+	}
+
+	hz_handler.Ok(w, response)
+}
+
+// DeleteUser soft deletes a user: tracking stops and they disappear from listings and lookups by
+// name, but their data is kept.
+func (uh *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	ctx, span := uh.monitor.StartSpan(r.Context(), "UserHandler.DeleteUser")
+	defer span.End()
+
+	id := chi.URLParam(r, "id")
+	uh.monitor.Logger().InfoArgs(ctx, "Deleting user: %s", id)
+
+	// Captured for the audit diff; a missing user is reported by SoftDeleteUser below.
+	var before any
+	if existing, err := uh.service.GetUserById(ctx, id); err == nil {
+		before = existing.ToAPI()
+	}
+
+	u, err := uh.service.SoftDeleteUser(ctx, id)
+	if err != nil {
+		if errors.Is(err, user.ErrUserValidation) {
+			uh.monitor.Logger().WarnArgs(ctx, "Cannot delete user %s: %+v", id, err)
+			hz_handler.Error(w, service_error.InvalidUser, err.Error())
+			return
+		}
+		if errors.Is(err, user.ErrUserNotFound) {
+			uh.monitor.Logger().WarnArgs(ctx, "User not found: %s", id)
+			hz_handler.Error(w, service_error.UserNotFound, "User not found.")
+			return
+		}
+
+		uh.monitor.Logger().ErrorArgs(ctx, "An unexpected error occurred while deleting user: %+v", err)
+		hz_handler.Error(w, service_error.Internal, "An unexpected service_error occurred while performing the user operation.")
+		return
+	}
+
+	recordAudit(ctx, uh.monitor, uh.audit, r, audit.ActionUserDelete, audit.EntityTypeUser, u.Id, before, u.ToAPI())
+
+	response := api.DeleteUserResponse{
+		User: u.ToAPI(),
+	}
+
+	hz_handler.Ok(w, response)
+}
+
+// DeleteUserPermanently removes a user with their snapshots, deltas, jobs and merged users. The
+// audit record keeps only the id, so no personal data outlives the user.
+func (uh *UserHandler) DeleteUserPermanently(w http.ResponseWriter, r *http.Request) {
+	ctx, span := uh.monitor.StartSpan(r.Context(), "UserHandler.DeleteUserPermanently")
+	defer span.End()
+
+	id := chi.URLParam(r, "id")
+	uh.monitor.Logger().InfoArgs(ctx, "Permanently deleting user: %s", id)
+
+	report, err := uh.lifecycle.HardDeleteUser(ctx, id)
+	if err != nil {
+		if errors.Is(err, user.ErrUserValidation) {
+			uh.monitor.Logger().WarnArgs(ctx, "Cannot permanently delete user %s: %+v", id, err)
+			hz_handler.Error(w, service_error.InvalidUser, err.Error())
+			return
+		}
+		if errors.Is(err, user.ErrUserNotFound) {
+			uh.monitor.Logger().WarnArgs(ctx, "User not found: %s", id)
+			hz_handler.Error(w, service_error.UserNotFound, "User not found.")
+			return
+		}
+
+		uh.monitor.Logger().ErrorArgs(ctx, "An unexpected error occurred while permanently deleting user: %+v", err)
+		hz_handler.Error(w, service_error.Internal, "An unexpected service_error occurred while performing the user operation.")
+		return
+	}
+
+	recordAudit(ctx, uh.monitor, uh.audit, r, audit.ActionUserPurge, audit.EntityTypeUser, id, nil, nil)
+
+	hz_handler.Ok(w, report.ToAPI())
+}
+
+// ExportUser returns everything held about a user, for data access requests.
+func (uh *UserHandler) ExportUser(w http.ResponseWriter, r *http.Request) {
+	ctx, span := uh.monitor.StartSpan(r.Context(), "UserHandler.ExportUser")
+	defer span.End()
+
+	id := chi.URLParam(r, "id")
+	uh.monitor.Logger().InfoArgs(ctx, "Exporting user: %s", id)
+
+	export, err := uh.lifecycle.ExportUser(ctx, id)
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			uh.monitor.Logger().WarnArgs(ctx, "User not found: %s", id)
+			hz_handler.Error(w, service_error.UserNotFound, "User not found.")
+			return
+		}
+
+		uh.monitor.Logger().ErrorArgs(ctx, "An unexpected error occurred while exporting user: %+v", err)
+		hz_handler.Error(w, service_error.Internal, "An unexpected service_error occurred while performing the user operation.")
+		return
+	}
+
+	// Reads are not audited, but handing out someone's data is worth a record.
+	recordAudit(ctx, uh.monitor, uh.audit, r, audit.ActionUserExport, audit.EntityTypeUser, id, nil, nil)
+
+	response := api.ExportUserResponse{
+		Export: export.ToAPI(),
+	}
+
+	hz_handler.Ok(w, response)
+}
+
+// ReportAccountType records the account type a check outside the API found, e.g. the worker
+// reading the hiscores of each account type.
+func (uh *UserHandler) ReportAccountType(w http.ResponseWriter, r *http.Request) {
+	ctx, span := uh.monitor.StartSpan(r.Context(), "UserHandler.ReportAccountType")
+	defer span.End()
+
+	id := chi.URLParam(r, "id")
+
+	var reportRequest api.ReportAccountTypeRequest
+	if ok := hz_handler.ReadBody(w, r, &reportRequest); !ok {
+		uh.monitor.Logger().Warn(ctx, "Failed to read request body for report account type")
+		hz_handler.Error(w, service_error.BadRequest, "Request body could not be read.")
+		return
+	}
+
+	uh.monitor.Logger().InfoArgs(ctx, "Reporting account type %s for user %s", reportRequest.AccountType, id)
+
+	detectedAt := time.Now()
+	if reportRequest.DetectedAt != nil {
+		detectedAt = *reportRequest.DetectedAt
+	}
+
+	before, _ := uh.service.GetUserById(ctx, id)
+	u, changed, err := uh.service.ReportAccountType(ctx, id, user.AccountType(reportRequest.AccountType), detectedAt)
+	uh.respondAccountType(ctx, w, r, before, u, changed, err)
+}
+
+// CheckAccountType looks the account type of a user up now and records it if it changed.
+func (uh *UserHandler) CheckAccountType(w http.ResponseWriter, r *http.Request) {
+	ctx, span := uh.monitor.StartSpan(r.Context(), "UserHandler.CheckAccountType")
+	defer span.End()
+
+	id := chi.URLParam(r, "id")
+	uh.monitor.Logger().InfoArgs(ctx, "Checking account type of user %s", id)
+
+	before, _ := uh.service.GetUserById(ctx, id)
+	u, changed, err := uh.accountTypes.CheckAccountType(ctx, id)
+	uh.respondAccountType(ctx, w, r, before, u, changed, err)
+}
+
+func (uh *UserHandler) respondAccountType(ctx context.Context, w http.ResponseWriter, r *http.Request, before user.User, u user.User, changed bool, err error) {
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			uh.monitor.Logger().WarnArgs(ctx, "User not found: %s", chi.URLParam(r, "id"))
+			hz_handler.Error(w, service_error.UserNotFound, "User not found.")
+			return
+		}
+		if errors.Is(err, user.ErrUserValidation) {
+			uh.monitor.Logger().WarnArgs(ctx, "Invalid user: %+v", err)
+			hz_handler.Error(w, service_error.InvalidUser, err.Error())
+			return
+		}
+		if errors.Is(err, user.ErrAccountTypeUndetected) {
+			uh.monitor.Logger().WarnArgs(ctx, "Account type undetected: %+v", err)
+			hz_handler.Error(w, service_error.AccountTypeUndetected, "The account type of the user could not be detected.")
+			return
+		}
+
+		uh.monitor.Logger().ErrorArgs(ctx, "An unexpected error occurred while updating the account type: %+v", err)
+		hz_handler.Error(w, service_error.Internal, "An unexpected service_error occurred while performing the user operation.")
+		return
+	}
+
+	if changed {
+		recordAudit(ctx, uh.monitor, uh.audit, r, audit.ActionUserAccountType, audit.EntityTypeUser, u.Id, before.ToAPI(), u.ToAPI())
+	}
+
+	response := api.ReportAccountTypeResponse{
+		User:    u.ToAPI(),
+		Changed: changed,
+	}
+
+	hz_handler.Ok(w, response)
+}
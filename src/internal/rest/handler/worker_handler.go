@@ -1,12 +1,17 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/audit"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/snapshot"
 	"github.com/ctfloyd/hazelmere-api/src/internal/core/worker"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/auth"
 	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/middleware"
 	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
 	"github.com/ctfloyd/hazelmere-api/src/internal/rest/service_error"
@@ -19,19 +24,27 @@ import (
 type WorkerHandler struct {
 	monitor *monitor.Monitor
 	service worker.WorkerService
+	jobs    worker.JobService
+	audit   audit.AuditService
 }
 
-func NewWorkerHandler(mon *monitor.Monitor, service worker.WorkerService) *WorkerHandler {
-	return &WorkerHandler{mon, service}
+func NewWorkerHandler(mon *monitor.Monitor, service worker.WorkerService, jobService worker.JobService, auditService audit.AuditService) *WorkerHandler {
+	return &WorkerHandler{mon, service, jobService, auditService}
 }
 
 func (wh *WorkerHandler) RegisterRoutes(mux *chi.Mux, version ApiVersion, authorizer *middleware.Authorizer) {
 	if version == ApiVersionV1 {
 		mux.Group(func(r chi.Router) {
 			r.Use(chiWare.Timeout(10 * time.Second))
-			r.Use(authorizer.Authorize)
+			r.Use(authorizer.Require(auth.ScopeWorkerTrigger))
 			r.Get(fmt.Sprintf("/v1/worker/snapshot/on-demand/{userId:%s}", hz_handler.RegexUuid), wh.GenerateSnapshotOnDemand)
 		})
+		mux.Group(func(r chi.Router) {
+			r.Use(chiWare.Timeout(5000 * time.Millisecond))
+			r.Use(authorizer.Require(auth.ScopeWorkerTrigger))
+			r.Post("/v1/worker/jobs", wh.CreateSnapshotJob)
+			r.Get(fmt.Sprintf("/v1/worker/jobs/{id:%s}", hz_handler.RegexUuid), wh.GetJob)
+		})
 	}
 }
 
@@ -43,21 +56,113 @@ func (wh *WorkerHandler) GenerateSnapshotOnDemand(w http.ResponseWriter, r *http
 
 	wh.monitor.Logger().InfoArgs(ctx, "Generating snapshot on demand for user: %s", userId)
 
-	ss, err := wh.service.GenerateSnapshotOnDemand(ctx, userId)
+	result, err := wh.service.GenerateSnapshotOnDemand(ctx, userId)
 	if err != nil {
+		if errors.Is(err, snapshot.ErrSnapshotValidation) {
+			wh.monitor.Logger().WarnArgs(ctx, "Cannot generate snapshot for user %s: %+v", userId, err)
+			hz_handler.Error(w, service_error.InvalidUser, err.Error())
+			return
+		}
 		if errors.Is(err, worker.ErrHiscoreTimeout) {
 			wh.monitor.Logger().WarnArgs(ctx, "Hiscore timeout while generating snapshot for user: %s", userId)
 			hz_handler.Error(w, service_error.HiscoreTimeout, "Osrs hiscores timed out.")
 			return
 		}
+		if errors.Is(err, worker.ErrWorkerUnavailable) {
+			wh.monitor.Logger().WarnArgs(ctx, "Worker unavailable while generating snapshot for user: %s", userId)
+			hz_handler.Error(w, service_error.WorkerUnavailable, "The worker is unavailable, try again later.")
+			return
+		}
 		wh.monitor.Logger().ErrorArgs(ctx, "An unexpected error occurred while generating snapshot for user %s: %+v", userId, err)
 		hz_handler.Error(w, service_error.Internal, "An unexpected error occurred while performing the worker operation.")
 		return
 	}
 
+	generated := result.Snapshot.ToAPI()
+	if !result.Reused && !result.Shared {
+		recordAudit(ctx, wh.monitor, wh.audit, r, audit.ActionWorkerSnapshotOnDemand, audit.EntityTypeSnapshot, generated.Id, nil, newSnapshotAuditView(generated))
+	}
+
 	response := api.GenerateSnapshotOnDemandResponse{
-		Snapshot: ss.ToAPI(),
+		Snapshot:      generated,
+		Reused:        result.Reused,
+		Shared:        result.Shared,
+		NextRefreshAt: result.NextRefreshAt,
+	}
+
+	hz_handler.Ok(w, response)
+}
+
+// CreateSnapshotJob queues an on-demand snapshot and returns immediately with 202 Accepted.
+// The job is polled through GetJob, or delivered to the webhook once it finishes.
+func (wh *WorkerHandler) CreateSnapshotJob(w http.ResponseWriter, r *http.Request) {
+	ctx, span := wh.monitor.StartSpan(r.Context(), "WorkerHandler.CreateSnapshotJob")
+	defer span.End()
+
+	var createSnapshotJobRequest api.CreateSnapshotJobRequest
+	if ok := hz_handler.ReadBody(w, r, &createSnapshotJobRequest); !ok {
+		return
+	}
+
+	wh.monitor.Logger().InfoArgs(ctx, "Queueing snapshot job for user: %s", createSnapshotJobRequest.UserId)
+
+	job := worker.Job{
+		UserId:     createSnapshotJobRequest.UserId,
+		WebhookUrl: createSnapshotJobRequest.WebhookUrl,
+	}
+	if principal, ok := middleware.PrincipalFromContext(ctx); ok {
+		job.RequestedBy = principal.Name
+	}
+
+	job, err := wh.jobs.CreateSnapshotJob(ctx, job)
+	if err != nil {
+		wh.writeJobError(ctx, w, err)
+		return
+	}
+
+	created := job.ToAPI()
+	recordAudit(ctx, wh.monitor, wh.audit, r, audit.ActionWorkerJobCreate, audit.EntityTypeJob, created.Id, nil, created)
+
+	response := api.CreateSnapshotJobResponse{
+		Job: created,
+	}
+
+	// hz_handler.Json writes the status after the body, so it cannot send 202.
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		wh.monitor.Logger().ErrorArgs(ctx, "Failed to encode job response: %v", err)
+	}
+}
+
+func (wh *WorkerHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	ctx, span := wh.monitor.StartSpan(r.Context(), "WorkerHandler.GetJob")
+	defer span.End()
+
+	id := chi.URLParam(r, "id")
+
+	job, err := wh.jobs.GetJobById(ctx, id)
+	if err != nil {
+		wh.writeJobError(ctx, w, err)
+		return
+	}
+
+	response := api.GetWorkerJobResponse{
+		Job: job.ToAPI(),
 	}
 
 	hz_handler.Ok(w, response)
 }
+
+func (wh *WorkerHandler) writeJobError(ctx context.Context, w http.ResponseWriter, err error) {
+	if errors.Is(err, worker.ErrJobNotFound) {
+		wh.monitor.Logger().Warn(ctx, "Job not found.")
+		hz_handler.Error(w, service_error.JobNotFound, "Job not found.")
+	} else if errors.Is(err, worker.ErrJobValidation) {
+		wh.monitor.Logger().WarnArgs(ctx, "Invalid job request: %+v", err)
+		hz_handler.Error(w, service_error.InvalidJob, err.Error())
+	} else {
+		wh.monitor.Logger().ErrorArgs(ctx, "An unexpected error occurred while performing the job operation: %+v", err)
+		hz_handler.Error(w, service_error.Internal, "An unexpected error occurred while performing the job operation.")
+	}
+}
@@ -0,0 +1,263 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/hiscore"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/middleware"
+	"github.com/ctfloyd/hazelmere-api/src/internal/rest/service_error"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_api"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_handler"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_service_error"
+	"github.com/go-chi/chi/v5"
+)
+
+const bearerAuth = "bearerAuth"
+
+// Route is a method and chi route pattern registered on the router.
+type Route struct {
+	Method  string
+	Pattern string
+}
+
+// Key identifies the route as "METHOD /path/{param}", without the param regexes.
+func (r Route) Key() string {
+	path, _ := parseRoute(r.Pattern)
+	return r.Method + " " + path
+}
+
+type routeParam struct {
+	name    string
+	pattern string
+}
+
+// parseRoute strips the regexes from chi route params, turning {userId:[0-9a-f]{8}} into
+// {userId}. Braces are matched by depth since the regexes themselves contain them.
+func parseRoute(route string) (string, []routeParam) {
+	var path strings.Builder
+	var params []routeParam
+	for i := 0; i < len(route); i++ {
+		if route[i] != '{' {
+			path.WriteByte(route[i])
+			continue
+		}
+
+		depth, end := 0, i
+		for ; end < len(route); end++ {
+			if route[end] == '{' {
+				depth++
+			} else if route[end] == '}' {
+				depth--
+				if depth == 0 {
+					break
+				}
+			}
+		}
+
+		name, pattern, _ := strings.Cut(route[i+1:end], ":")
+		params = append(params, routeParam{name: name, pattern: pattern})
+		path.WriteString("{" + name + "}")
+		i = end
+	}
+	return path.String(), params
+}
+
+// Routes walks the router and returns every registered route.
+func Routes(router chi.Routes) ([]Route, error) {
+	var routes []Route
+	err := chi.Walk(router, func(method string, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		routes = append(routes, Route{Method: method, Pattern: route})
+		return nil
+	})
+	return routes, err
+}
+
+// Generate builds the OpenAPI document for routes. It fails if a route has no entry in the
+// operations table, or an entry no longer matches a registered route.
+func Generate(routes []Route) (Document, error) {
+	registry := newSchemaRegistry()
+	doc := Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:       "Hazelmere API",
+			Description: "Old School RuneScape hiscore tracking. Generated from the registered routes and pkg/api types.",
+			Version:     "v1",
+		},
+		Paths: make(map[string]map[string]Operation),
+		Components: Components{
+			SecuritySchemes: map[string]SecurityScheme{
+				bearerAuth: {Type: "http", Scheme: "bearer"},
+			},
+		},
+	}
+
+	var errs []error
+	seen := make(map[string]bool)
+	for _, route := range routes {
+		path, params := parseRoute(route.Pattern)
+		key := route.Key()
+		spec, ok := operations[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("route %s has no entry in the operations table", key))
+			continue
+		}
+		seen[key] = true
+
+		operation, err := buildOperation(registry, params, spec)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		if route.Method != http.MethodGet {
+			operation.Parameters = append(operation.Parameters, idempotencyKeyParameter)
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]Operation)
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = operation
+	}
+
+	for key := range operations {
+		if !seen[key] {
+			errs = append(errs, fmt.Errorf("operation %s does not match a registered route", key))
+		}
+	}
+
+	errorSchema, err := registry.schemaFor(reflect.TypeOf(hz_api.ErrorResponse{}))
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		name := strings.TrimPrefix(errorSchema.Ref, "#/components/schemas/")
+		registry.schemas[name].Properties["code"].Enum = api.AllErrorCodes
+	}
+
+	if len(errs) > 0 {
+		sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+		return Document{}, errors.Join(errs...)
+	}
+
+	doc.Components.Schemas = registry.schemas
+	return doc, nil
+}
+
+// Marshal renders the document as indented JSON with a trailing newline.
+func Marshal(doc Document) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// idempotencyKeyParameter is accepted by every write, see middleware.Idempotency.
+var idempotencyKeyParameter = Parameter{
+	Name:        middleware.HeaderIdempotencyKey,
+	In:          "header",
+	Description: "Makes the write safe to retry: a repeat with the same key and body gets the first response replayed instead of running again.",
+	Schema:      &Schema{Type: "string"},
+}
+
+func buildOperation(registry *schemaRegistry, params []routeParam, spec OperationSpec) (Operation, error) {
+	operation := Operation{
+		OperationId: spec.Id,
+		Summary:     spec.Summary,
+		Tags:        []string{spec.Tag},
+		Responses:   make(map[string]Response),
+	}
+
+	for _, param := range params {
+		schema := &Schema{Type: "string"}
+		if param.pattern == hz_handler.RegexUuid {
+			schema.Format = "uuid"
+		}
+		operation.Parameters = append(operation.Parameters, Parameter{Name: param.name, In: "path", Required: true, Schema: schema})
+	}
+	for _, q := range spec.Query {
+		operation.Parameters = append(operation.Parameters, Parameter{Name: q.Name, In: "query", Description: q.Description, Schema: &Schema{Type: q.Type}})
+	}
+
+	if spec.Request != nil {
+		schema, err := registry.schemaFor(reflect.TypeOf(spec.Request))
+		if err != nil {
+			return Operation{}, err
+		}
+		operation.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {Schema: schema}},
+		}
+	}
+
+	schema, err := registry.schemaFor(reflect.TypeOf(spec.Response))
+	if err != nil {
+		return Operation{}, err
+	}
+	content := map[string]MediaType{"application/json": {Schema: schema}}
+	if spec.BinaryResponse {
+		content[hiscore.BinaryContentType] = MediaType{Schema: &Schema{Type: "string", Format: "binary"}}
+	}
+	status := http.StatusOK
+	if spec.Status != 0 {
+		status = spec.Status
+	}
+	operation.Responses[strconv.Itoa(status)] = Response{Description: "Success.", Headers: rateLimitHeaders(), Content: content}
+	for _, status := range spec.ResponseStatuses {
+		operation.Responses[strconv.Itoa(status)] = Response{Description: http.StatusText(status) + ".", Content: content}
+	}
+
+	serviceErrors := slices.Clone(spec.Errors)
+	serviceErrors = append(serviceErrors, service_error.RateLimited)
+	if spec.Scope != "" {
+		operation.RequiredScope = string(spec.Scope)
+		operation.Security = []map[string][]string{{bearerAuth: {}}}
+		serviceErrors = append(serviceErrors, service_error.Unauthorized, service_error.Forbidden)
+	}
+
+	errorSchema, err := registry.schemaFor(reflect.TypeOf(hz_api.ErrorResponse{}))
+	if err != nil {
+		return Operation{}, err
+	}
+	for status, codes := range codesByStatus(serviceErrors) {
+		operation.Responses[strconv.Itoa(status)] = Response{
+			Description: "Error codes: " + strings.Join(codes, ", ") + ".",
+			Content:     map[string]MediaType{"application/json": {Schema: errorSchema}},
+		}
+	}
+
+	return operation, nil
+}
+
+func codesByStatus(serviceErrors []hz_service_error.ServiceError) map[int][]string {
+	byStatus := make(map[int][]string)
+	for _, se := range serviceErrors {
+		if !slices.Contains(byStatus[se.Status], se.Code) {
+			byStatus[se.Status] = append(byStatus[se.Status], se.Code)
+		}
+	}
+	for status := range byStatus {
+		sort.Strings(byStatus[status])
+	}
+	return byStatus
+}
+
+func rateLimitHeaders() map[string]Header {
+	integer := &Schema{Type: "integer"}
+	return map[string]Header{
+		"RateLimit-Limit":     {Description: "Requests allowed in a burst.", Schema: integer},
+		"RateLimit-Remaining": {Description: "Requests left before the limit is reached.", Schema: integer},
+		"RateLimit-Reset":     {Description: "Seconds until the limit is fully replenished.", Schema: integer},
+	}
+}
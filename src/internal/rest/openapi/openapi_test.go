@@ -0,0 +1,93 @@
+package openapi_test
+
+import (
+	"bytes"
+	"flag"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/middleware"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-api/src/internal/rest/handler"
+	"github.com/ctfloyd/hazelmere-api/src/internal/rest/openapi"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_logger"
+	"github.com/go-chi/chi/v5"
+)
+
+var update = flag.Bool("update", false, "rewrite openapi.json from the registered routes")
+
+// registeredRoutes registers every handler the server registers in cli/serve. Services are nil
+// because only route registration is exercised.
+func registeredRoutes(t *testing.T) []openapi.Route {
+	t.Helper()
+
+	mon := monitor.New(hz_logger.NewZeroLogAdapater(hz_logger.LogLevelError))
+	authorizer := middleware.NewAuthorizer(false, nil, nil, mon)
+	handlers := handler.NewHandlers(mon, handler.Services{})
+
+	router := chi.NewRouter()
+	for _, h := range handlers {
+		h.RegisterRoutes(router, handler.ApiVersionV1, authorizer)
+	}
+
+	routes, err := openapi.Routes(router)
+	if err != nil {
+		t.Fatalf("walking routes: %v", err)
+	}
+	return routes
+}
+
+func TestSpecMatchesRoutes(t *testing.T) {
+	doc, err := openapi.Generate(registeredRoutes(t))
+	if err != nil {
+		t.Fatalf("generating spec:\n%v", err)
+	}
+
+	generated, err := openapi.Marshal(doc)
+	if err != nil {
+		t.Fatalf("marshalling spec: %v", err)
+	}
+
+	if *update {
+		if err := os.WriteFile("openapi.json", generated, 0o644); err != nil {
+			t.Fatalf("writing spec: %v", err)
+		}
+		return
+	}
+
+	if !bytes.Equal(generated, openapi.Spec) {
+		t.Fatal("openapi.json is out of date with the registered routes or pkg/api types; " +
+			"regenerate it with: go test ./src/internal/rest/openapi -update")
+	}
+}
+
+func TestAllErrorCodesIsComplete(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "../../../pkg/api/error.go", nil, 0)
+	if err != nil {
+		t.Fatalf("parsing error.go: %v", err)
+	}
+
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			for i, name := range spec.(*ast.ValueSpec).Names {
+				if !strings.HasPrefix(name.Name, "ErrorCode") {
+					continue
+				}
+				value := strings.Trim(spec.(*ast.ValueSpec).Values[i].(*ast.BasicLit).Value, `"`)
+				if !slices.Contains(api.AllErrorCodes, value) {
+					t.Errorf("%s is missing from api.AllErrorCodes", name.Name)
+				}
+			}
+		}
+	}
+}
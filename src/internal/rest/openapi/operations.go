@@ -0,0 +1,389 @@
+package openapi
+
+import (
+	"net/http"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/auth"
+	"github.com/ctfloyd/hazelmere-api/src/internal/rest/service_error"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_service_error"
+)
+
+// OperationSpec describes a route registered by a HazelmereHandler. Every registered route
+// must have an entry in operations, keyed by "METHOD /path/{param}".
+type OperationSpec struct {
+	Id      string
+	Summary string
+	Tag     string
+	// Scope is the scope enforced by Authorizer.Require, empty for public routes.
+	Scope    auth.Scope
+	Query    []QueryParam
+	Request  any
+	Response any
+	// Status is the success status returning Response, 200 when unset.
+	Status int
+	// BinaryResponse marks routes that return hiscore.BinaryContentType when requested
+	// through the Accept header.
+	BinaryResponse bool
+	// ResponseStatuses are additional statuses that return the Response schema.
+	ResponseStatuses []int
+	Errors           []hz_service_error.ServiceError
+}
+
+type QueryParam struct {
+	Name        string
+	Description string
+	Type        string
+}
+
+var operations = map[string]OperationSpec{
+	"GET /health": {
+		Id:               "healthCheck",
+		Summary:          "Report service and dependency health",
+		Tag:              "health",
+		Response:         api.HealthResponse{},
+		ResponseStatuses: []int{503},
+	},
+	"GET /openapi.json": {
+		Id:       "getOpenApiDocument",
+		Summary:  "Get this OpenAPI document",
+		Tag:      "meta",
+		Response: map[string]any{},
+	},
+	"GET /v1/user": {
+		Id:      "getAllUsers",
+		Summary: "List users a page at a time, optionally filtered by the query parameters",
+		Tag:     "user",
+		Scope:   auth.ScopeUserRead,
+		Query: []QueryParam{
+			{Name: "name", Description: "Name to search for, compared normalized.", Type: "string"},
+			{Name: "match", Description: "How name is matched: prefix (default) or contains.", Type: "string"},
+			{Name: "trackingStatus", Description: "ENABLED or DISABLED.", Type: "string"},
+			{Name: "accountType", Description: "Account type, e.g. IRONMAN.", Type: "string"},
+			{Name: "createdAfter", Description: "Only users created after this time in unix milliseconds.", Type: "integer"},
+			{Name: "sort", Description: "name (default), -name, createdAt or -createdAt.", Type: "string"},
+			{Name: "limit", Description: "Maximum number of users per page, default 100 and at most 1000.", Type: "integer"},
+			{Name: "cursor", Description: "nextCursor of the previous page.", Type: "string"},
+		},
+		Response: api.GetAllUsersResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.BadRequest, service_error.Internal},
+	},
+	"GET /v1/user/{id}": {
+		Id:       "getUserById",
+		Summary:  "Get a user by id",
+		Tag:      "user",
+		Scope:    auth.ScopeUserRead,
+		Response: api.GetUserByIdResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.UserNotFound, service_error.Internal},
+	},
+	"GET /v1/user/name/{name}": {
+		Id:       "getUserByName",
+		Summary:  "Get a user by their current or a past runescape name",
+		Tag:      "user",
+		Scope:    auth.ScopeUserRead,
+		Response: api.GetUserByNameResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.UserNotFound, service_error.Internal},
+	},
+	"POST /v1/user": {
+		Id:       "createUser",
+		Summary:  "Create a user",
+		Tag:      "user",
+		Scope:    auth.ScopeUserWrite,
+		Request:  api.CreateUserRequest{},
+		Response: api.CreateUserResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.BadRequest, service_error.InvalidUser, service_error.RunescapeNameAlreadyTracked, service_error.Internal},
+	},
+	"PUT /v1/user": {
+		Id:       "updateUser",
+		Summary:  "Update a user",
+		Tag:      "user",
+		Scope:    auth.ScopeUserWrite,
+		Request:  api.UpdateUserRequest{},
+		Response: api.UpdateUserResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.BadRequest, service_error.InvalidUser, service_error.UserNotFound, service_error.RunescapeNameAlreadyTracked, service_error.Internal},
+	},
+	"POST /v1/user/{id}/rename": {
+		Id:       "renameUser",
+		Summary:  "Rename a user, keeping the old name as an alias",
+		Tag:      "user",
+		Scope:    auth.ScopeUserWrite,
+		Request:  api.RenameUserRequest{},
+		Response: api.RenameUserResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.BadRequest, service_error.InvalidUser, service_error.UserNotFound, service_error.RunescapeNameAlreadyTracked, service_error.Internal},
+	},
+	"POST /v1/user/{id}/account-type": {
+		Id:       "reportAccountType",
+		Summary:  "Report the account type a check found a user to have",
+		Tag:      "user",
+		Scope:    auth.ScopeUserWrite,
+		Request:  api.ReportAccountTypeRequest{},
+		Response: api.ReportAccountTypeResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.BadRequest, service_error.InvalidUser, service_error.UserNotFound, service_error.Internal},
+	},
+	"POST /v1/user/{id}/account-type/check": {
+		Id:       "checkAccountType",
+		Summary:  "Detect the account type of a user now and record it if it changed",
+		Tag:      "user",
+		Scope:    auth.ScopeUserWrite,
+		Response: api.ReportAccountTypeResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.InvalidUser, service_error.UserNotFound, service_error.AccountTypeUndetected, service_error.Internal},
+	},
+	"DELETE /v1/user/{id}": {
+		Id:       "deleteUser",
+		Summary:  "Soft delete a user, stopping tracking and hiding them from listings",
+		Tag:      "user",
+		Scope:    auth.ScopeUserWrite,
+		Response: api.DeleteUserResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.InvalidUser, service_error.UserNotFound, service_error.Internal},
+	},
+	"GET /v1/snapshot/{userId}": {
+		Id:       "getAllSnapshotsForUser",
+		Summary:  "List every snapshot of a user",
+		Tag:      "snapshot",
+		Scope:    auth.ScopeSnapshotRead,
+		Response: api.GetAllSnapshotsForUser{},
+		Errors:   []hz_service_error.ServiceError{service_error.Internal},
+	},
+	"GET /v1/snapshot/{userId}/nearest/{timestamp}": {
+		Id:       "getSnapshotNearestTimestamp",
+		Summary:  "Get the snapshot of a user nearest to a unix millisecond timestamp",
+		Tag:      "snapshot",
+		Scope:    auth.ScopeSnapshotRead,
+		Response: api.GetSnapshotNearestTimestampResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.BadRequest, service_error.SnapshotNotFound, service_error.Internal},
+	},
+	"POST /v1/snapshot": {
+		Id:       "createSnapshot",
+		Summary:  "Create a snapshot and its delta",
+		Tag:      "snapshot",
+		Scope:    auth.ScopeSnapshotWrite,
+		Request:  api.CreateSnapshotRequest{},
+		Response: api.CreateSnapshotResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.BadRequest, service_error.InvalidSnapshot, service_error.Internal},
+	},
+	"POST /v1/snapshot/interval": {
+		Id:       "getSnapshotInterval",
+		Summary:  "Get the snapshots of a user in a time range, aggregated by window",
+		Tag:      "snapshot",
+		Scope:    auth.ScopeSnapshotRead,
+		Request:  api.GetSnapshotIntervalRequest{},
+		Response: api.GetSnapshotIntervalResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.BadRequest, service_error.Internal},
+	},
+	"POST /v1/summary/delta": {
+		Id:             "getSnapshotWithDeltas",
+		Summary:        "Get the snapshot at the start of a time range and every delta in it",
+		Tag:            "snapshot",
+		Scope:          auth.ScopeSnapshotRead,
+		Request:        api.GetSnapshotWithDeltasRequest{},
+		Response:       api.GetSnapshotWithDeltasResponse{},
+		BinaryResponse: true,
+		Errors:         []hz_service_error.ServiceError{service_error.BadRequest, service_error.SnapshotNotFound, service_error.Internal},
+	},
+	"GET /v1/delta/{userId}/latest": {
+		Id:       "getLatestDelta",
+		Summary:  "Get the latest delta of a user",
+		Tag:      "delta",
+		Scope:    auth.ScopeSnapshotRead,
+		Response: api.GetLatestDeltaResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.DeltaNotFound, service_error.Internal},
+	},
+	"POST /v1/delta/interval": {
+		Id:       "getDeltaInterval",
+		Summary:  "Get the deltas of a user in a time range",
+		Tag:      "delta",
+		Scope:    auth.ScopeSnapshotRead,
+		Request:  api.GetDeltaIntervalRequest{},
+		Response: api.GetDeltaIntervalResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.BadRequest, service_error.Internal},
+	},
+	"POST /v1/delta/summary": {
+		Id:       "getDeltaSummary",
+		Summary:  "Get the summed gains of a user in a time range",
+		Tag:      "delta",
+		Scope:    auth.ScopeSnapshotRead,
+		Request:  api.GetDeltaSummaryRequest{},
+		Response: api.GetDeltaSummaryResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.BadRequest, service_error.Internal},
+	},
+	"GET /v1/group": {
+		Id:       "getAllGroups",
+		Summary:  "List all groups",
+		Tag:      "group",
+		Scope:    auth.ScopeUserRead,
+		Response: api.GetAllGroupsResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.Internal},
+	},
+	"GET /v1/group/{id}": {
+		Id:       "getGroupById",
+		Summary:  "Get a group with its membership history",
+		Tag:      "group",
+		Scope:    auth.ScopeUserRead,
+		Response: api.GetGroupResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.GroupNotFound, service_error.Internal},
+	},
+	"POST /v1/group": {
+		Id:       "createGroup",
+		Summary:  "Create an empty group",
+		Tag:      "group",
+		Scope:    auth.ScopeUserWrite,
+		Request:  api.CreateGroupRequest{},
+		Response: api.CreateGroupResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.BadRequest, service_error.InvalidGroup, service_error.GroupNameTaken, service_error.Internal},
+	},
+	"PUT /v1/group/{id}": {
+		Id:       "updateGroup",
+		Summary:  "Rename a group",
+		Tag:      "group",
+		Scope:    auth.ScopeUserWrite,
+		Request:  api.UpdateGroupRequest{},
+		Response: api.UpdateGroupResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.BadRequest, service_error.GroupNotFound, service_error.InvalidGroup, service_error.GroupNameTaken, service_error.Internal},
+	},
+	"DELETE /v1/group/{id}": {
+		Id:       "deleteGroup",
+		Summary:  "Delete a group; its members' users are kept",
+		Tag:      "group",
+		Scope:    auth.ScopeUserWrite,
+		Response: api.DeleteGroupResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.GroupNotFound, service_error.Internal},
+	},
+	"POST /v1/group/{id}/member": {
+		Id:       "addGroupMember",
+		Summary:  "Add a user to a group, starting a new membership",
+		Tag:      "group",
+		Scope:    auth.ScopeUserWrite,
+		Request:  api.AddGroupMemberRequest{},
+		Response: api.AddGroupMemberResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.BadRequest, service_error.GroupNotFound, service_error.InvalidGroup, service_error.Internal},
+	},
+	"PUT /v1/group/{id}/member/{userId}": {
+		Id:       "updateGroupMember",
+		Summary:  "Change the role of a member",
+		Tag:      "group",
+		Scope:    auth.ScopeUserWrite,
+		Request:  api.UpdateGroupMemberRequest{},
+		Response: api.UpdateGroupMemberResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.BadRequest, service_error.GroupNotFound, service_error.InvalidGroup, service_error.Internal},
+	},
+	"DELETE /v1/group/{id}/member/{userId}": {
+		Id:      "removeGroupMember",
+		Summary: "End the membership of a user; gains made until they left still count",
+		Tag:     "group",
+		Scope:   auth.ScopeUserWrite,
+		Query: []QueryParam{
+			{Name: "leftAt", Description: "When the user left, in unix milliseconds. Defaults to now.", Type: "integer"},
+		},
+		Response: api.RemoveGroupMemberResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.BadRequest, service_error.GroupNotFound, service_error.InvalidGroup, service_error.Internal},
+	},
+	"POST /v1/group/gains": {
+		Id:       "getGroupGains",
+		Summary:  "Get the summed gains of a group's members in a time range, counting only while they were members",
+		Tag:      "group",
+		Scope:    auth.ScopeUserRead,
+		Request:  api.GetGroupGainsRequest{},
+		Response: api.GetGroupGainsResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.BadRequest, service_error.GroupNotFound, service_error.InvalidGroup, service_error.Internal},
+	},
+	"GET /v1/worker/snapshot/on-demand/{userId}": {
+		Id:       "generateSnapshotOnDemand",
+		Summary:  "Fetch the hiscores of a user now and store the snapshot",
+		Tag:      "worker",
+		Scope:    auth.ScopeWorkerTrigger,
+		Response: api.GenerateSnapshotOnDemandResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.InvalidUser, service_error.HiscoreTimeout, service_error.WorkerUnavailable, service_error.Internal},
+	},
+	"POST /v1/worker/jobs": {
+		Id:       "createSnapshotJob",
+		Summary:  "Queue an on-demand snapshot of a user, optionally notifying a webhook when it finishes",
+		Tag:      "worker",
+		Scope:    auth.ScopeWorkerTrigger,
+		Request:  api.CreateSnapshotJobRequest{},
+		Response: api.CreateSnapshotJobResponse{},
+		Status:   http.StatusAccepted,
+		Errors:   []hz_service_error.ServiceError{service_error.BadRequest, service_error.InvalidJob, service_error.Internal},
+	},
+	"GET /v1/worker/jobs/{id}": {
+		Id:       "getWorkerJob",
+		Summary:  "Get the status of a worker job",
+		Tag:      "worker",
+		Scope:    auth.ScopeWorkerTrigger,
+		Response: api.GetWorkerJobResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.JobNotFound, service_error.Internal},
+	},
+	"GET /v1/admin/token": {
+		Id:       "getAllTokens",
+		Summary:  "List API tokens",
+		Tag:      "admin",
+		Scope:    auth.ScopeAdmin,
+		Response: api.GetAllTokensResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.Internal},
+	},
+	"POST /v1/admin/token": {
+		Id:       "issueToken",
+		Summary:  "Issue an API token",
+		Tag:      "admin",
+		Scope:    auth.ScopeAdmin,
+		Request:  api.IssueTokenRequest{},
+		Response: api.IssueTokenResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.BadRequest, service_error.InvalidToken, service_error.Internal},
+	},
+	"POST /v1/admin/token/{id}/rotate": {
+		Id:       "rotateToken",
+		Summary:  "Replace the secret of an API token",
+		Tag:      "admin",
+		Scope:    auth.ScopeAdmin,
+		Response: api.RotateTokenResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.TokenNotFound, service_error.InvalidToken, service_error.Internal},
+	},
+	"POST /v1/admin/token/{id}/revoke": {
+		Id:       "revokeToken",
+		Summary:  "Revoke an API token",
+		Tag:      "admin",
+		Scope:    auth.ScopeAdmin,
+		Response: api.RevokeTokenResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.TokenNotFound, service_error.Internal},
+	},
+	"POST /v1/admin/user/merge": {
+		Id:       "mergeUsers",
+		Summary:  "Merge the snapshots and names of one user into another, or report what a merge would do",
+		Tag:      "admin",
+		Scope:    auth.ScopeAdmin,
+		Request:  api.MergeUsersRequest{},
+		Response: api.MergeUsersResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.BadRequest, service_error.InvalidUser, service_error.UserNotFound, service_error.Internal},
+	},
+	"DELETE /v1/admin/user/{id}": {
+		Id:       "deleteUserPermanently",
+		Summary:  "Permanently delete a user with their snapshots, deltas, jobs and merged users",
+		Tag:      "admin",
+		Scope:    auth.ScopeAdmin,
+		Response: api.DeleteUserPermanentlyResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.InvalidUser, service_error.UserNotFound, service_error.Internal},
+	},
+	"GET /v1/admin/user/{id}/export": {
+		Id:       "exportUser",
+		Summary:  "Export everything held about a user",
+		Tag:      "admin",
+		Scope:    auth.ScopeAdmin,
+		Response: api.ExportUserResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.UserNotFound, service_error.Internal},
+	},
+	"GET /v1/admin/audit": {
+		Id:      "getAuditRecords",
+		Summary: "Query the audit log, newest first",
+		Tag:     "admin",
+		Scope:   auth.ScopeAdmin,
+		Query: []QueryParam{
+			{Name: "actor", Description: "Token name that made the change.", Type: "string"},
+			{Name: "entityType", Description: "Type of the changed entity, e.g. user or snapshot.", Type: "string"},
+			{Name: "entityId", Description: "Id of the changed entity.", Type: "string"},
+			{Name: "start", Description: "Earliest record time in unix milliseconds.", Type: "integer"},
+			{Name: "end", Description: "Latest record time in unix milliseconds.", Type: "integer"},
+			{Name: "limit", Description: "Maximum number of records, at most 1000.", Type: "integer"},
+		},
+		Response: api.GetAuditRecordsResponse{},
+		Errors:   []hz_service_error.ServiceError{service_error.BadRequest, service_error.Internal},
+	},
+}
@@ -0,0 +1,166 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// enums lists the allowed values of the named string types in pkg/api.
+var enums = map[reflect.Type][]string{
+	reflect.TypeOf(api.ActivityType("")):      stringValues(api.AllActivityTypes),
+	reflect.TypeOf(api.AccountType("")):       stringValues(api.AllAccountTypes),
+	reflect.TypeOf(api.AccountTypeSource("")): stringValues(api.AllAccountTypeSources),
+	reflect.TypeOf(api.TrackingStatus("")):    stringValues(api.AllTrackingStatuses),
+	reflect.TypeOf(api.AggregationWindow("")): stringValues(api.AllAggregationWindows),
+	reflect.TypeOf(api.GroupRole("")):         stringValues(api.AllGroupRoles),
+}
+
+func stringValues[T ~string](values []T) []string {
+	s := make([]string, len(values))
+	for i := range values {
+		s[i] = string(values[i])
+	}
+	return s
+}
+
+// schemaRegistry reflects Go types into schemas, registering every named struct as a component.
+type schemaRegistry struct {
+	schemas map[string]*Schema
+	types   map[string]reflect.Type
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{
+		schemas: make(map[string]*Schema),
+		types:   make(map[string]reflect.Type),
+	}
+}
+
+func (sr *schemaRegistry) schemaFor(t reflect.Type) (*Schema, error) {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}, nil
+	case t == rawMessageType:
+		return &Schema{Description: "Any JSON value."}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		s, err := sr.schemaFor(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		if s.Ref != "" {
+			return s, nil
+		}
+		s.Nullable = true
+		return s, nil
+	case reflect.String:
+		return &Schema{Type: "string", Enum: enums[t]}, nil
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}, nil
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}, nil
+	case reflect.Interface:
+		return &Schema{Description: "Any JSON value."}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}, nil
+		}
+		items, err := sr.schemaFor(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s", t.Key())
+		}
+		values, err := sr.schemaFor(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		return sr.structRef(t)
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
+
+func (sr *schemaRegistry) structRef(t reflect.Type) (*Schema, error) {
+	name := t.Name()
+	if name == "" {
+		return nil, fmt.Errorf("anonymous struct types are not supported")
+	}
+
+	ref := &Schema{Ref: "#/components/schemas/" + name}
+	if existing, ok := sr.types[name]; ok {
+		if existing != t {
+			return nil, fmt.Errorf("schema name %s is used by both %s and %s", name, existing, t)
+		}
+		return ref, nil
+	}
+
+	// Registered before the fields are walked so recursive types terminate.
+	sr.types[name] = t
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	sr.schemas[name] = schema
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		jsonName, omitEmpty, skip := parseJsonTag(field)
+		if skip {
+			continue
+		}
+
+		fieldSchema, err := sr.schemaFor(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", name, field.Name, err)
+		}
+		schema.Properties[jsonName] = fieldSchema
+
+		if !omitEmpty && field.Type.Kind() != reflect.Pointer {
+			schema.Required = append(schema.Required, jsonName)
+		}
+	}
+
+	return ref, nil
+}
+
+func parseJsonTag(field reflect.StructField) (name string, omitEmpty bool, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	for _, option := range parts[1:] {
+		if option == "omitempty" || option == "omitzero" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty, false
+}
@@ -0,0 +1,9 @@
+package openapi
+
+import _ "embed"
+
+// Spec is the committed OpenAPI document served at /openapi.json. Regenerate it with
+// go test ./src/internal/rest/openapi -update after changing routes or pkg/api types.
+//
+//go:embed openapi.json
+var Spec []byte
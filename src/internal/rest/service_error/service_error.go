@@ -12,6 +12,18 @@ var InvalidSnapshot = hz_service_error.ServiceError{Code: api.ErrorCodeInvalidSn
 var SnapshotNotFound = hz_service_error.ServiceError{Code: api.ErrorCodeSnapshotNotFound, Status: http.StatusNotFound}
 var DeltaNotFound = hz_service_error.ServiceError{Code: api.ErrorCodeDeltaNotFound, Status: http.StatusNotFound}
 var UserNotFound = hz_service_error.ServiceError{Code: api.ErrorCodeUserNotFound, Status: http.StatusNotFound}
+var InvalidUser = hz_service_error.ServiceError{Code: api.ErrorCodeInvalidUser, Status: http.StatusBadRequest}
 var RunescapeNameAlreadyTracked = hz_service_error.ServiceError{Code: api.ErrorCodeRunescapeNameAlreadyTracked, Status: http.StatusBadRequest}
 var HiscoreTimeout = hz_service_error.ServiceError{Code: api.ErrorCodeHiscoreTimeout, Status: http.StatusRequestTimeout}
 var Unauthorized = hz_service_error.ServiceError{Code: api.ErrorCodeUnauthorized, Status: http.StatusUnauthorized}
+var Forbidden = hz_service_error.ServiceError{Code: api.ErrorCodeForbidden, Status: http.StatusForbidden}
+var TokenNotFound = hz_service_error.ServiceError{Code: api.ErrorCodeTokenNotFound, Status: http.StatusNotFound}
+var InvalidToken = hz_service_error.ServiceError{Code: api.ErrorCodeInvalidToken, Status: http.StatusBadRequest}
+var RateLimited = hz_service_error.ServiceError{Code: api.ErrorCodeRateLimited, Status: http.StatusTooManyRequests}
+var JobNotFound = hz_service_error.ServiceError{Code: api.ErrorCodeJobNotFound, Status: http.StatusNotFound}
+var InvalidJob = hz_service_error.ServiceError{Code: api.ErrorCodeInvalidJob, Status: http.StatusBadRequest}
+var WorkerUnavailable = hz_service_error.ServiceError{Code: api.ErrorCodeWorkerUnavailable, Status: http.StatusServiceUnavailable}
+var AccountTypeUndetected = hz_service_error.ServiceError{Code: api.ErrorCodeAccountTypeUndetected, Status: http.StatusUnprocessableEntity}
+var GroupNotFound = hz_service_error.ServiceError{Code: api.ErrorCodeGroupNotFound, Status: http.StatusNotFound}
+var InvalidGroup = hz_service_error.ServiceError{Code: api.ErrorCodeInvalidGroup, Status: http.StatusBadRequest}
+var GroupNameTaken = hz_service_error.ServiceError{Code: api.ErrorCodeGroupNameTaken, Status: http.StatusBadRequest}
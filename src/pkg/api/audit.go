@@ -0,0 +1,28 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditRecord struct {
+	Id         string        `json:"id"`
+	Timestamp  time.Time     `json:"timestamp"`
+	Actor      string        `json:"actor"`
+	Caller     string        `json:"caller,omitempty"`
+	RequestId  string        `json:"requestId,omitempty"`
+	Action     string        `json:"action"`
+	EntityType string        `json:"entityType"`
+	EntityId   string        `json:"entityId"`
+	Changes    []FieldChange `json:"changes"`
+}
+
+type FieldChange struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+type GetAuditRecordsResponse struct {
+	Records []AuditRecord `json:"records"`
+}
@@ -7,7 +7,44 @@ const (
 	ErrorCodeSnapshotNotFound            = "SNAPSHOT_NOT_FOUND"
 	ErrorCodeDeltaNotFound               = "DELTA_NOT_FOUND"
 	ErrorCodeUserNotFound                = "USER_NOT_FOUND"
+	ErrorCodeInvalidUser                 = "INVALID_USER"
 	ErrorCodeRunescapeNameAlreadyTracked = "RUNESCAPE_NAME_ALREADY_TRACKED"
 	ErrorCodeHiscoreTimeout              = "OSRS_HISCORE_TIMEOUT"
 	ErrorCodeUnauthorized                = "UNAUTHORIZED"
+	ErrorCodeForbidden                   = "FORBIDDEN"
+	ErrorCodeTokenNotFound               = "TOKEN_NOT_FOUND"
+	ErrorCodeInvalidToken                = "INVALID_TOKEN"
+	ErrorCodeRateLimited                 = "RATE_LIMITED"
+	ErrorCodeJobNotFound                 = "JOB_NOT_FOUND"
+	ErrorCodeInvalidJob                  = "INVALID_JOB"
+	ErrorCodeWorkerUnavailable           = "WORKER_UNAVAILABLE"
+	ErrorCodeAccountTypeUndetected       = "ACCOUNT_TYPE_UNDETECTED"
+	ErrorCodeGroupNotFound               = "GROUP_NOT_FOUND"
+	ErrorCodeInvalidGroup                = "INVALID_GROUP"
+	ErrorCodeGroupNameTaken              = "GROUP_NAME_TAKEN"
 )
+
+// AllErrorCodes lists every error code the API can return.
+var AllErrorCodes = []string{
+	ErrorCodeInternal,
+	ErrorCodeBadRequest,
+	ErrorCodeInvalidSnapshot,
+	ErrorCodeSnapshotNotFound,
+	ErrorCodeDeltaNotFound,
+	ErrorCodeUserNotFound,
+	ErrorCodeInvalidUser,
+	ErrorCodeRunescapeNameAlreadyTracked,
+	ErrorCodeHiscoreTimeout,
+	ErrorCodeUnauthorized,
+	ErrorCodeForbidden,
+	ErrorCodeTokenNotFound,
+	ErrorCodeInvalidToken,
+	ErrorCodeRateLimited,
+	ErrorCodeJobNotFound,
+	ErrorCodeInvalidJob,
+	ErrorCodeWorkerUnavailable,
+	ErrorCodeAccountTypeUndetected,
+	ErrorCodeGroupNotFound,
+	ErrorCodeInvalidGroup,
+	ErrorCodeGroupNameTaken,
+}
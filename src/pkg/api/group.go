@@ -0,0 +1,127 @@
+package api
+
+import "time"
+
+type GroupRole string
+
+const (
+	GroupRoleOwner  GroupRole = "OWNER"
+	GroupRoleAdmin  GroupRole = "ADMIN"
+	GroupRoleMember GroupRole = "MEMBER"
+)
+
+var AllGroupRoles = []GroupRole{
+	GroupRoleOwner,
+	GroupRoleAdmin,
+	GroupRoleMember,
+}
+
+// Group is a set of users sharing a dashboard, such as a clan.
+type Group struct {
+	Id        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Memberships lists every stay of every member, oldest first. A user who left and rejoined
+	// has a membership per stay.
+	Memberships []GroupMembership `json:"memberships"`
+}
+
+type GroupMembership struct {
+	UserId   string     `json:"userId"`
+	Role     GroupRole  `json:"role"`
+	JoinedAt time.Time  `json:"joinedAt"`
+	LeftAt   *time.Time `json:"leftAt,omitempty"`
+}
+
+// ActiveMembers returns the memberships of the users currently in the group.
+func (g Group) ActiveMembers() []GroupMembership {
+	var active []GroupMembership
+	for _, m := range g.Memberships {
+		if m.LeftAt == nil {
+			active = append(active, m)
+		}
+	}
+	return active
+}
+
+type GetAllGroupsResponse struct {
+	Groups []Group `json:"groups"`
+}
+
+type GetGroupResponse struct {
+	Group Group `json:"group"`
+}
+
+type CreateGroupRequest struct {
+	Name string `json:"name"`
+}
+
+type CreateGroupResponse struct {
+	Group Group `json:"group"`
+}
+
+type UpdateGroupRequest struct {
+	Name string `json:"name"`
+}
+
+type UpdateGroupResponse struct {
+	Group Group `json:"group"`
+}
+
+type DeleteGroupResponse struct {
+	Group Group `json:"group"`
+}
+
+// AddGroupMemberRequest adds a user to a group. JoinedAt defaults to now; set it to backfill the
+// members of an existing clan.
+type AddGroupMemberRequest struct {
+	UserId   string     `json:"userId"`
+	Role     GroupRole  `json:"role"`
+	JoinedAt *time.Time `json:"joinedAt,omitempty"`
+}
+
+type AddGroupMemberResponse struct {
+	Group Group `json:"group"`
+}
+
+type UpdateGroupMemberRequest struct {
+	Role GroupRole `json:"role"`
+}
+
+type UpdateGroupMemberResponse struct {
+	Group Group `json:"group"`
+}
+
+type RemoveGroupMemberResponse struct {
+	Group Group `json:"group"`
+}
+
+// GetGroupGainsRequest asks for the gains of a group's members between StartTime and EndTime.
+// Limit caps TopContributors and defaults to 10.
+type GetGroupGainsRequest struct {
+	GroupId   string    `json:"groupId"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+	Limit     int       `json:"limit"`
+}
+
+// GetGroupGainsResponse sums the gains members made while they were in the group. MemberCount
+// counts the users who were a member at any point of the interval.
+type GetGroupGainsResponse struct {
+	GroupId             string                 `json:"groupId"`
+	StartTime           time.Time              `json:"startTime"`
+	EndTime             time.Time              `json:"endTime"`
+	TotalExperienceGain int                    `json:"totalExperienceGain"`
+	Skills              []SkillDeltaSummary    `json:"skills"`
+	Bosses              []BossDeltaSummary     `json:"bosses"`
+	Activities          []ActivityDeltaSummary `json:"activities"`
+	TopContributors     []GroupContributor     `json:"topContributors"`
+	MemberCount         int                    `json:"memberCount"`
+}
+
+// GroupContributor is a member's overall experience gained while in the group.
+type GroupContributor struct {
+	UserId         string `json:"userId"`
+	RunescapeName  string `json:"runescapeName"`
+	ExperienceGain int    `json:"experienceGain"`
+}
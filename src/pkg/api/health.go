@@ -0,0 +1,25 @@
+package api
+
+const (
+	HealthStatusHealthy   = "healthy"
+	HealthStatusUnhealthy = "unhealthy"
+	HealthStatusDegraded  = "degraded"
+)
+
+// DependencyStatus represents the health status of a single dependency
+type DependencyStatus struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"` // "healthy", "unhealthy", "degraded"
+	Latency string `json:"latency,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// HealthResponse is the complete health check response
+type HealthResponse struct {
+	Status       string             `json:"status"` // "healthy", "unhealthy", "degraded"
+	Environment  string             `json:"environment"`
+	Commit       string             `json:"commit"`
+	BuildTime    string             `json:"buildTime"`
+	Timestamp    string             `json:"timestamp"`
+	Dependencies []DependencyStatus `json:"dependencies"`
+}
@@ -10,6 +10,12 @@ const (
 	AggregationWindowMonthly AggregationWindow = "monthly"
 )
 
+var AllAggregationWindows = []AggregationWindow{
+	AggregationWindowDaily,
+	AggregationWindowWeekly,
+	AggregationWindowMonthly,
+}
+
 type HiscoreSnapshot struct {
 	Id         string             `json:"id"`
 	UserId     string             `json:"userId"`
@@ -55,6 +61,10 @@ type GetSnapshotNearestTimestampResponse struct {
 }
 type GetAllSnapshotsForUser struct {
 	Snapshots []HiscoreSnapshot `json:"snapshots"`
+	// AccountType and AccountTypeHistory give the account type of a tracked user over time, for
+	// use with AccountTypeAt. They are left out for snapshots of users that are not tracked.
+	AccountType        AccountType         `json:"accountType,omitempty"`
+	AccountTypeHistory []AccountTypeChange `json:"accountTypeHistory,omitempty"`
 }
 
 type GetSnapshotIntervalRequest struct {
@@ -68,4 +78,7 @@ type GetSnapshotIntervalResponse struct {
 	Snapshots          []HiscoreSnapshot `json:"snapshots"`
 	TotalSnapshots     int               `json:"totalSnapshots"`
 	SnapshotsWithGains int               `json:"snapshotsWithGains"`
+	// AccountType and AccountTypeHistory are as in GetAllSnapshotsForUser.
+	AccountType        AccountType         `json:"accountType,omitempty"`
+	AccountTypeHistory []AccountTypeChange `json:"accountTypeHistory,omitempty"`
 }
@@ -0,0 +1,40 @@
+package api
+
+import "time"
+
+type Token struct {
+	Id         string     `json:"id"`
+	Name       string     `json:"name"`
+	Owner      string     `json:"owner,omitempty"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+type GetAllTokensResponse struct {
+	Tokens []Token `json:"tokens"`
+}
+
+type IssueTokenRequest struct {
+	Name      string     `json:"name"`
+	Owner     string     `json:"owner"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// IssueTokenResponse carries the plaintext secret. It is only returned once and cannot be recovered.
+type IssueTokenResponse struct {
+	Token  Token  `json:"token"`
+	Secret string `json:"secret"`
+}
+
+type RotateTokenResponse struct {
+	Token  Token  `json:"token"`
+	Secret string `json:"secret"`
+}
+
+type RevokeTokenResponse struct {
+	Token Token `json:"token"`
+}
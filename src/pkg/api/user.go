@@ -1,5 +1,10 @@
 package api
 
+import (
+	"strings"
+	"time"
+)
+
 type TrackingStatus string
 
 const (
@@ -7,6 +12,11 @@ const (
 	TrackingStatusDisabled TrackingStatus = "DISABLED"
 )
 
+var AllTrackingStatuses = []TrackingStatus{
+	TrackingStatusEnabled,
+	TrackingStatusDisabled,
+}
+
 func TrackingStatusFromValue(value string) TrackingStatus {
 	if value == string(TrackingStatusEnabled) {
 		return TrackingStatusEnabled
@@ -29,6 +39,14 @@ const (
 	AccountTypeGroupIronman    AccountType = "GROUP_IRONMAN"
 )
 
+var AllAccountTypes = []AccountType{
+	AccountTypeNormal,
+	AccountTypeIronman,
+	AccountTypeHardcoreIronman,
+	AccountTypeUltimateIronman,
+	AccountTypeGroupIronman,
+}
+
 func AccountTypeFromValue(value string) AccountType {
 	if value == string(AccountTypeNormal) {
 		return AccountTypeNormal
@@ -58,6 +76,72 @@ type User struct {
 	RunescapeName  string         `json:"runescapeName"`
 	TrackingStatus TrackingStatus `json:"trackingStatus"`
 	AccountType    AccountType    `json:"accountType"`
+	NameHistory    []NameChange   `json:"nameHistory,omitempty"`
+	// AccountTypeHistory lists the account type changes of the user, oldest first.
+	AccountTypeHistory []AccountTypeChange `json:"accountTypeHistory,omitempty"`
+	// MergedInto is the id of the user this one was merged into. A merged user has no snapshots
+	// of its own and lookups by its name find the user it was merged into.
+	MergedInto string     `json:"mergedInto,omitempty"`
+	MergedAt   *time.Time `json:"mergedAt,omitempty"`
+	CreatedAt  *time.Time `json:"createdAt,omitempty"`
+	// DeletedAt is set once the user was deleted. Deleted users are no longer tracked or listed.
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+// NameChange records a rename. The old name stays an alias of the user, so lookups by it keep
+// finding them.
+type NameChange struct {
+	OldName   string    `json:"oldName"`
+	NewName   string    `json:"newName"`
+	ChangedAt time.Time `json:"changedAt"`
+}
+
+// AccountTypeSource is what caused an account type change.
+type AccountTypeSource string
+
+const (
+	// AccountTypeSourceManual is a change made by updating the user.
+	AccountTypeSourceManual AccountTypeSource = "MANUAL"
+	// AccountTypeSourceDetected is a change found by checking the account, e.g. when a player
+	// de-irons or a hardcore ironman loses their status.
+	AccountTypeSourceDetected AccountTypeSource = "DETECTED"
+)
+
+var AllAccountTypeSources = []AccountTypeSource{
+	AccountTypeSourceManual,
+	AccountTypeSourceDetected,
+}
+
+// AccountTypeChange records a change of account type. ChangedAt is when it was detected, which
+// may be some time after the player changed it in game.
+type AccountTypeChange struct {
+	From      AccountType       `json:"from"`
+	To        AccountType       `json:"to"`
+	ChangedAt time.Time         `json:"changedAt"`
+	Source    AccountTypeSource `json:"source"`
+}
+
+// AccountTypeAt returns the account type a user had at a time, given their current type and
+// their account type changes oldest first.
+func AccountTypeAt(current AccountType, changes []AccountTypeChange, at time.Time) AccountType {
+	for _, change := range changes {
+		if at.Before(change.ChangedAt) {
+			return change.From
+		}
+	}
+	return current
+}
+
+// NormalizeRunescapeName returns the form names are compared in. OSRS treats names that differ
+// only in case or in '_', '-' and spaces as the same name.
+func NormalizeRunescapeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' {
+			return ' '
+		}
+		return r
+	}, strings.ToLower(name))
+	return strings.Join(strings.Fields(name), " ")
 }
 
 func (u *User) IsTrackingEnabled() bool {
@@ -90,6 +174,8 @@ func (u *User) IsGroupIronman() bool {
 
 type GetAllUsersResponse struct {
 	Users []User `json:"users"`
+	// NextCursor is set when a search has more users. Pass it as cursor to get the next page.
+	NextCursor string `json:"nextCursor,omitempty"`
 }
 
 type GetUserByIdResponse struct {
@@ -109,8 +195,95 @@ type UpdateUserRequest struct {
 	Id             string         `json:"id"`
 	RunescapeName  string         `json:"runescapeName"`
 	TrackingStatus TrackingStatus `json:"trackingStatus"`
-	AccountType    AccountType    `json:"accountType"`
+	// AccountType is left unchanged when omitted.
+	AccountType AccountType `json:"accountType,omitempty"`
 }
 type UpdateUserResponse struct {
 	User User `json:"user"`
 }
+
+type GetUserByNameResponse struct {
+	User User `json:"user"`
+	// MatchedAlias is set when the name is a past name of the user rather than their current one.
+	MatchedAlias bool `json:"matchedAlias"`
+}
+
+type RenameUserRequest struct {
+	RunescapeName string `json:"runescapeName"`
+}
+type RenameUserResponse struct {
+	User User `json:"user"`
+}
+
+type MergeUsersRequest struct {
+	SourceUserId string `json:"sourceUserId"`
+	TargetUserId string `json:"targetUserId"`
+	// DryRun reports what the merge would do without changing anything.
+	DryRun bool `json:"dryRun"`
+}
+
+type MergeUsersResponse struct {
+	Report MergeReport `json:"report"`
+}
+
+// MergeReport describes a merge of the source user into the target. DeltasRemoved counts the
+// deltas of both users, which are replaced by the DeltasCreated over the combined snapshots.
+type MergeReport struct {
+	SourceUserId         string   `json:"sourceUserId"`
+	TargetUserId         string   `json:"targetUserId"`
+	DryRun               bool     `json:"dryRun"`
+	SourceSnapshots      int      `json:"sourceSnapshots"`
+	TargetSnapshots      int      `json:"targetSnapshots"`
+	SnapshotsMoved       int      `json:"snapshotsMoved"`
+	DuplicateSnapshotIds []string `json:"duplicateSnapshotIds"`
+	DeltasRemoved        int64    `json:"deltasRemoved"`
+	DeltasCreated        int      `json:"deltasCreated"`
+	MembershipsMoved     int      `json:"membershipsMoved"`
+	Target               User     `json:"target"`
+}
+
+type DeleteUserResponse struct {
+	User User `json:"user"`
+}
+
+// DeleteUserPermanentlyResponse counts what was removed along with the user.
+type DeleteUserPermanentlyResponse struct {
+	UserId             string `json:"userId"`
+	MergedUsersDeleted int64  `json:"mergedUsersDeleted"`
+	SnapshotsDeleted   int64  `json:"snapshotsDeleted"`
+	DeltasDeleted      int64  `json:"deltasDeleted"`
+	JobsDeleted        int64  `json:"jobsDeleted"`
+	MembershipsDeleted int64  `json:"membershipsDeleted"`
+}
+
+type ExportUserResponse struct {
+	Export UserExport `json:"export"`
+}
+
+// UserExport is everything held about a user: their record, the records of the users merged into
+// them, every snapshot, delta and worker job of any of them, and the audit records of changes
+// made to those. Groups holds the groups any of them were in, with only their memberships.
+type UserExport struct {
+	ExportedAt   time.Time         `json:"exportedAt"`
+	User         User              `json:"user"`
+	MergedUsers  []User            `json:"mergedUsers"`
+	Snapshots    []HiscoreSnapshot `json:"snapshots"`
+	Deltas       []HiscoreDelta    `json:"deltas"`
+	Jobs         []WorkerJob       `json:"jobs"`
+	Groups       []Group           `json:"groups"`
+	AuditRecords []AuditRecord     `json:"auditRecords"`
+}
+
+// ReportAccountTypeRequest reports the account type of a user as seen by a check, e.g. by the
+// worker reading the hiscores. DetectedAt defaults to now.
+type ReportAccountTypeRequest struct {
+	AccountType AccountType `json:"accountType"`
+	DetectedAt  *time.Time  `json:"detectedAt,omitempty"`
+}
+
+// ReportAccountTypeResponse returns the user after a report or check. Changed is set when the
+// account type changed; reports older than the last change are ignored.
+type ReportAccountTypeResponse struct {
+	User    User `json:"user"`
+	Changed bool `json:"changed"`
+}
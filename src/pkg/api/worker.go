@@ -1,5 +1,80 @@
 package api
 
+import "time"
+
+// GenerateSnapshotOnDemandResponse reports whether the snapshot was fetched for this request or
+// reused. Reused snapshots were taken within the cooldown; a new one can be fetched from
+// NextRefreshAt. Shared is set when the request joined a fetch already in flight for the user.
 type GenerateSnapshotOnDemandResponse struct {
-	Snapshot HiscoreSnapshot `json:"snapshot"`
+	Snapshot      HiscoreSnapshot `json:"snapshot"`
+	Reused        bool            `json:"reused"`
+	Shared        bool            `json:"shared"`
+	NextRefreshAt time.Time       `json:"nextRefreshAt"`
+}
+
+type WorkerJobStatus string
+
+const (
+	WorkerJobStatusQueued    WorkerJobStatus = "QUEUED"
+	WorkerJobStatusRunning   WorkerJobStatus = "RUNNING"
+	WorkerJobStatusSucceeded WorkerJobStatus = "SUCCEEDED"
+	WorkerJobStatusFailed    WorkerJobStatus = "FAILED"
+)
+
+var AllWorkerJobStatuses = []WorkerJobStatus{
+	WorkerJobStatusQueued,
+	WorkerJobStatusRunning,
+	WorkerJobStatusSucceeded,
+	WorkerJobStatusFailed,
+}
+
+// Done reports whether the job has reached a terminal status.
+func (s WorkerJobStatus) Done() bool {
+	return s == WorkerJobStatusSucceeded || s == WorkerJobStatusFailed
+}
+
+type WorkerJobType string
+
+const (
+	WorkerJobTypeSnapshotOnDemand WorkerJobType = "SNAPSHOT_ON_DEMAND"
+)
+
+// WorkerJob is an asynchronous worker operation. SnapshotId is set once the job succeeds, and
+// Error once it fails.
+type WorkerJob struct {
+	Id                 string          `json:"id"`
+	Type               WorkerJobType   `json:"type"`
+	UserId             string          `json:"userId"`
+	Status             WorkerJobStatus `json:"status"`
+	SnapshotId         string          `json:"snapshotId,omitempty"`
+	SnapshotReused     bool            `json:"snapshotReused,omitempty"`
+	Error              *WorkerJobError `json:"error,omitempty"`
+	WebhookUrl         string          `json:"webhookUrl,omitempty"`
+	Attempts           int             `json:"attempts"`
+	CreatedAt          time.Time       `json:"createdAt"`
+	StartedAt          *time.Time      `json:"startedAt,omitempty"`
+	FinishedAt         *time.Time      `json:"finishedAt,omitempty"`
+	WebhookDeliveredAt *time.Time      `json:"webhookDeliveredAt,omitempty"`
+}
+
+// WorkerJobError uses the same codes as error responses, e.g. OSRS_HISCORE_TIMEOUT.
+type WorkerJobError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// CreateSnapshotJobRequest enqueues an on-demand snapshot. When WebhookUrl is set, the finished
+// job is POSTed to it as a GetWorkerJobResponse. It must resolve to a public address, and
+// redirects are not followed.
+type CreateSnapshotJobRequest struct {
+	UserId     string `json:"userId"`
+	WebhookUrl string `json:"webhookUrl,omitempty"`
+}
+
+type CreateSnapshotJobResponse struct {
+	Job WorkerJob `json:"job"`
+}
+
+type GetWorkerJobResponse struct {
+	Job WorkerJob `json:"job"`
 }
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+)
+
+// Audit queries the audit log of mutating API calls. Requires a token with the admin scope.
+type Audit struct {
+	prefix    string
+	transport *transport
+}
+
+// AuditQuery filters audit records. Zero values are ignored.
+type AuditQuery struct {
+	Actor      string
+	EntityType string
+	EntityId   string
+	Start      time.Time
+	End        time.Time
+	Limit      int
+}
+
+func newAudit(t *transport) *Audit {
+	return &Audit{
+		prefix:    "admin/audit",
+		transport: t,
+	}
+}
+
+func (audit *Audit) GetAuditRecords(query AuditQuery) (api.GetAuditRecordsResponse, error) {
+	return audit.GetAuditRecordsContext(context.Background(), query)
+}
+
+func (audit *Audit) GetAuditRecordsContext(ctx context.Context, query AuditQuery, opts ...CallOption) (api.GetAuditRecordsResponse, error) {
+	params := url.Values{}
+	if query.Actor != "" {
+		params.Set("actor", query.Actor)
+	}
+	if query.EntityType != "" {
+		params.Set("entityType", query.EntityType)
+	}
+	if query.EntityId != "" {
+		params.Set("entityId", query.EntityId)
+	}
+	if !query.Start.IsZero() {
+		params.Set("start", strconv.FormatInt(query.Start.UnixMilli(), 10))
+	}
+	if !query.End.IsZero() {
+		params.Set("end", strconv.FormatInt(query.End.UnixMilli(), 10))
+	}
+	if query.Limit > 0 {
+		params.Set("limit", strconv.Itoa(query.Limit))
+	}
+
+	u := audit.getBaseUrl()
+	if len(params) > 0 {
+		u = fmt.Sprintf("%s?%s", u, params.Encode())
+	}
+
+	var response api.GetAuditRecordsResponse
+	err := audit.transport.do(ctx, call{
+		method:     http.MethodGet,
+		url:        u,
+		response:   &response,
+		idempotent: true,
+		opts:       opts,
+	})
+	if err != nil {
+		return api.GetAuditRecordsResponse{}, err
+	}
+	return response, nil
+}
+
+func (audit *Audit) getBaseUrl() string {
+	return audit.transport.v1Url(audit.prefix)
+}
@@ -0,0 +1,1102 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/audit"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/delta"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/group"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/health"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/hiscore"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/snapshot"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/token"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/user"
+	"github.com/ctfloyd/hazelmere-api/src/internal/core/worker"
+	"github.com/ctfloyd/hazelmere-api/src/internal/database"
+	"github.com/ctfloyd/hazelmere-api/src/internal/dependency/wom"
+	"github.com/ctfloyd/hazelmere-api/src/internal/dependency/wom/womtest"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/auth"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/middleware"
+	"github.com/ctfloyd/hazelmere-api/src/internal/foundation/monitor"
+	"github.com/ctfloyd/hazelmere-api/src/internal/initialize"
+	"github.com/ctfloyd/hazelmere-api/src/internal/rest/handler"
+	"github.com/ctfloyd/hazelmere-api/src/internal/rest/openapi"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/client"
+	"github.com/ctfloyd/hazelmere-commons/pkg/hz_logger"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	adminToken = "contract-admin"
+	readToken  = "contract-read"
+)
+
+// clientMethods maps every server route to the client method that calls it. The coverage test
+// fails when a route is added without a client method, or a method here no longer exists.
+var clientMethods = map[string]string{
+	"GET /health":               "Health.CheckContext",
+	"GET /openapi.json":         "OpenAPI.GetDocumentContext",
+	"GET /v1/user":              "User.GetAllUsersContext",
+	"GET /v1/user/{id}":         "User.GetUserByIdContext",
+	"POST /v1/user":             "User.CreateUserContext",
+	"PUT /v1/user":              "User.UpdateUserContext",
+	"GET /v1/user/name/{name}":  "User.GetUserByNameContext",
+	"POST /v1/user/{id}/rename": "User.RenameUserContext",
+	"GET /v1/snapshot/{userId}": "Snapshot.GetAllSnapshotsForUserContext",
+	"GET /v1/snapshot/{userId}/nearest/{timestamp}": "Snapshot.GetSnapshotForUserNearestTimestampContext",
+	"POST /v1/snapshot":                             "Snapshot.CreateSnapshotContext",
+	"POST /v1/snapshot/interval":                    "Snapshot.GetSnapshotIntervalContext",
+	"POST /v1/summary/delta":                        "Snapshot.GetSnapshotWithDeltasContext",
+	"GET /v1/delta/{userId}/latest":                 "Delta.GetLatestDeltaContext",
+	"POST /v1/delta/interval":                       "Delta.GetDeltaIntervalContext",
+	"POST /v1/delta/summary":                        "Delta.GetDeltaSummaryContext",
+	"GET /v1/worker/snapshot/on-demand/{userId}":    "Worker.GenerateSnapshotOnDemandContext",
+	"POST /v1/worker/jobs":                          "Worker.CreateSnapshotJobContext",
+	"GET /v1/worker/jobs/{id}":                      "Worker.GetJobContext",
+	"GET /v1/admin/token":                           "Token.GetAllTokensContext",
+	"POST /v1/admin/token":                          "Token.IssueTokenContext",
+	"POST /v1/admin/token/{id}/rotate":              "Token.RotateTokenContext",
+	"POST /v1/admin/token/{id}/revoke":              "Token.RevokeTokenContext",
+	"GET /v1/admin/audit":                           "Audit.GetAuditRecordsContext",
+	"POST /v1/admin/user/merge":                     "User.MergeUsersContext",
+	"DELETE /v1/user/{id}":                          "User.DeleteUserContext",
+	"DELETE /v1/admin/user/{id}":                    "User.DeleteUserPermanentlyContext",
+	"GET /v1/admin/user/{id}/export":                "User.ExportUserContext",
+	"POST /v1/user/{id}/account-type":               "User.ReportAccountTypeContext",
+	"POST /v1/user/{id}/account-type/check":         "User.CheckAccountTypeContext",
+	"GET /v1/group":                                 "Group.GetAllGroupsContext",
+	"GET /v1/group/{id}":                            "Group.GetGroupByIdContext",
+	"POST /v1/group":                                "Group.CreateGroupContext",
+	"PUT /v1/group/{id}":                            "Group.UpdateGroupContext",
+	"DELETE /v1/group/{id}":                         "Group.DeleteGroupContext",
+	"POST /v1/group/{id}/member":                    "Group.AddMemberContext",
+	"PUT /v1/group/{id}/member/{userId}":            "Group.UpdateMemberContext",
+	"DELETE /v1/group/{id}/member/{userId}":         "Group.RemoveMemberContext",
+	"POST /v1/group/gains":                          "Group.GetGroupGainsContext",
+}
+
+// fakeWorkerService stands in for hazelmere-worker: it stores a fixed snapshot, times out for
+// timeoutUserId, or fails fast as if the worker were down for unavailableUserId.
+type fakeWorkerService struct {
+	orchestrator      hiscore.HiscoreOrchestrator
+	timeoutUserId     string
+	unavailableUserId string
+}
+
+func (f *fakeWorkerService) GenerateSnapshotOnDemand(ctx context.Context, userId string) (worker.OnDemandResult, error) {
+	if userId == f.timeoutUserId {
+		return worker.OnDemandResult{}, worker.ErrHiscoreTimeout
+	}
+	if userId == f.unavailableUserId {
+		return worker.OnDemandResult{}, worker.ErrWorkerUnavailable
+	}
+	snap := snapshot.HiscoreSnapshot{}.FromAPI(newSnapshot(userId, time.Now().Add(-time.Minute), 5_000_000))
+	result, err := f.orchestrator.CreateSnapshotWithDelta(ctx, snap)
+	if err != nil {
+		return worker.OnDemandResult{}, err
+	}
+	return worker.OnDemandResult{Snapshot: result.Snapshot, NextRefreshAt: result.Snapshot.Timestamp}, nil
+}
+
+type contractServer struct {
+	router            *chi.Mux
+	server            *httptest.Server
+	jobRunner         *worker.JobRunner
+	timeoutUserId     string
+	unavailableUserId string
+}
+
+// newContractServer wires the real router and services the way cli/serve does, over in-memory
+// repositories.
+func newContractServer(t *testing.T) *contractServer {
+	t.Helper()
+
+	logger := hz_logger.NewZeroLogAdapater(hz_logger.LogLevelError)
+	mon := monitor.New(logger)
+
+	auditService := audit.NewAuditService(mon, audit.NewMemoryAuditRepository(mon))
+
+	userRepo := user.NewMemoryUserRepository(mon)
+	userService := user.NewUserService(mon, userRepo, user.NewUserValidator())
+
+	deltaService := delta.NewDeltaService(mon, delta.NewMemoryDeltaRepository(mon), delta.NewDeltaCache(), userRepo)
+	snapshotService := snapshot.NewSnapshotService(mon, snapshot.NewMemorySnapshotRepository(mon), snapshot.NewSnapshotValidator(), userRepo)
+	txManager := database.NewTransactionManager(nil, false)
+	orchestrator := hiscore.NewHiscoreOrchestrator(mon, snapshotService, deltaService, txManager)
+	groupService := group.NewGroupService(mon, group.NewMemoryGroupRepository(mon), group.NewGroupValidator(), userRepo, deltaService)
+	userMerger := hiscore.NewUserMerger(mon, userService, snapshotService, deltaService, groupService, txManager)
+	womServer := womtest.NewServer(t, womtest.Player{Username: "Hyger", Type: wom.PlayerTypeRegular})
+	accountTypeChecker := user.NewAccountTypeChecker(mon, userService, user.NewWomAccountTypeDetector(wom.NewClient(logger, womServer.Config())))
+
+	workerService := &fakeWorkerService{orchestrator: orchestrator, timeoutUserId: uuid.New().String(), unavailableUserId: uuid.New().String()}
+	jobRepo := worker.NewMemoryJobRepository(mon)
+	jobRunner := worker.NewJobRunner(mon, jobRepo, workerService, worker.DefaultJobRunnerConfig)
+	jobService := worker.NewJobService(mon, jobRepo, worker.NewJobValidator(), nil)
+	userLifecycle := hiscore.NewUserLifecycle(mon, userService, snapshotService, deltaService, auditService, jobService, groupService, txManager)
+	tokenService := token.NewTokenService(mon, token.NewMemoryTokenRepository(mon), token.NewTokenValidator())
+
+	authorizer := middleware.NewAuthorizer(true, []middleware.TokenDefinition{
+		{Name: "admin", Token: adminToken, Scopes: []auth.Scope{auth.ScopeAdmin}},
+		{Name: "read", Token: readToken, Scopes: []auth.Scope{auth.ScopeSnapshotRead}},
+	}, tokenService, mon)
+	rateLimiter := middleware.NewRateLimiter(false, middleware.RateLimit{}, nil, mon)
+
+	router := initialize.InitRouter(logger)
+	router.Use(authorizer.Identify)
+	router.Use(rateLimiter.Limit)
+	router.Use(middleware.NewIdempotency(mon).Replay)
+
+	handlers := handler.NewHandlers(mon, handler.Services{
+		Health:        health.NewService(nil, "", "contract"),
+		Snapshot:      snapshotService,
+		Orchestrator:  orchestrator,
+		User:          userService,
+		UserMerger:    userMerger,
+		UserLifecycle: userLifecycle,
+		AccountTypes:  accountTypeChecker,
+		Worker:        workerService,
+		Job:           jobService,
+		Delta:         deltaService,
+		Group:         groupService,
+		Token:         tokenService,
+		Audit:         auditService,
+	})
+	for _, h := range handlers {
+		h.RegisterRoutes(router, handler.ApiVersionV1, authorizer)
+	}
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return &contractServer{router: router, server: server, jobRunner: jobRunner, timeoutUserId: workerService.timeoutUserId, unavailableUserId: workerService.unavailableUserId}
+}
+
+func (cs *contractServer) client(t *testing.T, token string) *client.Hazelmere {
+	t.Helper()
+
+	h, err := client.New(cs.server.URL, client.HazelmereConfig{Token: token, CallingApplication: "contract-test"},
+		client.WithRetryPolicy(client.RetryPolicy{}))
+	if err != nil {
+		t.Fatalf("creating client: %v", err)
+	}
+	return h
+}
+
+// createUser creates a user for the tests that only need one to take snapshots of.
+func createUser(t *testing.T, h *client.Hazelmere, runescapeName string) string {
+	t.Helper()
+
+	created, err := h.User.CreateUserContext(context.Background(), api.CreateUserRequest{RunescapeName: runescapeName})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return created.User.Id
+}
+
+func newSnapshot(userId string, timestamp time.Time, overall int) api.HiscoreSnapshot {
+	snap := api.HiscoreSnapshot{UserId: userId, Timestamp: timestamp.UTC().Truncate(time.Millisecond)}
+	for _, at := range api.AllSkillActivityTypes {
+		experience := 1000
+		if at == api.ActivityTypeOverall {
+			experience = overall
+		}
+		snap.Skills = append(snap.Skills, api.SkillSnapshot{ActivityType: at, Name: string(at), Level: 10, Experience: experience})
+	}
+	for _, at := range api.AllBossActivityTypes {
+		snap.Bosses = append(snap.Bosses, api.BossSnapshot{ActivityType: at, Name: string(at), KillCount: overall / 1000})
+	}
+	for _, at := range api.AllActivityActivityTypes {
+		snap.Activities = append(snap.Activities, api.ActivitySnapshot{ActivityType: at, Name: string(at), Score: 1})
+	}
+	return snap
+}
+
+func TestClientCoversEveryRoute(t *testing.T) {
+	cs := newContractServer(t)
+	h := cs.client(t, adminToken)
+
+	routes, err := openapi.Routes(cs.router)
+	if err != nil {
+		t.Fatalf("walking routes: %v", err)
+	}
+
+	registered := make(map[string]bool)
+	for _, route := range routes {
+		key := route.Key()
+		registered[key] = true
+		if _, ok := clientMethods[key]; !ok {
+			t.Errorf("route %s has no client method; add one to pkg/client and to clientMethods", key)
+		}
+	}
+
+	for key, method := range clientMethods {
+		if !registered[key] {
+			t.Errorf("clientMethods lists %s, which is no longer a registered route", key)
+		}
+		resource, name, _ := strings.Cut(method, ".")
+		field := reflect.ValueOf(h).Elem().FieldByName(resource)
+		if !field.IsValid() || !field.MethodByName(name).IsValid() {
+			t.Errorf("client method %s for %s does not exist", method, key)
+		}
+	}
+}
+
+func TestContractUser(t *testing.T) {
+	cs := newContractServer(t)
+	h := cs.client(t, adminToken)
+	ctx := context.Background()
+
+	created, err := h.User.CreateUserContext(ctx, api.CreateUserRequest{RunescapeName: "zezima", TrackingStatus: api.TrackingStatusEnabled, AccountType: api.AccountTypeNormal})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	byId, err := h.User.GetUserByIdContext(ctx, created.User.Id)
+	if err != nil {
+		t.Fatalf("GetUserById: %v", err)
+	}
+	if !reflect.DeepEqual(byId.User, created.User) {
+		t.Errorf("GetUserById = %+v, want %+v", byId.User, created.User)
+	}
+
+	updated, err := h.User.UpdateUserContext(ctx, api.UpdateUserRequest{Id: created.User.Id, RunescapeName: "zezima", TrackingStatus: api.TrackingStatusDisabled, AccountType: api.AccountTypeIronman})
+	if err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if updated.User.AccountType != api.AccountTypeIronman || updated.User.TrackingStatus != api.TrackingStatusDisabled {
+		t.Errorf("UpdateUser = %+v, want ironman with tracking disabled", updated.User)
+	}
+
+	all, err := h.User.GetAllUsersContext(ctx)
+	if err != nil {
+		t.Fatalf("GetAllUsers: %v", err)
+	}
+	if len(all.Users) != 1 || !reflect.DeepEqual(all.Users[0], updated.User) {
+		t.Errorf("GetAllUsers = %+v, want [%+v]", all.Users, updated.User)
+	}
+
+	_, err = h.User.CreateUserContext(ctx, api.CreateUserRequest{RunescapeName: "zezima"})
+	if !errors.Is(err, client.ErrRunescapeNameAlreadyTracked) {
+		t.Errorf("CreateUser with a tracked name: got %v, want ErrRunescapeNameAlreadyTracked", err)
+	}
+
+	_, err = h.User.GetUserByIdContext(ctx, uuid.New().String())
+	if !errors.Is(err, client.ErrUserNotFound) {
+		t.Errorf("GetUserById of an unknown id: got %v, want ErrUserNotFound", err)
+	}
+
+	_, err = h.User.UpdateUserContext(ctx, api.UpdateUserRequest{Id: uuid.New().String(), RunescapeName: "lynx titan"})
+	if !errors.Is(err, client.ErrUserNotFound) {
+		t.Errorf("UpdateUser of an unknown id: got %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestContractUserSearch(t *testing.T) {
+	cs := newContractServer(t)
+	h := cs.client(t, adminToken)
+	ctx := context.Background()
+
+	for _, name := range []string{"Iron Hyger", "Iron Man", "Iron_Mule", "Zezima"} {
+		request := api.CreateUserRequest{RunescapeName: name, AccountType: api.AccountTypeIronman}
+		if name == "Zezima" {
+			request.AccountType = api.AccountTypeNormal
+		}
+		if _, err := h.User.CreateUserContext(ctx, request); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+	}
+
+	query := client.UserQuery{Name: "iron", AccountType: api.AccountTypeIronman, Sort: "-name", Limit: 2}
+	first, err := h.User.SearchUsersContext(ctx, query)
+	if err != nil {
+		t.Fatalf("SearchUsers: %v", err)
+	}
+	if len(first.Users) != 2 || first.Users[0].RunescapeName != "Iron_Mule" || first.Users[1].RunescapeName != "Iron Man" || first.NextCursor == "" {
+		t.Fatalf("first page = %+v, want Iron_Mule and Iron Man with a next cursor", first)
+	}
+
+	query.Cursor = first.NextCursor
+	second, err := h.User.SearchUsersContext(ctx, query)
+	if err != nil {
+		t.Fatalf("SearchUsers: %v", err)
+	}
+	if len(second.Users) != 1 || second.Users[0].RunescapeName != "Iron Hyger" || second.NextCursor != "" {
+		t.Errorf("last page = %+v, want only Iron Hyger and no next cursor", second)
+	}
+	if second.Users[0].CreatedAt == nil {
+		t.Errorf("searched user has no creation time")
+	}
+
+	// A cursor only means something in the sort it was made for.
+	query.Sort = "createdAt"
+	_, err = h.User.SearchUsersContext(ctx, query)
+	if !errors.Is(err, client.ErrHazelmereBadRequest) {
+		t.Errorf("SearchUsers with a cursor of another sort: got %v, want ErrHazelmereBadRequest", err)
+	}
+	_, err = h.User.SearchUsersContext(ctx, client.UserQuery{Sort: "rank"})
+	if !errors.Is(err, client.ErrHazelmereBadRequest) {
+		t.Errorf("SearchUsers with an unknown sort: got %v, want ErrHazelmereBadRequest", err)
+	}
+
+	// Without any parameters users are listed a page of 100 at a time.
+	for i := range 97 {
+		if _, err := h.User.CreateUserContext(ctx, api.CreateUserRequest{RunescapeName: fmt.Sprintf("Alt %d", i)}); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+	}
+	page, err := h.User.SearchUsersContext(ctx, client.UserQuery{})
+	if err != nil || len(page.Users) != 100 || page.NextCursor == "" {
+		t.Errorf("SearchUsers without a query returned %d users with cursor %q, %v; want a page of 100", len(page.Users), page.NextCursor, err)
+	}
+	all, err := h.User.GetAllUsersContext(ctx)
+	if err != nil || len(all.Users) != 101 || all.NextCursor != "" {
+		t.Errorf("GetAllUsers returned %d users, %v; want all 101", len(all.Users), err)
+	}
+}
+
+func TestContractUserRename(t *testing.T) {
+	cs := newContractServer(t)
+	h := cs.client(t, adminToken)
+	ctx := context.Background()
+
+	created, err := h.User.CreateUserContext(ctx, api.CreateUserRequest{RunescapeName: "Iron Hyger"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	renamed, err := h.User.RenameUserContext(ctx, created.User.Id, api.RenameUserRequest{RunescapeName: "Hyger_BTW"})
+	if err != nil {
+		t.Fatalf("RenameUser: %v", err)
+	}
+	if renamed.User.RunescapeName != "Hyger_BTW" || len(renamed.User.NameHistory) != 1 || renamed.User.NameHistory[0].OldName != "Iron Hyger" {
+		t.Errorf("RenameUser = %+v, want Hyger_BTW renamed from Iron Hyger", renamed.User)
+	}
+
+	current, err := h.User.GetUserByNameContext(ctx, "hyger btw")
+	if err != nil || current.User.Id != created.User.Id || current.MatchedAlias {
+		t.Errorf("GetUserByName of the current name = %+v, %v; want the user without an alias match", current, err)
+	}
+	past, err := h.User.GetUserByNameContext(ctx, "IRON-HYGER")
+	if err != nil || past.User.Id != created.User.Id || !past.MatchedAlias {
+		t.Errorf("GetUserByName of the past name = %+v, %v; want the user matched by alias", past, err)
+	}
+
+	// The old name is free again, and its new holder wins lookups by it.
+	other, err := h.User.CreateUserContext(ctx, api.CreateUserRequest{RunescapeName: "iron_hyger"})
+	if err != nil {
+		t.Fatalf("CreateUser with a past name: %v", err)
+	}
+	taken, err := h.User.GetUserByNameContext(ctx, "Iron Hyger")
+	if err != nil || taken.User.Id != other.User.Id || taken.MatchedAlias {
+		t.Errorf("GetUserByName of a reused name = %+v, %v; want its new holder", taken, err)
+	}
+
+	_, err = h.User.RenameUserContext(ctx, created.User.Id, api.RenameUserRequest{RunescapeName: "IRON HYGER"})
+	if !errors.Is(err, client.ErrRunescapeNameAlreadyTracked) {
+		t.Errorf("RenameUser to a tracked name: got %v, want ErrRunescapeNameAlreadyTracked", err)
+	}
+	_, err = h.User.RenameUserContext(ctx, created.User.Id, api.RenameUserRequest{RunescapeName: "way too long a name"})
+	if !errors.Is(err, client.ErrInvalidUser) {
+		t.Errorf("RenameUser to an invalid name: got %v, want ErrInvalidUser", err)
+	}
+	_, err = h.User.RenameUserContext(ctx, uuid.New().String(), api.RenameUserRequest{RunescapeName: "zezima"})
+	if !errors.Is(err, client.ErrUserNotFound) {
+		t.Errorf("RenameUser of an unknown id: got %v, want ErrUserNotFound", err)
+	}
+	_, err = h.User.GetUserByNameContext(ctx, "lynx titan")
+	if !errors.Is(err, client.ErrUserNotFound) {
+		t.Errorf("GetUserByName of an unknown name: got %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestContractUserMerge(t *testing.T) {
+	cs := newContractServer(t)
+	h := cs.client(t, adminToken)
+	ctx := context.Background()
+
+	target, err := h.User.CreateUserContext(ctx, api.CreateUserRequest{RunescapeName: "Hyger"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	source, err := h.User.CreateUserContext(ctx, api.CreateUserRequest{RunescapeName: "Hyger Alt"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	request := api.MergeUsersRequest{SourceUserId: source.User.Id, TargetUserId: target.User.Id}
+
+	dryRun := request
+	dryRun.DryRun = true
+	report, err := h.User.MergeUsersContext(ctx, dryRun)
+	if err != nil || !report.Report.DryRun || report.Report.Target.Id != target.User.Id {
+		t.Fatalf("MergeUsers dry run = %+v, %v", report, err)
+	}
+	if unchanged, err := h.User.GetUserByIdContext(ctx, source.User.Id); err != nil || unchanged.User.MergedInto != "" {
+		t.Errorf("source after a dry run = %+v, %v; want it unmerged", unchanged, err)
+	}
+
+	merged, err := h.User.MergeUsersContext(ctx, request)
+	if err != nil {
+		t.Fatalf("MergeUsers: %v", err)
+	}
+	if len(merged.Report.Target.NameHistory) != 1 || merged.Report.Target.NameHistory[0].OldName != "Hyger Alt" {
+		t.Errorf("merged target = %+v, want Hyger Alt as a past name", merged.Report.Target)
+	}
+	tombstone, err := h.User.GetUserByIdContext(ctx, source.User.Id)
+	if err != nil || tombstone.User.MergedInto != target.User.Id {
+		t.Errorf("source after the merge = %+v, %v; want it merged into %s", tombstone, err, target.User.Id)
+	}
+
+	_, err = h.User.MergeUsersContext(ctx, request)
+	if !errors.Is(err, client.ErrInvalidUser) {
+		t.Errorf("MergeUsers of a merged user: got %v, want ErrInvalidUser", err)
+	}
+	_, err = h.User.MergeUsersContext(ctx, api.MergeUsersRequest{SourceUserId: uuid.New().String(), TargetUserId: target.User.Id})
+	if !errors.Is(err, client.ErrUserNotFound) {
+		t.Errorf("MergeUsers of an unknown user: got %v, want ErrUserNotFound", err)
+	}
+	_, err = cs.client(t, readToken).User.MergeUsersContext(ctx, dryRun)
+	if !errors.Is(err, client.ErrHazelmereForbidden) {
+		t.Errorf("MergeUsers without the admin scope: got %v, want ErrHazelmereForbidden", err)
+	}
+}
+
+func TestContractUserLifecycle(t *testing.T) {
+	cs := newContractServer(t)
+	h := cs.client(t, adminToken)
+	ctx := context.Background()
+
+	created, err := h.User.CreateUserContext(ctx, api.CreateUserRequest{RunescapeName: "Hyger"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	id := created.User.Id
+	start := time.Now().UTC().Add(-48 * time.Hour).Truncate(time.Hour)
+	for i, experience := range []int{1_000_000, 1_250_000} {
+		if _, err := h.Snapshot.CreateSnapshotContext(ctx, api.CreateSnapshotRequest{Snapshot: newSnapshot(id, start.Add(time.Duration(i)*24*time.Hour), experience)}); err != nil {
+			t.Fatalf("CreateSnapshot: %v", err)
+		}
+	}
+
+	export, err := h.User.ExportUserContext(ctx, id)
+	if err != nil {
+		t.Fatalf("ExportUser: %v", err)
+	}
+	if export.Export.User.Id != id || len(export.Export.Snapshots) != 2 || len(export.Export.Deltas) != 1 || len(export.Export.AuditRecords) == 0 {
+		t.Errorf("export = %d snapshots, %d deltas, %d audit records for %s; want 2, 1 and the user's creation",
+			len(export.Export.Snapshots), len(export.Export.Deltas), len(export.Export.AuditRecords), export.Export.User.Id)
+	}
+
+	deleted, err := h.User.DeleteUserContext(ctx, id)
+	if err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if deleted.User.DeletedAt == nil || deleted.User.TrackingStatus != api.TrackingStatusDisabled {
+		t.Errorf("deleted user = %+v, want deletedAt set and tracking disabled", deleted.User)
+	}
+	all, err := h.User.GetAllUsersContext(ctx)
+	if err != nil {
+		t.Fatalf("GetAllUsers: %v", err)
+	}
+	if len(all.Users) != 0 {
+		t.Errorf("GetAllUsers returned %d users after a soft delete, want 0", len(all.Users))
+	}
+
+	_, err = cs.client(t, readToken).User.DeleteUserPermanentlyContext(ctx, id)
+	if !errors.Is(err, client.ErrHazelmereForbidden) {
+		t.Errorf("DeleteUserPermanently without the admin scope: got %v, want ErrHazelmereForbidden", err)
+	}
+	report, err := h.User.DeleteUserPermanentlyContext(ctx, id)
+	if err != nil {
+		t.Fatalf("DeleteUserPermanently: %v", err)
+	}
+	if report.SnapshotsDeleted != 2 || report.DeltasDeleted != 1 {
+		t.Errorf("DeleteUserPermanently = %+v, want 2 snapshots and 1 delta deleted", report)
+	}
+	if _, err := h.User.GetUserByIdContext(ctx, id); !errors.Is(err, client.ErrUserNotFound) {
+		t.Errorf("GetUserById after a hard delete: got %v, want ErrUserNotFound", err)
+	}
+	if _, err := h.User.DeleteUserPermanentlyContext(ctx, id); !errors.Is(err, client.ErrUserNotFound) {
+		t.Errorf("DeleteUserPermanently of a deleted user: got %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestContractAccountTypes(t *testing.T) {
+	cs := newContractServer(t)
+	h := cs.client(t, adminToken)
+	ctx := context.Background()
+
+	if _, err := h.User.CreateUserContext(ctx, api.CreateUserRequest{RunescapeName: "Zezima", AccountType: "WIZARD"}); !errors.Is(err, client.ErrInvalidUser) {
+		t.Errorf("CreateUser with an unknown account type: got %v, want ErrInvalidUser", err)
+	}
+
+	created, err := h.User.CreateUserContext(ctx, api.CreateUserRequest{RunescapeName: "Hyger", AccountType: api.AccountTypeHardcoreIronman})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	id := created.User.Id
+	start := time.Now().UTC().Add(-72 * time.Hour).Truncate(time.Hour)
+	for i := range 3 {
+		if _, err := h.Snapshot.CreateSnapshotContext(ctx, api.CreateSnapshotRequest{Snapshot: newSnapshot(id, start.Add(time.Duration(i)*24*time.Hour), 1_000_000*(i+1))}); err != nil {
+			t.Fatalf("CreateSnapshot: %v", err)
+		}
+	}
+
+	// The worker finds the hardcore status lost between the second and third snapshot.
+	lostAt := start.Add(36 * time.Hour)
+	reported, err := h.User.ReportAccountTypeContext(ctx, id, api.ReportAccountTypeRequest{AccountType: api.AccountTypeIronman, DetectedAt: &lostAt})
+	if err != nil || !reported.Changed || reported.User.AccountType != api.AccountTypeIronman {
+		t.Fatalf("ReportAccountType = %+v, %v; want a change to IRONMAN", reported, err)
+	}
+	// An update that leaves the account type out keeps the detected one, and records no change.
+	updated, err := h.User.UpdateUserContext(ctx, api.UpdateUserRequest{Id: id, RunescapeName: "Hyger", TrackingStatus: api.TrackingStatusDisabled})
+	if err != nil || updated.User.AccountType != api.AccountTypeIronman || len(updated.User.AccountTypeHistory) != 1 {
+		t.Errorf("UpdateUser without an account type = %+v, %v; want IRONMAN with the one detected change", updated, err)
+	}
+	_, err = h.User.ReportAccountTypeContext(ctx, id, api.ReportAccountTypeRequest{AccountType: "WIZARD"})
+	if !errors.Is(err, client.ErrInvalidUser) {
+		t.Errorf("ReportAccountType of an unknown type: got %v, want ErrInvalidUser", err)
+	}
+
+	history, err := h.Snapshot.GetAllSnapshotsForUserContext(ctx, id)
+	if err != nil {
+		t.Fatalf("GetAllSnapshotsForUser: %v", err)
+	}
+	var types []api.AccountType
+	for _, snap := range history.Snapshots {
+		types = append(types, api.AccountTypeAt(history.AccountType, history.AccountTypeHistory, snap.Timestamp))
+	}
+	want := []api.AccountType{api.AccountTypeHardcoreIronman, api.AccountTypeHardcoreIronman, api.AccountTypeIronman}
+	if !reflect.DeepEqual(types, want) {
+		t.Errorf("account types of the snapshots = %v, want %v", types, want)
+	}
+
+	// Wise Old Man sees a regular account: the player de-ironed.
+	checked, err := h.User.CheckAccountTypeContext(ctx, id)
+	if err != nil || !checked.Changed || checked.User.AccountType != api.AccountTypeNormal {
+		t.Fatalf("CheckAccountType = %+v, %v; want a change to NORMAL", checked, err)
+	}
+	if sources := checked.User.AccountTypeHistory; len(sources) != 2 || sources[1].Source != api.AccountTypeSourceDetected {
+		t.Errorf("account type history = %+v, want two detected changes", sources)
+	}
+
+	unknown, err := h.User.CreateUserContext(ctx, api.CreateUserRequest{RunescapeName: "Nobody"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if _, err := h.User.CheckAccountTypeContext(ctx, unknown.User.Id); !errors.Is(err, client.ErrAccountTypeUndetected) {
+		t.Errorf("CheckAccountType of a player WOM does not know: got %v, want ErrAccountTypeUndetected", err)
+	}
+}
+
+func TestContractGroups(t *testing.T) {
+	cs := newContractServer(t)
+	h := cs.client(t, adminToken)
+	ctx := context.Background()
+
+	// Two players gain 1m overall experience a day over three days.
+	start := time.Now().UTC().Add(-96 * time.Hour).Truncate(time.Hour)
+	var ids []string
+	for _, name := range []string{"Zezima", "Woox"} {
+		created, err := h.User.CreateUserContext(ctx, api.CreateUserRequest{RunescapeName: name})
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		ids = append(ids, created.User.Id)
+		for i := range 4 {
+			if _, err := h.Snapshot.CreateSnapshotContext(ctx, api.CreateSnapshotRequest{Snapshot: newSnapshot(created.User.Id, start.Add(time.Duration(i)*24*time.Hour), 1_000_000*(i+1))}); err != nil {
+				t.Fatalf("CreateSnapshot: %v", err)
+			}
+		}
+	}
+	zezima, woox := ids[0], ids[1]
+
+	if _, err := h.Group.CreateGroupContext(ctx, api.CreateGroupRequest{Name: " "}); !errors.Is(err, client.ErrInvalidGroup) {
+		t.Errorf("CreateGroup without a name: got %v, want ErrInvalidGroup", err)
+	}
+	created, err := h.Group.CreateGroupContext(ctx, api.CreateGroupRequest{Name: "Iron Clad"})
+	if err != nil {
+		t.Fatalf("CreateGroup: %v", err)
+	}
+	id := created.Group.Id
+	if _, err := h.Group.CreateGroupContext(ctx, api.CreateGroupRequest{Name: "iron  CLAD"}); !errors.Is(err, client.ErrGroupNameTaken) {
+		t.Errorf("CreateGroup of a taken name: got %v, want ErrGroupNameTaken", err)
+	}
+
+	// Zezima is in the group throughout; Woox joins after the first day and leaves during the third.
+	if _, err := h.Group.AddMemberContext(ctx, id, api.AddGroupMemberRequest{UserId: zezima, Role: api.GroupRoleOwner, JoinedAt: &start}); err != nil {
+		t.Fatalf("AddMember: %v", err)
+	}
+	joinedAt := start.Add(36 * time.Hour)
+	if _, err := h.Group.AddMemberContext(ctx, id, api.AddGroupMemberRequest{UserId: woox, JoinedAt: &joinedAt}); err != nil {
+		t.Fatalf("AddMember: %v", err)
+	}
+	if _, err := h.Group.AddMemberContext(ctx, id, api.AddGroupMemberRequest{UserId: woox}); !errors.Is(err, client.ErrInvalidGroup) {
+		t.Errorf("AddMember of a current member: got %v, want ErrInvalidGroup", err)
+	}
+	if _, err := h.Group.AddMemberContext(ctx, id, api.AddGroupMemberRequest{UserId: uuid.New().String()}); !errors.Is(err, client.ErrInvalidGroup) {
+		t.Errorf("AddMember of an unknown user: got %v, want ErrInvalidGroup", err)
+	}
+	if _, err := h.Group.UpdateMemberContext(ctx, id, woox, api.UpdateGroupMemberRequest{Role: "CAPTAIN"}); !errors.Is(err, client.ErrInvalidGroup) {
+		t.Errorf("UpdateMember to an unknown role: got %v, want ErrInvalidGroup", err)
+	}
+	updated, err := h.Group.UpdateMemberContext(ctx, id, woox, api.UpdateGroupMemberRequest{Role: api.GroupRoleAdmin})
+	if err != nil || updated.Group.Memberships[1].Role != api.GroupRoleAdmin {
+		t.Errorf("UpdateMember = %+v, %v; want Woox as ADMIN", updated.Group, err)
+	}
+	leftAt := start.Add(60 * time.Hour)
+	removed, err := h.Group.RemoveMemberContext(ctx, id, woox, leftAt)
+	if err != nil || len(removed.Group.ActiveMembers()) != 1 || !removed.Group.Memberships[1].LeftAt.Equal(leftAt) {
+		t.Fatalf("RemoveMember = %+v, %v; want Woox to have left at %s", removed.Group, err, leftAt)
+	}
+
+	gainsRequest := api.GetGroupGainsRequest{GroupId: id, StartTime: start, EndTime: time.Now()}
+	gains, err := h.Group.GetGroupGainsContext(ctx, gainsRequest)
+	if err != nil {
+		t.Fatalf("GetGroupGains: %v", err)
+	}
+	wantContributors := []api.GroupContributor{
+		{UserId: zezima, RunescapeName: "Zezima", ExperienceGain: 3_000_000},
+		{UserId: woox, RunescapeName: "Woox", ExperienceGain: 1_000_000},
+	}
+	if gains.TotalExperienceGain != 4_000_000 || gains.MemberCount != 2 || !reflect.DeepEqual(gains.TopContributors, wantContributors) {
+		t.Errorf("GetGroupGains = %d experience by %d members, contributors %+v; want 4m by 2, %+v",
+			gains.TotalExperienceGain, gains.MemberCount, gains.TopContributors, wantContributors)
+	}
+	if len(gains.Skills) == 0 || gains.Skills[0].ActivityType != api.ActivityTypeOverall || gains.Skills[0].TotalExperienceGain != 4_000_000 {
+		t.Errorf("GetGroupGains skills = %+v; want overall first with 4m", gains.Skills)
+	}
+
+	// Rejoining cannot reach back into the time away, and only counts gains from the new stay.
+	earlyRejoin := leftAt.Add(-time.Hour)
+	if _, err := h.Group.AddMemberContext(ctx, id, api.AddGroupMemberRequest{UserId: woox, JoinedAt: &earlyRejoin}); !errors.Is(err, client.ErrInvalidGroup) {
+		t.Errorf("AddMember overlapping the previous stay: got %v, want ErrInvalidGroup", err)
+	}
+	rejoin := leftAt.Add(6 * time.Hour)
+	if _, err := h.Group.AddMemberContext(ctx, id, api.AddGroupMemberRequest{UserId: woox, JoinedAt: &rejoin}); err != nil {
+		t.Fatalf("AddMember to rejoin: %v", err)
+	}
+	gainsRequest.Limit = 1
+	gains, err = h.Group.GetGroupGainsContext(ctx, gainsRequest)
+	if err != nil || gains.TotalExperienceGain != 5_000_000 || len(gains.TopContributors) != 1 || gains.TopContributors[0].UserId != zezima {
+		t.Errorf("GetGroupGains after rejoining = %+v, %v; want 5m led by Zezima alone", gains, err)
+	}
+
+	if _, err := h.Group.GetGroupGainsContext(ctx, api.GetGroupGainsRequest{GroupId: uuid.New().String(), StartTime: start, EndTime: time.Now()}); !errors.Is(err, client.ErrGroupNotFound) {
+		t.Errorf("GetGroupGains of an unknown group: got %v, want ErrGroupNotFound", err)
+	}
+	if _, err := h.Group.GetGroupGainsContext(ctx, api.GetGroupGainsRequest{GroupId: id, StartTime: time.Now(), EndTime: start}); !errors.Is(err, client.ErrInvalidGroup) {
+		t.Errorf("GetGroupGains ending before it starts: got %v, want ErrInvalidGroup", err)
+	}
+
+	renamed, err := h.Group.UpdateGroupContext(ctx, id, api.UpdateGroupRequest{Name: "Iron Clad II"})
+	if err != nil || renamed.Group.Name != "Iron Clad II" || len(renamed.Group.Memberships) != 3 {
+		t.Errorf("UpdateGroup = %+v, %v; want the renamed group with its memberships", renamed.Group, err)
+	}
+	all, err := h.Group.GetAllGroupsContext(ctx)
+	if err != nil || len(all.Groups) != 1 {
+		t.Errorf("GetAllGroups returned %d groups, %v; want 1", len(all.Groups), err)
+	}
+	if _, err := cs.client(t, readToken).Group.CreateGroupContext(ctx, api.CreateGroupRequest{Name: "Gielinor Gains"}); !errors.Is(err, client.ErrHazelmereForbidden) {
+		t.Errorf("CreateGroup without the user:write scope: got %v, want ErrHazelmereForbidden", err)
+	}
+
+	if _, err := h.Group.DeleteGroupContext(ctx, id); err != nil {
+		t.Fatalf("DeleteGroup: %v", err)
+	}
+	if _, err := h.Group.GetGroupByIdContext(ctx, id); !errors.Is(err, client.ErrGroupNotFound) {
+		t.Errorf("GetGroupById after DeleteGroup: got %v, want ErrGroupNotFound", err)
+	}
+}
+
+func TestContractGroupGainsWithinADay(t *testing.T) {
+	cs := newContractServer(t)
+	h := cs.client(t, adminToken)
+	ctx := context.Background()
+
+	// Lynx Titan gains 1m experience between each of three snapshots on one day, and is only in
+	// the group for the first of those gains.
+	day := time.Now().UTC().AddDate(0, 0, -7).Truncate(24 * time.Hour)
+	created, err := h.User.CreateUserContext(ctx, api.CreateUserRequest{RunescapeName: "Lynx Titan"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	userId := created.User.Id
+	for i, hour := range []int{1, 3, 5} {
+		if _, err := h.Snapshot.CreateSnapshotContext(ctx, api.CreateSnapshotRequest{Snapshot: newSnapshot(userId, day.Add(time.Duration(hour)*time.Hour), 1_000_000*(i+1))}); err != nil {
+			t.Fatalf("CreateSnapshot: %v", err)
+		}
+	}
+
+	group, err := h.Group.CreateGroupContext(ctx, api.CreateGroupRequest{Name: "Day Trippers"})
+	if err != nil {
+		t.Fatalf("CreateGroup: %v", err)
+	}
+	joinedAt, leftAt := day.Add(2*time.Hour), day.Add(4*time.Hour)
+	if _, err := h.Group.AddMemberContext(ctx, group.Group.Id, api.AddGroupMemberRequest{UserId: userId, JoinedAt: &joinedAt}); err != nil {
+		t.Fatalf("AddMember: %v", err)
+	}
+	if _, err := h.Group.RemoveMemberContext(ctx, group.Group.Id, userId, leftAt); err != nil {
+		t.Fatalf("RemoveMember: %v", err)
+	}
+
+	gains, err := h.Group.GetGroupGainsContext(ctx, api.GetGroupGainsRequest{GroupId: group.Group.Id, StartTime: day, EndTime: day.Add(24 * time.Hour)})
+	if err != nil || gains.TotalExperienceGain != 1_000_000 {
+		t.Errorf("GetGroupGains = %+v, %v; want the 1m gained while in the group", gains, err)
+	}
+}
+
+func TestContractSnapshotAndDelta(t *testing.T) {
+	cs := newContractServer(t)
+	h := cs.client(t, adminToken)
+	ctx := context.Background()
+
+	userId := createUser(t, h, "Zezima")
+	start := time.Now().UTC().Add(-72 * time.Hour).Truncate(time.Hour)
+	first, err := h.Snapshot.CreateSnapshotContext(ctx, api.CreateSnapshotRequest{Snapshot: newSnapshot(userId, start, 1_000_000)})
+	if err != nil {
+		t.Fatalf("CreateSnapshot: %v", err)
+	}
+	second, err := h.Snapshot.CreateSnapshotContext(ctx, api.CreateSnapshotRequest{Snapshot: newSnapshot(userId, start.Add(24*time.Hour), 1_250_000)})
+	if err != nil {
+		t.Fatalf("CreateSnapshot: %v", err)
+	}
+
+	all, err := h.Snapshot.GetAllSnapshotsForUserContext(ctx, userId)
+	if err != nil {
+		t.Fatalf("GetAllSnapshotsForUser: %v", err)
+	}
+	if len(all.Snapshots) != 2 {
+		t.Errorf("GetAllSnapshotsForUser returned %d snapshots, want 2", len(all.Snapshots))
+	}
+
+	nearest, err := h.Snapshot.GetSnapshotForUserNearestTimestampContext(ctx, userId, start.Add(20*time.Hour).UnixMilli())
+	if err != nil {
+		t.Fatalf("GetSnapshotForUserNearestTimestamp: %v", err)
+	}
+	if nearest.Snapshot.Id != second.Snapshot.Id {
+		t.Errorf("nearest snapshot = %s, want %s", nearest.Snapshot.Id, second.Snapshot.Id)
+	}
+
+	interval, err := h.Snapshot.GetSnapshotIntervalContext(ctx, api.GetSnapshotIntervalRequest{
+		UserId: userId, StartTime: start, EndTime: start.Add(48 * time.Hour), AggregationWindow: api.AggregationWindowDaily,
+	})
+	if err != nil {
+		t.Fatalf("GetSnapshotInterval: %v", err)
+	}
+	if interval.TotalSnapshots != 2 || interval.SnapshotsWithGains != 1 || len(interval.Snapshots) != 1 {
+		t.Errorf("GetSnapshotInterval = %d total, %d with gains, %d snapshots; want 2, 1, 1",
+			interval.TotalSnapshots, interval.SnapshotsWithGains, len(interval.Snapshots))
+	}
+
+	latest, err := h.Delta.GetLatestDeltaContext(ctx, userId)
+	if err != nil {
+		t.Fatalf("GetLatestDelta: %v", err)
+	}
+	if latest.Delta.SnapshotId != second.Snapshot.Id || latest.Delta.PreviousSnapshotId != first.Snapshot.Id {
+		t.Errorf("latest delta links %s -> %s, want %s -> %s",
+			latest.Delta.PreviousSnapshotId, latest.Delta.SnapshotId, first.Snapshot.Id, second.Snapshot.Id)
+	}
+
+	deltas, err := h.Delta.GetDeltaIntervalContext(ctx, api.GetDeltaIntervalRequest{UserId: userId, StartTime: start, EndTime: start.Add(48 * time.Hour)})
+	if err != nil {
+		t.Fatalf("GetDeltaInterval: %v", err)
+	}
+	if deltas.TotalDeltas != 1 {
+		t.Errorf("GetDeltaInterval returned %d deltas, want 1", deltas.TotalDeltas)
+	}
+
+	if _, err := h.Delta.GetDeltaSummaryContext(ctx, api.GetDeltaSummaryRequest{UserId: userId, StartTime: start, EndTime: start.Add(48 * time.Hour)}); err != nil {
+		t.Fatalf("GetDeltaSummary: %v", err)
+	}
+
+	summaryRequest := api.GetSnapshotWithDeltasRequest{UserId: userId, StartTime: start, EndTime: start.Add(48 * time.Hour)}
+	summary, err := h.Snapshot.GetSnapshotWithDeltasContext(ctx, summaryRequest)
+	if err != nil {
+		t.Fatalf("GetSnapshotWithDeltas: %v", err)
+	}
+	binarySummary, err := h.Snapshot.GetSnapshotWithDeltasBinaryContext(ctx, summaryRequest)
+	if err != nil {
+		t.Fatalf("GetSnapshotWithDeltasBinary: %v", err)
+	}
+	if !binarySummary.Snapshot.Timestamp.Equal(summary.Snapshot.Timestamp) || len(binarySummary.Deltas) != len(summary.Deltas) {
+		t.Fatalf("binary summary %+v does not match JSON summary %+v", binarySummary, summary)
+	}
+	for i, skill := range summary.Snapshot.Skills {
+		got := binarySummary.Snapshot.Skills[i]
+		if got.ActivityType != skill.ActivityType || got.Experience != skill.Experience || got.Level != skill.Level {
+			t.Errorf("binary skill %d = %+v, want %+v", i, got, skill)
+		}
+	}
+
+	_, err = h.Snapshot.CreateSnapshotContext(ctx, api.CreateSnapshotRequest{Snapshot: api.HiscoreSnapshot{UserId: userId}})
+	if !errors.Is(err, client.ErrInvalidSnapshot) {
+		t.Errorf("CreateSnapshot with no skills: got %v, want ErrInvalidSnapshot", err)
+	}
+
+	unknown := uuid.New().String()
+	_, err = h.Snapshot.CreateSnapshotContext(ctx, api.CreateSnapshotRequest{Snapshot: newSnapshot(unknown, start, 1_000_000)})
+	if !errors.Is(err, client.ErrInvalidSnapshot) {
+		t.Errorf("CreateSnapshot of an unknown user: got %v, want ErrInvalidSnapshot", err)
+	}
+	_, err = h.Snapshot.GetSnapshotForUserNearestTimestampContext(ctx, unknown, start.UnixMilli())
+	if !errors.Is(err, client.ErrSnapshotNotFound) {
+		t.Errorf("nearest snapshot of an unknown user: got %v, want ErrSnapshotNotFound", err)
+	}
+	_, err = h.Delta.GetLatestDeltaContext(ctx, unknown)
+	if !errors.Is(err, client.ErrDeltaNotFound) {
+		t.Errorf("latest delta of an unknown user: got %v, want ErrDeltaNotFound", err)
+	}
+}
+
+func TestContractWorker(t *testing.T) {
+	cs := newContractServer(t)
+	h := cs.client(t, adminToken)
+	ctx := context.Background()
+
+	userId := createUser(t, h, "Zezima")
+	generated, err := h.Worker.GenerateSnapshotOnDemandContext(ctx, userId)
+	if err != nil {
+		t.Fatalf("GenerateSnapshotOnDemand: %v", err)
+	}
+	if generated.Snapshot.UserId != userId {
+		t.Errorf("generated snapshot belongs to %s, want %s", generated.Snapshot.UserId, userId)
+	}
+
+	_, err = h.Worker.GenerateSnapshotOnDemandContext(ctx, cs.timeoutUserId)
+	if !errors.Is(err, client.ErrHiscoreTimeout) {
+		t.Errorf("GenerateSnapshotOnDemand on timeout: got %v, want ErrHiscoreTimeout", err)
+	}
+
+	_, err = h.Worker.GenerateSnapshotOnDemandContext(ctx, cs.unavailableUserId)
+	if !errors.Is(err, client.ErrWorkerUnavailable) {
+		t.Errorf("GenerateSnapshotOnDemand while the worker is down: got %v, want ErrWorkerUnavailable", err)
+	}
+}
+
+func TestContractWorkerJobs(t *testing.T) {
+	cs := newContractServer(t)
+	h := cs.client(t, adminToken)
+	ctx := context.Background()
+
+	userId := createUser(t, h, "Zezima")
+	created, err := h.Worker.CreateSnapshotJobContext(ctx, api.CreateSnapshotJobRequest{UserId: userId})
+	if err != nil {
+		t.Fatalf("CreateSnapshotJob: %v", err)
+	}
+	if created.Job.Status != api.WorkerJobStatusQueued {
+		t.Errorf("created job is %s, want %s", created.Job.Status, api.WorkerJobStatusQueued)
+	}
+	if !cs.jobRunner.RunNext(ctx) {
+		t.Fatal("RunNext found no job to run")
+	}
+
+	finished, err := h.Worker.WaitForJob(ctx, created.Job.Id, time.Millisecond)
+	if err != nil {
+		t.Fatalf("WaitForJob: %v", err)
+	}
+	if finished.Status != api.WorkerJobStatusSucceeded || finished.SnapshotId == "" {
+		t.Errorf("finished job is %s with snapshot %q, want %s with a snapshot", finished.Status, finished.SnapshotId, api.WorkerJobStatusSucceeded)
+	}
+
+	timedOut, err := h.Worker.CreateSnapshotJobContext(ctx, api.CreateSnapshotJobRequest{UserId: cs.timeoutUserId})
+	if err != nil {
+		t.Fatalf("CreateSnapshotJob: %v", err)
+	}
+	cs.jobRunner.RunNext(ctx)
+	failed, err := h.Worker.WaitForJob(ctx, timedOut.Job.Id, time.Millisecond)
+	if !errors.Is(err, client.ErrJobFailed) || !errors.Is(err, client.ErrHiscoreTimeout) {
+		t.Errorf("WaitForJob on timeout: got %v, want ErrJobFailed and ErrHiscoreTimeout", err)
+	}
+	if failed.Status != api.WorkerJobStatusFailed {
+		t.Errorf("timed out job is %s, want %s", failed.Status, api.WorkerJobStatusFailed)
+	}
+
+	unknown, err := h.Worker.CreateSnapshotJobContext(ctx, api.CreateSnapshotJobRequest{UserId: uuid.New().String()})
+	if err != nil {
+		t.Fatalf("CreateSnapshotJob: %v", err)
+	}
+	cs.jobRunner.RunNext(ctx)
+	if _, err := h.Worker.WaitForJob(ctx, unknown.Job.Id, time.Millisecond); !errors.Is(err, client.ErrJobFailed) || !errors.Is(err, client.ErrInvalidUser) {
+		t.Errorf("WaitForJob for an unknown user: got %v, want ErrJobFailed and ErrInvalidUser", err)
+	}
+
+	_, err = h.Worker.CreateSnapshotJobContext(ctx, api.CreateSnapshotJobRequest{UserId: userId, WebhookUrl: "not a url"})
+	if !errors.Is(err, client.ErrInvalidJob) {
+		t.Errorf("CreateSnapshotJob with a bad webhook: got %v, want ErrInvalidJob", err)
+	}
+	_, err = h.Worker.GetJobContext(ctx, uuid.New().String())
+	if !errors.Is(err, client.ErrJobNotFound) {
+		t.Errorf("GetJob of an unknown job: got %v, want ErrJobNotFound", err)
+	}
+}
+
+func TestContractIdempotentWrites(t *testing.T) {
+	cs := newContractServer(t)
+	h := cs.client(t, adminToken)
+	ctx := context.Background()
+
+	userId := createUser(t, h, "Zezima")
+	request := api.CreateSnapshotRequest{Snapshot: newSnapshot(userId, time.Now().Add(-time.Hour), 1_000_000)}
+	first, err := h.Snapshot.CreateSnapshotContext(ctx, request, client.WithIdempotencyKey("snapshot-1"))
+	if err != nil {
+		t.Fatalf("CreateSnapshot: %v", err)
+	}
+	again, err := h.Snapshot.CreateSnapshotContext(ctx, request, client.WithIdempotencyKey("snapshot-1"))
+	if err != nil || again.Snapshot.Id != first.Snapshot.Id {
+		t.Errorf("CreateSnapshot with a repeated key = %s, %v; want the first snapshot %s", again.Snapshot.Id, err, first.Snapshot.Id)
+	}
+	if all, err := h.Snapshot.GetAllSnapshotsForUserContext(ctx, userId); err != nil || len(all.Snapshots) != 1 {
+		t.Errorf("GetAllSnapshotsForUser = %d snapshots, %v; want 1", len(all.Snapshots), err)
+	}
+
+	job, err := h.Worker.CreateSnapshotJobContext(ctx, api.CreateSnapshotJobRequest{UserId: userId}, client.WithIdempotencyKey("job-1"))
+	if err != nil {
+		t.Fatalf("CreateSnapshotJob: %v", err)
+	}
+	repeated, err := h.Worker.CreateSnapshotJobContext(ctx, api.CreateSnapshotJobRequest{UserId: userId}, client.WithIdempotencyKey("job-1"))
+	if err != nil || repeated.Job.Id != job.Job.Id {
+		t.Errorf("CreateSnapshotJob with a repeated key = %s, %v; want the first job %s", repeated.Job.Id, err, job.Job.Id)
+	}
+
+	_, err = h.Worker.CreateSnapshotJobContext(ctx, api.CreateSnapshotJobRequest{UserId: uuid.New().String()}, client.WithIdempotencyKey("job-1"))
+	if !errors.Is(err, client.ErrHazelmereBadRequest) {
+		t.Errorf("CreateSnapshotJob with a key used for another job: got %v, want ErrHazelmereBadRequest", err)
+	}
+}
+
+func TestContractTokenAndAudit(t *testing.T) {
+	cs := newContractServer(t)
+	h := cs.client(t, adminToken)
+	ctx := context.Background()
+
+	issued, err := h.Token.IssueTokenContext(ctx, api.IssueTokenRequest{Name: "grafana", Owner: "ops", Scopes: []string{string(auth.ScopeUserWrite)}})
+	if err != nil {
+		t.Fatalf("IssueToken: %v", err)
+	}
+
+	// The issued secret authenticates, and its scope allows creating users.
+	if _, err := cs.client(t, issued.Secret).User.CreateUserContext(ctx, api.CreateUserRequest{RunescapeName: "woox"}); err != nil {
+		t.Fatalf("CreateUser with an issued token: %v", err)
+	}
+
+	tokens, err := h.Token.GetAllTokensContext(ctx)
+	if err != nil {
+		t.Fatalf("GetAllTokens: %v", err)
+	}
+	if len(tokens.Tokens) != 1 {
+		t.Errorf("GetAllTokens returned %d tokens, want 1", len(tokens.Tokens))
+	}
+
+	rotated, err := h.Token.RotateTokenContext(ctx, issued.Token.Id)
+	if err != nil {
+		t.Fatalf("RotateToken: %v", err)
+	}
+	if rotated.Secret == issued.Secret {
+		t.Error("RotateToken returned the old secret")
+	}
+	if _, err := h.Token.RevokeTokenContext(ctx, issued.Token.Id); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	_, err = cs.client(t, rotated.Secret).User.CreateUserContext(ctx, api.CreateUserRequest{RunescapeName: "b0aty"})
+	if !errors.Is(err, client.ErrHazelmereUnauthorized) {
+		t.Errorf("CreateUser with a revoked token: got %v, want ErrHazelmereUnauthorized", err)
+	}
+
+	_, err = h.Token.RotateTokenContext(ctx, uuid.New().String())
+	if !errors.Is(err, client.ErrTokenNotFound) {
+		t.Errorf("RotateToken of an unknown id: got %v, want ErrTokenNotFound", err)
+	}
+
+	records, err := h.Audit.GetAuditRecordsContext(ctx, client.AuditQuery{EntityType: string(audit.EntityTypeUser)})
+	if err != nil {
+		t.Fatalf("GetAuditRecords: %v", err)
+	}
+	if len(records.Records) != 1 || records.Records[0].Actor != "grafana" {
+		t.Errorf("GetAuditRecords = %+v, want one user record by grafana", records.Records)
+	}
+
+	records, err = h.Audit.GetAuditRecordsContext(ctx, client.AuditQuery{EntityType: string(audit.EntityTypeToken)})
+	if err != nil {
+		t.Fatalf("GetAuditRecords of tokens: %v", err)
+	}
+	actions := map[string]api.AuditRecord{}
+	for _, record := range records.Records {
+		actions[record.Action] = record
+		if record.Actor != "admin" || record.EntityId != issued.Token.Id {
+			t.Errorf("token audit record = %+v, want one by admin for token %s", record, issued.Token.Id)
+		}
+		for _, change := range record.Changes {
+			if strings.Contains(strings.ToLower(change.Field), "secret") {
+				t.Errorf("%s audit record holds %s, want secrets left out", record.Action, change.Field)
+			}
+		}
+	}
+	for _, action := range []audit.Action{audit.ActionTokenIssue, audit.ActionTokenRotate, audit.ActionTokenRevoke} {
+		if _, ok := actions[string(action)]; !ok {
+			t.Errorf("token audit records = %+v, want a %s record", records.Records, action)
+		}
+	}
+	if revoke := actions[string(audit.ActionTokenRevoke)]; len(revoke.Changes) != 1 || revoke.Changes[0].Field != "revokedAt" {
+		t.Errorf("revoke audit changes = %+v, want revokedAt", revoke.Changes)
+	}
+}
+
+func TestContractAuthorization(t *testing.T) {
+	cs := newContractServer(t)
+	ctx := context.Background()
+
+	_, err := cs.client(t, readToken).User.CreateUserContext(ctx, api.CreateUserRequest{RunescapeName: "zezima"})
+	if !errors.Is(err, client.ErrHazelmereForbidden) {
+		t.Errorf("CreateUser with a read-only token: got %v, want ErrHazelmereForbidden", err)
+	}
+
+	_, err = cs.client(t, "not-a-token").User.CreateUserContext(ctx, api.CreateUserRequest{RunescapeName: "zezima"})
+	if !errors.Is(err, client.ErrHazelmereUnauthorized) {
+		t.Errorf("CreateUser with an unknown token: got %v, want ErrHazelmereUnauthorized", err)
+	}
+
+	if _, err := cs.client(t, "").Delta.GetLatestDeltaContext(ctx, uuid.New().String()); !errors.Is(err, client.ErrHazelmereUnauthorized) {
+		t.Errorf("GetLatestDelta without a token: got %v, want ErrHazelmereUnauthorized", err)
+	}
+	if _, err := cs.client(t, "").User.GetAllUsersContext(ctx); !errors.Is(err, client.ErrHazelmereUnauthorized) {
+		t.Errorf("GetAllUsers without a token: got %v, want ErrHazelmereUnauthorized", err)
+	}
+	_, err = cs.client(t, readToken).Delta.GetLatestDeltaContext(ctx, uuid.New().String())
+	if errors.Is(err, client.ErrHazelmereUnauthorized) || errors.Is(err, client.ErrHazelmereForbidden) {
+		t.Errorf("GetLatestDelta with the snapshot:read scope: got %v, want it authorized", err)
+	}
+	if _, err := cs.client(t, readToken).Group.GetAllGroupsContext(ctx); !errors.Is(err, client.ErrHazelmereForbidden) {
+		t.Errorf("GetAllGroups without the user:read scope: got %v, want ErrHazelmereForbidden", err)
+	}
+}
+
+func TestContractHealthAndOpenAPI(t *testing.T) {
+	cs := newContractServer(t)
+	h := cs.client(t, "")
+	ctx := context.Background()
+
+	status, err := h.Health.CheckContext(ctx)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if status.Status != api.HealthStatusHealthy || status.Environment != "contract" {
+		t.Errorf("Check = %+v, want healthy contract environment", status)
+	}
+
+	document, err := h.OpenAPI.GetDocumentContext(ctx)
+	if err != nil {
+		t.Fatalf("GetDocument: %v", err)
+	}
+	if string(document) != string(openapi.Spec) {
+		t.Error("GetDocument did not return the embedded OpenAPI document")
+	}
+}
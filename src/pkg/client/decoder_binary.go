@@ -0,0 +1,128 @@
+package client
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+)
+
+// BinaryContentType is the Accept value that selects the compact binary delta summary.
+const BinaryContentType = "application/x-hazelmere-binary"
+
+const binaryVersion uint8 = 1
+
+var ErrInvalidBinary = errors.Join(ErrHazelmereClient, errors.New("invalid binary response"))
+
+// DecodeDeltaSummaryBinary decodes the v1 binary delta summary written by the server for
+// POST /v1/summary/delta. Activity types are indices into api.AllActivityTypes.
+func DecodeDeltaSummaryBinary(data []byte) (api.GetSnapshotWithDeltasResponse, error) {
+	d := binaryDecoder{r: bytes.NewReader(data)}
+
+	version := d.uint8()
+	d.uint8() // flags, reserved
+	if d.err == nil && version != int(binaryVersion) {
+		return api.GetSnapshotWithDeltasResponse{}, errors.Join(ErrInvalidBinary, fmt.Errorf("unsupported version %d", version))
+	}
+
+	var response api.GetSnapshotWithDeltasResponse
+	response.Snapshot.Timestamp = d.timestamp()
+	response.Snapshot.Skills = make([]api.SkillSnapshot, d.uint8())
+	for i := range response.Snapshot.Skills {
+		response.Snapshot.Skills[i] = api.SkillSnapshot{ActivityType: d.activityType(), Experience: d.int32(), Level: d.int16()}
+	}
+	response.Snapshot.Bosses = make([]api.BossSnapshot, d.uint8())
+	for i := range response.Snapshot.Bosses {
+		response.Snapshot.Bosses[i] = api.BossSnapshot{ActivityType: d.activityType(), KillCount: d.int32()}
+	}
+	response.Snapshot.Activities = make([]api.ActivitySnapshot, d.uint8())
+	for i := range response.Snapshot.Activities {
+		response.Snapshot.Activities[i] = api.ActivitySnapshot{ActivityType: d.activityType(), Score: d.int32()}
+	}
+
+	response.Deltas = make([]api.HiscoreDelta, d.uint16())
+	for i := range response.Deltas {
+		delta := api.HiscoreDelta{Timestamp: d.timestamp()}
+		if n := d.uint8(); n > 0 {
+			delta.Skills = make([]api.SkillDelta, n)
+			for j := range delta.Skills {
+				delta.Skills[j] = api.SkillDelta{ActivityType: d.activityType(), ExperienceGain: d.int32(), LevelGain: d.int16()}
+			}
+		}
+		if n := d.uint8(); n > 0 {
+			delta.Bosses = make([]api.BossDelta, n)
+			for j := range delta.Bosses {
+				delta.Bosses[j] = api.BossDelta{ActivityType: d.activityType(), KillCountGain: d.int32()}
+			}
+		}
+		if n := d.uint8(); n > 0 {
+			delta.Activities = make([]api.ActivityDelta, n)
+			for j := range delta.Activities {
+				delta.Activities[j] = api.ActivityDelta{ActivityType: d.activityType(), ScoreGain: d.int32()}
+			}
+		}
+		response.Deltas[i] = delta
+	}
+
+	if d.err != nil {
+		return api.GetSnapshotWithDeltasResponse{}, errors.Join(ErrInvalidBinary, d.err)
+	}
+	if d.r.Len() != 0 {
+		return api.GetSnapshotWithDeltasResponse{}, errors.Join(ErrInvalidBinary, fmt.Errorf("%d trailing bytes", d.r.Len()))
+	}
+	return response, nil
+}
+
+// binaryDecoder reads big-endian values and keeps the first error, so callers check once at the end.
+type binaryDecoder struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (d *binaryDecoder) read(v any) {
+	if d.err != nil {
+		return
+	}
+	d.err = binary.Read(d.r, binary.BigEndian, v)
+}
+
+func (d *binaryDecoder) uint8() int {
+	var v uint8
+	d.read(&v)
+	return int(v)
+}
+
+func (d *binaryDecoder) uint16() int {
+	var v uint16
+	d.read(&v)
+	return int(v)
+}
+
+func (d *binaryDecoder) int16() int {
+	var v int16
+	d.read(&v)
+	return int(v)
+}
+
+func (d *binaryDecoder) int32() int {
+	var v int32
+	d.read(&v)
+	return int(v)
+}
+
+func (d *binaryDecoder) timestamp() time.Time {
+	var v int64
+	d.read(&v)
+	return time.UnixMilli(v).UTC()
+}
+
+func (d *binaryDecoder) activityType() api.ActivityType {
+	index := d.uint8()
+	if index < len(api.AllActivityTypes) {
+		return api.AllActivityTypes[index]
+	}
+	return api.ActivityTypeUnknown
+}
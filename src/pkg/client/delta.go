@@ -1,38 +1,45 @@
 package client
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net/http"
 
 	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
-	"github.com/ctfloyd/hazelmere-commons/pkg/hz_client"
 )
 
 var ErrDeltaNotFound = errors.Join(ErrHazelmereClient, errors.New("delta not found"))
 
 type Delta struct {
-	prefix string
-	client *hz_client.HttpClient
-	config HazelmereConfig
+	prefix    string
+	transport *transport
 }
 
-func newDelta(client *hz_client.HttpClient, config HazelmereConfig) *Delta {
-	mappings := map[string]error{
+func newDelta(t *transport) *Delta {
+	t.addErrorMappings(map[string]error{
 		api.ErrorCodeDeltaNotFound: ErrDeltaNotFound,
-	}
-	client.AddErrorMappings(mappings)
+	})
 
 	return &Delta{
-		prefix: "delta",
-		client: client,
-		config: config,
+		prefix:    "delta",
+		transport: t,
 	}
 }
 
 func (d *Delta) GetLatestDelta(userId string) (api.GetLatestDeltaResponse, error) {
-	url := fmt.Sprintf("%s/%s/latest", d.getBaseUrl(), userId)
+	return d.GetLatestDeltaContext(context.Background(), userId)
+}
+
+func (d *Delta) GetLatestDeltaContext(ctx context.Context, userId string, opts ...CallOption) (api.GetLatestDeltaResponse, error) {
 	var response api.GetLatestDeltaResponse
-	err := d.client.GetWithHeaders(url, makeHeadersFromConfig(d.config), &response)
+	err := d.transport.do(ctx, call{
+		method:     http.MethodGet,
+		url:        fmt.Sprintf("%s/%s/latest", d.getBaseUrl(), userId),
+		response:   &response,
+		idempotent: true,
+		opts:       opts,
+	})
 	if err != nil {
 		return api.GetLatestDeltaResponse{}, err
 	}
@@ -40,9 +47,20 @@ func (d *Delta) GetLatestDelta(userId string) (api.GetLatestDeltaResponse, error
 }
 
 func (d *Delta) GetDeltaInterval(request api.GetDeltaIntervalRequest) (api.GetDeltaIntervalResponse, error) {
-	url := fmt.Sprintf("%s/interval", d.getBaseUrl())
+	return d.GetDeltaIntervalContext(context.Background(), request)
+}
+
+// GetDeltaIntervalContext is a read-only POST, so it is retried like a GET.
+func (d *Delta) GetDeltaIntervalContext(ctx context.Context, request api.GetDeltaIntervalRequest, opts ...CallOption) (api.GetDeltaIntervalResponse, error) {
 	var response api.GetDeltaIntervalResponse
-	err := d.client.PostWithHeaders(url, makeHeadersFromConfig(d.config), request, &response)
+	err := d.transport.do(ctx, call{
+		method:     http.MethodPost,
+		url:        fmt.Sprintf("%s/interval", d.getBaseUrl()),
+		body:       request,
+		response:   &response,
+		idempotent: true,
+		opts:       opts,
+	})
 	if err != nil {
 		return api.GetDeltaIntervalResponse{}, err
 	}
@@ -50,9 +68,20 @@ func (d *Delta) GetDeltaInterval(request api.GetDeltaIntervalRequest) (api.GetDe
 }
 
 func (d *Delta) GetDeltaSummary(request api.GetDeltaSummaryRequest) (api.GetDeltaSummaryResponse, error) {
-	url := fmt.Sprintf("%s/summary", d.getBaseUrl())
+	return d.GetDeltaSummaryContext(context.Background(), request)
+}
+
+// GetDeltaSummaryContext is a read-only POST, so it is retried like a GET.
+func (d *Delta) GetDeltaSummaryContext(ctx context.Context, request api.GetDeltaSummaryRequest, opts ...CallOption) (api.GetDeltaSummaryResponse, error) {
 	var response api.GetDeltaSummaryResponse
-	err := d.client.PostWithHeaders(url, makeHeadersFromConfig(d.config), request, &response)
+	err := d.transport.do(ctx, call{
+		method:     http.MethodPost,
+		url:        fmt.Sprintf("%s/summary", d.getBaseUrl()),
+		body:       request,
+		response:   &response,
+		idempotent: true,
+		opts:       opts,
+	})
 	if err != nil {
 		return api.GetDeltaSummaryResponse{}, err
 	}
@@ -60,5 +89,5 @@ func (d *Delta) GetDeltaSummary(request api.GetDeltaSummaryRequest) (api.GetDelt
 }
 
 func (d *Delta) getBaseUrl() string {
-	return fmt.Sprintf("%s/%s", d.client.GetV1Url(), d.prefix)
+	return d.transport.v1Url(d.prefix)
 }
@@ -0,0 +1,221 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+)
+
+var ErrGroupNotFound = errors.Join(ErrHazelmereClient, errors.New("group not found"))
+var ErrInvalidGroup = errors.Join(ErrHazelmereClient, errors.New("invalid group"))
+var ErrGroupNameTaken = errors.Join(ErrHazelmereClient, errors.New("group name taken"))
+
+// Group manages groups, such as clans, and reads their combined gains. Reads require a token
+// with the user:read scope and writes one with the user:write scope.
+type Group struct {
+	prefix    string
+	transport *transport
+}
+
+func newGroup(t *transport) *Group {
+	t.addErrorMappings(map[string]error{
+		api.ErrorCodeGroupNotFound:  ErrGroupNotFound,
+		api.ErrorCodeInvalidGroup:   ErrInvalidGroup,
+		api.ErrorCodeGroupNameTaken: ErrGroupNameTaken,
+	})
+
+	return &Group{
+		prefix:    "group",
+		transport: t,
+	}
+}
+
+func (group *Group) GetAllGroups() (api.GetAllGroupsResponse, error) {
+	return group.GetAllGroupsContext(context.Background())
+}
+
+func (group *Group) GetAllGroupsContext(ctx context.Context, opts ...CallOption) (api.GetAllGroupsResponse, error) {
+	var response api.GetAllGroupsResponse
+	err := group.transport.do(ctx, call{
+		method:     http.MethodGet,
+		url:        group.getBaseUrl(),
+		response:   &response,
+		idempotent: true,
+		opts:       opts,
+	})
+	if err != nil {
+		return api.GetAllGroupsResponse{}, err
+	}
+	return response, nil
+}
+
+func (group *Group) GetGroupById(id string) (api.GetGroupResponse, error) {
+	return group.GetGroupByIdContext(context.Background(), id)
+}
+
+func (group *Group) GetGroupByIdContext(ctx context.Context, id string, opts ...CallOption) (api.GetGroupResponse, error) {
+	var response api.GetGroupResponse
+	err := group.transport.do(ctx, call{
+		method:     http.MethodGet,
+		url:        fmt.Sprintf("%s/%s", group.getBaseUrl(), id),
+		response:   &response,
+		idempotent: true,
+		opts:       opts,
+	})
+	if err != nil {
+		return api.GetGroupResponse{}, err
+	}
+	return response, nil
+}
+
+func (group *Group) CreateGroup(request api.CreateGroupRequest) (api.CreateGroupResponse, error) {
+	return group.CreateGroupContext(context.Background(), request)
+}
+
+func (group *Group) CreateGroupContext(ctx context.Context, request api.CreateGroupRequest, opts ...CallOption) (api.CreateGroupResponse, error) {
+	var response api.CreateGroupResponse
+	err := group.transport.do(ctx, call{
+		method:   http.MethodPost,
+		url:      group.getBaseUrl(),
+		body:     request,
+		response: &response,
+		opts:     opts,
+	})
+	if err != nil {
+		return api.CreateGroupResponse{}, err
+	}
+	return response, nil
+}
+
+func (group *Group) UpdateGroup(id string, request api.UpdateGroupRequest) (api.UpdateGroupResponse, error) {
+	return group.UpdateGroupContext(context.Background(), id, request)
+}
+
+// UpdateGroupContext replaces the name of a group, so it is retried like a GET.
+func (group *Group) UpdateGroupContext(ctx context.Context, id string, request api.UpdateGroupRequest, opts ...CallOption) (api.UpdateGroupResponse, error) {
+	var response api.UpdateGroupResponse
+	err := group.transport.do(ctx, call{
+		method:     http.MethodPut,
+		url:        fmt.Sprintf("%s/%s", group.getBaseUrl(), id),
+		body:       request,
+		response:   &response,
+		idempotent: true,
+		opts:       opts,
+	})
+	if err != nil {
+		return api.UpdateGroupResponse{}, err
+	}
+	return response, nil
+}
+
+func (group *Group) DeleteGroup(id string) (api.DeleteGroupResponse, error) {
+	return group.DeleteGroupContext(context.Background(), id)
+}
+
+func (group *Group) DeleteGroupContext(ctx context.Context, id string, opts ...CallOption) (api.DeleteGroupResponse, error) {
+	var response api.DeleteGroupResponse
+	err := group.transport.do(ctx, call{
+		method:   http.MethodDelete,
+		url:      fmt.Sprintf("%s/%s", group.getBaseUrl(), id),
+		response: &response,
+		opts:     opts,
+	})
+	if err != nil {
+		return api.DeleteGroupResponse{}, err
+	}
+	return response, nil
+}
+
+func (group *Group) AddMember(id string, request api.AddGroupMemberRequest) (api.AddGroupMemberResponse, error) {
+	return group.AddMemberContext(context.Background(), id, request)
+}
+
+func (group *Group) AddMemberContext(ctx context.Context, id string, request api.AddGroupMemberRequest, opts ...CallOption) (api.AddGroupMemberResponse, error) {
+	var response api.AddGroupMemberResponse
+	err := group.transport.do(ctx, call{
+		method:   http.MethodPost,
+		url:      fmt.Sprintf("%s/%s/member", group.getBaseUrl(), id),
+		body:     request,
+		response: &response,
+		opts:     opts,
+	})
+	if err != nil {
+		return api.AddGroupMemberResponse{}, err
+	}
+	return response, nil
+}
+
+func (group *Group) UpdateMember(id string, userId string, request api.UpdateGroupMemberRequest) (api.UpdateGroupMemberResponse, error) {
+	return group.UpdateMemberContext(context.Background(), id, userId, request)
+}
+
+// UpdateMemberContext sets the role of a member, so it is retried like a GET.
+func (group *Group) UpdateMemberContext(ctx context.Context, id string, userId string, request api.UpdateGroupMemberRequest, opts ...CallOption) (api.UpdateGroupMemberResponse, error) {
+	var response api.UpdateGroupMemberResponse
+	err := group.transport.do(ctx, call{
+		method:     http.MethodPut,
+		url:        fmt.Sprintf("%s/%s/member/%s", group.getBaseUrl(), id, userId),
+		body:       request,
+		response:   &response,
+		idempotent: true,
+		opts:       opts,
+	})
+	if err != nil {
+		return api.UpdateGroupMemberResponse{}, err
+	}
+	return response, nil
+}
+
+func (group *Group) RemoveMember(id string, userId string, leftAt time.Time) (api.RemoveGroupMemberResponse, error) {
+	return group.RemoveMemberContext(context.Background(), id, userId, leftAt)
+}
+
+// RemoveMemberContext ends the membership of a user at leftAt, or now when leftAt is zero.
+func (group *Group) RemoveMemberContext(ctx context.Context, id string, userId string, leftAt time.Time, opts ...CallOption) (api.RemoveGroupMemberResponse, error) {
+	u := fmt.Sprintf("%s/%s/member/%s", group.getBaseUrl(), id, userId)
+	if !leftAt.IsZero() {
+		u = fmt.Sprintf("%s?leftAt=%s", u, strconv.FormatInt(leftAt.UnixMilli(), 10))
+	}
+
+	var response api.RemoveGroupMemberResponse
+	err := group.transport.do(ctx, call{
+		method:   http.MethodDelete,
+		url:      u,
+		response: &response,
+		opts:     opts,
+	})
+	if err != nil {
+		return api.RemoveGroupMemberResponse{}, err
+	}
+	return response, nil
+}
+
+func (group *Group) GetGroupGains(request api.GetGroupGainsRequest) (api.GetGroupGainsResponse, error) {
+	return group.GetGroupGainsContext(context.Background(), request)
+}
+
+// GetGroupGainsContext is a read-only POST, so it is retried like a GET.
+func (group *Group) GetGroupGainsContext(ctx context.Context, request api.GetGroupGainsRequest, opts ...CallOption) (api.GetGroupGainsResponse, error) {
+	var response api.GetGroupGainsResponse
+	err := group.transport.do(ctx, call{
+		method:     http.MethodPost,
+		url:        fmt.Sprintf("%s/gains", group.getBaseUrl()),
+		body:       request,
+		response:   &response,
+		idempotent: true,
+		opts:       opts,
+	})
+	if err != nil {
+		return api.GetGroupGainsResponse{}, err
+	}
+	return response, nil
+}
+
+func (group *Group) getBaseUrl() string {
+	return group.transport.v1Url(group.prefix)
+}
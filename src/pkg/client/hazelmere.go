@@ -2,19 +2,49 @@ package client
 
 import (
 	"errors"
+	"net/http"
+	"time"
+
 	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
 	"github.com/ctfloyd/hazelmere-commons/pkg/hz_client"
 )
 
 var ErrHazelmereClient = errors.New("generic hazelmere client error")
+var ErrHazelmereInternal = errors.Join(ErrHazelmereClient, errors.New("internal service error"))
+var ErrHazelmereBadRequest = errors.Join(ErrHazelmereClient, errors.New("bad request"))
 var ErrHazelmereUnauthorized = errors.Join(ErrHazelmereClient, errors.New("unauthorized"))
+var ErrHazelmereForbidden = errors.Join(ErrHazelmereClient, errors.New("forbidden"))
+var ErrHazelmereRateLimited = errors.Join(ErrHazelmereClient, errors.New("rate limited"))
 var ErrIllegalArgument = errors.Join(ErrHazelmereClient, errors.New("illegal argument"))
 
+// RateLimitError is returned for calls rejected with 429 and matches ErrHazelmereRateLimited.
+// RetryAfter is how long the API asked callers to wait, zero when it did not say.
+type RateLimitError struct {
+	RetryAfter time.Duration
+	err        error
+}
+
+func (e *RateLimitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.err
+}
+
+// defaultTimeout bounds each attempt when no http.Client is supplied.
+const defaultTimeout = 10 * time.Second
+
 type Hazelmere struct {
 	Snapshot *Snapshot
 	User     *User
 	Worker   *Worker
 	Delta    *Delta
+	Group    *Group
+	Token    *Token
+	Audit    *Audit
+	Health   *Health
+	OpenAPI  *OpenAPI
 	Config   HazelmereConfig
 }
 
@@ -23,21 +53,50 @@ type HazelmereConfig struct {
 	CallingApplication string
 }
 
-func NewHazelmere(client *hz_client.HttpClient, config HazelmereConfig) (*Hazelmere, error) {
-	if client == nil {
-		return nil, errors.Join(ErrIllegalArgument, errors.New("client is nil"))
+// New creates a client for the API at host, e.g. https://api.hazelmere.xyz.
+func New(host string, config HazelmereConfig, opts ...ClientOption) (*Hazelmere, error) {
+	if host == "" {
+		return nil, errors.Join(ErrIllegalArgument, errors.New("host is empty"))
 	}
 
-	mappings := map[string]error{
-		api.ErrorCodeUnauthorized: ErrHazelmereUnauthorized,
+	options := clientOptions{
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		retry:      DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(&options)
 	}
-	client.AddErrorMappings(mappings)
+
+	t := newTransport(host, options.httpClient, config, options.retry)
+	t.addErrorMappings(map[string]error{
+		api.ErrorCodeInternal:     ErrHazelmereInternal,
+		api.ErrorCodeBadRequest:   ErrHazelmereBadRequest,
+		api.ErrorCodeUnauthorized: ErrHazelmereUnauthorized,
+		api.ErrorCodeForbidden:    ErrHazelmereForbidden,
+		api.ErrorCodeRateLimited:  ErrHazelmereRateLimited,
+	})
 
 	return &Hazelmere{
-		Snapshot: newSnapshot(client, config),
-		User:     newUser(client, config),
-		Worker:   newWorker(client, config),
-		Delta:    newDelta(client, config),
+		Snapshot: newSnapshot(t),
+		User:     newUser(t),
+		Worker:   newWorker(t),
+		Delta:    newDelta(t),
+		Group:    newGroup(t),
+		Token:    newToken(t),
+		Audit:    newAudit(t),
+		Health:   newHealth(t),
+		OpenAPI:  newOpenAPI(t),
 		Config:   config,
 	}, nil
 }
+
+// NewHazelmere creates a client for the host of an hz_client.HttpClient. Only the host is used;
+// calls go through the client's own transport with the default timeout and retry policy.
+//
+// Deprecated: use New, which takes the host directly and accepts options.
+func NewHazelmere(client *hz_client.HttpClient, config HazelmereConfig) (*Hazelmere, error) {
+	if client == nil {
+		return nil, errors.Join(ErrIllegalArgument, errors.New("client is nil"))
+	}
+	return New(client.GetHost(), config)
+}
@@ -2,13 +2,6 @@ package client
 
 type Option func(map[string]string)
 
-func makeHeadersFromConfig(config HazelmereConfig) map[string]string {
-	return makeHeaders(
-		withToken(config.Token),
-		withCallingApplication(config.CallingApplication),
-	)
-}
-
 func makeHeaders(opts ...Option) map[string]string {
 	headers := make(map[string]string)
 	for _, opt := range opts {
@@ -0,0 +1,42 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+)
+
+type Health struct {
+	path      string
+	transport *transport
+}
+
+func newHealth(t *transport) *Health {
+	return &Health{
+		path:      "health",
+		transport: t,
+	}
+}
+
+func (health *Health) Check() (api.HealthResponse, error) {
+	return health.CheckContext(context.Background())
+}
+
+// CheckContext returns the health report. An unhealthy service still returns its report, with
+// Status set to api.HealthStatusUnhealthy, rather than an error.
+func (health *Health) CheckContext(ctx context.Context, opts ...CallOption) (api.HealthResponse, error) {
+	var response api.HealthResponse
+	err := health.transport.do(ctx, call{
+		method:     http.MethodGet,
+		url:        health.transport.url(health.path),
+		response:   &response,
+		idempotent: true,
+		okStatuses: []int{http.StatusServiceUnavailable},
+		opts:       opts,
+	})
+	if err != nil {
+		return api.HealthResponse{}, err
+	}
+	return response, nil
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+type OpenAPI struct {
+	path      string
+	transport *transport
+}
+
+func newOpenAPI(t *transport) *OpenAPI {
+	return &OpenAPI{
+		path:      "openapi.json",
+		transport: t,
+	}
+}
+
+func (openAPI *OpenAPI) GetDocument() (json.RawMessage, error) {
+	return openAPI.GetDocumentContext(context.Background())
+}
+
+// GetDocumentContext returns the OpenAPI 3 document describing the API.
+func (openAPI *OpenAPI) GetDocumentContext(ctx context.Context, opts ...CallOption) (json.RawMessage, error) {
+	var document []byte
+	err := openAPI.transport.do(ctx, call{
+		method:     http.MethodGet,
+		url:        openAPI.transport.url(openAPI.path),
+		response:   &document,
+		idempotent: true,
+		opts:       opts,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(document), nil
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"net/http"
+	"time"
+)
+
+// ClientOption configures a Hazelmere client created with New.
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	httpClient *http.Client
+	retry      RetryPolicy
+}
+
+// WithHTTPClient sets the http.Client used for every call. Its Timeout bounds each attempt.
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(o *clientOptions) {
+		o.httpClient = httpClient
+	}
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(o *clientOptions) {
+		o.retry = policy
+	}
+}
+
+// CallOption configures a single call made through one of the Context methods.
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	timeout        time.Duration
+	idempotencyKey string
+	token          string
+	caller         string
+}
+
+func newCallOptions(config HazelmereConfig, opts []CallOption) callOptions {
+	options := callOptions{
+		token:  config.Token,
+		caller: config.CallingApplication,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+func (o callOptions) headers() map[string]string {
+	headers := makeHeaders(
+		withToken(o.token),
+		withCallingApplication(o.caller),
+	)
+	headers["Accept"] = "application/json"
+	if o.idempotencyKey != "" {
+		headers["Idempotency-Key"] = o.idempotencyKey
+	}
+	return headers
+}
+
+// WithTimeout bounds the whole call, including retries.
+func WithTimeout(timeout time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithIdempotencyKey sends an Idempotency-Key header, with which the API replays its first
+// response to a repeated write instead of running it again. Calls made with a key are retried
+// like idempotent ones. Use a new key for each distinct write.
+func WithIdempotencyKey(key string) CallOption {
+	return func(o *callOptions) {
+		o.idempotencyKey = key
+	}
+}
+
+// WithCaller overrides the x-hz-caller header set from HazelmereConfig.CallingApplication.
+func WithCaller(caller string) CallOption {
+	return func(o *callOptions) {
+		o.caller = caller
+	}
+}
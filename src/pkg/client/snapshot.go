@@ -1,39 +1,49 @@
 package client
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net/http"
+
 	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
-	"github.com/ctfloyd/hazelmere-commons/pkg/hz_client"
 )
 
 var ErrSnapshotNotFound = errors.Join(ErrHazelmereClient, errors.New("snapshot not found"))
 var ErrInvalidSnapshot = errors.Join(ErrHazelmereClient, errors.New("invalid snapshot"))
 
 type Snapshot struct {
-	prefix string
-	client *hz_client.HttpClient
-	config HazelmereConfig
+	prefix        string
+	summaryPrefix string
+	transport     *transport
 }
 
-func newSnapshot(client *hz_client.HttpClient, config HazelmereConfig) *Snapshot {
-	mappings := map[string]error{
+func newSnapshot(t *transport) *Snapshot {
+	t.addErrorMappings(map[string]error{
 		api.ErrorCodeSnapshotNotFound: ErrSnapshotNotFound,
 		api.ErrorCodeInvalidSnapshot:  ErrInvalidSnapshot,
-	}
-	client.AddErrorMappings(mappings)
+	})
 
 	return &Snapshot{
-		prefix: "snapshot",
-		client: client,
-		config: config,
+		prefix:        "snapshot",
+		summaryPrefix: "summary/delta",
+		transport:     t,
 	}
 }
 
 func (ss *Snapshot) GetAllSnapshotsForUser(userId string) (api.GetAllSnapshotsForUser, error) {
-	url := fmt.Sprintf("%s/%s", ss.getBaseUrl(), userId)
+	return ss.GetAllSnapshotsForUserContext(context.Background(), userId)
+}
+
+func (ss *Snapshot) GetAllSnapshotsForUserContext(ctx context.Context, userId string, opts ...CallOption) (api.GetAllSnapshotsForUser, error) {
 	var response api.GetAllSnapshotsForUser
-	err := ss.client.GetWithHeaders(url, makeHeadersFromConfig(ss.config), &response)
+	err := ss.transport.do(ctx, call{
+		method:     http.MethodGet,
+		url:        fmt.Sprintf("%s/%s", ss.getBaseUrl(), userId),
+		response:   &response,
+		idempotent: true,
+		opts:       opts,
+	})
 	if err != nil {
 		return api.GetAllSnapshotsForUser{}, err
 	}
@@ -41,9 +51,18 @@ func (ss *Snapshot) GetAllSnapshotsForUser(userId string) (api.GetAllSnapshotsFo
 }
 
 func (ss *Snapshot) GetSnapshotForUserNearestTimestamp(userId string, epochMillis int64) (api.GetSnapshotNearestTimestampResponse, error) {
-	url := fmt.Sprintf("%s/%s/nearest/%d", ss.getBaseUrl(), userId, epochMillis)
+	return ss.GetSnapshotForUserNearestTimestampContext(context.Background(), userId, epochMillis)
+}
+
+func (ss *Snapshot) GetSnapshotForUserNearestTimestampContext(ctx context.Context, userId string, epochMillis int64, opts ...CallOption) (api.GetSnapshotNearestTimestampResponse, error) {
 	var response api.GetSnapshotNearestTimestampResponse
-	err := ss.client.GetWithHeaders(url, makeHeadersFromConfig(ss.config), &response)
+	err := ss.transport.do(ctx, call{
+		method:     http.MethodGet,
+		url:        fmt.Sprintf("%s/%s/nearest/%d", ss.getBaseUrl(), userId, epochMillis),
+		response:   &response,
+		idempotent: true,
+		opts:       opts,
+	})
 	if err != nil {
 		return api.GetSnapshotNearestTimestampResponse{}, err
 	}
@@ -51,15 +70,99 @@ func (ss *Snapshot) GetSnapshotForUserNearestTimestamp(userId string, epochMilli
 }
 
 func (ss *Snapshot) CreateSnapshot(request api.CreateSnapshotRequest) (api.CreateSnapshotResponse, error) {
-	url := ss.getBaseUrl()
+	return ss.CreateSnapshotContext(context.Background(), request)
+}
+
+func (ss *Snapshot) CreateSnapshotContext(ctx context.Context, request api.CreateSnapshotRequest, opts ...CallOption) (api.CreateSnapshotResponse, error) {
 	var response api.CreateSnapshotResponse
-	err := ss.client.PostWithHeaders(url, makeHeadersFromConfig(ss.config), request, &response)
+	err := ss.transport.do(ctx, call{
+		method:   http.MethodPost,
+		url:      ss.getBaseUrl(),
+		body:     request,
+		response: &response,
+		opts:     opts,
+	})
 	if err != nil {
 		return api.CreateSnapshotResponse{}, err
 	}
 	return response, nil
 }
 
+func (ss *Snapshot) GetSnapshotInterval(request api.GetSnapshotIntervalRequest) (api.GetSnapshotIntervalResponse, error) {
+	return ss.GetSnapshotIntervalContext(context.Background(), request)
+}
+
+// GetSnapshotIntervalContext is a read-only POST, so it is retried like a GET.
+func (ss *Snapshot) GetSnapshotIntervalContext(ctx context.Context, request api.GetSnapshotIntervalRequest, opts ...CallOption) (api.GetSnapshotIntervalResponse, error) {
+	var response api.GetSnapshotIntervalResponse
+	err := ss.transport.do(ctx, call{
+		method:     http.MethodPost,
+		url:        fmt.Sprintf("%s/interval", ss.getBaseUrl()),
+		body:       request,
+		response:   &response,
+		idempotent: true,
+		opts:       opts,
+	})
+	if err != nil {
+		return api.GetSnapshotIntervalResponse{}, err
+	}
+	return response, nil
+}
+
+func (ss *Snapshot) GetSnapshotWithDeltas(request api.GetSnapshotWithDeltasRequest) (api.GetSnapshotWithDeltasResponse, error) {
+	return ss.GetSnapshotWithDeltasContext(context.Background(), request)
+}
+
+// GetSnapshotWithDeltasContext returns the snapshot nearest the start time and every delta up to
+// the end time. It is a read-only POST, so it is retried like a GET.
+func (ss *Snapshot) GetSnapshotWithDeltasContext(ctx context.Context, request api.GetSnapshotWithDeltasRequest, opts ...CallOption) (api.GetSnapshotWithDeltasResponse, error) {
+	var response api.GetSnapshotWithDeltasResponse
+	err := ss.transport.do(ctx, call{
+		method:     http.MethodPost,
+		url:        ss.transport.v1Url(ss.summaryPrefix),
+		body:       request,
+		response:   &response,
+		idempotent: true,
+		opts:       opts,
+	})
+	if err != nil {
+		return api.GetSnapshotWithDeltasResponse{}, err
+	}
+	return response, nil
+}
+
+func (ss *Snapshot) GetSnapshotWithDeltasBinary(request api.GetSnapshotWithDeltasRequest) (api.GetSnapshotWithDeltasResponse, error) {
+	return ss.GetSnapshotWithDeltasBinaryContext(context.Background(), request)
+}
+
+// GetSnapshotWithDeltasBinaryContext is GetSnapshotWithDeltasContext over the compact binary
+// encoding. The binary format omits ids, names and ranks, so those fields are left empty.
+func (ss *Snapshot) GetSnapshotWithDeltasBinaryContext(ctx context.Context, request api.GetSnapshotWithDeltasRequest, opts ...CallOption) (api.GetSnapshotWithDeltasResponse, error) {
+	var body []byte
+	err := ss.transport.do(ctx, call{
+		method:     http.MethodPost,
+		url:        ss.transport.v1Url(ss.summaryPrefix),
+		accept:     BinaryContentType,
+		body:       request,
+		response:   &body,
+		idempotent: true,
+		opts:       opts,
+	})
+	if err != nil {
+		return api.GetSnapshotWithDeltasResponse{}, err
+	}
+
+	response, err := DecodeDeltaSummaryBinary(body)
+	if err != nil {
+		return api.GetSnapshotWithDeltasResponse{}, err
+	}
+	response.Snapshot.UserId = request.UserId
+	for i := range response.Deltas {
+		response.Deltas[i].UserId = request.UserId
+	}
+	return response, nil
+}
+
 func (ss *Snapshot) getBaseUrl() string {
-	return fmt.Sprintf("%s/%s", ss.client.GetV1Url(), ss.prefix)
+	return ss.transport.v1Url(ss.prefix)
 }
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+)
+
+var ErrTokenNotFound = errors.Join(ErrHazelmereClient, errors.New("token not found"))
+var ErrInvalidToken = errors.Join(ErrHazelmereClient, errors.New("invalid token"))
+
+// Token manages API tokens. All operations require a token with the admin scope.
+type Token struct {
+	prefix    string
+	transport *transport
+}
+
+func newToken(t *transport) *Token {
+	t.addErrorMappings(map[string]error{
+		api.ErrorCodeTokenNotFound: ErrTokenNotFound,
+		api.ErrorCodeInvalidToken:  ErrInvalidToken,
+	})
+
+	return &Token{
+		prefix:    "admin/token",
+		transport: t,
+	}
+}
+
+func (token *Token) GetAllTokens() (api.GetAllTokensResponse, error) {
+	return token.GetAllTokensContext(context.Background())
+}
+
+func (token *Token) GetAllTokensContext(ctx context.Context, opts ...CallOption) (api.GetAllTokensResponse, error) {
+	var response api.GetAllTokensResponse
+	err := token.transport.do(ctx, call{
+		method:     http.MethodGet,
+		url:        token.getBaseUrl(),
+		response:   &response,
+		idempotent: true,
+		opts:       opts,
+	})
+	if err != nil {
+		return api.GetAllTokensResponse{}, err
+	}
+	return response, nil
+}
+
+func (token *Token) IssueToken(request api.IssueTokenRequest) (api.IssueTokenResponse, error) {
+	return token.IssueTokenContext(context.Background(), request)
+}
+
+func (token *Token) IssueTokenContext(ctx context.Context, request api.IssueTokenRequest, opts ...CallOption) (api.IssueTokenResponse, error) {
+	var response api.IssueTokenResponse
+	err := token.transport.do(ctx, call{
+		method:   http.MethodPost,
+		url:      token.getBaseUrl(),
+		body:     request,
+		response: &response,
+		opts:     opts,
+	})
+	if err != nil {
+		return api.IssueTokenResponse{}, err
+	}
+	return response, nil
+}
+
+func (token *Token) RotateToken(id string) (api.RotateTokenResponse, error) {
+	return token.RotateTokenContext(context.Background(), id)
+}
+
+func (token *Token) RotateTokenContext(ctx context.Context, id string, opts ...CallOption) (api.RotateTokenResponse, error) {
+	var response api.RotateTokenResponse
+	err := token.transport.do(ctx, call{
+		method:   http.MethodPost,
+		url:      fmt.Sprintf("%s/%s/rotate", token.getBaseUrl(), id),
+		response: &response,
+		opts:     opts,
+	})
+	if err != nil {
+		return api.RotateTokenResponse{}, err
+	}
+	return response, nil
+}
+
+func (token *Token) RevokeToken(id string) (api.RevokeTokenResponse, error) {
+	return token.RevokeTokenContext(context.Background(), id)
+}
+
+// RevokeTokenContext is safe to repeat, so it is retried like a GET.
+func (token *Token) RevokeTokenContext(ctx context.Context, id string, opts ...CallOption) (api.RevokeTokenResponse, error) {
+	var response api.RevokeTokenResponse
+	err := token.transport.do(ctx, call{
+		method:     http.MethodPost,
+		url:        fmt.Sprintf("%s/%s/revoke", token.getBaseUrl(), id),
+		response:   &response,
+		idempotent: true,
+		opts:       opts,
+	})
+	if err != nil {
+		return api.RevokeTokenResponse{}, err
+	}
+	return response, nil
+}
+
+func (token *Token) getBaseUrl() string {
+	return token.transport.v1Url(token.prefix)
+}
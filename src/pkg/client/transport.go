@@ -0,0 +1,232 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetryPolicy controls how failed calls are retried. Only idempotent calls, and calls made
+// WithIdempotencyKey, are retried on transport errors, 429 and 5xx responses; any call is retried
+// on ErrHiscoreTimeout. Calls are
+// never retried on ErrWorkerUnavailable, which lasts longer than any retry would wait. Waits use
+// full jitter: a random duration up to min(MaxWait, BaseWait * 2^attempt), but never less than
+// a Retry-After sent with a 429 or 503.
+type RetryPolicy struct {
+	MaxRetries int
+	BaseWait   time.Duration
+	MaxWait    time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 2,
+	BaseWait:   200 * time.Millisecond,
+	MaxWait:    2 * time.Second,
+}
+
+// errorResponse is the error body written by every API error.
+type errorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// transport performs API calls: it builds headers, maps error codes to typed errors and
+// retries according to the retry policy.
+type transport struct {
+	host       string
+	httpClient *http.Client
+	config     HazelmereConfig
+	retry      RetryPolicy
+
+	mu       sync.RWMutex
+	errorMap map[string]error
+}
+
+func newTransport(host string, httpClient *http.Client, config HazelmereConfig, retry RetryPolicy) *transport {
+	return &transport{
+		host:       host,
+		httpClient: httpClient,
+		config:     config,
+		retry:      retry,
+		errorMap:   make(map[string]error),
+	}
+}
+
+func (t *transport) addErrorMappings(mappings map[string]error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for code, err := range mappings {
+		t.errorMap[code] = err
+	}
+}
+
+func (t *transport) v1Url(path string) string {
+	return fmt.Sprintf("%s/v1/%s", t.host, path)
+}
+
+// url is for the few routes served outside /v1, such as /health.
+func (t *transport) url(path string) string {
+	return fmt.Sprintf("%s/%s", t.host, path)
+}
+
+// call is a single API call. Idempotent calls may be retried on any retryable failure. A response
+// of type *[]byte receives the raw body instead of decoded JSON.
+type call struct {
+	method     string
+	url        string
+	accept     string
+	body       any
+	response   any
+	idempotent bool
+	// okStatuses are non-2xx statuses whose body is a regular response, e.g. an unhealthy 503.
+	okStatuses []int
+	opts       []CallOption
+}
+
+func (t *transport) do(ctx context.Context, c call) error {
+	options := newCallOptions(t.config, c.opts)
+	if options.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.timeout)
+		defer cancel()
+	}
+
+	var payload []byte
+	if c.body != nil {
+		var err error
+		payload, err = json.Marshal(c.body)
+		if err != nil {
+			return errors.Join(ErrIllegalArgument, err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		retryable, retryAfter, err := t.attempt(ctx, c, options, payload)
+		if err == nil {
+			return nil
+		}
+
+		// The API dedupes writes sent with a key, so they are as safe to retry as idempotent calls.
+		idempotent := c.idempotent || options.idempotencyKey != ""
+		retryable = (retryable && idempotent && !errors.Is(err, ErrWorkerUnavailable)) || errors.Is(err, ErrHiscoreTimeout)
+		if !retryable || attempt >= t.retry.MaxRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(max(t.backoff(attempt), retryAfter)):
+		}
+	}
+}
+
+// attempt sends the request once. It reports whether a failure is safe to retry for idempotent
+// calls, and the least time the API asked to wait before retrying.
+func (t *transport) attempt(ctx context.Context, c call, options callOptions, payload []byte) (bool, time.Duration, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, c.method, c.url, body)
+	if err != nil {
+		return false, 0, errors.Join(ErrIllegalArgument, err)
+	}
+	for name, value := range options.headers() {
+		request.Header.Set(name, value)
+	}
+	if payload != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if c.accept != "" {
+		request.Header.Set("Accept", c.accept)
+	}
+
+	res, err := t.httpClient.Do(request)
+	if err != nil {
+		// A cancelled or expired context is final, anything else is a transport failure.
+		return ctx.Err() == nil, 0, errors.Join(ErrHazelmereClient, err)
+	}
+	defer res.Body.Close()
+
+	responseBytes, err := io.ReadAll(res.Body)
+	if err != nil {
+		return true, 0, errors.Join(ErrHazelmereClient, err)
+	}
+
+	if res.StatusCode >= 200 && res.StatusCode <= 299 || slices.Contains(c.okStatuses, res.StatusCode) {
+		if c.response == nil {
+			return false, 0, nil
+		}
+		if raw, ok := c.response.(*[]byte); ok {
+			*raw = responseBytes
+			return false, 0, nil
+		}
+		if err := json.Unmarshal(responseBytes, c.response); err != nil {
+			return false, 0, errors.Join(ErrHazelmereClient, err)
+		}
+		return false, 0, nil
+	}
+
+	retryable := res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+	err = t.mapError(res.StatusCode, responseBytes)
+	if res.StatusCode != http.StatusTooManyRequests && res.StatusCode != http.StatusServiceUnavailable {
+		return retryable, 0, err
+	}
+
+	retryAfter := parseRetryAfter(res.Header.Get("Retry-After"))
+	if res.StatusCode == http.StatusTooManyRequests {
+		err = &RateLimitError{RetryAfter: retryAfter, err: err}
+	}
+	return retryable, retryAfter, err
+}
+
+func (t *transport) mapError(status int, body []byte) error {
+	var response errorResponse
+	if err := json.Unmarshal(body, &response); err != nil || response.Code == "" {
+		return errors.Join(ErrHazelmereClient, fmt.Errorf("unexpected status %d", status))
+	}
+
+	return t.codeError(response.Code, response.Message)
+}
+
+// codeError maps an API error code to the error registered for it by the resource clients.
+func (t *transport) codeError(code string, message string) error {
+	t.mu.RLock()
+	mapped, ok := t.errorMap[code]
+	t.mu.RUnlock()
+	if ok {
+		return errors.Join(mapped, errors.New(message))
+	}
+	return errors.Join(ErrHazelmereClient, fmt.Errorf("[%s] - %s", code, message))
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds. Anything else means no minimum wait.
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func (t *transport) backoff(attempt int) time.Duration {
+	ceiling := t.retry.BaseWait << attempt
+	if ceiling <= 0 || ceiling > t.retry.MaxWait {
+		ceiling = t.retry.MaxWait
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling)
+}
@@ -0,0 +1,111 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
+	"github.com/ctfloyd/hazelmere-api/src/pkg/client"
+)
+
+// newRateLimitedServer rejects the first rejections requests with 429 and Retry-After: 1, then
+// serves an empty user list.
+func newRateLimitedServer(t *testing.T, rejections int32) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if calls.Add(1) <= rejections {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"code":"` + api.ErrorCodeRateLimited + `","message":"Too many requests."}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"users":[]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestTransportWaitsAtLeastRetryAfter(t *testing.T) {
+	server, calls := newRateLimitedServer(t, 1)
+	h, err := client.New(server.URL, client.HazelmereConfig{}, client.WithRetryPolicy(client.RetryPolicy{
+		MaxRetries: 1,
+		BaseWait:   time.Millisecond,
+		MaxWait:    time.Millisecond,
+	}))
+	if err != nil {
+		t.Fatalf("creating client: %v", err)
+	}
+
+	start := time.Now()
+	if _, err := h.User.GetAllUsersContext(context.Background()); err != nil {
+		t.Fatalf("GetAllUsers: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second || calls.Load() != 2 {
+		t.Errorf("GetAllUsers took %s over %d calls, want a retry no sooner than Retry-After", elapsed, calls.Load())
+	}
+}
+
+func TestTransportReturnsRateLimitError(t *testing.T) {
+	server, _ := newRateLimitedServer(t, 1)
+	h, err := client.New(server.URL, client.HazelmereConfig{}, client.WithRetryPolicy(client.RetryPolicy{}))
+	if err != nil {
+		t.Fatalf("creating client: %v", err)
+	}
+
+	_, err = h.User.GetAllUsersContext(context.Background())
+	if !errors.Is(err, client.ErrHazelmereRateLimited) {
+		t.Errorf("GetAllUsers when rate limited: got %v, want ErrHazelmereRateLimited", err)
+	}
+	var rateLimitErr *client.RateLimitError
+	if !errors.As(err, &rateLimitErr) || rateLimitErr.RetryAfter != time.Second {
+		t.Errorf("GetAllUsers when rate limited: got %v, want a RateLimitError with RetryAfter 1s", err)
+	}
+}
+
+func TestTransportRetriesWritesWithAnIdempotencyKey(t *testing.T) {
+	var calls atomic.Int32
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		w.Header().Set("Content-Type", "application/json")
+		if calls.Add(1)%2 == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(`{"code":"` + api.ErrorCodeInternal + `","message":"Bad gateway."}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"snapshot":{}}`))
+	}))
+	t.Cleanup(server.Close)
+	h, err := client.New(server.URL, client.HazelmereConfig{}, client.WithRetryPolicy(client.RetryPolicy{
+		MaxRetries: 1,
+		BaseWait:   time.Millisecond,
+		MaxWait:    time.Millisecond,
+	}))
+	if err != nil {
+		t.Fatalf("creating client: %v", err)
+	}
+	ctx := context.Background()
+
+	if _, err := h.Snapshot.CreateSnapshotContext(ctx, api.CreateSnapshotRequest{}); !errors.Is(err, client.ErrHazelmereInternal) || calls.Load() != 1 {
+		t.Fatalf("CreateSnapshot without a key = %v after %d calls, want ErrHazelmereInternal without a retry", err, calls.Load())
+	}
+
+	calls.Store(0)
+	keys = nil
+	if _, err := h.Snapshot.CreateSnapshotContext(ctx, api.CreateSnapshotRequest{}, client.WithIdempotencyKey("snapshot-1")); err != nil || calls.Load() != 2 {
+		t.Fatalf("CreateSnapshot with a key = %v after %d calls, want success on the retry", err, calls.Load())
+	}
+	for _, key := range keys {
+		if key != "snapshot-1" {
+			t.Errorf("Idempotency-Key = %q, want snapshot-1 on every attempt", key)
+		}
+	}
+}
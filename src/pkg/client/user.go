@@ -1,38 +1,129 @@
 package client
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
 	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
-	"github.com/ctfloyd/hazelmere-commons/pkg/hz_client"
 )
 
 var ErrUserNotFound = errors.Join(ErrHazelmereClient, errors.New("user not found"))
 var ErrInvalidUser = errors.Join(ErrHazelmereClient, errors.New("invalid user"))
+var ErrRunescapeNameAlreadyTracked = errors.Join(ErrHazelmereClient, errors.New("runescape name already tracked"))
+var ErrAccountTypeUndetected = errors.Join(ErrHazelmereClient, errors.New("account type undetected"))
 
 type User struct {
-	prefix string
-	client *hz_client.HttpClient
-	config HazelmereConfig
+	prefix      string
+	adminPrefix string
+	transport   *transport
 }
 
-func newUser(client *hz_client.HttpClient, config HazelmereConfig) *User {
-	mappings := map[string]error{
-		api.ErrorCodeUserNotFound:    ErrUserNotFound,
-		api.ErrorCodeInvalidSnapshot: ErrInvalidUser,
-	}
-	client.AddErrorMappings(mappings)
+// UserQuery searches users. Zero values are ignored; a query with none set returns the first page
+// of all users.
+type UserQuery struct {
+	Name string
+	// Match is "prefix" (the default) or "contains".
+	Match          string
+	TrackingStatus api.TrackingStatus
+	AccountType    api.AccountType
+	CreatedAfter   time.Time
+	// Sort is "name" (the default), "-name", "createdAt" or "-createdAt".
+	Sort string
+	// Cursor is the NextCursor of the previous page.
+	Cursor string
+	Limit  int
+}
+
+func newUser(t *transport) *User {
+	t.addErrorMappings(map[string]error{
+		api.ErrorCodeUserNotFound:                ErrUserNotFound,
+		api.ErrorCodeInvalidUser:                 ErrInvalidUser,
+		api.ErrorCodeRunescapeNameAlreadyTracked: ErrRunescapeNameAlreadyTracked,
+		api.ErrorCodeAccountTypeUndetected:       ErrAccountTypeUndetected,
+	})
 
 	return &User{
-		prefix: "user",
-		client: client,
-		config: config,
+		prefix:      "user",
+		adminPrefix: "admin/user",
+		transport:   t,
 	}
 }
 
 func (user *User) GetAllUsers() (api.GetAllUsersResponse, error) {
+	return user.GetAllUsersContext(context.Background())
+}
+
+// allUsersPageSize is the largest page the API returns.
+const allUsersPageSize = 1000
+
+// GetAllUsersContext returns every user, following the pages the API lists them in.
+func (user *User) GetAllUsersContext(ctx context.Context, opts ...CallOption) (api.GetAllUsersResponse, error) {
+	var all api.GetAllUsersResponse
+	query := UserQuery{Limit: allUsersPageSize}
+	for {
+		page, err := user.SearchUsersContext(ctx, query, opts...)
+		if err != nil {
+			return api.GetAllUsersResponse{}, err
+		}
+		all.Users = append(all.Users, page.Users...)
+		if page.NextCursor == "" {
+			return all, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+func (user *User) SearchUsers(query UserQuery) (api.GetAllUsersResponse, error) {
+	return user.SearchUsersContext(context.Background(), query)
+}
+
+// SearchUsersContext returns a page of the users matching query. Pass NextCursor of the response
+// as Cursor, with the rest of the query unchanged, to get the next page.
+func (user *User) SearchUsersContext(ctx context.Context, query UserQuery, opts ...CallOption) (api.GetAllUsersResponse, error) {
+	params := url.Values{}
+	if query.Name != "" {
+		params.Set("name", query.Name)
+	}
+	if query.Match != "" {
+		params.Set("match", query.Match)
+	}
+	if query.TrackingStatus != "" {
+		params.Set("trackingStatus", string(query.TrackingStatus))
+	}
+	if query.AccountType != "" {
+		params.Set("accountType", string(query.AccountType))
+	}
+	if !query.CreatedAfter.IsZero() {
+		params.Set("createdAfter", strconv.FormatInt(query.CreatedAfter.UnixMilli(), 10))
+	}
+	if query.Sort != "" {
+		params.Set("sort", query.Sort)
+	}
+	if query.Cursor != "" {
+		params.Set("cursor", query.Cursor)
+	}
+	if query.Limit > 0 {
+		params.Set("limit", strconv.Itoa(query.Limit))
+	}
+
+	u := user.getBaseUrl()
+	if len(params) > 0 {
+		u = fmt.Sprintf("%s?%s", u, params.Encode())
+	}
+
 	var response api.GetAllUsersResponse
-	err := user.client.GetWithHeaders(user.getBaseUrl(), makeHeadersFromConfig(user.config), &response)
+	err := user.transport.do(ctx, call{
+		method:     http.MethodGet,
+		url:        u,
+		response:   &response,
+		idempotent: true,
+		opts:       opts,
+	})
 	if err != nil {
 		return api.GetAllUsersResponse{}, err
 	}
@@ -40,18 +131,58 @@ func (user *User) GetAllUsers() (api.GetAllUsersResponse, error) {
 }
 
 func (user *User) GetUserById(id string) (api.GetUserByIdResponse, error) {
-	url := fmt.Sprintf("%s/%s", user.getBaseUrl(), id)
+	return user.GetUserByIdContext(context.Background(), id)
+}
+
+func (user *User) GetUserByIdContext(ctx context.Context, id string, opts ...CallOption) (api.GetUserByIdResponse, error) {
 	var response api.GetUserByIdResponse
-	err := user.client.GetWithHeaders(url, makeHeadersFromConfig(user.config), &response)
+	err := user.transport.do(ctx, call{
+		method:     http.MethodGet,
+		url:        fmt.Sprintf("%s/%s", user.getBaseUrl(), id),
+		response:   &response,
+		idempotent: true,
+		opts:       opts,
+	})
 	if err != nil {
 		return api.GetUserByIdResponse{}, err
 	}
 	return response, nil
 }
 
+// GetUserByName finds a user by their current or a past runescape name. Names are compared the
+// way OSRS does, see api.NormalizeRunescapeName.
+func (user *User) GetUserByName(name string) (api.GetUserByNameResponse, error) {
+	return user.GetUserByNameContext(context.Background(), name)
+}
+
+func (user *User) GetUserByNameContext(ctx context.Context, name string, opts ...CallOption) (api.GetUserByNameResponse, error) {
+	var response api.GetUserByNameResponse
+	err := user.transport.do(ctx, call{
+		method:     http.MethodGet,
+		url:        fmt.Sprintf("%s/name/%s", user.getBaseUrl(), url.PathEscape(name)),
+		response:   &response,
+		idempotent: true,
+		opts:       opts,
+	})
+	if err != nil {
+		return api.GetUserByNameResponse{}, err
+	}
+	return response, nil
+}
+
 func (user *User) CreateUser(request api.CreateUserRequest) (api.CreateUserResponse, error) {
+	return user.CreateUserContext(context.Background(), request)
+}
+
+func (user *User) CreateUserContext(ctx context.Context, request api.CreateUserRequest, opts ...CallOption) (api.CreateUserResponse, error) {
 	var response api.CreateUserResponse
-	err := user.client.PostWithHeaders(user.getBaseUrl(), makeHeadersFromConfig(user.config), request, &response)
+	err := user.transport.do(ctx, call{
+		method:   http.MethodPost,
+		url:      user.getBaseUrl(),
+		body:     request,
+		response: &response,
+		opts:     opts,
+	})
 	if err != nil {
 		return api.CreateUserResponse{}, err
 	}
@@ -59,14 +190,160 @@ func (user *User) CreateUser(request api.CreateUserRequest) (api.CreateUserRespo
 }
 
 func (user *User) UpdateUser(request api.UpdateUserRequest) (api.UpdateUserResponse, error) {
+	return user.UpdateUserContext(context.Background(), request)
+}
+
+func (user *User) UpdateUserContext(ctx context.Context, request api.UpdateUserRequest, opts ...CallOption) (api.UpdateUserResponse, error) {
 	var response api.UpdateUserResponse
-	err := user.client.PatchWithHeaders(user.getBaseUrl(), makeHeadersFromConfig(user.config), request, &response)
+	err := user.transport.do(ctx, call{
+		method:   http.MethodPut,
+		url:      user.getBaseUrl(),
+		body:     request,
+		response: &response,
+		opts:     opts,
+	})
 	if err != nil {
 		return api.UpdateUserResponse{}, err
 	}
 	return response, nil
 }
 
+func (user *User) RenameUser(id string, request api.RenameUserRequest) (api.RenameUserResponse, error) {
+	return user.RenameUserContext(context.Background(), id, request)
+}
+
+func (user *User) RenameUserContext(ctx context.Context, id string, request api.RenameUserRequest, opts ...CallOption) (api.RenameUserResponse, error) {
+	var response api.RenameUserResponse
+	err := user.transport.do(ctx, call{
+		method:   http.MethodPost,
+		url:      fmt.Sprintf("%s/%s/rename", user.getBaseUrl(), id),
+		body:     request,
+		response: &response,
+		opts:     opts,
+	})
+	if err != nil {
+		return api.RenameUserResponse{}, err
+	}
+	return response, nil
+}
+
+func (user *User) MergeUsers(request api.MergeUsersRequest) (api.MergeUsersResponse, error) {
+	return user.MergeUsersContext(context.Background(), request)
+}
+
+func (user *User) MergeUsersContext(ctx context.Context, request api.MergeUsersRequest, opts ...CallOption) (api.MergeUsersResponse, error) {
+	var response api.MergeUsersResponse
+	err := user.transport.do(ctx, call{
+		method:   http.MethodPost,
+		url:      fmt.Sprintf("%s/merge", user.transport.v1Url(user.adminPrefix)),
+		body:     request,
+		response: &response,
+		opts:     opts,
+	})
+	if err != nil {
+		return api.MergeUsersResponse{}, err
+	}
+	return response, nil
+}
+
+func (user *User) ReportAccountType(id string, request api.ReportAccountTypeRequest) (api.ReportAccountTypeResponse, error) {
+	return user.ReportAccountTypeContext(context.Background(), id, request)
+}
+
+// ReportAccountTypeContext records the account type a check found. A report with DetectedAt set
+// changes nothing when repeated, so it is retried like a read.
+func (user *User) ReportAccountTypeContext(ctx context.Context, id string, request api.ReportAccountTypeRequest, opts ...CallOption) (api.ReportAccountTypeResponse, error) {
+	var response api.ReportAccountTypeResponse
+	err := user.transport.do(ctx, call{
+		method:     http.MethodPost,
+		url:        fmt.Sprintf("%s/%s/account-type", user.getBaseUrl(), id),
+		body:       request,
+		response:   &response,
+		idempotent: request.DetectedAt != nil,
+		opts:       opts,
+	})
+	if err != nil {
+		return api.ReportAccountTypeResponse{}, err
+	}
+	return response, nil
+}
+
+func (user *User) CheckAccountType(id string) (api.ReportAccountTypeResponse, error) {
+	return user.CheckAccountTypeContext(context.Background(), id)
+}
+
+func (user *User) CheckAccountTypeContext(ctx context.Context, id string, opts ...CallOption) (api.ReportAccountTypeResponse, error) {
+	var response api.ReportAccountTypeResponse
+	err := user.transport.do(ctx, call{
+		method:   http.MethodPost,
+		url:      fmt.Sprintf("%s/%s/account-type/check", user.getBaseUrl(), id),
+		response: &response,
+		opts:     opts,
+	})
+	if err != nil {
+		return api.ReportAccountTypeResponse{}, err
+	}
+	return response, nil
+}
+
+func (user *User) DeleteUser(id string) (api.DeleteUserResponse, error) {
+	return user.DeleteUserContext(context.Background(), id)
+}
+
+// DeleteUserContext soft deletes a user. Deleting an already deleted user succeeds, so it is
+// retried like a read.
+func (user *User) DeleteUserContext(ctx context.Context, id string, opts ...CallOption) (api.DeleteUserResponse, error) {
+	var response api.DeleteUserResponse
+	err := user.transport.do(ctx, call{
+		method:     http.MethodDelete,
+		url:        fmt.Sprintf("%s/%s", user.getBaseUrl(), id),
+		response:   &response,
+		idempotent: true,
+		opts:       opts,
+	})
+	if err != nil {
+		return api.DeleteUserResponse{}, err
+	}
+	return response, nil
+}
+
+func (user *User) DeleteUserPermanently(id string) (api.DeleteUserPermanentlyResponse, error) {
+	return user.DeleteUserPermanentlyContext(context.Background(), id)
+}
+
+func (user *User) DeleteUserPermanentlyContext(ctx context.Context, id string, opts ...CallOption) (api.DeleteUserPermanentlyResponse, error) {
+	var response api.DeleteUserPermanentlyResponse
+	err := user.transport.do(ctx, call{
+		method:   http.MethodDelete,
+		url:      fmt.Sprintf("%s/%s", user.transport.v1Url(user.adminPrefix), id),
+		response: &response,
+		opts:     opts,
+	})
+	if err != nil {
+		return api.DeleteUserPermanentlyResponse{}, err
+	}
+	return response, nil
+}
+
+func (user *User) ExportUser(id string) (api.ExportUserResponse, error) {
+	return user.ExportUserContext(context.Background(), id)
+}
+
+func (user *User) ExportUserContext(ctx context.Context, id string, opts ...CallOption) (api.ExportUserResponse, error) {
+	var response api.ExportUserResponse
+	err := user.transport.do(ctx, call{
+		method:     http.MethodGet,
+		url:        fmt.Sprintf("%s/%s/export", user.transport.v1Url(user.adminPrefix), id),
+		response:   &response,
+		idempotent: true,
+		opts:       opts,
+	})
+	if err != nil {
+		return api.ExportUserResponse{}, err
+	}
+	return response, nil
+}
+
 func (user *User) getBaseUrl() string {
-	return fmt.Sprintf("%s/%s", user.client.GetV1Url(), user.prefix)
+	return user.transport.v1Url(user.prefix)
 }
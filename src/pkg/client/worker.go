@@ -1,42 +1,139 @@
 package client
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"time"
+
 	"github.com/ctfloyd/hazelmere-api/src/pkg/api"
-	"github.com/ctfloyd/hazelmere-commons/pkg/hz_client"
 )
 
 var ErrHiscoreTimeout = errors.Join(ErrHazelmereClient, errors.New("osrs hiscore timeout"))
+var ErrJobNotFound = errors.Join(ErrHazelmereClient, errors.New("job not found"))
+var ErrInvalidJob = errors.Join(ErrHazelmereClient, errors.New("invalid job"))
+var ErrJobFailed = errors.Join(ErrHazelmereClient, errors.New("job failed"))
+var ErrWorkerUnavailable = errors.Join(ErrHazelmereClient, errors.New("worker unavailable"))
+
+// DefaultJobPollInterval is how often WaitForJob polls when no interval is given.
+const DefaultJobPollInterval = time.Second
 
 type Worker struct {
-	prefix string
-	client *hz_client.HttpClient
-	config HazelmereConfig
+	prefix    string
+	transport *transport
 }
 
-func newWorker(client *hz_client.HttpClient, config HazelmereConfig) *Worker {
-	mappings := map[string]error{
-		api.ErrorCodeHiscoreTimeout: ErrHiscoreTimeout,
-	}
-	client.AddErrorMappings(mappings)
+func newWorker(t *transport) *Worker {
+	t.addErrorMappings(map[string]error{
+		api.ErrorCodeHiscoreTimeout:    ErrHiscoreTimeout,
+		api.ErrorCodeJobNotFound:       ErrJobNotFound,
+		api.ErrorCodeInvalidJob:        ErrInvalidJob,
+		api.ErrorCodeWorkerUnavailable: ErrWorkerUnavailable,
+	})
+
 	return &Worker{
-		prefix: "worker",
-		client: client,
-		config: config,
+		prefix:    "worker",
+		transport: t,
 	}
 }
 
 func (worker *Worker) GenerateSnapshotOnDemand(userId string) (api.GenerateSnapshotOnDemandResponse, error) {
+	return worker.GenerateSnapshotOnDemandContext(context.Background(), userId)
+}
+
+// GenerateSnapshotOnDemandContext stores a new snapshot, so it is only retried when the OSRS
+// hiscores time out and nothing was written.
+func (worker *Worker) GenerateSnapshotOnDemandContext(ctx context.Context, userId string, opts ...CallOption) (api.GenerateSnapshotOnDemandResponse, error) {
 	var response api.GenerateSnapshotOnDemandResponse
-	url := fmt.Sprintf("%s/snapshot/on-demand/%s", worker.getBaseUrl(), userId)
-	err := worker.client.GetWithHeaders(url, makeHeadersFromConfig(worker.config), &response)
+	err := worker.transport.do(ctx, call{
+		method:   http.MethodGet,
+		url:      fmt.Sprintf("%s/snapshot/on-demand/%s", worker.getBaseUrl(), userId),
+		response: &response,
+		opts:     opts,
+	})
 	if err != nil {
 		return api.GenerateSnapshotOnDemandResponse{}, err
 	}
 	return response, nil
 }
 
+func (worker *Worker) CreateSnapshotJob(request api.CreateSnapshotJobRequest) (api.CreateSnapshotJobResponse, error) {
+	return worker.CreateSnapshotJobContext(context.Background(), request)
+}
+
+// CreateSnapshotJobContext queues an on-demand snapshot and returns without waiting for it.
+// Use WaitForJob, or the request's webhook, to learn how it finished.
+func (worker *Worker) CreateSnapshotJobContext(ctx context.Context, request api.CreateSnapshotJobRequest, opts ...CallOption) (api.CreateSnapshotJobResponse, error) {
+	var response api.CreateSnapshotJobResponse
+	err := worker.transport.do(ctx, call{
+		method:   http.MethodPost,
+		url:      fmt.Sprintf("%s/jobs", worker.getBaseUrl()),
+		body:     request,
+		response: &response,
+		opts:     opts,
+	})
+	if err != nil {
+		return api.CreateSnapshotJobResponse{}, err
+	}
+	return response, nil
+}
+
+func (worker *Worker) GetJob(id string) (api.GetWorkerJobResponse, error) {
+	return worker.GetJobContext(context.Background(), id)
+}
+
+func (worker *Worker) GetJobContext(ctx context.Context, id string, opts ...CallOption) (api.GetWorkerJobResponse, error) {
+	var response api.GetWorkerJobResponse
+	err := worker.transport.do(ctx, call{
+		method:     http.MethodGet,
+		url:        fmt.Sprintf("%s/jobs/%s", worker.getBaseUrl(), id),
+		response:   &response,
+		idempotent: true,
+		opts:       opts,
+	})
+	if err != nil {
+		return api.GetWorkerJobResponse{}, err
+	}
+	return response, nil
+}
+
+// WaitForJob polls the job every pollInterval, or DefaultJobPollInterval when it is zero, until
+// it finishes or ctx is done. A failed job is returned along with ErrJobFailed joined with the
+// error of its code, e.g. ErrHiscoreTimeout.
+func (worker *Worker) WaitForJob(ctx context.Context, id string, pollInterval time.Duration, opts ...CallOption) (api.WorkerJob, error) {
+	if pollInterval <= 0 {
+		pollInterval = DefaultJobPollInterval
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		response, err := worker.GetJobContext(ctx, id, opts...)
+		if err != nil {
+			return api.WorkerJob{}, err
+		}
+
+		job := response.Job
+		if job.Status == api.WorkerJobStatusFailed {
+			if job.Error == nil {
+				return job, ErrJobFailed
+			}
+			return job, errors.Join(ErrJobFailed, worker.transport.codeError(job.Error.Code, job.Error.Message))
+		}
+		if job.Status.Done() {
+			return job, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return job, errors.Join(ErrHazelmereClient, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
 func (worker *Worker) getBaseUrl() string {
-	return fmt.Sprintf("%s/%s", worker.client.GetV1Url(), worker.prefix)
+	return worker.transport.v1Url(worker.prefix)
 }